@@ -482,6 +482,25 @@ func BenchmarkMarshal(b *testing.B) {
 	}
 }
 
+func BenchmarkMarshalerTo(b *testing.B) {
+	v := hotPoint{Name: "point", X: -100, Y: 1000, F: 1.5, G: 0.1, B: []byte{1, 2, 3, 4}, OK: true}
+	for _, tc := range []struct {
+		name string
+		v    interface{}
+	}{
+		{"reflection", toHotPointReflect(&v)},
+		{"MarshalerTo", &v},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Marshal(tc.v); err != nil {
+					b.Fatal("Marshal:", err)
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkMarshalCanonical(b *testing.B) {
 	type strc struct {
 		A string `cbor:"a"`
@@ -662,6 +681,87 @@ func BenchmarkUnmarshalCWTClaimsWithDupMapKeyOpt(b *testing.B) {
 	}
 }
 
+type manyKeyAsIntFields struct {
+	F1  int `cbor:"1,keyasint"`
+	F2  int `cbor:"2,keyasint"`
+	F3  int `cbor:"3,keyasint"`
+	F4  int `cbor:"4,keyasint"`
+	F5  int `cbor:"5,keyasint"`
+	F6  int `cbor:"6,keyasint"`
+	F7  int `cbor:"7,keyasint"`
+	F8  int `cbor:"8,keyasint"`
+	F9  int `cbor:"9,keyasint"`
+	F10 int `cbor:"10,keyasint"`
+	F11 int `cbor:"11,keyasint"`
+	F12 int `cbor:"12,keyasint"`
+	F13 int `cbor:"13,keyasint"`
+	F14 int `cbor:"14,keyasint"`
+	F15 int `cbor:"15,keyasint"`
+	F16 int `cbor:"16,keyasint"`
+	F17 int `cbor:"17,keyasint"`
+	F18 int `cbor:"18,keyasint"`
+	F19 int `cbor:"19,keyasint"`
+	F20 int `cbor:"20,keyasint"`
+	F21 int `cbor:"21,keyasint"`
+	F22 int `cbor:"22,keyasint"`
+	F23 int `cbor:"23,keyasint"`
+	F24 int `cbor:"24,keyasint"`
+}
+
+// manyMixedKeyFields has the same fields as manyKeyAsIntFields and a field with
+// text string key, so its fields are found by linear search instead of jump table.
+type manyMixedKeyFields struct {
+	F1   int    `cbor:"1,keyasint"`
+	F2   int    `cbor:"2,keyasint"`
+	F3   int    `cbor:"3,keyasint"`
+	F4   int    `cbor:"4,keyasint"`
+	F5   int    `cbor:"5,keyasint"`
+	F6   int    `cbor:"6,keyasint"`
+	F7   int    `cbor:"7,keyasint"`
+	F8   int    `cbor:"8,keyasint"`
+	F9   int    `cbor:"9,keyasint"`
+	F10  int    `cbor:"10,keyasint"`
+	F11  int    `cbor:"11,keyasint"`
+	F12  int    `cbor:"12,keyasint"`
+	F13  int    `cbor:"13,keyasint"`
+	F14  int    `cbor:"14,keyasint"`
+	F15  int    `cbor:"15,keyasint"`
+	F16  int    `cbor:"16,keyasint"`
+	F17  int    `cbor:"17,keyasint"`
+	F18  int    `cbor:"18,keyasint"`
+	F19  int    `cbor:"19,keyasint"`
+	F20  int    `cbor:"20,keyasint"`
+	F21  int    `cbor:"21,keyasint"`
+	F22  int    `cbor:"22,keyasint"`
+	F23  int    `cbor:"23,keyasint"`
+	F24  int    `cbor:"24,keyasint"`
+	Name string `cbor:"name"`
+}
+
+func BenchmarkUnmarshalKeyAsIntStruct(b *testing.B) {
+	data, err := Marshal(manyKeyAsIntFields{F1: 1, F12: 1000, F24: -1000})
+	if err != nil {
+		b.Fatal("Marshal:", err)
+	}
+
+	b.Run("jump table", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var v manyKeyAsIntFields
+			if err := Unmarshal(data, &v); err != nil {
+				b.Fatal("Unmarshal:", err)
+			}
+		}
+	})
+	b.Run("linear search", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var v manyMixedKeyFields
+			if err := Unmarshal(data, &v); err != nil {
+				b.Fatal("Unmarshal:", err)
+			}
+		}
+	})
+}
+
 func BenchmarkMarshalCWTClaims(b *testing.B) {
 	// Data from https://tools.ietf.org/html/rfc8392#appendix-A section A.1
 	data := hexDecode("a70175636f61703a2f2f61732e6578616d706c652e636f6d02656572696b77037818636f61703a2f2f6c696768742e6578616d706c652e636f6d041a5612aeb0051a5610d9f0061a5610d9f007420b71")
@@ -0,0 +1,495 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// maxExponentForRat is the max absolute value of exponent of decimal fraction or bigfloat
+// when converting to big.Rat.  It limits memory used to compute 10^exponent or 2^exponent
+// from untrusted data.
+const maxExponentForRat = 1 << 16
+
+var (
+	typeBigFloat        = reflect.TypeOf(big.Float{})
+	typeBigRat          = reflect.TypeOf(big.Rat{})
+	typeDecimalFraction = reflect.TypeOf(DecimalFraction{})
+	typeBigFloatTag     = reflect.TypeOf(BigFloat{})
+)
+
+// DecimalFraction represents CBOR tag 4 (decimal fraction) defined in RFC 8949 Section 3.4.4.
+// Its value is Mantissa * 10^Exponent.
+type DecimalFraction struct {
+	Exponent int64
+	Mantissa big.Int
+}
+
+// NewDecimalFraction returns DecimalFraction with the same value as r.
+// An error is returned if r can't be represented exactly as decimal fraction (e.g. 1/3).
+func NewDecimalFraction(r *big.Rat) (DecimalFraction, error) {
+	exp, mant, ok := ratToDecimalFraction(r)
+	if !ok {
+		return DecimalFraction{}, errors.New("cbor: cannot represent " + r.String() + " as decimal fraction")
+	}
+	return DecimalFraction{Exponent: exp, Mantissa: *mant}, nil
+}
+
+// Rat returns the value of df as *big.Rat.
+func (df DecimalFraction) Rat() (*big.Rat, error) {
+	return exponentMantissaToRat(10, df.Exponent, &df.Mantissa)
+}
+
+// MarshalCBOR encodes DecimalFraction as CBOR tag 4.
+func (df DecimalFraction) MarshalCBOR() ([]byte, error) {
+	return marshalExponentMantissa(tagNumDecimalFraction, df.Exponent, &df.Mantissa)
+}
+
+// UnmarshalCBOR decodes CBOR tag 4 to DecimalFraction.
+// Decoding CBOR null and undefined to DecimalFraction is no-op.
+func (df *DecimalFraction) UnmarshalCBOR(data []byte) error {
+	if df == nil {
+		return errors.New("cbor.DecimalFraction: UnmarshalCBOR on nil pointer")
+	}
+	exp, mant, err := unmarshalExponentMantissa(data, tagNumDecimalFraction, typeDecimalFraction)
+	if err != nil || mant == nil {
+		return err
+	}
+	*df = DecimalFraction{Exponent: exp, Mantissa: *mant}
+	return nil
+}
+
+// BigFloat represents CBOR tag 5 (bigfloat) defined in RFC 8949 Section 3.4.4.
+// Its value is Mantissa * 2^Exponent.
+type BigFloat struct {
+	Exponent int64
+	Mantissa big.Int
+}
+
+// NewBigFloat returns BigFloat with the same value as f.
+// An error is returned if f is infinity.
+func NewBigFloat(f *big.Float) (BigFloat, error) {
+	exp, mant, err := bigFloatToExponentMantissa(f)
+	if err != nil {
+		return BigFloat{}, err
+	}
+	return BigFloat{Exponent: exp, Mantissa: *mant}, nil
+}
+
+// Float returns the value of bf as *big.Float, without rounding.
+func (bf BigFloat) Float() (*big.Float, error) {
+	return exponentMantissaToFloat(new(big.Float), bf.Exponent, &bf.Mantissa)
+}
+
+// Rat returns the value of bf as *big.Rat.
+func (bf BigFloat) Rat() (*big.Rat, error) {
+	return exponentMantissaToRat(2, bf.Exponent, &bf.Mantissa)
+}
+
+// MarshalCBOR encodes BigFloat as CBOR tag 5.
+func (bf BigFloat) MarshalCBOR() ([]byte, error) {
+	return marshalExponentMantissa(tagNumBigFloat, bf.Exponent, &bf.Mantissa)
+}
+
+// UnmarshalCBOR decodes CBOR tag 5 to BigFloat.
+// Decoding CBOR null and undefined to BigFloat is no-op.
+func (bf *BigFloat) UnmarshalCBOR(data []byte) error {
+	if bf == nil {
+		return errors.New("cbor.BigFloat: UnmarshalCBOR on nil pointer")
+	}
+	exp, mant, err := unmarshalExponentMantissa(data, tagNumBigFloat, typeBigFloatTag)
+	if err != nil || mant == nil {
+		return err
+	}
+	*bf = BigFloat{Exponent: exp, Mantissa: *mant}
+	return nil
+}
+
+func marshalExponentMantissa(tagNum uint64, exp int64, mant *big.Int) ([]byte, error) {
+	e := getEncodeBuffer()
+	defer putEncodeBuffer(e)
+
+	if err := encodeExponentMantissa(e, defaultEncMode, tagNum, exp, mant); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, e.Len())
+	copy(buf, e.Bytes())
+	return buf, nil
+}
+
+func unmarshalExponentMantissa(data []byte, tagNum uint64, goType reflect.Type) (int64, *big.Int, error) {
+	// Decoding CBOR null and undefined is no-op.
+	if len(data) == 1 && (data[0] == 0xf6 || data[0] == 0xf7) {
+		return 0, nil, nil
+	}
+
+	d := decoder{data: data, dm: defaultDecMode}
+
+	typ, _, num := d.getHead()
+	if typ != cborTypeTag {
+		return 0, nil, &UnmarshalTypeError{CBORType: typ.String(), GoType: goType.String()}
+	}
+	if num != tagNum {
+		return 0, nil, &UnmarshalTypeError{
+			CBORType: typ.String(),
+			GoType:   goType.String(),
+			errorMsg: "tag number " + strconv.FormatUint(num, 10) + " isn't " + strconv.FormatUint(tagNum, 10),
+		}
+	}
+	return d.parseExponentMantissa(tagNum)
+}
+
+// encodeExponentMantissa encodes CBOR tag 4 or 5 with content [exp, mant].
+// Mantissa is encoded as CBOR integer if it fits, otherwise it is encoded as bignum.
+func encodeExponentMantissa(e *bytes.Buffer, em *encMode, tagNum uint64, exp int64, mant *big.Int) error {
+	encodeHead(e, byte(cborTypeTag), tagNum)
+	encodeHead(e, byte(cborTypeArray), 2)
+
+	if exp >= 0 {
+		encodeHead(e, byte(cborTypePositiveInt), uint64(exp))
+	} else {
+		encodeHead(e, byte(cborTypeNegativeInt), uint64(-1-exp))
+	}
+
+	return encodeIntegerOrBignum(e, em, mant)
+}
+
+// encodeIntegerOrBignum encodes n as CBOR integer if it fits in int64, otherwise as
+// specified by BigIntConvert.
+func encodeIntegerOrBignum(e *bytes.Buffer, em *encMode, n *big.Int) error {
+	if n.IsInt64() {
+		i := n.Int64()
+		if i >= 0 {
+			encodeHead(e, byte(cborTypePositiveInt), uint64(i))
+		} else {
+			encodeHead(e, byte(cborTypeNegativeInt), uint64(-1-i))
+		}
+		return nil
+	}
+	return encodeBigInt(e, em, reflect.ValueOf(*n))
+}
+
+// parseExponentMantissa parses content of CBOR tag 4 or 5, which is an array
+// of an integer exponent and an integer or bignum mantissa.
+func (d *decoder) parseExponentMantissa(tagNum uint64) (int64, *big.Int, error) {
+	start := d.off
+	invalidContent := func(msg string) error {
+		d.off = start
+		d.skip()
+		return newInadmissibleTagContentTypeErrorf(
+			"tag number " + strconv.FormatUint(tagNum, 10) + " must be followed by array of exponent and mantissa, " + msg)
+	}
+
+	if t := d.nextCBORType(); t != cborTypeArray {
+		return 0, nil, invalidContent("got " + t.String())
+	}
+	_, _, val, indefiniteLength := d.getHeadWithIndefiniteLengthFlag()
+	count := int(val)
+	if indefiniteLength {
+		count = d.numOfItemsUntilBreak()
+	}
+	if count != 2 {
+		return 0, nil, invalidContent("got array of " + strconv.Itoa(count) + " elements")
+	}
+
+	// Parse exponent.
+	t := d.nextCBORType()
+	if t != cborTypePositiveInt && t != cborTypeNegativeInt {
+		return 0, nil, invalidContent("got exponent of type " + t.String())
+	}
+	_, _, val = d.getHead()
+	if val > math.MaxInt64 {
+		return 0, nil, invalidContent("got exponent overflowing int64")
+	}
+	exp := int64(val)
+	if t == cborTypeNegativeInt {
+		exp = int64(-1) ^ exp
+	}
+
+	// Parse mantissa.
+	if s := d.integerOrBignumMismatch(); s != "" {
+		return 0, nil, invalidContent("got mantissa " + s)
+	}
+	var mant big.Int
+	if err := d.parseToValue(reflect.ValueOf(&mant).Elem(), getTypeInfo(typeBigInt)); err != nil {
+		return 0, nil, invalidContent(err.Error())
+	}
+
+	if indefiniteLength {
+		d.foundBreak()
+	}
+	return exp, &mant, nil
+}
+
+// integerOrBignumMismatch returns description of the next data item if it isn't CBOR
+// integer or bignum (tag 2 or 3), or empty string otherwise.
+func (d *decoder) integerOrBignumMismatch() string {
+	t := d.nextCBORType()
+	if t == cborTypeTag {
+		off := d.off
+		_, _, num := d.getHead()
+		d.off = off
+		if num != tagNumUnsignedBignum && num != tagNumNegativeBignum {
+			return "of tag number " + strconv.FormatUint(num, 10)
+		}
+	} else if t != cborTypePositiveInt && t != cborTypeNegativeInt {
+		return "of type " + t.String()
+	}
+	return ""
+}
+
+// parseRational parses content of CBOR tag 30, which is an array of an integer or
+// bignum numerator and a positive integer or bignum denominator.
+func (d *decoder) parseRational() (*big.Rat, error) {
+	start := d.off
+	invalidContent := func(msg string) error {
+		d.off = start
+		d.skip()
+		return newInadmissibleTagContentTypeErrorf(
+			"tag number " + strconv.Itoa(tagNumRational) + " must be followed by array of numerator and denominator, " + msg)
+	}
+
+	if t := d.nextCBORType(); t != cborTypeArray {
+		return nil, invalidContent("got " + t.String())
+	}
+	_, _, val, indefiniteLength := d.getHeadWithIndefiniteLengthFlag()
+	count := int(val)
+	if indefiniteLength {
+		count = d.numOfItemsUntilBreak()
+	}
+	if count != 2 {
+		return nil, invalidContent("got array of " + strconv.Itoa(count) + " elements")
+	}
+
+	var num, den big.Int
+	for _, elem := range []struct {
+		name string
+		v    *big.Int
+	}{{"numerator", &num}, {"denominator", &den}} {
+		if s := d.integerOrBignumMismatch(); s != "" {
+			return nil, invalidContent("got " + elem.name + " " + s)
+		}
+		if err := d.parseToValue(reflect.ValueOf(elem.v).Elem(), getTypeInfo(typeBigInt)); err != nil {
+			return nil, invalidContent(err.Error())
+		}
+	}
+	if den.Sign() <= 0 {
+		return nil, invalidContent("got non-positive denominator " + den.String())
+	}
+
+	if indefiniteLength {
+		d.foundBreak()
+	}
+	return new(big.Rat).SetFrac(&num, &den), nil
+}
+
+func exponentMantissaToRat(base int64, exp int64, mant *big.Int) (*big.Rat, error) {
+	if exp > maxExponentForRat || exp < -maxExponentForRat {
+		return nil, errors.New("cbor: exponent " + strconv.FormatInt(exp, 10) + " is out of range for big.Rat (range is [" +
+			strconv.Itoa(-maxExponentForRat) + ", " + strconv.Itoa(maxExponentForRat) + "])")
+	}
+	absExp := exp
+	if absExp < 0 {
+		absExp = -absExp
+	}
+	p := new(big.Int).Exp(big.NewInt(base), big.NewInt(absExp), nil)
+
+	r := new(big.Rat).SetInt(mant)
+	if exp >= 0 {
+		return r.Mul(r, new(big.Rat).SetInt(p)), nil
+	}
+	return r.Quo(r, new(big.Rat).SetInt(p)), nil
+}
+
+// exponentMantissaToFloat sets f to mant * 2^exp and returns f.  Mantissa is rounded
+// if f's precision is non-zero and less than mantissa's bit length.
+func exponentMantissaToFloat(f *big.Float, exp int64, mant *big.Int) (*big.Float, error) {
+	f.SetInt(mant)
+	if f.Sign() == 0 {
+		return f, nil
+	}
+	// MantExp and SetMantExp use int exponent within big.MinExp and big.MaxExp.
+	fExp := int64(f.MantExp(nil))
+	if exp+fExp > big.MaxExp || exp+fExp < big.MinExp {
+		return nil, errors.New("cbor: exponent " + strconv.FormatInt(exp, 10) + " is out of range for big.Float")
+	}
+	return f.SetMantExp(f, int(exp)), nil
+}
+
+// bigFloatToExponentMantissa returns exponent and integer mantissa of f.
+func bigFloatToExponentMantissa(f *big.Float) (int64, *big.Int, error) {
+	if f.IsInf() {
+		return 0, nil, &UnsupportedValueError{msg: "big.Float infinity"}
+	}
+	if f.Sign() == 0 {
+		return 0, new(big.Int), nil
+	}
+	// f = mant * 2^exp, where 0.5 <= |mant| < 1.0.
+	mant := new(big.Float)
+	exp := f.MantExp(mant)
+	// Shift mantissa to integer using the minimum precision needed to represent f exactly.
+	prec := int(f.MinPrec())
+	mant.SetMantExp(mant, prec)
+	mantInt, _ := mant.Int(nil)
+	return int64(exp) - int64(prec), mantInt, nil
+}
+
+// ratToDecimalFraction returns exponent and mantissa of decimal fraction representing r,
+// or false if r can't be represented exactly as decimal fraction.
+func ratToDecimalFraction(r *big.Rat) (int64, *big.Int, bool) {
+	if r.IsInt() {
+		return 0, new(big.Int).Set(r.Num()), true
+	}
+
+	// r (in lowest terms) is decimal fraction if and only if its denominator is 2^a * 5^b.
+	den := new(big.Int).Set(r.Denom())
+	twos := den.TrailingZeroBits()
+	den.Rsh(den, twos)
+
+	five := big.NewInt(5)
+	var fives uint
+	var rem big.Int
+	for den.Cmp(big.NewInt(1)) != 0 {
+		var quo big.Int
+		quo.QuoRem(den, five, &rem)
+		if rem.Sign() != 0 {
+			return 0, nil, false
+		}
+		den.Set(&quo)
+		fives++
+	}
+
+	// r = num / (2^twos * 5^fives) = num * 2^(n-twos) * 5^(n-fives) / 10^n
+	n := twos
+	if fives > n {
+		n = fives
+	}
+	mant := new(big.Int).Set(r.Num())
+	mant.Lsh(mant, n-twos)
+	mant.Mul(mant, new(big.Int).Exp(five, big.NewInt(int64(n-fives)), nil))
+	return -int64(n), mant, true
+}
+
+func encodeBigFloat(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	if em.bigFloat == BigFloatStruct {
+		return encodeStruct(e, em, v)
+	}
+	if b := em.encTagBytes(v.Type()); b != nil {
+		e.Write(b)
+	}
+	f := v.Interface().(big.Float)
+	exp, mant, err := bigFloatToExponentMantissa(&f)
+	if err != nil {
+		return err
+	}
+	return encodeExponentMantissa(e, em, tagNumBigFloat, exp, mant)
+}
+
+func encodeBigRat(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	if em.bigRat == BigRatStruct {
+		return encodeStruct(e, em, v)
+	}
+	if b := em.encTagBytes(v.Type()); b != nil {
+		e.Write(b)
+	}
+	r := v.Interface().(big.Rat)
+	if em.bigRat == BigRatRational {
+		encodeHead(e, byte(cborTypeTag), tagNumRational)
+		encodeHead(e, byte(cborTypeArray), 2)
+		if err := encodeIntegerOrBignum(e, em, r.Num()); err != nil {
+			return err
+		}
+		return encodeIntegerOrBignum(e, em, r.Denom())
+	}
+	exp, mant, ok := ratToDecimalFraction(&r)
+	if !ok {
+		return &UnsupportedValueError{msg: "big.Rat " + r.String() + " can't be represented as decimal fraction"}
+	}
+	return encodeExponentMantissa(e, em, tagNumDecimalFraction, exp, mant)
+}
+
+func isEmptyBigFloat(em *encMode, v reflect.Value) (bool, error) {
+	if em.bigFloat == BigFloatStruct {
+		return isEmptyStruct(em, v)
+	}
+	return false, nil
+}
+
+func isEmptyBigRat(em *encMode, v reflect.Value) (bool, error) {
+	if em.bigRat == BigRatStruct {
+		return isEmptyStruct(em, v)
+	}
+	return false, nil
+}
+
+// parseExponentMantissaToValue decodes content of CBOR tag 4 or 5 to big.Rat or big.Float value v.
+func (d *decoder) parseExponentMantissaToValue(tagNum uint64, v reflect.Value, tInfo *typeInfo) error {
+	exp, mant, err := d.parseExponentMantissa(tagNum)
+	if err != nil {
+		return err
+	}
+
+	if tInfo.nonPtrType == typeBigFloat && tagNum == tagNumBigFloat {
+		f, err := exponentMantissaToFloat(d.newBigFloat(), exp, mant)
+		if err != nil {
+			return &UnmarshalTypeError{CBORType: cborTypeTag.String(), GoType: tInfo.nonPtrType.String(), errorMsg: err.Error()}
+		}
+		v.Set(reflect.ValueOf(*f))
+		return nil
+	}
+
+	base := int64(2)
+	if tagNum == tagNumDecimalFraction {
+		base = 10
+	}
+	r, err := exponentMantissaToRat(base, exp, mant)
+	if err != nil {
+		return &UnmarshalTypeError{CBORType: cborTypeTag.String(), GoType: tInfo.nonPtrType.String(), errorMsg: err.Error()}
+	}
+	if tInfo.nonPtrType == typeBigRat {
+		v.Set(reflect.ValueOf(*r))
+		return nil
+	}
+	v.Set(reflect.ValueOf(*d.newBigFloat().SetRat(r)))
+	return nil
+}
+
+// parseRationalToValue decodes content of CBOR tag 30 to big.Rat or big.Float value v.
+func (d *decoder) parseRationalToValue(v reflect.Value, tInfo *typeInfo) error {
+	r, err := d.parseRational()
+	if err != nil {
+		return err
+	}
+	if tInfo.nonPtrType == typeBigRat {
+		v.Set(reflect.ValueOf(*r))
+		return nil
+	}
+	v.Set(reflect.ValueOf(*d.newBigFloat().SetRat(r)))
+	return nil
+}
+
+// newBigFloat returns a new big.Float with precision and rounding mode specified by
+// BigFloatPrecision and BigFloatRoundingMode decoding options.
+func (d *decoder) newBigFloat() *big.Float {
+	f := new(big.Float).SetMode(d.dm.bigFloatRoundingMode)
+	if d.dm.bigFloatPrecision > 0 {
+		f.SetPrec(d.dm.bigFloatPrecision)
+	}
+	return f
+}
+
+// fillBigFloat decodes CBOR floating-point number f to big.Float value v.
+func (d *decoder) fillBigFloat(t cborType, f float64, v reflect.Value) error {
+	if math.IsNaN(f) {
+		return &UnmarshalTypeError{CBORType: t.String(), GoType: typeBigFloat.String(), errorMsg: "NaN can't be represented by big.Float"}
+	}
+	v.Set(reflect.ValueOf(*d.newBigFloat().SetFloat64(f)))
+	return nil
+}
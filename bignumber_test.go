@@ -0,0 +1,501 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestDecimalFraction(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     []byte
+		exponent int64
+		mantissa string
+		rat      string
+	}{
+		{name: "273.15", data: hexDecode("c48221196ab3"), exponent: -2, mantissa: "27315", rat: "5463/20"},
+		{name: "-1.5", data: hexDecode("c482202e"), exponent: -1, mantissa: "-15", rat: "-3/2"},
+		{name: "1200", data: hexDecode("c482020c"), exponent: 2, mantissa: "12", rat: "1200/1"},
+		{name: "bignum mantissa", data: hexDecode("c48220c249010000000000000000"), exponent: -1, mantissa: "18446744073709551616", rat: "9223372036854775808/5"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wantMantissa, _ := new(big.Int).SetString(tc.mantissa, 10)
+			wantRat, _ := new(big.Rat).SetString(tc.rat)
+
+			var df DecimalFraction
+			if err := Unmarshal(tc.data, &df); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if df.Exponent != tc.exponent || df.Mantissa.Cmp(wantMantissa) != 0 {
+				t.Errorf("Unmarshal(0x%x) = {%d, %s}, want {%d, %s}", tc.data, df.Exponent, &df.Mantissa, tc.exponent, wantMantissa)
+			}
+			r, err := df.Rat()
+			if err != nil {
+				t.Fatalf("Rat() returned error %v", err)
+			}
+			if r.Cmp(wantRat) != 0 {
+				t.Errorf("Rat() = %s, want %s", r, wantRat)
+			}
+
+			b, err := Marshal(df)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned error %v", df, err)
+			}
+			if !bytes.Equal(b, tc.data) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", df, b, tc.data)
+			}
+
+			// Decode to big.Rat
+			var gotRat big.Rat
+			if err := Unmarshal(tc.data, &gotRat); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if gotRat.Cmp(wantRat) != 0 {
+				t.Errorf("Unmarshal(0x%x) = %s, want %s", tc.data, &gotRat, wantRat)
+			}
+
+			// Decode to *big.Float
+			var gotFloat *big.Float
+			if err := Unmarshal(tc.data, &gotFloat); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			wantFloat := new(big.Float).SetRat(wantRat)
+			if gotFloat.Cmp(wantFloat) != 0 {
+				t.Errorf("Unmarshal(0x%x) = %s, want %s", tc.data, gotFloat, wantFloat)
+			}
+		})
+	}
+}
+
+func TestBigFloat(t *testing.T) {
+	em, err := EncOptions{BigFloat: BigFloatBigfloat}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		data     []byte
+		exponent int64
+		mantissa string
+		value    string
+	}{
+		{name: "1.5", data: hexDecode("c5822003"), exponent: -1, mantissa: "3", value: "1.5"},
+		{name: "-0.75", data: hexDecode("c5822122"), exponent: -2, mantissa: "-3", value: "-0.75"},
+		{name: "1536", data: hexDecode("c5820903"), exponent: 9, mantissa: "3", value: "1536"},
+		{name: "bignum mantissa", data: hexDecode("c58200c349010000000000000000"), exponent: 0, mantissa: "-18446744073709551617", value: "-18446744073709551617"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wantMantissa, _ := new(big.Int).SetString(tc.mantissa, 10)
+			wantFloat, _, err := big.ParseFloat(tc.value, 10, 128, big.ToNearestEven)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var bf BigFloat
+			if err := Unmarshal(tc.data, &bf); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if bf.Exponent != tc.exponent || bf.Mantissa.Cmp(wantMantissa) != 0 {
+				t.Errorf("Unmarshal(0x%x) = {%d, %s}, want {%d, %s}", tc.data, bf.Exponent, &bf.Mantissa, tc.exponent, wantMantissa)
+			}
+			f, err := bf.Float()
+			if err != nil {
+				t.Fatalf("Float() returned error %v", err)
+			}
+			if f.Cmp(wantFloat) != 0 {
+				t.Errorf("Float() = %s, want %s", f, wantFloat)
+			}
+
+			b, err := Marshal(bf)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned error %v", bf, err)
+			}
+			if !bytes.Equal(b, tc.data) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", bf, b, tc.data)
+			}
+
+			// Decode to big.Float
+			var gotFloat big.Float
+			if err := Unmarshal(tc.data, &gotFloat); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if gotFloat.Cmp(wantFloat) != 0 {
+				t.Errorf("Unmarshal(0x%x) = %s, want %s", tc.data, &gotFloat, wantFloat)
+			}
+
+			// Encode big.Float
+			b, err = em.Marshal(wantFloat)
+			if err != nil {
+				t.Fatalf("Marshal(%s) returned error %v", wantFloat, err)
+			}
+			if !bytes.Equal(b, tc.data) {
+				t.Errorf("Marshal(%s) = 0x%x, want 0x%x", wantFloat, b, tc.data)
+			}
+
+			// Decode to big.Rat
+			var gotRat big.Rat
+			if err := Unmarshal(tc.data, &gotRat); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			wantRat, _ := wantFloat.Rat(nil)
+			if gotRat.Cmp(wantRat) != 0 {
+				t.Errorf("Unmarshal(0x%x) = %s, want %s", tc.data, &gotRat, wantRat)
+			}
+		})
+	}
+}
+
+func TestEncodeBigRat(t *testing.T) {
+	em, err := EncOptions{BigRat: BigRatDecimalFraction}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		rat      string
+		wantData []byte
+	}{
+		{rat: "5463/20", wantData: hexDecode("c48221196ab3")},
+		{rat: "-3/2", wantData: hexDecode("c482202e")},
+		{rat: "1/8", wantData: hexDecode("c48222187d")},
+		{rat: "7", wantData: hexDecode("c4820007")},
+		{rat: "0", wantData: hexDecode("c4820000")},
+	}
+	for _, tc := range testCases {
+		r, _ := new(big.Rat).SetString(tc.rat)
+		b, err := em.Marshal(r)
+		if err != nil {
+			t.Errorf("Marshal(%s) returned error %v", r, err)
+		} else if !bytes.Equal(b, tc.wantData) {
+			t.Errorf("Marshal(%s) = 0x%x, want 0x%x", r, b, tc.wantData)
+		}
+
+		df, err := NewDecimalFraction(r)
+		if err != nil {
+			t.Errorf("NewDecimalFraction(%s) returned error %v", r, err)
+		} else if got, _ := df.Rat(); got.Cmp(r) != 0 {
+			t.Errorf("NewDecimalFraction(%s).Rat() = %s", r, got)
+		}
+	}
+}
+
+func TestEncodeBigNumberError(t *testing.T) {
+	em, err := EncOptions{BigFloat: BigFloatBigfloat, BigRat: BigRatDecimalFraction}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	r := big.NewRat(1, 3)
+	wantErrorMsg := "cbor: unsupported value: big.Rat 1/3 can't be represented as decimal fraction"
+	if _, err := em.Marshal(r); err == nil {
+		t.Errorf("Marshal(%s) didn't return an error", r)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Marshal(%s) returned error %q, want %q", r, err.Error(), wantErrorMsg)
+	}
+	if _, err := NewDecimalFraction(r); err == nil {
+		t.Errorf("NewDecimalFraction(%s) didn't return an error", r)
+	}
+
+	f := new(big.Float).SetInf(false)
+	wantErrorMsg = "cbor: unsupported value: big.Float infinity"
+	if _, err := em.Marshal(f); err == nil {
+		t.Errorf("Marshal(%s) didn't return an error", f)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Marshal(%s) returned error %q, want %q", f, err.Error(), wantErrorMsg)
+	}
+	if _, err := NewBigFloat(f); err == nil {
+		t.Errorf("NewBigFloat(%s) didn't return an error", f)
+	}
+}
+
+func TestEncodeBigNumberDefault(t *testing.T) {
+	type T struct {
+		F big.Float `cbor:",omitempty"`
+		R big.Rat   `cbor:",omitempty"`
+	}
+
+	// big.Float and big.Rat are encoded as Go structs by default.
+	testCases := []struct {
+		name     string
+		v        interface{}
+		wantData []byte
+	}{
+		{name: "big.Float", v: *big.NewFloat(1.5), wantData: hexDecode("a0")},
+		{name: "*big.Float", v: big.NewFloat(1.5), wantData: hexDecode("a0")},
+		{name: "big.Rat", v: *big.NewRat(1, 3), wantData: hexDecode("a0")},
+		{name: "*big.Rat", v: big.NewRat(1, 3), wantData: hexDecode("a0")},
+		{name: "struct", v: T{F: *big.NewFloat(1.5), R: *big.NewRat(1, 3)}, wantData: hexDecode("a0")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := Marshal(tc.v)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned error %v", tc.v, err)
+			}
+			if !bytes.Equal(b, tc.wantData) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", tc.v, b, tc.wantData)
+			}
+		})
+	}
+}
+
+func TestDecodeBigNumberError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "content isn't array",
+			data:         hexDecode("c401"),
+			wantErrorMsg: "cbor: tag number 4 must be followed by array of exponent and mantissa, got positive integer",
+		},
+		{
+			name:         "array of 3 elements",
+			data:         hexDecode("c483010203"),
+			wantErrorMsg: "cbor: tag number 4 must be followed by array of exponent and mantissa, got array of 3 elements",
+		},
+		{
+			name:         "exponent isn't integer",
+			data:         hexDecode("c482f93c0001"),
+			wantErrorMsg: "cbor: tag number 4 must be followed by array of exponent and mantissa, got exponent of type primitives",
+		},
+		{
+			name:         "mantissa isn't integer",
+			data:         hexDecode("c482016161"),
+			wantErrorMsg: "cbor: tag number 4 must be followed by array of exponent and mantissa, got mantissa of type UTF-8 text string",
+		},
+		{
+			name:         "mantissa is tag other than bignum",
+			data:         hexDecode("c48201c101"),
+			wantErrorMsg: "cbor: tag number 4 must be followed by array of exponent and mantissa, got mantissa of tag number 1",
+		},
+		{
+			name:         "exponent too large",
+			data:         hexDecode("c4821a0001000101"),
+			wantErrorMsg: "cbor: cannot unmarshal tag into Go value of type big.Rat (cbor: exponent 65537 is out of range for big.Rat (range is [-65536, 65536]))",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var r big.Rat
+			if err := Unmarshal(tc.data, &r); err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error", tc.data)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+
+	var df DecimalFraction
+	data := hexDecode("c5822003")
+	if err := Unmarshal(data, &df); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+	} else if !strings.Contains(err.Error(), "tag number 5 isn't 4") {
+		t.Errorf("Unmarshal(0x%x) returned error %q", data, err.Error())
+	}
+}
+
+func TestUnmarshalToBigFloatWithPrecision(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts DecOptions
+		data []byte
+		want string // big.Float in 'p' format
+		prec uint
+	}{
+		{
+			name: "float64 with default precision",
+			data: hexDecode("fb3fb999999999999a"), // 0.1
+			want: "0x.ccccccccccccdp-3",
+			prec: 53,
+		},
+		{
+			name: "float64 rounded to nearest even",
+			opts: DecOptions{BigFloatPrecision: 8},
+			data: hexDecode("fb3fb999999999999a"), // 0.1
+			want: "0x.cdp-3",
+			prec: 8,
+		},
+		{
+			name: "float64 rounded toward zero",
+			opts: DecOptions{BigFloatPrecision: 8, BigFloatRoundingMode: big.ToZero},
+			data: hexDecode("fb3fb999999999999a"), // 0.1
+			want: "0x.ccp-3",
+			prec: 8,
+		},
+		{
+			name: "float16",
+			opts: DecOptions{BigFloatPrecision: 8},
+			data: hexDecode("f93e00"), // 1.5
+			want: "0x.cp+1",
+			prec: 8,
+		},
+		{
+			name: "positive integer",
+			opts: DecOptions{BigFloatPrecision: 4},
+			data: hexDecode("1903ff"), // 1023
+			want: "0x.8p+11",
+			prec: 4,
+		},
+		{
+			name: "negative integer",
+			opts: DecOptions{BigFloatPrecision: 4, BigFloatRoundingMode: big.ToZero},
+			data: hexDecode("3903fe"), // -1023
+			want: "-0x.fp+10",
+			prec: 4,
+		},
+		{
+			name: "bignum",
+			opts: DecOptions{BigFloatPrecision: 8},
+			data: hexDecode("c249010000000000000001"), // 18446744073709551617
+			want: "0x.8p+65",
+			prec: 8,
+		},
+		{
+			name: "bigfloat",
+			opts: DecOptions{BigFloatPrecision: 4, BigFloatRoundingMode: big.AwayFromZero},
+			data: hexDecode("c5822018ff"), // 255 * 2^-1
+			want: "0x.8p+8",
+			prec: 4,
+		},
+		{
+			name: "decimal fraction",
+			opts: DecOptions{BigFloatPrecision: 8, BigFloatRoundingMode: big.ToZero},
+			data: hexDecode("c4822001"), // 0.1
+			want: "0x.ccp-3",
+			prec: 8,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := tc.opts.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned error %v", err)
+			}
+			var f big.Float
+			if err := dm.Unmarshal(tc.data, &f); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if s := f.Text('p', 0); s != tc.want {
+				t.Errorf("Unmarshal(0x%x) = %s, want %s", tc.data, s, tc.want)
+			}
+			if f.Prec() != tc.prec {
+				t.Errorf("Unmarshal(0x%x) returned precision %d, want %d", tc.data, f.Prec(), tc.prec)
+			}
+		})
+	}
+}
+
+func TestUnmarshalNaNToBigFloat(t *testing.T) {
+	data := hexDecode("f97e00")
+	wantErrorMsg := "cbor: cannot unmarshal primitives into Go value of type big.Float (NaN can't be represented by big.Float)"
+
+	var f big.Float
+	if err := Unmarshal(data, &f); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
+	}
+}
+
+func TestRational(t *testing.T) {
+	em, err := EncOptions{BigRat: BigRatRational}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		rat      string
+		wantData []byte
+	}{
+		{rat: "1/3", wantData: hexDecode("d81e820103")},
+		{rat: "-6/4", wantData: hexDecode("d81e822202")},
+		{rat: "7", wantData: hexDecode("d81e820701")},
+		{rat: "0", wantData: hexDecode("d81e820001")},
+		{rat: "1/18446744073709551616", wantData: hexDecode("d81e8201c249010000000000000000")},
+	}
+	for _, tc := range testCases {
+		r, _ := new(big.Rat).SetString(tc.rat)
+		b, err := em.Marshal(r)
+		if err != nil {
+			t.Errorf("Marshal(%s) returned error %v", r, err)
+		} else if !bytes.Equal(b, tc.wantData) {
+			t.Errorf("Marshal(%s) = 0x%x, want 0x%x", r, b, tc.wantData)
+		}
+
+		var got big.Rat
+		if err := Unmarshal(tc.wantData, &got); err != nil {
+			t.Errorf("Unmarshal(0x%x) returned error %v", tc.wantData, err)
+		} else if got.Cmp(r) != 0 {
+			t.Errorf("Unmarshal(0x%x) = %s, want %s", tc.wantData, &got, r)
+		}
+	}
+
+	// Rational numbers not in lowest terms are decoded, and they can be decoded to big.Float.
+	data := hexDecode("d81e82381d0c") // -30/12
+	var f big.Float
+	if err := Unmarshal(data, &f); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	if want := big.NewFloat(-2.5); f.Cmp(want) != 0 {
+		t.Errorf("Unmarshal(0x%x) = %s, want %s", data, &f, want)
+	}
+}
+
+func TestDecodeRationalError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "content isn't array",
+			data:         hexDecode("d81e01"),
+			wantErrorMsg: "cbor: tag number 30 must be followed by array of numerator and denominator, got positive integer",
+		},
+		{
+			name:         "array of 1 element",
+			data:         hexDecode("d81e8101"),
+			wantErrorMsg: "cbor: tag number 30 must be followed by array of numerator and denominator, got array of 1 elements",
+		},
+		{
+			name:         "numerator isn't integer",
+			data:         hexDecode("d81e82f93c0001"),
+			wantErrorMsg: "cbor: tag number 30 must be followed by array of numerator and denominator, got numerator of type primitives",
+		},
+		{
+			name:         "denominator is tag other than bignum",
+			data:         hexDecode("d81e8201c101"),
+			wantErrorMsg: "cbor: tag number 30 must be followed by array of numerator and denominator, got denominator of tag number 1",
+		},
+		{
+			name:         "zero denominator",
+			data:         hexDecode("d81e820100"),
+			wantErrorMsg: "cbor: tag number 30 must be followed by array of numerator and denominator, got non-positive denominator 0",
+		},
+		{
+			name:         "negative denominator",
+			data:         hexDecode("d81e820120"),
+			wantErrorMsg: "cbor: tag number 30 must be followed by array of numerator and denominator, got non-positive denominator -1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var r big.Rat
+			if err := Unmarshal(tc.data, &r); err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error", tc.data)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
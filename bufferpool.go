@@ -0,0 +1,159 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"math/bits"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// BufferPool is a pool of buffers used by an encoding mode to build encoded data in
+// Marshal, MarshalAppend, EncodeWrapped, and Encoder.Encode.  It can be set with
+// EncOptions.BufferPool to share buffers between modes, to bound memory retained by
+// pooled buffers, or to collect metrics.
+//
+// Get must return an empty buffer.  Put receives a buffer returned by Get after the
+// encoded data is copied or written, and the buffer isn't used by the encoding mode
+// after Put.  Put receives the buffer before it is reset, so Len reports the size of
+// the last encoded data.  Implementations must be safe for concurrent use.
+type BufferPool interface {
+	Get() *bytes.Buffer
+	Put(b *bytes.Buffer)
+}
+
+// BufferPoolStats contains statistics of SizeClassBufferPool.
+type BufferPoolStats struct {
+	// Gets is the number of buffers returned by Get.
+	Gets uint64
+
+	// Allocs is the number of buffers allocated by Get because no pooled buffer of the
+	// expected size class was available.
+	Allocs uint64
+
+	// Puts is the number of buffers kept for reuse by Put.
+	Puts uint64
+
+	// Drops is the number of buffers discarded by Put because their capacity is larger
+	// than the max buffer size of the pool.
+	Drops uint64
+}
+
+const (
+	minBufferSizeClass = 64 // capacity of buffers in the smallest size class
+	numBufferSizeClass = 20 // size classes from 64 bytes to 32 MiB
+)
+
+// SizeClassBufferPool is a BufferPool that keeps buffers in a sync.Pool for each
+// size class (power of two capacity from 64 bytes).  Get returns a buffer of the size
+// class of the most recently encoded data, so a few large messages don't make small
+// messages hold large buffers, and messages of similar size don't grow new buffers.
+//
+// Buffers larger than max buffer size are discarded instead of pooled, which bounds
+// memory retained after encoding occasional large messages.
+type SizeClassBufferPool struct {
+	// Counters are first to be 64-bit aligned for atomic operations on 32-bit platforms.
+	gets   uint64
+	allocs uint64
+	puts   uint64
+	drops  uint64
+
+	pools    [numBufferSizeClass]sync.Pool
+	maxSize  int
+	maxClass uint32 // size class of buffers with max buffer size
+	class    uint32 // size class of the most recently encoded data
+}
+
+// NewSizeClassBufferPool returns a SizeClassBufferPool that discards buffers with
+// capacity larger than maxBufferSize.  It returns an error if maxBufferSize is less
+// than 64 bytes.
+func NewSizeClassBufferPool(maxBufferSize int) (*SizeClassBufferPool, error) {
+	if maxBufferSize < minBufferSizeClass {
+		return nil, errors.New("cbor: invalid max buffer size " + strconv.Itoa(maxBufferSize))
+	}
+	return &SizeClassBufferPool{
+		maxSize:  maxBufferSize,
+		maxClass: uint32(bufferSizeClass(maxBufferSize, false)),
+	}, nil
+}
+
+// Get returns an empty buffer with capacity of at least the size class of the most
+// recently encoded data.
+func (p *SizeClassBufferPool) Get() *bytes.Buffer {
+	atomic.AddUint64(&p.gets, 1)
+	class := atomic.LoadUint32(&p.class)
+	if b, _ := p.pools[class].Get().(*bytes.Buffer); b != nil {
+		return b
+	}
+	atomic.AddUint64(&p.allocs, 1)
+	b := new(bytes.Buffer)
+	b.Grow(minBufferSizeClass << class)
+	return b
+}
+
+// Put records size class of encoded data in b, and keeps b for reuse if its capacity
+// isn't larger than max buffer size.
+func (p *SizeClassBufferPool) Put(b *bytes.Buffer) {
+	class := uint32(bufferSizeClass(b.Len(), true))
+	if class > p.maxClass {
+		class = p.maxClass
+	}
+	atomic.StoreUint32(&p.class, class)
+
+	c := b.Cap()
+	if c > p.maxSize {
+		atomic.AddUint64(&p.drops, 1)
+		return
+	}
+	atomic.AddUint64(&p.puts, 1)
+	b.Reset()
+	p.pools[bufferSizeClass(c, false)].Put(b)
+}
+
+// Stats returns statistics of p.
+func (p *SizeClassBufferPool) Stats() BufferPoolStats {
+	return BufferPoolStats{
+		Gets:   atomic.LoadUint64(&p.gets),
+		Allocs: atomic.LoadUint64(&p.allocs),
+		Puts:   atomic.LoadUint64(&p.puts),
+		Drops:  atomic.LoadUint64(&p.drops),
+	}
+}
+
+// bufferSizeClass returns the smallest size class with capacity of at least n if
+// roundUp is true, or the largest size class with capacity of at most n otherwise.
+func bufferSizeClass(n int, roundUp bool) int {
+	if n <= minBufferSizeClass {
+		return 0
+	}
+	class := bits.Len(uint(n-1)) - bits.Len(minBufferSizeClass-1)
+	if !roundUp && minBufferSizeClass<<class > n {
+		class--
+	}
+	if class >= numBufferSizeClass {
+		class = numBufferSizeClass - 1
+	}
+	return class
+}
+
+// getBuffer returns a buffer for encoded data from BufferPool option, or from the
+// built-in pool if the option isn't set.
+func (em *encMode) getBuffer() *bytes.Buffer {
+	if em.bufferPool != nil {
+		return em.bufferPool.Get()
+	}
+	return getEncodeBuffer()
+}
+
+// putBuffer returns e obtained from getBuffer.
+func (em *encMode) putBuffer(e *bytes.Buffer) {
+	if em.bufferPool != nil {
+		em.bufferPool.Put(e)
+		return
+	}
+	putEncodeBuffer(e)
+}
@@ -0,0 +1,141 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestBufferSizeClass(t *testing.T) {
+	testCases := []struct {
+		n           int
+		wantRoundUp int
+		wantRound   int
+	}{
+		{n: 0, wantRoundUp: 0, wantRound: 0},
+		{n: 64, wantRoundUp: 0, wantRound: 0},
+		{n: 65, wantRoundUp: 1, wantRound: 0},
+		{n: 128, wantRoundUp: 1, wantRound: 1},
+		{n: 1000, wantRoundUp: 4, wantRound: 3},
+		{n: 1024, wantRoundUp: 4, wantRound: 4},
+		{n: 1 << 30, wantRoundUp: numBufferSizeClass - 1, wantRound: numBufferSizeClass - 1},
+	}
+	for _, tc := range testCases {
+		if class := bufferSizeClass(tc.n, true); class != tc.wantRoundUp {
+			t.Errorf("bufferSizeClass(%d, true) = %d, want %d", tc.n, class, tc.wantRoundUp)
+		}
+		if class := bufferSizeClass(tc.n, false); class != tc.wantRound {
+			t.Errorf("bufferSizeClass(%d, false) = %d, want %d", tc.n, class, tc.wantRound)
+		}
+	}
+}
+
+func TestSizeClassBufferPool(t *testing.T) {
+	p, err := NewSizeClassBufferPool(4096)
+	if err != nil {
+		t.Fatalf("NewSizeClassBufferPool() returned error %v", err)
+	}
+
+	b := p.Get()
+	if b.Len() != 0 || b.Cap() < 64 {
+		t.Errorf("Get() returned buffer with length %d and capacity %d, want empty buffer with capacity >= 64", b.Len(), b.Cap())
+	}
+
+	// Buffer for the next data is in the size class of the last encoded data.
+	b.Write(make([]byte, 1000))
+	p.Put(b)
+	b = p.Get()
+	if b.Len() != 0 || b.Cap() < 1024 {
+		t.Errorf("Get() returned buffer with length %d and capacity %d, want empty buffer with capacity >= 1024", b.Len(), b.Cap())
+	}
+
+	// Buffer larger than max buffer size is dropped.
+	b.Write(make([]byte, 5000))
+	p.Put(b)
+	b = p.Get()
+	if b.Cap() > 4096 {
+		t.Errorf("Get() returned buffer with capacity %d, want capacity <= 4096", b.Cap())
+	}
+	p.Put(b)
+
+	stats := p.Stats()
+	if stats.Gets != 3 || stats.Puts != 2 || stats.Drops != 1 || stats.Allocs == 0 {
+		t.Errorf("Stats() = %+v, want 3 gets, 2 puts, 1 drop, and at least 1 alloc", stats)
+	}
+
+	wantErrorMsg := "cbor: invalid max buffer size 10"
+	if _, err := NewSizeClassBufferPool(10); err == nil {
+		t.Errorf("NewSizeClassBufferPool() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("NewSizeClassBufferPool() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+}
+
+// countingBufferPool counts buffers obtained and returned by encoding modes.
+type countingBufferPool struct {
+	mu         sync.Mutex
+	gets, puts int
+}
+
+func (p *countingBufferPool) Get() *bytes.Buffer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gets++
+	return new(bytes.Buffer)
+}
+
+func (p *countingBufferPool) Put(b *bytes.Buffer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.puts++
+}
+
+func TestEncOptionsBufferPool(t *testing.T) {
+	pool := &countingBufferPool{}
+	em, err := EncOptions{BufferPool: pool}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	v := map[string]int{"a": 1}
+	want := hexDecode("a1616101")
+
+	b, err := em.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("Marshal() = 0x%x, want 0x%x", b, want)
+	}
+
+	b, err = em.(ExtendedEncMode).MarshalAppend(nil, v)
+	if err != nil {
+		t.Fatalf("MarshalAppend() returned error %v", err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("MarshalAppend() = 0x%x, want 0x%x", b, want)
+	}
+
+	if _, err = em.(ExtendedEncMode).EncodeWrapped(v); err != nil {
+		t.Fatalf("EncodeWrapped() returned error %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err = em.NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Encode() = 0x%x, want 0x%x", buf.Bytes(), want)
+	}
+
+	if _, err = em.Marshal(make(chan int)); err == nil {
+		t.Errorf("Marshal() didn't return an error")
+	}
+
+	if pool.gets != 5 || pool.puts != 5 {
+		t.Errorf("BufferPool got %d Get and %d Put calls, want 5 each", pool.gets, pool.puts)
+	}
+}
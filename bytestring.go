@@ -12,7 +12,7 @@ import (
 // allow []byte as map key, so ByteString can be used to support data formats
 // having CBOR map with byte string keys. ByteString can also be used to
 // encode invalid UTF-8 string as CBOR byte string.
-// See DecOption.MapKeyByteStringMode for more details.
+// See DecOptions.MapKeyByteString for more details.
 type ByteString string
 
 // Bytes returns bytes representing ByteString.
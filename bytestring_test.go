@@ -3,7 +3,10 @@
 
 package cbor
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestByteString(t *testing.T) {
 	type s1 struct {
@@ -99,3 +102,38 @@ func TestByteString(t *testing.T) {
 	dm, _ := DecOptions{}.DecMode()
 	testRoundTrip(t, testCases, em, dm)
 }
+
+func TestUnmarshalMapWithByteStringKeys(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+		want interface{}
+	}{
+		{
+			name: "to map[ByteString]int",
+			data: hexDecode("a2420102014003"), // {h'0102': 1, h'': 3}
+			want: map[ByteString]int{"\x01\x02": 1, "": 3},
+		},
+		{
+			name: "indefinite-length byte string key to map[ByteString]int",
+			data: hexDecode("a15f41014102ff01"), // {(_ h'01', h'02'): 1}
+			want: map[ByteString]int{"\x01\x02": 1},
+		},
+		{
+			name: "to map[interface{}]interface{}",
+			data: hexDecode("a1420102f5"), // {h'0102': true}
+			want: map[interface{}]interface{}{ByteString("\x01\x02"): true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := reflect.New(reflect.TypeOf(tc.want))
+			if err := Unmarshal(tc.data, v.Interface()); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if !reflect.DeepEqual(v.Elem().Interface(), tc.want) {
+				t.Errorf("Unmarshal(0x%x) = %v, want %v", tc.data, v.Elem().Interface(), tc.want)
+			}
+		})
+	}
+}
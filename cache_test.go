@@ -0,0 +1,129 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSetTypeCacheLimitError(t *testing.T) {
+	wantErrorMsg := "cbor: invalid type cache limit -1"
+	if err := SetTypeCacheLimit(-1); err == nil {
+		t.Errorf("SetTypeCacheLimit(-1) didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("SetTypeCacheLimit(-1) returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+}
+
+func TestBoundedTypeCache(t *testing.T) {
+	const limit = 4
+	if err := SetTypeCacheLimit(limit); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := SetTypeCacheLimit(0); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if stats := TypeCacheStats(); stats != (CacheStats{}) {
+		t.Errorf("TypeCacheStats() = %+v, want zero value after SetTypeCacheLimit()", stats)
+	}
+
+	// Encode and decode dynamically created struct types.
+	const numTypes = 50
+	for i := 0; i < numTypes; i++ {
+		typ := reflect.StructOf([]reflect.StructField{
+			{Name: "F" + strconv.Itoa(i), Type: reflect.TypeOf(0), Tag: `cbor:"f"`},
+		})
+		v := reflect.New(typ)
+		v.Elem().Field(0).SetInt(int64(i))
+
+		b, err := Marshal(v.Interface())
+		if err != nil {
+			t.Fatalf("Marshal() returned error %v", err)
+		}
+
+		decoded := reflect.New(typ)
+		if err = Unmarshal(b, decoded.Interface()); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", b, err)
+		}
+		if !reflect.DeepEqual(decoded.Elem().Interface(), v.Elem().Interface()) {
+			t.Errorf("Unmarshal(0x%x) = %v, want %v", b, decoded.Elem().Interface(), v.Elem().Interface())
+		}
+	}
+
+	stats := TypeCacheStats()
+	if stats.Entries > limit*len(typeCaches) {
+		t.Errorf("TypeCacheStats().Entries = %d, want <= %d", stats.Entries, limit*len(typeCaches))
+	}
+	if stats.Misses == 0 {
+		t.Errorf("TypeCacheStats().Misses = 0, want > 0")
+	}
+	if stats.Evictions == 0 {
+		t.Errorf("TypeCacheStats().Evictions = 0, want > 0")
+	}
+
+	// Cached types are hits.
+	hits := stats.Hits
+	if _, err := Marshal(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Marshal(2); err != nil {
+		t.Fatal(err)
+	}
+	if stats = TypeCacheStats(); stats.Hits <= hits {
+		t.Errorf("TypeCacheStats().Hits = %d, want > %d", stats.Hits, hits)
+	}
+}
+
+func TestBoundedTypeCacheConcurrency(t *testing.T) {
+	if err := SetTypeCacheLimit(2); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := SetTypeCacheLimit(0); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	type s1 struct{ A int }
+	type s2 struct{ B string }
+	type s3 struct{ C []byte }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for _, v := range []interface{}{&s1{A: j}, &s2{B: "b"}, &s3{C: []byte{1}}} {
+					b, err := Marshal(v)
+					if err != nil {
+						t.Errorf("Marshal() returned error %v", err)
+						return
+					}
+					if err = Unmarshal(b, v); err != nil {
+						t.Errorf("Unmarshal() returned error %v", err)
+						return
+					}
+				}
+				if j == 50 && i == 0 {
+					// Changing limit concurrently with encoding and decoding is safe.
+					if err := SetTypeCacheLimit(3); err != nil {
+						t.Errorf("SetTypeCacheLimit() returned error %v", err)
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if stats := TypeCacheStats(); stats.Entries > 3*len(typeCaches) {
+		t.Errorf("TypeCacheStats().Entries = %d, want <= %d", stats.Entries, 3*len(typeCaches))
+	}
+}
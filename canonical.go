@@ -0,0 +1,235 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"reflect"
+
+	"github.com/x448/float16"
+)
+
+var coreDetEncMode, _ = CoreDetEncOptions().encMode()
+
+// Canonicalize returns a copy of m re-encoded using the encoding options of em.
+// If em is nil, Core Deterministic Encoding options are used.
+//
+// Canonicalize transcodes m without decoding it to Go values, so every data item
+// (including tags, simple values, and undefined) is preserved.  Specifically:
+//   - integers, lengths, and tag numbers are encoded in shortest form,
+//   - indefinite-length strings, arrays, and maps are encoded with definite length,
+//   - map keys are sorted as specified by em's Sort option,
+//   - floating-point numbers are encoded as specified by em's ShortestFloat, NaNConvert,
+//     InfConvert, and NumericReduction options.  float16 values are kept as is if ShortestFloat
+//     is ShortestFloatNone,
+//   - simple values and negative integers are rejected as specified by em's SimpleValues
+//     and NegativeInt65 options.
+//
+// An error is returned if m is not a single well-formed CBOR data item, or if a map contains
+// duplicate keys after canonicalization (only detected when em sorts map keys).
+func (m RawMessage) Canonicalize(em EncMode) (RawMessage, error) {
+	iem, err := canonicalEncMode(em)
+	if err != nil {
+		return nil, err
+	}
+
+	d := decoder{data: m, dm: defaultDecMode}
+	if err := d.wellformed(false, false); err != nil {
+		return nil, err
+	}
+
+	var e bytes.Buffer
+	d.reset(m)
+	if err := canonicalize(&e, iem, &d); err != nil {
+		return nil, err
+	}
+	return RawMessage(e.Bytes()), nil
+}
+
+// Canonicalize returns a copy of data re-encoded using the encoding options of em,
+// without decoding data to Go values.  It is useful for normalizing stored documents
+// before hashing or deduplication.  If em is nil, Core Deterministic Encoding options
+// are used.  See RawMessage.Canonicalize for details.
+func Canonicalize(data []byte, em EncMode) ([]byte, error) {
+	return RawMessage(data).Canonicalize(em)
+}
+
+// Equal reports whether CBOR data items a and b are equal after being canonicalized
+// using em.  If em is nil, Core Deterministic Encoding options are used.
+// It is useful for comparing data items for signature verification and deduplication.
+func Equal(a, b RawMessage, em EncMode) (bool, error) {
+	ca, err := a.Canonicalize(em)
+	if err != nil {
+		return false, err
+	}
+	cb, err := b.Canonicalize(em)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ca, cb), nil
+}
+
+func canonicalEncMode(em EncMode) (*encMode, error) {
+	if em == nil {
+		return coreDetEncMode, nil
+	}
+	iem, ok := em.(*encMode)
+	if !ok {
+		return nil, errors.New("cbor: unsupported EncMode implementation " + reflect.TypeOf(em).String())
+	}
+	return iem, nil
+}
+
+// canonicalize transcodes the data item at d.off to e.
+// It assumes data is well-formed, and does not perform bounds checking.
+func canonicalize(e *bytes.Buffer, em *encMode, d *decoder) error {
+	t, ai, val := d.getHead()
+	indefiniteLength := additionalInformation(ai).isIndefiniteLength()
+
+	switch t {
+	case cborTypePositiveInt:
+		encodeHead(e, byte(t), val)
+		return nil
+
+	case cborTypeNegativeInt:
+		if err := em.acceptableNegativeInt(val); err != nil {
+			return err
+		}
+		encodeHead(e, byte(t), val)
+		return nil
+
+	case cborTypeByteString, cborTypeTextString:
+		if !indefiniteLength {
+			encodeHead(e, byte(t), val)
+			e.Write(d.data[d.off : d.off+int(val)])
+			d.off += int(val)
+			return nil
+		}
+		var b []byte
+		for !d.foundBreak() {
+			_, _, n := d.getHead()
+			b = append(b, d.data[d.off:d.off+int(n)]...)
+			d.off += int(n)
+		}
+		encodeHead(e, byte(t), uint64(len(b)))
+		e.Write(b)
+		return nil
+
+	case cborTypeArray:
+		count := int(val)
+		if indefiniteLength {
+			count = d.numOfItemsUntilBreak()
+		}
+		encodeHead(e, byte(t), uint64(count))
+		for i := 0; i < count; i++ {
+			if err := canonicalize(e, em, d); err != nil {
+				return err
+			}
+		}
+		if indefiniteLength {
+			d.off++ // Skip break code
+		}
+		return nil
+
+	case cborTypeMap:
+		count := int(val)
+		if indefiniteLength {
+			count = d.numOfItemsUntilBreak() / 2
+		}
+		err := canonicalizeMap(e, em, d, count)
+		if indefiniteLength {
+			d.off++ // Skip break code
+		}
+		return err
+
+	case cborTypeTag:
+		encodeHead(e, byte(t), val)
+		return canonicalize(e, em, d)
+	}
+
+	// CBOR primitives
+	switch ai {
+	case additionalInformationAsFloat16:
+		if em.shortestFloat == ShortestFloatNone {
+			f16 := float16.Float16(val)
+			if f16.IsNaN() && em.nanConvert == NaNConvertReject {
+				return &UnsupportedValueError{msg: "floating-point NaN"}
+			}
+			if f16.IsInf(0) && em.infConvert == InfConvertReject {
+				return &UnsupportedValueError{msg: "floating-point infinity"}
+			}
+			return encodeFloat16(e, f16)
+		}
+		f := float16.Frombits(uint16(val)).Float32()
+		return encodeFloat(e, em, reflect.ValueOf(f))
+
+	case additionalInformationAsFloat32:
+		f := math.Float32frombits(uint32(val))
+		return encodeFloat(e, em, reflect.ValueOf(f))
+
+	case additionalInformationAsFloat64:
+		f := math.Float64frombits(val)
+		return encodeFloat(e, em, reflect.ValueOf(f))
+	}
+
+	if err := em.acceptableSimpleValue(val); err != nil {
+		return err
+	}
+	encodeHead(e, byte(t), val)
+	return nil
+}
+
+func canonicalizeMap(e *bytes.Buffer, em *encMode, d *decoder, count int) error {
+	encodeHead(e, byte(cborTypeMap), uint64(count))
+	if em.sort == SortNone || em.sort == SortFastShuffle || count <= 1 {
+		for i := 0; i < count*2; i++ {
+			if err := canonicalize(e, em, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	kvsp := getKeyValues(count) // for sorting keys
+	defer putKeyValues(kvsp)
+	kvs := *kvsp
+
+	kvBeginOffset := e.Len()
+	for i := 0; i < count; i++ {
+		offset := e.Len()
+		if err := canonicalize(e, em, d); err != nil {
+			return err
+		}
+		valueOffset := e.Len()
+		if err := canonicalize(e, em, d); err != nil {
+			return err
+		}
+		kvs[i] = keyValue{
+			offset:      offset - kvBeginOffset,
+			valueOffset: valueOffset - kvBeginOffset,
+			nextOffset:  e.Len() - kvBeginOffset,
+		}
+	}
+	kvTotalLen := e.Len() - kvBeginOffset
+	dst := e.Bytes()[kvBeginOffset:]
+
+	sortKeyValuesByKeys(em, kvs, dst)
+
+	for i := 1; i < count; i++ {
+		prev, cur := kvs[i-1], kvs[i]
+		if bytes.Equal(dst[prev.offset:prev.valueOffset], dst[cur.offset:cur.valueOffset]) {
+			return errors.New("cbor: found duplicate map key 0x" + hex.EncodeToString(dst[cur.offset:cur.valueOffset]) + " after canonicalization")
+		}
+	}
+
+	sorted := make([]byte, 0, kvTotalLen)
+	for _, kv := range kvs {
+		sorted = append(sorted, dst[kv.offset:kv.nextOffset]...)
+	}
+	copy(dst, sorted)
+	return nil
+}
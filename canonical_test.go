@@ -0,0 +1,326 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRawMessageCanonicalize(t *testing.T) {
+	ctap2EncMode, err := CTAP2EncOptions().EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	sortNoneEncMode, err := EncOptions{}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		em   EncMode
+		data []byte
+		want []byte
+	}{
+		{
+			name: "integer not in shortest form",
+			data: hexDecode("1b0000000000000001"),
+			want: hexDecode("01"),
+		},
+		{
+			name: "negative integer not in shortest form",
+			data: hexDecode("3900ff"),
+			want: hexDecode("38ff"),
+		},
+		{
+			name: "indefinite-length byte string",
+			data: hexDecode("5f42010243030405ff"),
+			want: hexDecode("450102030405"),
+		},
+		{
+			name: "indefinite-length text string",
+			data: hexDecode("7f657374726561646d696e67ff"),
+			want: hexDecode("6973747265616d696e67"),
+		},
+		{
+			name: "indefinite-length array with nested items",
+			data: hexDecode("9f018202039f0405ffff"),
+			want: hexDecode("8301820203820405"),
+		},
+		{
+			name: "map keys sorted bytewise lexicographic",
+			data: hexDecode("a361620119000102626161190064"),
+			want: hexDecode("a301026162016261611864"),
+		},
+		{
+			name: "indefinite-length map",
+			data: hexDecode("bf6161016162820203ff"),
+			want: hexDecode("a26161016162820203"),
+		},
+		{
+			name: "tag number not in shortest form",
+			data: hexDecode("d900011a00000001"),
+			want: hexDecode("c101"),
+		},
+		{
+			name: "float64 shortened to float16",
+			data: hexDecode("fb3ff8000000000000"),
+			want: hexDecode("f93e00"),
+		},
+		{
+			name: "float32 NaN converted",
+			data: hexDecode("fa7fc00001"),
+			want: hexDecode("f97e00"),
+		},
+		{
+			name: "simple value and undefined are preserved",
+			data: hexDecode("82f7f820"),
+			want: hexDecode("82f7f820"),
+		},
+		{
+			name: "map keys sorted length-first",
+			em:   ctap2EncMode,
+			data: hexDecode("a2626161010a02"),
+			want: hexDecode("a20a0262616101"),
+		},
+		{
+			name: "map keys not sorted",
+			em:   sortNoneEncMode,
+			data: hexDecode("bf626161010a02ff"),
+			want: hexDecode("a2626161010a02"),
+		},
+		{
+			name: "float16 kept with ShortestFloatNone",
+			em:   sortNoneEncMode,
+			data: hexDecode("f93e00"),
+			want: hexDecode("f93e00"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := RawMessage(tc.data).Canonicalize(tc.em)
+			if err != nil {
+				t.Fatalf("Canonicalize(0x%x) returned error %v", tc.data, err)
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("Canonicalize(0x%x) = 0x%x, want 0x%x", tc.data, []byte(got), tc.want)
+			}
+		})
+	}
+}
+
+func TestRawMessageCanonicalizeError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "empty data",
+			data:         nil,
+			wantErrorMsg: "EOF",
+		},
+		{
+			name:         "truncated data",
+			data:         hexDecode("8201"),
+			wantErrorMsg: "unexpected EOF",
+		},
+		{
+			name:         "extraneous data",
+			data:         hexDecode("0102"),
+			wantErrorMsg: "cbor: 1 bytes of extraneous data starting at index 1",
+		},
+		{
+			name:         "duplicate map keys after canonicalization",
+			data:         hexDecode("a20102180103"),
+			wantErrorMsg: "cbor: found duplicate map key 0x01 after canonicalization",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := RawMessage(tc.data).Canonicalize(nil); err == nil {
+				t.Errorf("Canonicalize(0x%x) didn't return an error", tc.data)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Canonicalize(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	testCases := []struct {
+		name string
+		a, b []byte
+		want bool
+	}{
+		{
+			name: "identical",
+			a:    hexDecode("a201020304"),
+			b:    hexDecode("a201020304"),
+			want: true,
+		},
+		{
+			name: "different map key order and argument sizes",
+			a:    hexDecode("a201020304"),
+			b:    hexDecode("bf18030419000102ff"),
+			want: true,
+		},
+		{
+			name: "different float widths",
+			a:    hexDecode("fb3ff8000000000000"),
+			b:    hexDecode("fa3fc00000"),
+			want: true,
+		},
+		{
+			name: "different values",
+			a:    hexDecode("a201020304"),
+			b:    hexDecode("a201020305"),
+			want: false,
+		},
+		{
+			name: "different types",
+			a:    hexDecode("01"),
+			b:    hexDecode("f93c00"),
+			want: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Equal(tc.a, tc.b, nil)
+			if err != nil {
+				t.Fatalf("Equal(0x%x, 0x%x) returned error %v", tc.a, tc.b, err)
+			}
+			if got != tc.want {
+				t.Errorf("Equal(0x%x, 0x%x) = %t, want %t", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+
+	if _, err := Equal(hexDecode("01"), hexDecode("82"), nil); err == nil {
+		t.Errorf("Equal() didn't return an error for malformed data")
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	lengthFirstEM, err := EncOptions{Sort: SortLengthFirst}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	// {"bb": 1, "a": [_ 1.5]} with indefinite-length array and non-shortest argument
+	data := hexDecode("a2626262180161619ffb3ff8000000000000ff")
+	testCases := []struct {
+		name string
+		em   EncMode
+		want []byte
+	}{
+		{name: "core deterministic", em: nil, want: hexDecode("a2616181f93e0062626201")},
+		{name: "length first", em: lengthFirstEM, want: hexDecode("a2616181fb3ff800000000000062626201")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Canonicalize(data, tc.em)
+			if err != nil {
+				t.Fatalf("Canonicalize(0x%x) returned error %v", data, err)
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("Canonicalize(0x%x) = 0x%x, want 0x%x", data, got, tc.want)
+			}
+		})
+	}
+
+	if _, err := Canonicalize(hexDecode("a2"), nil); err == nil {
+		t.Errorf("Canonicalize(0xa2) didn't return an error")
+	}
+}
+
+func TestCanonicalizeRejectNaNAndInf(t *testing.T) {
+	em, err := EncOptions{
+		ShortestFloat: ShortestFloatNone,
+		NaNConvert:    NaNConvertReject,
+		InfConvert:    InfConvertReject,
+	}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{"float16 NaN", hexDecode("f97e00"), "cbor: unsupported value: floating-point NaN"},
+		{"float32 NaN", hexDecode("fa7fc00000"), "cbor: unsupported value: floating-point NaN"},
+		{"float64 NaN", hexDecode("fb7ff8000000000000"), "cbor: unsupported value: floating-point NaN"},
+		{"float16 infinity", hexDecode("f97c00"), "cbor: unsupported value: floating-point infinity"},
+		{"float32 negative infinity", hexDecode("faff800000"), "cbor: unsupported value: floating-point infinity"},
+		{"float64 infinity in array", hexDecode("81fb7ff0000000000000"), "cbor: unsupported value: floating-point infinity"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Canonicalize(tc.data, em)
+			if err == nil {
+				t.Fatalf("Canonicalize(0x%x) didn't return an error", tc.data)
+			}
+			if _, ok := err.(*UnsupportedValueError); !ok {
+				t.Errorf("Canonicalize(0x%x) returned wrong error type %T, want (*UnsupportedValueError)", tc.data, err)
+			}
+			if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Canonicalize(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+
+	// Finite float16 is kept as is.
+	got, err := Canonicalize(hexDecode("f93e00"), em)
+	if err != nil {
+		t.Fatalf("Canonicalize(0xf93e00) returned error %v", err)
+	}
+	if want := hexDecode("f93e00"); !bytes.Equal(got, want) {
+		t.Errorf("Canonicalize(0xf93e00) = 0x%x, want 0x%x", got, want)
+	}
+}
+
+func TestCanonicalizeDCBOR(t *testing.T) {
+	em, err := DCBOREncOptions().EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		data         []byte
+		want         []byte
+		wantErrorMsg string
+	}{
+		{name: "float64 1.0", data: hexDecode("fb3ff0000000000000"), want: hexDecode("01")},
+		{name: "float16 -0.0", data: hexDecode("f98000"), want: hexDecode("00")},
+		{name: "float32 NaN", data: hexDecode("fa7fc00001"), want: hexDecode("f97e00")},
+		{name: "float16 1.5 in map", data: hexDecode("a16161f93e00"), want: hexDecode("a16161f93e00")},
+		{name: "nint -2^63", data: hexDecode("3b7fffffffffffffff"), want: hexDecode("3b7fffffffffffffff")},
+		{name: "nint -2^64", data: hexDecode("813bffffffffffffffff"), wantErrorMsg: "cbor: unsupported value: 65-bit negative integer"},
+		{name: "undefined", data: hexDecode("f7"), wantErrorMsg: "cbor: unsupported value: simple value 23"},
+		{name: "simple value 255", data: hexDecode("a101f8ff"), wantErrorMsg: "cbor: unsupported value: simple value 255"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Canonicalize(tc.data, em)
+			if tc.wantErrorMsg != "" {
+				if err == nil {
+					t.Errorf("Canonicalize(0x%x) didn't return an error, want %q", tc.data, tc.wantErrorMsg)
+				} else if err.Error() != tc.wantErrorMsg {
+					t.Errorf("Canonicalize(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Canonicalize(0x%x) returned error %v", tc.data, err)
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("Canonicalize(0x%x) = 0x%x, want 0x%x", tc.data, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+// Names of deterministic encoding profiles reported by Capabilities.
+const (
+	// ProfilePreferred is "Preferred Serialization" defined in RFC 8949 Section 4.1.
+	ProfilePreferred = "preferred"
+
+	// ProfileCoreDeterministic is "Core Deterministic Encoding" defined in RFC 8949 Section 4.2.1.
+	ProfileCoreDeterministic = "core-deterministic"
+
+	// ProfileCanonical is "Canonical CBOR" defined in RFC 7049 Section 3.9.
+	ProfileCanonical = "canonical"
+
+	// ProfileCTAP2 is "CTAP2 Canonical CBOR" defined in FIDO CTAP specification.
+	ProfileCTAP2 = "ctap2-canonical"
+
+	// ProfileDCBOR is the "dCBOR" deterministic CBOR application profile.
+	ProfileDCBOR = "dcbor"
+)
+
+// Capabilities describes CBOR features supported by this package, and characteristics
+// of encoding and decoding modes.  It can be encoded (e.g. with Marshal) and exchanged
+// with peers or test harnesses to negotiate capabilities.
+type Capabilities struct {
+	// Specification is the CBOR specification implemented by this package.
+	Specification string `cbor:"specification"`
+
+	// Tags are tag numbers with builtin support, in ascending order.  Tag numbers
+	// registered with TagSet aren't included.
+	Tags []uint64 `cbor:"tags"`
+
+	// Profiles are deterministic encoding profiles with encoding presets
+	// (e.g. ProfileCoreDeterministic).
+	Profiles []string `cbor:"profiles"`
+
+	// Encoding describes the encoding mode, or is nil if no encoding mode is given.
+	Encoding *EncCapabilities `cbor:"encoding,omitempty"`
+
+	// Decoding describes the decoding mode, or is nil if no decoding mode is given.
+	Decoding *DecCapabilities `cbor:"decoding,omitempty"`
+}
+
+// EncCapabilities describes characteristics of an encoding mode.
+type EncCapabilities struct {
+	// Profile is the deterministic encoding profile the encoding mode complies with,
+	// or empty if it doesn't comply with any profile in Capabilities.Profiles.
+	// The most specific profile is reported, e.g. ProfileDCBOR instead of
+	// ProfileCoreDeterministic.
+	Profile string `cbor:"profile,omitempty"`
+
+	// SortedMapKeys is true if map keys are sorted.
+	SortedMapKeys bool `cbor:"sortedMapKeys"`
+
+	// ShortestFloat is true if floating-point values are encoded in the shortest
+	// form that preserves the value.
+	ShortestFloat bool `cbor:"shortestFloat"`
+
+	// IndefiniteLength is true if indefinite-length items can be encoded.
+	IndefiniteLength bool `cbor:"indefiniteLength"`
+
+	// Tags is true if tags can be encoded.
+	Tags bool `cbor:"tags"`
+}
+
+// DecCapabilities describes characteristics of a decoding mode.
+type DecCapabilities struct {
+	// Profile is the deterministic encoding profile enforced by the decoding mode,
+	// or empty if deterministic encoding isn't enforced.
+	Profile string `cbor:"profile,omitempty"`
+
+	// IndefiniteLength is true if indefinite-length items are accepted.
+	IndefiniteLength bool `cbor:"indefiniteLength"`
+
+	// Tags is true if tags are accepted.
+	Tags bool `cbor:"tags"`
+
+	// DuplicateMapKeys is true if maps with duplicate keys are accepted.
+	DuplicateMapKeys bool `cbor:"duplicateMapKeys"`
+
+	// InvalidUTF8 is true if text strings with invalid UTF-8 are accepted.
+	InvalidUTF8 bool `cbor:"invalidUTF8"`
+
+	// Limits of decoded data items.  See DecOptions for details.
+	MaxNestedLevels    int `cbor:"maxNestedLevels"`
+	MaxArrayElements   int `cbor:"maxArrayElements"`
+	MaxMapPairs        int `cbor:"maxMapPairs"`
+	MaxStringBytes     int `cbor:"maxStringBytes,omitempty"`
+	MaxByteStringBytes int `cbor:"maxByteStringBytes,omitempty"`
+}
+
+// SupportedCapabilities returns Capabilities describing CBOR features supported by this
+// package, and characteristics of em and dm.  em and dm can be nil.
+func SupportedCapabilities(em EncMode, dm DecMode) Capabilities {
+	c := Capabilities{
+		Specification: "RFC 8949",
+		Tags: []uint64{
+			tagNumRFC3339Time,
+			tagNumEpochTime,
+			tagNumUnsignedBignum,
+			tagNumNegativeBignum,
+			tagNumDecimalFraction,
+			tagNumBigFloat,
+			tagNumExpectedLaterEncodingBase64URL,
+			tagNumExpectedLaterEncodingBase64,
+			tagNumExpectedLaterEncodingBase16,
+			tagNumEncodedCBORDataItem,
+			tagNumRational,
+			tagNumCWT,
+			tagNumDays,
+			tagNumExtendedTime,
+			tagNumDuration,
+			tagNumFullDate,
+			tagNumSelfDescribedCBOR,
+		},
+		Profiles: []string{
+			ProfilePreferred,
+			ProfileCoreDeterministic,
+			ProfileCanonical,
+			ProfileCTAP2,
+			ProfileDCBOR,
+		},
+	}
+
+	if em != nil {
+		opts := em.EncOptions()
+		c.Encoding = &EncCapabilities{
+			Profile:          encProfile(opts),
+			SortedMapKeys:    opts.Sort != SortNone && opts.Sort != SortFastShuffle,
+			ShortestFloat:    opts.ShortestFloat == ShortestFloat16,
+			IndefiniteLength: opts.IndefLength == IndefLengthAllowed,
+			Tags:             opts.TagsMd == TagsAllowed,
+		}
+	}
+
+	if dm != nil {
+		opts := dm.DecOptions()
+		dc := &DecCapabilities{
+			IndefiniteLength:   opts.IndefLength == IndefLengthAllowed,
+			Tags:               opts.TagsMd == TagsAllowed,
+			DuplicateMapKeys:   opts.DupMapKey == DupMapKeyQuiet && opts.Deterministic == DeterministicNotChecked,
+			InvalidUTF8:        opts.UTF8 == UTF8DecodeInvalid,
+			MaxNestedLevels:    opts.MaxNestedLevels,
+			MaxArrayElements:   opts.MaxArrayElements,
+			MaxMapPairs:        opts.MaxMapPairs,
+			MaxStringBytes:     opts.MaxStringBytes,
+			MaxByteStringBytes: opts.MaxByteStringBytes,
+		}
+		switch opts.Deterministic {
+		case DeterministicCoreRequired:
+			dc.Profile = ProfileCoreDeterministic
+		case DeterministicDCBORRequired:
+			dc.Profile = ProfileDCBOR
+		}
+		c.Decoding = dc
+	}
+
+	return c
+}
+
+// encProfile returns the most specific deterministic encoding profile that encoding
+// options opts comply with, or empty string.
+func encProfile(opts EncOptions) string { //nolint:gocritic // ignore hugeParam
+	preferredFloat := opts.ShortestFloat == ShortestFloat16 &&
+		(opts.NaNConvert == NaNConvert7e00 || opts.NaNConvert == NaNConvertReject) &&
+		(opts.InfConvert == InfConvertFloat16 || opts.InfConvert == InfConvertReject)
+	definiteLength := opts.IndefLength == IndefLengthForbidden
+
+	switch {
+	case opts.Sort == SortCoreDeterministic && preferredFloat && definiteLength:
+		if opts.NumericReduction == NumericReductionFloatToInt &&
+			opts.SimpleValues == SimpleValuesFalseTrueNull &&
+			opts.NegativeInt65 == NegativeInt65Forbidden {
+			return ProfileDCBOR
+		}
+		return ProfileCoreDeterministic
+
+	case opts.Sort == SortCTAP2 && definiteLength && opts.TagsMd == TagsForbidden &&
+		opts.ShortestFloat == ShortestFloatNone:
+		return ProfileCTAP2
+
+	case opts.Sort == SortCanonical && preferredFloat && definiteLength:
+		return ProfileCanonical
+
+	case preferredFloat:
+		return ProfilePreferred
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSupportedCapabilities(t *testing.T) {
+	c := SupportedCapabilities(nil, nil)
+	if c.Specification != "RFC 8949" {
+		t.Errorf("Specification = %q, want %q", c.Specification, "RFC 8949")
+	}
+	wantTags := []uint64{0, 1, 2, 3, 4, 5, 21, 22, 23, 24, 30, 61, 100, 1001, 1002, 1004, 55799}
+	if !reflect.DeepEqual(c.Tags, wantTags) {
+		t.Errorf("Tags = %v, want %v", c.Tags, wantTags)
+	}
+	wantProfiles := []string{"preferred", "core-deterministic", "canonical", "ctap2-canonical", "dcbor"}
+	if !reflect.DeepEqual(c.Profiles, wantProfiles) {
+		t.Errorf("Profiles = %v, want %v", c.Profiles, wantProfiles)
+	}
+	if c.Encoding != nil || c.Decoding != nil {
+		t.Errorf("SupportedCapabilities(nil, nil) returned mode capabilities %+v, %+v", c.Encoding, c.Decoding)
+	}
+}
+
+func TestSupportedCapabilitiesEncMode(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts EncOptions
+		want EncCapabilities
+	}{
+		{
+			name: "default",
+			opts: EncOptions{},
+			want: EncCapabilities{IndefiniteLength: true, Tags: true},
+		},
+		{
+			name: "preferred",
+			opts: PreferredUnsortedEncOptions(),
+			want: EncCapabilities{Profile: ProfilePreferred, ShortestFloat: true, IndefiniteLength: true, Tags: true},
+		},
+		{
+			name: "core deterministic",
+			opts: CoreDetEncOptions(),
+			want: EncCapabilities{Profile: ProfileCoreDeterministic, SortedMapKeys: true, ShortestFloat: true, Tags: true},
+		},
+		{
+			name: "canonical",
+			opts: CanonicalEncOptions(),
+			want: EncCapabilities{Profile: ProfileCanonical, SortedMapKeys: true, ShortestFloat: true, Tags: true},
+		},
+		{
+			name: "CTAP2",
+			opts: CTAP2EncOptions(),
+			want: EncCapabilities{Profile: ProfileCTAP2, SortedMapKeys: true},
+		},
+		{
+			name: "dCBOR",
+			opts: DCBOREncOptions(),
+			want: EncCapabilities{Profile: ProfileDCBOR, SortedMapKeys: true, ShortestFloat: true, Tags: true},
+		},
+		{
+			name: "sorted with indefinite length",
+			opts: EncOptions{Sort: SortCoreDeterministic},
+			want: EncCapabilities{SortedMapKeys: true, IndefiniteLength: true, Tags: true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := tc.opts.EncMode()
+			if err != nil {
+				t.Fatalf("EncMode() returned error %v", err)
+			}
+			c := SupportedCapabilities(em, nil)
+			if c.Decoding != nil {
+				t.Errorf("SupportedCapabilities() returned decoding capabilities %+v, want nil", c.Decoding)
+			}
+			if c.Encoding == nil {
+				t.Fatalf("SupportedCapabilities() returned nil encoding capabilities")
+			}
+			if *c.Encoding != tc.want {
+				t.Errorf("SupportedCapabilities() returned encoding capabilities %+v, want %+v", *c.Encoding, tc.want)
+			}
+		})
+	}
+}
+
+func TestSupportedCapabilitiesDecMode(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts DecOptions
+		want DecCapabilities
+	}{
+		{
+			name: "default",
+			opts: DecOptions{},
+			want: DecCapabilities{
+				IndefiniteLength: true,
+				Tags:             true,
+				DuplicateMapKeys: true,
+				MaxNestedLevels:  32,
+				MaxArrayElements: 131072,
+				MaxMapPairs:      131072,
+			},
+		},
+		{
+			name: "core deterministic",
+			opts: CoreDetDecOptions(),
+			want: DecCapabilities{
+				Profile:          ProfileCoreDeterministic,
+				Tags:             true,
+				MaxNestedLevels:  32,
+				MaxArrayElements: 131072,
+				MaxMapPairs:      131072,
+			},
+		},
+		{
+			name: "dCBOR",
+			opts: DCBORDecOptions(),
+			want: DecCapabilities{
+				Profile:          ProfileDCBOR,
+				Tags:             true,
+				MaxNestedLevels:  32,
+				MaxArrayElements: 131072,
+				MaxMapPairs:      131072,
+			},
+		},
+		{
+			name: "untrusted",
+			opts: UntrustedDecOptions(),
+			want: DecCapabilities{
+				Tags:               true,
+				MaxNestedLevels:    16,
+				MaxArrayElements:   4096,
+				MaxMapPairs:        4096,
+				MaxStringBytes:     64 * 1024,
+				MaxByteStringBytes: 1024 * 1024,
+			},
+		},
+		{
+			name: "invalid UTF-8 and no tags",
+			opts: DecOptions{UTF8: UTF8DecodeInvalid, TagsMd: TagsForbidden},
+			want: DecCapabilities{
+				IndefiniteLength: true,
+				DuplicateMapKeys: true,
+				InvalidUTF8:      true,
+				MaxNestedLevels:  32,
+				MaxArrayElements: 131072,
+				MaxMapPairs:      131072,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := tc.opts.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned error %v", err)
+			}
+			c := SupportedCapabilities(nil, dm)
+			if c.Encoding != nil {
+				t.Errorf("SupportedCapabilities() returned encoding capabilities %+v, want nil", c.Encoding)
+			}
+			if c.Decoding == nil {
+				t.Fatalf("SupportedCapabilities() returned nil decoding capabilities")
+			}
+			if *c.Decoding != tc.want {
+				t.Errorf("SupportedCapabilities() returned decoding capabilities %+v, want %+v", *c.Decoding, tc.want)
+			}
+		})
+	}
+}
+
+func TestCapabilitiesRoundTrip(t *testing.T) {
+	em, err := CoreDetEncOptions().EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	c := SupportedCapabilities(em, UntrustedDecMode())
+
+	b, err := em.Marshal(c)
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+	var got Capabilities
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal() returned error %v", err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("Unmarshal(Marshal(%+v)) = %+v", c, got)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ChoiceAlternative is an alternative of Choice.
+type ChoiceAlternative struct {
+	// Name identifies the alternative in ChoiceError (e.g. name of CDDL rule).
+	Name string
+
+	// Type is the Go type that CBOR data item is decoded into for this alternative.
+	Type reflect.Type
+
+	// Match, if not nil, reports whether CBOR data item can be this alternative,
+	// e.g. by checking a discriminator such as tag number or value of a map key.
+	// The alternative is skipped if Match returns false.
+	Match func(data []byte) bool
+}
+
+// Choice decodes CBOR data item into the first of several alternative Go types that
+// accepts it, similar to CDDL choice (e.g. "shape = circle / rectangle").
+//
+// Alternatives are tried in order, and the first alternative that matches and that
+// CBOR data item is decoded into without error is used.  Decoding is lenient by default
+// (e.g. unknown map keys are ignored), so use a DecMode with restrictions such as
+// ExtraDecErrorUnknownField, or use Match, to reject data items of other alternatives.
+type Choice struct {
+	dm           DecMode
+	alternatives []ChoiceAlternative
+}
+
+// NewChoice returns Choice with alternatives decoded using dm (default decoding mode
+// if dm is nil).  It returns an error if there are no alternatives or if any
+// alternative doesn't have Type.
+func NewChoice(dm DecMode, alternatives ...ChoiceAlternative) (*Choice, error) {
+	if len(alternatives) == 0 {
+		return nil, errors.New("cbor: choice must have at least one alternative")
+	}
+	for i, alt := range alternatives {
+		if alt.Type == nil {
+			return nil, errors.New("cbor: choice alternative " + strconv.Itoa(i) + " has nil Type")
+		}
+	}
+	if dm == nil {
+		dm = defaultDecMode
+	}
+	alts := make([]ChoiceAlternative, len(alternatives))
+	copy(alts, alternatives)
+	return &Choice{dm: dm, alternatives: alts}, nil
+}
+
+// Unmarshal decodes CBOR data item into the first matching alternative, and returns index
+// of the alternative and the decoded value of the alternative's Type.  If data isn't
+// well-formed, the error is returned as is.  If no alternative matches, ChoiceError is
+// returned.
+func (c *Choice) Unmarshal(data []byte) (int, interface{}, error) {
+	if err := c.dm.Wellformed(data); err != nil {
+		return -1, nil, err
+	}
+
+	errs := make([]error, len(c.alternatives))
+	for i, alt := range c.alternatives {
+		if alt.Match != nil && !alt.Match(data) {
+			errs[i] = errChoiceAlternativeNotMatched
+			continue
+		}
+		v := reflect.New(alt.Type)
+		if err := c.dm.Unmarshal(data, v.Interface()); err != nil {
+			errs[i] = err
+			continue
+		}
+		return i, v.Elem().Interface(), nil
+	}
+	return -1, nil, &ChoiceError{alternatives: c.alternatives, Errs: errs}
+}
+
+var errChoiceAlternativeNotMatched = errors.New("not matched")
+
+// ChoiceError is returned by Choice.Unmarshal when CBOR data item doesn't match any
+// alternative.
+type ChoiceError struct {
+	alternatives []ChoiceAlternative
+
+	// Errs contains error of each alternative, in the same order as alternatives.
+	// Error of alternative skipped by Match has message "not matched".
+	Errs []error
+}
+
+func (e *ChoiceError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("cbor: CBOR data item doesn't match any choice alternative")
+	for i, err := range e.Errs {
+		if i == 0 {
+			sb.WriteString(": ")
+		} else {
+			sb.WriteString("; ")
+		}
+		name := e.alternatives[i].Name
+		if name == "" {
+			name = e.alternatives[i].Type.String()
+		}
+		sb.WriteString(name + " (" + strings.TrimPrefix(err.Error(), "cbor: ") + ")")
+	}
+	return sb.String()
+}
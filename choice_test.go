@@ -0,0 +1,176 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+type choiceCircle struct {
+	R int `cbor:"r"`
+}
+
+type choiceRectangle struct {
+	W int `cbor:"w"`
+	H int `cbor:"h"`
+}
+
+func TestChoice(t *testing.T) {
+	dm, err := DecOptions{ExtraReturnErrors: ExtraDecErrorUnknownField}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	c, err := NewChoice(dm,
+		ChoiceAlternative{Name: "circle", Type: reflect.TypeOf(choiceCircle{})},
+		ChoiceAlternative{Name: "rectangle", Type: reflect.TypeOf(choiceRectangle{})},
+		ChoiceAlternative{Type: reflect.TypeOf(uint(0))},
+	)
+	if err != nil {
+		t.Fatalf("NewChoice() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		data      []byte
+		wantIndex int
+		wantValue interface{}
+	}{
+		{
+			name:      "first alternative",
+			data:      hexDecode("a1617201"), // {"r": 1}
+			wantIndex: 0,
+			wantValue: choiceCircle{R: 1},
+		},
+		{
+			name:      "second alternative",
+			data:      hexDecode("a2617702616803"), // {"w": 2, "h": 3}
+			wantIndex: 1,
+			wantValue: choiceRectangle{W: 2, H: 3},
+		},
+		{
+			name:      "third alternative",
+			data:      hexDecode("05"),
+			wantIndex: 2,
+			wantValue: uint(5),
+		},
+		{
+			name:      "empty map matches first alternative",
+			data:      hexDecode("a0"),
+			wantIndex: 0,
+			wantValue: choiceCircle{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			i, v, err := c.Unmarshal(tc.data)
+			if err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if i != tc.wantIndex {
+				t.Errorf("Unmarshal(0x%x) returned index %d, want %d", tc.data, i, tc.wantIndex)
+			}
+			if !reflect.DeepEqual(v, tc.wantValue) {
+				t.Errorf("Unmarshal(0x%x) = %#v, want %#v", tc.data, v, tc.wantValue)
+			}
+		})
+	}
+}
+
+func TestChoiceMatch(t *testing.T) {
+	matchTag := func(num uint64) func([]byte) bool {
+		return func(data []byte) bool {
+			var tag RawTag
+			return Unmarshal(data, &tag) == nil && tag.Number == num
+		}
+	}
+
+	c, err := NewChoice(nil,
+		ChoiceAlternative{Name: "circle", Type: reflect.TypeOf(choiceCircle{}), Match: matchTag(100)},
+		ChoiceAlternative{Name: "rectangle", Type: reflect.TypeOf(choiceRectangle{}), Match: matchTag(101)},
+	)
+	if err != nil {
+		t.Fatalf("NewChoice() returned error %v", err)
+	}
+
+	// Without Match, default decoding mode would decode this data item as circle.
+	data := hexDecode("d865a2617702616803") // 101({"w": 2, "h": 3})
+	i, v, err := c.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	if i != 1 {
+		t.Errorf("Unmarshal(0x%x) returned index %d, want 1", data, i)
+	}
+	want := choiceRectangle{W: 2, H: 3}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("Unmarshal(0x%x) = %#v, want %#v", data, v, want)
+	}
+}
+
+func TestChoiceError(t *testing.T) {
+	dm, err := DecOptions{ExtraReturnErrors: ExtraDecErrorUnknownField}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	c, err := NewChoice(dm,
+		ChoiceAlternative{Name: "circle", Type: reflect.TypeOf(choiceCircle{})},
+		ChoiceAlternative{Type: reflect.TypeOf(uint(0)), Match: func([]byte) bool { return false }},
+	)
+	if err != nil {
+		t.Fatalf("NewChoice() returned error %v", err)
+	}
+
+	data := hexDecode("a2617702616803") // {"w": 2, "h": 3}
+	_, _, err = c.Unmarshal(data)
+	wantErrorMsg := "cbor: CBOR data item doesn't match any choice alternative: circle (found unknown field at map element index 0); uint (not matched)"
+	if err == nil {
+		t.Fatalf("Unmarshal(0x%x) didn't return an error", data)
+	}
+	if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
+	}
+	choiceErr, ok := err.(*ChoiceError)
+	if !ok {
+		t.Fatalf("Unmarshal(0x%x) returned error %T, want *ChoiceError", data, err)
+	}
+	if _, ok := choiceErr.Errs[0].(*UnknownFieldError); !ok {
+		t.Errorf("ChoiceError.Errs[0] is %T, want *UnknownFieldError", choiceErr.Errs[0])
+	}
+
+	data = hexDecode("a161")
+	if _, _, err = c.Unmarshal(data); err != io.ErrUnexpectedEOF {
+		t.Errorf("Unmarshal(0x%x) returned error %v, want %v", data, err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestNewChoiceError(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		alternatives []ChoiceAlternative
+		wantErrorMsg string
+	}{
+		{
+			name:         "no alternatives",
+			wantErrorMsg: "cbor: choice must have at least one alternative",
+		},
+		{
+			name:         "nil Type",
+			alternatives: []ChoiceAlternative{{Type: reflect.TypeOf(0)}, {Name: "x"}},
+			wantErrorMsg: "cbor: choice alternative 1 has nil Type",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewChoice(nil, tc.alternatives...)
+			if err == nil {
+				t.Errorf("NewChoice() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("NewChoice() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+/*
+Package codec provides adapters for using CBOR with RPC frameworks.
+
+ClientCodec and ServerCodec implement net/rpc's ClientCodec and ServerCodec interfaces:
+
+	client := rpc.NewClientWithCodec(codec.NewClientCodec(conn, em, dm))
+	go server.ServeCodec(codec.NewServerCodec(conn, em, dm))
+
+GRPCCodec implements the Codec interface of google.golang.org/grpc/encoding without
+importing gRPC, so it can be registered by programs that already depend on gRPC:
+
+	encoding.RegisterCodec(codec.NewGRPCCodec(em, dm))
+
+All adapters use the provided EncMode and DecMode, or default options if they are nil.
+*/
+package codec
@@ -0,0 +1,44 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package codec
+
+import "github.com/fxamacker/cbor/v2"
+
+// GRPCCodecName is the content-subtype of GRPCCodec.  gRPC uses it to select
+// the codec with content-type "application/grpc+cbor".
+const GRPCCodecName = "cbor"
+
+// GRPCCodec implements the Codec interface of google.golang.org/grpc/encoding.
+// It can be registered with encoding.RegisterCodec.
+type GRPCCodec struct {
+	em cbor.EncMode
+	dm cbor.DecMode
+}
+
+// NewGRPCCodec returns a new GRPCCodec using em for encoding and dm for decoding.
+// If em or dm is nil, default options are used.
+func NewGRPCCodec(em cbor.EncMode, dm cbor.DecMode) *GRPCCodec {
+	if em == nil {
+		em = defaultEncMode
+	}
+	if dm == nil {
+		dm = defaultDecMode
+	}
+	return &GRPCCodec{em: em, dm: dm}
+}
+
+// Marshal returns the CBOR encoding of v.
+func (c *GRPCCodec) Marshal(v interface{}) ([]byte, error) {
+	return c.em.Marshal(v)
+}
+
+// Unmarshal parses the CBOR-encoded data and stores the result in the value pointed to by v.
+func (c *GRPCCodec) Unmarshal(data []byte, v interface{}) error {
+	return c.dm.Unmarshal(data, v)
+}
+
+// Name returns GRPCCodecName.
+func (c *GRPCCodec) Name() string {
+	return GRPCCodecName
+}
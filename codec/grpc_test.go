@@ -0,0 +1,51 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// grpcCodec is the Codec interface of google.golang.org/grpc/encoding.
+type grpcCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	Name() string
+}
+
+var _ grpcCodec = (*GRPCCodec)(nil)
+
+func TestGRPCCodec(t *testing.T) {
+	em, err := cbor.EncOptions{Sort: cbor.SortCanonical}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	c := NewGRPCCodec(em, nil)
+
+	if name := c.Name(); name != "cbor" {
+		t.Errorf("Name() returned %q, want %q", name, "cbor")
+	}
+
+	v := map[int]string{10: "a", 1: "b"}
+	wantData, _ := hex.DecodeString("a20161620a6161")
+	data, err := c.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(%v) returned error %v", v, err)
+	}
+	if !bytes.Equal(data, wantData) {
+		t.Errorf("Marshal(%v) = 0x%x, want 0x%x", v, data, wantData)
+	}
+
+	var got map[int]string
+	if err := c.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	if len(got) != 2 || got[10] != "a" || got[1] != "b" {
+		t.Errorf("Unmarshal(0x%x) = %v, want %v", data, got, v)
+	}
+}
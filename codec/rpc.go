@@ -0,0 +1,144 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package codec
+
+import (
+	"bufio"
+	"io"
+	"net/rpc"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+var (
+	defaultEncMode, _ = cbor.EncOptions{}.EncMode()
+	defaultDecMode, _ = cbor.DecOptions{}.DecMode()
+)
+
+// ClientCodec implements rpc.ClientCodec using CBOR.  Each request and response
+// is encoded as a CBOR header (rpc.Request or rpc.Response) followed by a CBOR body.
+type ClientCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *cbor.Decoder
+	enc    *cbor.Encoder
+	encBuf *bufio.Writer
+}
+
+// NewClientCodec returns a new ClientCodec using conn for transport, em for encoding
+// requests, and dm for decoding responses.  If em or dm is nil, default options are used.
+func NewClientCodec(conn io.ReadWriteCloser, em cbor.EncMode, dm cbor.DecMode) *ClientCodec {
+	if em == nil {
+		em = defaultEncMode
+	}
+	if dm == nil {
+		dm = defaultDecMode
+	}
+	encBuf := bufio.NewWriter(conn)
+	return &ClientCodec{
+		rwc:    conn,
+		dec:    dm.NewDecoder(conn),
+		enc:    em.NewEncoder(encBuf),
+		encBuf: encBuf,
+	}
+}
+
+// WriteRequest writes request header r and body to the connection.
+func (c *ClientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	if err := c.enc.Encode(r); err != nil {
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+// ReadResponseHeader reads response header into r.
+func (c *ClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	return c.dec.Decode(r)
+}
+
+// ReadResponseBody reads response body into body.  If body is nil,
+// response body is read and discarded.
+func (c *ClientCodec) ReadResponseBody(body interface{}) error {
+	if body == nil {
+		return c.dec.Skip()
+	}
+	return c.dec.Decode(body)
+}
+
+// Close closes the underlying connection.
+func (c *ClientCodec) Close() error {
+	return c.rwc.Close()
+}
+
+// ServerCodec implements rpc.ServerCodec using CBOR.  Each request and response
+// is encoded as a CBOR header (rpc.Request or rpc.Response) followed by a CBOR body.
+type ServerCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *cbor.Decoder
+	enc    *cbor.Encoder
+	encBuf *bufio.Writer
+	closed bool
+}
+
+// NewServerCodec returns a new ServerCodec using conn for transport, dm for decoding
+// requests, and em for encoding responses.  If em or dm is nil, default options are used.
+func NewServerCodec(conn io.ReadWriteCloser, em cbor.EncMode, dm cbor.DecMode) *ServerCodec {
+	if em == nil {
+		em = defaultEncMode
+	}
+	if dm == nil {
+		dm = defaultDecMode
+	}
+	encBuf := bufio.NewWriter(conn)
+	return &ServerCodec{
+		rwc:    conn,
+		dec:    dm.NewDecoder(conn),
+		enc:    em.NewEncoder(encBuf),
+		encBuf: encBuf,
+	}
+}
+
+// ReadRequestHeader reads request header into r.
+func (c *ServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	return c.dec.Decode(r)
+}
+
+// ReadRequestBody reads request body into body.  If body is nil,
+// request body is read and discarded.
+func (c *ServerCodec) ReadRequestBody(body interface{}) error {
+	if body == nil {
+		return c.dec.Skip()
+	}
+	return c.dec.Decode(body)
+}
+
+// WriteResponse writes response header r and body to the connection.
+// If response can't be encoded, the connection is closed because the
+// client can't recover from a partially written response.
+func (c *ServerCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	if err := c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+// Close closes the underlying connection.  Closing a closed ServerCodec is no-op.
+func (c *ServerCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
@@ -0,0 +1,132 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package codec
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"reflect"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+type Args struct {
+	A, B int
+}
+
+type Reply struct {
+	C int
+}
+
+type Arith int
+
+func (a *Arith) Add(args *Args, reply *Reply) error {
+	reply.C = args.A + args.B
+	return nil
+}
+
+func (a *Arith) Div(args *Args, reply *Reply) error {
+	if args.B == 0 {
+		return errors.New("divide by zero")
+	}
+	reply.C = args.A / args.B
+	return nil
+}
+
+func (a *Arith) Echo(args map[string]interface{}, reply *map[string]interface{}) error {
+	*reply = args
+	return nil
+}
+
+func newTestClient(t *testing.T, em cbor.EncMode, dm cbor.DecMode) *rpc.Client {
+	server := rpc.NewServer()
+	if err := server.Register(new(Arith)); err != nil {
+		t.Fatalf("Register() returned error %v", err)
+	}
+
+	cli, srv := net.Pipe()
+	go server.ServeCodec(NewServerCodec(srv, em, dm))
+
+	client := rpc.NewClientWithCodec(NewClientCodec(cli, em, dm))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRPC(t *testing.T) {
+	client := newTestClient(t, nil, nil)
+
+	var reply Reply
+	if err := client.Call("Arith.Add", &Args{7, 8}, &reply); err != nil {
+		t.Fatalf("Call(Arith.Add) returned error %v", err)
+	}
+	if reply.C != 15 {
+		t.Errorf("Call(Arith.Add) returned %d, want 15", reply.C)
+	}
+
+	// Error returned by service method
+	wantErrorMsg := "divide by zero"
+	if err := client.Call("Arith.Div", &Args{7, 0}, &reply); err == nil {
+		t.Errorf("Call(Arith.Div) didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Call(Arith.Div) returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+
+	// Unknown method
+	wantErrorMsg = "rpc: can't find method Arith.Mul"
+	if err := client.Call("Arith.Mul", &Args{7, 8}, &reply); err == nil {
+		t.Errorf("Call(Arith.Mul) didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Call(Arith.Mul) returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+
+	// Connection is still usable after errors.
+	if err := client.Call("Arith.Div", &Args{8, 2}, &reply); err != nil {
+		t.Fatalf("Call(Arith.Div) returned error %v", err)
+	}
+	if reply.C != 4 {
+		t.Errorf("Call(Arith.Div) returned %d, want 4", reply.C)
+	}
+}
+
+func TestRPCConcurrentCalls(t *testing.T) {
+	client := newTestClient(t, nil, nil)
+
+	const n = 20
+	calls := make([]*rpc.Call, n)
+	for i := 0; i < n; i++ {
+		calls[i] = client.Go("Arith.Add", &Args{i, i}, new(Reply), nil)
+	}
+	for i, call := range calls {
+		<-call.Done
+		if call.Error != nil {
+			t.Fatalf("Go(Arith.Add) returned error %v", call.Error)
+		}
+		if c := call.Reply.(*Reply).C; c != 2*i {
+			t.Errorf("Go(Arith.Add) returned %d, want %d", c, 2*i)
+		}
+	}
+}
+
+func TestRPCWithModes(t *testing.T) {
+	em, err := cbor.EncOptions{Sort: cbor.SortCanonical}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	dm, err := cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+	client := newTestClient(t, em, dm)
+
+	args := map[string]interface{}{"a": uint64(1), "b": map[string]interface{}{"c": "d"}}
+	var reply map[string]interface{}
+	if err := client.Call("Arith.Echo", args, &reply); err != nil {
+		t.Fatalf("Call(Arith.Echo) returned error %v", err)
+	}
+	if !reflect.DeepEqual(reply, args) {
+		t.Errorf("Call(Arith.Echo) returned %v, want %v", reply, args)
+	}
+}
@@ -109,9 +109,18 @@ const (
 	tagNumEpochTime                      = 1
 	tagNumUnsignedBignum                 = 2
 	tagNumNegativeBignum                 = 3
+	tagNumDecimalFraction                = 4
+	tagNumBigFloat                       = 5
 	tagNumExpectedLaterEncodingBase64URL = 21
 	tagNumExpectedLaterEncodingBase64    = 22
 	tagNumExpectedLaterEncodingBase16    = 23
+	tagNumEncodedCBORDataItem            = 24
+	tagNumRational                       = 30
+	tagNumCWT                            = 61
+	tagNumDays                           = 100
+	tagNumExtendedTime                   = 1001
+	tagNumDuration                       = 1002
+	tagNumFullDate                       = 1004
 	tagNumSelfDescribedCBOR              = 55799
 )
 
@@ -0,0 +1,166 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"errors"
+	"reflect"
+	"time"
+)
+
+var (
+	typeNumericDate = reflect.TypeOf(NumericDate{})
+	typeCWT         = reflect.TypeOf(CWT{})
+)
+
+// CWTClaims represents the claims set of CBOR Web Token (CWT) defined in RFC 8392,
+// with registered claims encoded as integer map keys.  Claims not listed here are
+// kept in Other, so claims sets can be decoded and encoded again without losing them.
+type CWTClaims struct {
+	Issuer     string                     `cbor:"1,keyasint,omitempty"` // iss
+	Subject    string                     `cbor:"2,keyasint,omitempty"` // sub
+	Audience   string                     `cbor:"3,keyasint,omitempty"` // aud
+	Expiration *NumericDate               `cbor:"4,keyasint,omitempty"` // exp
+	NotBefore  *NumericDate               `cbor:"5,keyasint,omitempty"` // nbf
+	IssuedAt   *NumericDate               `cbor:"6,keyasint,omitempty"` // iat
+	CWTID      []byte                     `cbor:"7,keyasint,omitempty"` // cti
+	Other      map[interface{}]RawMessage `cbor:",unknown"`
+}
+
+// NumericDate represents NumericDate defined in RFC 8392 Section 2: number of seconds
+// since 1970-01-01T00:00:00Z UTC, encoded as CBOR integer or floating-point number
+// without tag 1.  EncOptions.NumericDate specifies how to encode NumericDate.
+//
+// Zero NumericDate is encoded as CBOR null, and decoding CBOR null or undefined to
+// NumericDate is no-op, same as time.Time.
+type NumericDate struct {
+	time.Time
+}
+
+// NewNumericDate returns NumericDate with time t, e.g. for claims in CWTClaims.
+func NewNumericDate(t time.Time) *NumericDate {
+	return &NumericDate{Time: t}
+}
+
+// MarshalCBORTo encodes NumericDate as CBOR integer or floating-point number, as
+// specified by EncOptions.NumericDate.
+func (nd NumericDate) MarshalCBORTo(s *EncoderState) error {
+	t := nd.Time
+	if t.IsZero() {
+		s.WriteNil()
+		return nil
+	}
+	if s.em.numericDate == NumericDateInteger {
+		s.WriteInt(t.Unix())
+		return nil
+	}
+	t = t.UTC().Round(s.em.timePrecision.duration())
+	secs, nsecs := t.Unix(), t.Nanosecond()
+	if nsecs == 0 {
+		s.WriteInt(secs)
+		return nil
+	}
+	return s.WriteFloat64(float64(secs) + float64(nsecs)/1e9)
+}
+
+// UnmarshalCBOR decodes CBOR integer or floating-point number to NumericDate.
+// Tagged values (e.g. tag 1) are rejected because RFC 8392 requires the tag to be omitted.
+func (nd *NumericDate) UnmarshalCBOR(data []byte) error {
+	if nd == nil {
+		return errors.New("cbor.NumericDate: UnmarshalCBOR on nil pointer")
+	}
+
+	// Decoding CBOR null and CBOR undefined to NumericDate is no-op.
+	if len(data) == 1 && (data[0] == 0xf6 || data[0] == 0xf7) {
+		return nil
+	}
+
+	d := decoder{data: data, dm: defaultDecMode}
+
+	switch t := d.nextCBORType(); t {
+	case cborTypePositiveInt, cborTypeNegativeInt:
+		secs, err := d.parseInt64(typeNumericDate)
+		if err != nil {
+			return err
+		}
+		nd.Time = time.Unix(secs, 0)
+		return nil
+
+	case cborTypePrimitives:
+		f, ok := d.parseFloat()
+		if !ok {
+			return &UnmarshalTypeError{CBORType: t.String(), GoType: typeNumericDate.String()}
+		}
+		secs, nsecs, ok := floatToSeconds(f)
+		if !ok {
+			return &UnmarshalTypeError{
+				CBORType: t.String(),
+				GoType:   typeNumericDate.String(),
+				errorMsg: "floating-point number isn't a valid NumericDate",
+			}
+		}
+		nd.Time = time.Unix(secs, nsecs)
+		return nil
+
+	default:
+		return &UnmarshalTypeError{CBORType: t.String(), GoType: typeNumericDate.String()}
+	}
+}
+
+// CWT represents CBOR tag 61 (CBOR Web Token) defined in RFC 8392 Section 6.  Message
+// is the enclosed COSE message (e.g. COSE_Sign1 with tag 18), whose payload contains
+// encoded CWTClaims.
+type CWT struct {
+	Message RawMessage
+}
+
+// MarshalCBOR encodes CWT as CBOR tag 61 enclosing Message.
+func (c CWT) MarshalCBOR() ([]byte, error) {
+	if len(c.Message) == 0 {
+		return nil, errors.New("cbor.CWT: MarshalCBOR with empty COSE message")
+	}
+
+	e := getEncodeBuffer()
+	defer putEncodeBuffer(e)
+
+	encodeHead(e, byte(cborTypeTag), tagNumCWT)
+
+	buf := make([]byte, e.Len()+len(c.Message))
+	n := copy(buf, e.Bytes())
+	copy(buf[n:], c.Message)
+	return buf, nil
+}
+
+// UnmarshalCBOR decodes CBOR tag 61 to CWT.  Since tag 61 is optional (RFC 8392
+// Section 6), a COSE message without tag 61 is also decoded to CWT.
+// Decoding CBOR null and CBOR undefined to CWT is no-op.
+func (c *CWT) UnmarshalCBOR(data []byte) error {
+	if c == nil {
+		return errors.New("cbor.CWT: UnmarshalCBOR on nil pointer")
+	}
+
+	// Decoding CBOR null and CBOR undefined to CWT is no-op.
+	if len(data) == 1 && (data[0] == 0xf6 || data[0] == 0xf7) {
+		return nil
+	}
+
+	d := decoder{data: data, dm: defaultDecMode}
+	if d.nextCBORType() == cborTypeTag {
+		off := d.off
+		if _, _, tagNum := d.getHead(); tagNum != tagNumCWT {
+			d.off = off
+		}
+	}
+
+	if t := d.nextCBORType(); t != cborTypeArray && t != cborTypeTag {
+		return &UnmarshalTypeError{
+			CBORType: t.String(),
+			GoType:   typeCWT.String(),
+			errorMsg: "COSE message must be an array or tag",
+		}
+	}
+
+	c.Message = append(c.Message[:0], d.data[d.off:]...)
+	return nil
+}
@@ -0,0 +1,209 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCWTClaims(t *testing.T) {
+	// Example claims set from RFC 8392 Appendix A.1, and with additional claim 8 (cnf).
+	testCases := []struct {
+		name string
+		data []byte
+		want CWTClaims
+	}{
+		{
+			name: "registered claims",
+			data: hexDecode("a70175636f61703a2f2f61732e6578616d706c652e636f6d02656572696b77037818636f61703a2f2f6c696768742e6578616d706c652e636f6d041a5612aeb0051a5610d9f0061a5610d9f007420b71"),
+			want: CWTClaims{
+				Issuer:     "coap://as.example.com",
+				Subject:    "erikw",
+				Audience:   "coap://light.example.com",
+				Expiration: NewNumericDate(time.Unix(1444064944, 0)),
+				NotBefore:  NewNumericDate(time.Unix(1443944944, 0)),
+				IssuedAt:   NewNumericDate(time.Unix(1443944944, 0)),
+				CWTID:      []byte{0x0b, 0x71},
+			},
+		},
+		{
+			name: "other claims",
+			data: hexDecode("a202656572696b7708a1010a"),
+			want: CWTClaims{
+				Subject: "erikw",
+				Other:   map[interface{}]RawMessage{int64(8): hexDecode("a1010a")},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var claims CWTClaims
+			if err := Unmarshal(tc.data, &claims); err != nil {
+				t.Fatalf("Unmarshal() returned error %v", err)
+			}
+			if !reflect.DeepEqual(claims, tc.want) {
+				t.Errorf("Unmarshal() = %+v, want %+v", claims, tc.want)
+			}
+
+			b, err := Marshal(claims)
+			if err != nil {
+				t.Fatalf("Marshal() returned error %v", err)
+			}
+			if !bytes.Equal(b, tc.data) {
+				t.Errorf("Marshal() = 0x%x, want 0x%x", b, tc.data)
+			}
+		})
+	}
+}
+
+func TestNumericDate(t *testing.T) {
+	emDynamic, err := EncOptions{NumericDate: NumericDateDynamic}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		nd          NumericDate
+		wantInteger []byte
+		wantDynamic []byte
+	}{
+		{
+			name:        "whole seconds",
+			nd:          NumericDate{time.Unix(1443944944, 0)},
+			wantInteger: hexDecode("1a5610d9f0"),
+			wantDynamic: hexDecode("1a5610d9f0"),
+		},
+		{
+			name:        "fractional seconds",
+			nd:          NumericDate{time.Unix(1443944944, 500000000)},
+			wantInteger: hexDecode("1a5610d9f0"),
+			wantDynamic: hexDecode("fb41d584367c200000"),
+		},
+		{
+			name:        "before epoch",
+			nd:          NumericDate{time.Unix(-1, 0)},
+			wantInteger: hexDecode("20"),
+			wantDynamic: hexDecode("20"),
+		},
+		{
+			name:        "zero",
+			wantInteger: hexDecode("f6"),
+			wantDynamic: hexDecode("f6"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := Marshal(tc.nd)
+			if err != nil {
+				t.Fatalf("Marshal() returned error %v", err)
+			}
+			if !bytes.Equal(b, tc.wantInteger) {
+				t.Errorf("Marshal() = 0x%x, want 0x%x", b, tc.wantInteger)
+			}
+
+			b, err = emDynamic.Marshal(tc.nd)
+			if err != nil {
+				t.Fatalf("Marshal() returned error %v", err)
+			}
+			if !bytes.Equal(b, tc.wantDynamic) {
+				t.Errorf("Marshal() = 0x%x, want 0x%x", b, tc.wantDynamic)
+			}
+
+			var nd NumericDate
+			if err := Unmarshal(b, &nd); err != nil {
+				t.Fatalf("Unmarshal() returned error %v", err)
+			}
+			if !nd.Equal(tc.nd.Time) {
+				t.Errorf("Unmarshal() = %v, want %v", nd, tc.nd)
+			}
+		})
+	}
+}
+
+func TestNumericDateUnmarshalError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "tag 1",
+			data:         hexDecode("c11a5610d9f0"),
+			wantErrorMsg: "cbor: cannot unmarshal tag into Go value of type cbor.NumericDate",
+		},
+		{
+			name:         "text string",
+			data:         hexDecode("60"),
+			wantErrorMsg: "cbor: cannot unmarshal UTF-8 text string into Go value of type cbor.NumericDate",
+		},
+		{
+			name:         "integer overflow",
+			data:         hexDecode("1bffffffffffffffff"),
+			wantErrorMsg: "cbor: cannot unmarshal positive integer into Go value of type cbor.NumericDate (18446744073709551615 overflows Go's int64)",
+		},
+		{
+			name:         "NaN",
+			data:         hexDecode("f97e00"),
+			wantErrorMsg: "cbor: cannot unmarshal primitives into Go value of type cbor.NumericDate (floating-point number isn't a valid NumericDate)",
+		},
+		{
+			name:         "boolean",
+			data:         hexDecode("f5"),
+			wantErrorMsg: "cbor: cannot unmarshal primitives into Go value of type cbor.NumericDate",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var nd NumericDate
+			if err := Unmarshal(tc.data, &nd); err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error", tc.data)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestCWT(t *testing.T) {
+	// COSE_Sign1 message 18([h'', {}, h'a0', h''])
+	msg := hexDecode("d28440a041a040")
+	tagged := append(hexDecode("d83d"), msg...)
+
+	b, err := Marshal(CWT{Message: msg})
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+	if !bytes.Equal(b, tagged) {
+		t.Errorf("Marshal() = 0x%x, want 0x%x", b, tagged)
+	}
+
+	for _, data := range [][]byte{tagged, msg} {
+		var c CWT
+		if err := Unmarshal(data, &c); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+		}
+		if !bytes.Equal(c.Message, msg) {
+			t.Errorf("Unmarshal(0x%x) = 0x%x, want 0x%x", data, c.Message, msg)
+		}
+	}
+
+	wantErrorMsg := "cbor.CWT: MarshalCBOR with empty COSE message"
+	if _, err := Marshal(CWT{}); err == nil {
+		t.Errorf("Marshal() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Marshal() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+
+	wantErrorMsg = "cbor: cannot unmarshal positive integer into Go value of type cbor.CWT (COSE message must be an array or tag)"
+	var c CWT
+	if err := Unmarshal(hexDecode("d83d01"), &c); err == nil {
+		t.Errorf("Unmarshal() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const (
+	secondsPerDay = 24 * 60 * 60
+
+	// maxDays is the max absolute value of tag 100 content, so seconds since epoch
+	// don't overflow int64.
+	maxDays = math.MaxInt64 / secondsPerDay
+
+	fullDateLayout = "2006-01-02"
+)
+
+var typeDate = reflect.TypeOf(Date{})
+
+// Date represents a calendar date without time zone, encoded as tag 100 (number of days
+// since 1970-01-01) or tag 1004 ("YYYY-MM-DD" text string) defined in RFC 8943.
+// EncOptions.DateTag specifies which tag is used for encoding, and both tags are
+// decoded to Date.  Tags 100 and 1004 can also be decoded to time.Time, which is set
+// to midnight UTC of the date.
+//
+// Zero Date is encoded as CBOR null, and decoding CBOR null or undefined to Date is
+// no-op, same as time.Time.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateOf returns the date of t in t's location.
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// In returns the time of midnight at the beginning of date d in loc.
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// IsZero returns true if d is zero Date.
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+// String returns d in "YYYY-MM-DD" format.
+func (d Date) String() string {
+	return d.In(time.UTC).Format(fullDateLayout)
+}
+
+// days returns number of days since 1970-01-01.
+func (d Date) days() int64 {
+	secs := d.In(time.UTC).Unix()
+	days := secs / secondsPerDay
+	if secs%secondsPerDay != 0 {
+		days-- // Round toward negative infinity for dates before 1970-01-01.
+	}
+	return days
+}
+
+// MarshalCBORTo encodes Date as tag 1004 or tag 100, as specified by EncOptions.DateTag.
+func (d Date) MarshalCBORTo(s *EncoderState) error {
+	if d.IsZero() {
+		s.WriteNil()
+		return nil
+	}
+	if s.em.dateTag == DateTagDays {
+		if err := s.WriteTagHead(tagNumDays); err != nil {
+			return err
+		}
+		s.WriteInt(d.days())
+		return nil
+	}
+	if d.Year < 0 || d.Year > 9999 {
+		return &UnsupportedValueError{msg: "year " + strconv.Itoa(d.Year) + " of cbor.Date can't be encoded as full-date in tag 1004"}
+	}
+	if err := s.WriteTagHead(tagNumFullDate); err != nil {
+		return err
+	}
+	encodeHead(s.e, byte(cborTypeTextString), uint64(len(fullDateLayout)))
+	s.e.WriteString(d.String())
+	return nil
+}
+
+// UnmarshalCBOR decodes tag 100 or tag 1004 to Date.
+func (d *Date) UnmarshalCBOR(data []byte) error {
+	if d == nil {
+		return errors.New("cbor.Date: UnmarshalCBOR on nil pointer")
+	}
+
+	// Decoding CBOR null and CBOR undefined to Date is no-op.
+	if len(data) == 1 && (data[0] == 0xf6 || data[0] == 0xf7) {
+		return nil
+	}
+
+	dec := decoder{data: data, dm: defaultDecMode}
+
+	t := dec.nextCBORType()
+	if t != cborTypeTag {
+		return &UnmarshalTypeError{CBORType: t.String(), GoType: typeDate.String()}
+	}
+	_, _, tagNum := dec.getHead()
+	if tagNum != tagNumDays && tagNum != tagNumFullDate {
+		return &UnmarshalTypeError{
+			CBORType: t.String(),
+			GoType:   typeDate.String(),
+			errorMsg: "tag number " + strconv.FormatUint(tagNum, 10) + " isn't 100 or 1004",
+		}
+	}
+
+	date, err := dec.parseDate(tagNum, typeDate)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+// parseDate decodes content of tag 100 or tag 1004 to Date.  Tag number is already
+// consumed.
+func (d *decoder) parseDate(tagNum uint64, goType reflect.Type) (Date, error) {
+	t := d.nextCBORType()
+	switch {
+	case tagNum == tagNumDays && (t == cborTypePositiveInt || t == cborTypeNegativeInt):
+		days, err := d.parseInt64(goType)
+		if err != nil {
+			return Date{}, err
+		}
+		if days > maxDays || days < -maxDays {
+			return Date{}, &UnmarshalTypeError{
+				CBORType: t.String(),
+				GoType:   goType.String(),
+				errorMsg: strconv.FormatInt(days, 10) + " days overflows Go's time.Time",
+			}
+		}
+		return DateOf(time.Unix(days*secondsPerDay, 0).UTC()), nil
+
+	case tagNum == tagNumFullDate && t == cborTypeTextString:
+		s, err := d.parseTextString()
+		if err != nil {
+			return Date{}, err
+		}
+		tm, err := time.Parse(fullDateLayout, string(s))
+		if err != nil {
+			return Date{}, errors.New("cbor: cannot set " + string(s) + " for " + goType.String() + ": " + err.Error())
+		}
+		return DateOf(tm), nil
+
+	default:
+		d.skip()
+		contentType := "integer"
+		if tagNum == tagNumFullDate {
+			contentType = "text string"
+		}
+		return Date{}, newInadmissibleTagContentTypeError(int(tagNum), contentType, t.String())
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDate(t *testing.T) {
+	emDays, err := EncOptions{DateTag: DateTagDays}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	// Examples from RFC 8943 Section 4 and dates before 1970-01-01.
+	testCases := []struct {
+		name       string
+		date       Date
+		wantString []byte
+		wantDays   []byte
+	}{
+		{
+			name:       "1940-10-09",
+			date:       Date{Year: 1940, Month: time.October, Day: 9},
+			wantString: hexDecode("d903ec6a313934302d31302d3039"),
+			wantDays:   hexDecode("d8643929b3"), // -10676
+		},
+		{
+			name:       "1980-12-08",
+			date:       Date{Year: 1980, Month: time.December, Day: 8},
+			wantString: hexDecode("d903ec6a313938302d31322d3038"),
+			wantDays:   hexDecode("d864190f9a"), // 3994
+		},
+		{
+			name:       "1970-01-01",
+			date:       Date{Year: 1970, Month: time.January, Day: 1},
+			wantString: hexDecode("d903ec6a313937302d30312d3031"),
+			wantDays:   hexDecode("d86400"),
+		},
+		{
+			name:       "1969-12-31",
+			date:       Date{Year: 1969, Month: time.December, Day: 31},
+			wantString: hexDecode("d903ec6a313936392d31322d3331"),
+			wantDays:   hexDecode("d86420"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := Marshal(tc.date)
+			if err != nil {
+				t.Fatalf("Marshal() returned error %v", err)
+			}
+			if !bytes.Equal(b, tc.wantString) {
+				t.Errorf("Marshal() = 0x%x, want 0x%x", b, tc.wantString)
+			}
+
+			b, err = emDays.Marshal(tc.date)
+			if err != nil {
+				t.Fatalf("Marshal() returned error %v", err)
+			}
+			if !bytes.Equal(b, tc.wantDays) {
+				t.Errorf("Marshal() = 0x%x, want 0x%x", b, tc.wantDays)
+			}
+
+			for _, data := range [][]byte{tc.wantString, tc.wantDays} {
+				var date Date
+				if err := Unmarshal(data, &date); err != nil {
+					t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+				}
+				if date != tc.date {
+					t.Errorf("Unmarshal(0x%x) = %v, want %v", data, date, tc.date)
+				}
+
+				var tm time.Time
+				if err := Unmarshal(data, &tm); err != nil {
+					t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+				}
+				if want := tc.date.In(time.UTC); !tm.Equal(want) {
+					t.Errorf("Unmarshal(0x%x) = %v, want %v", data, tm, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDateZero(t *testing.T) {
+	b, err := Marshal(Date{})
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+	if !bytes.Equal(b, cborNil) {
+		t.Errorf("Marshal() = 0x%x, want 0xf6", b)
+	}
+
+	date := Date{Year: 2000, Month: time.January, Day: 1}
+	if err := Unmarshal(cborNil, &date); err != nil {
+		t.Fatalf("Unmarshal() returned error %v", err)
+	}
+	if want := (Date{Year: 2000, Month: time.January, Day: 1}); date != want {
+		t.Errorf("Unmarshal() = %v, want %v", date, want)
+	}
+
+	if d := DateOf(time.Date(2000, time.February, 3, 23, 0, 0, 0, time.UTC)); d.String() != "2000-02-03" {
+		t.Errorf("DateOf() = %v, want 2000-02-03", d)
+	}
+}
+
+func TestDateError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "untagged",
+			data:         hexDecode("00"),
+			wantErrorMsg: "cbor: cannot unmarshal positive integer into Go value of type cbor.Date",
+		},
+		{
+			name:         "wrong tag number",
+			data:         hexDecode("c100"),
+			wantErrorMsg: "cbor: cannot unmarshal tag into Go value of type cbor.Date (tag number 1 isn't 100 or 1004)",
+		},
+		{
+			name:         "tag 100 with text string",
+			data:         hexDecode("d8646a313938302d31322d3038"),
+			wantErrorMsg: "cbor: tag number 100 must be followed by integer, got UTF-8 text string",
+		},
+		{
+			name:         "tag 1004 with integer",
+			data:         hexDecode("d903ec00"),
+			wantErrorMsg: "cbor: tag number 1004 must be followed by text string, got positive integer",
+		},
+		{
+			name:         "tag 1004 with invalid date",
+			data:         hexDecode("d903ec6a313938302d31332d3038"),
+			wantErrorMsg: "cbor: cannot set 1980-13-08 for cbor.Date: parsing time \"1980-13-08\": month out of range",
+		},
+		{
+			name:         "tag 100 overflow",
+			data:         hexDecode("d8641b7fffffffffffffff"),
+			wantErrorMsg: "cbor: cannot unmarshal positive integer into Go value of type cbor.Date (9223372036854775807 days overflows Go's time.Time)",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var date Date
+			if err := Unmarshal(tc.data, &date); err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error", tc.data)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+
+	wantErrorMsg := "cbor: unsupported value: year 10000 of cbor.Date can't be encoded as full-date in tag 1004"
+	if _, err := Marshal(Date{Year: 10000, Month: time.January, Day: 1}); err == nil {
+		t.Errorf("Marshal() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Marshal() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+
+	em, err := EncOptions{TagsMd: TagsForbidden}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	wantErrorMsg = "cbor: cannot encode tag number 1004 when TagsMd is TagsForbidden"
+	if _, err := em.Marshal(Date{Year: 2000, Month: time.January, Day: 1}); err == nil {
+		t.Errorf("Marshal() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Marshal() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
@@ -50,7 +51,7 @@ import (
 //	CBOR text strings decode to string.
 //	CBOR arrays decode to []interface{}.
 //	CBOR maps decode to map[interface{}]interface{}.
-//	CBOR null and undefined values decode to nil.
+//	CBOR null and undefined values decode to nil (see DecOptions.SimpleValueToAny).
 //	CBOR times (tag 0 and 1) decode to time.Time.
 //	CBOR bignums (tag 2 and 3) decode to big.Int.
 //	CBOR tags with an unrecognized number decode to cbor.Tag
@@ -83,8 +84,29 @@ import (
 //  3. struct field name.
 //
 // Unmarshal tries an exact match for field name, then a case-insensitive match.
-// Map key-value pairs without corresponding struct fields are ignored.  See
-// DecOptions.ExtraReturnErrors to return error at unknown field.
+// See DecOptions.FieldNameMatching to only allow exact matches.  Map key-value pairs
+// without corresponding struct fields are ignored.  See DecOptions.ExtraReturnErrors
+// to return error at unknown field.
+//
+// Struct field with "unknown" option (map[string]RawMessage or map[interface{}]RawMessage)
+// receives map key-value pairs without corresponding struct fields instead, with values
+// kept as encoded CBOR data items, so they can be encoded again by Marshal.  Text string
+// keys are stored as string, byte string keys (see DecOptions.FieldNameByteString) as
+// ByteString, and integer keys as int64 (or Integer if out of int64 range).  If map key
+// type is string, byte string keys are stored as string and integer keys are ignored.
+// Unknown field error isn't returned for captured map entries.
+//
+// Struct field with "inline" option is decoded from the parent CBOR map: exported fields
+// of a struct (or pointer to struct) field are matched as if they were fields of the
+// parent struct, same as anonymous struct fields, and a map field (with string or
+// interface{} keys and any value type) receives map key-value pairs without corresponding
+// struct fields, with values decoded to the map value type, same as "unknown" option.
+//
+// Struct field with "orig" option (RawMessage) receives the entire encoded
+// CBOR map (or CBOR array for struct with "toarray" option) decoded to the struct, e.g.
+// to verify signatures or keep audit trails after processing decoded fields.  It isn't
+// set if decoding the map stops early because of DupMapKeyError or UnknownFieldError.
+// Field with "orig" option is ignored by Marshal.
 //
 // To unmarshal a CBOR text string into a time.Time value, Unmarshal parses text
 // string formatted in RFC3339.  To unmarshal a CBOR integer/float into a
@@ -92,18 +114,24 @@ import (
 // and fractional seconds since January 1, 1970 UTC. As a special case, Infinite
 // and NaN float values decode to time.Time's zero value.
 //
+// To unmarshal a CBOR integer, bignum, or float into a json.Number value, Unmarshal
+// stores the number in decimal (float16 and float32 with the shortest representation
+// of float32).  Infinite and NaN float values can't be decoded to json.Number.
+//
 // To unmarshal CBOR null (0xf6) and undefined (0xf7) values into a
 // slice/map/pointer, Unmarshal sets Go value to nil.  Because null is often
 // used to mean "not present", unmarshalling CBOR null and undefined value
 // into any other Go type has no effect and returns no error.
 //
 // Unmarshal supports CBOR tag 55799 (self-describe CBOR), tag 0 and 1 (time),
-// and tag 2 and 3 (bignum).
+// and tag 2 and 3 (bignum).  Tag 100 and 1004 (date) can be decoded to Date and
+// time.Time.
 //
 // Unmarshal returns ExtraneousDataError error (without decoding into v)
 // if there are any remaining bytes following the first valid CBOR data item.
 // See UnmarshalFirst, if you want to unmarshal only the first
-// CBOR data item without ExtraneousDataError caused by remaining bytes.
+// CBOR data item without ExtraneousDataError caused by remaining bytes,
+// and DecOptions.TrailingBytes to ignore remaining bytes.
 func Unmarshal(data []byte, v interface{}) error {
 	return defaultDecMode.Unmarshal(data, v)
 }
@@ -151,6 +179,17 @@ type Unmarshaler interface {
 	UnmarshalCBOR([]byte) error
 }
 
+// UnmarshalerWithMode is the interface implemented by types that wish to unmarshal
+// CBOR data themselves using the decoding mode of the caller.  This allows custom
+// types to honor the caller's decoding options (e.g. DupMapKey, registered tags)
+// when unmarshaling their contents.  The input is a valid CBOR value.
+// UnmarshalCBORWithMode must copy the CBOR data if it needs to use it after returning.
+//
+// If a type implements both UnmarshalerWithMode and Unmarshaler, UnmarshalerWithMode is used.
+type UnmarshalerWithMode interface {
+	UnmarshalCBORWithMode(data []byte, dm DecMode) error
+}
+
 // InvalidUnmarshalError describes an invalid argument passed to Unmarshal.
 type InvalidUnmarshalError struct {
 	s string
@@ -166,6 +205,7 @@ type UnmarshalTypeError struct {
 	GoType          string // type of Go value it could not be decoded into
 	StructFieldName string // name of the struct field holding the Go value (optional)
 	errorMsg        string // additional error message (optional)
+	ErrorLocation          // location of CBOR value (see ErrorLocation)
 }
 
 func (e *UnmarshalTypeError) Error() string {
@@ -181,6 +221,9 @@ func (e *UnmarshalTypeError) Error() string {
 	return s
 }
 
+// Is returns true if target is ErrTypeMismatch.
+func (e *UnmarshalTypeError) Is(target error) bool { return target == ErrTypeMismatch }
+
 // InvalidMapKeyTypeError describes invalid Go map key type when decoding CBOR map.
 // For example, Go doesn't allow slice as map key.
 type InvalidMapKeyTypeError struct {
@@ -201,6 +244,9 @@ func (e *DupMapKeyError) Error() string {
 	return fmt.Sprintf("cbor: found duplicate map key \"%v\" at map element index %d", e.Key, e.Index)
 }
 
+// Is returns true if target is ErrDuplicateMapKey.
+func (e *DupMapKeyError) Is(target error) bool { return target == ErrDuplicateMapKey }
+
 // UnknownFieldError describes detected unknown field in CBOR map when decoding to Go struct.
 type UnknownFieldError struct {
 	Index int
@@ -210,6 +256,9 @@ func (e *UnknownFieldError) Error() string {
 	return fmt.Sprintf("cbor: found unknown field at map element index %d", e.Index)
 }
 
+// Is returns true if target is ErrUnknownField.
+func (e *UnknownFieldError) Is(target error) bool { return target == ErrUnknownField }
+
 // UnacceptableDataItemError is returned when unmarshaling a CBOR input that contains a data item
 // that is not acceptable to a specific CBOR-based application protocol ("invalid or unexpected" as
 // described in RFC 8949 Section 5 Paragraph 3).
@@ -254,6 +303,17 @@ func (e *ByteStringExpectedFormatError) Unwrap() error {
 	return e.err
 }
 
+// ArrayLengthError is returned when decoding CBOR array to Go array of different length
+// with ArrayLengthExact decoding option.
+type ArrayLengthError struct {
+	GoType     string // type of Go array
+	CBORLength int    // number of CBOR array elements
+}
+
+func (e *ArrayLengthError) Error() string {
+	return "cbor: cannot unmarshal CBOR array of " + strconv.Itoa(e.CBORLength) + " elements into Go array of type " + e.GoType
+}
+
 // InadmissibleTagContentTypeError is returned when unmarshaling built-in CBOR tags
 // fails because of inadmissible type for tag content. Currently, the built-in
 // CBOR tags in this codec are tags 0-3 and 21-23.
@@ -299,6 +359,9 @@ func (e *InadmissibleTagContentTypeError) Error() string {
 //     equal (==) values for both keys.
 //  2. When decoding into a map, both keys are equal (==) when decoded into values of the
 //     destination map's key type.
+//
+// Map keys that can't be Go map keys (e.g. arrays and maps) are considered duplicates if
+// their CBOR encodings are identical.
 type DupMapKeyMode int
 
 const (
@@ -314,6 +377,16 @@ const (
 	// WARNING: using DupMapKeyEnforcedAPF will decrease performance and increase memory use.
 	DupMapKeyEnforcedAPF
 
+	// DupMapKeyReject rejects CBOR data items containing duplicate map keys without
+	// decoding any of the data item, so the destination isn't partially filled.  Map
+	// keys are checked before decoding by comparing values decoded to interface{} with
+	// default decoding options (e.g. 1 and 1.0 are different keys, and byte strings
+	// and text strings with the same content are different keys).  Keys that are only
+	// duplicates in the destination type (e.g. two keys matching the same struct field)
+	// are also rejected while decoding, as with DupMapKeyEnforcedAPF.
+	// WARNING: using DupMapKeyReject will decrease performance and increase memory use.
+	DupMapKeyReject
+
 	maxDupMapKeyMode
 )
 
@@ -338,6 +411,30 @@ func (m IndefLengthMode) valid() bool {
 	return m >= 0 && m < maxIndefLengthMode
 }
 
+// ChunkedStringMode specifies whether to allow, forbid, or require indefinite-length
+// (chunked) byte strings and text strings when decoding.
+type ChunkedStringMode int
+
+const (
+	// ChunkedStringAllowed allows both definite-length and indefinite-length strings.
+	ChunkedStringAllowed ChunkedStringMode = iota
+
+	// ChunkedStringForbidden disallows indefinite-length strings, even if
+	// IndefLength is IndefLengthAllowed.
+	ChunkedStringForbidden
+
+	// ChunkedStringRequired disallows definite-length strings, including chunks of
+	// indefinite-length strings, that are longer than MaxStringChunkSize bytes.
+	// Longer strings must be encoded in chunks of at most MaxStringChunkSize bytes.
+	ChunkedStringRequired
+
+	maxChunkedStringMode
+)
+
+func (m ChunkedStringMode) valid() bool {
+	return m >= 0 && m < maxChunkedStringMode
+}
+
 // TagsMode specifies whether to allow CBOR tags.
 type TagsMode int
 
@@ -355,6 +452,25 @@ func (tm TagsMode) valid() bool {
 	return tm >= 0 && tm < maxTagsMode
 }
 
+// FloatMode specifies whether to allow floating-point numbers (major type 7, additional
+// information 25 through 27).
+type FloatMode int
+
+const (
+	// FloatAllowed allows floating-point numbers.
+	FloatAllowed FloatMode = iota
+
+	// FloatForbidden disallows floating-point numbers.  Decoding returns an
+	// UnacceptableDataItemError, and encoding returns an UnsupportedValueError.
+	FloatForbidden
+
+	maxFloatMode
+)
+
+func (fm FloatMode) valid() bool {
+	return fm >= 0 && fm < maxFloatMode
+}
+
 // IntDecMode specifies which Go type (int64, uint64, or big.Int) should
 // be used when decoding CBOR integers (major type 0 and 1) to Go interface{}.
 type IntDecMode int
@@ -389,6 +505,27 @@ const (
 	// - big.Int or *big.Int (see BigIntDecMode) if value doesn't fit into int64
 	IntDecConvertSignedOrBigInt
 
+	// IntDecConvertInteger affects how CBOR integers (major type 0 and 1) decode to Go interface{}.
+	// It makes CBOR integers (major type 0 and 1) decode to:
+	// - cbor.Integer, which preserves major type and full range of CBOR integers
+	IntDecConvertInteger
+
+	// IntDecConvertSignedOrFloat64 affects how CBOR integers (major type 0 and 1) decode to Go interface{}.
+	// It makes CBOR integers (major type 0 and 1) decode to:
+	// - int64 if value fits
+	// - float64 if value doesn't fit into int64, which can lose precision
+	// This is compatible with how encoding/json decodes JSON numbers with UseNumber disabled.
+	IntDecConvertSignedOrFloat64
+
+	// IntDecConvertSignedOrUint64 affects how CBOR integers (major type 0 and 1) decode to Go interface{}.
+	// It makes CBOR integers (major type 0 and 1) decode to:
+	// - int64 if value fits
+	// - uint64 if value > math.MaxInt64
+	// - big.Int or *big.Int (see BigIntDecMode) if value < math.MinInt64
+	// Unlike IntDecConvertSignedOrBigInt, big.Int isn't allocated for unsigned values
+	// from 2^63 to 2^64-1, e.g. large counters mixed with signed values.
+	IntDecConvertSignedOrUint64
+
 	maxIntDec
 )
 
@@ -464,6 +601,29 @@ func (um UTF8Mode) valid() bool {
 	return um >= 0 && um < maxUTF8Mode
 }
 
+// MapKeyUTF8Mode option specifies if decoder should decode CBOR Text containing
+// invalid UTF-8 string in map keys (including CBOR Text nested in map keys).
+type MapKeyUTF8Mode int
+
+const (
+	// MapKeyUTF8SameAsUTF8 uses UTF8 option to validate CBOR Text in map keys.
+	MapKeyUTF8SameAsUTF8 MapKeyUTF8Mode = iota
+
+	// MapKeyUTF8RejectInvalid rejects CBOR Text containing
+	// invalid UTF-8 string in map keys.
+	MapKeyUTF8RejectInvalid
+
+	// MapKeyUTF8DecodeInvalid allows decoding CBOR Text containing
+	// invalid UTF-8 string in map keys.
+	MapKeyUTF8DecodeInvalid
+
+	maxMapKeyUTF8Mode
+)
+
+func (mkum MapKeyUTF8Mode) valid() bool {
+	return mkum >= 0 && mkum < maxMapKeyUTF8Mode
+}
+
 // FieldNameMatchingMode specifies how string keys in CBOR maps are matched to Go struct field names.
 type FieldNameMatchingMode int
 
@@ -527,6 +687,144 @@ func (bstsm ByteStringToStringMode) valid() bool {
 	return bstsm >= 0 && bstsm < maxByteStringToStringMode
 }
 
+// TextStringToByteSliceMode specifies the behavior when decoding a CBOR text string into a Go byte slice.
+type TextStringToByteSliceMode int
+
+const (
+	// TextStringToByteSliceForbidden generates an error on an attempt to decode a CBOR text string into a Go byte slice.
+	TextStringToByteSliceForbidden TextStringToByteSliceMode = iota
+
+	// TextStringToByteSliceAllowed permits decoding a CBOR text string into a Go byte slice.
+	TextStringToByteSliceAllowed
+
+	maxTextStringToByteSliceMode
+)
+
+func (tsbsm TextStringToByteSliceMode) valid() bool {
+	return tsbsm >= 0 && tsbsm < maxTextStringToByteSliceMode
+}
+
+// ZeroCopyMode specifies whether decoded byte slices can share memory with the input data.
+type ZeroCopyMode int
+
+const (
+	// ZeroCopyNone copies decoded bytes, so decoded values never share memory with the input data.
+	ZeroCopyNone ZeroCopyMode = iota
+
+	// ZeroCopyBytes makes decoded Go byte slices, RawMessage values, and struct fields with
+	// "raw" or "orig" option share memory with the input data when CBOR data is a definite-length
+	// byte string (or any data item for RawMessage, "raw", and "orig" fields), instead of copying it.
+	//
+	// Decoded values are only valid as long as the input data isn't modified, so the input
+	// data must be treated as read-only while decoded values are in use.  When decoding with
+	// Decoder, decoded values are only valid until the next call to Decode, Skip, Buffered, or
+	// Reset because Decoder reuses its internal buffer.  Decoded values must not be modified (e.g. by
+	// append) unless they are copied first.  Struct fields with "copy" option are always
+	// copied, which can be used for fields that need to outlive the input data.
+	ZeroCopyBytes
+
+	maxZeroCopyMode
+)
+
+func (zcm ZeroCopyMode) valid() bool {
+	return zcm >= 0 && zcm < maxZeroCopyMode
+}
+
+// ReuseMode specifies whether decoding reuses memory already allocated in the destination value.
+type ReuseMode int
+
+const (
+	// ReuseNone decodes to existing slices (if they have enough capacity) and merges
+	// decoded entries into existing maps.  Struct fields and map entries absent from
+	// CBOR data are left unchanged.
+	ReuseNone ReuseMode = iota
+
+	// ReuseContainers decodes CBOR data as if the destination value were set to its
+	// zero value first, while reusing memory already allocated in it:
+	//   - slices (including byte slices) with enough capacity are resliced,
+	//   - maps are kept, with entries absent from CBOR data deleted, and
+	//     existing map values (e.g. structs with slice fields) decoded in place,
+	//   - values pointed to by non-nil pointers are decoded in place,
+	//   - struct fields absent from CBOR data are reset the same way.
+	//
+	// This allows decoding into pooled values (e.g. from sync.Pool) with few allocations
+	// in steady state.  The destination value must not share memory with values in use
+	// elsewhere, since reused memory is overwritten.
+	ReuseContainers
+
+	maxReuseMode
+)
+
+func (rm ReuseMode) valid() bool {
+	return rm >= 0 && rm < maxReuseMode
+}
+
+// NilValueMode specifies how to decode CBOR null and undefined to Go values that can't
+// be nil (e.g. integers, strings, structs, and arrays).  CBOR null and undefined are
+// always decoded to nil pointers, slices, and maps.  They are decoded to nil empty
+// interface values unless NilValue is NilValueNoOp, which leaves non-nil empty
+// interface values unchanged.
+type NilValueMode int
+
+const (
+	// NilValueNoOp leaves the destination value unchanged.
+	NilValueNoOp NilValueMode = iota
+
+	// NilValueZero sets the destination value to its zero value.
+	NilValueZero
+
+	// NilValueForbidden returns UnmarshalTypeError, so missing values can be
+	// detected instead of being silently ignored.
+	NilValueForbidden
+
+	maxNilValueMode
+)
+
+func (nvm NilValueMode) valid() bool {
+	return nvm >= 0 && nvm < maxNilValueMode
+}
+
+// ArrayLengthMode specifies how to decode CBOR array to Go array of different length.
+type ArrayLengthMode int
+
+const (
+	// ArrayLengthLenient decodes CBOR array to Go array of different length, ignoring
+	// extra CBOR array elements and setting remaining Go array elements to zero values.
+	ArrayLengthLenient ArrayLengthMode = iota
+
+	// ArrayLengthExact returns ArrayLengthError when decoding CBOR array to Go array
+	// of different length.
+	ArrayLengthExact
+
+	maxArrayLengthMode
+)
+
+func (alm ArrayLengthMode) valid() bool {
+	return alm >= 0 && alm < maxArrayLengthMode
+}
+
+// EncodedItemDecMode specifies how to decode CBOR tag 24 (encoded CBOR data item).
+type EncodedItemDecMode int
+
+const (
+	// EncodedItemDecodeAsTag decodes tag 24 like other unregistered tags: to Tag when
+	// decoding to empty interface, and its content (byte string) when decoding to other
+	// Go types.
+	EncodedItemDecodeAsTag EncodedItemDecMode = iota
+
+	// EncodedItemDecodeUnwrap decodes the CBOR data item enclosed in tag 24 as if it
+	// appeared in place of tag 24, including when decoding to empty interface.  Tag 24
+	// is kept when decoding to EncodedItem, Tag, RawTag, RawMessage, and other types
+	// implementing Unmarshaler, or to types registered with a tag number.
+	EncodedItemDecodeUnwrap
+
+	maxEncodedItemDecMode
+)
+
+func (eidm EncodedItemDecMode) valid() bool {
+	return eidm >= 0 && eidm < maxEncodedItemDecMode
+}
+
 // FieldNameByteStringMode specifies the behavior when decoding a CBOR byte string map key as a Go struct field name.
 type FieldNameByteStringMode int
 
@@ -557,6 +855,11 @@ const (
 	// when decoding unrecognized CBOR tag into an empty interface.
 	UnrecognizedTagContentToAny
 
+	// UnrecognizedTagForbidden returns UnacceptableDataItemError when decoding unrecognized
+	// CBOR tag into an empty interface, so that tags unknown to a protocol aren't hidden
+	// in decoded values.
+	UnrecognizedTagForbidden
+
 	maxUnrecognizedTagToAny
 )
 
@@ -588,10 +891,109 @@ func (tttam TimeTagToAnyMode) valid() bool {
 	return tttam >= 0 && tttam < maxTimeTagToAnyMode
 }
 
+// SimpleValueToAnyMode specifies how to decode CBOR simple values false, true, null, and
+// undefined into an empty interface (any).
+type SimpleValueToAnyMode int
+
+const (
+	// SimpleValueToGoValue decodes CBOR false and true to Go bool, and CBOR null and
+	// undefined to nil.
+	SimpleValueToGoValue SimpleValueToAnyMode = iota
+
+	// SimpleValueUndefinedToSimpleValue decodes CBOR undefined to SimpleValue(23), so
+	// that it can be distinguished from CBOR null.  Other simple values are decoded as
+	// with SimpleValueToGoValue.
+	SimpleValueUndefinedToSimpleValue
+
+	// SimpleValueToSimpleValue decodes CBOR false, true, null, and undefined to
+	// SimpleValue(20) through SimpleValue(23), which are encoded back to the same
+	// simple values.
+	SimpleValueToSimpleValue
+
+	maxSimpleValueToAnyMode
+)
+
+func (svtam SimpleValueToAnyMode) valid() bool {
+	return svtam >= 0 && svtam < maxSimpleValueToAnyMode
+}
+
+// SmallValueToAnyMode specifies how to decode small CBOR integers and text strings
+// into an empty interface (any).
+type SmallValueToAnyMode int
+
+const (
+	// SmallValueToAnyAllocate stores decoded integers and text strings in an empty
+	// interface as usual, which allocates memory for most values.
+	SmallValueToAnyAllocate SmallValueToAnyMode = iota
+
+	// SmallValueToAnyCacheIntegers uses preallocated values for decoded integers
+	// between -256 and 1023 (as uint64 or int64, see IntDec), so decoding these
+	// integers into an empty interface doesn't allocate memory.
+	SmallValueToAnyCacheIntegers
+
+	// SmallValueToAnyCacheIntegersAndStrings is like SmallValueToAnyCacheIntegers and
+	// also reuses values of decoded text strings of up to 32 bytes, so a text string
+	// repeated in data decoded by the same function call or Decoder (e.g. map keys in
+	// an array of maps) allocates memory only once.
+	SmallValueToAnyCacheIntegersAndStrings
+
+	maxSmallValueToAnyMode
+)
+
+func (svtam SmallValueToAnyMode) valid() bool {
+	return svtam >= 0 && svtam < maxSmallValueToAnyMode
+}
+
+// TrailingBytesMode specifies how to handle bytes following the CBOR data item passed
+// to Unmarshal and Wellformed.
+type TrailingBytesMode int
+
+const (
+	// TrailingBytesForbidden makes Unmarshal and Wellformed return ExtraneousDataError
+	// (without decoding into v) if any bytes follow the CBOR data item, so data is
+	// accepted only if it contains exactly one CBOR data item.
+	TrailingBytesForbidden TrailingBytesMode = iota
+
+	// TrailingBytesAllowed makes Unmarshal and Wellformed ignore bytes following the
+	// first CBOR data item without checking them.  Use UnmarshalFirst instead to get
+	// the remaining bytes.
+	TrailingBytesAllowed
+
+	maxTrailingBytesMode
+)
+
+func (tbm TrailingBytesMode) valid() bool {
+	return tbm >= 0 && tbm < maxTrailingBytesMode
+}
+
+// Limits of integers and text strings cached with SmallValueToAny.
+const (
+	minCachedInt       = -256
+	maxCachedInt       = 1023
+	maxCachedStringLen = 32
+	maxCachedStrings   = 1024
+)
+
+var (
+	cachedIntsOnce sync.Once
+	cachedUints    [maxCachedInt + 1]interface{}
+	cachedInts     [maxCachedInt - minCachedInt + 1]interface{}
+)
+
+func initCachedInts() {
+	for i := range cachedUints {
+		cachedUints[i] = uint64(i)
+	}
+	for i := range cachedInts {
+		cachedInts[i] = int64(i + minCachedInt)
+	}
+}
+
 // SimpleValueRegistry is a registry of unmarshaling behaviors for each possible CBOR simple value
 // number (0...23 and 32...255).
 type SimpleValueRegistry struct {
 	rejected [256]bool
+	analogs  map[SimpleValue]reflect.Value
 }
 
 // WithRejectedSimpleValue registers the given simple value as rejected. If the simple value is
@@ -606,6 +1008,33 @@ func WithRejectedSimpleValue(sv SimpleValue) func(*SimpleValueRegistry) error {
 	}
 }
 
+// WithSimpleValueAnalog registers analog as the Go value of the given unassigned simple value
+// (0...19 and 32...255).  During unmarshaling, the simple value is decoded to analog if the
+// destination is an empty interface or a type that analog is assignable to.  Otherwise, the
+// simple value is decoded as if it wasn't registered.  For example, a protocol using simple
+// value 99 as a sentinel can register a Go constant of a user-defined type as its analog.
+//
+// To encode analog back to the simple value, set EncOptions.SimpleValueAnalogs to the same
+// registry, or implement Marshaler for the user-defined type using SimpleValue.MarshalCBOR.
+func WithSimpleValueAnalog(sv SimpleValue, analog interface{}) func(*SimpleValueRegistry) error {
+	return func(r *SimpleValueRegistry) error {
+		if sv >= 24 && sv <= 31 {
+			return fmt.Errorf("cbor: cannot set analog for reserved simple value %d", sv)
+		}
+		if sv >= 20 && sv <= 23 {
+			return fmt.Errorf("cbor: cannot set analog for assigned simple value %d", sv)
+		}
+		if analog == nil {
+			return fmt.Errorf("cbor: cannot set nil analog for simple value %d", sv)
+		}
+		if r.analogs == nil {
+			r.analogs = make(map[SimpleValue]reflect.Value)
+		}
+		r.analogs[sv] = reflect.ValueOf(analog)
+		return nil
+	}
+}
+
 // Creates a new SimpleValueRegistry. The registry state is initialized by executing the provided
 // functions in order against a registry that is pre-populated with the defaults for all well-formed
 // simple value numbers.
@@ -673,6 +1102,26 @@ func (bttm ByteStringToTimeMode) valid() bool {
 	return bttm >= 0 && bttm < maxByteStringToTimeMode
 }
 
+// DurationDecMode specifies how to decode CBOR integer and floating-point numbers
+// into Go time.Duration.  Duration (tag 1002) defined in RFC 9581 is always decoded
+// into time.Duration regardless of DurationDecMode.
+type DurationDecMode int
+
+const (
+	// DurationDecNanoseconds decodes CBOR integer as number of nanoseconds, and
+	// returns an error on an attempt to decode CBOR floating-point number.
+	DurationDecNanoseconds DurationDecMode = iota
+
+	// DurationDecSeconds decodes CBOR integer and floating-point number as number of seconds.
+	DurationDecSeconds
+
+	maxDurationDecMode
+)
+
+func (ddm DurationDecMode) valid() bool {
+	return ddm >= 0 && ddm < maxDurationDecMode
+}
+
 // ByteStringExpectedFormatMode specifies how to decode CBOR byte string into Go byte slice
 // when the byte string is NOT enclosed in CBOR tag 21, 22, or 23.  An error is returned if
 // the CBOR byte string does not contain the expected format (e.g. base64) specified.
@@ -745,7 +1194,47 @@ func (bum BinaryUnmarshalerMode) valid() bool {
 	return bum >= 0 && bum < maxBinaryUnmarshalerMode
 }
 
+// DeterministicMode specifies whether to reject CBOR data items that are not
+// deterministically encoded.
+type DeterministicMode int
+
+const (
+	// DeterministicNotChecked accepts well-formed CBOR data items regardless of
+	// how they are encoded.
+	DeterministicNotChecked DeterministicMode = iota
+
+	// DeterministicCoreRequired returns an UnacceptableDataItemError if a CBOR data item
+	// doesn't comply with "Core Deterministic Encoding Requirements" (RFC 8949 Section 4.2.1):
+	//  1. Arguments for integers, lengths in major types 2 through 5, and tags
+	//     must be as short as possible.
+	//  2. Floating-point values must use the shortest form that preserves the value.
+	//  3. Indefinite-length items must not appear.
+	//  4. Keys in every map must be sorted in the bytewise lexicographic order of
+	//     their deterministic encodings, without duplicates.
+	DeterministicCoreRequired
+
+	// DeterministicDCBORRequired returns an UnacceptableDataItemError if a CBOR data item
+	// doesn't comply with DeterministicCoreRequired or with the "dCBOR" deterministic CBOR
+	// application profile (draft-mcnally-deterministic-cbor):
+	//  1. Floating-point values without fractional part in the range [-2^63, 2^64-1],
+	//     including negative zero, must be encoded as integers.
+	//  2. NaN must be encoded as 0xf97e00.
+	//  3. Simple values other than false, true, and null (e.g. undefined) must not appear.
+	//  4. Negative integers less than -2^63 (65-bit negative integers) must not appear.
+	// Text strings aren't checked for Unicode Normalization Form C.
+	DeterministicDCBORRequired
+
+	maxDeterministicMode
+)
+
+func (dtm DeterministicMode) valid() bool {
+	return dtm >= 0 && dtm < maxDeterministicMode
+}
+
 // DecOptions specifies decoding options.
+//
+// Options that hold maps, lists, or functions (e.g. InterfaceFallbackTypes) refer to
+// immutable values by pointer, so DecOptions remains comparable with ==.
 type DecOptions struct {
 	// DupMapKey specifies whether to enforce duplicate map key.
 	DupMapKey DupMapKeyMode
@@ -772,6 +1261,10 @@ type DecOptions struct {
 	//
 	//   DecTagRequired: Only tags 0 and 1 can be decoded to time.Time. Any
 	//   other input will produce an error.
+	//
+	// TimeTag only affects decoding to time.Time.  Regardless of this option,
+	// tag 1 enclosing an integer can be decoded to Go integer types (e.g. an
+	// int64 field storing epoch seconds), and tag 0 can be decoded to Go string.
 	TimeTag DecTagMode
 
 	// MaxNestedLevels specifies the max nested levels allowed for any combination of CBOR array, maps, and tags.
@@ -787,9 +1280,38 @@ type DecOptions struct {
 	// Default is 128*1024=131072 and it can be set to [16, 2147483647]
 	MaxMapPairs int
 
+	// MaxStringBytes specifies the max length in bytes of a CBOR text string, including
+	// the total length of all chunks of an indefinite-length text string.
+	// Default is 0 (no limit) and it can be set to [0, 2147483647].
+	MaxStringBytes int
+
+	// MaxByteStringBytes specifies the max length in bytes of a CBOR byte string, including
+	// the total length of all chunks of an indefinite-length byte string.
+	// Default is 0 (no limit) and it can be set to [0, 2147483647].
+	MaxByteStringBytes int
+
+	// MaxDecodedSizeBytes specifies the max total size in bytes of Go values decoded
+	// from a CBOR data item, so that data within individual limits (e.g. an array of
+	// many large strings) can't exhaust memory.  The decoded size is estimated from CBOR
+	// data before decoding, independent of the Go type decoded into: the length of each
+	// byte string and text string, plus 16 bytes for each array element and each map key
+	// and value.  MaxDecodedSizeError is returned if the estimated size is exceeded.
+	// Default is 0 (no limit) and it can be set to [0, 2147483647].
+	MaxDecodedSizeBytes int
+
 	// IndefLength specifies whether to allow indefinite length CBOR items.
 	IndefLength IndefLengthMode
 
+	// ChunkedString specifies whether to allow, forbid, or require indefinite-length
+	// (chunked) byte strings and text strings.  Default is ChunkedStringAllowed.
+	ChunkedString ChunkedStringMode
+
+	// MaxStringChunkSize specifies the max length in bytes of a definite-length byte
+	// string or text string, including each chunk of an indefinite-length string, when
+	// ChunkedString is ChunkedStringRequired.  It must be set to [1, 2147483647] when
+	// ChunkedString is ChunkedStringRequired, and 0 otherwise.
+	MaxStringChunkSize int
+
 	// TagsMd specifies whether to allow CBOR tags (major type 6).
 	TagsMd TagsMode
 
@@ -860,9 +1382,17 @@ type DecOptions struct {
 	// 25 through 27) representing positive or negative infinity.
 	Inf InfMode
 
+	// Float specifies whether to decode floating-point values (major type 7, additional
+	// information 25 through 27).  FloatForbidden can be used by protocol profiles that
+	// don't allow floating-point numbers.
+	Float FloatMode
+
 	// ByteStringToTime specifies how to decode CBOR byte string into Go time.Time.
 	ByteStringToTime ByteStringToTimeMode
 
+	// Duration specifies how to decode CBOR integer and floating-point numbers into Go time.Duration.
+	Duration DurationDecMode
+
 	// ByteStringExpectedFormat specifies how to decode CBOR byte string into Go byte slice
 	// when the byte string is NOT enclosed in CBOR tag 21, 22, or 23.  An error is returned if
 	// the CBOR byte string does not contain the expected format (e.g. base64) specified.
@@ -879,6 +1409,309 @@ type DecOptions struct {
 	// BinaryUnmarshaler specifies how to decode into types that implement
 	// encoding.BinaryUnmarshaler.
 	BinaryUnmarshaler BinaryUnmarshalerMode
+
+	// Deterministic specifies whether to reject CBOR data items that are not
+	// deterministically encoded.  By default, encoded data items aren't checked
+	// for deterministic encoding.
+	Deterministic DeterministicMode
+
+	// InterfaceFallbackTypes maps non-empty interface types to concrete types to create
+	// and decode to when unmarshalling CBOR into a nil interface value of that interface type.
+	// The concrete type is used if CBOR data isn't a tag registered (in TagSet) with a type
+	// implementing the interface.  For example, mapping an interface to a struct type with
+	// a RawMessage field allows unknown variants to be decoded and kept for later processing.
+	// Each concrete type (or a pointer to it) must implement its interface type.
+	// By default, unmarshal returns UnmarshalTypeError for such CBOR data.
+	// Use NewInterfaceFallbackTypes to create it.
+	InterfaceFallbackTypes *InterfaceFallbackTypes
+
+	// MapKeyUTF8 specifies if decoder should decode CBOR Text containing invalid UTF-8
+	// in map keys.  This allows map keys to be validated differently from other CBOR Text
+	// (e.g. reject invalid UTF-8 in map keys while decoding invalid UTF-8 in map values).
+	// By default, CBOR Text in map keys is validated as specified by UTF8 option.
+	MapKeyUTF8 MapKeyUTF8Mode
+
+	// TextStringToByteSlice specifies the behavior when decoding a CBOR text string into a Go byte slice.
+	TextStringToByteSlice TextStringToByteSliceMode
+
+	// BigFloatPrecision specifies mantissa precision (in bits) of big.Float values created
+	// when decoding CBOR to big.Float.  Values are rounded to this precision using
+	// BigFloatRoundingMode.  By default (precision 0), decoded values aren't rounded and
+	// precision is set as described by big.Float's SetFloat64, SetInt, SetRat, etc.
+	// It can be set to [0, big.MaxPrec].
+	BigFloatPrecision uint
+
+	// BigFloatRoundingMode specifies rounding mode of big.Float values created when
+	// decoding CBOR to big.Float.  Default is big.ToNearestEven.
+	BigFloatRoundingMode big.RoundingMode
+
+	// ZeroCopy specifies whether decoded byte slices can share memory with the input data
+	// instead of being copied.  Default is ZeroCopyNone.  See ZeroCopyBytes for details
+	// on the lifetime of decoded values.
+	ZeroCopy ZeroCopyMode
+
+	// Reuse specifies whether decoding reuses slices, maps, and pointed-to values already
+	// allocated in the destination value, and resets parts of it absent from CBOR data.
+	// Default is ReuseNone.
+	Reuse ReuseMode
+
+	// NilValue specifies how to decode CBOR null and undefined to Go values that can't
+	// be nil.  Default is NilValueNoOp.
+	NilValue NilValueMode
+
+	// ArrayLength specifies how to decode CBOR array to Go array of different length.
+	// Default is ArrayLengthLenient.
+	ArrayLength ArrayLengthMode
+
+	// StringerEnums lists values of integer types that implement fmt.Stringer, such as
+	// time.January through time.December, to decode from CBOR text strings by name.
+	// A CBOR text string decoded to one of these types must match the String output of
+	// a listed value of the same type, e.g. "March" decodes to time.March.  CBOR integers
+	// are decoded to these types as usual.  See EncOptions.StringerEnums for encoding.
+	// Use NewStringerEnums to create it.
+	StringerEnums *StringerEnums
+
+	// EncodedItem specifies how to decode CBOR tag 24 (encoded CBOR data item).
+	// Default is EncodedItemDecodeAsTag.  See EncodedItem type for decoding tag 24
+	// explicitly.
+	EncodedItem EncodedItemDecMode
+
+	// SimpleValueToAny specifies how to decode CBOR simple values false, true, null, and
+	// undefined into an empty interface (any).  Default is SimpleValueToGoValue.
+	// Other simple values are always decoded to SimpleValue (see SimpleValues).
+	SimpleValueToAny SimpleValueToAnyMode
+
+	// SmallValueToAny specifies how to decode small CBOR integers and text strings into
+	// an empty interface (any), to reduce allocations when decoding data dominated by
+	// such values.  Default is SmallValueToAnyAllocate.
+	SmallValueToAny SmallValueToAnyMode
+
+	// Budget, if not nil, is called periodically while decoding each CBOR data item,
+	// with the number of data items decoded and the number of bytes consumed so far,
+	// and once more with the totals after the data item is decoded.  Decoding is
+	// aborted if Budget returns a non-nil error, which is returned as is.  This can be
+	// used to enforce limits on decoder work that are more flexible than MaxNestedLevels,
+	// MaxArrayElements, etc.  Budget is called before CBOR data is decoded into Go values.
+	// It can be called concurrently by multiple decoding functions sharing the same DecMode,
+	// and it can be called again for the same data when Decoder needs to read more data.
+	// Use NewBudget to create it.
+	Budget *Budget
+
+	// FieldNameTransform, if not nil, transforms a CBOR map key that doesn't exactly match
+	// any Go struct field name (or tag name) before matching it again, e.g. to decode
+	// snake_case keys like "device_id" to field DeviceID without annotating every field.
+	// The transformed key is matched exactly and then, depending on FieldNameMatching,
+	// case-insensitively.  It doesn't apply to keyasint fields or to encoding.
+	// Use NewStringTransform to create it.
+	FieldNameTransform *StringTransform
+
+	// UnicodeNormalization, if not nil, normalizes decoded CBOR text strings, including
+	// keys of Go maps, e.g. to Unicode Normalization Form C using norm.NFC.String from
+	// golang.org/x/text/unicode/norm.  It is called after UTF-8 validation.  It isn't
+	// applied to CBOR map keys matched against Go struct fields.  Set FieldNameTransform
+	// to the same function to match struct fields using normalized keys.
+	// Use NewStringTransform to create it.
+	UnicodeNormalization *StringTransform
+
+	// FieldNameIgnoredTags lists tag numbers to remove from CBOR map keys before matching
+	// them with Go struct fields, e.g. tag 21 (expected conversion to base64url) used by
+	// some encoders as a hint around text string keys.  Nested tags are removed if all of
+	// them are listed.  Map keys enclosed in other tags don't match any struct field.
+	// Use NewTagNumbers to create it.
+	FieldNameIgnoredTags *TagNumbers
+
+	// TrailingBytes specifies whether Unmarshal and Wellformed accept bytes following
+	// the CBOR data item.  Default is TrailingBytesForbidden.  UnmarshalFirst and Decoder
+	// aren't affected, since they are intended for data with multiple data items.
+	// CBOR data items enclosed in tag 24 must always be exactly one data item.
+	TrailingBytes TrailingBytesMode
+
+	// AllowedTags, if not nil, lists tag numbers allowed in CBOR data when TagsMd is
+	// TagsAllowed.  Other tags are rejected with UnacceptableDataItemError, including
+	// tags registered with TagSet and tags enclosed in other tags.  Set TagsMd to
+	// TagsForbidden to reject all tags.  Use NewTagNumbers to create it.
+	AllowedTags *TagNumbers
+}
+
+// InterfaceFallbackTypes is an immutable map of interface types to concrete types used
+// by DecOptions.InterfaceFallbackTypes.
+type InterfaceFallbackTypes struct {
+	m map[reflect.Type]reflect.Type
+}
+
+// NewInterfaceFallbackTypes returns InterfaceFallbackTypes with a copy of m, which
+// maps non-empty interface types to concrete types.  It returns nil if m is empty.
+func NewInterfaceFallbackTypes(m map[reflect.Type]reflect.Type) *InterfaceFallbackTypes {
+	if len(m) == 0 {
+		return nil
+	}
+	p := &InterfaceFallbackTypes{m: make(map[reflect.Type]reflect.Type, len(m))}
+	for ifaceType, concreteType := range m {
+		p.m[ifaceType] = concreteType
+	}
+	return p
+}
+
+// get returns concrete type registered for interface type t, or nil if p is nil or
+// t isn't registered.
+func (p *InterfaceFallbackTypes) get(t reflect.Type) reflect.Type {
+	if p == nil {
+		return nil
+	}
+	return p.m[t]
+}
+
+// StringerEnums is an immutable list of values used by DecOptions.StringerEnums.
+type StringerEnums struct {
+	enums []interface{}
+}
+
+// NewStringerEnums returns StringerEnums with a copy of enums, which are values of
+// integer types that implement fmt.Stringer.  It returns nil if enums is empty.
+func NewStringerEnums(enums ...interface{}) *StringerEnums {
+	if len(enums) == 0 {
+		return nil
+	}
+	p := &StringerEnums{enums: make([]interface{}, len(enums))}
+	copy(p.enums, enums)
+	return p
+}
+
+// Budget is an immutable function used by DecOptions.Budget.
+type Budget struct {
+	fn func(itemsDecoded int, bytesConsumed int) error
+}
+
+// NewBudget returns Budget calling fn.  It returns nil if fn is nil.
+func NewBudget(fn func(itemsDecoded int, bytesConsumed int) error) *Budget {
+	if fn == nil {
+		return nil
+	}
+	return &Budget{fn: fn}
+}
+
+// StringTransform is an immutable function used by DecOptions.FieldNameTransform and
+// DecOptions.UnicodeNormalization.
+type StringTransform struct {
+	fn func(s string) string
+}
+
+// NewStringTransform returns StringTransform calling fn.  It returns nil if fn is nil.
+func NewStringTransform(fn func(s string) string) *StringTransform {
+	if fn == nil {
+		return nil
+	}
+	return &StringTransform{fn: fn}
+}
+
+// TagNumbers is an immutable list of tag numbers used by DecOptions.FieldNameIgnoredTags
+// and DecOptions.AllowedTags.
+type TagNumbers struct {
+	nums []uint64
+}
+
+// NewTagNumbers returns TagNumbers with a copy of nums.  It returns nil if nums is empty.
+func NewTagNumbers(nums ...uint64) *TagNumbers {
+	if len(nums) == 0 {
+		return nil
+	}
+	p := &TagNumbers{nums: make([]uint64, len(nums))}
+	copy(p.nums, nums)
+	return p
+}
+
+func (p *TagNumbers) contains(tagNum uint64) bool {
+	for _, n := range p.nums {
+		if n == tagNum {
+			return true
+		}
+	}
+	return false
+}
+
+// budgetInterval is the number of data items decoded between calls to DecOptions.Budget.
+const budgetInterval = 1024
+
+// CoreDetDecOptions returns DecOptions that reject CBOR data items which don't
+// comply with "Core Deterministic Encoding Requirements" defined in RFC 8949
+// Section 4.2.1.  This can be used to verify that data is encoded by peers
+// using CoreDetEncOptions.
+func CoreDetDecOptions() DecOptions {
+	return DecOptions{
+		IndefLength:   IndefLengthForbidden,
+		Deterministic: DeterministicCoreRequired,
+	}
+}
+
+// UntrustedDecOptions returns DecOptions suitable for decoding CBOR data from untrusted
+// sources, with limits lower than the defaults:
+//
+//   - MaxNestedLevels is 16, MaxArrayElements and MaxMapPairs are 4096.
+//   - MaxStringBytes is 64 KiB and MaxByteStringBytes is 1 MiB.
+//   - Duplicate map keys are rejected before decoding (DupMapKeyReject).
+//   - Invalid UTF-8 text strings are rejected, including in map keys.
+//   - Indefinite-length items are rejected.
+//   - Only tags 0 and 1 (timestamps) are allowed (AllowedTags).  Other tags, including
+//     bignums, are rejected.  Each tag counts toward MaxNestedLevels.
+//
+// The returned options can be adjusted to the needs of a protocol before creating DecMode.
+func UntrustedDecOptions() DecOptions {
+	return DecOptions{
+		DupMapKey:          DupMapKeyReject,
+		MaxNestedLevels:    16,
+		MaxArrayElements:   4096,
+		MaxMapPairs:        4096,
+		MaxStringBytes:     64 * 1024,
+		MaxByteStringBytes: 1024 * 1024,
+		IndefLength:        IndefLengthForbidden,
+		UTF8:               UTF8RejectInvalid,
+		MapKeyUTF8:         MapKeyUTF8RejectInvalid,
+		AllowedTags:        untrustedAllowedTags,
+	}
+}
+
+// untrustedAllowedTags is shared by all DecOptions returned by UntrustedDecOptions,
+// so they are equal with ==.
+var untrustedAllowedTags = NewTagNumbers(tagNumRFC3339Time, tagNumEpochTime)
+
+// DCBORDecOptions returns DecOptions that reject CBOR data items which don't comply
+// with the "dCBOR" deterministic CBOR application profile (draft-mcnally-deterministic-cbor),
+// used by Gordian Envelope.  This can be used to verify that data is encoded by peers
+// using DCBOREncOptions.  See DeterministicDCBORRequired for details.
+func DCBORDecOptions() DecOptions {
+	return DecOptions{
+		IndefLength:   IndefLengthForbidden,
+		Deterministic: DeterministicDCBORRequired,
+	}
+}
+
+// JSONCompatibleDecOptions returns DecOptions for decoding CBOR data to Go interface{}
+// values which can be passed to encoding/json or text/template as is:
+//
+//   - CBOR maps are decoded to map[string]interface{}, and UnmarshalTypeError is returned
+//     for map keys which aren't CBOR text strings.
+//   - CBOR integers are decoded to int64, or float64 if value doesn't fit into int64.
+//   - NaN and infinity are rejected, since they can't be encoded to JSON.
+//   - Content of unrecognized tags is decoded without cbor.Tag.
+//
+// Other data items are decoded to default Go types, e.g. CBOR byte strings to []byte
+// (encoded as base64 text by encoding/json), and tags 0 and 1 to time.Time.
+func JSONCompatibleDecOptions() DecOptions {
+	return DecOptions{
+		DefaultMapType:       reflect.TypeOf(map[string]interface{}(nil)),
+		IntDec:               IntDecConvertSignedOrFloat64,
+		NaN:                  NaNDecodeForbidden,
+		Inf:                  InfDecodeForbidden,
+		UnrecognizedTagToAny: UnrecognizedTagContentToAny,
+	}
+}
+
+var untrustedDecMode, _ = UntrustedDecOptions().decMode()
+
+// UntrustedDecMode returns DecMode created with UntrustedDecOptions, for decoding
+// CBOR data from untrusted sources.  The returned DecMode is safe for concurrent use.
+func UntrustedDecMode() DecMode {
+	return untrustedDecMode
 }
 
 // DecMode returns DecMode with immutable options and no tags (safe for concurrency).
@@ -888,7 +1721,7 @@ func (opts DecOptions) DecMode() (DecMode, error) { //nolint:gocritic // ignore
 
 // validForTags checks that the provided tag set is compatible with these options and returns a
 // non-nil error if and only if the provided tag set is incompatible.
-func (opts DecOptions) validForTags(tags TagSet) error { //nolint:gocritic // ignore hugeParam
+func (opts DecOptions) validForTags(tags tagProvider) error { //nolint:gocritic // ignore hugeParam
 	if opts.TagsMd == TagsForbidden {
 		return errors.New("cbor: cannot create DecMode with TagSet when TagsMd is TagsForbidden")
 	}
@@ -961,6 +1794,10 @@ const (
 	minMaxMapPairs     = 16
 	maxMaxMapPairs     = 2147483647
 
+	maxMaxStringBytes = 2147483647
+
+	maxMaxDecodedSizeBytes = 2147483647
+
 	defaultMaxNestedLevels = 32
 	minMaxNestedLevels     = 4
 	maxMaxNestedLevels     = 65535
@@ -987,6 +1824,19 @@ func (opts DecOptions) decMode() (*decMode, error) { //nolint:gocritic // ignore
 	if !opts.IndefLength.valid() {
 		return nil, errors.New("cbor: invalid IndefLength " + strconv.Itoa(int(opts.IndefLength)))
 	}
+	if !opts.ChunkedString.valid() {
+		return nil, errors.New("cbor: invalid ChunkedString " + strconv.Itoa(int(opts.ChunkedString)))
+	}
+	if opts.MaxStringChunkSize < 0 || opts.MaxStringChunkSize > maxMaxStringBytes {
+		return nil, errors.New("cbor: invalid MaxStringChunkSize " + strconv.Itoa(opts.MaxStringChunkSize) +
+			" (range is [0, " + strconv.Itoa(maxMaxStringBytes) + "])")
+	}
+	if opts.ChunkedString == ChunkedStringRequired && opts.MaxStringChunkSize == 0 {
+		return nil, errors.New("cbor: MaxStringChunkSize must be set when ChunkedString is ChunkedStringRequired")
+	}
+	if opts.ChunkedString != ChunkedStringRequired && opts.MaxStringChunkSize != 0 {
+		return nil, errors.New("cbor: MaxStringChunkSize can only be set when ChunkedString is ChunkedStringRequired")
+	}
 
 	if !opts.TagsMd.valid() {
 		return nil, errors.New("cbor: invalid TagsMd " + strconv.Itoa(int(opts.TagsMd)))
@@ -1021,6 +1871,21 @@ func (opts DecOptions) decMode() (*decMode, error) { //nolint:gocritic // ignore
 			" (range is [" + strconv.Itoa(minMaxMapPairs) + ", " + strconv.Itoa(maxMaxMapPairs) + "])")
 	}
 
+	if opts.MaxStringBytes < 0 || opts.MaxStringBytes > maxMaxStringBytes {
+		return nil, errors.New("cbor: invalid MaxStringBytes " + strconv.Itoa(opts.MaxStringBytes) +
+			" (range is [0, " + strconv.Itoa(maxMaxStringBytes) + "])")
+	}
+
+	if opts.MaxByteStringBytes < 0 || opts.MaxByteStringBytes > maxMaxStringBytes {
+		return nil, errors.New("cbor: invalid MaxByteStringBytes " + strconv.Itoa(opts.MaxByteStringBytes) +
+			" (range is [0, " + strconv.Itoa(maxMaxStringBytes) + "])")
+	}
+
+	if opts.MaxDecodedSizeBytes < 0 || opts.MaxDecodedSizeBytes > maxMaxDecodedSizeBytes {
+		return nil, errors.New("cbor: invalid MaxDecodedSizeBytes " + strconv.Itoa(opts.MaxDecodedSizeBytes) +
+			" (range is [0, " + strconv.Itoa(maxMaxDecodedSizeBytes) + "])")
+	}
+
 	if !opts.ExtraReturnErrors.valid() {
 		return nil, errors.New("cbor: invalid ExtraReturnErrors " + strconv.Itoa(int(opts.ExtraReturnErrors)))
 	}
@@ -1075,10 +1940,18 @@ func (opts DecOptions) decMode() (*decMode, error) { //nolint:gocritic // ignore
 		return nil, errors.New("cbor: invalid InfDec " + strconv.Itoa(int(opts.Inf)))
 	}
 
+	if !opts.Float.valid() {
+		return nil, errors.New("cbor: invalid Float " + strconv.Itoa(int(opts.Float)))
+	}
+
 	if !opts.ByteStringToTime.valid() {
 		return nil, errors.New("cbor: invalid ByteStringToTime " + strconv.Itoa(int(opts.ByteStringToTime)))
 	}
 
+	if !opts.Duration.valid() {
+		return nil, errors.New("cbor: invalid Duration " + strconv.Itoa(int(opts.Duration)))
+	}
+
 	if !opts.ByteStringExpectedFormat.valid() {
 		return nil, errors.New("cbor: invalid ByteStringExpectedFormat " + strconv.Itoa(int(opts.ByteStringExpectedFormat)))
 	}
@@ -1091,15 +1964,134 @@ func (opts DecOptions) decMode() (*decMode, error) { //nolint:gocritic // ignore
 		return nil, errors.New("cbor: invalid BinaryUnmarshaler " + strconv.Itoa(int(opts.BinaryUnmarshaler)))
 	}
 
-	dm := decMode{
-		dupMapKey:                opts.DupMapKey,
-		timeTag:                  opts.TimeTag,
-		maxNestedLevels:          opts.MaxNestedLevels,
-		maxArrayElements:         opts.MaxArrayElements,
-		maxMapPairs:              opts.MaxMapPairs,
-		indefLength:              opts.IndefLength,
-		tagsMd:                   opts.TagsMd,
-		intDec:                   opts.IntDec,
+	if !opts.Deterministic.valid() {
+		return nil, errors.New("cbor: invalid Deterministic " + strconv.Itoa(int(opts.Deterministic)))
+	}
+
+	if !opts.MapKeyUTF8.valid() {
+		return nil, errors.New("cbor: invalid MapKeyUTF8 " + strconv.Itoa(int(opts.MapKeyUTF8)))
+	}
+
+	if !opts.TextStringToByteSlice.valid() {
+		return nil, errors.New("cbor: invalid TextStringToByteSlice " + strconv.Itoa(int(opts.TextStringToByteSlice)))
+	}
+
+	if opts.BigFloatPrecision > big.MaxPrec {
+		return nil, errors.New("cbor: invalid BigFloatPrecision " + strconv.FormatUint(uint64(opts.BigFloatPrecision), 10) +
+			" (range is [0, " + strconv.FormatUint(big.MaxPrec, 10) + "])")
+	}
+
+	if !opts.ZeroCopy.valid() {
+		return nil, errors.New("cbor: invalid ZeroCopy " + strconv.Itoa(int(opts.ZeroCopy)))
+	}
+	if !opts.Reuse.valid() {
+		return nil, errors.New("cbor: invalid Reuse " + strconv.Itoa(int(opts.Reuse)))
+	}
+	if !opts.NilValue.valid() {
+		return nil, errors.New("cbor: invalid NilValue " + strconv.Itoa(int(opts.NilValue)))
+	}
+	if !opts.ArrayLength.valid() {
+		return nil, errors.New("cbor: invalid ArrayLength " + strconv.Itoa(int(opts.ArrayLength)))
+	}
+	if !opts.EncodedItem.valid() {
+		return nil, errors.New("cbor: invalid EncodedItem " + strconv.Itoa(int(opts.EncodedItem)))
+	}
+
+	if !opts.SimpleValueToAny.valid() {
+		return nil, errors.New("cbor: invalid SimpleValueToAny " + strconv.Itoa(int(opts.SimpleValueToAny)))
+	}
+
+	if !opts.SmallValueToAny.valid() {
+		return nil, errors.New("cbor: invalid SmallValueToAny " + strconv.Itoa(int(opts.SmallValueToAny)))
+	}
+
+	if !opts.TrailingBytes.valid() {
+		return nil, errors.New("cbor: invalid TrailingBytes " + strconv.Itoa(int(opts.TrailingBytes)))
+	}
+
+	if opts.BigFloatRoundingMode > big.ToPositiveInf {
+		return nil, errors.New("cbor: invalid BigFloatRoundingMode " + strconv.Itoa(int(opts.BigFloatRoundingMode)))
+	}
+
+	fieldNameIgnoredTags := opts.FieldNameIgnoredTags
+	if fieldNameIgnoredTags != nil && len(fieldNameIgnoredTags.nums) == 0 {
+		fieldNameIgnoredTags = nil
+	}
+
+	allowedTags := opts.AllowedTags
+	if allowedTags != nil && len(allowedTags.nums) == 0 {
+		allowedTags = nil
+	}
+
+	stringerEnums := opts.StringerEnums
+	if stringerEnums != nil && len(stringerEnums.enums) == 0 {
+		stringerEnums = nil
+	}
+	var stringerEnumValues map[reflect.Type]map[string]reflect.Value
+	if stringerEnums != nil {
+		stringerEnumValues = make(map[reflect.Type]map[string]reflect.Value)
+		for _, enum := range stringerEnums.enums {
+			s, ok := enum.(fmt.Stringer)
+			if !ok || !isIntegerKind(reflect.TypeOf(enum).Kind()) {
+				return nil, fmt.Errorf("cbor: invalid StringerEnums: %T is not an integer type implementing fmt.Stringer", enum)
+			}
+			v := reflect.ValueOf(enum)
+			names := stringerEnumValues[v.Type()]
+			if names == nil {
+				names = make(map[string]reflect.Value)
+				stringerEnumValues[v.Type()] = names
+			}
+			name := s.String()
+			if _, ok := names[name]; ok {
+				return nil, fmt.Errorf("cbor: invalid StringerEnums: duplicate name %q for %T", name, enum)
+			}
+			names[name] = v
+		}
+	}
+
+	interfaceFallbackTypes := opts.InterfaceFallbackTypes
+	if interfaceFallbackTypes != nil && len(interfaceFallbackTypes.m) == 0 {
+		interfaceFallbackTypes = nil
+	}
+	if interfaceFallbackTypes != nil {
+		for ifaceType, concreteType := range interfaceFallbackTypes.m {
+			if ifaceType == nil || ifaceType.Kind() != reflect.Interface || ifaceType.NumMethod() == 0 {
+				return nil, fmt.Errorf("cbor: invalid InterfaceFallbackTypes: %v is not a non-empty interface type", ifaceType)
+			}
+			if concreteType == nil || concreteType.Kind() == reflect.Interface ||
+				(!concreteType.Implements(ifaceType) && !reflect.PtrTo(concreteType).Implements(ifaceType)) {
+				return nil, fmt.Errorf("cbor: invalid InterfaceFallbackTypes: %v does not implement %v", concreteType, ifaceType)
+			}
+		}
+	}
+
+	budget := opts.Budget
+	if budget != nil && budget.fn == nil {
+		budget = nil
+	}
+	fieldNameTransform := opts.FieldNameTransform
+	if fieldNameTransform != nil && fieldNameTransform.fn == nil {
+		fieldNameTransform = nil
+	}
+	unicodeNormalization := opts.UnicodeNormalization
+	if unicodeNormalization != nil && unicodeNormalization.fn == nil {
+		unicodeNormalization = nil
+	}
+
+	dm := decMode{
+		dupMapKey:                opts.DupMapKey,
+		timeTag:                  opts.TimeTag,
+		maxNestedLevels:          opts.MaxNestedLevels,
+		maxArrayElements:         opts.MaxArrayElements,
+		maxMapPairs:              opts.MaxMapPairs,
+		maxStringBytes:           opts.MaxStringBytes,
+		maxByteStringBytes:       opts.MaxByteStringBytes,
+		maxDecodedSizeBytes:      opts.MaxDecodedSizeBytes,
+		indefLength:              opts.IndefLength,
+		chunkedString:            opts.ChunkedString,
+		maxStringChunkSize:       opts.MaxStringChunkSize,
+		tagsMd:                   opts.TagsMd,
+		intDec:                   opts.IntDec,
 		mapKeyByteString:         opts.MapKeyByteString,
 		extraReturnErrors:        opts.ExtraReturnErrors,
 		defaultMapType:           opts.DefaultMapType,
@@ -1114,10 +2106,33 @@ func (opts DecOptions) decMode() (*decMode, error) { //nolint:gocritic // ignore
 		simpleValues:             simpleValues,
 		nanDec:                   opts.NaN,
 		infDec:                   opts.Inf,
+		float:                    opts.Float,
 		byteStringToTime:         opts.ByteStringToTime,
+		duration:                 opts.Duration,
 		byteStringExpectedFormat: opts.ByteStringExpectedFormat,
 		bignumTag:                opts.BignumTag,
 		binaryUnmarshaler:        opts.BinaryUnmarshaler,
+		deterministic:            opts.Deterministic,
+		interfaceFallbackTypes:   interfaceFallbackTypes,
+		mapKeyUTF8:               opts.MapKeyUTF8,
+		textStringToByteSlice:    opts.TextStringToByteSlice,
+		bigFloatPrecision:        opts.BigFloatPrecision,
+		bigFloatRoundingMode:     opts.BigFloatRoundingMode,
+		zeroCopy:                 opts.ZeroCopy,
+		reuse:                    opts.Reuse,
+		nilValue:                 opts.NilValue,
+		arrayLength:              opts.ArrayLength,
+		stringerEnums:            stringerEnums,
+		stringerEnumValues:       stringerEnumValues,
+		encodedItem:              opts.EncodedItem,
+		simpleValueToAny:         opts.SimpleValueToAny,
+		smallValueToAny:          opts.SmallValueToAny,
+		budget:                   budget,
+		fieldNameTransform:       fieldNameTransform,
+		unicodeNormalization:     unicodeNormalization,
+		fieldNameIgnoredTags:     fieldNameIgnoredTags,
+		trailingBytes:            opts.TrailingBytes,
+		allowedTags:              allowedTags,
 	}
 
 	return &dm, nil
@@ -1169,6 +2184,42 @@ type DecMode interface {
 	DecOptions() DecOptions
 }
 
+// ExtendedDecMode is an interface for CBOR decoding, which extends DecMode with
+// methods that aren't part of DecMode to keep it compatible with existing
+// implementations.  DecMode returned by DecOptions methods implements it.
+type ExtendedDecMode interface {
+	DecMode
+
+	// DecodeWrapped decodes CBOR data item wrapped in a definite-length CBOR byte string
+	// into the value pointed to by v, using the decoding mode.
+	//
+	// See the documentation for DecodeWrapped for details.
+	DecodeWrapped(data []byte, v interface{}) error
+
+	// MemoryUsage returns approximate number of bytes retained by the decoding mode,
+	// including its tags.  Memory used by internal caches of Go type information is
+	// shared by all modes, and is reported by TypeCacheStats.
+	MemoryUsage() int
+
+	// WithOptions returns a new DecMode with the options of this mode modified by fn,
+	// and the same tags as this mode.  This mode isn't modified.
+	//
+	// See the documentation for (*decMode).WithOptions for details.
+	WithOptions(fn func(opts *DecOptions)) (DecMode, error)
+
+	// Precompile builds and caches decoding information of types and types reachable
+	// from them, and returns StructPlan of struct types found.
+	//
+	// See the documentation for (*decMode).Precompile for details.
+	Precompile(types ...reflect.Type) ([]StructPlan, error)
+
+	// This private method is to prevent users implementing
+	// this interface and so future additions to it will
+	// not be breaking changes.
+	// See https://go.dev/blog/module-compatibility
+	unexport()
+}
+
 type decMode struct {
 	tags                     tagProvider
 	dupMapKey                DupMapKeyMode
@@ -1176,7 +2227,12 @@ type decMode struct {
 	maxNestedLevels          int
 	maxArrayElements         int
 	maxMapPairs              int
+	maxStringBytes           int
+	maxByteStringBytes       int
+	maxDecodedSizeBytes      int
 	indefLength              IndefLengthMode
+	chunkedString            ChunkedStringMode
+	maxStringChunkSize       int
 	tagsMd                   TagsMode
 	intDec                   IntDecMode
 	mapKeyByteString         MapKeyByteStringMode
@@ -1193,14 +2249,39 @@ type decMode struct {
 	simpleValues             *SimpleValueRegistry
 	nanDec                   NaNMode
 	infDec                   InfMode
+	float                    FloatMode
 	byteStringToTime         ByteStringToTimeMode
+	duration                 DurationDecMode
 	byteStringExpectedFormat ByteStringExpectedFormatMode
 	bignumTag                BignumTagMode
 	binaryUnmarshaler        BinaryUnmarshalerMode
+	deterministic            DeterministicMode
+	interfaceFallbackTypes   *InterfaceFallbackTypes
+	mapKeyUTF8               MapKeyUTF8Mode
+	textStringToByteSlice    TextStringToByteSliceMode
+	bigFloatPrecision        uint
+	bigFloatRoundingMode     big.RoundingMode
+	zeroCopy                 ZeroCopyMode
+	reuse                    ReuseMode
+	nilValue                 NilValueMode
+	arrayLength              ArrayLengthMode
+	stringerEnums            *StringerEnums
+	stringerEnumValues       map[reflect.Type]map[string]reflect.Value
+	encodedItem              EncodedItemDecMode
+	simpleValueToAny         SimpleValueToAnyMode
+	smallValueToAny          SmallValueToAnyMode
+	budget                   *Budget
+	fieldNameTransform       *StringTransform
+	unicodeNormalization     *StringTransform
+	fieldNameIgnoredTags     *TagNumbers
+	trailingBytes            TrailingBytesMode
+	allowedTags              *TagNumbers
 }
 
 var defaultDecMode, _ = DecOptions{}.decMode()
 
+func (dm *decMode) unexport() {}
+
 // DecOptions returns user specified options used to create this DecMode.
 func (dm *decMode) DecOptions() DecOptions {
 	simpleValues := dm.simpleValues
@@ -1216,7 +2297,12 @@ func (dm *decMode) DecOptions() DecOptions {
 		MaxNestedLevels:          dm.maxNestedLevels,
 		MaxArrayElements:         dm.maxArrayElements,
 		MaxMapPairs:              dm.maxMapPairs,
+		MaxStringBytes:           dm.maxStringBytes,
+		MaxByteStringBytes:       dm.maxByteStringBytes,
+		MaxDecodedSizeBytes:      dm.maxDecodedSizeBytes,
 		IndefLength:              dm.indefLength,
+		ChunkedString:            dm.chunkedString,
+		MaxStringChunkSize:       dm.maxStringChunkSize,
 		TagsMd:                   dm.tagsMd,
 		IntDec:                   dm.intDec,
 		MapKeyByteString:         dm.mapKeyByteString,
@@ -1233,11 +2319,57 @@ func (dm *decMode) DecOptions() DecOptions {
 		SimpleValues:             simpleValues,
 		NaN:                      dm.nanDec,
 		Inf:                      dm.infDec,
+		Float:                    dm.float,
 		ByteStringToTime:         dm.byteStringToTime,
+		Duration:                 dm.duration,
 		ByteStringExpectedFormat: dm.byteStringExpectedFormat,
 		BignumTag:                dm.bignumTag,
 		BinaryUnmarshaler:        dm.binaryUnmarshaler,
+		Deterministic:            dm.deterministic,
+		InterfaceFallbackTypes:   dm.interfaceFallbackTypes,
+		MapKeyUTF8:               dm.mapKeyUTF8,
+		TextStringToByteSlice:    dm.textStringToByteSlice,
+		BigFloatPrecision:        dm.bigFloatPrecision,
+		BigFloatRoundingMode:     dm.bigFloatRoundingMode,
+		ZeroCopy:                 dm.zeroCopy,
+		Reuse:                    dm.reuse,
+		NilValue:                 dm.nilValue,
+		ArrayLength:              dm.arrayLength,
+		StringerEnums:            dm.stringerEnums,
+		EncodedItem:              dm.encodedItem,
+		SimpleValueToAny:         dm.simpleValueToAny,
+		SmallValueToAny:          dm.smallValueToAny,
+		Budget:                   dm.budget,
+		FieldNameTransform:       dm.fieldNameTransform,
+		AllowedTags:              dm.allowedTags,
+		UnicodeNormalization:     dm.unicodeNormalization,
+		FieldNameIgnoredTags:     dm.fieldNameIgnoredTags,
+		TrailingBytes:            dm.trailingBytes,
+	}
+}
+
+// WithOptions returns a new DecMode with the options of dm modified by fn, and the
+// same tags as dm: tags copied by DecModeWithTags are shared since they are immutable,
+// and shared tags registered with DecModeWithSharedTags remain shared.  fn receives a
+// copy of dm's options, so dm isn't modified and can be used concurrently.
+//
+// Derived modes are cheap to create for per-request or per-tenant option tweaks.
+// Go type information (e.g. struct fields) is cached globally and shared by all modes,
+// so it isn't recomputed for derived modes.
+func (dm *decMode) WithOptions(fn func(opts *DecOptions)) (DecMode, error) {
+	opts := dm.DecOptions()
+	fn(&opts)
+	if dm.tags != nil {
+		if err := opts.validForTags(dm.tags); err != nil {
+			return nil, err
+		}
 	}
+	newDM, err := opts.decMode()
+	if err != nil {
+		return nil, err
+	}
+	newDM.tags = dm.tags
+	return newDM, nil
 }
 
 // Unmarshal parses the CBOR-encoded data into the value pointed to by v
@@ -1249,9 +2381,9 @@ func (dm *decMode) Unmarshal(data []byte, v interface{}) error {
 	d := decoder{data: data, dm: dm}
 
 	// Check well-formedness.
-	off := d.off                      // Save offset before data validation
-	err := d.wellformed(false, false) // don't allow any extra data after valid data item.
-	d.off = off                       // Restore offset
+	off := d.off // Save offset before data validation
+	err := d.wellformed(dm.trailingBytes == TrailingBytesAllowed, false)
+	d.off = off // Restore offset
 	if err != nil {
 		return err
 	}
@@ -1309,10 +2441,11 @@ func (dm *decMode) Valid(data []byte) error {
 // MaxArrayElements, MaxMapPairs, etc.
 //
 // If there are any remaining bytes after the CBOR data item,
-// an ExtraneousDataError is returned.
+// an ExtraneousDataError is returned, unless TrailingBytes option is
+// TrailingBytesAllowed.
 func (dm *decMode) Wellformed(data []byte) error {
 	d := decoder{data: data, dm: dm}
-	return d.wellformed(false, false)
+	return d.wellformed(dm.trailingBytes == TrailingBytesAllowed, false)
 }
 
 // NewDecoder returns a new decoder that reads from r using dm DecMode.
@@ -1335,6 +2468,25 @@ type decoder struct {
 	// encoding of the byte strings h'42' and h'43' would be controlled by tag 23 and 21,
 	// respectively.
 	expectedLaterEncodingTags []uint64
+
+	// parsingMapKey is true when parsing CBOR map key (including data items nested in map key).
+	parsingMapKey bool
+
+	// copyBytes is true when parsing struct field with "copy" option (including data items nested in it).
+	copyBytes bool
+
+	// itemsDecoded and budgetOff are number of data items decoded and offset of the
+	// top-level data item being checked by wellformed, used for calling dm.budget.
+	itemsDecoded int
+	budgetOff    int
+
+	// decodedSize is estimated size of Go values decoded from the top-level data item
+	// being checked by wellformed, used for checking dm.maxDecodedSizeBytes.
+	decodedSize int
+
+	// cachedStrings stores decoded text strings in empty interfaces for reuse,
+	// if SmallValueToAny is SmallValueToAnyCacheIntegersAndStrings.
+	cachedStrings map[string]interface{}
 }
 
 // value decodes CBOR data item into the value pointed to by v.
@@ -1353,12 +2505,48 @@ func (d *decoder) value(v interface{}) error {
 		return &InvalidUnmarshalError{"cbor: Unmarshal(nil " + rv.Type().String() + ")"}
 	}
 	rv = rv.Elem()
-	return d.parseToValue(rv, getTypeInfo(rv.Type()))
+	off := d.off
+	err := d.parseToValue(rv, getTypeInfo(rv.Type()))
+	if err != nil {
+		d.setErrorPath(err, off)
+	}
+	return err
 }
 
 // parseToValue decodes CBOR data to value.  It assumes data is well-formed,
-// and does not perform bounds checking.
-func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolint:gocyclo
+// and does not perform bounds checking.  Offset of data item is set in
+// returned error (see ErrorLocation).
+func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error {
+	off := d.off
+	err := d.parseDataItemToValue(v, tInfo)
+	if err == nil && d.dm.tags != nil && tInfo.spclType != specialTypeEmptyIface {
+		err = d.validateTagContent(v)
+	}
+	if err != nil {
+		setErrorOffset(err, off)
+	}
+	return err
+}
+
+// validateTagContent calls ValidateContent of registered tag with decoded value v.
+func (d *decoder) validateTagContent(v reflect.Value) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	tagItem := d.dm.tags.getTagItemFromType(v.Type())
+	if tagItem == nil || tagItem.opts.ValidateContent == nil || tagItem.opts.DecTag == DecTagIgnored {
+		return nil
+	}
+	if err := tagItem.opts.ValidateContent(v.Interface()); err != nil {
+		return &TagContentError{RegisteredType: tagItem.contentType, RegisteredTagNum: tagItem.num, err: err}
+	}
+	return nil
+}
+
+func (d *decoder) parseDataItemToValue(v reflect.Value, tInfo *typeInfo) error { //nolint:gocyclo
 
 	// Decode CBOR nil or CBOR undefined to pointer value by setting pointer value to nil.
 	if d.nextCBORNil() && v.Kind() == reflect.Ptr {
@@ -1372,7 +2560,9 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 			// Use value type
 			v = v.Elem()
 			tInfo = getTypeInfo(v.Type())
-		} else { //nolint:gocritic
+		} else {
+			var concreteType reflect.Type
+
 			// Create and use registered type if CBOR data is registered tag
 			if d.dm.tags != nil && d.nextCBORType() == cborTypeTag {
 
@@ -1388,12 +2578,21 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 				if registeredType != nil {
 					if registeredType.Implements(tInfo.nonPtrType) ||
 						reflect.PtrTo(registeredType).Implements(tInfo.nonPtrType) {
-						v.Set(reflect.New(registeredType))
-						v = v.Elem()
-						tInfo = getTypeInfo(registeredType)
+						concreteType = registeredType
 					}
 				}
 			}
+
+			// Create and use fallback type if CBOR data isn't registered tag
+			if concreteType == nil && !d.nextCBORNil() {
+				concreteType = d.dm.interfaceFallbackTypes.get(tInfo.nonPtrType)
+			}
+
+			if concreteType != nil {
+				v.Set(reflect.New(concreteType))
+				v = v.Elem()
+				tInfo = getTypeInfo(concreteType)
+			}
 		}
 	}
 
@@ -1431,12 +2630,26 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 	}
 	d.off = off
 
+	// Unwrap tag 24 (encoded CBOR data item) and decode enclosed data item.
+	if d.dm.encodedItem == EncodedItemDecodeUnwrap &&
+		(tInfo.spclType == specialTypeNone || tInfo.spclType == specialTypeTime) &&
+		d.nextCBOREncodedItem() &&
+		d.getRegisteredTagItem(tInfo.nonPtrType) == nil {
+		nd, err := d.nextEncodedItemDecoder()
+		if err != nil {
+			return err
+		}
+		return nd.parseToValue(v, tInfo)
+	}
+
 	if tInfo.spclType != specialTypeNone {
 		switch tInfo.spclType {
 		case specialTypeEmptyIface:
 			iv, err := d.parse(false) // Skipped self-described CBOR tag number already.
 			if iv != nil {
 				v.Set(reflect.ValueOf(iv))
+			} else if err == nil && d.dm.nilValue != NilValueNoOp && !v.IsNil() {
+				v.Set(reflect.Zero(v.Type()))
 			}
 			return err
 
@@ -1445,9 +2658,9 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 
 		case specialTypeTime:
 			if d.nextCBORNil() {
-				// Decoding CBOR null and undefined to time.Time is no-op.
+				// Decoding CBOR null and undefined to time.Time is no-op by default.
 				d.skip()
-				return nil
+				return d.fillNilValue(v)
 			}
 			tm, ok, err := d.parseToTime()
 			if err != nil {
@@ -1459,6 +2672,10 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 			return nil
 
 		case specialTypeUnmarshalerIface:
+			if tInfo.nonPtrType == typeRawMessage && d.zeroCopy() {
+				v.SetBytes(d.data[d.off:d.nextDataItemEnd()])
+				return nil
+			}
 			return d.parseToUnmarshaler(v)
 		}
 	}
@@ -1480,11 +2697,27 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 		}
 	}
 
+	if tInfo.nonPtrType == typeDuration {
+		if ok, err := d.parseToDuration(v); ok {
+			return err
+		}
+	}
+
+	if tInfo.nonPtrType == typeJSONNumber {
+		if ok, err := d.parseToJSONNumber(v); ok {
+			return err
+		}
+	}
+
 	t := d.nextCBORType()
 
 	switch t {
 	case cborTypePositiveInt:
 		_, _, val := d.getHead()
+		if tInfo.nonPtrType == typeBigFloat {
+			v.Set(reflect.ValueOf(*d.newBigFloat().SetUint64(val)))
+			return nil
+		}
 		return fillPositiveInt(t, val, v)
 
 	case cborTypeNegativeInt:
@@ -1500,6 +2733,10 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 				v.Set(reflect.ValueOf(*bi))
 				return nil
 			}
+			if tInfo.nonPtrType == typeBigFloat {
+				v.Set(reflect.ValueOf(*d.newBigFloat().SetInt(bi)))
+				return nil
+			}
 			return &UnmarshalTypeError{
 				CBORType: t.String(),
 				GoType:   tInfo.nonPtrType.String(),
@@ -1507,6 +2744,10 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 			}
 		}
 		nValue := int64(-1) ^ int64(val)
+		if tInfo.nonPtrType == typeBigFloat {
+			v.Set(reflect.ValueOf(*d.newBigFloat().SetInt64(nValue)))
+			return nil
+		}
 		return fillNegativeInt(t, nValue, v)
 
 	case cborTypeByteString:
@@ -1516,28 +2757,44 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 			return err
 		}
 		copied = copied || converted
-		return fillByteString(t, b, !copied, v, d.dm.byteStringToString, d.dm.binaryUnmarshaler)
+		if !copied && d.reuseByteSlice(b, v) {
+			return nil
+		}
+		return fillByteString(t, b, !copied && !d.zeroCopy(), v, d.dm.byteStringToString, d.dm.binaryUnmarshaler)
 
 	case cborTypeTextString:
 		b, err := d.parseTextString()
 		if err != nil {
 			return err
 		}
-		return fillTextString(t, b, v)
+		b = d.normalizeTextString(b)
+		if names, ok := d.dm.stringerEnumValues[tInfo.nonPtrType]; ok {
+			return fillStringerEnum(t, b, v, names)
+		}
+		return fillTextString(t, b, v, d.dm.textStringToByteSlice)
 
 	case cborTypePrimitives:
 		_, ai, val := d.getHead()
 		switch ai {
 		case additionalInformationAsFloat16:
 			f := float64(float16.Frombits(uint16(val)).Float32())
+			if tInfo.nonPtrType == typeBigFloat {
+				return d.fillBigFloat(t, f, v)
+			}
 			return fillFloat(t, f, v)
 
 		case additionalInformationAsFloat32:
 			f := float64(math.Float32frombits(uint32(val)))
+			if tInfo.nonPtrType == typeBigFloat {
+				return d.fillBigFloat(t, f, v)
+			}
 			return fillFloat(t, f, v)
 
 		case additionalInformationAsFloat64:
 			f := math.Float64frombits(val)
+			if tInfo.nonPtrType == typeBigFloat {
+				return d.fillBigFloat(t, f, v)
+			}
 			return fillFloat(t, f, v)
 
 		default: // ai <= 24
@@ -1547,6 +2804,10 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 					Message:  "simple value " + strconv.FormatInt(int64(val), 10) + " is not recognized",
 				}
 			}
+			if analog, ok := d.dm.simpleValues.analogs[SimpleValue(val)]; ok && analog.Type().AssignableTo(v.Type()) {
+				v.Set(analog)
+				return nil
+			}
 
 			switch ai {
 			case additionalInformationAsFalse,
@@ -1555,7 +2816,7 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 
 			case additionalInformationAsNull,
 				additionalInformationAsUndefined:
-				return fillNil(t, v)
+				return d.fillNilValue(v)
 
 			default:
 				return fillPositiveInt(t, val, v)
@@ -1574,8 +2835,12 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 				v.Set(reflect.ValueOf(*bi))
 				return nil
 			}
+			if tInfo.nonPtrType == typeBigFloat {
+				v.Set(reflect.ValueOf(*d.newBigFloat().SetInt(bi)))
+				return nil
+			}
 			if tInfo.nonPtrKind == reflect.Slice || tInfo.nonPtrKind == reflect.Array {
-				return fillByteString(t, b, !copied, v, ByteStringToStringForbidden, d.dm.binaryUnmarshaler)
+				return fillByteString(t, b, !copied && !d.zeroCopy(), v, ByteStringToStringForbidden, d.dm.binaryUnmarshaler)
 			}
 			if bi.IsUint64() {
 				return fillPositiveInt(t, bi.Uint64(), v)
@@ -1597,8 +2862,12 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 				v.Set(reflect.ValueOf(*bi))
 				return nil
 			}
+			if tInfo.nonPtrType == typeBigFloat {
+				v.Set(reflect.ValueOf(*d.newBigFloat().SetInt(bi)))
+				return nil
+			}
 			if tInfo.nonPtrKind == reflect.Slice || tInfo.nonPtrKind == reflect.Array {
-				return fillByteString(t, b, !copied, v, ByteStringToStringForbidden, d.dm.binaryUnmarshaler)
+				return fillByteString(t, b, !copied && !d.zeroCopy(), v, ByteStringToStringForbidden, d.dm.binaryUnmarshaler)
 			}
 			if bi.IsInt64() {
 				return fillNegativeInt(t, bi.Int64(), v)
@@ -1609,6 +2878,18 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 				errorMsg: bi.String() + " overflows " + v.Type().String(),
 			}
 
+		case tagNumDecimalFraction, tagNumBigFloat:
+			// Decimal fraction (tag 4) and bigfloat (tag 5) can be decoded to big.Rat or big.Float.
+			if tInfo.nonPtrType == typeBigRat || tInfo.nonPtrType == typeBigFloat {
+				return d.parseExponentMantissaToValue(tagNum, v, tInfo)
+			}
+
+		case tagNumRational:
+			// Rational number (tag 30) can be decoded to big.Rat or big.Float.
+			if tInfo.nonPtrType == typeBigRat || tInfo.nonPtrType == typeBigFloat {
+				return d.parseRationalToValue(v, tInfo)
+			}
+
 		case tagNumExpectedLaterEncodingBase64URL, tagNumExpectedLaterEncodingBase64, tagNumExpectedLaterEncodingBase16:
 			// If conversion for interoperability with text encodings is not configured,
 			// treat tags 21-23 as unregistered tags.
@@ -1648,9 +2929,9 @@ func (d *decoder) parseToValue(v reflect.Value, tInfo *typeInfo) error { //nolin
 
 func (d *decoder) parseToTag(v reflect.Value) error {
 	if d.nextCBORNil() {
-		// Decoding CBOR null and undefined to cbor.Tag is no-op.
+		// Decoding CBOR null and undefined to cbor.Tag is no-op by default.
 		d.skip()
-		return nil
+		return d.fillNilValue(v)
 	}
 
 	t := d.nextCBORType()
@@ -1677,6 +2958,27 @@ func (d *decoder) parseToTag(v reflect.Value) error {
 func (d *decoder) parseToTime() (time.Time, bool, error) {
 	// Verify that tag number or absence of tag number is acceptable to specified timeTag.
 	if t := d.nextCBORType(); t == cborTypeTag {
+		// Extended time (tag 1001) and dates (tag 100 and 1004) are always decoded,
+		// regardless of timeTag.
+		off := d.off
+		_, _, tagNum := d.getHead()
+		switch tagNum {
+		case tagNumExtendedTime:
+			secs, nsecs, err := d.parseExtendedTime(typeTime)
+			if err != nil {
+				return time.Time{}, false, err
+			}
+			return time.Unix(secs, nsecs), true, nil
+
+		case tagNumDays, tagNumFullDate:
+			date, err := d.parseDate(tagNum, typeTime)
+			if err != nil {
+				return time.Time{}, false, err
+			}
+			return date.In(time.UTC), true, nil
+		}
+		d.off = off
+
 		if d.dm.timeTag == DecTagIgnored {
 			// Skip all enclosing tags
 			for t == cborTypeTag {
@@ -1785,29 +3087,348 @@ func (d *decoder) parseToTime() (time.Time, bool, error) {
 	}
 }
 
-// parseToUnmarshaler parses CBOR data to value implementing Unmarshaler interface.
-// It assumes data is well-formed, and does not perform bounds checking.
-func (d *decoder) parseToUnmarshaler(v reflect.Value) error {
-	if d.nextCBORNil() && v.Kind() == reflect.Ptr && v.IsNil() {
-		d.skip()
-		return nil
+// parseToDuration decodes duration (tag 1002) to time.Duration v.  If DurationDecSeconds
+// is specified, it also decodes CBOR integer and floating-point number as seconds.
+// It returns false without moving cursor if the next data item isn't handled.
+func (d *decoder) parseToDuration(v reflect.Value) (bool, error) {
+	t := d.nextCBORType()
+	switch t {
+	case cborTypeTag:
+		off := d.off
+		if _, _, tagNum := d.getHead(); tagNum != tagNumDuration {
+			d.off = off
+			return false, nil
+		}
+		secs, nsecs, err := d.parseExtendedTime(typeDuration)
+		if err != nil {
+			return true, err
+		}
+		return true, setDuration(v, t, secs, nsecs)
+
+	case cborTypePositiveInt, cborTypeNegativeInt:
+		if d.dm.duration != DurationDecSeconds {
+			return false, nil
+		}
+		secs, err := d.parseInt64(typeDuration)
+		if err != nil {
+			return true, err
+		}
+		return true, setDuration(v, t, secs, 0)
+
+	case cborTypePrimitives:
+		if d.dm.duration != DurationDecSeconds {
+			return false, nil
+		}
+		f, ok := d.parseFloat()
+		if !ok {
+			return false, nil
+		}
+		secs, nsecs, ok := floatToSeconds(f)
+		if !ok {
+			return true, &UnmarshalTypeError{
+				CBORType: t.String(),
+				GoType:   typeDuration.String(),
+				errorMsg: strconv.FormatFloat(f, 'g', -1, 64) + " overflows Go's time.Duration",
+			}
+		}
+		return true, setDuration(v, t, secs, nsecs)
+	}
+	return false, nil
+}
+
+func setDuration(v reflect.Value, t cborType, secs int64, nsecs int64) error {
+	if secs > math.MaxInt64/int64(time.Second) ||
+		secs < math.MinInt64/int64(time.Second) ||
+		(secs > 0 && nsecs > math.MaxInt64-secs*int64(time.Second)) {
+		return &UnmarshalTypeError{
+			CBORType: t.String(),
+			GoType:   typeDuration.String(),
+			errorMsg: strconv.FormatInt(secs, 10) + " seconds overflows Go's time.Duration",
+		}
+	}
+	v.SetInt(secs*int64(time.Second) + nsecs)
+	return nil
+}
+
+// parseExtendedTime decodes content of extended time (tag 1001) or duration (tag 1002)
+// defined in RFC 9581, and returns seconds and non-negative nanoseconds.  Base time (map
+// key 1) can be integer or floating-point number, and fractional seconds (map key -3, -6,
+// or -9) can be used with integer base time.  Other critical (negative) keys are rejected,
+// and other elective keys are ignored.
+func (d *decoder) parseExtendedTime(goType reflect.Type) (secs int64, nsecs int64, err error) {
+	tagNum := tagNumExtendedTime
+	if goType == typeDuration {
+		tagNum = tagNumDuration
+	}
+
+	start := d.off
+	t := d.nextCBORType()
+	if t != cborTypeMap {
+		d.skip()
+		return 0, 0, &UnmarshalTypeError{
+			CBORType: t.String(),
+			GoType:   goType.String(),
+			errorMsg: "tag number " + strconv.Itoa(tagNum) + " must be followed by map",
+		}
+	}
+
+	_, _, val, indefiniteLength := d.getHeadWithIndefiniteLengthFlag()
+	count := int(val)
+	if indefiniteLength {
+		count = d.numOfItemsUntilBreak() / 2
+	}
+
+	var hasBase, hasFraction, floatBase bool
+	for i := 0; i < count; i++ {
+		kt := d.nextCBORType()
+		if kt != cborTypePositiveInt && kt != cborTypeNegativeInt {
+			// Skip elective key and value.
+			d.skip()
+			d.skip()
+			continue
+		}
+		key, err := d.parseInt64(goType)
+		if err != nil {
+			if kt == cborTypeNegativeInt {
+				d.off = start
+				d.skip()
+				return 0, 0, errors.New("cbor: unsupported critical key in tag number " + strconv.Itoa(tagNum))
+			}
+			d.skip() // Skip value of elective key
+			continue
+		}
+
+		switch key {
+		case 1:
+			vt := d.nextCBORType()
+			switch vt {
+			case cborTypePositiveInt, cborTypeNegativeInt:
+				secs, err = d.parseInt64(goType)
+				if err != nil {
+					d.off = start
+					d.skip()
+					return 0, 0, err
+				}
+			case cborTypePrimitives:
+				f, ok := d.parseFloat()
+				if !ok {
+					d.off = start
+					d.skip()
+					return 0, 0, &UnmarshalTypeError{CBORType: vt.String(), GoType: goType.String()}
+				}
+				secs, nsecs, ok = floatToSeconds(f)
+				if !ok {
+					d.off = start
+					d.skip()
+					return 0, 0, &UnmarshalTypeError{
+						CBORType: vt.String(),
+						GoType:   goType.String(),
+						errorMsg: strconv.FormatFloat(f, 'g', -1, 64) + " overflows Go's int64",
+					}
+				}
+				floatBase = true
+			default:
+				d.off = start
+				d.skip()
+				return 0, 0, &UnmarshalTypeError{CBORType: vt.String(), GoType: goType.String()}
+			}
+			hasBase = true
+
+		case -3, -6, -9:
+			vt := d.nextCBORType()
+			if vt != cborTypePositiveInt || hasFraction {
+				d.off = start
+				d.skip()
+				return 0, 0, errors.New("cbor: invalid fractional seconds in tag number " + strconv.Itoa(tagNum))
+			}
+			_, _, frac := d.getHead()
+			digits := -key
+			scale := uint64(1)
+			for j := int64(0); j < 9-digits; j++ {
+				scale *= 10
+			}
+			if frac >= 1e9/scale {
+				d.off = start
+				d.skip()
+				return 0, 0, errors.New("cbor: invalid fractional seconds in tag number " + strconv.Itoa(tagNum))
+			}
+			nsecs = int64(frac * scale)
+			hasFraction = true
+
+		default:
+			if key < 0 {
+				d.off = start
+				d.skip()
+				return 0, 0, errors.New("cbor: unsupported critical key " + strconv.FormatInt(key, 10) + " in tag number " + strconv.Itoa(tagNum))
+			}
+			d.skip() // Skip value of elective key
+		}
+	}
+	if indefiniteLength {
+		d.off++ // Skip break code
+	}
+
+	if !hasBase {
+		return 0, 0, errors.New("cbor: missing base time (key 1) in tag number " + strconv.Itoa(tagNum))
+	}
+	if floatBase && hasFraction {
+		return 0, 0, errors.New("cbor: fractional seconds can't be used with floating-point base time in tag number " + strconv.Itoa(tagNum))
+	}
+	return secs, nsecs, nil
+}
+
+// parseInt64 decodes CBOR integer to int64.  It assumes next data item is CBOR integer.
+func (d *decoder) parseInt64(goType reflect.Type) (int64, error) {
+	t, _, val := d.getHead()
+	if val > math.MaxInt64 {
+		return 0, &UnmarshalTypeError{
+			CBORType: t.String(),
+			GoType:   goType.String(),
+			errorMsg: Integer{Value: val, Negative: t == cborTypeNegativeInt}.String() + " overflows Go's int64",
+		}
+	}
+	if t == cborTypeNegativeInt {
+		return int64(-1) ^ int64(val), nil
+	}
+	return int64(val), nil
+}
+
+// parseFloat decodes CBOR floating-point number to float64, and returns false
+// without moving cursor if next data item isn't CBOR floating-point number.
+func (d *decoder) parseFloat() (float64, bool) {
+	off := d.off
+	_, ai, val := d.getHead()
+	switch ai {
+	case additionalInformationAsFloat16:
+		return float64(float16.Frombits(uint16(val)).Float32()), true
+
+	case additionalInformationAsFloat32:
+		return float64(math.Float32frombits(uint32(val))), true
+
+	case additionalInformationAsFloat64:
+		return math.Float64frombits(val), true
+	}
+	d.off = off
+	return 0, false
+}
+
+// floatToSeconds converts f to seconds and non-negative nanoseconds (rounded to
+// nearest nanosecond).  It returns false if f is NaN, infinity, or overflows int64.
+func floatToSeconds(f float64) (secs int64, nsecs int64, ok bool) {
+	if math.IsNaN(f) || f < -(1<<63) || f >= 1<<63 {
+		return 0, 0, false
+	}
+	s, frac := math.Modf(f)
+	secs, nsecs = int64(s), int64(math.Round(frac*1e9))
+	if nsecs < 0 {
+		secs--
+		nsecs += 1e9
+	}
+	if nsecs >= 1e9 {
+		secs++
+		nsecs -= 1e9
+	}
+	return secs, nsecs, true
+}
+
+// parseToUnmarshaler parses CBOR data to value implementing Unmarshaler interface.
+// It assumes data is well-formed, and does not perform bounds checking.
+func (d *decoder) parseToUnmarshaler(v reflect.Value) error {
+	if d.nextCBORNil() && v.Kind() == reflect.Ptr && v.IsNil() {
+		d.skip()
+		return nil
+	}
+
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		v = v.Addr()
+	}
+	if u, ok := v.Interface().(UnmarshalerWithMode); ok {
+		start := d.off
+		d.skip()
+		return u.UnmarshalCBORWithMode(d.data[start:d.off], d.dm)
+	}
+	if u, ok := v.Interface().(Unmarshaler); ok {
+		start := d.off
+		d.skip()
+		return u.UnmarshalCBOR(d.data[start:d.off])
+	}
+	d.skip()
+	return errors.New("cbor: failed to assert " + v.Type().String() + " as cbor.Unmarshaler")
+}
+
+// parseToRawField copies the next CBOR data item to byte slice v (struct field with "raw" option).
+// If zero copy is enabled, v shares memory with the input data instead.
+func (d *decoder) parseToRawField(v reflect.Value) {
+	if d.zeroCopy() {
+		v.SetBytes(d.data[d.off:d.nextDataItemEnd()])
+		return
+	}
+	v.SetBytes(d.nextRawMessage())
+}
+
+// nextDataItemEnd moves cursor past the next CBOR data item and returns the new offset.
+func (d *decoder) nextDataItemEnd() int {
+	d.skip()
+	return d.off
+}
+
+// zeroCopy returns true if decoded byte slices can share memory with the input data.
+func (d *decoder) zeroCopy() bool {
+	return d.dm.zeroCopy == ZeroCopyBytes && !d.copyBytes
+}
+
+// uint64ToAny returns val in an empty interface, using preallocated value
+// if allowed by SmallValueToAny.
+func (d *decoder) uint64ToAny(val uint64) interface{} {
+	if d.dm.smallValueToAny != SmallValueToAnyAllocate && val <= maxCachedInt {
+		cachedIntsOnce.Do(initCachedInts)
+		return cachedUints[val]
+	}
+	return val
+}
+
+// int64ToAny returns val in an empty interface, using preallocated value
+// if allowed by SmallValueToAny.
+func (d *decoder) int64ToAny(val int64) interface{} {
+	if d.dm.smallValueToAny != SmallValueToAnyAllocate && val >= minCachedInt && val <= maxCachedInt {
+		cachedIntsOnce.Do(initCachedInts)
+		return cachedInts[val-minCachedInt]
 	}
+	return val
+}
 
-	if v.Kind() != reflect.Ptr && v.CanAddr() {
-		v = v.Addr()
+// stringToAny returns b as string in an empty interface, reusing previously
+// decoded value if allowed by SmallValueToAny.
+func (d *decoder) stringToAny(b []byte) interface{} {
+	if d.dm.smallValueToAny != SmallValueToAnyCacheIntegersAndStrings || len(b) > maxCachedStringLen {
+		return string(b)
 	}
-	if u, ok := v.Interface().(Unmarshaler); ok {
-		start := d.off
-		d.skip()
-		return u.UnmarshalCBOR(d.data[start:d.off])
+	if v, ok := d.cachedStrings[string(b)]; ok {
+		return v
 	}
-	d.skip()
-	return errors.New("cbor: failed to assert " + v.Type().String() + " as cbor.Unmarshaler")
+	s := string(b)
+	var v interface{} = s
+	if len(d.cachedStrings) < maxCachedStrings {
+		if d.cachedStrings == nil {
+			d.cachedStrings = make(map[string]interface{})
+		}
+		d.cachedStrings[s] = v
+	}
+	return v
 }
 
 // parse parses CBOR data and returns value in default Go type.
 // It assumes data is well-formed, and does not perform bounds checking.
-func (d *decoder) parse(skipSelfDescribedTag bool) (interface{}, error) { //nolint:gocyclo
+func (d *decoder) parse(skipSelfDescribedTag bool) (interface{}, error) {
+	off := d.off
+	v, err := d.parseDataItem(skipSelfDescribedTag)
+	if err != nil {
+		setErrorOffset(err, off)
+	}
+	return v, err
+}
+
+func (d *decoder) parseDataItem(skipSelfDescribedTag bool) (interface{}, error) { //nolint:gocyclo
 	// Strip self-described CBOR tag number.
 	if skipSelfDescribedTag {
 		for d.nextCBORType() == cborTypeTag {
@@ -1838,7 +3459,7 @@ func (d *decoder) parse(skipSelfDescribedTag bool) (interface{}, error) { //noli
 
 		switch d.dm.intDec {
 		case IntDecConvertNone:
-			return val, nil
+			return d.uint64ToAny(val), nil
 
 		case IntDecConvertSigned, IntDecConvertSignedOrFail:
 			if val > math.MaxInt64 {
@@ -1849,7 +3470,7 @@ func (d *decoder) parse(skipSelfDescribedTag bool) (interface{}, error) { //noli
 				}
 			}
 
-			return int64(val), nil
+			return d.int64ToAny(int64(val)), nil
 
 		case IntDecConvertSignedOrBigInt:
 			if val > math.MaxInt64 {
@@ -1860,7 +3481,24 @@ func (d *decoder) parse(skipSelfDescribedTag bool) (interface{}, error) { //noli
 				return *bi, nil
 			}
 
-			return int64(val), nil
+			return d.int64ToAny(int64(val)), nil
+
+		case IntDecConvertInteger:
+			return Integer{Value: val}, nil
+
+		case IntDecConvertSignedOrFloat64:
+			if val > math.MaxInt64 {
+				return float64(val), nil
+			}
+
+			return d.int64ToAny(int64(val)), nil
+
+		case IntDecConvertSignedOrUint64:
+			if val > math.MaxInt64 {
+				return val, nil
+			}
+
+			return d.int64ToAny(int64(val)), nil
 
 		default:
 			// not reachable
@@ -1869,7 +3507,15 @@ func (d *decoder) parse(skipSelfDescribedTag bool) (interface{}, error) { //noli
 	case cborTypeNegativeInt:
 		_, _, val := d.getHead()
 
+		if d.dm.intDec == IntDecConvertInteger {
+			return Integer{Value: val, Negative: true}, nil
+		}
+
 		if val > math.MaxInt64 {
+			if d.dm.intDec == IntDecConvertSignedOrFloat64 {
+				return -1 - float64(val), nil
+			}
+
 			// CBOR negative integer value overflows Go int64, use big.Int instead.
 			bi := new(big.Int).SetUint64(val)
 			bi.Add(bi, big.NewInt(1))
@@ -1890,7 +3536,7 @@ func (d *decoder) parse(skipSelfDescribedTag bool) (interface{}, error) { //noli
 		}
 
 		nValue := int64(-1) ^ int64(val)
-		return nValue, nil
+		return d.int64ToAny(nValue), nil
 
 	case cborTypeByteString:
 		b, copied := d.parseByteString()
@@ -1906,7 +3552,7 @@ func (d *decoder) parse(skipSelfDescribedTag bool) (interface{}, error) { //noli
 
 		switch effectiveByteStringType {
 		case typeByteSlice:
-			if copied {
+			if copied || d.zeroCopy() {
 				return b, nil
 			}
 			clone := make([]byte, len(b))
@@ -1932,7 +3578,7 @@ func (d *decoder) parse(skipSelfDescribedTag bool) (interface{}, error) { //noli
 		if err != nil {
 			return nil, err
 		}
-		return string(b), nil
+		return d.stringToAny(d.normalizeTextString(b)), nil
 
 	case cborTypeTag:
 		tagOff := d.off
@@ -2001,6 +3647,16 @@ func (d *decoder) parse(skipSelfDescribedTag bool) (interface{}, error) { //noli
 			}
 			return *bi, nil
 
+		case tagNumEncodedCBORDataItem:
+			if d.dm.encodedItem == EncodedItemDecodeUnwrap {
+				d.off = tagOff
+				nd, err := d.nextEncodedItemDecoder()
+				if err != nil {
+					return nil, err
+				}
+				return nd.parse(true)
+			}
+
 		case tagNumExpectedLaterEncodingBase64URL, tagNumExpectedLaterEncodingBase64, tagNumExpectedLaterEncodingBase16:
 			// If conversion for interoperability with text encodings is not configured,
 			// treat tags 21-23 as unregistered tags.
@@ -2032,6 +3688,15 @@ func (d *decoder) parse(skipSelfDescribedTag bool) (interface{}, error) { //noli
 			}
 		}
 
+		if d.dm.unrecognizedTagToAny == UnrecognizedTagForbidden {
+			d.off = tagOff
+			d.skip()
+			return nil, &UnacceptableDataItemError{
+				CBORType: t.String(),
+				Message:  "tag number " + strconv.FormatUint(tagNum, 10) + " is not recognized",
+			}
+		}
+
 		// Parse tag content
 		d.off = contentOff
 		content, err := d.parse(false)
@@ -2052,9 +3717,23 @@ func (d *decoder) parse(skipSelfDescribedTag bool) (interface{}, error) { //noli
 			}
 		}
 		if ai < 20 || ai == 24 {
+			if analog, ok := d.dm.simpleValues.analogs[SimpleValue(val)]; ok {
+				return analog.Interface(), nil
+			}
 			return SimpleValue(val), nil
 		}
 
+		switch d.dm.simpleValueToAny {
+		case SimpleValueToSimpleValue:
+			if ai <= additionalInformationAsUndefined {
+				return SimpleValue(val), nil
+			}
+		case SimpleValueUndefinedToSimpleValue:
+			if ai == additionalInformationAsUndefined {
+				return SimpleValue(val), nil
+			}
+		}
+
 		switch ai {
 		case additionalInformationAsFalse,
 			additionalInformationAsTrue:
@@ -2202,8 +3881,8 @@ func (d *decoder) parseTextString() ([]byte, error) {
 	if !indefiniteLength {
 		b := d.data[d.off : d.off+int(val)]
 		d.off += int(val)
-		if d.dm.utf8 == UTF8RejectInvalid && !utf8.Valid(b) {
-			return nil, &SemanticError{"cbor: invalid UTF-8 string"}
+		if d.rejectInvalidUTF8() && !utf8.Valid(b) {
+			return nil, &SemanticError{msg: "cbor: invalid UTF-8 string"}
 		}
 		return b, nil
 	}
@@ -2213,17 +3892,39 @@ func (d *decoder) parseTextString() ([]byte, error) {
 		_, _, val = d.getHead()
 		x := d.data[d.off : d.off+int(val)]
 		d.off += int(val)
-		if d.dm.utf8 == UTF8RejectInvalid && !utf8.Valid(x) {
+		if d.rejectInvalidUTF8() && !utf8.Valid(x) {
 			for !d.foundBreak() {
 				d.skip() // Skip remaining chunk on error
 			}
-			return nil, &SemanticError{"cbor: invalid UTF-8 string"}
+			return nil, &SemanticError{msg: "cbor: invalid UTF-8 string"}
 		}
 		b = append(b, x...)
 	}
 	return b, nil
 }
 
+// normalizeTextString returns text string b normalized by UnicodeNormalization option,
+// or b if the option isn't set.
+func (d *decoder) normalizeTextString(b []byte) []byte {
+	if d.dm.unicodeNormalization == nil {
+		return b
+	}
+	return []byte(d.dm.unicodeNormalization.fn(string(b)))
+}
+
+// rejectInvalidUTF8 returns true if CBOR Text containing invalid UTF-8 should be rejected.
+func (d *decoder) rejectInvalidUTF8() bool {
+	if d.parsingMapKey {
+		switch d.dm.mapKeyUTF8 {
+		case MapKeyUTF8RejectInvalid:
+			return true
+		case MapKeyUTF8DecodeInvalid:
+			return false
+		}
+	}
+	return d.dm.utf8 == UTF8RejectInvalid
+}
+
 func (d *decoder) parseArray() ([]interface{}, error) {
 	_, _, val, indefiniteLength := d.getHeadWithIndefiniteLengthFlag()
 	hasSize := !indefiniteLength
@@ -2253,12 +3954,16 @@ func (d *decoder) parseArrayToSlice(v reflect.Value, tInfo *typeInfo) error {
 	if !hasSize {
 		count = d.numOfItemsUntilBreak() // peek ahead to get array size to preallocate slice for better performance
 	}
-	if v.IsNil() || v.Cap() < count || count == 0 {
+	reuse := d.dm.reuse == ReuseContainers && !v.IsNil() && v.Cap() >= count
+	if !reuse && (v.IsNil() || v.Cap() < count || count == 0) {
 		v.Set(reflect.MakeSlice(tInfo.nonPtrType, count, count))
 	}
 	v.SetLen(count)
 	var err error
 	for i := 0; (hasSize && i < count) || (!hasSize && !d.foundBreak()); i++ {
+		if reuse {
+			resetValue(v.Index(i))
+		}
 		if lastErr := d.parseToValue(v.Index(i), tInfo.elemTypeInfo); lastErr != nil {
 			if err == nil {
 				err = lastErr
@@ -2275,8 +3980,12 @@ func (d *decoder) parseArrayToArray(v reflect.Value, tInfo *typeInfo) error {
 	gi := 0
 	vLen := v.Len()
 	var err error
-	for ci := 0; (hasSize && ci < count) || (!hasSize && !d.foundBreak()); ci++ {
+	ci := 0
+	for ; (hasSize && ci < count) || (!hasSize && !d.foundBreak()); ci++ {
 		if gi < vLen {
+			if d.dm.reuse == ReuseContainers {
+				resetValue(v.Index(gi))
+			}
 			// Read CBOR array element and set array element
 			if lastErr := d.parseToValue(v.Index(gi), tInfo.elemTypeInfo); lastErr != nil {
 				if err == nil {
@@ -2295,6 +4004,9 @@ func (d *decoder) parseArrayToArray(v reflect.Value, tInfo *typeInfo) error {
 			v.Index(gi).Set(zeroV)
 		}
 	}
+	if err == nil && ci != vLen && d.dm.arrayLength == ArrayLengthExact {
+		return &ArrayLengthError{GoType: tInfo.nonPtrType.String(), CBORLength: ci}
+	}
 	return err
 }
 
@@ -2308,7 +4020,11 @@ func (d *decoder) parseMap() (interface{}, error) {
 	keyCount := 0
 	for i := 0; (hasSize && i < count) || (!hasSize && !d.foundBreak()); i++ {
 		// Parse CBOR map key.
-		if k, lastErr = d.parse(true); lastErr != nil {
+		parsingMapKey := d.parsingMapKey
+		d.parsingMapKey = true
+		k, lastErr = d.parse(true)
+		d.parsingMapKey = parsingMapKey
+		if lastErr != nil {
 			if err == nil {
 				err = lastErr
 			}
@@ -2344,7 +4060,7 @@ func (d *decoder) parseMap() (interface{}, error) {
 		m[k] = e
 
 		// Detect duplicate map key.
-		if d.dm.dupMapKey == DupMapKeyEnforcedAPF {
+		if d.dm.dupMapKey != DupMapKeyQuiet {
 			newKeyCount := len(m)
 			if newKeyCount == keyCount {
 				m[k] = nil
@@ -2380,8 +4096,9 @@ func (d *decoder) parseMapToMap(v reflect.Value, tInfo *typeInfo) error { //noli
 	keyIsInterfaceType := keyType == typeIntf // If key type is interface{}, need to check if key value is hashable.
 	var err, lastErr error
 	keyCount := v.Len()
-	var existingKeys map[interface{}]bool // Store existing map keys, used for detecting duplicate map key.
-	if d.dm.dupMapKey == DupMapKeyEnforcedAPF {
+	reuse := d.dm.reuse == ReuseContainers && keyCount > 0
+	var existingKeys map[interface{}]bool // Store existing map keys, used for detecting duplicate map key and stale map entries.
+	if d.dm.dupMapKey != DupMapKeyQuiet || reuse {
 		existingKeys = make(map[interface{}]bool, keyCount)
 		if keyCount > 0 {
 			vKeys := v.MapKeys()
@@ -2400,7 +4117,11 @@ func (d *decoder) parseMapToMap(v reflect.Value, tInfo *typeInfo) error { //noli
 			}
 			keyValue.Set(zeroKeyValue)
 		}
-		if lastErr = d.parseToValue(keyValue, tInfo.keyTypeInfo); lastErr != nil {
+		parsingMapKey := d.parsingMapKey
+		d.parsingMapKey = true
+		lastErr = d.parseToValue(keyValue, tInfo.keyTypeInfo)
+		d.parsingMapKey = parsingMapKey
+		if lastErr != nil {
 			if err == nil {
 				err = lastErr
 			}
@@ -2438,6 +4159,13 @@ func (d *decoder) parseMapToMap(v reflect.Value, tInfo *typeInfo) error { //noli
 			}
 			eleValue.Set(zeroEleValue)
 		}
+		if reuse && !reuseEle {
+			// Decode to existing map value to reuse memory allocated in it.
+			if existingEleValue := v.MapIndex(keyValue); existingEleValue.IsValid() {
+				eleValue.Set(existingEleValue)
+				resetValue(eleValue)
+			}
+		}
 		if lastErr := d.parseToValue(eleValue, tInfo.elemTypeInfo); lastErr != nil {
 			if err == nil {
 				err = lastErr
@@ -2449,7 +4177,7 @@ func (d *decoder) parseMapToMap(v reflect.Value, tInfo *typeInfo) error { //noli
 		v.SetMapIndex(keyValue, eleValue)
 
 		// Detect duplicate map key.
-		if d.dm.dupMapKey == DupMapKeyEnforcedAPF {
+		if d.dm.dupMapKey != DupMapKeyQuiet {
 			newKeyCount := v.Len()
 			if newKeyCount == keyCount {
 				kvi := keyValue.Interface()
@@ -2468,6 +4196,21 @@ func (d *decoder) parseMapToMap(v reflect.Value, tInfo *typeInfo) error { //noli
 			}
 			keyCount = newKeyCount
 		}
+
+		if reuse {
+			delete(existingKeys, keyValue.Interface())
+		}
+	}
+
+	if reuse {
+		// Delete stale map entries absent from CBOR data.
+		for k := range existingKeys {
+			kv := reflect.ValueOf(k)
+			if !kv.IsValid() {
+				kv = reflect.Zero(keyType)
+			}
+			v.SetMapIndex(kv, reflect.Value{})
+		}
 	}
 	return err
 }
@@ -2530,7 +4273,17 @@ func (d *decoder) parseArrayToStruct(v reflect.Value, tInfo *typeInfo) error {
 			}
 		}
 
-		if lastErr = d.parseToValue(fv, f.typInfo); lastErr != nil {
+		copyBytes := d.copyBytes
+		d.copyBytes = copyBytes || f.copy
+		if f.raw {
+			d.parseToRawField(fv)
+			lastErr = nil
+		} else {
+			lastErr = d.parseToValue(fv, f.typInfo)
+		}
+		d.copyBytes = copyBytes
+
+		if lastErr != nil {
 			if err == nil {
 				if typeError, ok := lastErr.(*UnmarshalTypeError); ok {
 					typeError.StructFieldName = tInfo.typ.String() + "." + f.name
@@ -2541,9 +4294,82 @@ func (d *decoder) parseArrayToStruct(v reflect.Value, tInfo *typeInfo) error {
 			}
 		}
 	}
+	if structType.origField != nil {
+		if lastErr = d.setOrigField(v, structType.origField, start); lastErr != nil && err == nil {
+			err = lastErr
+		}
+	}
 	return err
 }
 
+// skipFieldNameIgnoredTags skips tags listed in FieldNameIgnoredTags enclosing
+// map key at d.off, and returns CBOR type of the remaining data item.
+func (d *decoder) skipFieldNameIgnoredTags() cborType {
+	t := d.nextCBORType()
+	for t == cborTypeTag {
+		off := d.off
+		_, _, tagNum := d.getHead()
+		if !d.isFieldNameIgnoredTag(tagNum) {
+			d.off = off
+			break
+		}
+		t = d.nextCBORType()
+	}
+	return t
+}
+
+func (d *decoder) isFieldNameIgnoredTag(tagNum uint64) bool {
+	return d.dm.fieldNameIgnoredTags.contains(tagNum)
+}
+
+// findStructFieldIndex returns index of struct field matching CBOR map key.  Key is matched
+// exactly, then transformed by FieldNameTransform and matched exactly, and then, if
+// FieldNameMatching allows, the key and transformed key are matched case-insensitively.
+func (d *decoder) findStructFieldIndex(structType *decodingStructType, key []byte) (int, bool) {
+	// Check for exact match on field name.
+	if i, ok := structType.fieldIndicesByName[string(key)]; ok {
+		return i, true
+	}
+
+	// Check for exact match on transformed field name.
+	var keyString, transformedKey string
+	if d.dm.fieldNameTransform != nil {
+		keyString = string(key)
+		transformedKey = d.dm.fieldNameTransform.fn(keyString)
+		if i, ok := structType.fieldIndicesByName[transformedKey]; ok {
+			return i, true
+		}
+	}
+
+	if d.dm.fieldNameMatching == FieldNameMatchingCaseSensitive {
+		return -1, false
+	}
+
+	// Find field with case-insensitive match
+	if d.dm.fieldNameTransform == nil {
+		return structType.findFoldFieldIndex(string(key))
+	}
+	if i, ok := structType.findFoldFieldIndex(keyString); ok {
+		return i, true
+	}
+	if transformedKey == keyString {
+		return -1, false
+	}
+	return structType.findFoldFieldIndex(transformedKey)
+}
+
+// findFoldFieldIndex returns index of the first struct field whose name is a
+// case-insensitive match for name.
+func (st *decodingStructType) findFoldFieldIndex(name string) (int, bool) {
+	for i := 0; i < len(st.fields); i++ {
+		fld := st.fields[i]
+		if len(fld.name) == len(name) && strings.EqualFold(fld.name, name) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
 // parseMapToStruct needs to be fast so gocyclo can be ignored for now.
 func (d *decoder) parseMapToStruct(v reflect.Value, tInfo *typeInfo) error { //nolint:gocyclo
 	structType := getDecodingStructType(tInfo.nonPtrType)
@@ -2564,6 +4390,7 @@ func (d *decoder) parseMapToStruct(v reflect.Value, tInfo *typeInfo) error { //n
 	var err, lastErr error
 
 	// Get CBOR map size
+	start := d.off
 	_, _, val, indefiniteLength := d.getHeadWithIndefiniteLengthFlag()
 	hasSize := !indefiniteLength
 	count := int(val)
@@ -2588,6 +4415,17 @@ func (d *decoder) parseMapToStruct(v reflect.Value, tInfo *typeInfo) error { //n
 
 	errOnUnknownField := (d.dm.extraReturnErrors & ExtraDecErrorUnknownField) > 0
 
+	if structType.unknownField != nil && d.dm.reuse == ReuseContainers {
+		// Reset field with "unknown" option before capturing unknown map entries.
+		fv, _ := getFieldValue(v, structType.unknownField.idx, func(reflect.Value) (reflect.Value, error) {
+			// Skip null pointer to embedded struct
+			return reflect.Value{}, nil
+		})
+		if fv.IsValid() {
+			resetValue(fv)
+		}
+	}
+
 MapEntryLoop:
 	for j := 0; (hasSize && j < count) || (!hasSize && !d.foundBreak()); j++ {
 		var f *field
@@ -2596,11 +4434,21 @@ MapEntryLoop:
 		// field, k will hold the map key.
 		var k interface{}
 
+		// If struct has field with "unknown" option and the key at index j did not match
+		// any field, unknownKey will hold the map key.
+		var unknownKey interface{}
+
 		t := d.nextCBORType()
+		if t == cborTypeTag && d.dm.fieldNameIgnoredTags != nil {
+			t = d.skipFieldNameIgnoredTags()
+		}
 		if t == cborTypeTextString || (t == cborTypeByteString && d.dm.fieldNameByteString == FieldNameByteStringAllowed) {
 			var keyBytes []byte
 			if t == cborTypeTextString {
+				parsingMapKey := d.parsingMapKey
+				d.parsingMapKey = true
 				keyBytes, lastErr = d.parseTextString()
+				d.parsingMapKey = parsingMapKey
 				if lastErr != nil {
 					if err == nil {
 						err = lastErr
@@ -2612,15 +4460,14 @@ MapEntryLoop:
 				keyBytes, _ = d.parseByteString()
 			}
 
-			// Check for exact match on field name.
-			if i, ok := structType.fieldIndicesByName[string(keyBytes)]; ok {
+			if i, ok := d.findStructFieldIndex(structType, keyBytes); ok {
 				fld := structType.fields[i]
 
 				if !foundFldIdx[i] {
 					f = fld
 					foundFldIdx[i] = true
-				} else if d.dm.dupMapKey == DupMapKeyEnforcedAPF {
-					err = &DupMapKeyError{fld.name, j}
+				} else if d.dm.dupMapKey != DupMapKeyQuiet {
+					err = &DupMapKeyError{string(keyBytes), j}
 					d.skip() // skip value
 					j++
 					// skip the rest of the map
@@ -2636,89 +4483,58 @@ MapEntryLoop:
 				}
 			}
 
-			// Find field with case-insensitive match
-			if f == nil && d.dm.fieldNameMatching == FieldNameMatchingPreferCaseSensitive {
-				keyLen := len(keyBytes)
-				keyString := string(keyBytes)
-				for i := 0; i < len(structType.fields); i++ {
-					fld := structType.fields[i]
-					if len(fld.name) == keyLen && strings.EqualFold(fld.name, keyString) {
-						if !foundFldIdx[i] {
-							f = fld
-							foundFldIdx[i] = true
-						} else if d.dm.dupMapKey == DupMapKeyEnforcedAPF {
-							err = &DupMapKeyError{keyString, j}
-							d.skip() // skip value
-							j++
-							// skip the rest of the map
-							for ; (hasSize && j < count) || (!hasSize && !d.foundBreak()); j++ {
-								d.skip()
-								d.skip()
-							}
-							return err
-						} else {
-							// discard repeated match
-							d.skip()
-							continue MapEntryLoop
-						}
-						break
-					}
-				}
-			}
-
-			if d.dm.dupMapKey == DupMapKeyEnforcedAPF && f == nil {
+			if d.dm.dupMapKey != DupMapKeyQuiet && f == nil {
 				k = string(keyBytes)
 			}
+			if structType.unknownField != nil && f == nil {
+				if t == cborTypeTextString {
+					unknownKey = string(keyBytes)
+				} else {
+					unknownKey = ByteString(keyBytes)
+				}
+			}
 		} else if t <= cborTypeNegativeInt { // uint/int
-			var nameAsInt int64
+			_, _, val := d.getHead()
+			nameAsInt := Integer{Value: val, Negative: t == cborTypeNegativeInt}
 
-			if t == cborTypePositiveInt {
-				_, _, val := d.getHead()
-				nameAsInt = int64(val)
+			// Find field
+			i := -1
+			if structType.intKeyFields != nil {
+				i = structType.intKeyFields.lookup(t, val)
 			} else {
-				_, _, val := d.getHead()
-				if val > math.MaxInt64 {
-					if err == nil {
-						err = &UnmarshalTypeError{
-							CBORType: t.String(),
-							GoType:   reflect.TypeOf(int64(0)).String(),
-							errorMsg: "-1-" + strconv.FormatUint(val, 10) + " overflows Go's int64",
-						}
+				for n, fld := range structType.fields {
+					if fld.keyAsInt && fld.nameAsInt == nameAsInt {
+						i = n
+						break
 					}
-					d.skip() // skip value
-					continue
 				}
-				nameAsInt = int64(-1) ^ int64(val)
 			}
-
-			// Find field
-			for i := 0; i < len(structType.fields); i++ {
-				fld := structType.fields[i]
-				if fld.keyAsInt && fld.nameAsInt == nameAsInt {
-					if !foundFldIdx[i] {
-						f = fld
-						foundFldIdx[i] = true
-					} else if d.dm.dupMapKey == DupMapKeyEnforcedAPF {
-						err = &DupMapKeyError{nameAsInt, j}
-						d.skip() // skip value
-						j++
-						// skip the rest of the map
-						for ; (hasSize && j < count) || (!hasSize && !d.foundBreak()); j++ {
-							d.skip()
-							d.skip()
-						}
-						return err
-					} else {
-						// discard repeated match
+			if i >= 0 {
+				if !foundFldIdx[i] {
+					f = structType.fields[i]
+					foundFldIdx[i] = true
+				} else if d.dm.dupMapKey != DupMapKeyQuiet {
+					err = &DupMapKeyError{nameAsInt.key(), j}
+					d.skip() // skip value
+					j++
+					// skip the rest of the map
+					for ; (hasSize && j < count) || (!hasSize && !d.foundBreak()); j++ {
+						d.skip()
 						d.skip()
-						continue MapEntryLoop
 					}
-					break
+					return err
+				} else {
+					// discard repeated match
+					d.skip()
+					continue MapEntryLoop
 				}
 			}
 
-			if d.dm.dupMapKey == DupMapKeyEnforcedAPF && f == nil {
-				k = nameAsInt
+			if d.dm.dupMapKey != DupMapKeyQuiet && f == nil {
+				k = nameAsInt.key()
+			}
+			if structType.unknownField != nil && f == nil {
+				unknownKey = nameAsInt.key()
 			}
 		} else {
 			if err == nil {
@@ -2728,25 +4544,22 @@ MapEntryLoop:
 					errorMsg: "map key is of type " + t.String() + " and cannot be used to match struct field name",
 				}
 			}
-			if d.dm.dupMapKey == DupMapKeyEnforcedAPF {
+			if d.dm.dupMapKey != DupMapKeyQuiet {
 				// parse key
+				keyOff := d.off
 				k, lastErr = d.parse(true)
 				if lastErr != nil {
 					d.skip() // skip value
 					continue
 				}
-				// Detect if CBOR map key can be used as Go map key.
-				if !isHashableValue(reflect.ValueOf(k)) {
-					d.skip() // skip value
-					continue
-				}
+				k = comparableMapKey(k, d.data[keyOff:d.off])
 			} else {
 				d.skip() // skip key
 			}
 		}
 
 		if f == nil {
-			if errOnUnknownField {
+			if errOnUnknownField && unknownKey == nil {
 				err = &UnknownFieldError{j}
 				d.skip() // Skip value
 				j++
@@ -2762,7 +4575,7 @@ MapEntryLoop:
 			// duplicates. This check detects duplicates between two map keys that do
 			// not match a struct field. If unknown field errors are enabled, then this
 			// check is never reached.
-			if d.dm.dupMapKey == DupMapKeyEnforcedAPF {
+			if d.dm.dupMapKey != DupMapKeyQuiet {
 				if mapKeys == nil {
 					mapKeys = make(map[interface{}]struct{}, 1)
 				}
@@ -2782,6 +4595,13 @@ MapEntryLoop:
 				keyCount = newKeyCount
 			}
 
+			if unknownKey != nil {
+				if lastErr = d.parseToUnknownField(v, structType.unknownField, unknownKey); lastErr != nil && err == nil {
+					err = lastErr
+				}
+				continue
+			}
+
 			d.skip() // Skip value
 			continue
 		}
@@ -2808,7 +4628,17 @@ MapEntryLoop:
 			}
 		}
 
-		if lastErr = d.parseToValue(fv, f.typInfo); lastErr != nil {
+		copyBytes := d.copyBytes
+		d.copyBytes = copyBytes || f.copy
+		if f.raw {
+			d.parseToRawField(fv)
+			lastErr = nil
+		} else {
+			lastErr = d.parseToValue(fv, f.typInfo)
+		}
+		d.copyBytes = copyBytes
+
+		if lastErr != nil {
 			if err == nil {
 				if typeError, ok := lastErr.(*UnmarshalTypeError); ok {
 					typeError.StructFieldName = tInfo.nonPtrType.String() + "." + f.name
@@ -2819,9 +4649,163 @@ MapEntryLoop:
 			}
 		}
 	}
+
+	if d.dm.reuse == ReuseContainers {
+		// Reset struct fields absent from CBOR data.
+		for i, found := range foundFldIdx {
+			if found {
+				continue
+			}
+			f := structType.fields[i]
+			fv, _ := getFieldValue(v, f.idx, func(reflect.Value) (reflect.Value, error) {
+				// Skip null pointer to embedded struct
+				return reflect.Value{}, nil
+			})
+			if fv.IsValid() {
+				resetValue(fv)
+			}
+		}
+	}
+	if structType.origField != nil {
+		if lastErr = d.setOrigField(v, structType.origField, start); lastErr != nil && err == nil {
+			err = lastErr
+		}
+	}
+	return err
+}
+
+// setOrigField sets encoded CBOR data item from start to d.off (entire CBOR map or array
+// decoded to struct v) to struct field f with "orig" option.
+func (d *decoder) setOrigField(v reflect.Value, f *field, start int) error {
+	fv, err := getFieldValue(v, f.idx, func(v reflect.Value) (reflect.Value, error) {
+		// Return a new value for embedded field null pointer to point to, or return error.
+		if !v.CanSet() {
+			return reflect.Value{}, errors.New("cbor: cannot set embedded pointer to unexported struct: " + v.Type().String())
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		return v, nil
+	})
+	if !fv.IsValid() {
+		return err
+	}
+
+	if d.zeroCopy() && !f.copy {
+		fv.SetBytes(d.data[start:d.off])
+		return nil
+	}
+	b := make(RawMessage, d.off-start)
+	copy(b, d.data[start:d.off])
+	fv.SetBytes(b)
+	return nil
+}
+
+// parseToUnknownField sets a copy of the next CBOR data item (map value) with key to
+// the map in struct field f with "unknown" option, or decodes it to the map value type
+// for "inline" option.  Integer keys are ignored if map key type is string.
+func (d *decoder) parseToUnknownField(v reflect.Value, f *field, key interface{}) error {
+	fv, err := getFieldValue(v, f.idx, func(v reflect.Value) (reflect.Value, error) {
+		// Return a new value for embedded field null pointer to point to, or return error.
+		if !v.CanSet() {
+			return reflect.Value{}, errors.New("cbor: cannot set embedded pointer to unexported struct: " + v.Type().String())
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		return v, nil
+	})
+	if !fv.IsValid() {
+		d.skip()
+		return err
+	}
+
+	kv := reflect.ValueOf(key)
+	if keyType := fv.Type().Key(); keyType != typeIntf {
+		if kv.Kind() != reflect.String {
+			d.skip()
+			return nil
+		}
+		kv = kv.Convert(keyType)
+	}
+
+	if fv.IsNil() {
+		fv.Set(reflect.MakeMap(fv.Type()))
+	}
+	elemType := fv.Type().Elem()
+	if elemType == typeRawMessage {
+		fv.SetMapIndex(kv, reflect.ValueOf(d.nextRawMessage()))
+		return nil
+	}
+
+	// Decode value of map in field with "inline" option.
+	ev := reflect.New(elemType).Elem()
+	err = d.parseToValue(ev, getTypeInfo(elemType))
+	fv.SetMapIndex(kv, ev)
 	return err
 }
 
+// resetValue sets v to its zero value while keeping memory allocated for slices,
+// maps, and pointed-to values in v, so it can be reused by ReuseContainers.
+func resetValue(v reflect.Value) {
+	if !v.CanSet() {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		if !v.IsNil() {
+			v.SetLen(0)
+		}
+		return
+
+	case reflect.Map:
+		if v.Len() > 0 {
+			for _, k := range v.MapKeys() {
+				v.SetMapIndex(k, reflect.Value{})
+			}
+		}
+		return
+
+	case reflect.Ptr:
+		if !v.IsNil() {
+			resetValue(v.Elem())
+		}
+		return
+
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			resetValue(v.Index(i))
+		}
+		return
+
+	case reflect.Struct:
+		if isExportedStruct(v.Type()) {
+			for i := 0; i < v.NumField(); i++ {
+				resetValue(v.Field(i))
+			}
+			return
+		}
+	}
+	v.Set(reflect.Zero(v.Type()))
+}
+
+// isExportedStruct returns true if all fields of struct type t are exported.
+func isExportedStruct(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// reuseByteSlice copies b to the underlying array of []byte v if ReuseContainers is
+// specified and v has enough capacity.  It returns false if b isn't copied to v.
+func (d *decoder) reuseByteSlice(b []byte, v reflect.Value) bool {
+	if d.dm.reuse != ReuseContainers || d.zeroCopy() || v.Type() != typeByteSlice || v.IsNil() || v.Cap() < len(b) {
+		return false
+	}
+	v.SetLen(len(b))
+	copy(v.Bytes(), b)
+	return true
+}
+
 // validRegisteredTagNums verifies that tag numbers match registered tag numbers of type t.
 // validRegisteredTagNums assumes next CBOR data type is tag.  It scans all tag numbers, and stops at tag content.
 func (d *decoder) validRegisteredTagNums(registeredTag *tagItem) error {
@@ -2969,21 +4953,40 @@ func (d *decoder) nextCBORNil() bool {
 }
 
 var (
-	typeIntf              = reflect.TypeOf([]interface{}(nil)).Elem()
-	typeTime              = reflect.TypeOf(time.Time{})
-	typeBigInt            = reflect.TypeOf(big.Int{})
-	typeUnmarshaler       = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
-	typeBinaryUnmarshaler = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
-	typeString            = reflect.TypeOf("")
-	typeByteSlice         = reflect.TypeOf([]byte(nil))
+	typeIntf                = reflect.TypeOf([]interface{}(nil)).Elem()
+	typeTime                = reflect.TypeOf(time.Time{})
+	typeDuration            = reflect.TypeOf(time.Duration(0))
+	typeBigInt              = reflect.TypeOf(big.Int{})
+	typeUnmarshaler         = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	typeUnmarshalerWithMode = reflect.TypeOf((*UnmarshalerWithMode)(nil)).Elem()
+	typeBinaryUnmarshaler   = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	typeString              = reflect.TypeOf("")
+	typeByteSlice           = reflect.TypeOf([]byte(nil))
+	typeInt64               = reflect.TypeOf(int64(0))
 )
 
-func fillNil(_ cborType, v reflect.Value) error {
+// fillNilValue sets v for decoded CBOR null or undefined as specified by NilValue option.
+func (d *decoder) fillNilValue(v reflect.Value) error {
 	switch v.Kind() {
 	case reflect.Slice, reflect.Map, reflect.Interface, reflect.Ptr:
 		v.Set(reflect.Zero(v.Type()))
 		return nil
 	}
+	switch d.dm.nilValue {
+	case NilValueZero:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+
+	case NilValueForbidden:
+		return &UnmarshalTypeError{
+			CBORType: cborTypePrimitives.String(),
+			GoType:   v.Type().String(),
+			errorMsg: "null or undefined can't be decoded to non-nilable type",
+		}
+	}
+	if d.dm.reuse == ReuseContainers && v.Kind() == reflect.Struct {
+		resetValue(v)
+	}
 	return nil
 }
 
@@ -3127,14 +5130,45 @@ func fillByteString(t cborType, val []byte, shared bool, v reflect.Value, bsts B
 	return &UnmarshalTypeError{CBORType: t.String(), GoType: v.Type().String()}
 }
 
-func fillTextString(t cborType, val []byte, v reflect.Value) error {
+func fillTextString(t cborType, val []byte, v reflect.Value, tsbs TextStringToByteSliceMode) error {
 	if v.Kind() == reflect.String {
 		v.SetString(string(val))
 		return nil
 	}
+	if tsbs == TextStringToByteSliceAllowed && v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		// SetBytes shares the underlying bytes of the source slice.
+		src := make([]byte, len(val))
+		copy(src, val)
+		v.SetBytes(src)
+		return nil
+	}
 	return &UnmarshalTypeError{CBORType: t.String(), GoType: v.Type().String()}
 }
 
+func fillStringerEnum(t cborType, val []byte, v reflect.Value, names map[string]reflect.Value) error {
+	ev, ok := names[string(val)]
+	if !ok {
+		return &UnmarshalTypeError{
+			CBORType: t.String(),
+			GoType:   v.Type().String(),
+			errorMsg: "unknown enum name " + strconv.Quote(string(val)),
+		}
+	}
+	v.Set(ev)
+	return nil
+}
+
+func isIntegerKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+
+	default:
+		return false
+	}
+}
+
 func isImmutableKind(k reflect.Kind) bool {
 	switch k {
 	case reflect.Bool,
@@ -3166,6 +5200,30 @@ func isHashableValue(rv reflect.Value) bool {
 	return true
 }
 
+// encodedMapKey is CBOR encoding of a map key that can't be a Go map key (e.g. array),
+// used for detecting duplicate map keys.
+type encodedMapKey string
+
+// String returns diagnostic notation of k, which is used in DupMapKeyError message.
+func (k encodedMapKey) String() string {
+	if s, err := Diagnose([]byte(k)); err == nil {
+		return s
+	}
+	return hex.EncodeToString([]byte(k))
+}
+
+// comparableMapKey returns k if it can be a Go map key, or k with []byte converted to
+// ByteString, or CBOR encoding b of k otherwise.
+func comparableMapKey(k interface{}, b []byte) interface{} {
+	if isHashableValue(reflect.ValueOf(k)) {
+		return k
+	}
+	if converted, ok := convertByteSliceToByteString(k); ok {
+		return converted
+	}
+	return encodedMapKey(b)
+}
+
 // convertByteSliceToByteString converts []byte to ByteString if
 // - v is []byte type, or
 // - v is Tag type and tag content type is []byte
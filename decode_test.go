@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -16,6 +17,7 @@ import (
 	"strings"
 	"testing"
 	"time"
+	"unicode"
 )
 
 var (
@@ -27,7 +29,6 @@ var (
 	typeInt8            = reflect.TypeOf(int8(0))
 	typeInt16           = reflect.TypeOf(int16(0))
 	typeInt32           = reflect.TypeOf(int32(0))
-	typeInt64           = reflect.TypeOf(int64(0))
 	typeFloat32         = reflect.TypeOf(float32(0))
 	typeFloat64         = reflect.TypeOf(float64(0))
 	typeByteArray       = reflect.TypeOf([5]byte{})
@@ -4117,6 +4118,49 @@ func TestDecTimeTagOption(t *testing.T) {
 	}
 }
 
+func TestDecTimeTagToIntegerField(t *testing.T) {
+	type claims struct {
+		Exp int64  `cbor:"4,keyasint"`
+		Nbf uint64 `cbor:"5,keyasint"`
+		Iat int    `cbor:"6,keyasint"`
+	}
+	// {4: 1(1363896240), 5: 1(1363896240), 6: 1(-1)}
+	data := hexDecode("a304c11a514b67b005c11a514b67b006c120")
+	want := claims{Exp: 1363896240, Nbf: 1363896240, Iat: -1}
+
+	for _, tc := range []struct {
+		name    string
+		timeTag DecTagMode
+	}{
+		{name: "DecTagIgnored", timeTag: DecTagIgnored},
+		{name: "DecTagOptional", timeTag: DecTagOptional},
+		{name: "DecTagRequired", timeTag: DecTagRequired},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := DecOptions{TimeTag: tc.timeTag}.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned error %v", err)
+			}
+			var got claims
+			if err := dm.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+			}
+			if got != want {
+				t.Errorf("Unmarshal(0x%x) = %+v, want %+v", data, got, want)
+			}
+		})
+	}
+
+	// Tag 1 enclosing floating-point number can't be decoded to integer field.
+	data = hexDecode("a104c1fb41d452d9ec200000")
+	var got claims
+	if err := Unmarshal(data, &got); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+	} else if _, ok := err.(*UnmarshalTypeError); !ok {
+		t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*UnmarshalTypeError)", data, err)
+	}
+}
+
 func TestUnmarshalStructTag1(t *testing.T) {
 	type strc struct {
 		A string `cbor:"a"`
@@ -4425,6 +4469,76 @@ func TestMarshalerError(t *testing.T) {
 	}
 }
 
+// modeMap implements both Marshaler and MarshalerWithMode (and the equivalent
+// unmarshaler interfaces) to verify that the WithMode variants take precedence.
+type modeMap map[int]string
+
+func (m modeMap) MarshalCBOR() ([]byte, error) {
+	return nil, errors.New("MarshalCBOR shouldn't be called")
+}
+
+func (m modeMap) MarshalCBORWithMode(em EncMode) ([]byte, error) {
+	return em.Marshal(map[int]string(m))
+}
+
+func (m *modeMap) UnmarshalCBOR([]byte) error {
+	return errors.New("UnmarshalCBOR shouldn't be called")
+}
+
+func (m *modeMap) UnmarshalCBORWithMode(data []byte, dm DecMode) error {
+	return dm.Unmarshal(data, (*map[int]string)(m))
+}
+
+func TestMarshalerUnmarshalerWithMode(t *testing.T) {
+	v := modeMap{10: "a", 1: "b", 100: "c"}
+
+	em, err := EncOptions{Sort: SortCanonical}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	wantData := hexDecode("a30161620a616118646163")
+	data, err := em.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(%v) returned error %v", v, err)
+	}
+	if !bytes.Equal(data, wantData) {
+		t.Errorf("Marshal(%v) = 0x%x, want 0x%x", v, data, wantData)
+	}
+
+	// Encoding options are passed through nested values.
+	type s struct {
+		M modeMap
+	}
+	data, err = em.Marshal(s{M: v})
+	if err != nil {
+		t.Fatalf("Marshal(%v) returned error %v", v, err)
+	}
+	if want := append(hexDecode("a1614d"), wantData...); !bytes.Equal(data, want) {
+		t.Errorf("Marshal(%v) = 0x%x, want 0x%x", v, data, want)
+	}
+
+	var got modeMap
+	if err := Unmarshal(wantData, &got); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", wantData, err)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Errorf("Unmarshal(0x%x) = %v, want %v", wantData, got, v)
+	}
+
+	// Decoding options are honored by UnmarshalCBORWithMode.
+	dupData := hexDecode("a2016161016162")
+	dm, err := DecOptions{DupMapKey: DupMapKeyEnforcedAPF}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+	got = nil
+	if err := dm.Unmarshal(dupData, &got); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error", dupData)
+	} else if _, ok := err.(*DupMapKeyError); !ok {
+		t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*DupMapKeyError)", dupData, err)
+	}
+}
+
 // Found at https://github.com/oasislabs/oasis-core/blob/master/go/common/cbor/cbor_test.go
 func TestOutOfMem1(t *testing.T) {
 	data := []byte("\x9b\x00\x00000000")
@@ -4472,18 +4586,88 @@ func TestCOSEExamples(t *testing.T) {
 	}
 }
 
-func TestUnmarshalStructKeyAsIntError(t *testing.T) {
+func TestUnmarshalStructKeyAsIntOutOfInt64Range(t *testing.T) {
 	type T1 struct {
 		F1 int `cbor:"1,keyasint"`
 	}
-	data := hexDecode("a13bffffffffffffffff01") // {1: -18446744073709551616}
+	data := hexDecode("a13bffffffffffffffff01") // {-18446744073709551616: 1}
 	var v T1
-	if err := Unmarshal(data, &v); err == nil {
+	if err := Unmarshal(data, &v); err != nil {
+		t.Errorf("Unmarshal(0x%x) returned an error %v", data, err)
+	} else if v != (T1{}) {
+		t.Errorf("Unmarshal(0x%x) = %+v, want %+v", data, v, T1{})
+	}
+
+	type T2 struct {
+		Min      int `cbor:"-18446744073709551616,keyasint"`
+		MinInt64 int `cbor:"-9223372036854775809,keyasint"`
+		MaxInt64 int `cbor:"9223372036854775808,keyasint"`
+		Max      int `cbor:"18446744073709551615,keyasint"`
+		Neg      int `cbor:"-1,keyasint"`
+	}
+	want := T2{Min: 1, MinInt64: 2, MaxInt64: 3, Max: 4, Neg: 5}
+	// {-18446744073709551616: 1, -9223372036854775809: 2, 9223372036854775808: 3, 18446744073709551615: 4, -1: 5}
+	data = hexDecode("a53bffffffffffffffff013b8000000000000000021b8000000000000000031bffffffffffffffff042005")
+	b, err := Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal(%+v) returned an error %v", want, err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Errorf("Marshal(%+v) = 0x%x, want 0x%x", want, b, data)
+	}
+	var v2 T2
+	if err = Unmarshal(data, &v2); err != nil {
+		t.Errorf("Unmarshal(0x%x) returned an error %v", data, err)
+	} else if v2 != want {
+		t.Errorf("Unmarshal(0x%x) = %+v, want %+v", data, v2, want)
+	}
+}
+
+func TestUnmarshalStructKeyAsIntJumpTable(t *testing.T) {
+	type T struct {
+		A int    `cbor:"0,keyasint"`
+		B string `cbor:"-1,keyasint"`
+		C bool   `cbor:"255,keyasint"`
+		D int    `cbor:"-256,keyasint"`
+		E int    `cbor:"1,keyasint"`
+		F int    `cbor:"01,keyasint"` // same key as E
+	}
+	if getDecodingStructType(reflect.TypeOf(T{})).intKeyFields == nil {
+		t.Fatalf("%T has no jump table for keyasint fields", T{})
+	}
+
+	// {0: 1, -1: "a", 255: true, -256: 2, 1: 3, 256: 4, -257: 5, 2: 6}
+	data := hexDecode("a8000120616118fff538ff02010319010004390100050206")
+	want := T{A: 1, B: "a", C: true, D: 2, E: 3}
+	var v T
+	if err := Unmarshal(data, &v); err != nil {
+		t.Errorf("Unmarshal(0x%x) returned an error %v", data, err)
+	} else if v != want {
+		t.Errorf("Unmarshal(0x%x) = %+v, want %+v", data, v, want)
+	}
+
+	// {1: 3, 1: 4}
+	data = hexDecode("a201030104")
+	dm, _ := DecOptions{DupMapKey: DupMapKeyEnforcedAPF}.DecMode()
+	wantErrorMsg := "cbor: found duplicate map key \"1\" at map element index 1"
+	if err := dm.Unmarshal(data, &v); err == nil {
 		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
-	} else if _, ok := err.(*UnmarshalTypeError); !ok {
-		t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*UnmarshalTypeError)", data, err)
-	} else if !strings.Contains(err.Error(), "cannot unmarshal") {
-		t.Errorf("Unmarshal(0x%x) returned error %q, want error containing %q", data, err.Error(), "cannot unmarshal")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
+	}
+
+	type keyOutOfRange struct {
+		A int `cbor:"1,keyasint"`
+		B int `cbor:"-257,keyasint"`
+	}
+	type mixedKeys struct {
+		A int `cbor:"1,keyasint"`
+		B int `cbor:"b"`
+	}
+	for _, typ := range []reflect.Type{reflect.TypeOf(keyOutOfRange{}), reflect.TypeOf(mixedKeys{})} {
+		if getDecodingStructType(typ).intKeyFields != nil {
+			t.Errorf("%s has jump table for keyasint fields", typ)
+		}
 	}
 }
 
@@ -4892,6 +5076,36 @@ func TestUnmarshalToNotNilInterface(t *testing.T) {
 	}
 }
 
+// stubDecMode implements DecMode without methods of ExtendedDecMode.
+type stubDecMode struct{}
+
+func (stubDecMode) Unmarshal([]byte, interface{}) error                { return nil }
+func (stubDecMode) UnmarshalFirst([]byte, interface{}) ([]byte, error) { return nil, nil }
+func (stubDecMode) Valid([]byte) error                                 { return nil }
+func (stubDecMode) Wellformed([]byte) error                            { return nil }
+func (stubDecMode) NewDecoder(io.Reader) *Decoder                      { return nil }
+func (stubDecMode) DecOptions() DecOptions                             { return DecOptions{} }
+
+var _ DecMode = stubDecMode{}
+
+func TestExtendedDecMode(t *testing.T) {
+	tags := NewTagSet()
+	modes := map[string]func() (DecMode, error){
+		"DecMode":               DecOptions{}.DecMode,
+		"DecModeWithTags":       func() (DecMode, error) { return DecOptions{}.DecModeWithTags(tags) },
+		"DecModeWithSharedTags": func() (DecMode, error) { return DecOptions{}.DecModeWithSharedTags(tags) },
+	}
+	for name, fn := range modes {
+		dm, err := fn()
+		if err != nil {
+			t.Fatalf("%s() returned error %v", name, err)
+		}
+		if _, ok := dm.(ExtendedDecMode); !ok {
+			t.Errorf("%s() returned %T, want ExtendedDecMode", name, dm)
+		}
+	}
+}
+
 func TestDecOptions(t *testing.T) {
 	simpleValues, err := NewSimpleValueRegistryFromDefaults(WithRejectedSimpleValue(255))
 	if err != nil {
@@ -4904,7 +5118,12 @@ func TestDecOptions(t *testing.T) {
 		MaxNestedLevels:          100,
 		MaxArrayElements:         102,
 		MaxMapPairs:              101,
+		MaxStringBytes:           102,
+		MaxByteStringBytes:       103,
+		MaxDecodedSizeBytes:      104,
 		IndefLength:              IndefLengthForbidden,
+		ChunkedString:            ChunkedStringRequired,
+		MaxStringChunkSize:       1024,
 		TagsMd:                   TagsForbidden,
 		IntDec:                   IntDecConvertSigned,
 		MapKeyByteString:         MapKeyByteStringForbidden,
@@ -4921,16 +5140,39 @@ func TestDecOptions(t *testing.T) {
 		SimpleValues:             simpleValues,
 		NaN:                      NaNDecodeForbidden,
 		Inf:                      InfDecodeForbidden,
+		Float:                    FloatForbidden,
 		ByteStringToTime:         ByteStringToTimeAllowed,
+		Duration:                 DurationDecSeconds,
 		ByteStringExpectedFormat: ByteStringExpectedBase64URL,
 		BignumTag:                BignumTagForbidden,
 		BinaryUnmarshaler:        BinaryUnmarshalerNone,
+		Deterministic:            DeterministicCoreRequired,
+		InterfaceFallbackTypes:   NewInterfaceFallbackTypes(map[reflect.Type]reflect.Type{reflect.TypeOf((*error)(nil)).Elem(): reflect.TypeOf(UnknownFieldError{})}),
+		MapKeyUTF8:               MapKeyUTF8RejectInvalid,
+		TextStringToByteSlice:    TextStringToByteSliceAllowed,
+		BigFloatPrecision:        100,
+		BigFloatRoundingMode:     big.ToZero,
+		ZeroCopy:                 ZeroCopyBytes,
+		Reuse:                    ReuseContainers,
+		NilValue:                 NilValueForbidden,
+		ArrayLength:              ArrayLengthExact,
+		StringerEnums:            NewStringerEnums(time.January, time.February),
+		EncodedItem:              EncodedItemDecodeUnwrap,
+		Budget:                   NewBudget(func(int, int) error { return nil }),
+		FieldNameTransform:       NewStringTransform(strings.ToLower),
+		UnicodeNormalization:     NewStringTransform(strings.ToUpper),
+		AllowedTags:              NewTagNumbers(0, 1),
+		SimpleValueToAny:         SimpleValueToSimpleValue,
+		SmallValueToAny:          SmallValueToAnyCacheIntegersAndStrings,
+		FieldNameIgnoredTags:     NewTagNumbers(21),
+		TrailingBytes:            TrailingBytesAllowed,
 	}
 	ov := reflect.ValueOf(opts1)
 	for i := 0; i < ov.NumField(); i++ {
 		fv := ov.Field(i)
 		if fv.IsZero() {
-			t.Errorf("options field %q is unset or set to the zero value for its type", ov.Type().Field(i).Name)
+			fn := ov.Type().Field(i).Name
+			t.Errorf("options field %q is unset or set to the zero value for its type", fn)
 		}
 	}
 	dm, err := opts1.DecMode()
@@ -4938,7 +5180,7 @@ func TestDecOptions(t *testing.T) {
 		t.Errorf("DecMode() returned an error %v", err)
 	} else {
 		opts2 := dm.DecOptions()
-		if !reflect.DeepEqual(opts1, opts2) {
+		if opts1 != opts2 {
 			t.Errorf("DecOptions->DecMode->DecOptions returned different values: %#v, %#v", opts1, opts2)
 		}
 	}
@@ -5211,7 +5453,10 @@ func TestUnmarshalStructKeyAsIntNumError(t *testing.T) {
 		F1 int `cbor:"a,keyasint"`
 	}
 	type T2 struct {
-		F1 int `cbor:"-18446744073709551616,keyasint"`
+		F1 int `cbor:"-18446744073709551617,keyasint"`
+	}
+	type T3 struct {
+		F1 int `cbor:"18446744073709551616,keyasint"`
 	}
 	testCases := []struct {
 		name         string
@@ -5226,10 +5471,16 @@ func TestUnmarshalStructKeyAsIntNumError(t *testing.T) {
 			wantErrorMsg: "cbor: failed to parse field name \"a\" to int",
 		},
 		{
-			name:         "out of range int as key",
+			name:         "out of range negative int as key",
 			data:         hexDecode("a13bffffffffffffffff01"),
 			obj:          T2{},
-			wantErrorMsg: "cbor: failed to parse field name \"-18446744073709551616\" to int",
+			wantErrorMsg: "cbor: failed to parse field name \"-18446744073709551617\" to int",
+		},
+		{
+			name:         "out of range positive int as key",
+			data:         hexDecode("a11bffffffffffffffff01"),
+			obj:          T3{},
+			wantErrorMsg: "cbor: failed to parse field name \"18446744073709551616\" to int",
 		},
 	}
 	for _, tc := range testCases {
@@ -6058,7 +6309,7 @@ func TestUnmarshalDupMapKeyToStructStringParseError(t *testing.T) {
 	}
 }
 
-func TestUnmarshalDupMapKeyToStructIntParseError(t *testing.T) {
+func TestUnmarshalDupMapKeyToStructIntOutOfInt64Range(t *testing.T) {
 	type s struct {
 		A int `cbor:"1,keyasint"`
 		B int `cbor:"3,keyasint"`
@@ -6066,30 +6317,27 @@ func TestUnmarshalDupMapKeyToStructIntParseError(t *testing.T) {
 	}
 	data := hexDecode("a43bffffffffffffffff0203043bffffffffffffffff030506") // {-18446744073709551616:2, 3:4, -18446744073709551616:3, 5:6}
 
-	// Duplicate key doesn't overwrite previous value (default).
+	// Unknown keys are ignored (default).
 	wantS := s{B: 4, C: 6}
-	wantErrorMsg := "cbor: cannot unmarshal"
 	var s1 s
-	if err := Unmarshal(data, &s1); err == nil {
-		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
-	} else if _, ok := err.(*UnmarshalTypeError); !ok {
-		t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*UnmarshalTypeError)", data, err)
-	} else if !strings.Contains(err.Error(), wantErrorMsg) {
-		t.Errorf("Unmarshal(0x%x) returned error %q, want error containing %q", data, err.Error(), wantErrorMsg)
+	if err := Unmarshal(data, &s1); err != nil {
+		t.Errorf("Unmarshal(0x%x) returned an error %v", data, err)
 	}
 	if !reflect.DeepEqual(s1, wantS) {
 		t.Errorf("Unmarshal(0x%x) = %+v (%T), want %+v (%T)", data, s1, s1, wantS, wantS)
 	}
 
 	// Duplicate key triggers error.
+	wantS = s{B: 4}
+	wantErrorMsg := "cbor: found duplicate map key \"-18446744073709551616\" at map element index 2"
 	dm, _ := DecOptions{DupMapKey: DupMapKeyEnforcedAPF}.DecMode()
 	var s2 s
 	if err := dm.Unmarshal(data, &s2); err == nil {
 		t.Errorf("Unmarshal(0x%x, %s) didn't return an error", data, reflect.TypeOf(s2))
-	} else if _, ok := err.(*UnmarshalTypeError); !ok {
-		t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*UnmarshalTypeError)", data, err)
-	} else if !strings.Contains(err.Error(), wantErrorMsg) {
-		t.Errorf("Unmarshal(0x%x) returned error %q, want error containing %q", data, err.Error(), wantErrorMsg)
+	} else if _, ok := err.(*DupMapKeyError); !ok {
+		t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*DupMapKeyError)", data, err)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
 	}
 	if !reflect.DeepEqual(s2, wantS) {
 		t.Errorf("Unmarshal(0x%x) = %+v (%T), want %+v (%T)", data, s2, s2, wantS, wantS)
@@ -6162,14 +6410,16 @@ func TestUnmarshalDupMapKeyToStructWrongTypeUnhashableError(t *testing.T) {
 	}
 
 	// Duplicate key triggers error.
+	wantS = s{A: "", B: "B", C: "C"}
+	wantErrorMsg = "cbor: found duplicate map key \"[0]\" at map element index 3"
 	dm, _ := DecOptions{DupMapKey: DupMapKeyEnforcedAPF}.DecMode()
 	var s2 s
 	if err := dm.Unmarshal(data, &s2); err == nil {
 		t.Errorf("Unmarshal(0x%x, %s) didn't return an error", data, reflect.TypeOf(s2))
-	} else if _, ok := err.(*UnmarshalTypeError); !ok {
-		t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*UnmarshalTypeError)", data, err)
-	} else if !strings.Contains(err.Error(), wantErrorMsg) {
-		t.Errorf("Unmarshal(0x%x) returned error %q, want error containing %q", data, err.Error(), wantErrorMsg)
+	} else if _, ok := err.(*DupMapKeyError); !ok {
+		t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*DupMapKeyError)", data, err)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
 	}
 	if !reflect.DeepEqual(s2, wantS) {
 		t.Errorf("Unmarshal(0x%x) = %+v (%T), want %+v (%T)", data, s2, s2, wantS, wantS)
@@ -6202,14 +6452,16 @@ func TestUnmarshalDupMapKeyToStructTagTypeError(t *testing.T) {
 	}
 
 	// Duplicate key triggers error.
+	wantS = s{A: "", B: "B", C: "C"}
+	wantErrorMsg = "cbor: found duplicate map key \"18446744073709551616\" at map element index 3"
 	dm, _ := DecOptions{DupMapKey: DupMapKeyEnforcedAPF}.DecMode()
 	var s2 s
 	if err := dm.Unmarshal(data, &s2); err == nil {
 		t.Errorf("Unmarshal(0x%x, %s) didn't return an error", data, reflect.TypeOf(s2))
-	} else if _, ok := err.(*UnmarshalTypeError); !ok {
-		t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*UnmarshalTypeError)", data, err)
-	} else if !strings.Contains(err.Error(), wantErrorMsg) {
-		t.Errorf("Unmarshal(0x%x) returned error %q, want error containing %q", data, err.Error(), wantErrorMsg)
+	} else if _, ok := err.(*DupMapKeyError); !ok {
+		t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*DupMapKeyError)", data, err)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
 	}
 	if !reflect.DeepEqual(s2, wantS) {
 		t.Errorf("Unmarshal(0x%x) = %+v (%T), want %+v (%T)", data, s2, s2, wantS, wantS)
@@ -6318,6 +6570,112 @@ func TestExceedMaxMapPairs(t *testing.T) {
 	}
 }
 
+func TestDecModeInvalidMaxStringBytes(t *testing.T) {
+	testCases := []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "MaxStringBytes < 0",
+			opts:         DecOptions{MaxStringBytes: -1},
+			wantErrorMsg: "cbor: invalid MaxStringBytes -1 (range is [0, 2147483647])",
+		},
+		{
+			name:         "MaxStringBytes > 2147483647",
+			opts:         DecOptions{MaxStringBytes: 2147483648},
+			wantErrorMsg: "cbor: invalid MaxStringBytes 2147483648 (range is [0, 2147483647])",
+		},
+		{
+			name:         "MaxByteStringBytes < 0",
+			opts:         DecOptions{MaxByteStringBytes: -1},
+			wantErrorMsg: "cbor: invalid MaxByteStringBytes -1 (range is [0, 2147483647])",
+		},
+		{
+			name:         "MaxByteStringBytes > 2147483647",
+			opts:         DecOptions{MaxByteStringBytes: 2147483648},
+			wantErrorMsg: "cbor: invalid MaxByteStringBytes 2147483648 (range is [0, 2147483647])",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestExceedMaxStringBytes(t *testing.T) {
+	testCases := []struct {
+		name         string
+		opts         DecOptions
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "text string",
+			opts:         DecOptions{MaxStringBytes: 4},
+			data:         hexDecode("6568656c6c6f"),
+			wantErrorMsg: "cbor: exceeded max length 4 bytes for CBOR UTF-8 text string",
+		},
+		{
+			name:         "indefinite length text string",
+			opts:         DecOptions{MaxStringBytes: 4},
+			data:         hexDecode("7f626865636c6c6fff"),
+			wantErrorMsg: "cbor: exceeded max length 4 bytes for CBOR UTF-8 text string",
+		},
+		{
+			name:         "byte string",
+			opts:         DecOptions{MaxByteStringBytes: 4},
+			data:         hexDecode("450102030405"),
+			wantErrorMsg: "cbor: exceeded max length 4 bytes for CBOR byte string",
+		},
+		{
+			name:         "indefinite length byte string",
+			opts:         DecOptions{MaxByteStringBytes: 4},
+			data:         hexDecode("5f4201024203044105ff"),
+			wantErrorMsg: "cbor: exceeded max length 4 bytes for CBOR byte string",
+		},
+		{
+			name:         "nested byte string",
+			opts:         DecOptions{MaxByteStringBytes: 4},
+			data:         hexDecode("a16161450102030405"),
+			wantErrorMsg: "cbor: exceeded max length 4 bytes for CBOR byte string",
+		},
+		{
+			name:         "truncated byte string exceeding limit",
+			opts:         DecOptions{MaxByteStringBytes: 4},
+			data:         hexDecode("5a7fffffff"),
+			wantErrorMsg: "cbor: exceeded max length 4 bytes for CBOR byte string",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, _ := tc.opts.DecMode()
+			var v interface{}
+			if err := dm.Unmarshal(tc.data, &v); err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error", tc.data)
+			} else if _, ok := err.(*MaxStringBytesError); !ok {
+				t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*MaxStringBytesError)", tc.data, err)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+
+	// Strings within limits are decoded.
+	dm, _ := DecOptions{MaxStringBytes: 5, MaxByteStringBytes: 5}.DecMode()
+	data := hexDecode("827f626865636c6c6fff5f4201024203044105ff")
+	var v []interface{}
+	if err := dm.Unmarshal(data, &v); err != nil {
+		t.Errorf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+}
+
 func TestDecIndefiniteLengthOption(t *testing.T) {
 	testCases := []struct {
 		name         string
@@ -6645,42 +7003,202 @@ func TestIntDecConvertSignedOrError(t *testing.T) {
 	}
 }
 
-func TestDecModeInvalidMapKeyByteString(t *testing.T) {
-	for _, tc := range []struct {
-		name         string
-		opts         DecOptions
-		wantErrorMsg string
+func TestIntDecConvertSignedOrFloat64(t *testing.T) {
+	dm, err := DecOptions{IntDec: IntDecConvertSignedOrFloat64}.DecMode()
+	if err != nil {
+		t.Errorf("DecMode() returned an error %+v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		data    []byte
+		wantObj interface{}
 	}{
 		{
-			name:         "below range of valid modes",
-			opts:         DecOptions{MapKeyByteString: -1},
-			wantErrorMsg: "cbor: invalid MapKeyByteString -1",
+			name:    "CBOR pos int",
+			data:    hexDecode("1a000f4240"),
+			wantObj: int64(1000000),
 		},
 		{
-			name:         "above range of valid modes",
-			opts:         DecOptions{MapKeyByteString: 101},
-			wantErrorMsg: "cbor: invalid MapKeyByteString 101",
+			name:    "CBOR pos int overflows int64",
+			data:    hexDecode("1b8000000000000000"), // math.MaxInt64+1
+			wantObj: float64(1 << 63),
 		},
-	} {
+		{
+			name:    "CBOR neg int",
+			data:    hexDecode("3903e7"),
+			wantObj: int64(-1000),
+		},
+		{
+			name:    "CBOR neg int overflows int64",
+			data:    hexDecode("3bffffffffffffffff"), // -2^64
+			wantObj: float64(-(1 << 64)),
+		},
+	}
+	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := tc.opts.DecMode()
+			var v interface{}
+			err := dm.Unmarshal(tc.data, &v)
 			if err == nil {
-				t.Errorf("DecMode() didn't return an error")
-			} else if err.Error() != tc.wantErrorMsg {
-				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+				if !reflect.DeepEqual(v, tc.wantObj) {
+					t.Errorf("Unmarshal(0x%x) return %v (%T), want %v (%T)", tc.data, v, v, tc.wantObj, tc.wantObj)
+				}
+			} else {
+				t.Errorf("Unmarshal(0x%x) returned error %q", tc.data, err)
 			}
 		})
 	}
 }
 
-func TestMapKeyByteString(t *testing.T) {
-	bsForbiddenMode, err := DecOptions{MapKeyByteString: MapKeyByteStringForbidden}.DecMode()
-	if err != nil {
-		t.Errorf("DecMode() returned an error %+v", err)
-	}
+func TestIntDecConvertSignedOrUint64(t *testing.T) {
+	for _, bigIntDec := range []BigIntDecMode{BigIntDecodeValue, BigIntDecodePointer} {
+		dm, err := DecOptions{
+			IntDec:    IntDecConvertSignedOrUint64,
+			BigIntDec: bigIntDec,
+		}.DecMode()
+		if err != nil {
+			t.Errorf("DecMode() returned an error %+v", err)
+		}
 
-	bsAllowedMode, err := DecOptions{MapKeyByteString: MapKeyByteStringAllowed}.DecMode()
-	if err != nil {
+		bi := new(big.Int).Sub(big.NewInt(math.MinInt64), big.NewInt(1))
+		var wantBigInt interface{} = *bi
+		if bigIntDec == BigIntDecodePointer {
+			wantBigInt = bi
+		}
+
+		testCases := []struct {
+			name    string
+			data    []byte
+			wantObj interface{}
+		}{
+			{
+				name:    "CBOR pos int",
+				data:    hexDecode("1a000f4240"),
+				wantObj: int64(1000000),
+			},
+			{
+				name:    "CBOR pos int max int64",
+				data:    hexDecode("1b7fffffffffffffff"),
+				wantObj: int64(math.MaxInt64),
+			},
+			{
+				name:    "CBOR pos int overflows int64",
+				data:    hexDecode("1b8000000000000000"), // math.MaxInt64+1
+				wantObj: uint64(1 << 63),
+			},
+			{
+				name:    "CBOR neg int",
+				data:    hexDecode("3903e7"),
+				wantObj: int64(-1000),
+			},
+			{
+				name:    "CBOR neg int overflows int64",
+				data:    hexDecode("3b8000000000000000"), // math.MinInt64-1
+				wantObj: wantBigInt,
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				var v interface{}
+				err := dm.Unmarshal(tc.data, &v)
+				if err == nil {
+					if !reflect.DeepEqual(v, tc.wantObj) {
+						t.Errorf("Unmarshal(0x%x) return %v (%T), want %v (%T)", tc.data, v, v, tc.wantObj, tc.wantObj)
+					}
+				} else {
+					t.Errorf("Unmarshal(0x%x) returned error %q", tc.data, err)
+				}
+			})
+		}
+	}
+}
+
+func TestJSONCompatibleDecOptions(t *testing.T) {
+	dm, err := JSONCompatibleDecOptions().DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	// {"a": [1, -2, 18446744073709551615, 1.5], "b": {"c": h'01'}, "d": 65535(true)}
+	data := hexDecode("a361618401211bfffffffffffffffff93e006162a1616341016164d9fffff5")
+	want := map[string]interface{}{
+		"a": []interface{}{int64(1), int64(-2), float64(18446744073709551615), float64(1.5)},
+		"b": map[string]interface{}{"c": []byte{1}},
+		"d": true,
+	}
+
+	var v interface{}
+	if err = dm.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("Unmarshal(0x%x) = %#v, want %#v", data, v, want)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal(%#v) returned error %v", v, err)
+	}
+	wantJSON := `{"a":[1,-2,18446744073709552000,1.5],"b":{"c":"AQ=="},"d":true}`
+	if string(b) != wantJSON {
+		t.Errorf("json.Marshal(%#v) = %s, want %s", v, b, wantJSON)
+	}
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"integer map key", hexDecode("a10101")},
+		{"byte string map key", hexDecode("a1413101")},
+		{"nested integer map key", hexDecode("a16161a10101")},
+		{"NaN", hexDecode("f97e00")},
+		{"infinity", hexDecode("f97c00")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var v interface{}
+			if err := dm.Unmarshal(tc.data, &v); err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error", tc.data)
+			}
+		})
+	}
+}
+
+func TestDecModeInvalidMapKeyByteString(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{MapKeyByteString: -1},
+			wantErrorMsg: "cbor: invalid MapKeyByteString -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{MapKeyByteString: 101},
+			wantErrorMsg: "cbor: invalid MapKeyByteString 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestMapKeyByteString(t *testing.T) {
+	bsForbiddenMode, err := DecOptions{MapKeyByteString: MapKeyByteStringForbidden}.DecMode()
+	if err != nil {
+		t.Errorf("DecMode() returned an error %+v", err)
+	}
+
+	bsAllowedMode, err := DecOptions{MapKeyByteString: MapKeyByteStringAllowed}.DecMode()
+	if err != nil {
 		t.Errorf("DecMode() returned an error %+v", err)
 	}
 
@@ -8143,6 +8661,12 @@ func TestDecodeFieldNameMatching(t *testing.T) {
 			data:      hexDecode("a2614201614202"), // {"B": 1, "B": 2} (invalid)
 			wantValue: s{UpperB: 1},
 		},
+		{
+			name:      "keys differing in case match fields differing in case",
+			opts:      DecOptions{FieldNameMatching: FieldNameMatchingCaseSensitive},
+			data:      hexDecode("a2614201616202"), // {"B": 1, "b": 2}
+			wantValue: s{UpperB: 1, LowerB: 2},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -8162,6 +8686,212 @@ func TestDecodeFieldNameMatching(t *testing.T) {
 	}
 }
 
+func TestDecodeUnknownFieldNameAllocs(t *testing.T) {
+	type s struct {
+		A int `cbor:"a"`
+	}
+
+	dm, err := DecOptions{FieldNameMatching: FieldNameMatchingCaseSensitive}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	allocs := func(m map[string]int) float64 {
+		data, err := Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal() returned error %v", err)
+		}
+		return testing.AllocsPerRun(10, func() {
+			var v s
+			if err := dm.Unmarshal(data, &v); err != nil {
+				t.Fatalf("Unmarshal() returned error %v", err)
+			}
+		})
+	}
+
+	known := allocs(map[string]int{"a": 1})
+	unknown := allocs(map[string]int{"a": 1, "an_unknown_field_name_longer_than_32_bytes": 2})
+
+	// Unknown map key isn't converted to string without FieldNameTransform.
+	if unknown > known {
+		t.Errorf("Unmarshal() with unknown map key allocated %v times, want %v", unknown, known)
+	}
+}
+
+func TestDecodeUnicodeNormalization(t *testing.T) {
+	// Composes "e" followed by U+0301 (combining acute accent) to U+00E9, which is
+	// enough for testing without depending on golang.org/x/text/unicode/norm.
+	nfc := func(s string) string {
+		return strings.ReplaceAll(s, "e\u0301", "\u00e9")
+	}
+
+	dm, err := DecOptions{UnicodeNormalization: NewStringTransform(nfc)}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned an error %v", err)
+	}
+	if dm.DecOptions().UnicodeNormalization == nil {
+		t.Errorf("DecOptions().UnicodeNormalization is nil")
+	}
+
+	decomposed := hexDecode("6663616665cc81") // "cafe\u0301"
+	composed := "caf\u00e9"
+
+	t.Run("string", func(t *testing.T) {
+		var v string
+		if err := dm.Unmarshal(decomposed, &v); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", decomposed, err)
+		}
+		if v != composed {
+			t.Errorf("Unmarshal(0x%x) = %q, want %q", decomposed, v, composed)
+		}
+	})
+
+	t.Run("empty interface", func(t *testing.T) {
+		var v interface{}
+		if err := dm.Unmarshal(decomposed, &v); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", decomposed, err)
+		}
+		if v != composed {
+			t.Errorf("Unmarshal(0x%x) = %q, want %q", decomposed, v, composed)
+		}
+	})
+
+	t.Run("map key", func(t *testing.T) {
+		data := hexDecode("a26663616665cc810165636166c3a902") // {"cafe\u0301": 1, "caf\u00e9": 2}
+		var v map[string]int
+		if err := dm.Unmarshal(data, &v); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+		}
+		if want := map[string]int{composed: 2}; !reflect.DeepEqual(v, want) {
+			t.Errorf("Unmarshal(0x%x) = %v, want %v", data, v, want)
+		}
+
+		dupDM, _ := DecOptions{UnicodeNormalization: NewStringTransform(nfc), DupMapKey: DupMapKeyEnforcedAPF}.DecMode()
+		if err := dupDM.Unmarshal(data, &v); err == nil {
+			t.Errorf("Unmarshal(0x%x) didn't return an error for duplicate map keys after normalization", data)
+		}
+	})
+
+	t.Run("struct field", func(t *testing.T) {
+		type s struct {
+			Cafe int `cbor:"caf\u00e9"`
+		}
+		data := hexDecode("a16663616665cc8101") // {"cafe\u0301": 1}
+
+		var v s
+		if err := dm.Unmarshal(data, &v); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+		}
+		if v.Cafe != 0 {
+			t.Errorf("Unmarshal(0x%x) = %+v, want field not matched without FieldNameTransform", data, v)
+		}
+
+		dm, _ := DecOptions{UnicodeNormalization: NewStringTransform(nfc), FieldNameTransform: NewStringTransform(nfc)}.DecMode()
+		if err := dm.Unmarshal(data, &v); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+		}
+		if v.Cafe != 1 {
+			t.Errorf("Unmarshal(0x%x) = %+v, want field matched with FieldNameTransform", data, v)
+		}
+	})
+}
+
+func TestDecodeFieldNameTransform(t *testing.T) {
+	type s struct {
+		DeviceID  int
+		CreatedAt int
+		Label     int `cbor:"label_text"`
+	}
+
+	snakeToCamel := func(key string) string {
+		var sb strings.Builder
+		upper := true
+		for _, r := range key {
+			if r == '_' {
+				upper = true
+				continue
+			}
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			sb.WriteRune(r)
+		}
+		return sb.String()
+	}
+
+	testCases := []struct {
+		name      string
+		opts      DecOptions
+		data      []byte
+		wantValue s
+	}{
+		{
+			name:      "no transform",
+			data:      hexDecode("a2696465766963655f6964016a637265617465645f617402"), // {"device_id": 1, "created_at": 2}
+			wantValue: s{},
+		},
+		{
+			name:      "transformed key matches field name",
+			opts:      DecOptions{FieldNameTransform: NewStringTransform(snakeToCamel)},
+			data:      hexDecode("a2696465766963655f6964016a637265617465645f617402"), // {"device_id": 1, "created_at": 2}
+			wantValue: s{DeviceID: 1, CreatedAt: 2},
+		},
+		{
+			name:      "transformed key matches field name case-sensitively",
+			opts:      DecOptions{FieldNameTransform: NewStringTransform(snakeToCamel), FieldNameMatching: FieldNameMatchingCaseSensitive},
+			data:      hexDecode("a2696465766963655f6964016a637265617465645f617402"), // {"device_id": 1, "created_at": 2}
+			wantValue: s{CreatedAt: 2},
+		},
+		{
+			name:      "exact match isn't transformed",
+			opts:      DecOptions{FieldNameTransform: NewStringTransform(snakeToCamel)},
+			data:      hexDecode("a16a6c6162656c5f7465787403"), // {"label_text": 3}
+			wantValue: s{Label: 3},
+		},
+		{
+			name:      "exact match before transformed match",
+			opts:      DecOptions{FieldNameTransform: NewStringTransform(snakeToCamel)},
+			data:      hexDecode("a268446576696365494401696465766963655f696402"), // {"DeviceID": 1, "device_id": 2}
+			wantValue: s{DeviceID: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decMode, err := tc.opts.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned an error %v", err)
+			}
+
+			var dst s
+			err = decMode.Unmarshal(tc.data, &dst)
+			if err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned unexpected error %v", tc.data, err)
+			}
+
+			if !reflect.DeepEqual(dst, tc.wantValue) {
+				t.Errorf("Unmarshal(0x%x) = %#v, want %#v", tc.data, dst, tc.wantValue)
+			}
+		})
+	}
+
+	t.Run("duplicate map key", func(t *testing.T) {
+		decMode, _ := DecOptions{FieldNameTransform: NewStringTransform(snakeToCamel), DupMapKey: DupMapKeyEnforcedAPF}.DecMode()
+		if decMode.DecOptions().FieldNameTransform == nil {
+			t.Errorf("DecOptions().FieldNameTransform is nil")
+		}
+
+		data := hexDecode("a268446576696365494401696465766963655f696402") // {"DeviceID": 1, "device_id": 2}
+		var dst s
+		err := decMode.Unmarshal(data, &dst)
+		wantErr := &DupMapKeyError{Key: "device_id", Index: 1}
+		if !reflect.DeepEqual(err, wantErr) {
+			t.Errorf("Unmarshal(0x%x) returned error %v, want %v", data, err, wantErr)
+		}
+	})
+}
+
 func TestInvalidBigIntDecMode(t *testing.T) {
 	for _, tc := range []struct {
 		name         string
@@ -8482,6 +9212,41 @@ func TestUnmarshalFieldNameByteString(t *testing.T) {
 	}
 }
 
+func TestFieldNameByteStringRoundTrip(t *testing.T) {
+	type s struct {
+		A       int                   `cbor:"a"`
+		B       int                   `cbor:"1,keyasint"`
+		Unknown map[string]RawMessage `cbor:",unknown"`
+	}
+
+	dm, err := DecOptions{FieldNameByteString: FieldNameByteStringAllowed}.DecMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	em, err := EncOptions{FieldName: FieldNameToByteString, Sort: SortCoreDeterministic}.EncMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := hexDecode("a301024161034162f5") // {1: 2, h'61': 3, h'62': true}
+	var v s
+	if err = dm.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	want := s{A: 3, B: 2, Unknown: map[string]RawMessage{"b": hexDecode("f5")}}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("Unmarshal(0x%x) = %#v, want %#v", data, v, want)
+	}
+
+	b, err := em.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(%#v) returned error %v", v, err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Errorf("Marshal(%#v) = 0x%x, want 0x%x", v, b, data)
+	}
+}
+
 func TestDecModeInvalidReturnTypeForEmptyInterface(t *testing.T) {
 	for _, tc := range []struct {
 		name         string
@@ -8684,6 +9449,21 @@ func TestNewSimpleValueRegistry(t *testing.T) {
 			opts:         []func(*SimpleValueRegistry) error{WithRejectedSimpleValue(31)},
 			wantErrorMsg: "cbor: cannot set analog for reserved simple value 31",
 		},
+		{
+			name:         "analog for reserved",
+			opts:         []func(*SimpleValueRegistry) error{WithSimpleValueAnalog(24, 0)},
+			wantErrorMsg: "cbor: cannot set analog for reserved simple value 24",
+		},
+		{
+			name:         "analog for assigned",
+			opts:         []func(*SimpleValueRegistry) error{WithSimpleValueAnalog(22, 0)},
+			wantErrorMsg: "cbor: cannot set analog for assigned simple value 22",
+		},
+		{
+			name:         "nil analog",
+			opts:         []func(*SimpleValueRegistry) error{WithSimpleValueAnalog(99, nil)},
+			wantErrorMsg: "cbor: cannot set nil analog for simple value 99",
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			_, err := NewSimpleValueRegistryFromDefaults(tc.opts...)
@@ -8800,6 +9580,46 @@ func TestUnmarshalSimpleValues(t *testing.T) {
 			want:          uint64(200),
 			assertOnError: assertNilError,
 		},
+		{
+			name:          "analog into interface{}",
+			fns:           []func(*SimpleValueRegistry) error{WithSimpleValueAnalog(99, testSentinel)},
+			in:            []byte{0xf8, 0x63},
+			into:          typeIntf,
+			want:          testSentinel,
+			assertOnError: assertNilError,
+		},
+		{
+			name:          "analog into its type",
+			fns:           []func(*SimpleValueRegistry) error{WithSimpleValueAnalog(99, testSentinel)},
+			in:            []byte{0xf8, 0x63},
+			into:          reflect.TypeOf(testSentinel),
+			want:          testSentinel,
+			assertOnError: assertNilError,
+		},
+		{
+			name:          "analog into unassignable uint64",
+			fns:           []func(*SimpleValueRegistry) error{WithSimpleValueAnalog(99, testSentinel)},
+			in:            []byte{0xf8, 0x63},
+			into:          typeUint64,
+			want:          uint64(99),
+			assertOnError: assertNilError,
+		},
+		{
+			name:          "analog into SimpleValue",
+			fns:           []func(*SimpleValueRegistry) error{WithSimpleValueAnalog(99, testSentinel)},
+			in:            []byte{0xf8, 0x63},
+			into:          typeSimpleValue,
+			want:          SimpleValue(99),
+			assertOnError: assertNilError,
+		},
+		{
+			name:          "analog in additional information into interface{}",
+			fns:           []func(*SimpleValueRegistry) error{WithSimpleValueAnalog(16, "sixteen")},
+			in:            []byte{0xf0},
+			into:          typeIntf,
+			want:          "sixteen",
+			assertOnError: assertNilError,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			r, err := NewSimpleValueRegistryFromDefaults(tc.fns...)
@@ -8823,37 +9643,305 @@ func TestUnmarshalSimpleValues(t *testing.T) {
 	}
 }
 
-func isCBORNil(data []byte) bool {
-	return len(data) > 0 && (data[0] == 0xf6 || data[0] == 0xf7)
-}
+type testSentinelType struct{}
 
-func TestDecModeInvalidTimeTagToAnyMode(t *testing.T) {
-	for _, tc := range []struct {
-		name         string
-		opts         DecOptions
-		wantErrorMsg string
-	}{
-		{
-			name:         "below range of valid modes",
-			opts:         DecOptions{TimeTagToAny: -1},
-			wantErrorMsg: "cbor: invalid TimeTagToAny -1",
-		},
-		{
-			name:         "above range of valid modes",
-			opts:         DecOptions{TimeTagToAny: 4},
-			wantErrorMsg: "cbor: invalid TimeTagToAny 4",
-		},
-	} {
-		t.Run(tc.name, func(t *testing.T) {
-			_, err := tc.opts.DecMode()
-			if err == nil {
-				t.Errorf("Expected non nil error from DecMode()")
-			} else if err.Error() != tc.wantErrorMsg {
-				t.Errorf("Expected error: %q, want: %q \n", tc.wantErrorMsg, err.Error())
-			}
-		})
-	}
-}
+const testSentinelSimpleValue = SimpleValue(99)
+
+var testSentinel = testSentinelType{}
+
+func (testSentinelType) MarshalCBOR() ([]byte, error) {
+	return testSentinelSimpleValue.MarshalCBOR()
+}
+
+func TestSimpleValueAnalogRoundTrip(t *testing.T) {
+	r, err := NewSimpleValueRegistryFromDefaults(WithSimpleValueAnalog(testSentinelSimpleValue, testSentinel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm, err := DecOptions{SimpleValues: r}.DecMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := []interface{}{uint64(1), testSentinel}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal(%v) returned error %v", in, err)
+	}
+	if want := hexDecode("8201f863"); !bytes.Equal(data, want) {
+		t.Errorf("Marshal(%v) = 0x%x, want 0x%x", in, data, want)
+	}
+
+	var out []interface{}
+	if err := dm.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	if !reflect.DeepEqual(in, out) || out[1] != testSentinel {
+		t.Errorf("Unmarshal(0x%x) = %v, want %v", data, out, in)
+	}
+}
+
+type testEnum int
+
+const (
+	testEnumUnknown testEnum = -1
+	testEnumA       testEnum = 1
+)
+
+func TestSimpleValueAnalogEncode(t *testing.T) {
+	r, err := NewSimpleValueRegistryFromDefaults(
+		WithSimpleValueAnalog(testSentinelSimpleValue, testSentinel),
+		WithSimpleValueAnalog(100, testEnumUnknown),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	em, err := EncOptions{SimpleValueAnalogs: r}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	if em.EncOptions().SimpleValueAnalogs != r {
+		t.Errorf("EncOptions().SimpleValueAnalogs = %p, want %p", em.EncOptions().SimpleValueAnalogs, r)
+	}
+	dm, err := DecOptions{SimpleValues: r}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	type s struct {
+		E  testEnum            `cbor:"e"`
+		ES []testEnum          `cbor:"es"`
+		EM map[string]testEnum `cbor:"em"`
+	}
+
+	for _, tc := range []struct {
+		name     string
+		v        interface{}
+		wantData []byte
+	}{
+		{
+			name:     "analog",
+			v:        testEnumUnknown,
+			wantData: hexDecode("f864"), // simple(100)
+		},
+		{
+			name:     "value isn't analog",
+			v:        testEnumA,
+			wantData: hexDecode("01"),
+		},
+		{
+			name:     "analog implementing Marshaler",
+			v:        testSentinel,
+			wantData: hexDecode("f863"), // simple(99)
+		},
+		{
+			name: "analogs in struct fields, slices, and maps",
+			v:    s{E: testEnumUnknown, ES: []testEnum{testEnumA, testEnumUnknown}, EM: map[string]testEnum{"a": testEnumUnknown}},
+			// {"e": simple(100), "es": [1, simple(100)], "em": {"a": simple(100)}}
+			wantData: hexDecode("a36165f8646265738201f86462656da16161f864"),
+		},
+		{
+			name:     "analog in interface{}",
+			v:        []interface{}{testEnumUnknown, "a"},
+			wantData: hexDecode("82f8646161"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := em.Marshal(tc.v)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned error %v", tc.v, err)
+			}
+			if !bytes.Equal(data, tc.wantData) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", tc.v, data, tc.wantData)
+			}
+
+			got := reflect.New(reflect.TypeOf(tc.v))
+			if err := dm.Unmarshal(data, got.Interface()); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+			}
+			if !reflect.DeepEqual(got.Elem().Interface(), tc.v) {
+				t.Errorf("Unmarshal(0x%x) = %v, want %v", data, got.Elem().Interface(), tc.v)
+			}
+		})
+	}
+
+	// Analogs aren't encoded without SimpleValueAnalogs.
+	if data, err := Marshal(testEnumUnknown); err != nil {
+		t.Errorf("Marshal() returned error %v", err)
+	} else if want := hexDecode("20"); !bytes.Equal(data, want) {
+		t.Errorf("Marshal() = 0x%x, want 0x%x", data, want)
+	}
+
+	// Encoded simple values are subject to SimpleValues option.
+	em, err = EncOptions{SimpleValueAnalogs: r, SimpleValues: SimpleValuesFalseTrueNull}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	wantErrorMsg := "cbor: unsupported value: simple value 100"
+	if _, err := em.Marshal(testEnumUnknown); err == nil {
+		t.Errorf("Marshal() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Marshal() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+}
+
+func TestEncModeInvalidSimpleValueAnalogs(t *testing.T) {
+	type uncomparable struct {
+		s []int
+	}
+
+	for _, tc := range []struct {
+		name         string
+		analog       interface{}
+		wantErrorMsg string
+	}{
+		{
+			name:         "unnamed type",
+			analog:       "sixteen",
+			wantErrorMsg: "cbor: cannot encode analog of unnamed type string as simple value 16",
+		},
+		{
+			name:         "uncomparable type",
+			analog:       uncomparable{},
+			wantErrorMsg: "cbor: cannot encode analog of uncomparable type cbor.uncomparable as simple value 16",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewSimpleValueRegistryFromDefaults(WithSimpleValueAnalog(16, tc.analog))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = EncOptions{SimpleValueAnalogs: r}.EncMode()
+			if err == nil {
+				t.Errorf("EncMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDecModeInvalidSimpleValueToAny(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{SimpleValueToAny: -1},
+			wantErrorMsg: "cbor: invalid SimpleValueToAny -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{SimpleValueToAny: 101},
+			wantErrorMsg: "cbor: invalid SimpleValueToAny 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestUnmarshalSimpleValueToAny(t *testing.T) {
+	// [false, true, null, undefined, simple(99)]
+	data := hexDecode("85f4f5f6f7f863")
+
+	for _, tc := range []struct {
+		name string
+		mode SimpleValueToAnyMode
+		want []interface{}
+	}{
+		{
+			name: "SimpleValueToGoValue",
+			mode: SimpleValueToGoValue,
+			want: []interface{}{false, true, nil, nil, SimpleValue(99)},
+		},
+		{
+			name: "SimpleValueUndefinedToSimpleValue",
+			mode: SimpleValueUndefinedToSimpleValue,
+			want: []interface{}{false, true, nil, SimpleValue(23), SimpleValue(99)},
+		},
+		{
+			name: "SimpleValueToSimpleValue",
+			mode: SimpleValueToSimpleValue,
+			want: []interface{}{SimpleValue(20), SimpleValue(21), SimpleValue(22), SimpleValue(23), SimpleValue(99)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := DecOptions{SimpleValueToAny: tc.mode}.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned error %v", err)
+			}
+
+			var v interface{}
+			if err := dm.Unmarshal(data, &v); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+			}
+			if !reflect.DeepEqual(v, tc.want) {
+				t.Errorf("Unmarshal(0x%x) = %#v, want %#v", data, v, tc.want)
+			}
+
+			// Typed destinations are not affected.
+			var b []bool
+			if err := dm.Unmarshal(hexDecode("82f4f5"), &b); err != nil {
+				t.Fatalf("Unmarshal(0x82f4f5) returned error %v", err)
+			}
+			if !reflect.DeepEqual(b, []bool{false, true}) {
+				t.Errorf("Unmarshal(0x82f4f5) = %v, want [false true]", b)
+			}
+
+			if tc.mode == SimpleValueToGoValue {
+				// Undefined is lost.
+				return
+			}
+			encoded, err := Marshal(v)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned error %v", v, err)
+			}
+			if !bytes.Equal(encoded, data) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", v, encoded, data)
+			}
+		})
+	}
+}
+func isCBORNil(data []byte) bool {
+	return len(data) > 0 && (data[0] == 0xf6 || data[0] == 0xf7)
+}
+
+func TestDecModeInvalidTimeTagToAnyMode(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{TimeTagToAny: -1},
+			wantErrorMsg: "cbor: invalid TimeTagToAny -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{TimeTagToAny: 4},
+			wantErrorMsg: "cbor: invalid TimeTagToAny 4",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("Expected non nil error from DecMode()")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Expected error: %q, want: %q \n", tc.wantErrorMsg, err.Error())
+			}
+		})
+	}
+}
 
 func TestDecModeTimeTagToAny(t *testing.T) {
 	for _, tc := range []struct {
@@ -10132,3 +11220,3085 @@ func TestBignumTagMode(t *testing.T) {
 		})
 	}
 }
+
+func TestDecModeInvalidDeterministic(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{Deterministic: -1},
+			wantErrorMsg: "cbor: invalid Deterministic -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{Deterministic: 101},
+			wantErrorMsg: "cbor: invalid Deterministic 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDeterministicCoreRequired(t *testing.T) {
+	dm, err := DecOptions{Deterministic: DeterministicCoreRequired}.DecMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name           string
+		data           []byte
+		wantErrMessage string // empty if deterministic
+	}{
+		{name: "uint 23", data: hexDecode("17")},
+		{name: "uint 24", data: hexDecode("1818")},
+		{name: "uint 23 with 1-byte argument", data: hexDecode("1817"), wantErrMessage: "cbor: data item of cbor type positive integer is not accepted by protocol: non-deterministic encoding: argument not in shortest form"},
+		{name: "uint 255 with 2-byte argument", data: hexDecode("1900ff"), wantErrMessage: "cbor: data item of cbor type positive integer is not accepted by protocol: non-deterministic encoding: argument not in shortest form"},
+		{name: "uint 65535 with 4-byte argument", data: hexDecode("1a0000ffff"), wantErrMessage: "cbor: data item of cbor type positive integer is not accepted by protocol: non-deterministic encoding: argument not in shortest form"},
+		{name: "uint 4294967295 with 8-byte argument", data: hexDecode("1b00000000ffffffff"), wantErrMessage: "cbor: data item of cbor type positive integer is not accepted by protocol: non-deterministic encoding: argument not in shortest form"},
+		{name: "nint -1 with 1-byte argument", data: hexDecode("3800"), wantErrMessage: "cbor: data item of cbor type negative integer is not accepted by protocol: non-deterministic encoding: argument not in shortest form"},
+		{name: "byte string length with 1-byte argument", data: hexDecode("580101"), wantErrMessage: "cbor: data item of cbor type byte string is not accepted by protocol: non-deterministic encoding: argument not in shortest form"},
+		{name: "array length with 1-byte argument", data: hexDecode("980101"), wantErrMessage: "cbor: data item of cbor type array is not accepted by protocol: non-deterministic encoding: argument not in shortest form"},
+		{name: "tag number with 1-byte argument", data: hexDecode("d80101"), wantErrMessage: "cbor: data item of cbor type tag is not accepted by protocol: non-deterministic encoding: argument not in shortest form"},
+		{name: "nested non-shortest uint", data: hexDecode("81a1011817"), wantErrMessage: "cbor: data item of cbor type positive integer is not accepted by protocol: non-deterministic encoding: argument not in shortest form"},
+		{name: "float16 1.5", data: hexDecode("f93e00")},
+		{name: "float32 100000.0", data: hexDecode("fa47c35000")},
+		{name: "float64 1.1", data: hexDecode("fb3ff199999999999a")},
+		{name: "float32 1.5", data: hexDecode("fa3fc00000"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: non-deterministic encoding: floating-point value not in shortest form"},
+		{name: "float64 100000.0", data: hexDecode("fb40f86a0000000000"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: non-deterministic encoding: floating-point value not in shortest form"},
+		{name: "float32 infinity", data: hexDecode("fa7f800000"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: non-deterministic encoding: floating-point value not in shortest form"},
+		{name: "float64 NaN", data: hexDecode("fb7ff8000000000000"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: non-deterministic encoding: floating-point value not in shortest form"},
+		{name: "float32 NaN with payload", data: hexDecode("fa7fc00001")},
+		{name: "indefinite-length byte string", data: hexDecode("5f42010243030405ff"), wantErrMessage: "cbor: data item of cbor type byte string is not accepted by protocol: non-deterministic encoding: indefinite-length byte string"},
+		{name: "indefinite-length array", data: hexDecode("9f0102ff"), wantErrMessage: "cbor: data item of cbor type array is not accepted by protocol: non-deterministic encoding: indefinite-length array"},
+		{name: "map keys sorted bytewise", data: hexDecode("a40a011864022003616104")},
+		{name: "map keys not sorted", data: hexDecode("a220030a01"), wantErrMessage: "cbor: data item of cbor type map is not accepted by protocol: non-deterministic encoding: map keys not in bytewise lexicographic order or duplicated"},
+		{name: "map text string keys sorted bytewise", data: hexDecode("a261610162303002")},
+		{name: "duplicate map keys", data: hexDecode("a20a010a02"), wantErrMessage: "cbor: data item of cbor type map is not accepted by protocol: non-deterministic encoding: map keys not in bytewise lexicographic order or duplicated"},
+		{name: "nested map keys not sorted", data: hexDecode("a101a220030a01"), wantErrMessage: "cbor: data item of cbor type map is not accepted by protocol: non-deterministic encoding: map keys not in bytewise lexicographic order or duplicated"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var v interface{}
+			err := dm.Unmarshal(tc.data, &v)
+			if tc.wantErrMessage == "" {
+				if err != nil {
+					t.Errorf("Unmarshal(0x%x) returned error %v", tc.data, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error, want %q", tc.data, tc.wantErrMessage)
+			} else if _, ok := err.(*UnacceptableDataItemError); !ok {
+				t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want *UnacceptableDataItemError", tc.data, err)
+			} else if err.Error() != tc.wantErrMessage {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrMessage)
+			}
+		})
+	}
+}
+
+func TestCoreDetDecOptionsAcceptsCoreDetEncoding(t *testing.T) {
+	em, err := CoreDetEncOptions().EncMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm, err := CoreDetDecOptions().DecMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := map[interface{}]interface{}{
+		"a":    1.5,
+		"bb":   []interface{}{uint64(24), int64(-25), 100000.0, 1.1, math.NaN(), math.Inf(1)},
+		-1:     []byte{1, 2, 3},
+		100:    map[string]int{"z": 1, "yy": 2, "x": 3},
+		"aaaa": Tag{Number: 1000, Content: "hello"},
+	}
+	b, err := em.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+	var got interface{}
+	if err := dm.Unmarshal(b, &got); err != nil {
+		t.Errorf("Unmarshal(0x%x) returned error %v", b, err)
+	}
+}
+
+func TestDeterministicDCBORRequired(t *testing.T) {
+	dm, err := DecOptions{Deterministic: DeterministicDCBORRequired}.DecMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name           string
+		data           []byte
+		wantErrMessage string // empty if dCBOR
+	}{
+		{name: "uint 23", data: hexDecode("17")},
+		{name: "uint 23 with 1-byte argument", data: hexDecode("1817"), wantErrMessage: "cbor: data item of cbor type positive integer is not accepted by protocol: non-deterministic encoding: argument not in shortest form"},
+		{name: "nint -2^63", data: hexDecode("3b7fffffffffffffff")},
+		{name: "nint -2^63-1", data: hexDecode("3b8000000000000000"), wantErrMessage: "cbor: data item of cbor type negative integer is not accepted by protocol: 65-bit negative integer"},
+		{name: "false", data: hexDecode("f4")},
+		{name: "true", data: hexDecode("f5")},
+		{name: "null", data: hexDecode("f6")},
+		{name: "undefined", data: hexDecode("f7"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: simple value 23"},
+		{name: "simple value 16", data: hexDecode("f0"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: simple value 16"},
+		{name: "simple value 255", data: hexDecode("f8ff"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: simple value 255"},
+		{name: "float16 1.5", data: hexDecode("f93e00")},
+		{name: "float64 1.1", data: hexDecode("fb3ff199999999999a")},
+		{name: "float32 2^64", data: hexDecode("fa5f800000")},
+		{name: "float16 infinity", data: hexDecode("f97c00")},
+		{name: "float16 NaN", data: hexDecode("f97e00")},
+		{name: "float16 1.0", data: hexDecode("f93c00"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: non-deterministic encoding: floating-point value not reduced to integer"},
+		{name: "float16 -0.0", data: hexDecode("f98000"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: non-deterministic encoding: floating-point value not reduced to integer"},
+		{name: "float32 100000.0", data: hexDecode("fa47c35000"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: non-deterministic encoding: floating-point value not reduced to integer"},
+		{name: "float32 -2^63", data: hexDecode("fadf000000"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: non-deterministic encoding: floating-point value not reduced to integer"},
+		{name: "float16 NaN with payload", data: hexDecode("f97e01"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: non-deterministic encoding: NaN not encoded as 0xf97e00"},
+		{name: "float32 NaN with payload", data: hexDecode("fa7fc00001"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: non-deterministic encoding: NaN not encoded as 0xf97e00"},
+		{name: "float64 NaN", data: hexDecode("fb7ff8000000000000"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: non-deterministic encoding: floating-point value not in shortest form"},
+		{name: "indefinite-length array", data: hexDecode("9f0102ff"), wantErrMessage: "cbor: data item of cbor type array is not accepted by protocol: non-deterministic encoding: indefinite-length array"},
+		{name: "map keys not sorted", data: hexDecode("a220030a01"), wantErrMessage: "cbor: data item of cbor type map is not accepted by protocol: non-deterministic encoding: map keys not in bytewise lexicographic order or duplicated"},
+		{name: "nested undefined", data: hexDecode("a16161d82a81f7"), wantErrMessage: "cbor: data item of cbor type primitives is not accepted by protocol: simple value 23"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var v interface{}
+			err := dm.Unmarshal(tc.data, &v)
+			if tc.wantErrMessage == "" {
+				if err != nil {
+					t.Errorf("Unmarshal(0x%x) returned error %v", tc.data, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error, want %q", tc.data, tc.wantErrMessage)
+			} else if _, ok := err.(*UnacceptableDataItemError); !ok {
+				t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want *UnacceptableDataItemError", tc.data, err)
+			} else if err.Error() != tc.wantErrMessage {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrMessage)
+			}
+		})
+	}
+}
+
+func TestDCBORDecOptionsAcceptsDCBOREncoding(t *testing.T) {
+	em, err := DCBOREncOptions().EncMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm, err := DCBORDecOptions().DecMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := map[interface{}]interface{}{
+		"a":    1.5,
+		"bb":   []interface{}{uint64(24), int64(-25), 100000.0, -0.0, 1.1, math.NaN(), math.Inf(1), nil, true},
+		-1:     []byte{1, 2, 3},
+		100:    map[string]float32{"z": 1, "yy": 2.5, "x": 3},
+		"aaaa": Tag{Number: 1000, Content: "hello"},
+	}
+	b, err := em.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+	var got interface{}
+	if err := dm.Unmarshal(b, &got); err != nil {
+		t.Errorf("Unmarshal(0x%x) returned error %v", b, err)
+	}
+}
+
+func TestUntrustedDecMode(t *testing.T) {
+	dm := UntrustedDecMode()
+	if opts := dm.DecOptions(); opts != UntrustedDecOptions() {
+		t.Errorf("UntrustedDecMode().DecOptions() = %+v, want %+v", opts, UntrustedDecOptions())
+	}
+
+	var v struct {
+		A string          `cbor:"a"`
+		B []int           `cbor:"b"`
+		T time.Time       `cbor:"t"`
+		M map[string]bool `cbor:"m"`
+	}
+	data := hexDecode("a46161617861628201026174c11a514b67b0616da16178f5") // {"a": "x", "b": [1, 2], "t": 1(1363896240), "m": {"x": true}}
+	if err := dm.Unmarshal(data, &v); err != nil {
+		t.Errorf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "duplicate map key",
+			data:         hexDecode("a2616101616102"), // {"a": 1, "a": 2}
+			wantErrorMsg: "cbor: found duplicate map key \"a\" at map element index 1",
+		},
+		{
+			name:         "invalid UTF-8 text string",
+			data:         hexDecode("61fe"),
+			wantErrorMsg: "cbor: invalid UTF-8 string",
+		},
+		{
+			name:         "indefinite-length array",
+			data:         hexDecode("9f01ff"),
+			wantErrorMsg: "cbor: indefinite-length array isn't allowed",
+		},
+		{
+			name:         "bignum",
+			data:         hexDecode("c249010000000000000000"), // 18446744073709551616
+			wantErrorMsg: "cbor: data item of cbor type tag is not accepted by protocol: tag number 2",
+		},
+		{
+			name:         "tag enclosed in allowed tag",
+			data:         hexDecode("c1d9d9f71a514b67b0"), // 1(55799(1363896240))
+			wantErrorMsg: "cbor: data item of cbor type tag is not accepted by protocol: tag number 55799",
+		},
+		{
+			name:         "exceeding max nested levels",
+			data:         hexDecode(strings.Repeat("81", 17) + "01"),
+			wantErrorMsg: "cbor: exceeded max nested level 16",
+		},
+		{
+			name:         "exceeding max array elements",
+			data:         hexDecode("991001" + strings.Repeat("00", 4097)),
+			wantErrorMsg: "cbor: exceeded max number of elements 4096 for CBOR array",
+		},
+		{
+			name:         "exceeding max string bytes",
+			data:         hexDecode("7a00010001" + strings.Repeat("61", 65537)),
+			wantErrorMsg: "cbor: exceeded max length 65536 bytes for CBOR UTF-8 text string",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var v interface{}
+			err := dm.Unmarshal(tc.data, &v)
+			if err == nil {
+				t.Fatalf("Unmarshal() didn't return an error")
+			}
+			if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDecodeAllowedTags(t *testing.T) {
+	type myInt int
+
+	tags := NewTagSet()
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagRequired}, reflect.TypeOf(myInt(0)), 101); err != nil {
+		t.Fatalf("TagSet.Add() returned error %v", err)
+	}
+	dm, err := DecOptions{AllowedTags: NewTagNumbers(100)}.DecModeWithTags(tags)
+	if err != nil {
+		t.Fatalf("DecModeWithTags() returned error %v", err)
+	}
+
+	data := hexDecode("d86401") // 100(1)
+	var v interface{}
+	if err := dm.Unmarshal(data, &v); err != nil {
+		t.Errorf("Unmarshal(0x%x) returned error %v", data, err)
+	} else if want := (Tag{Number: 100, Content: uint64(1)}); !reflect.DeepEqual(v, want) {
+		t.Errorf("Unmarshal(0x%x) = %v, want %v", data, v, want)
+	}
+
+	for _, tc := range []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "tag isn't listed",
+			data:         hexDecode("d86501"), // 101(1)
+			wantErrorMsg: "cbor: data item of cbor type tag is not accepted by protocol: tag number 101",
+		},
+		{
+			name:         "nested tag isn't listed",
+			data:         hexDecode("d864d86501"), // 100(101(1))
+			wantErrorMsg: "cbor: data item of cbor type tag is not accepted by protocol: tag number 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var v myInt
+			if err := dm.Unmarshal(tc.data, &v); err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error", tc.data)
+			} else if _, ok := err.(*UnacceptableDataItemError); !ok {
+				t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*UnacceptableDataItemError)", tc.data, err)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+type testPayload interface {
+	payloadKind() string
+}
+
+type testKnownPayload struct {
+	_    struct{} `cbor:",toarray"`
+	Name string
+}
+
+func (p testKnownPayload) payloadKind() string { return "known" }
+
+type testGenericPayload struct {
+	Raw RawMessage
+}
+
+func (p *testGenericPayload) payloadKind() string { return "generic" }
+
+func (p *testGenericPayload) UnmarshalCBOR(data []byte) error {
+	return p.Raw.UnmarshalCBOR(data)
+}
+
+func TestDecModeInvalidInterfaceFallbackTypes(t *testing.T) {
+	typePayload := reflect.TypeOf((*testPayload)(nil)).Elem()
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "key is not interface",
+			opts:         DecOptions{InterfaceFallbackTypes: NewInterfaceFallbackTypes(map[reflect.Type]reflect.Type{reflect.TypeOf(0): reflect.TypeOf(0)})},
+			wantErrorMsg: "cbor: invalid InterfaceFallbackTypes: int is not a non-empty interface type",
+		},
+		{
+			name:         "key is empty interface",
+			opts:         DecOptions{InterfaceFallbackTypes: NewInterfaceFallbackTypes(map[reflect.Type]reflect.Type{typeIntf: reflect.TypeOf(0)})},
+			wantErrorMsg: "cbor: invalid InterfaceFallbackTypes: interface {} is not a non-empty interface type",
+		},
+		{
+			name:         "nil value",
+			opts:         DecOptions{InterfaceFallbackTypes: NewInterfaceFallbackTypes(map[reflect.Type]reflect.Type{typePayload: nil})},
+			wantErrorMsg: "cbor: invalid InterfaceFallbackTypes: <nil> does not implement cbor.testPayload",
+		},
+		{
+			name:         "value doesn't implement interface",
+			opts:         DecOptions{InterfaceFallbackTypes: NewInterfaceFallbackTypes(map[reflect.Type]reflect.Type{typePayload: reflect.TypeOf("")})},
+			wantErrorMsg: "cbor: invalid InterfaceFallbackTypes: string does not implement cbor.testPayload",
+		},
+		{
+			name:         "value is interface",
+			opts:         DecOptions{InterfaceFallbackTypes: NewInterfaceFallbackTypes(map[reflect.Type]reflect.Type{typePayload: typePayload})},
+			wantErrorMsg: "cbor: invalid InterfaceFallbackTypes: cbor.testPayload does not implement cbor.testPayload",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestUnmarshalToInterfaceWithFallbackType(t *testing.T) {
+	typePayload := reflect.TypeOf((*testPayload)(nil)).Elem()
+
+	tags := NewTagSet()
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagRequired}, reflect.TypeOf(testKnownPayload{}), 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DecOptions{
+		InterfaceFallbackTypes: NewInterfaceFallbackTypes(map[reflect.Type]reflect.Type{
+			typePayload: reflect.TypeOf(testGenericPayload{}),
+		}),
+	}
+
+	// Decoding fails without fallback type.
+	dmWithoutFallback, err := DecOptions{}.DecModeWithTags(tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := hexDecode("83d903e8816161d903e9820102f6") // [1000([\"a\"]), 1001([1, 2]), null]
+	var payloads []testPayload
+	if err = dmWithoutFallback.Unmarshal(data, &payloads); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+	} else if _, ok := err.(*UnmarshalTypeError); !ok {
+		t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want *UnmarshalTypeError", data, err)
+	}
+
+	dm, err := opts.DecModeWithTags(tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads = nil
+	if err = dm.Unmarshal(data, &payloads); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	if len(payloads) != 3 {
+		t.Fatalf("Unmarshal(0x%x) = %v, want 3 elements", data, payloads)
+	}
+	if p, ok := payloads[0].(*testKnownPayload); !ok || p.Name != "a" {
+		t.Errorf("Unmarshal(0x%x) element 0 = %#v, want &testKnownPayload{Name: \"a\"}", data, payloads[0])
+	}
+	wantRaw := RawMessage(hexDecode("d903e9820102"))
+	if p, ok := payloads[1].(*testGenericPayload); !ok || !bytes.Equal(p.Raw, wantRaw) {
+		t.Errorf("Unmarshal(0x%x) element 1 = %#v, want &testGenericPayload{Raw: 0x%x}", data, payloads[1], wantRaw)
+	}
+	if payloads[2] != nil {
+		t.Errorf("Unmarshal(0x%x) element 2 = %#v, want nil", data, payloads[2])
+	}
+
+	// Fallback type is also used without registered tags.
+	dm, err = opts.DecMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p testPayload
+	if err = dm.Unmarshal(hexDecode("01"), &p); err != nil {
+		t.Fatalf("Unmarshal() returned error %v", err)
+	}
+	if gp, ok := p.(*testGenericPayload); !ok || !bytes.Equal(gp.Raw, []byte{0x01}) {
+		t.Errorf("Unmarshal() = %#v, want &testGenericPayload{Raw: 0x01}", p)
+	}
+}
+
+func TestDecModeInvalidMapKeyUTF8(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{MapKeyUTF8: -1},
+			wantErrorMsg: "cbor: invalid MapKeyUTF8 -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{MapKeyUTF8: 101},
+			wantErrorMsg: "cbor: invalid MapKeyUTF8 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestMapKeyUTF8(t *testing.T) {
+	type s struct {
+		A string `cbor:"a"`
+	}
+
+	// {"a": "\xff"}
+	invalidValue := hexDecode("a1616161ff")
+	// {"\xff": "a"}
+	invalidKey := hexDecode("a161ff6161")
+	// {["\xff"]: "a"}
+	invalidNestedKey := hexDecode("a18161ff6161")
+
+	for _, tc := range []struct {
+		name       string
+		opts       DecOptions
+		data       []byte
+		dstType    reflect.Type
+		wantErrMsg string
+	}{
+		{name: "default rejects invalid value", data: invalidValue, dstType: reflect.TypeOf(map[string]string(nil)), wantErrMsg: "cbor: invalid UTF-8 string"},
+		{name: "default rejects invalid key", data: invalidKey, dstType: reflect.TypeOf(map[string]string(nil)), wantErrMsg: "cbor: invalid UTF-8 string"},
+		{name: "UTF8DecodeInvalid accepts invalid key", opts: DecOptions{UTF8: UTF8DecodeInvalid}, data: invalidKey, dstType: reflect.TypeOf(map[string]string(nil))},
+		{name: "MapKeyUTF8RejectInvalid accepts invalid value", opts: DecOptions{UTF8: UTF8DecodeInvalid, MapKeyUTF8: MapKeyUTF8RejectInvalid}, data: invalidValue, dstType: reflect.TypeOf(map[string]string(nil))},
+		{name: "MapKeyUTF8RejectInvalid accepts invalid value to struct", opts: DecOptions{UTF8: UTF8DecodeInvalid, MapKeyUTF8: MapKeyUTF8RejectInvalid}, data: invalidValue, dstType: reflect.TypeOf(s{})},
+		{name: "MapKeyUTF8RejectInvalid accepts invalid value to empty interface", opts: DecOptions{UTF8: UTF8DecodeInvalid, MapKeyUTF8: MapKeyUTF8RejectInvalid}, data: invalidValue, dstType: typeIntf},
+		{name: "MapKeyUTF8RejectInvalid rejects invalid key", opts: DecOptions{UTF8: UTF8DecodeInvalid, MapKeyUTF8: MapKeyUTF8RejectInvalid}, data: invalidKey, dstType: reflect.TypeOf(map[string]string(nil)), wantErrMsg: "cbor: invalid UTF-8 string"},
+		{name: "MapKeyUTF8RejectInvalid rejects invalid key to struct", opts: DecOptions{UTF8: UTF8DecodeInvalid, MapKeyUTF8: MapKeyUTF8RejectInvalid}, data: invalidKey, dstType: reflect.TypeOf(s{}), wantErrMsg: "cbor: invalid UTF-8 string"},
+		{name: "MapKeyUTF8RejectInvalid rejects invalid key to empty interface", opts: DecOptions{UTF8: UTF8DecodeInvalid, MapKeyUTF8: MapKeyUTF8RejectInvalid}, data: invalidKey, dstType: typeIntf, wantErrMsg: "cbor: invalid UTF-8 string"},
+		{name: "MapKeyUTF8RejectInvalid rejects invalid nested key", opts: DecOptions{UTF8: UTF8DecodeInvalid, MapKeyUTF8: MapKeyUTF8RejectInvalid}, data: invalidNestedKey, dstType: reflect.TypeOf(map[[1]string]string(nil)), wantErrMsg: "cbor: invalid UTF-8 string"},
+		{name: "MapKeyUTF8DecodeInvalid rejects invalid value", opts: DecOptions{MapKeyUTF8: MapKeyUTF8DecodeInvalid}, data: invalidValue, dstType: reflect.TypeOf(map[string]string(nil)), wantErrMsg: "cbor: invalid UTF-8 string"},
+		{name: "MapKeyUTF8DecodeInvalid accepts invalid key", opts: DecOptions{MapKeyUTF8: MapKeyUTF8DecodeInvalid}, data: invalidKey, dstType: reflect.TypeOf(map[string]string(nil))},
+		{name: "MapKeyUTF8DecodeInvalid accepts invalid nested key", opts: DecOptions{MapKeyUTF8: MapKeyUTF8DecodeInvalid}, data: invalidNestedKey, dstType: reflect.TypeOf(map[[1]string]string(nil))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := tc.opts.DecMode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := reflect.New(tc.dstType)
+			err = dm.Unmarshal(tc.data, v.Interface())
+			if tc.wantErrMsg == "" {
+				if err != nil {
+					t.Errorf("Unmarshal(0x%x) returned error %v", tc.data, err)
+				}
+			} else if err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error, want %q", tc.data, tc.wantErrMsg)
+			} else if err.Error() != tc.wantErrMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestDecModeInvalidTextStringToByteSlice(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{TextStringToByteSlice: -1},
+			wantErrorMsg: "cbor: invalid TextStringToByteSlice -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{TextStringToByteSlice: 101},
+			wantErrorMsg: "cbor: invalid TextStringToByteSlice 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDecModeInvalidBigFloatOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "precision above big.MaxPrec",
+			opts:         DecOptions{BigFloatPrecision: big.MaxPrec + 1},
+			wantErrorMsg: "cbor: invalid BigFloatPrecision 4294967296 (range is [0, 4294967295])",
+		},
+		{
+			name:         "rounding mode above range of valid modes",
+			opts:         DecOptions{BigFloatRoundingMode: 101},
+			wantErrorMsg: "cbor: invalid BigFloatRoundingMode 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestTextStringToByteSlice(t *testing.T) {
+	type namedBytes []byte
+
+	type message struct {
+		ID      []byte     `cbor:"1,keyasint"`
+		Payload string     `cbor:"2,keyasint"`
+		Extra   namedBytes `cbor:"3,keyasint"`
+	}
+
+	// {1: "abc", 2: h'010203', 3: (_ "d", "e")}
+	data := hexDecode("a301636162630243010203037f61646165ff")
+
+	for _, tc := range []struct {
+		name       string
+		opts       DecOptions
+		want       message
+		wantErrMsg string
+	}{
+		{
+			name:       "default",
+			opts:       DecOptions{ByteStringToString: ByteStringToStringAllowed},
+			wantErrMsg: "cbor: cannot unmarshal UTF-8 text string into Go struct field cbor.message.1 of type []uint8",
+		},
+		{
+			name: "TextStringToByteSliceAllowed",
+			opts: DecOptions{ByteStringToString: ByteStringToStringAllowed, TextStringToByteSlice: TextStringToByteSliceAllowed},
+			want: message{ID: []byte("abc"), Payload: "\x01\x02\x03", Extra: namedBytes("de")},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := tc.opts.DecMode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got message
+			err = dm.Unmarshal(data, &got)
+			if tc.wantErrMsg != "" {
+				if err == nil {
+					t.Errorf("Unmarshal(0x%x) didn't return an error, want %q", data, tc.wantErrMsg)
+				} else if err.Error() != tc.wantErrMsg {
+					t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), tc.wantErrMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Unmarshal(0x%x) = %+v, want %+v", data, got, tc.want)
+			}
+		})
+	}
+
+	// Decoded byte slice doesn't share underlying array with CBOR data.
+	dm, err := DecOptions{TextStringToByteSlice: TextStringToByteSliceAllowed}.DecMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	textData := hexDecode("63616263")
+	var b []byte
+	if err := dm.Unmarshal(textData, &b); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", textData, err)
+	}
+	textData[1] = 'x'
+	if string(b) != "abc" {
+		t.Errorf("Unmarshal(0x%x) = %q, want %q", textData, b, "abc")
+	}
+}
+
+func TestStructFieldRawOption(t *testing.T) {
+	type header []byte
+	type withRaw struct {
+		A int
+		B header `cbor:"b,raw"`
+		C []byte `cbor:"c,raw,omitempty"`
+	}
+	type coseSign1 struct {
+		_           struct{} `cbor:",toarray"`
+		Protected   []byte   `cbor:",raw"`
+		Unprotected map[int]interface{}
+		Payload     []byte
+	}
+
+	testCases := []struct {
+		name string
+		data []byte
+		obj  interface{}
+		want interface{}
+	}{
+		{
+			name: "map",
+			// {"A": 1, "b": {4: h'01', 1: -7}}, raw field "b" has unsorted map and non-shortest integer
+			data: hexDecode("a26141016162a21a0000000441010126"),
+			obj:  &withRaw{},
+			want: &withRaw{A: 1, B: header(hexDecode("a21a0000000441010126"))},
+		},
+		{
+			name: "toarray",
+			// [h'A10126', {}, h'74657374']
+			data: hexDecode("8343a10126a04474657374"),
+			obj:  &coseSign1{},
+			want: &coseSign1{Protected: hexDecode("43a10126"), Unprotected: map[int]interface{}{}, Payload: []byte("test")},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Unmarshal(tc.data, tc.obj); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if !reflect.DeepEqual(tc.obj, tc.want) {
+				t.Errorf("Unmarshal(0x%x) = %+v, want %+v", tc.data, tc.obj, tc.want)
+			}
+
+			b, err := Marshal(tc.obj)
+			if err != nil {
+				t.Fatalf("Marshal(%+v) returned error %v", tc.obj, err)
+			}
+			if !bytes.Equal(b, tc.data) {
+				t.Errorf("Marshal(%+v) = 0x%x, want 0x%x", tc.obj, b, tc.data)
+			}
+		})
+	}
+
+	// Decoded raw field doesn't share memory with input data.
+	data := hexDecode("a2614101616201")
+	var v withRaw
+	if err := Unmarshal(data, &v); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	data[len(data)-1] = 0x02
+	if !bytes.Equal(v.B, []byte{0x01}) {
+		t.Errorf("raw field shares memory with input data")
+	}
+
+	// Empty raw field is encoded as CBOR nil.
+	b, err := Marshal(withRaw{A: 1})
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+	if want := hexDecode("a26141016162f6"); !bytes.Equal(b, want) {
+		t.Errorf("Marshal() = 0x%x, want 0x%x", b, want)
+	}
+
+	// Malformed raw field isn't encoded.
+	if _, err := Marshal(withRaw{B: header{0x82, 0x01}}); err == nil {
+		t.Errorf("Marshal() didn't return an error")
+	} else if _, ok := err.(*MarshalerError); !ok {
+		t.Errorf("Marshal() returned wrong error type %T, want (*MarshalerError)", err)
+	}
+}
+
+func TestStructFieldRawOptionInvalidType(t *testing.T) {
+	type invalidRaw struct {
+		A int `cbor:"a,raw"`
+	}
+	wantErrorMsg := "cbor: field cbor.invalidRaw.a with \"raw\" option must be a byte slice, got int"
+
+	if _, err := Marshal(invalidRaw{}); err == nil {
+		t.Errorf("Marshal() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Marshal() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+
+	type invalidRawToArray struct {
+		_ struct{} `cbor:",toarray"`
+		A int      `cbor:"a,raw"`
+	}
+	if _, err := Marshal(invalidRawToArray{}); err == nil {
+		t.Errorf("Marshal() didn't return an error")
+	}
+
+	data := hexDecode("a1616101")
+	var v invalidRaw
+	if err := Unmarshal(data, &v); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
+	}
+}
+
+func TestDecModeInvalidZeroCopy(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{ZeroCopy: -1},
+			wantErrorMsg: "cbor: invalid ZeroCopy -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{ZeroCopy: 101},
+			wantErrorMsg: "cbor: invalid ZeroCopy 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestZeroCopy(t *testing.T) {
+	type s struct {
+		A []byte     `cbor:"a"`
+		B RawMessage `cbor:"b"`
+		C []byte     `cbor:"c,raw"`
+		D []byte     `cbor:"d,copy"`
+		E []byte     `cbor:"e,raw,copy"`
+		F struct {
+			G []byte `cbor:"g"`
+		} `cbor:"f,copy"`
+	}
+
+	// {"a": h'01', "b": [1], "c": h'02', "d": h'03', "e": h'04', "f": {"g": h'05'}}
+	data := hexDecode("a661614101616281016163410261644103616541046166a161674105")
+	aliases := func(b []byte) bool {
+		return len(b) > 0 && &b[0] == &data[bytes.Index(data, b)]
+	}
+
+	for _, tc := range []struct {
+		name        string
+		zeroCopy    ZeroCopyMode
+		wantAliased map[string]bool
+	}{
+		{
+			name:        "ZeroCopyNone",
+			zeroCopy:    ZeroCopyNone,
+			wantAliased: map[string]bool{},
+		},
+		{
+			name:        "ZeroCopyBytes",
+			zeroCopy:    ZeroCopyBytes,
+			wantAliased: map[string]bool{"a": true, "b": true, "c": true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := DecOptions{ZeroCopy: tc.zeroCopy}.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned error %v", err)
+			}
+
+			var v s
+			if err := dm.Unmarshal(data, &v); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+			}
+			for name, b := range map[string][]byte{"a": v.A, "b": v.B, "c": v.C, "d": v.D, "e": v.E, "g": v.F.G} {
+				if aliases(b) != tc.wantAliased[name] {
+					t.Errorf("field %q shares memory with input data: %t, want %t", name, aliases(b), tc.wantAliased[name])
+				}
+			}
+
+			var i interface{}
+			if err := dm.Unmarshal(hexDecode("4101"), &i); err != nil {
+				t.Fatalf("Unmarshal() returned error %v", err)
+			}
+			if !bytes.Equal(i.([]byte), []byte{1}) {
+				t.Errorf("Unmarshal() = %v, want [1]", i)
+			}
+		})
+	}
+}
+
+func TestDecModeInvalidReuse(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{Reuse: -1},
+			wantErrorMsg: "cbor: invalid Reuse -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{Reuse: 101},
+			wantErrorMsg: "cbor: invalid Reuse 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestReuseContainers(t *testing.T) {
+	type item struct {
+		B []byte `cbor:"b"`
+		N []int  `cbor:"n"`
+	}
+	type s struct {
+		S []item          `cbor:"s"`
+		M map[string]item `cbor:"m"`
+		P *item           `cbor:"p"`
+		X int             `cbor:"x"`
+	}
+
+	newItem := func() item {
+		return item{B: append(make([]byte, 0, 8), 9, 9), N: append(make([]int, 0, 8), 9, 9)}
+	}
+	newValue := func() s {
+		p := newItem()
+		return s{
+			S: []item{newItem(), newItem()},
+			M: map[string]item{"k": newItem(), "stale": newItem()},
+			P: &p,
+			X: 42,
+		}
+	}
+
+	// {"s": [{"b": h'01', "n": [1]}], "m": {"k": {"b": h'02'}}, "p": {"n": [2, 3]}}
+	data := hexDecode("a3617381a261624101616e8101616da1616ba1616241026170a1616e820203")
+
+	t.Run("ReuseNone", func(t *testing.T) {
+		v := newValue()
+		if err := Unmarshal(data, &v); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+		}
+		if v.X != 42 {
+			t.Errorf("Unmarshal(0x%x) reset absent field X to %d, want 42", data, v.X)
+		}
+		if _, ok := v.M["stale"]; !ok {
+			t.Errorf("Unmarshal(0x%x) deleted absent map entry", data)
+		}
+	})
+
+	t.Run("ReuseContainers", func(t *testing.T) {
+		dm, err := DecOptions{Reuse: ReuseContainers}.DecMode()
+		if err != nil {
+			t.Fatalf("DecMode() returned error %v", err)
+		}
+
+		v := newValue()
+		s0 := &v.S[0]
+		s0B, s0N := &v.S[0].B[:1][0], &v.S[0].N[:1][0]
+		m := reflect.ValueOf(v.M).Pointer()
+		mB := &v.M["k"].B[:1][0]
+		p := v.P
+		pN := &v.P.N[:1][0]
+
+		if err := dm.Unmarshal(data, &v); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+		}
+
+		want := s{
+			S: []item{{B: []byte{1}, N: []int{1}}},
+			M: map[string]item{"k": {B: []byte{2}, N: []int{}}},
+			P: &item{B: []byte{}, N: []int{2, 3}},
+		}
+		if !reflect.DeepEqual(v, want) {
+			t.Errorf("Unmarshal(0x%x) = %+v, want %+v", data, v, want)
+		}
+
+		for _, tc := range []struct {
+			name string
+			same bool
+		}{
+			{"slice", &v.S[0] == s0},
+			{"byte slice in slice element", &v.S[0].B[0] == s0B},
+			{"int slice in slice element", &v.S[0].N[0] == s0N},
+			{"map", reflect.ValueOf(v.M).Pointer() == m},
+			{"byte slice in map value", &v.M["k"].B[0] == mB},
+			{"pointer", v.P == p},
+			{"int slice in pointed-to value", &v.P.N[0] == pN},
+		} {
+			if !tc.same {
+				t.Errorf("Unmarshal(0x%x) didn't reuse %s", data, tc.name)
+			}
+		}
+	})
+
+	t.Run("ReuseContainers null to struct", func(t *testing.T) {
+		dm, err := DecOptions{Reuse: ReuseContainers}.DecMode()
+		if err != nil {
+			t.Fatalf("DecMode() returned error %v", err)
+		}
+
+		v := newItem()
+		if err := dm.Unmarshal(hexDecode("f6"), &v); err != nil {
+			t.Fatalf("Unmarshal(0xf6) returned error %v", err)
+		}
+		want := item{B: []byte{}, N: []int{}}
+		if !reflect.DeepEqual(v, want) {
+			t.Errorf("Unmarshal(0xf6) = %+v, want %+v", v, want)
+		}
+	})
+}
+
+func TestDecModeInvalidDuration(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{Duration: -1},
+			wantErrorMsg: "cbor: invalid Duration -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{Duration: 101},
+			wantErrorMsg: "cbor: invalid Duration 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDecodeDuration(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		mode DurationDecMode
+		data []byte
+		want time.Duration
+	}{
+		{"nanoseconds", DurationDecNanoseconds, hexDecode("1a59682f00"), 1500 * time.Millisecond},
+		{"nanoseconds tag 1002", DurationDecNanoseconds, hexDecode("d903eaa20101281a1dcd6500"), 1500 * time.Millisecond},
+		{"seconds integer", DurationDecSeconds, hexDecode("02"), 2 * time.Second},
+		{"seconds negative integer", DurationDecSeconds, hexDecode("21"), -2 * time.Second},
+		{"seconds float16", DurationDecSeconds, hexDecode("f93e00"), 1500 * time.Millisecond},
+		{"seconds float64", DurationDecSeconds, hexDecode("fbbff8000000000000"), -1500 * time.Millisecond},
+		{"seconds tag 1002", DurationDecSeconds, hexDecode("d903eaa20121281a1dcd6500"), -1500 * time.Millisecond},
+		{"tag 1002 milliseconds", DurationDecNanoseconds, hexDecode("d903eaa20101221901f4"), 1500 * time.Millisecond},
+		{"tag 1002 float base time", DurationDecNanoseconds, hexDecode("d903eaa101fb3ff8000000000000"), 1500 * time.Millisecond},
+		{"tag 1002 indefinite length map", DurationDecNanoseconds, hexDecode("d903eabf0102ff"), 2 * time.Second},
+		{"tag 1002 elective keys", DurationDecNanoseconds, hexDecode("d903eaa3617800010205f6"), 2 * time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := DecOptions{Duration: tc.mode}.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned an error %v", err)
+			}
+			var d time.Duration
+			if err = dm.Unmarshal(tc.data, &d); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned an error %v", tc.data, err)
+			}
+			if d != tc.want {
+				t.Errorf("Unmarshal(0x%x) = %v, want %v", tc.data, d, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeDurationError(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		mode         DurationDecMode
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "float as nanoseconds",
+			mode:         DurationDecNanoseconds,
+			data:         hexDecode("f93e00"),
+			wantErrorMsg: "cbor: cannot unmarshal primitives into Go value of type time.Duration",
+		},
+		{
+			name:         "seconds overflow",
+			mode:         DurationDecSeconds,
+			data:         hexDecode("1b7fffffffffffffff"),
+			wantErrorMsg: "cbor: cannot unmarshal positive integer into Go value of type time.Duration (9223372036854775807 seconds overflows Go's time.Duration)",
+		},
+		{
+			name:         "seconds NaN",
+			mode:         DurationDecSeconds,
+			data:         hexDecode("f97e00"),
+			wantErrorMsg: "cbor: cannot unmarshal primitives into Go value of type time.Duration (NaN overflows Go's time.Duration)",
+		},
+		{
+			name:         "tag 1002 without map",
+			mode:         DurationDecNanoseconds,
+			data:         hexDecode("d903ea01"),
+			wantErrorMsg: "cbor: cannot unmarshal positive integer into Go value of type time.Duration (tag number 1002 must be followed by map)",
+		},
+		{
+			name:         "tag 1002 missing base time",
+			mode:         DurationDecNanoseconds,
+			data:         hexDecode("d903eaa0"),
+			wantErrorMsg: "cbor: missing base time (key 1) in tag number 1002",
+		},
+		{
+			name:         "tag 1002 unsupported critical key",
+			mode:         DurationDecNanoseconds,
+			data:         hexDecode("d903eaa201012000"),
+			wantErrorMsg: "cbor: unsupported critical key -1 in tag number 1002",
+		},
+		{
+			name:         "tag 1002 fractional seconds too large",
+			mode:         DurationDecNanoseconds,
+			data:         hexDecode("d903eaa20101221903e8"),
+			wantErrorMsg: "cbor: invalid fractional seconds in tag number 1002",
+		},
+		{
+			name:         "tag 1002 fractional seconds with float base time",
+			mode:         DurationDecNanoseconds,
+			data:         hexDecode("d903eaa201f93e002201"),
+			wantErrorMsg: "cbor: fractional seconds can't be used with floating-point base time in tag number 1002",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := DecOptions{Duration: tc.mode}.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned an error %v", err)
+			}
+			var d time.Duration
+			err = dm.Unmarshal(tc.data, &d)
+			if err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error", tc.data)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDecodeExtendedTime(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		timeTag DecTagMode
+		data    []byte
+		want    time.Time
+	}{
+		{"nanoseconds", DecTagIgnored, hexDecode("d903e9a2011a6553f100281a075bcd15"), time.Unix(1700000000, 123456789)},
+		{"microseconds", DecTagIgnored, hexDecode("d903e9a2011a6553f100251a0001e240"), time.Unix(1700000000, 123456000)},
+		{"float base time", DecTagIgnored, hexDecode("d903e9a101fb3ff8000000000000"), time.Unix(1, 500000000)},
+		{"TimeTag optional", DecTagOptional, hexDecode("d903e9a1011a6553f100"), time.Unix(1700000000, 0)},
+		{"TimeTag required", DecTagRequired, hexDecode("d903e9a1011a6553f100"), time.Unix(1700000000, 0)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := DecOptions{TimeTag: tc.timeTag}.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned an error %v", err)
+			}
+			var tm time.Time
+			if err = dm.Unmarshal(tc.data, &tm); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned an error %v", tc.data, err)
+			}
+			if !tm.Equal(tc.want) {
+				t.Errorf("Unmarshal(0x%x) = %v, want %v", tc.data, tm, tc.want)
+			}
+		})
+	}
+
+	// Extended time with elective key is followed by other data items.
+	type st struct {
+		_ struct{} `cbor:",toarray"`
+		T time.Time
+		B bool
+	}
+	data := hexDecode("82d903e9a2011a6553f100617800f5")
+	var v st
+	if err := Unmarshal(data, &v); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned an error %v", data, err)
+	}
+	if !v.T.Equal(time.Unix(1700000000, 0)) || !v.B {
+		t.Errorf("Unmarshal(0x%x) = %+v, want {T:%v B:true}", data, v, time.Unix(1700000000, 0))
+	}
+}
+
+func TestDecodeBudget(t *testing.T) {
+	type budgetCall struct {
+		items int
+		bytes int
+	}
+
+	// Array of 2050 zeros contains 2051 data items in 2053 bytes.
+	data := append(hexDecode("990802"), make([]byte, 2050)...)
+
+	var calls []budgetCall
+	dm, err := DecOptions{
+		Budget: NewBudget(func(itemsDecoded int, bytesConsumed int) error {
+			calls = append(calls, budgetCall{itemsDecoded, bytesConsumed})
+			return nil
+		}),
+		MaxArrayElements: 4096,
+	}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned an error %v", err)
+	}
+	if dm.DecOptions().Budget == nil {
+		t.Errorf("DecOptions().Budget is nil")
+	}
+
+	var v []int
+	if err = dm.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Unmarshal() returned an error %v", err)
+	}
+	if len(v) != 2050 {
+		t.Errorf("Unmarshal() decoded %d elements, want 2050", len(v))
+	}
+	wantCalls := []budgetCall{{1024, 1026}, {2048, 2050}, {2051, 2053}}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("Budget called with %v, want %v", calls, wantCalls)
+	}
+}
+
+func TestDecodeBudgetError(t *testing.T) {
+	errBudgetExceeded := errors.New("budget exceeded")
+
+	data := append(hexDecode("990802"), make([]byte, 2050)...)
+
+	dm, err := DecOptions{
+		Budget: NewBudget(func(itemsDecoded int, bytesConsumed int) error {
+			if itemsDecoded > 1000 {
+				return errBudgetExceeded
+			}
+			return nil
+		}),
+		MaxArrayElements: 4096,
+	}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned an error %v", err)
+	}
+
+	var v []int
+	err = dm.Unmarshal(data, &v)
+	if err != errBudgetExceeded {
+		t.Errorf("Unmarshal() returned error %v, want %v", err, errBudgetExceeded)
+	}
+	if v != nil {
+		t.Errorf("Unmarshal() decoded %v on error, want nil", v)
+	}
+
+	dec := dm.NewDecoder(bytes.NewReader(data))
+	if err = dec.Decode(&v); err != errBudgetExceeded {
+		t.Errorf("Decode() returned error %v, want %v", err, errBudgetExceeded)
+	}
+}
+
+func TestDecModeInvalidArrayLength(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{ArrayLength: -1},
+			wantErrorMsg: "cbor: invalid ArrayLength -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{ArrayLength: 101},
+			wantErrorMsg: "cbor: invalid ArrayLength 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDecodeArrayLength(t *testing.T) {
+	dmExact, err := DecOptions{ArrayLength: ArrayLengthExact}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		data      []byte
+		wantValue [2]int
+		wantErr   error // with ArrayLengthExact
+	}{
+		{
+			name:      "same length",
+			data:      hexDecode("820102"),
+			wantValue: [2]int{1, 2},
+		},
+		{
+			name:      "same length indefinite-length",
+			data:      hexDecode("9f0102ff"),
+			wantValue: [2]int{1, 2},
+		},
+		{
+			name:      "longer CBOR array",
+			data:      hexDecode("83010203"),
+			wantValue: [2]int{1, 2},
+			wantErr:   &ArrayLengthError{GoType: "[2]int", CBORLength: 3},
+		},
+		{
+			name:      "longer indefinite-length CBOR array",
+			data:      hexDecode("9f010203ff"),
+			wantValue: [2]int{1, 2},
+			wantErr:   &ArrayLengthError{GoType: "[2]int", CBORLength: 3},
+		},
+		{
+			name:      "shorter CBOR array",
+			data:      hexDecode("8101"),
+			wantValue: [2]int{1, 0},
+			wantErr:   &ArrayLengthError{GoType: "[2]int", CBORLength: 1},
+		},
+		{
+			name:      "empty CBOR array",
+			data:      hexDecode("80"),
+			wantValue: [2]int{},
+			wantErr:   &ArrayLengthError{GoType: "[2]int", CBORLength: 0},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := [2]int{8, 9}
+			if err := Unmarshal(tc.data, &v); err != nil {
+				t.Errorf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if v != tc.wantValue {
+				t.Errorf("Unmarshal(0x%x) = %v, want %v", tc.data, v, tc.wantValue)
+			}
+
+			v = [2]int{8, 9}
+			err := dmExact.Unmarshal(tc.data, &v)
+			if !reflect.DeepEqual(err, tc.wantErr) {
+				t.Errorf("Unmarshal(0x%x) returned error %v, want %v", tc.data, err, tc.wantErr)
+			}
+			if v != tc.wantValue {
+				t.Errorf("Unmarshal(0x%x) = %v, want %v", tc.data, v, tc.wantValue)
+			}
+		})
+	}
+
+	t.Run("nested in struct", func(t *testing.T) {
+		type s struct {
+			A [1]int `cbor:"a"`
+			B int    `cbor:"b"`
+		}
+		data := hexDecode("a26161820102616203") // {"a": [1, 2], "b": 3}
+		var v s
+		err := dmExact.Unmarshal(data, &v)
+		wantErrorMsg := "cbor: cannot unmarshal CBOR array of 2 elements into Go array of type [1]int"
+		if err == nil {
+			t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+		} else if err.Error() != wantErrorMsg {
+			t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
+		}
+	})
+}
+
+func TestDecModeInvalidStringerEnums(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "integer type not implementing fmt.Stringer",
+			opts:         DecOptions{StringerEnums: NewStringerEnums(1)},
+			wantErrorMsg: "cbor: invalid StringerEnums: int is not an integer type implementing fmt.Stringer",
+		},
+		{
+			name:         "non-integer type implementing fmt.Stringer",
+			opts:         DecOptions{StringerEnums: NewStringerEnums(big.NewInt(1))},
+			wantErrorMsg: "cbor: invalid StringerEnums: *big.Int is not an integer type implementing fmt.Stringer",
+		},
+		{
+			name:         "nil",
+			opts:         DecOptions{StringerEnums: NewStringerEnums(nil)},
+			wantErrorMsg: "cbor: invalid StringerEnums: <nil> is not an integer type implementing fmt.Stringer",
+		},
+		{
+			name:         "duplicate name",
+			opts:         DecOptions{StringerEnums: NewStringerEnums(time.March, time.April, time.March)},
+			wantErrorMsg: "cbor: invalid StringerEnums: duplicate name \"March\" for time.Month",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDecodeStringerEnums(t *testing.T) {
+	var enums []interface{}
+	for m := time.January; m <= time.December; m++ {
+		enums = append(enums, m)
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		enums = append(enums, d)
+	}
+	dm, err := DecOptions{StringerEnums: NewStringerEnums(enums...)}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	march := time.March
+
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantValue    interface{}
+		wantErrorMsg string
+	}{
+		{
+			name:      "text string to time.Month",
+			data:      hexDecode("654d61726368"), // "March"
+			wantValue: time.March,
+		},
+		{
+			name:      "integer to time.Month",
+			data:      hexDecode("03"),
+			wantValue: time.March,
+		},
+		{
+			name:      "text string to *time.Month",
+			data:      hexDecode("654d61726368"), // "March"
+			wantValue: &march,
+		},
+		{
+			name:      "text string map key to time.Weekday",
+			data:      hexDecode("a1664d6f6e64617901"), // {"Monday": 1}
+			wantValue: map[time.Weekday]int{time.Monday: 1},
+		},
+		{
+			name:         "unknown name",
+			data:         hexDecode("66536d61726368"), // "Smarch"
+			wantValue:    time.Month(0),
+			wantErrorMsg: "cbor: cannot unmarshal UTF-8 text string into Go value of type time.Month (unknown enum name \"Smarch\")",
+		},
+		{
+			name:         "name of different type",
+			data:         hexDecode("664d6f6e646179"), // "Monday"
+			wantValue:    time.Month(0),
+			wantErrorMsg: "cbor: cannot unmarshal UTF-8 text string into Go value of type time.Month (unknown enum name \"Monday\")",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := reflect.New(reflect.TypeOf(tc.wantValue))
+			err := dm.Unmarshal(tc.data, v.Interface())
+			if tc.wantErrorMsg != "" {
+				if err == nil {
+					t.Fatalf("Unmarshal(0x%x) didn't return an error", tc.data)
+				}
+				if _, ok := err.(*UnmarshalTypeError); !ok {
+					t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*UnmarshalTypeError)", tc.data, err)
+				}
+				if err.Error() != tc.wantErrorMsg {
+					t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if !reflect.DeepEqual(v.Elem().Interface(), tc.wantValue) {
+				t.Errorf("Unmarshal(0x%x) = %v, want %v", tc.data, v.Elem().Interface(), tc.wantValue)
+			}
+		})
+	}
+
+	// Text string is rejected without StringerEnums.
+	var m time.Month
+	if err := Unmarshal(hexDecode("654d61726368"), &m); err == nil {
+		t.Errorf("Unmarshal(0x654d61726368) didn't return an error")
+	}
+}
+
+func TestStringerEnumsRoundTrip(t *testing.T) {
+	type event struct {
+		Month time.Month
+		Day   time.Weekday
+	}
+	em, err := EncOptions{StringerEnums: StringerEnumToString}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	dm, err := DecOptions{StringerEnums: NewStringerEnums(time.June, time.Friday)}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+	testRoundTrip(t, []roundTripTest{
+		{
+			name:         "struct with stringer enums",
+			obj:          event{Month: time.June, Day: time.Friday},
+			wantCborData: hexDecode("a2654d6f6e7468644a756e656344617966467269646179"), // {"Month": "June", "Day": "Friday"}
+		},
+	}, em, dm)
+}
+
+func TestUnknownFieldOption(t *testing.T) {
+	type s struct {
+		A       int                   `cbor:"a"`
+		Unknown map[string]RawMessage `cbor:",unknown"`
+	}
+	type sIntf struct {
+		A       int                        `cbor:"a"`
+		Unknown map[interface{}]RawMessage `cbor:",unknown"`
+	}
+	type Embedded struct {
+		Unknown map[string]RawMessage `cbor:",unknown"`
+	}
+	type sEmbedded struct {
+		A int `cbor:"a"`
+		*Embedded
+	}
+
+	emSorted, err := EncOptions{Sort: SortBytewiseLexical}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		opts      DecOptions
+		data      []byte
+		wantValue interface{}
+		wantData  []byte // re-encoded with SortBytewiseLexical (data if nil)
+	}{
+		{
+			name: "text string keys",
+			data: hexDecode("a36161016162810261636178"), // {"a": 1, "b": [2], "c": "x"}
+			wantValue: s{
+				A:       1,
+				Unknown: map[string]RawMessage{"b": hexDecode("8102"), "c": hexDecode("6178")},
+			},
+		},
+		{
+			name:      "no unknown keys",
+			data:      hexDecode("a1616101"), // {"a": 1}
+			wantValue: s{A: 1},
+		},
+		{
+			name: "integer keys ignored by map[string]RawMessage",
+			data: hexDecode("a4616101010220036162f5"), // {"a": 1, 1: 2, -1: 3, "b": true}
+			wantValue: s{
+				A:       1,
+				Unknown: map[string]RawMessage{"b": hexDecode("f5")},
+			},
+			wantData: hexDecode("a26161016162f5"), // {"a": 1, "b": true}
+		},
+		{
+			name: "integer keys",
+			data: hexDecode("a301026161012003"), // {1: 2, "a": 1, -1: 3}
+			wantValue: sIntf{
+				A:       1,
+				Unknown: map[interface{}]RawMessage{int64(1): hexDecode("02"), int64(-1): hexDecode("03")},
+			},
+			wantData: hexDecode("a301022003616101"), // {1: 2, -1: 3, "a": 1}
+		},
+		{
+			name: "byte string keys",
+			opts: DecOptions{FieldNameByteString: FieldNameByteStringAllowed},
+			data: hexDecode("a2616101416202"), // {"a": 1, h'62': 2}
+			wantValue: sIntf{
+				A:       1,
+				Unknown: map[interface{}]RawMessage{ByteString("b"): hexDecode("02")},
+			},
+			wantData: hexDecode("a2416202616101"), // {h'62': 2, "a": 1}
+		},
+		{
+			name: "no error for captured unknown fields",
+			opts: DecOptions{ExtraReturnErrors: ExtraDecErrorUnknownField},
+			data: hexDecode("a2616101616203"), // {"a": 1, "b": 3}
+			wantValue: s{
+				A:       1,
+				Unknown: map[string]RawMessage{"b": hexDecode("03")},
+			},
+		},
+		{
+			name: "embedded struct pointer",
+			data: hexDecode("a2616101616203"), // {"a": 1, "b": 3}
+			wantValue: sEmbedded{
+				A:        1,
+				Embedded: &Embedded{Unknown: map[string]RawMessage{"b": hexDecode("03")}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := tc.opts.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned error %v", err)
+			}
+
+			v := reflect.New(reflect.TypeOf(tc.wantValue))
+			if err = dm.Unmarshal(tc.data, v.Interface()); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if !reflect.DeepEqual(v.Elem().Interface(), tc.wantValue) {
+				t.Errorf("Unmarshal(0x%x) = %#v, want %#v", tc.data, v.Elem().Interface(), tc.wantValue)
+			}
+
+			wantData := tc.wantData
+			if wantData == nil {
+				wantData = tc.data
+			}
+			b, err := emSorted.Marshal(v.Elem().Interface())
+			if err != nil {
+				t.Fatalf("Marshal(%#v) returned error %v", v.Elem().Interface(), err)
+			}
+			if !bytes.Equal(b, wantData) {
+				t.Errorf("Marshal(%#v) = 0x%x, want 0x%x", v.Elem().Interface(), b, wantData)
+			}
+		})
+	}
+
+	t.Run("duplicate unknown keys", func(t *testing.T) {
+		dm, _ := DecOptions{DupMapKey: DupMapKeyEnforcedAPF}.DecMode()
+		data := hexDecode("a3616101616202616203") // {"a": 1, "b": 2, "b": 3}
+		var v s
+		err := dm.Unmarshal(data, &v)
+		wantErr := &DupMapKeyError{Key: "b", Index: 2}
+		if !reflect.DeepEqual(err, wantErr) {
+			t.Errorf("Unmarshal(0x%x) returned error %v, want %v", data, err, wantErr)
+		}
+	})
+
+	t.Run("reuse containers", func(t *testing.T) {
+		dm, _ := DecOptions{Reuse: ReuseContainers}.DecMode()
+		data := hexDecode("a2616101616203") // {"a": 1, "b": 3}
+		v := s{Unknown: map[string]RawMessage{"c": hexDecode("04")}}
+		if err := dm.Unmarshal(data, &v); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+		}
+		want := s{A: 1, Unknown: map[string]RawMessage{"b": hexDecode("03")}}
+		if !reflect.DeepEqual(v, want) {
+			t.Errorf("Unmarshal(0x%x) = %#v, want %#v", data, v, want)
+		}
+	})
+
+	t.Run("omitempty struct with unknown entries", func(t *testing.T) {
+		type outer struct {
+			S s `cbor:"s,omitempty"`
+		}
+		v := outer{S: s{Unknown: map[string]RawMessage{"b": hexDecode("03")}}}
+		b, err := Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal(%#v) returned error %v", v, err)
+		}
+		want := hexDecode("a16173a2616100616203") // {"s": {"a": 0, "b": 3}}
+		if !bytes.Equal(b, want) {
+			t.Errorf("Marshal(%#v) = 0x%x, want 0x%x", v, b, want)
+		}
+	})
+}
+
+func TestUnknownFieldOptionError(t *testing.T) {
+	type wrongType struct {
+		A       int               `cbor:"a"`
+		Unknown map[string][]byte `cbor:",unknown"`
+	}
+	type twoFields struct {
+		Unknown1 map[string]RawMessage `cbor:",unknown"`
+		Unknown2 map[string]RawMessage `cbor:",unknown"`
+	}
+
+	for _, tc := range []struct {
+		name         string
+		v            interface{}
+		wantErrorMsg string
+	}{
+		{
+			name:         "wrong type",
+			v:            &wrongType{},
+			wantErrorMsg: "cbor: field cbor.wrongType.Unknown with \"unknown\" option must be map[string]cbor.RawMessage or map[interface{}]cbor.RawMessage, got map[string][]uint8",
+		},
+		{
+			name:         "two fields",
+			v:            &twoFields{},
+			wantErrorMsg: "cbor: struct cbor.twoFields has more than one field with \"unknown\" option",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Unmarshal(hexDecode("a1616101"), tc.v); err == nil {
+				t.Errorf("Unmarshal() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+			if _, err := Marshal(tc.v); err == nil {
+				t.Errorf("Marshal() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Marshal() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+
+	t.Run("map key same as field name", func(t *testing.T) {
+		type s struct {
+			A       int                   `cbor:"a"`
+			Unknown map[string]RawMessage `cbor:",unknown"`
+		}
+		v := s{A: 1, Unknown: map[string]RawMessage{"a": hexDecode("02")}}
+		wantErrorMsg := "cbor: cannot encode field cbor.s.Unknown with \"unknown\" option: map key a is the same as another field name"
+		if _, err := Marshal(v); err == nil {
+			t.Errorf("Marshal() didn't return an error")
+		} else if err.Error() != wantErrorMsg {
+			t.Errorf("Marshal() returned error %q, want %q", err.Error(), wantErrorMsg)
+		}
+	})
+}
+
+func TestInlineFieldOption(t *testing.T) {
+	type common struct {
+		Version int    `cbor:"version"`
+		Name    string `cbor:"name"`
+	}
+	type config struct {
+		Common     common                 `cbor:",inline"`
+		Limits     *common                `cbor:"limits,omitempty"`
+		Port       int                    `cbor:"port"`
+		Extensions map[string]interface{} `cbor:",inline"`
+	}
+	type configPtr struct {
+		Common *common `cbor:",inline"`
+		Port   int     `cbor:"port"`
+	}
+	type typedExtensions struct {
+		Port       int            `cbor:"port"`
+		Extensions map[string]int `cbor:",inline"`
+	}
+
+	em, err := EncOptions{Sort: SortBytewiseLexical}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		data      []byte
+		wantValue interface{}
+	}{
+		{
+			name: "inline struct and map",
+			// {"name": "a", "port": 80, "x-foo": true, "version": 1}
+			data: hexDecode("a4646e616d65616164706f7274185065782d666f6ff56776657273696f6e01"),
+			wantValue: config{
+				Common:     common{Version: 1, Name: "a"},
+				Port:       80,
+				Extensions: map[string]interface{}{"x-foo": true},
+			},
+		},
+		{
+			name: "inline pointer to struct",
+			// {"name": "a", "port": 80, "version": 1}
+			data: hexDecode("a3646e616d65616164706f727418506776657273696f6e01"),
+			wantValue: configPtr{
+				Common: &common{Version: 1, Name: "a"},
+				Port:   80,
+			},
+		},
+		{
+			name: "inline map with typed values",
+			// {"a": 1, "b": 2, "port": 80}
+			data: hexDecode("a361610161620264706f72741850"),
+			wantValue: typedExtensions{
+				Port:       80,
+				Extensions: map[string]int{"a": 1, "b": 2},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v := reflect.New(reflect.TypeOf(tc.wantValue))
+			if err := Unmarshal(tc.data, v.Interface()); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if !reflect.DeepEqual(v.Elem().Interface(), tc.wantValue) {
+				t.Errorf("Unmarshal(0x%x) = %#v, want %#v", tc.data, v.Elem().Interface(), tc.wantValue)
+			}
+
+			b, err := em.Marshal(tc.wantValue)
+			if err != nil {
+				t.Fatalf("Marshal(%#v) returned error %v", tc.wantValue, err)
+			}
+			if !bytes.Equal(b, tc.data) {
+				t.Errorf("Marshal(%#v) = 0x%x, want 0x%x", tc.wantValue, b, tc.data)
+			}
+		})
+	}
+
+	t.Run("type mismatch in inline map value", func(t *testing.T) {
+		data := hexDecode("a161616178") // {"a": "x"}
+		var v typedExtensions
+		err := Unmarshal(data, &v)
+		if _, ok := err.(*UnmarshalTypeError); !ok {
+			t.Errorf("Unmarshal(0x%x) returned error %v (%T), want *UnmarshalTypeError", data, err, err)
+		}
+	})
+
+	t.Run("invalid field type", func(t *testing.T) {
+		type wrongType struct {
+			A int `cbor:",inline"`
+		}
+		type twoFields struct {
+			M1 map[string]int         `cbor:",inline"`
+			M2 map[string]interface{} `cbor:",inline"`
+		}
+		type mapKey struct {
+			M map[int]int `cbor:",inline"`
+		}
+
+		for _, tc := range []struct {
+			v            interface{}
+			wantErrorMsg string
+		}{
+			{&wrongType{}, "cbor: field cbor.wrongType.A with \"inline\" option must be a struct or a map with string or interface{} keys, got int"},
+			{&twoFields{}, "cbor: struct cbor.twoFields has more than one field with \"inline\" option"},
+			{&mapKey{}, "cbor: field cbor.mapKey.M with \"inline\" option must be a struct or a map with string or interface{} keys, got map[int]int"},
+		} {
+			if err := Unmarshal(hexDecode("a1616101"), tc.v); err == nil {
+				t.Errorf("Unmarshal() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+			if _, err := Marshal(tc.v); err == nil {
+				t.Errorf("Marshal() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Marshal() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		}
+	})
+
+	t.Run("map key same as field name", func(t *testing.T) {
+		v := typedExtensions{Port: 1, Extensions: map[string]int{"port": 2}}
+		wantErrorMsg := "cbor: cannot encode field cbor.typedExtensions.Extensions with \"inline\" option: map key port is the same as another field name"
+		if _, err := Marshal(v); err == nil {
+			t.Errorf("Marshal() didn't return an error")
+		} else if err.Error() != wantErrorMsg {
+			t.Errorf("Marshal() returned error %q, want %q", err.Error(), wantErrorMsg)
+		}
+	})
+}
+
+func TestOrigFieldOption(t *testing.T) {
+	type s struct {
+		A    int        `cbor:"a"`
+		Orig RawMessage `cbor:",orig"`
+	}
+	type sArray struct {
+		_    struct{} `cbor:",toarray"`
+		A    int
+		B    string
+		Orig RawMessage `cbor:",orig"`
+	}
+	type Embedded struct {
+		Orig RawMessage `cbor:",orig"`
+	}
+	type sEmbedded struct {
+		A int `cbor:"a"`
+		*Embedded
+	}
+	type outer struct {
+		S    s          `cbor:"s"`
+		Orig RawMessage `cbor:",orig"`
+	}
+
+	testCases := []struct {
+		name      string
+		opts      DecOptions
+		data      []byte
+		wantValue interface{}
+	}{
+		{
+			name:      "map",
+			data:      hexDecode("a2616101616203"), // {"a": 1, "b": 3}
+			wantValue: s{A: 1, Orig: hexDecode("a2616101616203")},
+		},
+		{
+			name:      "indefinite-length map",
+			data:      hexDecode("bf616101ff"), // {_ "a": 1}
+			wantValue: s{A: 1, Orig: hexDecode("bf616101ff")},
+		},
+		{
+			name:      "map key same as field name",
+			data:      hexDecode("a2616101644f7269670a"), // {"a": 1, "Orig": 10}
+			wantValue: s{A: 1, Orig: hexDecode("a2616101644f7269670a")},
+		},
+		{
+			name:      "zero copy",
+			opts:      DecOptions{ZeroCopy: ZeroCopyBytes},
+			data:      hexDecode("a1616101"), // {"a": 1}
+			wantValue: s{A: 1, Orig: hexDecode("a1616101")},
+		},
+		{
+			name:      "toarray",
+			data:      hexDecode("82016178"), // [1, "x"]
+			wantValue: sArray{A: 1, B: "x", Orig: hexDecode("82016178")},
+		},
+		{
+			name:      "embedded struct pointer",
+			data:      hexDecode("a1616101"), // {"a": 1}
+			wantValue: sEmbedded{A: 1, Embedded: &Embedded{Orig: hexDecode("a1616101")}},
+		},
+		{
+			name: "nested struct",
+			data: hexDecode("a16173a1616101"), // {"s": {"a": 1}}
+			wantValue: outer{
+				S:    s{A: 1, Orig: hexDecode("a1616101")},
+				Orig: hexDecode("a16173a1616101"),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := tc.opts.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned error %v", err)
+			}
+
+			v := reflect.New(reflect.TypeOf(tc.wantValue))
+			if err = dm.Unmarshal(tc.data, v.Interface()); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if !reflect.DeepEqual(v.Elem().Interface(), tc.wantValue) {
+				t.Errorf("Unmarshal(0x%x) = %#v, want %#v", tc.data, v.Elem().Interface(), tc.wantValue)
+			}
+		})
+	}
+
+	t.Run("copy", func(t *testing.T) {
+		data := hexDecode("a1616101") // {"a": 1}
+		var v s
+		if err := Unmarshal(data, &v); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+		}
+		data[0] = 0xff
+		if want := hexDecode("a1616101"); !bytes.Equal(v.Orig, want) {
+			t.Errorf("Unmarshal() set Orig to 0x%x, want 0x%x", v.Orig, want)
+		}
+	})
+
+	t.Run("ignored when encoding", func(t *testing.T) {
+		v := s{A: 1, Orig: hexDecode("a2616101616203")}
+		b, err := Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal(%#v) returned error %v", v, err)
+		}
+		if want := hexDecode("a1616101"); !bytes.Equal(b, want) {
+			t.Errorf("Marshal(%#v) = 0x%x, want 0x%x", v, b, want)
+		}
+	})
+}
+
+func TestOrigFieldOptionError(t *testing.T) {
+	type wrongType struct {
+		A    int    `cbor:"a"`
+		Orig []byte `cbor:",orig"`
+	}
+	type twoFields struct {
+		Orig1 RawMessage `cbor:",orig"`
+		Orig2 RawMessage `cbor:",orig"`
+	}
+
+	for _, tc := range []struct {
+		name         string
+		v            interface{}
+		wantErrorMsg string
+	}{
+		{
+			name:         "wrong type",
+			v:            &wrongType{},
+			wantErrorMsg: "cbor: field cbor.wrongType.Orig with \"orig\" option must be cbor.RawMessage, got []uint8",
+		},
+		{
+			name:         "two fields",
+			v:            &twoFields{},
+			wantErrorMsg: "cbor: struct cbor.twoFields has more than one field with \"orig\" option",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Unmarshal(hexDecode("a1616101"), tc.v); err == nil {
+				t.Errorf("Unmarshal() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+			if _, err := Marshal(tc.v); err == nil {
+				t.Errorf("Marshal() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Marshal() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDecodeErrorLocation(t *testing.T) {
+	type s struct {
+		A int `cbor:"a"`
+	}
+	testCases := []struct {
+		name       string
+		data       []byte
+		v          interface{}
+		wantOffset int
+		wantPath   string
+	}{
+		{
+			name:       "type error in root data item",
+			data:       hexDecode("6178"), // "x"
+			v:          new(int),
+			wantOffset: 0,
+			wantPath:   "",
+		},
+		{
+			name:       "type error in struct field",
+			data:       hexDecode("a161616178"), // {"a": "x"}
+			v:          new(s),
+			wantOffset: 3,
+			wantPath:   `/"a"`,
+		},
+		{
+			name:       "type error in nested array element",
+			data:       hexDecode("83a0a0a16768656164657273840102036178"), // [{}, {}, {"headers": [1, 2, 3, "x"]}]
+			v:          new([]map[string][]int),
+			wantOffset: 16,
+			wantPath:   `/2/"headers"/3`,
+		},
+		{
+			name:       "syntax error",
+			data:       hexDecode("82018202ff"), // [1, [2, break]]
+			v:          new(interface{}),
+			wantOffset: 4,
+			wantPath:   "/1/1",
+		},
+		{
+			name:       "semantic error",
+			data:       hexDecode("a10182616161ff"), // {1: ["a", invalid UTF-8 text string]}
+			v:          new(interface{}),
+			wantOffset: 5,
+			wantPath:   "/1/1",
+		},
+		{
+			name:       "tagged data item",
+			data:       hexDecode("82d8298101d829816178"), // [41([1]), 41(["x"])]
+			v:          new([][]int),
+			wantOffset: 8,
+			wantPath:   "/1/0",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Unmarshal(tc.data, tc.v)
+			if err == nil {
+				t.Fatalf("Unmarshal(0x%x) didn't return an error", tc.data)
+			}
+			var le locatedError
+			if !errors.As(err, &le) {
+				t.Fatalf("Unmarshal(0x%x) returned error %T, want error with location", tc.data, err)
+			}
+			loc := le.errorLocation()
+			if loc.Offset != tc.wantOffset || loc.Path != tc.wantPath {
+				t.Errorf("Unmarshal(0x%x) returned error location (%d, %q), want (%d, %q)", tc.data, loc.Offset, loc.Path, tc.wantOffset, tc.wantPath)
+			}
+		})
+	}
+}
+
+func TestDecodeErrorLocationErrorsAs(t *testing.T) {
+	data := hexDecode("a161616178") // {"a": "x"}
+	var v struct {
+		A int `cbor:"a"`
+	}
+	err := Unmarshal(data, &v)
+
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("Unmarshal(0x%x) returned error %T, want *UnmarshalTypeError", data, err)
+	}
+	if typeErr.Offset != 3 || typeErr.Path != `/"a"` {
+		t.Errorf("Unmarshal(0x%x) returned error location (%d, %q), want (3, %q)", data, typeErr.Offset, typeErr.Path, `/"a"`)
+	}
+}
+
+func TestDecodeFieldNameIgnoredTags(t *testing.T) {
+	type s struct {
+		A int `cbor:"a"`
+		B int `cbor:"b"`
+		C int `cbor:"1,keyasint"`
+	}
+
+	testCases := []struct {
+		name         string
+		opts         DecOptions
+		data         []byte
+		wantValue    s
+		wantErrorMsg string
+	}{
+		{
+			name:      "untagged keys",
+			opts:      DecOptions{FieldNameIgnoredTags: NewTagNumbers(21)},
+			data:      hexDecode("a36161016162020103"), // {"a": 1, "b": 2, 1: 3}
+			wantValue: s{A: 1, B: 2, C: 3},
+		},
+		{
+			name:      "tagged keys",
+			opts:      DecOptions{FieldNameIgnoredTags: NewTagNumbers(21)},
+			data:      hexDecode("a3d5616101d5616202d50103"), // {21("a"): 1, 21("b"): 2, 21(1): 3}
+			wantValue: s{A: 1, B: 2, C: 3},
+		},
+		{
+			name:      "nested tags",
+			opts:      DecOptions{FieldNameIgnoredTags: NewTagNumbers(21, 22)},
+			data:      hexDecode("a2d5616101d6d5616202"), // {21("a"): 1, 22(21("b")): 2}
+			wantValue: s{A: 1, B: 2},
+		},
+		{
+			name:         "nested tag isn't listed",
+			opts:         DecOptions{FieldNameIgnoredTags: NewTagNumbers(21)},
+			data:         hexDecode("a2d5616101d6d5616202"), // {21("a"): 1, 22(21("b")): 2}
+			wantValue:    s{A: 1},
+			wantErrorMsg: "cbor: cannot unmarshal tag into Go value of type string (map key is of type tag and cannot be used to match struct field name)",
+		},
+		{
+			name:         "no ignored tags",
+			data:         hexDecode("a1d5616101"), // {21("a"): 1}
+			wantErrorMsg: "cbor: cannot unmarshal tag into Go value of type string (map key is of type tag and cannot be used to match struct field name)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decMode, err := tc.opts.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned an error %v", err)
+			}
+
+			var dst s
+			err = decMode.Unmarshal(tc.data, &dst)
+			if tc.wantErrorMsg == "" && err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned unexpected error %v", tc.data, err)
+			} else if tc.wantErrorMsg != "" && (err == nil || err.Error() != tc.wantErrorMsg) {
+				t.Errorf("Unmarshal(0x%x) returned error %v, want %q", tc.data, err, tc.wantErrorMsg)
+			}
+
+			if !reflect.DeepEqual(dst, tc.wantValue) {
+				t.Errorf("Unmarshal(0x%x) = %#v, want %#v", tc.data, dst, tc.wantValue)
+			}
+		})
+	}
+}
+
+func TestDecModeInvalidSmallValueToAny(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{SmallValueToAny: -1},
+			wantErrorMsg: "cbor: invalid SmallValueToAny -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{SmallValueToAny: 101},
+			wantErrorMsg: "cbor: invalid SmallValueToAny 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestUnmarshalSmallValueToAny(t *testing.T) {
+	// [-257, -256, -1, 0, 1023, 1024, "a", "a", 33-byte string]
+	data := hexDecode("89390100" + "38ff" + "20" + "00" + "1903ff" + "190400" + "6161" + "6161" + "7821" + strings.Repeat("61", 33))
+	longString := strings.Repeat("a", 33)
+
+	for _, tc := range []struct {
+		name string
+		opts DecOptions
+		want []interface{}
+	}{
+		{
+			name: "SmallValueToAnyAllocate",
+			opts: DecOptions{SmallValueToAny: SmallValueToAnyAllocate},
+			want: []interface{}{int64(-257), int64(-256), int64(-1), uint64(0), uint64(1023), uint64(1024), "a", "a", longString},
+		},
+		{
+			name: "SmallValueToAnyCacheIntegers",
+			opts: DecOptions{SmallValueToAny: SmallValueToAnyCacheIntegers},
+			want: []interface{}{int64(-257), int64(-256), int64(-1), uint64(0), uint64(1023), uint64(1024), "a", "a", longString},
+		},
+		{
+			name: "SmallValueToAnyCacheIntegersAndStrings",
+			opts: DecOptions{SmallValueToAny: SmallValueToAnyCacheIntegersAndStrings},
+			want: []interface{}{int64(-257), int64(-256), int64(-1), uint64(0), uint64(1023), uint64(1024), "a", "a", longString},
+		},
+		{
+			name: "SmallValueToAnyCacheIntegers with IntDecConvertSigned",
+			opts: DecOptions{SmallValueToAny: SmallValueToAnyCacheIntegers, IntDec: IntDecConvertSigned},
+			want: []interface{}{int64(-257), int64(-256), int64(-1), int64(0), int64(1023), int64(1024), "a", "a", longString},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := tc.opts.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned error %v", err)
+			}
+			var v interface{}
+			if err := dm.Unmarshal(data, &v); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+			}
+			if !reflect.DeepEqual(v, tc.want) {
+				t.Errorf("Unmarshal(0x%x) = %#v, want %#v", data, v, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalSmallValueToAnyAllocs(t *testing.T) {
+	items := make([]interface{}, 100)
+	for i := range items {
+		items[i] = map[string]interface{}{"id": i - 50, "type": "item"}
+	}
+	data, err := Marshal(items)
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+
+	allocs := func(mode SmallValueToAnyMode) float64 {
+		dm, err := DecOptions{SmallValueToAny: mode}.DecMode()
+		if err != nil {
+			t.Fatalf("DecMode() returned error %v", err)
+		}
+		return testing.AllocsPerRun(10, func() {
+			var v interface{}
+			if err := dm.Unmarshal(data, &v); err != nil {
+				t.Fatalf("Unmarshal() returned error %v", err)
+			}
+		})
+	}
+
+	noCache := allocs(SmallValueToAnyAllocate)
+	intCache := allocs(SmallValueToAnyCacheIntegers)
+	intAndStringCache := allocs(SmallValueToAnyCacheIntegersAndStrings)
+
+	// 50 negative integers are allocated without cache.
+	if intCache > noCache-50 {
+		t.Errorf("Unmarshal() with SmallValueToAnyCacheIntegers allocated %v times, want at most %v", intCache, noCache-50)
+	}
+	// 2 map keys and 1 map value are repeated 100 times.
+	if intAndStringCache > intCache-250 {
+		t.Errorf("Unmarshal() with SmallValueToAnyCacheIntegersAndStrings allocated %v times, want at most %v", intAndStringCache, intCache-250)
+	}
+}
+
+func TestDecModeWithOptions(t *testing.T) {
+	type myInt int
+
+	tags := NewTagSet()
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagRequired}, reflect.TypeOf(myInt(0)), 100); err != nil {
+		t.Fatalf("TagSet.Add() returned error %v", err)
+	}
+	dm, err := DecOptions{}.DecModeWithTags(tags)
+	if err != nil {
+		t.Fatalf("DecModeWithTags() returned error %v", err)
+	}
+
+	derived, err := dm.(ExtendedDecMode).WithOptions(func(opts *DecOptions) {
+		opts.DupMapKey = DupMapKeyEnforcedAPF
+	})
+	if err != nil {
+		t.Fatalf("WithOptions() returned error %v", err)
+	}
+	if dupMapKey := derived.DecOptions().DupMapKey; dupMapKey != DupMapKeyEnforcedAPF {
+		t.Errorf("WithOptions() returned DecMode with DupMapKey %d, want %d", dupMapKey, DupMapKeyEnforcedAPF)
+	}
+	if dupMapKey := dm.DecOptions().DupMapKey; dupMapKey != DupMapKeyQuiet {
+		t.Errorf("WithOptions() modified original DecMode DupMapKey to %d, want %d", dupMapKey, DupMapKeyQuiet)
+	}
+
+	// Derived mode keeps tags of the original mode.
+	data := hexDecode("a101d86402") // {1: 100(2)}
+	var v map[int]myInt
+	if err := derived.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	if want := map[int]myInt{1: 2}; !reflect.DeepEqual(v, want) {
+		t.Errorf("Unmarshal(0x%x) = %v, want %v", data, v, want)
+	}
+	data = hexDecode("a10102") // {1: 2}
+	if err := derived.Unmarshal(data, &v); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error for missing tag", data)
+	}
+
+	for _, tc := range []struct {
+		name         string
+		fn           func(opts *DecOptions)
+		wantErrorMsg string
+	}{
+		{
+			name:         "invalid option",
+			fn:           func(opts *DecOptions) { opts.DupMapKey = -1 },
+			wantErrorMsg: "cbor: invalid DupMapKey -1",
+		},
+		{
+			name:         "tags forbidden",
+			fn:           func(opts *DecOptions) { opts.TagsMd = TagsForbidden },
+			wantErrorMsg: "cbor: cannot create DecMode with TagSet when TagsMd is TagsForbidden",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := dm.(ExtendedDecMode).WithOptions(tc.fn)
+			if err == nil {
+				t.Errorf("WithOptions() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("WithOptions() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDecModeInvalidTrailingBytes(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{TrailingBytes: -1},
+			wantErrorMsg: "cbor: invalid TrailingBytes -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{TrailingBytes: 101},
+			wantErrorMsg: "cbor: invalid TrailingBytes 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestUnmarshalTrailingBytes(t *testing.T) {
+	data := hexDecode("820102ff01") // [1, 2] followed by malformed data
+
+	dmForbidden, err := DecOptions{TrailingBytes: TrailingBytesForbidden}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+	dmAllowed, err := DecOptions{TrailingBytes: TrailingBytesAllowed}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	for _, dm := range []DecMode{defaultDecMode, dmForbidden} {
+		v := []int{}
+		err := dm.Unmarshal(data, &v)
+		if !errors.Is(err, ErrExtraneousData) {
+			t.Errorf("Unmarshal() returned error %v, want %v", err, ErrExtraneousData)
+		}
+		if len(v) != 0 {
+			t.Errorf("Unmarshal() = %v, want no decoded value", v)
+		}
+		if err := dm.Wellformed(data); !errors.Is(err, ErrExtraneousData) {
+			t.Errorf("Wellformed() returned error %v, want %v", err, ErrExtraneousData)
+		}
+	}
+
+	var v []int
+	if err := dmAllowed.Unmarshal(data, &v); err != nil {
+		t.Errorf("Unmarshal() returned error %v", err)
+	}
+	if !reflect.DeepEqual(v, []int{1, 2}) {
+		t.Errorf("Unmarshal() = %v, want [1 2]", v)
+	}
+	if err := dmAllowed.Wellformed(data); err != nil {
+		t.Errorf("Wellformed() returned error %v", err)
+	}
+
+	// Remaining bytes are returned by UnmarshalFirst with either mode.
+	for _, dm := range []DecMode{dmForbidden, dmAllowed} {
+		rest, err := dm.UnmarshalFirst(data, &v)
+		if err != nil {
+			t.Errorf("UnmarshalFirst() returned error %v", err)
+		}
+		if !bytes.Equal(rest, data[3:]) {
+			t.Errorf("UnmarshalFirst() returned rest 0x%x, want 0x%x", rest, data[3:])
+		}
+	}
+
+	// Tag 24 content must be exactly one data item even with TrailingBytesAllowed.
+	var i interface{}
+	dmUnwrap, err := DecOptions{TrailingBytes: TrailingBytesAllowed, EncodedItem: EncodedItemDecodeUnwrap}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+	if err := dmUnwrap.Unmarshal(hexDecode("d818420101"), &i); !errors.Is(err, ErrExtraneousData) {
+		t.Errorf("Unmarshal() returned error %v, want %v", err, ErrExtraneousData)
+	}
+}
+
+func TestDecModeInvalidFloat(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{Float: -1},
+			wantErrorMsg: "cbor: invalid Float -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{Float: 101},
+			wantErrorMsg: "cbor: invalid Float 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestUnmarshalFloatForbidden(t *testing.T) {
+	dm, err := DecOptions{Float: FloatForbidden}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"float16", hexDecode("f93e00")},
+		{"float32", hexDecode("fa47c35000")},
+		{"float64", hexDecode("fb3ff199999999999a")},
+		{"float16 NaN", hexDecode("f97e00")},
+		{"float64 Inf", hexDecode("fb7ff0000000000000")},
+		{"float in array", hexDecode("8201f93e00")},
+		{"float in tag", hexDecode("c1fb41d452d9ec200000")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			wantErrorMsg := "cbor: data item of cbor type primitives is not accepted by protocol: floating-point number"
+
+			var v interface{}
+			err := dm.Unmarshal(tc.data, &v)
+			var uerr *UnacceptableDataItemError
+			if !errors.As(err, &uerr) {
+				t.Errorf("Unmarshal(0x%x) returned error %v (%T), want *UnacceptableDataItemError", tc.data, err, err)
+			} else if err.Error() != wantErrorMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), wantErrorMsg)
+			}
+
+			if err := dm.Wellformed(tc.data); err == nil {
+				t.Errorf("Wellformed(0x%x) didn't return an error", tc.data)
+			}
+		})
+	}
+
+	// Integers and simple values aren't affected.
+	var v []interface{}
+	if err := dm.Unmarshal(hexDecode("8401f4f6f0"), &v); err != nil {
+		t.Errorf("Unmarshal() returned error %v", err)
+	}
+}
+
+func TestDecModeInvalidChunkedString(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{ChunkedString: -1},
+			wantErrorMsg: "cbor: invalid ChunkedString -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{ChunkedString: 101},
+			wantErrorMsg: "cbor: invalid ChunkedString 101",
+		},
+		{
+			name:         "negative MaxStringChunkSize",
+			opts:         DecOptions{ChunkedString: ChunkedStringRequired, MaxStringChunkSize: -1},
+			wantErrorMsg: "cbor: invalid MaxStringChunkSize -1 (range is [0, 2147483647])",
+		},
+		{
+			name:         "ChunkedStringRequired without MaxStringChunkSize",
+			opts:         DecOptions{ChunkedString: ChunkedStringRequired},
+			wantErrorMsg: "cbor: MaxStringChunkSize must be set when ChunkedString is ChunkedStringRequired",
+		},
+		{
+			name:         "MaxStringChunkSize without ChunkedStringRequired",
+			opts:         DecOptions{MaxStringChunkSize: 16},
+			wantErrorMsg: "cbor: MaxStringChunkSize can only be set when ChunkedString is ChunkedStringRequired",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestUnmarshalChunkedString(t *testing.T) {
+	forbiddenDM, err := DecOptions{ChunkedString: ChunkedStringForbidden}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+	requiredDM, err := DecOptions{ChunkedString: ChunkedStringRequired, MaxStringChunkSize: 2}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	for _, tc := range []struct {
+		name                  string
+		data                  []byte
+		wantValue             interface{}
+		wantForbiddenErrorMsg string
+		wantRequiredErrorMsg  string
+	}{
+		{
+			name:      "short byte string",
+			data:      hexDecode("420102"),
+			wantValue: []byte{1, 2},
+		},
+		{
+			name:      "short text string",
+			data:      hexDecode("626162"),
+			wantValue: "ab",
+		},
+		{
+			name:                 "long byte string",
+			data:                 hexDecode("43010203"),
+			wantValue:            []byte{1, 2, 3},
+			wantRequiredErrorMsg: "cbor: data item of cbor type byte string is not accepted by protocol: definite-length string longer than 2 bytes",
+		},
+		{
+			name:                 "long text string",
+			data:                 hexDecode("63616263"),
+			wantValue:            "abc",
+			wantRequiredErrorMsg: "cbor: data item of cbor type UTF-8 text string is not accepted by protocol: definite-length string longer than 2 bytes",
+		},
+		{
+			name:                  "chunked byte string",
+			data:                  hexDecode("5f4201024103ff"),
+			wantValue:             []byte{1, 2, 3},
+			wantForbiddenErrorMsg: "cbor: data item of cbor type byte string is not accepted by protocol: indefinite-length string",
+		},
+		{
+			name:                  "chunked text string",
+			data:                  hexDecode("7f6261626163ff"),
+			wantValue:             "abc",
+			wantForbiddenErrorMsg: "cbor: data item of cbor type UTF-8 text string is not accepted by protocol: indefinite-length string",
+		},
+		{
+			name:                  "chunked text string with long chunk",
+			data:                  hexDecode("7f63616263ff"),
+			wantValue:             "abc",
+			wantForbiddenErrorMsg: "cbor: data item of cbor type UTF-8 text string is not accepted by protocol: indefinite-length string",
+			wantRequiredErrorMsg:  "cbor: data item of cbor type UTF-8 text string is not accepted by protocol: definite-length string longer than 2 bytes",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, dmc := range []struct {
+				name         string
+				dm           DecMode
+				wantErrorMsg string
+			}{
+				{"ChunkedStringForbidden", forbiddenDM, tc.wantForbiddenErrorMsg},
+				{"ChunkedStringRequired", requiredDM, tc.wantRequiredErrorMsg},
+			} {
+				var v interface{}
+				err := dmc.dm.Unmarshal(tc.data, &v)
+				if dmc.wantErrorMsg == "" {
+					if err != nil {
+						t.Errorf("%s: Unmarshal(0x%x) returned error %v", dmc.name, tc.data, err)
+					} else if !reflect.DeepEqual(v, tc.wantValue) {
+						t.Errorf("%s: Unmarshal(0x%x) = %v (%T), want %v (%T)", dmc.name, tc.data, v, v, tc.wantValue, tc.wantValue)
+					}
+					continue
+				}
+				var uerr *UnacceptableDataItemError
+				if !errors.As(err, &uerr) {
+					t.Errorf("%s: Unmarshal(0x%x) returned error %v (%T), want *UnacceptableDataItemError", dmc.name, tc.data, err, err)
+				} else if err.Error() != dmc.wantErrorMsg {
+					t.Errorf("%s: Unmarshal(0x%x) returned error %q, want %q", dmc.name, tc.data, err.Error(), dmc.wantErrorMsg)
+				}
+			}
+		})
+	}
+
+	// Strings encoded with StringChunkSize are accepted by ChunkedStringRequired
+	// with the same MaxStringChunkSize.
+	em, err := EncOptions{StringChunkSize: 2}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	want := []interface{}{"hello", []byte{1, 2, 3, 4, 5}}
+	b, err := em.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal(%v) returned error %v", want, err)
+	}
+	var got []interface{}
+	if err := requiredDM.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", b, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal(0x%x) = %v, want %v", b, got, want)
+	}
+}
+
+func TestDecModeInvalidNilValue(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{NilValue: -1},
+			wantErrorMsg: "cbor: invalid NilValue -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{NilValue: 101},
+			wantErrorMsg: "cbor: invalid NilValue 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestUnmarshalNilValue(t *testing.T) {
+	type s struct {
+		A int    `cbor:"a"`
+		B string `cbor:"b"`
+	}
+
+	zeroDM, err := DecOptions{NilValue: NilValueZero}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+	forbiddenDM, err := DecOptions{NilValue: NilValueForbidden}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	tm := time.Unix(1000, 0)
+	for _, data := range [][]byte{hexDecode("f6"), hexDecode("f7")} {
+		testCases := []struct {
+			name     string
+			v        interface{} // pointer to initial value
+			wantZero interface{}
+		}{
+			{"int", func() interface{} { i := 1; return &i }(), 0},
+			{"string", func() interface{} { s := "a"; return &s }(), ""},
+			{"bool", func() interface{} { b := true; return &b }(), false},
+			{"array", &[2]int{1, 2}, [2]int{}},
+			{"struct", &s{A: 1, B: "a"}, s{}},
+			{"time.Time", &tm, time.Time{}},
+			{"cbor.Tag", &Tag{Number: 100, Content: "a"}, Tag{}},
+		}
+		for _, tc := range testCases {
+			t.Run(fmt.Sprintf("%s 0x%x", tc.name, data), func(t *testing.T) {
+				// Default NilValueNoOp leaves value unchanged.
+				v := reflect.New(reflect.TypeOf(tc.v).Elem())
+				v.Elem().Set(reflect.ValueOf(tc.v).Elem())
+				if err := Unmarshal(data, v.Interface()); err != nil {
+					t.Errorf("Unmarshal(0x%x) returned error %v", data, err)
+				} else if !reflect.DeepEqual(v.Elem().Interface(), reflect.ValueOf(tc.v).Elem().Interface()) {
+					t.Errorf("Unmarshal(0x%x) = %v, want %v", data, v.Elem().Interface(), reflect.ValueOf(tc.v).Elem().Interface())
+				}
+
+				v = reflect.New(reflect.TypeOf(tc.v).Elem())
+				v.Elem().Set(reflect.ValueOf(tc.v).Elem())
+				if err := zeroDM.Unmarshal(data, v.Interface()); err != nil {
+					t.Errorf("Unmarshal(0x%x) with NilValueZero returned error %v", data, err)
+				} else if !reflect.DeepEqual(v.Elem().Interface(), tc.wantZero) {
+					t.Errorf("Unmarshal(0x%x) with NilValueZero = %v, want %v", data, v.Elem().Interface(), tc.wantZero)
+				}
+
+				v = reflect.New(reflect.TypeOf(tc.v).Elem())
+				wantErrorMsg := "cbor: cannot unmarshal primitives into Go value of type " + v.Elem().Type().String() +
+					" (null or undefined can't be decoded to non-nilable type)"
+				err := forbiddenDM.Unmarshal(data, v.Interface())
+				var typeErr *UnmarshalTypeError
+				if !errors.As(err, &typeErr) {
+					t.Errorf("Unmarshal(0x%x) with NilValueForbidden returned error %v (%T), want *UnmarshalTypeError", data, err, err)
+				} else if err.Error() != wantErrorMsg {
+					t.Errorf("Unmarshal(0x%x) with NilValueForbidden returned error %q, want %q", data, err.Error(), wantErrorMsg)
+				}
+			})
+		}
+	}
+
+	// Missing struct field value is detected with NilValueForbidden.
+	data := hexDecode("a26161016162f6") // {"a": 1, "b": null}
+	var v s
+	wantErrorMsg := "cbor: cannot unmarshal primitives into Go struct field cbor.s.b of type string (null or undefined can't be decoded to non-nilable type)"
+	if err := forbiddenDM.Unmarshal(data, &v); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
+	}
+
+	// Nilable values are set to nil regardless of NilValue.
+	for _, dm := range []DecMode{zeroDM, forbiddenDM} {
+		type nilable struct {
+			P *int           `cbor:"p"`
+			S []int          `cbor:"s"`
+			M map[string]int `cbor:"m"`
+			I interface{}    `cbor:"i"`
+		}
+		one := 1
+		v := nilable{P: &one, S: []int{1}, M: map[string]int{"a": 1}, I: 1}
+		data := hexDecode("a46170f66173f6616df66169f6") // {"p": null, "s": null, "m": null, "i": null}
+		if err := dm.Unmarshal(data, &v); err != nil {
+			t.Errorf("Unmarshal(0x%x) returned error %v", data, err)
+		} else if v.P != nil || v.S != nil || v.M != nil || v.I != nil {
+			t.Errorf("Unmarshal(0x%x) = %+v, want nil fields", data, v)
+		}
+	}
+}
+
+func TestDecModeInvalidMaxDecodedSizeBytes(t *testing.T) {
+	testCases := []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "MaxDecodedSizeBytes < 0",
+			opts:         DecOptions{MaxDecodedSizeBytes: -1},
+			wantErrorMsg: "cbor: invalid MaxDecodedSizeBytes -1 (range is [0, 2147483647])",
+		},
+		{
+			name:         "MaxDecodedSizeBytes > 2147483647",
+			opts:         DecOptions{MaxDecodedSizeBytes: 2147483648},
+			wantErrorMsg: "cbor: invalid MaxDecodedSizeBytes 2147483648 (range is [0, 2147483647])",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestExceedMaxDecodedSize(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     []byte
+		wantSize int // estimated decoded size
+	}{
+		{
+			name:     "byte string",
+			data:     hexDecode("450102030405"),
+			wantSize: 5,
+		},
+		{
+			name:     "indefinite length text string",
+			data:     hexDecode("7f626865636c6c6fff"),
+			wantSize: 5,
+		},
+		{
+			name:     "array of byte strings",
+			data:     hexDecode("82450102030405450102030405"),
+			wantSize: 42,
+		},
+		{
+			name:     "indefinite length array",
+			data:     hexDecode("9f0102ff"),
+			wantSize: 32,
+		},
+		{
+			name:     "map",
+			data:     hexDecode("a1616101"),
+			wantSize: 33,
+		},
+		{
+			name:     "indefinite length map",
+			data:     hexDecode("bf616101ff"),
+			wantSize: 33,
+		},
+		{
+			name:     "tagged array",
+			data:     hexDecode("d8258201426869"),
+			wantSize: 34,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := DecOptions{MaxDecodedSizeBytes: tc.wantSize}.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned error %v", err)
+			}
+			var v interface{}
+			if err := dm.Unmarshal(tc.data, &v); err != nil {
+				t.Errorf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+
+			dm, err = DecOptions{MaxDecodedSizeBytes: tc.wantSize - 1}.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned error %v", err)
+			}
+			wantErrorMsg := fmt.Sprintf("cbor: exceeded max decoded size %d bytes", tc.wantSize-1)
+			err = dm.Unmarshal(tc.data, &v)
+			if err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error", tc.data)
+			} else if _, ok := err.(*MaxDecodedSizeError); !ok {
+				t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*MaxDecodedSizeError)", tc.data, err)
+			} else if err.Error() != wantErrorMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), wantErrorMsg)
+			} else if !errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("Unmarshal(0x%x) returned error %v that doesn't match ErrLimitExceeded", tc.data, err)
+			}
+		})
+	}
+}
+
+func TestExceedMaxDecodedSizeDecoder(t *testing.T) {
+	dm, err := DecOptions{MaxDecodedSizeBytes: 5}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	// Max decoded size applies to each data item in the stream.
+	dec := dm.NewDecoder(bytes.NewReader(hexDecode("45010203040545010203040546010203040506")))
+	var v []byte
+	for i := 0; i < 2; i++ {
+		if err := dec.Decode(&v); err != nil {
+			t.Fatalf("Decode() returned error %v", err)
+		}
+	}
+	if err := dec.Decode(&v); err == nil {
+		t.Errorf("Decode() didn't return an error")
+	} else if _, ok := err.(*MaxDecodedSizeError); !ok {
+		t.Errorf("Decode() returned wrong error type %T, want (*MaxDecodedSizeError)", err)
+	}
+}
+
+func TestUnmarshalDupMapKeyReject(t *testing.T) {
+	dm, err := DecOptions{DupMapKey: DupMapKeyReject}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	type s struct {
+		A int `cbor:"a"`
+		B int `cbor:"b"`
+	}
+
+	testCases := []struct {
+		name         string
+		data         []byte
+		v            interface{}
+		want         interface{} // v is unchanged
+		wantErrorMsg string
+	}{
+		{
+			name:         "text string keys to map",
+			data:         hexDecode("a3616201616101616202"), // {"b": 1, "a": 1, "b": 2}
+			v:            &map[string]int{"c": 3},
+			want:         &map[string]int{"c": 3},
+			wantErrorMsg: "cbor: found duplicate map key \"b\" at map element index 2",
+		},
+		{
+			name:         "text string keys to struct",
+			data:         hexDecode("a3616201616101616202"), // {"b": 1, "a": 1, "b": 2}
+			v:            &s{A: 5},
+			want:         &s{A: 5},
+			wantErrorMsg: "cbor: found duplicate map key \"b\" at map element index 2",
+		},
+		{
+			name:         "integer keys to struct",
+			data:         hexDecode("a2010118010102"), // {1: 1, 1: 2} with non-preferred encoding of second key
+			v:            &s{},
+			want:         &s{},
+			wantErrorMsg: "cbor: found duplicate map key \"1\" at map element index 1",
+		},
+		{
+			name:         "float keys with different precision",
+			data:         hexDecode("a2f93c0001fa3f80000002"), // {1.0: 1, 1.0: 2}
+			v:            new(interface{}),
+			want:         new(interface{}),
+			wantErrorMsg: "cbor: found duplicate map key \"1\" at map element index 1",
+		},
+		{
+			name:         "array keys to empty interface",
+			data:         hexDecode("a2810001810002"), // {[0]: 1, [0]: 2}
+			v:            new(interface{}),
+			want:         new(interface{}),
+			wantErrorMsg: "cbor: found duplicate map key \"[0]\" at map element index 1",
+		},
+		{
+			name:         "byte string keys to struct",
+			data:         hexDecode("a2416101416102"), // {h'61': 1, h'61': 2}
+			v:            &s{},
+			want:         &s{},
+			wantErrorMsg: "cbor: found duplicate map key \"a\" at map element index 1",
+		},
+		{
+			name:         "nested map",
+			data:         hexDecode("82a0a2616101616102"), // [{}, {"a": 1, "a": 2}]
+			v:            &[]map[string]int{{"c": 3}},
+			want:         &[]map[string]int{{"c": 3}},
+			wantErrorMsg: "cbor: found duplicate map key \"a\" at map element index 1",
+		},
+		{
+			name:         "indefinite-length map",
+			data:         hexDecode("bf616101616102ff"), // {_ "a": 1, "a": 2}
+			v:            &map[string]int{},
+			want:         &map[string]int{},
+			wantErrorMsg: "cbor: found duplicate map key \"a\" at map element index 1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := dm.Unmarshal(tc.data, tc.v)
+			if err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error", tc.data)
+			} else if _, ok := err.(*DupMapKeyError); !ok {
+				t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*DupMapKeyError)", tc.data, err)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			} else if !errors.Is(err, ErrDuplicateMapKey) {
+				t.Errorf("Unmarshal(0x%x) returned error %v that doesn't match ErrDuplicateMapKey", tc.data, err)
+			}
+			if !reflect.DeepEqual(tc.v, tc.want) {
+				t.Errorf("Unmarshal(0x%x) = %v, want %v", tc.data, tc.v, tc.want)
+			}
+		})
+	}
+
+	// Keys that are different CBOR values aren't duplicates.
+	for _, data := range [][]byte{
+		hexDecode("a20101f93c0002"),   // {1: 1, 1.0: 2}
+		hexDecode("a2416101616102"),   // {h'61': 1, "a": 2}
+		hexDecode("a2810001820000a0"), // {[0]: 1, [0, 0]: {}}
+		hexDecode("a2c101010102"),     // {1(1): 1, 1: 2}
+	} {
+		if err := dm.Wellformed(data); err != nil {
+			t.Errorf("Wellformed(0x%x) returned error %v", data, err)
+		}
+	}
+
+	// Keys that are only duplicates in the destination type are rejected while decoding.
+	data := hexDecode("a2614101616102") // {"A": 1, "a": 2}
+	var v s
+	if err := dm.Unmarshal(data, &v); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+	} else if _, ok := err.(*DupMapKeyError); !ok {
+		t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*DupMapKeyError)", data, err)
+	}
+}
+
+func TestUnmarshalWithUnrecognizedTagForbidden(t *testing.T) {
+	type myInt int
+
+	tags := NewTagSet()
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagRequired}, reflect.TypeOf(myInt(0)), 100); err != nil {
+		t.Fatalf("TagSet.Add() returned error %v", err)
+	}
+	dm, err := DecOptions{UnrecognizedTagToAny: UnrecognizedTagForbidden}.DecModeWithTags(tags)
+	if err != nil {
+		t.Fatalf("DecModeWithTags() returned error %v", err)
+	}
+
+	for _, tc := range []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "unrecognized tag",
+			data:         hexDecode("d8ff00"), // 255(0)
+			wantErrorMsg: "cbor: data item of cbor type tag is not accepted by protocol: tag number 255 is not recognized",
+		},
+		{
+			name:         "unrecognized tag in array",
+			data:         hexDecode("8201d9012c6161"), // [1, 300("a")]
+			wantErrorMsg: "cbor: data item of cbor type tag is not accepted by protocol: tag number 300 is not recognized",
+		},
+		{
+			name:         "unrecognized tag enclosing registered tag",
+			data:         hexDecode("d8ffd86401"), // 255(100(1))
+			wantErrorMsg: "cbor: data item of cbor type tag is not accepted by protocol: tag number 255 is not recognized",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var v interface{}
+			if err := dm.Unmarshal(tc.data, &v); err == nil {
+				t.Errorf("Unmarshal(0x%x) didn't return an error", tc.data)
+			} else if _, ok := err.(*UnacceptableDataItemError); !ok {
+				t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*UnacceptableDataItemError)", tc.data, err)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+
+	// Recognized tags are decoded.
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want interface{}
+	}{
+		{
+			name: "registered tag",
+			data: hexDecode("d86401"), // 100(1)
+			want: myInt(1),
+		},
+		{
+			name: "epoch time",
+			data: hexDecode("c100"), // 1(0)
+			want: time.Unix(0, 0),
+		},
+		{
+			name: "bignum",
+			data: hexDecode("c24101"), // 2(h'01')
+			want: *big.NewInt(1),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var v interface{}
+			if err := dm.Unmarshal(tc.data, &v); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if tm, ok := tc.want.(time.Time); ok {
+				if got, ok := v.(time.Time); !ok || !got.Equal(tm) {
+					t.Errorf("Unmarshal(0x%x) = %v (%T), want %v (%T)", tc.data, v, v, tc.want, tc.want)
+				}
+			} else if !reflect.DeepEqual(v, tc.want) {
+				t.Errorf("Unmarshal(0x%x) = %v (%T), want %v (%T)", tc.data, v, v, tc.want, tc.want)
+			}
+		})
+	}
+
+	// Unrecognized tags decoded to Tag aren't rejected.
+	data := hexDecode("d8ff00") // 255(0)
+	var tag Tag
+	if err := dm.Unmarshal(data, &tag); err != nil {
+		t.Errorf("Unmarshal(0x%x) returned error %v", data, err)
+	} else if want := (Tag{Number: 255, Content: uint64(0)}); !reflect.DeepEqual(tag, want) {
+		t.Errorf("Unmarshal(0x%x) = %v, want %v", data, tag, want)
+	}
+}
@@ -609,7 +609,7 @@ func (di *diagnose) encodeTextString(val string, quote byte) error {
 		c, size := utf8.DecodeRuneInString(val[i:])
 		switch {
 		case c == utf8.RuneError:
-			return &SemanticError{"cbor: invalid UTF-8 string"}
+			return &SemanticError{msg: "cbor: invalid UTF-8 string"}
 
 		case c < utf16SurrSelf:
 			di.writeU16(c)
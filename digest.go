@@ -0,0 +1,158 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"sort"
+)
+
+// Digest returns a digest of v encoded using em, computed with hash functions returned
+// by newHash (e.g. sha256.New).  If em is nil, Core Deterministic Encoding options are
+// used.  The digest doesn't depend on the order of map entries, so it can be used in
+// place of hashing canonical encoding, e.g. for deduplication, without sorting encoded
+// map entries of large maps.
+//
+// The digest is the hash of the canonical encoding of v (see Canonicalize), except that
+// each map is represented by its head followed by, for each entry in bytewise order of
+// key digests, the digest of its key and the digest of its value, where the digest of a
+// data item is the hash of its representation.  So the digest isn't the same as the hash
+// of the canonical encoding of v.  Map keys are compared by their digests, and an error
+// is returned if a map contains duplicate keys.
+//
+// v can be RawMessage to compute the digest of encoded CBOR data.
+func Digest(v interface{}, em EncMode, newHash func() hash.Hash) ([]byte, error) {
+	if newHash == nil {
+		return nil, errors.New("cbor: cannot compute digest with nil hash function")
+	}
+	iem, err := canonicalEncMode(em)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encode without sorting map keys because map entries are ordered by digests.
+	// Indefinite-length items are allowed because they are digested as definite-length.
+	unsorted := *iem
+	unsorted.sort = SortNone
+	unsorted.indefLength = IndefLengthAllowed
+	unsorted.selfDescribedCBOR = SelfDescribedCBORNone
+
+	e := getEncodeBuffer()
+	defer putEncodeBuffer(e)
+
+	if err := encodeValue(e, &unsorted, v); err != nil {
+		return nil, err
+	}
+
+	d := decoder{data: e.Bytes(), dm: defaultDecMode}
+	if err := d.wellformed(false, false); err != nil {
+		return nil, err
+	}
+	d.reset(e.Bytes())
+
+	dg := digester{em: &unsorted, d: &d, newHash: newHash}
+	h := newHash()
+	if err := dg.digest(h); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+type digester struct {
+	em      *encMode
+	d       *decoder
+	newHash func() hash.Hash
+	scratch bytes.Buffer
+}
+
+// mapEntryDigest is the digest of a map entry's key and value.
+type mapEntryDigest struct {
+	key   []byte
+	value []byte
+}
+
+// digest writes representation of the data item at d.off to h, and moves cursor past it.
+func (dg *digester) digest(h hash.Hash) error {
+	d := dg.d
+
+	t := d.nextCBORType()
+	switch t {
+	case cborTypeArray, cborTypeMap:
+		_, _, val, indefiniteLength := d.getHeadWithIndefiniteLengthFlag()
+		count := int(val)
+		if indefiniteLength {
+			count = d.numOfItemsUntilBreak()
+			if t == cborTypeMap {
+				count /= 2
+			}
+		}
+
+		dg.scratch.Reset()
+		encodeHead(&dg.scratch, byte(t), uint64(count))
+		h.Write(dg.scratch.Bytes())
+
+		var err error
+		if t == cborTypeArray {
+			for i := 0; i < count && err == nil; i++ {
+				err = dg.digest(h)
+			}
+		} else {
+			err = dg.digestMapEntries(h, count)
+		}
+		if err != nil {
+			return err
+		}
+
+		if indefiniteLength {
+			d.off++ // Skip break code
+		}
+		return nil
+
+	case cborTypeTag:
+		_, _, tagNum := d.getHead()
+		dg.scratch.Reset()
+		encodeHead(&dg.scratch, byte(t), tagNum)
+		h.Write(dg.scratch.Bytes())
+		return dg.digest(h)
+	}
+
+	dg.scratch.Reset()
+	if err := canonicalize(&dg.scratch, dg.em, d); err != nil {
+		return err
+	}
+	h.Write(dg.scratch.Bytes())
+	return nil
+}
+
+// digestMapEntries writes digests of count map entries at d.off to h, in bytewise order
+// of key digests.
+func (dg *digester) digestMapEntries(h hash.Hash, count int) error {
+	entries := make([]mapEntryDigest, count)
+	for i := range entries {
+		kh := dg.newHash()
+		if err := dg.digest(kh); err != nil {
+			return err
+		}
+		vh := dg.newHash()
+		if err := dg.digest(vh); err != nil {
+			return err
+		}
+		entries[i] = mapEntryDigest{key: kh.Sum(nil), value: vh.Sum(nil)}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	for i, entry := range entries {
+		if i > 0 && bytes.Equal(entries[i-1].key, entry.key) {
+			return errors.New("cbor: found duplicate map key while computing digest")
+		}
+		h.Write(entry.key)
+		h.Write(entry.value)
+	}
+	return nil
+}
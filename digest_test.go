@@ -0,0 +1,108 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	sum := func(data []byte) []byte {
+		s := sha256.Sum256(data)
+		return s[:]
+	}
+
+	t.Run("non-map value", func(t *testing.T) {
+		// [_ 1.5, "a"] is canonicalized to [1.5, "a"] with float16.
+		got, err := Digest(RawMessage(hexDecode("9ffb3ff80000000000006161ff")), nil, sha256.New)
+		if err != nil {
+			t.Fatalf("Digest() returned error %v", err)
+		}
+		if want := sum(hexDecode("82f93e006161")); !bytes.Equal(got, want) {
+			t.Errorf("Digest() = 0x%x, want 0x%x", got, want)
+		}
+	})
+
+	t.Run("map", func(t *testing.T) {
+		got, err := Digest(map[string]int{"a": 1, "b": 2}, nil, sha256.New)
+		if err != nil {
+			t.Fatalf("Digest() returned error %v", err)
+		}
+
+		entries := [][2][]byte{
+			{sum(hexDecode("6161")), sum(hexDecode("01"))},
+			{sum(hexDecode("6162")), sum(hexDecode("02"))},
+		}
+		if bytes.Compare(entries[0][0], entries[1][0]) > 0 {
+			entries[0], entries[1] = entries[1], entries[0]
+		}
+		representation := hexDecode("a2")
+		for _, entry := range entries {
+			representation = append(representation, entry[0]...)
+			representation = append(representation, entry[1]...)
+		}
+		if want := sum(representation); !bytes.Equal(got, want) {
+			t.Errorf("Digest() = 0x%x, want 0x%x", got, want)
+		}
+	})
+
+	t.Run("map entry order", func(t *testing.T) {
+		testCases := []interface{}{
+			map[string]interface{}{"a": 1, "b": []interface{}{2, map[int]string{3: "c", 4: "d"}}},
+			// {"b": [2, {4: "d", 3: "c"}], "a": 1}
+			RawMessage(hexDecode("a261628202a2046164036163616101")),
+			// {_ "a": 1, "b": [2, {_ 3: "c", 4: "d"}]}
+			RawMessage(hexDecode("bf61610161628202bf036163046164ffff")),
+		}
+		want, err := Digest(testCases[0], nil, sha256.New)
+		if err != nil {
+			t.Fatalf("Digest() returned error %v", err)
+		}
+		for _, v := range testCases[1:] {
+			got, err := Digest(v, nil, sha256.New)
+			if err != nil {
+				t.Fatalf("Digest(%v) returned error %v", v, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Digest(%v) = 0x%x, want 0x%x", v, got, want)
+			}
+		}
+
+		got, err := Digest(map[string]interface{}{"a": 1, "b": []interface{}{2, map[int]string{3: "c", 4: "e"}}}, nil, sha256.New)
+		if err != nil {
+			t.Fatalf("Digest() returned error %v", err)
+		}
+		if bytes.Equal(got, want) {
+			t.Errorf("Digest() of different values returned the same digest 0x%x", got)
+		}
+	})
+}
+
+func TestDigestError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		v            interface{}
+		wantErrorMsg string
+	}{
+		{"malformed data", RawMessage(hexDecode("81")), "cbor: error calling MarshalCBOR for type cbor.RawMessage: unexpected EOF"},
+		{"duplicate map keys", RawMessage(hexDecode("a218010101f6")), "cbor: found duplicate map key while computing digest"},
+		{"unsupported type", make(chan int), "cbor: unsupported type: chan int"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Digest(tc.v, nil, sha256.New)
+			if err == nil {
+				t.Errorf("Digest() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Digest() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+
+	if _, err := Digest(1, nil, nil); err == nil {
+		t.Errorf("Digest() with nil hash function didn't return an error")
+	}
+}
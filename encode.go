@@ -16,8 +16,10 @@ import (
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/x448/float16"
 )
@@ -58,17 +60,43 @@ import (
 //
 // Marshal supports format string stored under the "cbor" key in the struct
 // field's tag.  CBOR format string can specify the name of the field,
-// "omitempty" and "keyasint" options, and special case "-" for field omission.
-// If "cbor" key is absent, Marshal uses "json" key.
+// "omitempty", "omitzero", and "keyasint" options, and special case "-" for field
+// omission.  If "cbor" key is absent, Marshal uses "json" key.
+//
+// Struct field with "omitzero" option is omitted if its value is the zero value
+// of its type.  If the field type has an IsZero() bool method, it is used to
+// determine whether the value is zero (e.g. time.Time).  Unlike "omitempty",
+// "omitzero" omits zero structs and zero-length arrays, and doesn't omit
+// empty non-nil slices and maps.
+//
+// Struct field with "unknown" option (map[string]RawMessage or map[interface{}]RawMessage)
+// holds map entries without corresponding struct fields, e.g. captured by Unmarshal for
+// forward compatibility.  Its entries are encoded as pairs of the struct's CBOR map, and
+// an error is returned if a map key is the same as the name of another field.  "unknown"
+// is disabled by "toarray".
+//
+// Struct field with "inline" option is merged into the parent CBOR map.  Fields of a
+// struct (or pointer to struct) field are encoded as if they were fields of the parent
+// struct, same as anonymous struct fields.  Entries of a map field (with string or
+// interface{} keys) are encoded as pairs of the parent map, same as "unknown" option,
+// and a struct can have only one map field with "unknown" or "inline" option.
+//
+// Struct field with "array" option (byte slice or byte array) is encoded as CBOR array
+// of unsigned integers instead of CBOR byte string, regardless of EncOptions.ByteSlice
+// and EncOptions.ByteArray.
+//
+// Struct field with "nilasempty" or "nilasnull" option (slice or map) is encoded as empty
+// CBOR container or CBOR null respectively when nil, regardless of EncOptions.NilContainers.
 //
 // Struct field name is treated as integer if it has "keyasint" option in
 // its format string.  The format string must specify an integer as its
-// field name.
+// field name, which can be any CBOR integer from -2^64 to 2^64-1 (e.g. large
+// labels used by CWT and COSE extensions) regardless of the size of int.
 //
 // Special struct field "_" is used to specify struct level options, such as
 // "toarray". "toarray" option enables Go struct to be encoded as CBOR array.
-// "omitempty" is disabled by "toarray" to ensure that the same number
-// of elements are encoded every time.
+// "omitempty" and "omitzero" are disabled by "toarray" to ensure that the same
+// number of elements are encoded every time.
 //
 // Anonymous struct fields are marshaled as if their exported fields
 // were fields in the outer struct.  Marshal follows the same struct fields
@@ -84,6 +112,9 @@ import (
 // EncOptions.BigIntConvert to always encode big.Int values as CBOR
 // bignums.
 //
+// json.Number values encode as CBOR text strings, or as CBOR numbers with
+// EncOptions.JSONNumber set to JSONNumberToNumber.
+//
 // Pointer values encode as the value pointed to.
 //
 // Interface values encode as the value stored in the interface.
@@ -107,12 +138,44 @@ func MarshalToBuffer(v interface{}, buf *bytes.Buffer) error {
 	return defaultEncMode.MarshalToBuffer(v, buf)
 }
 
+// MarshalAppend appends the CBOR encoding of v to dst and returns the extended buffer,
+// using default encoding options.  Reusing dst across calls avoids allocating a new
+// slice for each encoded data item.  If an error is returned, dst is returned unmodified.
+//
+// See Marshal for more details.
+func MarshalAppend(dst []byte, v interface{}) ([]byte, error) {
+	return defaultEncMode.MarshalAppend(dst, v)
+}
+
+// CanMarshal checks whether v can be encoded using default encoding options,
+// without returning encoded data.  It is useful for rejecting values that can't
+// be encoded (e.g. unsupported types, or errors returned by MarshalCBOR) before
+// encoding them.
+//
+// CanMarshal walks structs, slices, arrays, maps, pointers, and interfaces, and it
+// checks other values (including values of types implementing Marshaler) by encoding
+// them into a reused buffer, which is discarded.  It returns MarshalPathError with the
+// location of the first value that can't be encoded.
+func CanMarshal(v interface{}) error {
+	return defaultEncMode.CanMarshal(v)
+}
+
 // Marshaler is the interface implemented by types that can marshal themselves
 // into valid CBOR.
 type Marshaler interface {
 	MarshalCBOR() ([]byte, error)
 }
 
+// MarshalerWithMode is the interface implemented by types that can marshal themselves
+// into valid CBOR using the encoding mode of the caller.  This allows custom types
+// to honor the caller's encoding options (e.g. Sort, ShortestFloat, registered tags)
+// when marshaling their contents.
+//
+// If a type implements both MarshalerWithMode and Marshaler, MarshalerWithMode is used.
+type MarshalerWithMode interface {
+	MarshalCBORWithMode(em EncMode) ([]byte, error)
+}
+
 // MarshalerError represents error from checking encoded CBOR data item
 // returned from MarshalCBOR for well-formedness and some very limited tag validation.
 type MarshalerError struct {
@@ -150,6 +213,27 @@ func (e *UnsupportedValueError) Error() string {
 	return "cbor: unsupported value: " + e.msg
 }
 
+// MarshalPathError is returned by CanMarshal when attempting to encode a value that
+// can't be encoded.  Path is the location of the value in Go syntax relative to the
+// value passed to CanMarshal (e.g. ".Items[2].Price"), and it is empty for the value
+// itself.  Err is the error that Marshal returns for the value.
+type MarshalPathError struct {
+	Path string
+	Err  error
+}
+
+func (e *MarshalPathError) Error() string {
+	msg := strings.TrimPrefix(e.Err.Error(), "cbor: ")
+	if e.Path == "" {
+		return "cbor: cannot marshal value: " + msg
+	}
+	return "cbor: cannot marshal value at " + e.Path + ": " + msg
+}
+
+func (e *MarshalPathError) Unwrap() error {
+	return e.Err
+}
+
 // SortMode identifies supported sorting order.
 type SortMode int
 
@@ -176,6 +260,11 @@ const (
 	// constant.
 	SortFastShuffle SortMode = 3
 
+	// SortCustom causes map keys or struct fields to be sorted by EncOptions.SortLess,
+	// which compares their encodings.  It can be used to produce byte-identical output
+	// for systems that use a non-standard ordering.
+	SortCustom SortMode = 4
+
 	// SortCanonical is used in "Canonical CBOR" encoding in RFC 7049 3.9.
 	SortCanonical SortMode = SortLengthFirst
 
@@ -185,13 +274,37 @@ const (
 	// SortCoreDeterministic is used in "Core Deterministic Encoding" in RFC 7049bis.
 	SortCoreDeterministic SortMode = SortBytewiseLexical
 
-	maxSortMode SortMode = 4
+	maxSortMode SortMode = 5
 )
 
 func (sm SortMode) valid() bool {
 	return sm >= 0 && sm < maxSortMode
 }
 
+// SortMapMode specifies how encoded map entries are put in the order specified by SortMode.
+// It doesn't affect encoded data, only memory use and speed of encoding sorted maps.
+type SortMapMode int
+
+const (
+	// SortMapEncodedPairs encodes all key-value pairs of a map and then rearranges
+	// the encoded pairs in sorted order.  It is fastest but needs temporary memory
+	// for twice the size of the encoded map.
+	SortMapEncodedPairs SortMapMode = iota
+
+	// SortMapEncodedKeys encodes and sorts only the keys of a map, and then encodes
+	// each value directly after its key in sorted order.  Encoded values are never
+	// moved or copied, so temporary memory is proportional to the size of encoded keys
+	// instead of the size of the encoded map.  It is useful for encoding very large
+	// maps or maps with large values, at the cost of one map lookup per entry.
+	SortMapEncodedKeys
+
+	maxSortMapMode
+)
+
+func (smm SortMapMode) valid() bool {
+	return smm >= 0 && smm < maxSortMapMode
+}
+
 // StringMode specifies how to encode Go string values.
 type StringMode int
 
@@ -298,11 +411,12 @@ const (
 	// TimeUnix causes time.Time to be encoded as epoch time in integer with second precision.
 	TimeUnix TimeMode = iota
 
-	// TimeUnixMicro causes time.Time to be encoded as epoch time in float-point rounded to microsecond precision.
+	// TimeUnixMicro causes time.Time to be encoded as epoch time in float-point rounded to microsecond precision
+	// (or precision specified by TimePrecision).
 	TimeUnixMicro
 
 	// TimeUnixDynamic causes time.Time to be encoded as integer if time.Time doesn't have fractional seconds,
-	// otherwise float-point rounded to microsecond precision.
+	// otherwise float-point rounded to microsecond precision (or precision specified by TimePrecision).
 	TimeUnixDynamic
 
 	// TimeRFC3339 causes time.Time to be encoded as RFC3339 formatted string with second precision.
@@ -311,6 +425,11 @@ const (
 	// TimeRFC3339Nano causes time.Time to be encoded as RFC3339 formatted string with nanosecond precision.
 	TimeRFC3339Nano
 
+	// TimeExtended causes time.Time to be encoded as extended time (tag 1001) defined in RFC 9581,
+	// with integer epoch seconds (map key 1) and nanoseconds (map key -9) if non-zero.
+	// Tag number 1001 is always encoded, regardless of TimeTag.
+	TimeExtended
+
 	maxTimeMode
 )
 
@@ -318,6 +437,84 @@ func (tm TimeMode) valid() bool {
 	return tm >= 0 && tm < maxTimeMode
 }
 
+// TimePrecisionMode specifies precision of time.Time encoded as epoch time in float-point
+// (TimeUnixMicro and TimeUnixDynamic).
+type TimePrecisionMode int
+
+const (
+	// TimePrecisionMicrosecond rounds time.Time to microsecond precision.
+	TimePrecisionMicrosecond TimePrecisionMode = iota
+
+	// TimePrecisionSecond rounds time.Time to second precision.
+	TimePrecisionSecond
+
+	// TimePrecisionMillisecond rounds time.Time to millisecond precision.
+	TimePrecisionMillisecond
+
+	// TimePrecisionNanosecond keeps nanosecond precision of time.Time.  Note that float64
+	// can't represent all nanoseconds of recent epoch times exactly.
+	TimePrecisionNanosecond
+
+	maxTimePrecisionMode
+)
+
+func (tpm TimePrecisionMode) valid() bool {
+	return tpm >= 0 && tpm < maxTimePrecisionMode
+}
+
+func (tpm TimePrecisionMode) duration() time.Duration {
+	switch tpm {
+	case TimePrecisionSecond:
+		return time.Second
+	case TimePrecisionMillisecond:
+		return time.Millisecond
+	case TimePrecisionNanosecond:
+		return time.Nanosecond
+	default:
+		return time.Microsecond
+	}
+}
+
+// TimeZoneMode specifies the time zone of time.Time encoded as RFC3339 formatted string
+// (TimeRFC3339 and TimeRFC3339Nano).
+type TimeZoneMode int
+
+const (
+	// TimeZonePreserve encodes time.Time with its own time zone offset.
+	TimeZonePreserve TimeZoneMode = iota
+
+	// TimeZoneUTC converts time.Time to UTC before encoding.
+	TimeZoneUTC
+
+	maxTimeZoneMode
+)
+
+func (tzm TimeZoneMode) valid() bool {
+	return tzm >= 0 && tzm < maxTimeZoneMode
+}
+
+// DurationMode specifies how to encode time.Duration values.
+type DurationMode int
+
+const (
+	// DurationNanoseconds causes time.Duration to be encoded as integer number of nanoseconds.
+	DurationNanoseconds DurationMode = iota
+
+	// DurationSecondsDynamic causes time.Duration to be encoded as integer number of seconds
+	// if it doesn't have fractional seconds, otherwise float-point number of seconds.
+	DurationSecondsDynamic
+
+	// DurationExtended causes time.Duration to be encoded as duration (tag 1002) defined
+	// in RFC 9581, with integer seconds (map key 1) and nanoseconds (map key -9) if non-zero.
+	DurationExtended
+
+	maxDurationMode
+)
+
+func (dm DurationMode) valid() bool {
+	return dm >= 0 && dm < maxDurationMode
+}
+
 // BigIntConvertMode specifies how to encode big.Int values.
 type BigIntConvertMode int
 
@@ -341,6 +538,48 @@ func (bim BigIntConvertMode) valid() bool {
 	return bim >= 0 && bim < maxBigIntConvert
 }
 
+// BigFloatMode specifies how to encode big.Float values.
+type BigFloatMode int
+
+const (
+	// BigFloatStruct encodes big.Float as a Go struct without exported fields (an empty
+	// CBOR map), same as previous releases.
+	BigFloatStruct BigFloatMode = iota
+
+	// BigFloatBigfloat encodes big.Float as CBOR bigfloat (tag 5).
+	BigFloatBigfloat
+
+	maxBigFloatMode
+)
+
+func (bfm BigFloatMode) valid() bool {
+	return bfm >= 0 && bfm < maxBigFloatMode
+}
+
+// BigRatMode specifies how to encode big.Rat values.
+type BigRatMode int
+
+const (
+	// BigRatStruct encodes big.Rat as a Go struct without exported fields (an empty
+	// CBOR map), same as previous releases.
+	BigRatStruct BigRatMode = iota
+
+	// BigRatDecimalFraction encodes big.Rat as CBOR decimal fraction (tag 4), and
+	// returns UnsupportedValueError if the value can't be represented exactly as
+	// decimal fraction (e.g. 1/3).
+	BigRatDecimalFraction
+
+	// BigRatRational encodes big.Rat as CBOR rational number (tag 30), which is an
+	// array of numerator and positive denominator in lowest terms.
+	BigRatRational
+
+	maxBigRatMode
+)
+
+func (brm BigRatMode) valid() bool {
+	return brm >= 0 && brm < maxBigRatMode
+}
+
 // NilContainersMode specifies how to encode nil slices and maps.
 type NilContainersMode int
 
@@ -386,6 +625,31 @@ func (om OmitEmptyMode) valid() bool {
 	return om >= 0 && om < maxOmitEmptyMode
 }
 
+// EmptyStructMode specifies how to encode Go structs (not encoded as CBOR array
+// with "toarray" option) that have no fields to encode, either because the struct
+// has no exported fields or because all of its fields were omitted by "omitempty".
+type EmptyStructMode int
+
+const (
+	// EmptyStructAsEmptyMap encodes empty structs to CBOR empty map (0xa0).
+	EmptyStructAsEmptyMap EmptyStructMode = iota
+
+	// EmptyStructAsNull encodes empty structs to CBOR null (0xf6).
+	EmptyStructAsNull
+
+	// EmptyStructOmitted omits struct fields whose value is an empty struct from
+	// the encoded parent struct, as if the field were tagged with "omitempty".
+	// Empty structs that are not struct field values (e.g. top-level values and
+	// array elements) are encoded to CBOR empty map (0xa0).
+	EmptyStructOmitted
+
+	maxEmptyStructMode
+)
+
+func (esm EmptyStructMode) valid() bool {
+	return esm >= 0 && esm < maxEmptyStructMode
+}
+
 // FieldNameMode specifies the CBOR type to use when encoding struct field names.
 type FieldNameMode int
 
@@ -393,7 +657,9 @@ const (
 	// FieldNameToTextString encodes struct fields to CBOR text string (major type 3).
 	FieldNameToTextString FieldNameMode = iota
 
-	// FieldNameToTextString encodes struct fields to CBOR byte string (major type 2).
+	// FieldNameToByteString encodes struct fields to CBOR byte string (major type 2).
+	// String keys of map[string]RawMessage field with "unknown" option are also encoded
+	// to CBOR byte string.
 	FieldNameToByteString
 
 	maxFieldNameMode
@@ -464,6 +730,24 @@ func (bam ByteArrayMode) valid() bool {
 	return bam >= 0 && bam < maxByteArrayMode
 }
 
+// ByteSliceMode specifies how to encode byte slices.
+type ByteSliceMode int
+
+const (
+	// ByteSliceToByteString encodes byte slices to CBOR byte string type.
+	ByteSliceToByteString ByteSliceMode = iota
+
+	// ByteSliceToArray encodes byte slices to CBOR array type with one unsigned integer
+	// item for each byte in the slice.  ByteSliceLaterFormat is ignored.
+	ByteSliceToArray
+
+	maxByteSliceMode
+)
+
+func (bsm ByteSliceMode) valid() bool {
+	return bsm >= 0 && bsm < maxByteSliceMode
+}
+
 // BinaryMarshalerMode specifies how to encode types that implement encoding.BinaryMarshaler.
 type BinaryMarshalerMode int
 
@@ -481,11 +765,200 @@ func (bmm BinaryMarshalerMode) valid() bool {
 	return bmm >= 0 && bmm < maxBinaryMarshalerMode
 }
 
+// StringerEnumMode specifies how to encode integer types that implement fmt.Stringer,
+// such as time.Month and time.Weekday.
+type StringerEnumMode int
+
+const (
+	// StringerEnumNone encodes integer types that implement fmt.Stringer as integers.
+	StringerEnumNone StringerEnumMode = iota
+
+	// StringerEnumToString encodes integer types that implement fmt.Stringer to CBOR text
+	// string (or the major type specified by String option) containing the output of String.
+	StringerEnumToString
+
+	maxStringerEnumMode
+)
+
+func (sem StringerEnumMode) valid() bool {
+	return sem >= 0 && sem < maxStringerEnumMode
+}
+
+// EncodedItemMode specifies how to encode EncodedItem.
+type EncodedItemMode int
+
+const (
+	// EncodedItemToTag encodes EncodedItem to CBOR tag 24 (encoded CBOR data item)
+	// enclosing a byte string.
+	EncodedItemToTag EncodedItemMode = iota
+
+	// EncodedItemToByteString encodes EncodedItem to a byte string without tag 24,
+	// e.g. for CDDL "bstr .cbor" types such as COSE protected headers.
+	EncodedItemToByteString
+
+	maxEncodedItemMode
+)
+
+func (eim EncodedItemMode) valid() bool {
+	return eim >= 0 && eim < maxEncodedItemMode
+}
+
+// SelfDescribedCBORMode specifies whether to prefix encoded top-level CBOR data items
+// with the self-described CBOR tag number 55799 (RFC 8949 Section 3.4.6), so that
+// CBOR data can be recognized by its first bytes (0xd9d9f7).
+type SelfDescribedCBORMode int
+
+const (
+	// SelfDescribedCBORNone doesn't prefix encoded data items with tag number 55799.
+	SelfDescribedCBORNone SelfDescribedCBORMode = iota
+
+	// SelfDescribedCBOREachItem prefixes each top-level data item encoded by Marshal,
+	// MarshalToBuffer, and Encoder with tag number 55799.
+	SelfDescribedCBOREachItem
+
+	// SelfDescribedCBOROncePerStream prefixes the first top-level data item written by
+	// Encoder (after it is created or reset) with tag number 55799.  Marshal and
+	// MarshalToBuffer prefix each data item as with SelfDescribedCBOREachItem.
+	SelfDescribedCBOROncePerStream
+
+	maxSelfDescribedCBORMode
+)
+
+func (sdm SelfDescribedCBORMode) valid() bool {
+	return sdm >= 0 && sdm < maxSelfDescribedCBORMode
+}
+
+// JSONNumberMode specifies how to encode json.Number.
+type JSONNumberMode int
+
+const (
+	// JSONNumberToString encodes json.Number to CBOR text string like other Go strings.
+	JSONNumberToString JSONNumberMode = iota
+
+	// JSONNumberToNumber encodes json.Number to CBOR integer if it is an integer literal
+	// (without fraction or exponent), using bignum if it doesn't fit in CBOR integer,
+	// and to CBOR floating-point number otherwise.  Invalid json.Number returns an error.
+	JSONNumberToNumber
+
+	maxJSONNumberMode
+)
+
+func (jnm JSONNumberMode) valid() bool {
+	return jnm >= 0 && jnm < maxJSONNumberMode
+}
+
+// NumericReductionMode specifies whether to encode floating-point values as integers.
+type NumericReductionMode int
+
+const (
+	// NumericReductionNone encodes floating-point values as floating-point numbers.
+	NumericReductionNone NumericReductionMode = iota
+
+	// NumericReductionFloatToInt encodes floating-point values without fractional part
+	// in the range [-2^63, 2^64-1] as CBOR integers, including negative zero as 0.
+	// This is the numeric reduction of the dCBOR application profile.
+	NumericReductionFloatToInt
+
+	maxNumericReductionMode
+)
+
+func (nrm NumericReductionMode) valid() bool {
+	return nrm >= 0 && nrm < maxNumericReductionMode
+}
+
+// SimpleValuesMode specifies which CBOR simple values can be encoded.
+type SimpleValuesMode int
+
+const (
+	// SimpleValuesAny encodes any simple value.
+	SimpleValuesAny SimpleValuesMode = iota
+
+	// SimpleValuesFalseTrueNull returns an UnsupportedValueError when encoding simple
+	// values other than false, true, and null (e.g. undefined).
+	SimpleValuesFalseTrueNull
+
+	maxSimpleValuesMode
+)
+
+func (svm SimpleValuesMode) valid() bool {
+	return svm >= 0 && svm < maxSimpleValuesMode
+}
+
+// NegativeInt65Mode specifies whether to encode CBOR negative integers less than -2^63,
+// which don't fit in int64.
+type NegativeInt65Mode int
+
+const (
+	// NegativeInt65Allowed encodes integers in the range [-2^64, -2^63-1] as
+	// CBOR negative integers.
+	NegativeInt65Allowed NegativeInt65Mode = iota
+
+	// NegativeInt65Forbidden returns an UnsupportedValueError when encoding integers
+	// in the range [-2^64, -2^63-1] as CBOR negative integers.
+	NegativeInt65Forbidden
+
+	maxNegativeInt65Mode
+)
+
+func (nim NegativeInt65Mode) valid() bool {
+	return nim >= 0 && nim < maxNegativeInt65Mode
+}
+
+// NumericDateMode specifies how to encode NumericDate (e.g. claims in CWTClaims).
+type NumericDateMode int
+
+const (
+	// NumericDateInteger encodes NumericDate as CBOR integer seconds, truncating
+	// fractional seconds.  This is the most interoperable form.
+	NumericDateInteger NumericDateMode = iota
+
+	// NumericDateDynamic encodes NumericDate as CBOR integer if it doesn't have
+	// fractional seconds, otherwise as CBOR floating-point number rounded to the
+	// precision specified by TimePrecision.
+	NumericDateDynamic
+
+	maxNumericDateMode
+)
+
+func (ndm NumericDateMode) valid() bool {
+	return ndm >= 0 && ndm < maxNumericDateMode
+}
+
+// DateTagMode specifies how to encode Date.
+type DateTagMode int
+
+const (
+	// DateTagString encodes Date as tag 1004 (RFC 8943) enclosing "YYYY-MM-DD" text string.
+	DateTagString DateTagMode = iota
+
+	// DateTagDays encodes Date as tag 100 (RFC 8943) enclosing number of days since
+	// 1970-01-01.
+	DateTagDays
+
+	maxDateTagMode
+)
+
+func (dtm DateTagMode) valid() bool {
+	return dtm >= 0 && dtm < maxDateTagMode
+}
+
 // EncOptions specifies encoding options.
+//
+// Options that hold maps or functions (e.g. EmptyPredicates) refer to immutable values
+// by pointer, so EncOptions remains comparable with ==.
 type EncOptions struct {
 	// Sort specifies sorting order.
 	Sort SortMode
 
+	// SortMap specifies how Go map entries are sorted when Sort is SortLengthFirst,
+	// SortBytewiseLexical, or SortCustom.  Default is SortMapEncodedPairs.
+	SortMap SortMapMode
+
+	// SortLess reports whether encoded map key (or struct field name) a sorts before
+	// encoded map key b.  It must be set if Sort is SortCustom, and it must not be set
+	// otherwise.  Use NewLessFunc to create it.
+	SortLess *LessFunc
+
 	// ShortestFloat specifies the shortest floating-point encoding that preserves
 	// the value being encoded.
 	ShortestFloat ShortestFloatMode
@@ -496,9 +969,22 @@ type EncOptions struct {
 	// InfConvert specifies how to encode Inf and it overrides ShortestFloatMode.
 	InfConvert InfConvertMode
 
+	// Float specifies whether to encode floating-point numbers.  FloatForbidden returns
+	// UnsupportedValueError on attempts to encode Go float32 and float64 values (including
+	// time values encoded as floating-point numbers), and MarshalerError if data returned
+	// by Marshaler contains floating-point numbers.  Floating-point values encoded as
+	// integers by NumericReductionFloatToInt are allowed.
+	Float FloatMode
+
 	// BigIntConvert specifies how to encode big.Int values.
 	BigIntConvert BigIntConvertMode
 
+	// BigFloat specifies how to encode big.Float values.  Default is BigFloatStruct.
+	BigFloat BigFloatMode
+
+	// BigRat specifies how to encode big.Rat values.  Default is BigRatStruct.
+	BigRat BigRatMode
+
 	// Time specifies how to encode time.Time.
 	Time TimeMode
 
@@ -506,10 +992,29 @@ type EncOptions struct {
 	// RFC3339 format gets tag number 0, and numeric epoch time tag number 1.
 	TimeTag EncTagMode
 
+	// TimePrecision specifies precision of time.Time encoded as epoch time in float-point.
+	TimePrecision TimePrecisionMode
+
+	// TimeZone specifies time zone of time.Time encoded as RFC3339 formatted string.
+	TimeZone TimeZoneMode
+
+	// Duration specifies how to encode time.Duration.
+	Duration DurationMode
+
 	// IndefLength specifies whether to allow indefinite length CBOR items.
 	IndefLength IndefLengthMode
 
-	// NilContainers specifies how to encode nil slices and maps.
+	// StringChunkSize specifies the max length in bytes of each chunk when encoding
+	// byte strings and text strings.  Strings longer than StringChunkSize are encoded
+	// as indefinite-length strings, so peers can process them incrementally.  Text
+	// strings are split at UTF-8 character boundaries, so each chunk is valid UTF-8.
+	// Default is 0 (strings are not chunked) and it can be set to [0, 2147483647].
+	// Struct field names are not chunked.  It can't be set when IndefLength is
+	// IndefLengthForbidden.
+	StringChunkSize int
+
+	// NilContainers specifies how to encode nil slices and maps.  Struct fields with
+	// "nilasempty" or "nilasnull" option override it.
 	NilContainers NilContainersMode
 
 	// TagsMd specifies whether to allow CBOR tags (major type 6).
@@ -518,6 +1023,9 @@ type EncOptions struct {
 	// OmitEmptyMode specifies how to encode struct fields with omitempty tag.
 	OmitEmpty OmitEmptyMode
 
+	// EmptyStruct specifies how to encode structs that have no fields to encode.
+	EmptyStruct EmptyStructMode
+
 	// String specifies which CBOR type to use when encoding Go strings.
 	// - CBOR text string (major type 3) is default
 	// - CBOR byte string (major type 2)
@@ -536,8 +1044,143 @@ type EncOptions struct {
 	// ByteArray specifies how to encode byte arrays.
 	ByteArray ByteArrayMode
 
+	// ByteSlice specifies how to encode byte slices.  Struct field with "array" option
+	// is encoded as CBOR array regardless of ByteSlice and ByteArray.
+	ByteSlice ByteSliceMode
+
 	// BinaryMarshaler specifies how to encode types that implement encoding.BinaryMarshaler.
 	BinaryMarshaler BinaryMarshalerMode
+
+	// StringerEnums specifies how to encode integer types that implement fmt.Stringer.
+	// Default is StringerEnumNone.  Use DecOptions.StringerEnums to decode the encoded
+	// names back to values.
+	StringerEnums StringerEnumMode
+
+	// EncodedItem specifies how to encode EncodedItem.  Default is EncodedItemToTag.
+	EncodedItem EncodedItemMode
+
+	// SelfDescribedCBOR specifies whether to prefix encoded top-level data items with
+	// the self-described CBOR tag number 55799.  Default is SelfDescribedCBORNone.
+	SelfDescribedCBOR SelfDescribedCBORMode
+
+	// JSONNumber specifies how to encode json.Number.  Default is JSONNumberToString.
+	JSONNumber JSONNumberMode
+
+	// NumericReduction specifies whether to encode floating-point values without
+	// fractional part as integers.  Default is NumericReductionNone.
+	NumericReduction NumericReductionMode
+
+	// SimpleValues specifies which simple values can be encoded, including SimpleValue
+	// and simple values in data transcoded by Canonicalize.  Default is SimpleValuesAny.
+	SimpleValues SimpleValuesMode
+
+	// NegativeInt65 specifies whether to encode integers in the range [-2^64, -2^63-1]
+	// (e.g. big.Int or json.Number) as CBOR negative integers.  Default is NegativeInt65Allowed.
+	NegativeInt65 NegativeInt65Mode
+
+	// SimpleValueAnalogs, if not nil, encodes Go values equal to analogs registered with
+	// WithSimpleValueAnalog as their simple values, so that they round-trip with the same
+	// registry set to DecOptions.SimpleValues.  Analogs must be of named and comparable
+	// types (e.g. constants of user-defined types), which take precedence over Marshaler
+	// and other encoding of these types.  Rejected simple values in the registry don't
+	// affect encoding.  Encoded simple values are subject to the SimpleValues option.
+	SimpleValueAnalogs *SimpleValueRegistry
+
+	// EmptyPredicates, if not nil, maps Go types to functions reporting whether a value
+	// of that type is empty.  A struct field with "omitempty" option is omitted if its type
+	// is in EmptyPredicates and the function returns true, regardless of OmitEmpty.
+	// This allows "omitempty" to work with types that can't be modified, e.g. time.Time
+	// with IsZero().  Types are matched exactly, so functions registered for pointer types
+	// can receive nil pointers.  Use NewEmptyPredicates to create it.
+	EmptyPredicates *EmptyPredicates
+
+	// BufferPool, if not nil, provides buffers used to build encoded data instead of the
+	// built-in buffer pool.  See BufferPool and SizeClassBufferPool for details.
+	BufferPool BufferPool
+
+	// NumericDate specifies how to encode NumericDate.  Default is NumericDateInteger.
+	NumericDate NumericDateMode
+
+	// DateTag specifies how to encode Date.  Default is DateTagString.
+	DateTag DateTagMode
+
+	// EncodeHook, if not nil, is called before encoding the value passed to Marshal and
+	// each of its array and slice elements, map values, and struct fields, e.g. to mask
+	// secrets or convert units without implementing Marshaler for every type.  path is
+	// the location of v in Go syntax relative to the value passed to Marshal (e.g.
+	// ".Items[2].Price"), same as MarshalPathError, and it is empty for the value itself.
+	// Interface values are passed with their concrete values.  If EncodeHook returns true,
+	// replacement is encoded instead of v, and EncodeHook is called for its elements and
+	// fields.  Struct fields are omitted by "omitempty" and "omitzero" options based on
+	// their original values.  EncodeHook isn't called for map keys, data returned by
+	// Marshaler, or values encoded by CanMarshal.  It can be called concurrently by
+	// multiple encoding functions sharing the same EncMode.  Use NewEncodeHook to create it.
+	EncodeHook *EncodeHook
+}
+
+// LessFunc is an immutable function used by EncOptions.SortLess.
+type LessFunc struct {
+	less func(a, b []byte) bool
+}
+
+// NewLessFunc returns LessFunc using less to sort encoded map keys (or struct field
+// names).  Encoded keys are distinct, and less must define a strict weak ordering,
+// e.g. as required by sort.Slice.  It returns nil if less is nil.
+func NewLessFunc(less func(a, b []byte) bool) *LessFunc {
+	if less == nil {
+		return nil
+	}
+	return &LessFunc{less: less}
+}
+
+// get returns less function of f, or nil if f is nil.
+func (f *LessFunc) get() func(a, b []byte) bool {
+	if f == nil {
+		return nil
+	}
+	return f.less
+}
+
+// EmptyPredicates is an immutable map of Go types to functions used by
+// EncOptions.EmptyPredicates.
+type EmptyPredicates struct {
+	m map[reflect.Type]func(v interface{}) bool
+}
+
+// NewEmptyPredicates returns EmptyPredicates with a copy of m, which maps Go types to
+// functions reporting whether a value of that type is empty.  It returns nil if m is
+// empty.
+func NewEmptyPredicates(m map[reflect.Type]func(v interface{}) bool) *EmptyPredicates {
+	if len(m) == 0 {
+		return nil
+	}
+	p := &EmptyPredicates{m: make(map[reflect.Type]func(v interface{}) bool, len(m))}
+	for t, fn := range m {
+		p.m[t] = fn
+	}
+	return p
+}
+
+// get returns function registered for type t, or false if p is nil or t isn't registered.
+func (p *EmptyPredicates) get(t reflect.Type) (func(v interface{}) bool, bool) {
+	if p == nil {
+		return nil, false
+	}
+	fn, ok := p.m[t]
+	return fn, ok
+}
+
+// EncodeHook is an immutable function used by EncOptions.EncodeHook.
+type EncodeHook struct {
+	fn func(path string, v reflect.Value) (replacement interface{}, ok bool)
+}
+
+// NewEncodeHook returns EncodeHook calling fn.  It returns nil if fn is nil.
+func NewEncodeHook(fn func(path string, v reflect.Value) (replacement interface{}, ok bool)) *EncodeHook {
+	if fn == nil {
+		return nil
+	}
+	return &EncodeHook{fn: fn}
 }
 
 // CanonicalEncOptions returns EncOptions for "Canonical CBOR" encoding,
@@ -630,6 +1273,31 @@ func PreferredUnsortedEncOptions() EncOptions {
 	}
 }
 
+// DCBOREncOptions returns EncOptions for the "dCBOR" deterministic CBOR application
+// profile (draft-mcnally-deterministic-cbor), used by Gordian Envelope, with the
+// following rules in addition to those of CoreDetEncOptions:
+//
+//  1. Floating-point values without fractional part in the range [-2^63, 2^64-1] are
+//     encoded as integers, and negative zero is encoded as 0.
+//  2. NaN is encoded as 0xf97e00.
+//  3. Simple values other than false, true, and null (e.g. undefined) are rejected.
+//  4. Integers in the range [-2^64, -2^63-1] (65-bit negative integers) are rejected.
+//
+// Data returned by Marshaler and RawMessage isn't checked for these rules.
+// Use DCBORDecOptions to verify that data complies with dCBOR.
+func DCBOREncOptions() EncOptions {
+	return EncOptions{
+		Sort:             SortCoreDeterministic,
+		ShortestFloat:    ShortestFloat16,
+		NaNConvert:       NaNConvert7e00,
+		InfConvert:       InfConvertFloat16,
+		IndefLength:      IndefLengthForbidden,
+		NumericReduction: NumericReductionFloatToInt,
+		SimpleValues:     SimpleValuesFalseTrueNull,
+		NegativeInt65:    NegativeInt65Forbidden,
+	}
+}
+
 // EncMode returns EncMode with immutable options and no tags (safe for concurrency).
 func (opts EncOptions) EncMode() (EncMode, error) { //nolint:gocritic // ignore hugeParam
 	return opts.encMode()
@@ -668,7 +1336,7 @@ func (opts EncOptions) UserBufferEncModeWithTags(tags TagSet) (UserBufferEncMode
 	}
 	syncTags.RUnlock()
 	if len(ts) > 0 {
-		em.tags = ts
+		em.setTags(ts)
 	}
 	return em, nil
 }
@@ -690,7 +1358,7 @@ func (opts EncOptions) UserBufferEncModeWithSharedTags(tags TagSet) (UserBufferE
 	if err != nil {
 		return nil, err
 	}
-	em.tags = tags
+	em.setTags(tags)
 	return em, nil
 }
 
@@ -698,6 +1366,15 @@ func (opts EncOptions) encMode() (*encMode, error) { //nolint:gocritic // ignore
 	if !opts.Sort.valid() {
 		return nil, errors.New("cbor: invalid SortMode " + strconv.Itoa(int(opts.Sort)))
 	}
+	if !opts.SortMap.valid() {
+		return nil, errors.New("cbor: invalid SortMap " + strconv.Itoa(int(opts.SortMap)))
+	}
+	if opts.Sort == SortCustom && (opts.SortLess == nil || opts.SortLess.less == nil) {
+		return nil, errors.New("cbor: SortLess must be set when Sort is SortCustom")
+	}
+	if opts.Sort != SortCustom && opts.SortLess != nil {
+		return nil, errors.New("cbor: SortLess can only be set when Sort is SortCustom")
+	}
 	if !opts.ShortestFloat.valid() {
 		return nil, errors.New("cbor: invalid ShortestFloatMode " + strconv.Itoa(int(opts.ShortestFloat)))
 	}
@@ -707,18 +1384,43 @@ func (opts EncOptions) encMode() (*encMode, error) { //nolint:gocritic // ignore
 	if !opts.InfConvert.valid() {
 		return nil, errors.New("cbor: invalid InfConvertMode " + strconv.Itoa(int(opts.InfConvert)))
 	}
+	if !opts.Float.valid() {
+		return nil, errors.New("cbor: invalid Float " + strconv.Itoa(int(opts.Float)))
+	}
 	if !opts.BigIntConvert.valid() {
 		return nil, errors.New("cbor: invalid BigIntConvertMode " + strconv.Itoa(int(opts.BigIntConvert)))
 	}
+	if !opts.BigFloat.valid() {
+		return nil, errors.New("cbor: invalid BigFloat " + strconv.Itoa(int(opts.BigFloat)))
+	}
+	if !opts.BigRat.valid() {
+		return nil, errors.New("cbor: invalid BigRat " + strconv.Itoa(int(opts.BigRat)))
+	}
 	if !opts.Time.valid() {
 		return nil, errors.New("cbor: invalid TimeMode " + strconv.Itoa(int(opts.Time)))
 	}
 	if !opts.TimeTag.valid() {
 		return nil, errors.New("cbor: invalid TimeTag " + strconv.Itoa(int(opts.TimeTag)))
 	}
+	if !opts.TimePrecision.valid() {
+		return nil, errors.New("cbor: invalid TimePrecision " + strconv.Itoa(int(opts.TimePrecision)))
+	}
+	if !opts.TimeZone.valid() {
+		return nil, errors.New("cbor: invalid TimeZone " + strconv.Itoa(int(opts.TimeZone)))
+	}
+	if !opts.Duration.valid() {
+		return nil, errors.New("cbor: invalid Duration " + strconv.Itoa(int(opts.Duration)))
+	}
 	if !opts.IndefLength.valid() {
 		return nil, errors.New("cbor: invalid IndefLength " + strconv.Itoa(int(opts.IndefLength)))
 	}
+	if opts.StringChunkSize < 0 || opts.StringChunkSize > maxMaxStringBytes {
+		return nil, errors.New("cbor: invalid StringChunkSize " + strconv.Itoa(opts.StringChunkSize) +
+			" (range is [0, " + strconv.Itoa(maxMaxStringBytes) + "])")
+	}
+	if opts.StringChunkSize > 0 && opts.IndefLength == IndefLengthForbidden {
+		return nil, errors.New("cbor: cannot set IndefLength to IndefLengthForbidden when StringChunkSize is set")
+	}
 	if !opts.NilContainers.valid() {
 		return nil, errors.New("cbor: invalid NilContainers " + strconv.Itoa(int(opts.NilContainers)))
 	}
@@ -728,9 +1430,18 @@ func (opts EncOptions) encMode() (*encMode, error) { //nolint:gocritic // ignore
 	if opts.TagsMd == TagsForbidden && opts.TimeTag == EncTagRequired {
 		return nil, errors.New("cbor: cannot set TagsMd to TagsForbidden when TimeTag is EncTagRequired")
 	}
+	if opts.TagsMd == TagsForbidden && opts.Time == TimeExtended {
+		return nil, errors.New("cbor: cannot set TagsMd to TagsForbidden when Time is TimeExtended")
+	}
+	if opts.TagsMd == TagsForbidden && opts.Duration == DurationExtended {
+		return nil, errors.New("cbor: cannot set TagsMd to TagsForbidden when Duration is DurationExtended")
+	}
 	if !opts.OmitEmpty.valid() {
 		return nil, errors.New("cbor: invalid OmitEmpty " + strconv.Itoa(int(opts.OmitEmpty)))
 	}
+	if !opts.EmptyStruct.valid() {
+		return nil, errors.New("cbor: invalid EmptyStruct " + strconv.Itoa(int(opts.EmptyStruct)))
+	}
 	stringMajorType, err := opts.String.cborType()
 	if err != nil {
 		return nil, err
@@ -745,32 +1456,147 @@ func (opts EncOptions) encMode() (*encMode, error) { //nolint:gocritic // ignore
 	if !opts.ByteArray.valid() {
 		return nil, errors.New("cbor: invalid ByteArray " + strconv.Itoa(int(opts.ByteArray)))
 	}
+	if !opts.ByteSlice.valid() {
+		return nil, errors.New("cbor: invalid ByteSlice " + strconv.Itoa(int(opts.ByteSlice)))
+	}
 	if !opts.BinaryMarshaler.valid() {
 		return nil, errors.New("cbor: invalid BinaryMarshaler " + strconv.Itoa(int(opts.BinaryMarshaler)))
 	}
+	if !opts.StringerEnums.valid() {
+		return nil, errors.New("cbor: invalid StringerEnums " + strconv.Itoa(int(opts.StringerEnums)))
+	}
+	if !opts.EncodedItem.valid() {
+		return nil, errors.New("cbor: invalid EncodedItem " + strconv.Itoa(int(opts.EncodedItem)))
+	}
+	if !opts.SelfDescribedCBOR.valid() {
+		return nil, errors.New("cbor: invalid SelfDescribedCBOR " + strconv.Itoa(int(opts.SelfDescribedCBOR)))
+	}
+	if opts.TagsMd == TagsForbidden && opts.SelfDescribedCBOR != SelfDescribedCBORNone {
+		return nil, errors.New("cbor: cannot set TagsMd to TagsForbidden when SelfDescribedCBOR is not SelfDescribedCBORNone")
+	}
+	if !opts.JSONNumber.valid() {
+		return nil, errors.New("cbor: invalid JSONNumber " + strconv.Itoa(int(opts.JSONNumber)))
+	}
+	if !opts.NumericReduction.valid() {
+		return nil, errors.New("cbor: invalid NumericReduction " + strconv.Itoa(int(opts.NumericReduction)))
+	}
+	if !opts.SimpleValues.valid() {
+		return nil, errors.New("cbor: invalid SimpleValues " + strconv.Itoa(int(opts.SimpleValues)))
+	}
+	if !opts.NegativeInt65.valid() {
+		return nil, errors.New("cbor: invalid NegativeInt65 " + strconv.Itoa(int(opts.NegativeInt65)))
+	}
+	if !opts.NumericDate.valid() {
+		return nil, errors.New("cbor: invalid NumericDate " + strconv.Itoa(int(opts.NumericDate)))
+	}
+	if !opts.DateTag.valid() {
+		return nil, errors.New("cbor: invalid DateTag " + strconv.Itoa(int(opts.DateTag)))
+	}
+	simpleValueAnalogs, err := newSimpleValueAnalogs(opts.SimpleValueAnalogs)
+	if err != nil {
+		return nil, err
+	}
+	emptyPredicates := opts.EmptyPredicates
+	if emptyPredicates != nil {
+		for t, fn := range emptyPredicates.m {
+			if t == nil {
+				return nil, errors.New("cbor: invalid EmptyPredicates: nil type")
+			}
+			if fn == nil {
+				return nil, errors.New("cbor: invalid EmptyPredicates: nil function for " + t.String())
+			}
+		}
+		if len(emptyPredicates.m) == 0 {
+			emptyPredicates = nil
+		}
+	}
+	encodeHook := opts.EncodeHook
+	if encodeHook != nil && encodeHook.fn == nil {
+		encodeHook = nil
+	}
 	em := encMode{
 		sort:                      opts.Sort,
+		sortMap:                   opts.SortMap,
+		sortLess:                  opts.SortLess,
 		shortestFloat:             opts.ShortestFloat,
 		nanConvert:                opts.NaNConvert,
 		infConvert:                opts.InfConvert,
+		float:                     opts.Float,
 		bigIntConvert:             opts.BigIntConvert,
+		bigFloat:                  opts.BigFloat,
+		bigRat:                    opts.BigRat,
 		time:                      opts.Time,
 		timeTag:                   opts.TimeTag,
+		timePrecision:             opts.TimePrecision,
+		timeZone:                  opts.TimeZone,
+		duration:                  opts.Duration,
 		indefLength:               opts.IndefLength,
+		stringChunkSize:           opts.StringChunkSize,
 		nilContainers:             opts.NilContainers,
 		tagsMd:                    opts.TagsMd,
 		omitEmpty:                 opts.OmitEmpty,
+		emptyStruct:               opts.EmptyStruct,
 		stringType:                opts.String,
 		stringMajorType:           stringMajorType,
 		fieldName:                 opts.FieldName,
 		byteSliceLaterFormat:      opts.ByteSliceLaterFormat,
 		byteSliceLaterEncodingTag: byteSliceLaterEncodingTag,
 		byteArray:                 opts.ByteArray,
+		byteSlice:                 opts.ByteSlice,
 		binaryMarshaler:           opts.BinaryMarshaler,
-	}
+		stringerEnums:             opts.StringerEnums,
+		encodedItem:               opts.EncodedItem,
+		selfDescribedCBOR:         opts.SelfDescribedCBOR,
+		jsonNumber:                opts.JSONNumber,
+		numericReduction:          opts.NumericReduction,
+		simpleValues:              opts.SimpleValues,
+		negativeInt65:             opts.NegativeInt65,
+		simpleValueRegistry:       opts.SimpleValueAnalogs,
+		simpleValueAnalogs:        simpleValueAnalogs,
+		emptyPredicates:           emptyPredicates,
+		bufferPool:                opts.BufferPool,
+		numericDate:               opts.NumericDate,
+		dateTag:                   opts.DateTag,
+		encodeHook:                encodeHook,
+	}
+	if em.float == FloatForbidden {
+		// Data returned by Marshaler is checked with a copy of the shared
+		// decoding mode that also rejects floating-point numbers.
+		dm := *getMarshalerDecMode(em.indefLength, em.tagsMd)
+		dm.float = FloatForbidden
+		em.marshalerDecMode = &dm
+	}
+	em.initDerivedModes()
 	return &em, nil
 }
 
+// initDerivedModes creates copies of em used to encode nil slices and maps in struct
+// fields with "nilasempty" and "nilasnull" options, and data items nested in the data
+// item being encoded.  It is called again by setTags, so that the copies have em's tags.
+func (em *encMode) initDerivedModes() {
+	for mode := range em.nilContainerModes {
+		if NilContainersMode(mode) == em.nilContainers {
+			em.nilContainerModes[mode] = em
+			continue
+		}
+		nem := *em
+		nem.nilContainers = NilContainersMode(mode)
+		em.nilContainerModes[mode] = &nem
+	}
+	em.nested = nil
+	if em.selfDescribedCBOR != SelfDescribedCBORNone {
+		nem := *em
+		nem.selfDescribedCBOR = SelfDescribedCBORNone
+		em.nested = &nem
+	}
+}
+
+// setTags sets tags of em and its derived modes.
+func (em *encMode) setTags(tags tagProvider) {
+	em.tags = tags
+	em.initDerivedModes()
+}
+
 // EncMode is the main interface for CBOR encoding.
 type EncMode interface {
 	Marshal(v interface{}) ([]byte, error)
@@ -778,6 +1604,61 @@ type EncMode interface {
 	EncOptions() EncOptions
 }
 
+// ExtendedEncMode is an interface for CBOR encoding, which extends EncMode with
+// methods that aren't part of EncMode to keep it compatible with existing
+// implementations.  EncMode returned by EncOptions methods implements it.
+type ExtendedEncMode interface {
+	EncMode
+
+	// MarshalAppend appends the CBOR encoding of v to dst using the encoding mode,
+	// and returns the extended buffer.
+	//
+	// See the documentation for MarshalAppend for details.
+	MarshalAppend(dst []byte, v interface{}) ([]byte, error)
+
+	// CanMarshal checks whether v can be encoded using the encoding mode, without
+	// returning encoded data.  It returns MarshalPathError for the first value that
+	// can't be encoded.
+	//
+	// See the documentation for CanMarshal for details.
+	CanMarshal(v interface{}) error
+
+	// EncodeWrapped encodes v using the encoding mode, and returns the encoded CBOR
+	// data item wrapped in a definite-length CBOR byte string.
+	//
+	// See the documentation for EncodeWrapped for details.
+	EncodeWrapped(v interface{}) ([]byte, error)
+
+	// MarshalWithMask returns the CBOR encoding of v using the encoding mode, with
+	// only struct fields selected by mask.
+	//
+	// See the documentation for (*encMode).MarshalWithMask for details.
+	MarshalWithMask(v interface{}, mask FieldMask) ([]byte, error)
+
+	// Precompile builds and caches encoding information of types and types reachable
+	// from them, and returns StructPlan of struct types found.
+	//
+	// See the documentation for (*encMode).Precompile for details.
+	Precompile(types ...reflect.Type) ([]StructPlan, error)
+
+	// MemoryUsage returns approximate number of bytes retained by the encoding mode,
+	// including its tags.  Memory used by internal caches of Go type information is
+	// shared by all modes, and is reported by TypeCacheStats.
+	MemoryUsage() int
+
+	// WithOptions returns a new EncMode with the options of this mode modified by fn,
+	// and the same tags as this mode.  This mode isn't modified.
+	//
+	// See the documentation for (*encMode).WithOptions for details.
+	WithOptions(fn func(opts *EncOptions)) (EncMode, error)
+
+	// This private method is to prevent users implementing
+	// this interface and so future additions to it will
+	// not be breaking changes.
+	// See https://go.dev/blog/module-compatibility
+	unexport()
+}
+
 // UserBufferEncMode is an interface for CBOR encoding, which extends EncMode by
 // adding MarshalToBuffer to support user specified buffer rather than encoding
 // into the built-in buffer pool.
@@ -795,23 +1676,71 @@ type UserBufferEncMode interface {
 type encMode struct {
 	tags                      tagProvider
 	sort                      SortMode
+	sortMap                   SortMapMode
+	sortLess                  *LessFunc
 	shortestFloat             ShortestFloatMode
 	nanConvert                NaNConvertMode
 	infConvert                InfConvertMode
+	float                     FloatMode
 	bigIntConvert             BigIntConvertMode
+	bigFloat                  BigFloatMode
+	bigRat                    BigRatMode
 	time                      TimeMode
 	timeTag                   EncTagMode
+	timePrecision             TimePrecisionMode
+	timeZone                  TimeZoneMode
+	duration                  DurationMode
 	indefLength               IndefLengthMode
+	stringChunkSize           int
 	nilContainers             NilContainersMode
 	tagsMd                    TagsMode
 	omitEmpty                 OmitEmptyMode
+	emptyStruct               EmptyStructMode
 	stringType                StringMode
 	stringMajorType           cborType
 	fieldName                 FieldNameMode
 	byteSliceLaterFormat      ByteSliceLaterFormatMode
 	byteSliceLaterEncodingTag uint64
 	byteArray                 ByteArrayMode
+	byteSlice                 ByteSliceMode
 	binaryMarshaler           BinaryMarshalerMode
+	stringerEnums             StringerEnumMode
+	encodedItem               EncodedItemMode
+	selfDescribedCBOR         SelfDescribedCBORMode
+	jsonNumber                JSONNumberMode
+	numericReduction          NumericReductionMode
+	simpleValues              SimpleValuesMode
+	negativeInt65             NegativeInt65Mode
+	emptyPredicates           *EmptyPredicates
+	bufferPool                BufferPool
+	numericDate               NumericDateMode
+	dateTag                   DateTagMode
+	encodeHook                *EncodeHook
+	simpleValueRegistry       *SimpleValueRegistry
+
+	// simpleValueAnalogs are analogs in simpleValueRegistry by Go type, or nil if there
+	// isn't any.
+	simpleValueAnalogs map[reflect.Type][]simpleValueAnalog
+
+	// fieldMask selects struct fields to encode in MarshalWithMask, or nil to encode all fields.
+	fieldMask FieldMask
+
+	// nilContainerModes are em and its copies with each NilContainersMode, used to encode
+	// nil slices and maps in struct fields with "nilasempty" and "nilasnull" options.
+	nilContainerModes [maxNilContainersMode]*encMode
+
+	// nested is a copy of em without self-described CBOR tag, passed to MarshalerWithMode
+	// so that nested data items aren't prefixed with tag number 55799.  It is nil if
+	// em doesn't prefix data items with the tag.
+	nested *encMode
+
+	// marshalerDecMode checks data returned by Marshaler if it isn't nil, instead of
+	// decoding mode returned by getMarshalerDecMode.
+	marshalerDecMode *decMode
+
+	// hookPath is the path of the value being encoded, passed to encodeHook.  It is only
+	// set in the copy of encMode used by one call to encodeValue if encodeHook isn't nil.
+	hookPath *[]byte
 }
 
 var defaultEncMode, _ = EncOptions{}.encMode()
@@ -889,22 +1818,70 @@ func getMarshalerDecMode(indefLength IndefLengthMode, tagsMd TagsMode) *decMode
 func (em *encMode) EncOptions() EncOptions {
 	return EncOptions{
 		Sort:                 em.sort,
+		SortMap:              em.sortMap,
+		SortLess:             em.sortLess,
 		ShortestFloat:        em.shortestFloat,
 		NaNConvert:           em.nanConvert,
 		InfConvert:           em.infConvert,
+		Float:                em.float,
 		BigIntConvert:        em.bigIntConvert,
+		BigFloat:             em.bigFloat,
+		BigRat:               em.bigRat,
 		Time:                 em.time,
 		TimeTag:              em.timeTag,
+		TimePrecision:        em.timePrecision,
+		TimeZone:             em.timeZone,
+		Duration:             em.duration,
 		IndefLength:          em.indefLength,
+		StringChunkSize:      em.stringChunkSize,
 		NilContainers:        em.nilContainers,
 		TagsMd:               em.tagsMd,
 		OmitEmpty:            em.omitEmpty,
+		EmptyStruct:          em.emptyStruct,
 		String:               em.stringType,
 		FieldName:            em.fieldName,
 		ByteSliceLaterFormat: em.byteSliceLaterFormat,
 		ByteArray:            em.byteArray,
+		ByteSlice:            em.byteSlice,
 		BinaryMarshaler:      em.binaryMarshaler,
+		StringerEnums:        em.stringerEnums,
+		EncodedItem:          em.encodedItem,
+		SelfDescribedCBOR:    em.selfDescribedCBOR,
+		JSONNumber:           em.jsonNumber,
+		SimpleValueAnalogs:   em.simpleValueRegistry,
+		NumericReduction:     em.numericReduction,
+		SimpleValues:         em.simpleValues,
+		NegativeInt65:        em.negativeInt65,
+		EmptyPredicates:      em.emptyPredicates,
+		BufferPool:           em.bufferPool,
+		NumericDate:          em.numericDate,
+		DateTag:              em.dateTag,
+		EncodeHook:           em.encodeHook,
+	}
+}
+
+// WithOptions returns a new EncMode with the options of em modified by fn, and the
+// same tags as em: tags copied by EncModeWithTags are shared since they are immutable,
+// and shared tags registered with EncModeWithSharedTags remain shared.  fn receives a
+// copy of em's options, so em isn't modified and can be used concurrently.
+//
+// Derived modes are cheap to create for per-request or per-tenant option tweaks.
+// Go type information (e.g. struct fields) is cached globally and shared by all modes,
+// so it isn't recomputed for derived modes.
+func (em *encMode) WithOptions(fn func(opts *EncOptions)) (EncMode, error) {
+	opts := em.EncOptions()
+	fn(&opts)
+	if em.tags != nil && opts.TagsMd == TagsForbidden {
+		return nil, errors.New("cbor: cannot create EncMode with TagSet when TagsMd is TagsForbidden")
 	}
+	newEM, err := opts.encMode()
+	if err != nil {
+		return nil, err
+	}
+	if em.tags != nil {
+		newEM.setTags(em.tags)
+	}
+	return newEM, nil
 }
 
 func (em *encMode) unexport() {}
@@ -918,21 +1895,48 @@ func (em *encMode) encTagBytes(t reflect.Type) []byte {
 	return nil
 }
 
+// nestedEncMode returns encoding mode for data items nested in the data item being
+// encoded with em, which aren't prefixed with self-described CBOR tag.
+func (em *encMode) nestedEncMode() *encMode {
+	if em.nested != nil {
+		return em.nested
+	}
+	return em
+}
+
+// marshalNested returns the CBOR encoding of v nested in another data item, without
+// self-described CBOR tag.
+func marshalNested(em EncMode, v interface{}) ([]byte, error) {
+	if iem, ok := em.(*encMode); ok {
+		return iem.marshal(v, false)
+	}
+	return em.Marshal(v)
+}
+
 // Marshal returns the CBOR encoding of v using em encoding mode.
 //
 // See the documentation for Marshal for details.
 func (em *encMode) Marshal(v interface{}) ([]byte, error) {
-	e := getEncodeBuffer()
+	return em.marshal(v, em.selfDescribedCBOR != SelfDescribedCBORNone)
+}
 
-	if err := encode(e, em, reflect.ValueOf(v)); err != nil {
-		putEncodeBuffer(e)
+// marshal returns the CBOR encoding of v using em encoding mode, prefixed with
+// self-described CBOR tag if selfDescribed is true.
+func (em *encMode) marshal(v interface{}, selfDescribed bool) ([]byte, error) {
+	e := em.getBuffer()
+
+	if selfDescribed {
+		encodeHead(e, byte(cborTypeTag), tagNumSelfDescribedCBOR)
+	}
+	if err := encodeValue(e, em, v); err != nil {
+		em.putBuffer(e)
 		return nil, err
 	}
 
 	buf := make([]byte, e.Len())
 	copy(buf, e.Bytes())
 
-	putEncodeBuffer(e)
+	em.putBuffer(e)
 	return buf, nil
 }
 
@@ -947,7 +1951,164 @@ func (em *encMode) MarshalToBuffer(v interface{}, buf *bytes.Buffer) error {
 	if buf == nil {
 		return fmt.Errorf("cbor: encoding buffer provided by user is nil")
 	}
-	return encode(buf, em, reflect.ValueOf(v))
+	if em.selfDescribedCBOR != SelfDescribedCBORNone {
+		encodeHead(buf, byte(cborTypeTag), tagNumSelfDescribedCBOR)
+	}
+	return encodeValue(buf, em, v)
+}
+
+// MarshalAppend appends the CBOR encoding of v to dst and returns the extended buffer,
+// using em encoding mode.
+//
+// See the documentation for MarshalAppend for details.
+func (em *encMode) MarshalAppend(dst []byte, v interface{}) ([]byte, error) {
+	e := em.getBuffer()
+	defer em.putBuffer(e)
+
+	if em.selfDescribedCBOR != SelfDescribedCBORNone {
+		encodeHead(e, byte(cborTypeTag), tagNumSelfDescribedCBOR)
+	}
+	if err := encodeValue(e, em, v); err != nil {
+		return dst, err
+	}
+	return append(dst, e.Bytes()...), nil
+}
+
+// CanMarshal checks whether v can be encoded using em encoding mode, without
+// returning encoded data.
+//
+// See the documentation for CanMarshal for details.
+func (em *encMode) CanMarshal(v interface{}) error {
+	e := getEncodeBuffer()
+	defer putEncodeBuffer(e)
+
+	err := canEncode(e, em, reflect.ValueOf(v))
+	if err != nil {
+		if _, ok := err.(*MarshalPathError); !ok {
+			err = &MarshalPathError{Err: err}
+		}
+	}
+	return err
+}
+
+// canEncode returns error if v can't be encoded by em.  e is used as scratch buffer.
+func canEncode(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	if !v.IsValid() {
+		return nil
+	}
+
+	t := v.Type()
+	if !isWalkableType(em, t) {
+		if f, _ := getEncodeFunc(t); f == nil {
+			return &UnsupportedTypeError{t}
+		}
+		e.Reset()
+		return encode(e, em, v)
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			if err := canEncode(e, em, v.Elem()); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := canEncode(e, em, v.Index(i)); err != nil {
+				return withMarshalPath(err, "["+strconv.Itoa(i)+"]")
+			}
+		}
+
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			err := canEncode(e, em, iter.Key())
+			if err == nil {
+				err = canEncode(e, em, iter.Value())
+			}
+			if err != nil {
+				return withMarshalPath(err, fmt.Sprintf("[%#v]", iter.Key().Interface()))
+			}
+		}
+
+	case reflect.Struct:
+		if err := canEncodeStruct(e, em, v); err != nil {
+			return err
+		}
+	}
+
+	// Type can be unsupported even if there is no element to check (e.g. empty []chan int).
+	if f, _ := getEncodeFunc(t); f == nil {
+		return &UnsupportedTypeError{t}
+	}
+	return nil
+}
+
+func canEncodeStruct(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	t := v.Type()
+	structType, err := getEncodingStructType(t)
+
+	flds := structType.fields
+	if err != nil {
+		// Find the field causing the error.
+		flds, _ = getFields(t)
+	}
+	for _, f := range flds {
+		fv, _ := getFieldValue(v, f.idx, func(reflect.Value) (reflect.Value, error) {
+			// Skip null pointer to embedded struct
+			return reflect.Value{}, nil
+		})
+		var fErr error
+		if f.raw && err == nil && fv.IsValid() {
+			e.Reset()
+			fErr = encodeRawField(e, em, fv)
+		} else {
+			fErr = canEncode(e, em, fv)
+		}
+		if fErr != nil {
+			return withMarshalPath(fErr, "."+t.FieldByIndex(f.idx).Name)
+		}
+	}
+	return err
+}
+
+// isWalkableType returns true if values of t are encoded by encoding their elements
+// or fields, rather than by built-in support for t or its Marshaler implementation.
+func isWalkableType(em *encMode, t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return true
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return false
+		}
+	case reflect.Array, reflect.Map, reflect.Struct:
+	default:
+		return false
+	}
+	switch t {
+	case typeTag, typeTime, typeBigInt, typeBigFloat, typeBigRat, typeRawMessage, typeByteString:
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	if pt.Implements(typeMarshalerTo) || pt.Implements(typeMarshalerWithMode) || pt.Implements(typeMarshaler) {
+		return false
+	}
+	if pt.Implements(typeBinaryMarshaler) && em.binaryMarshaler == BinaryMarshalerByteString {
+		return false
+	}
+	return true
+}
+
+// withMarshalPath prepends path segment to the path of err.
+func withMarshalPath(err error, segment string) error {
+	if pe, ok := err.(*MarshalPathError); ok {
+		pe.Path = segment + pe.Path
+		return pe
+	}
+	return &MarshalPathError{Path: segment, Err: err}
 }
 
 // NewEncoder returns a new encoder that writes to w using em EncMode.
@@ -982,13 +2143,79 @@ func encode(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 		e.Write(cborNil)
 		return nil
 	}
-	vt := v.Type()
-	f, _ := getEncodeFunc(vt)
-	if f == nil {
-		return &UnsupportedTypeError{vt}
+	vt := v.Type()
+	f, _ := getEncodeFunc(vt)
+	if f == nil {
+		return &UnsupportedTypeError{vt}
+	}
+
+	return f(e, em, v)
+}
+
+// encodeValue encodes v passed to encoding functions, such as Marshal.  If EncodeHook
+// is set, it is applied to v and values in v using a copy of em with new path state.
+func encodeValue(e *bytes.Buffer, em *encMode, v interface{}) error {
+	if em.encodeHook == nil {
+		return encode(e, em, reflect.ValueOf(v))
+	}
+	hem := *em // shallow copy
+	hem.hookPath = new([]byte)
+	return encodeHooked(e, &hem, nil, reflect.ValueOf(v), "")
+}
+
+// encodeHooked encodes v at path of the current value appended with segment, using f
+// (or encode if f is nil), unless em.encodeHook returns a replacement for v.
+func encodeHooked(e *bytes.Buffer, em *encMode, f encodeFunc, v reflect.Value, segment string) error {
+	path := em.hookPath
+	n := len(*path)
+	*path = append(*path, segment...)
+	defer func() { *path = (*path)[:n] }()
+
+	hv := v
+	for hv.Kind() == reflect.Interface && !hv.IsNil() {
+		hv = hv.Elem()
+	}
+	if hv.IsValid() {
+		if r, ok := em.encodeHook.fn(string(*path), hv); ok {
+			return encode(e, em, reflect.ValueOf(r))
+		}
+	}
+	if f == nil {
+		return encode(e, em, v)
+	}
+	return f(e, em, v)
+}
+
+// encodeElement encodes element v at index i of array or slice with f.
+func encodeElement(e *bytes.Buffer, em *encMode, f encodeFunc, v reflect.Value, i int) error {
+	if em.hookPath == nil {
+		return f(e, em, v)
+	}
+	return encodeHooked(e, em, f, v, "["+strconv.Itoa(i)+"]")
+}
+
+// encodeMapValue encodes value v of map key k with f.
+func encodeMapValue(e *bytes.Buffer, em *encMode, f encodeFunc, k, v reflect.Value) error {
+	if em.hookPath == nil {
+		return f(e, em, v)
+	}
+	return encodeHooked(e, em, f, v, mapKeyPathSegment(k))
+}
+
+// encodeField encodes value fv of field f in struct type t.
+func encodeField(e *bytes.Buffer, em *encMode, t reflect.Type, f *field, fv reflect.Value) error {
+	if em.hookPath == nil {
+		return f.ef(e, em, fv)
 	}
+	return encodeHooked(e, em, f.ef, fv, "."+t.FieldByIndex(f.idx).Name)
+}
 
-	return f(e, em, v)
+// mapKeyPathSegment returns path segment of map key k in Go syntax, e.g. ["a"].
+func mapKeyPathSegment(k reflect.Value) string {
+	if !k.CanInterface() {
+		return "[" + k.String() + "]"
+	}
+	return fmt.Sprintf("[%#v]", k.Interface())
 }
 
 func encodeBool(e *bytes.Buffer, em *encMode, v reflect.Value) error {
@@ -1030,14 +2257,34 @@ func encodeFloat(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 		e.Write(b)
 	}
 	f64 := v.Float()
+	if em.float == FloatForbidden && (math.IsNaN(f64) || math.IsInf(f64, 0)) {
+		return &UnsupportedValueError{msg: "floating-point number"}
+	}
 	if math.IsNaN(f64) {
 		return encodeNaN(e, em, v)
 	}
 	if math.IsInf(f64, 0) {
 		return encodeInf(e, em, v)
 	}
+	return encodeFiniteFloat(e, em, f64, v.Kind() == reflect.Float64)
+}
+
+// encodeFiniteFloat encodes f64 which isn't NaN or Inf.  isFloat64 is false if f64
+// is converted from float32.
+func encodeFiniteFloat(e *bytes.Buffer, em *encMode, f64 float64, isFloat64 bool) error {
+	if em.numericReduction == NumericReductionFloatToInt && f64 == math.Trunc(f64) && f64 >= -(1<<63) && f64 < 1<<64 {
+		if f64 >= 0 {
+			encodeHead(e, byte(cborTypePositiveInt), uint64(f64))
+		} else {
+			encodeHead(e, byte(cborTypeNegativeInt), uint64(-1-int64(f64)))
+		}
+		return nil
+	}
+	if em.float == FloatForbidden {
+		return &UnsupportedValueError{msg: "floating-point number"}
+	}
 	fopt := em.shortestFloat
-	if v.Kind() == reflect.Float64 && (fopt == ShortestFloatNone || cannotFitFloat32(f64)) {
+	if isFloat64 && (fopt == ShortestFloatNone || cannotFitFloat32(f64)) {
 		// Encode float64
 		// Don't use encodeFloat64() because it cannot be inlined.
 		const argumentSize = 8
@@ -1204,6 +2451,9 @@ func encodeByteString(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 		e.Write(cborNil)
 		return nil
 	}
+	if vk == reflect.Slice && em.byteSlice == ByteSliceToArray {
+		return encodeBytesToArray(e, em, v)
+	}
 	if vk == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 && em.byteSliceLaterEncodingTag != 0 {
 		encodeHead(e, byte(cborTypeTag), em.byteSliceLaterEncodingTag)
 	}
@@ -1214,14 +2464,95 @@ func encodeByteString(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 	if slen == 0 {
 		return e.WriteByte(byte(cborTypeByteString))
 	}
-	encodeHead(e, byte(cborTypeByteString), uint64(slen))
 	if vk == reflect.Array {
+		if em.stringChunkSize > 0 && slen > em.stringChunkSize {
+			b := make([]byte, slen)
+			reflect.Copy(reflect.ValueOf(b), v)
+			encodeBytes(e, em, b)
+			return nil
+		}
+		encodeHead(e, byte(cborTypeByteString), uint64(slen))
 		for i := 0; i < slen; i++ {
 			e.WriteByte(byte(v.Index(i).Uint()))
 		}
 		return nil
 	}
-	e.Write(v.Bytes())
+	encodeBytes(e, em, v.Bytes())
+	return nil
+}
+
+// encodeBytes encodes b as CBOR byte string.  If b is longer than StringChunkSize,
+// b is encoded as indefinite-length byte string with chunks of StringChunkSize bytes.
+func encodeBytes(e *bytes.Buffer, em *encMode, b []byte) {
+	if em.stringChunkSize == 0 || len(b) <= em.stringChunkSize {
+		encodeHead(e, byte(cborTypeByteString), uint64(len(b)))
+		e.Write(b)
+		return
+	}
+	e.WriteByte(cborByteStringWithIndefiniteLengthHead)
+	for len(b) > 0 {
+		n := em.stringChunkSize
+		if n > len(b) {
+			n = len(b)
+		}
+		encodeHead(e, byte(cborTypeByteString), uint64(n))
+		e.Write(b[:n])
+		b = b[n:]
+	}
+	e.WriteByte(cborBreakFlag)
+}
+
+// encodeStringContent encodes s as CBOR text string or byte string, as specified by
+// String option.  If s is longer than StringChunkSize, s is encoded as indefinite-length
+// string with chunks of at most StringChunkSize bytes.  Text string chunks end at UTF-8
+// character boundaries, unless StringChunkSize is smaller than the character.
+func encodeStringContent(e *bytes.Buffer, em *encMode, s string) {
+	t := em.stringMajorType
+	if em.stringChunkSize == 0 || len(s) <= em.stringChunkSize {
+		encodeHead(e, byte(t), uint64(len(s)))
+		e.WriteString(s)
+		return
+	}
+	e.WriteByte(byte(t) | byte(additionalInformationAsIndefiniteLengthFlag))
+	for len(s) > 0 {
+		n := em.stringChunkSize
+		if n >= len(s) {
+			n = len(s)
+		} else if t == cborTypeTextString {
+			// Move chunk end back to the start of a UTF-8 encoded character, or
+			// forward if the chunk can't hold the first character.
+			i := n
+			for i > 0 && !utf8.RuneStart(s[i]) {
+				i--
+			}
+			if i == 0 {
+				for i = n; i < len(s) && !utf8.RuneStart(s[i]); i++ {
+				}
+			}
+			n = i
+		}
+		encodeHead(e, byte(t), uint64(n))
+		e.WriteString(s[:n])
+		s = s[n:]
+	}
+	e.WriteByte(cborBreakFlag)
+}
+
+// encodeBytesToArray encodes byte slice or byte array v to CBOR array with one unsigned
+// integer item for each byte.
+func encodeBytesToArray(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	if v.Kind() == reflect.Slice && v.IsNil() && em.nilContainers == NilContainerAsNull {
+		e.Write(cborNil)
+		return nil
+	}
+	if b := em.encTagBytes(v.Type()); b != nil {
+		e.Write(b)
+	}
+	n := v.Len()
+	encodeHead(e, byte(cborTypeArray), uint64(n))
+	for i := 0; i < n; i++ {
+		encodeHead(e, byte(cborTypePositiveInt), v.Index(i).Uint())
+	}
 	return nil
 }
 
@@ -1229,9 +2560,7 @@ func encodeString(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 	if b := em.encTagBytes(v.Type()); b != nil {
 		e.Write(b)
 	}
-	s := v.String()
-	encodeHead(e, byte(em.stringMajorType), uint64(len(s)))
-	e.WriteString(s)
+	encodeStringContent(e, em, v.String())
 	return nil
 }
 
@@ -1256,7 +2585,7 @@ func (ae arrayEncodeFunc) encode(e *bytes.Buffer, em *encMode, v reflect.Value)
 	}
 	encodeHead(e, byte(cborTypeArray), uint64(alen))
 	for i := 0; i < alen; i++ {
-		if err := ae.f(e, em, v.Index(i)); err != nil {
+		if err := encodeElement(e, em, ae.f, v.Index(i), i); err != nil {
 			return err
 		}
 	}
@@ -1269,7 +2598,8 @@ func (ae arrayEncodeFunc) encode(e *bytes.Buffer, em *encMode, v reflect.Value)
 type encodeKeyValueFunc func(e *bytes.Buffer, em *encMode, v reflect.Value, kvs []keyValue) error
 
 type mapEncodeFunc struct {
-	e encodeKeyValueFunc
+	e      encodeKeyValueFunc
+	kf, ef encodeFunc
 }
 
 func (me mapEncodeFunc) encode(e *bytes.Buffer, em *encMode, v reflect.Value) error {
@@ -1290,6 +2620,13 @@ func (me mapEncodeFunc) encode(e *bytes.Buffer, em *encMode, v reflect.Value) er
 		return me.e(e, em, v, nil)
 	}
 
+	if em.sortMap == SortMapEncodedKeys {
+		return me.encodeSortedByKeys(e, em, v)
+	}
+	return me.encodeSortedPairs(e, em, v)
+}
+
+func (me mapEncodeFunc) encodeSortedPairs(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 	kvsp := getKeyValues(v.Len()) // for sorting keys
 	defer putKeyValues(kvsp)
 	kvs := *kvsp
@@ -1298,6 +2635,54 @@ func (me mapEncodeFunc) encode(e *bytes.Buffer, em *encMode, v reflect.Value) er
 	if err := me.e(e, em, v, kvs); err != nil {
 		return err
 	}
+	sortKeyValues(e, em, kvBeginOffset, kvs)
+	return nil
+}
+
+// encodeSortedByKeys encodes map keys to a separate buffer and sorts them, and then
+// encodes key/value pairs in sorted order.  Unlike encodeSortedPairs, encoded values
+// aren't rearranged.
+func (me mapEncodeFunc) encodeSortedByKeys(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	keys := v.MapKeys()
+
+	kb := getEncodeBuffer()
+	defer putEncodeBuffer(kb)
+
+	kvsp := getKeyValues(len(keys)) // for sorting keys
+	defer putKeyValues(kvsp)
+	kvs := *kvsp
+
+	for i, key := range keys {
+		offset := kb.Len()
+		if err := me.kf(kb, em, key); err != nil {
+			return err
+		}
+		kvs[i] = keyValue{offset: offset, valueOffset: kb.Len()}
+	}
+
+	sorter := &encodedKeySorter{keys: keys, kvs: kvs, data: kb.Bytes(), lengthFirst: em.sort == SortLengthFirst, less: em.sortLess.get()}
+	sort.Sort(sorter)
+
+	kvBeginOffset := e.Len()
+	for i, kv := range kvs {
+		value := v.MapIndex(keys[i])
+		if !value.IsValid() {
+			// Values can't be looked up by keys such as NaN, so sort encoded pairs instead.
+			e.Truncate(kvBeginOffset)
+			return me.encodeSortedPairs(e, em, v)
+		}
+		e.Write(sorter.data[kv.offset:kv.valueOffset])
+		if err := encodeMapValue(e, em, me.ef, keys[i], value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortKeyValues sorts encoded key/value pairs at the end of e starting at kvBeginOffset,
+// in the order specified by em.sort.  kvs is positions of encoded pairs relative to
+// kvBeginOffset.
+func sortKeyValues(e *bytes.Buffer, em *encMode, kvBeginOffset int, kvs []keyValue) {
 	kvTotalLen := e.Len() - kvBeginOffset
 
 	// Use the capacity at the tail of the encode buffer as a staging area to rearrange the
@@ -1306,11 +2691,7 @@ func (me mapEncodeFunc) encode(e *bytes.Buffer, em *encMode, v reflect.Value) er
 	tmp := e.Bytes()[e.Len() : e.Len()+kvTotalLen] // Can use e.AvailableBuffer() in Go 1.21+.
 	dst := e.Bytes()[kvBeginOffset:]
 
-	if em.sort == SortBytewiseLexical {
-		sort.Sort(&bytewiseKeyValueSorter{kvs: kvs, data: dst})
-	} else {
-		sort.Sort(&lengthFirstKeyValueSorter{kvs: kvs, data: dst})
-	}
+	sortKeyValuesByKeys(em, kvs, dst)
 
 	// This is where the encoded bytes are actually rearranged in the output buffer to reflect
 	// the desired order.
@@ -1320,9 +2701,18 @@ func (me mapEncodeFunc) encode(e *bytes.Buffer, em *encMode, v reflect.Value) er
 		sortedOffset += kv.nextOffset - kv.offset
 	}
 	copy(dst, tmp[:kvTotalLen])
+}
 
-	return nil
-
+// sortKeyValuesByKeys sorts kvs by encoded keys in data in the order specified by em.sort.
+func sortKeyValuesByKeys(em *encMode, kvs []keyValue, data []byte) {
+	switch em.sort {
+	case SortBytewiseLexical:
+		sort.Sort(&bytewiseKeyValueSorter{kvs: kvs, data: data})
+	case SortCustom:
+		sort.Sort(&customKeyValueSorter{kvs: kvs, data: data, less: em.sortLess.get()})
+	default:
+		sort.Sort(&lengthFirstKeyValueSorter{kvs: kvs, data: data})
+	}
 }
 
 // keyValue is the position of an encoded pair in a buffer. All offsets are zero-based and relative
@@ -1372,6 +2762,57 @@ func (x *lengthFirstKeyValueSorter) Less(i, j int) bool {
 	return bytes.Compare(x.data[kvi.offset:kvi.valueOffset], x.data[kvj.offset:kvj.valueOffset]) <= 0
 }
 
+type customKeyValueSorter struct {
+	kvs  []keyValue
+	data []byte
+	less func(a, b []byte) bool
+}
+
+func (x *customKeyValueSorter) Len() int {
+	return len(x.kvs)
+}
+
+func (x *customKeyValueSorter) Swap(i, j int) {
+	x.kvs[i], x.kvs[j] = x.kvs[j], x.kvs[i]
+}
+
+func (x *customKeyValueSorter) Less(i, j int) bool {
+	kvi, kvj := x.kvs[i], x.kvs[j]
+	return x.less(x.data[kvi.offset:kvi.valueOffset], x.data[kvj.offset:kvj.valueOffset])
+}
+
+// encodedKeySorter sorts map keys by their encodings.  kvs is positions of encoded
+// keys in data, and only offset and valueOffset are used.
+type encodedKeySorter struct {
+	keys        []reflect.Value
+	kvs         []keyValue
+	data        []byte
+	lengthFirst bool
+	less        func(a, b []byte) bool // used instead of lengthFirst if not nil
+}
+
+func (x *encodedKeySorter) Len() int {
+	return len(x.kvs)
+}
+
+func (x *encodedKeySorter) Swap(i, j int) {
+	x.kvs[i], x.kvs[j] = x.kvs[j], x.kvs[i]
+	x.keys[i], x.keys[j] = x.keys[j], x.keys[i]
+}
+
+func (x *encodedKeySorter) Less(i, j int) bool {
+	kvi, kvj := x.kvs[i], x.kvs[j]
+	if x.less != nil {
+		return x.less(x.data[kvi.offset:kvi.valueOffset], x.data[kvj.offset:kvj.valueOffset])
+	}
+	if x.lengthFirst {
+		if keyLengthDifference := (kvi.valueOffset - kvi.offset) - (kvj.valueOffset - kvj.offset); keyLengthDifference != 0 {
+			return keyLengthDifference < 0
+		}
+	}
+	return bytes.Compare(x.data[kvi.offset:kvi.valueOffset], x.data[kvj.offset:kvj.valueOffset]) <= 0
+}
+
 var keyValuePool = sync.Pool{}
 
 func getKeyValues(length int) *[]keyValue {
@@ -1397,6 +2838,64 @@ func putKeyValues(x *[]keyValue) {
 	keyValuePool.Put(x)
 }
 
+// unknownFieldValue returns map in struct field f with "unknown" or "inline" option, or invalid value
+// if f is in null pointer to embedded struct.
+func unknownFieldValue(v reflect.Value, f *field) reflect.Value {
+	if len(f.idx) == 1 {
+		return v.Field(f.idx[0])
+	}
+	// Get embedded field value.  No error is expected.
+	fv, _ := getFieldValue(v, f.idx, func(reflect.Value) (reflect.Value, error) {
+		// Skip null pointer to embedded struct
+		return reflect.Value{}, nil
+	})
+	return fv
+}
+
+// encodeUnknownField encodes entries of map m in struct field f with "unknown" or "inline" option,
+// after encoded pairs of other fields at kvs (relative to kvBeginOffset).  It returns
+// an error if a map key is the same as an encoded field name.  Encoded pairs are sorted
+// if required by em.sort.
+func encodeUnknownField(e *bytes.Buffer, em *encMode, t reflect.Type, f *field, m reflect.Value, kvBeginOffset int, kvs []keyValue) error {
+	fieldCount := len(kvs)
+	keyAsByteString := em.fieldName == FieldNameToByteString && m.Type().Key().Kind() == reflect.String
+	iter := m.MapRange()
+	for iter.Next() {
+		keyBegin := e.Len()
+		if keyAsByteString {
+			key := iter.Key().String()
+			encodeHead(e, byte(cborTypeByteString), uint64(len(key)))
+			e.WriteString(key)
+		} else if err := encode(e, em, iter.Key()); err != nil {
+			return err
+		}
+		valueBegin := e.Len()
+
+		key := e.Bytes()[keyBegin:valueBegin]
+		for _, kv := range kvs[:fieldCount] {
+			if bytes.Equal(e.Bytes()[kvBeginOffset+kv.offset:kvBeginOffset+kv.valueOffset], key) {
+				return fmt.Errorf("cbor: cannot encode field %s.%s with \"%s\" option: map key %v is the same as another field name", t.String(), f.name, f.unknownOption(), iter.Key())
+			}
+		}
+
+		var err error
+		if em.hookPath == nil {
+			err = encode(e, em, iter.Value())
+		} else {
+			err = encodeHooked(e, em, nil, iter.Value(), "."+t.Field(f.idx[len(f.idx)-1]).Name+mapKeyPathSegment(iter.Key()))
+		}
+		if err != nil {
+			return err
+		}
+		kvs = append(kvs, keyValue{offset: keyBegin - kvBeginOffset, valueOffset: valueBegin - kvBeginOffset, nextOffset: e.Len() - kvBeginOffset})
+	}
+
+	if em.sort != SortNone && em.sort != SortFastShuffle {
+		sortKeyValues(e, em, kvBeginOffset, kvs)
+	}
+	return nil
+}
+
 func encodeStructToArray(e *bytes.Buffer, em *encMode, v reflect.Value) (err error) {
 	structType, err := getEncodingStructType(v.Type())
 	if err != nil {
@@ -1409,6 +2908,11 @@ func encodeStructToArray(e *bytes.Buffer, em *encMode, v reflect.Value) (err err
 
 	flds := structType.fields
 
+	if em.fieldMask != nil {
+		// Field mask doesn't apply to struct encoded as array or to its fields.
+		em = em.withFieldMask(nil)
+	}
+
 	encodeHead(e, byte(cborTypeArray), uint64(len(flds)))
 	for i := 0; i < len(flds); i++ {
 		f := flds[i]
@@ -1428,7 +2932,7 @@ func encodeStructToArray(e *bytes.Buffer, em *encMode, v reflect.Value) (err err
 			}
 		}
 
-		if err := f.ef(e, em, fv); err != nil {
+		if err := encodeField(e, em, v.Type(), f, fv); err != nil {
 			return err
 		}
 	}
@@ -1448,19 +2952,44 @@ func encodeStruct(e *bytes.Buffer, em *encMode, v reflect.Value) (err error) {
 		start = rand.Intn(len(flds)) //nolint:gosec // Don't need a CSPRNG for deck cutting.
 	}
 
+	begin := e.Len()
+
 	if b := em.encTagBytes(v.Type()); b != nil {
 		e.Write(b)
 	}
 
+	// Map in field with "unknown" option is encoded after other fields.
+	var unknown reflect.Value
+	if structType.unknownField != nil && em.fieldMask == nil {
+		unknown = unknownFieldValue(v, structType.unknownField)
+	}
+	unknownLen := 0
+	if unknown.IsValid() {
+		unknownLen = unknown.Len()
+	}
+
+	// Positions of encoded pairs, used to detect duplicate keys and to sort pairs of
+	// fields and unknown map entries.
+	var kvs []keyValue
+
 	// Encode head with struct field count.
 	// Head is rewritten later if actual encoded field count is different from struct field count.
-	encodedHeadLen := encodeHead(e, byte(cborTypeMap), uint64(len(flds)))
+	headCount := len(flds) + unknownLen
+	encodedHeadLen := encodeHead(e, byte(cborTypeMap), uint64(headCount))
 
 	kvbegin := e.Len()
 	kvcount := 0
 	for offset := 0; offset < len(flds); offset++ {
 		f := flds[(start+offset)%len(flds)]
 
+		fem := em
+		if em.fieldMask != nil {
+			var selected bool
+			if fem, selected = em.fieldEncMode(f); !selected {
+				continue
+			}
+		}
+
 		var fv reflect.Value
 		if len(f.idx) == 1 {
 			fv = v.Field(f.idx[0])
@@ -1474,30 +3003,58 @@ func encodeStruct(e *bytes.Buffer, em *encMode, v reflect.Value) (err error) {
 				continue
 			}
 		}
-		if f.omitEmpty {
-			empty, err := f.ief(em, fv)
+		if f.omitEmpty || f.omitZero {
+			omitted, err := isOmittedField(fem, f, fv)
 			if err != nil {
 				return err
 			}
-			if empty {
+			if omitted {
 				continue
 			}
 		}
 
+		keyBegin := e.Len()
+
 		if !f.keyAsInt && em.fieldName == FieldNameToByteString {
 			e.Write(f.cborNameByteString)
 		} else { // int or text string
 			e.Write(f.cborName)
 		}
 
-		if err := f.ef(e, em, fv); err != nil {
+		valueBegin := e.Len()
+
+		if err := encodeField(e, fem, v.Type(), f, fv); err != nil {
 			return err
 		}
 
+		if em.emptyStruct == EmptyStructOmitted && isEncodedEmptyStruct(f.typ, e.Bytes()[valueBegin:]) {
+			e.Truncate(keyBegin)
+			continue
+		}
+
+		if unknownLen > 0 || em.sort == SortCustom {
+			kvs = append(kvs, keyValue{offset: keyBegin - kvbegin, valueOffset: valueBegin - kvbegin, nextOffset: e.Len() - kvbegin})
+		}
 		kvcount++
 	}
 
-	if len(flds) == kvcount {
+	if unknownLen > 0 {
+		if err := encodeUnknownField(e, em, v.Type(), structType.unknownField, unknown, kvbegin, kvs); err != nil {
+			return err
+		}
+		kvcount += unknownLen
+	} else if em.sort == SortCustom && len(kvs) > 1 {
+		// Fields can't be presorted by custom order, so encoded pairs are sorted.
+		sortKeyValues(e, em, kvbegin, kvs)
+	}
+
+	if kvcount == 0 && em.emptyStruct == EmptyStructAsNull {
+		e.Truncate(begin)
+		e.Write(cborNil)
+		return nil
+	}
+
+	if headCount == kvcount {
 		// Encoded element count in head is the same as actual element count.
 		return nil
 	}
@@ -1529,6 +3086,15 @@ func encodeStruct(e *bytes.Buffer, em *encMode, v reflect.Value) (err error) {
 	return nil
 }
 
+// isEncodedEmptyStruct returns true if t is a struct type (or pointer to struct type)
+// and b is its encoded value without tag and fields.
+func isEncodedEmptyStruct(t reflect.Type, b []byte) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && len(b) == 1 && b[0] == 0xa0
+}
+
 func encodeIntf(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 	if v.IsNil() {
 		e.Write(cborNil)
@@ -1543,6 +3109,10 @@ func encodeTime(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 		e.Write(cborNil) // Even if tag is required, encode as CBOR null.
 		return nil
 	}
+	if em.time == TimeExtended {
+		encodeHead(e, byte(cborTypeTag), tagNumExtendedTime)
+		return encodeExtendedTime(e, em, t.Unix(), t.Nanosecond())
+	}
 	if em.timeTag == EncTagRequired {
 		tagNumber := 1
 		if em.time == TimeRFC3339 || em.time == TimeRFC3339Nano {
@@ -1556,12 +3126,12 @@ func encodeTime(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 		return encodeInt(e, em, reflect.ValueOf(secs))
 
 	case TimeUnixMicro:
-		t = t.UTC().Round(time.Microsecond)
+		t = t.UTC().Round(em.timePrecision.duration())
 		f := float64(t.UnixNano()) / 1e9
 		return encodeFloat(e, em, reflect.ValueOf(f))
 
 	case TimeUnixDynamic:
-		t = t.UTC().Round(time.Microsecond)
+		t = t.UTC().Round(em.timePrecision.duration())
 		secs, nsecs := t.Unix(), uint64(t.Nanosecond())
 		if nsecs == 0 {
 			return encodeInt(e, em, reflect.ValueOf(secs))
@@ -1570,15 +3140,65 @@ func encodeTime(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 		return encodeFloat(e, em, reflect.ValueOf(f))
 
 	case TimeRFC3339:
+		if em.timeZone == TimeZoneUTC {
+			t = t.UTC()
+		}
 		s := t.Format(time.RFC3339)
 		return encodeString(e, em, reflect.ValueOf(s))
 
 	default: // TimeRFC3339Nano
+		if em.timeZone == TimeZoneUTC {
+			t = t.UTC()
+		}
 		s := t.Format(time.RFC3339Nano)
 		return encodeString(e, em, reflect.ValueOf(s))
 	}
 }
 
+func encodeDuration(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	if em.duration == DurationExtended {
+		encodeHead(e, byte(cborTypeTag), tagNumDuration)
+		d := time.Duration(v.Int())
+		secs, nsecs := int64(d/time.Second), int(d%time.Second)
+		if nsecs < 0 {
+			// Fractional part is always non-negative in RFC 9581.
+			secs--
+			nsecs += int(time.Second)
+		}
+		return encodeExtendedTime(e, em, secs, nsecs)
+	}
+	if em.duration == DurationSecondsDynamic {
+		if b := em.encTagBytes(v.Type()); b != nil {
+			e.Write(b)
+		}
+		d := time.Duration(v.Int())
+		if d%time.Second == 0 {
+			return encodeInt(e, em, reflect.ValueOf(int64(d/time.Second)))
+		}
+		return encodeFloat(e, em, reflect.ValueOf(d.Seconds()))
+	}
+	return encodeInt(e, em, v)
+}
+
+// encodeExtendedTime encodes content of extended time (tag 1001) or duration (tag 1002)
+// as map with integer seconds (key 1) and nanoseconds (key -9) if nsecs is non-zero.
+func encodeExtendedTime(e *bytes.Buffer, em *encMode, secs int64, nsecs int) error {
+	if nsecs == 0 {
+		encodeHead(e, byte(cborTypeMap), 1)
+	} else {
+		encodeHead(e, byte(cborTypeMap), 2)
+	}
+	encodeHead(e, byte(cborTypePositiveInt), 1)
+	if err := encodeInt(e, em, reflect.ValueOf(secs)); err != nil {
+		return err
+	}
+	if nsecs != 0 {
+		encodeHead(e, byte(cborTypeNegativeInt), 8) // -9
+		encodeHead(e, byte(cborTypePositiveInt), uint64(nsecs))
+	}
+	return nil
+}
+
 func encodeBigInt(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 	if em.bigIntConvert == BigIntConvertReject {
 		return &UnsupportedTypeError{Type: typeBigInt}
@@ -1600,6 +3220,9 @@ func encodeBigInt(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 				return nil
 			}
 			// Encode as CBOR neg int (major type 1)
+			if err := em.acceptableNegativeInt(bi.Uint64()); err != nil {
+				return err
+			}
 			encodeHead(e, byte(cborTypeNegativeInt), bi.Uint64())
 			return nil
 		}
@@ -1665,6 +3288,74 @@ func (bme binaryMarshalerEncoder) isEmpty(em *encMode, v reflect.Value) (bool, e
 	return len(data) == 0, nil
 }
 
+type stringerEnumEncoder struct {
+	alternateEncode encodeFunc
+}
+
+func (see stringerEnumEncoder) encode(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	if em.stringerEnums != StringerEnumToString {
+		return see.alternateEncode(e, em, v)
+	}
+
+	if b := em.encTagBytes(v.Type()); b != nil {
+		e.Write(b)
+	}
+	encodeStringContent(e, em, v.Interface().(fmt.Stringer).String())
+	return nil
+}
+
+// simpleValueAnalog is an analog registered with WithSimpleValueAnalog, and its simple value.
+type simpleValueAnalog struct {
+	value interface{}
+	sv    SimpleValue
+}
+
+// newSimpleValueAnalogs returns analogs in r by Go type, with analogs of the same type
+// sorted by simple value.
+func newSimpleValueAnalogs(r *SimpleValueRegistry) (map[reflect.Type][]simpleValueAnalog, error) {
+	if r == nil || len(r.analogs) == 0 {
+		return nil, nil
+	}
+	analogs := make(map[reflect.Type][]simpleValueAnalog)
+	for i := 0; i <= math.MaxUint8; i++ {
+		sv := SimpleValue(i)
+		analog, ok := r.analogs[sv]
+		if !ok {
+			continue
+		}
+		t := analog.Type()
+		if t.Name() == "" || t.PkgPath() == "" {
+			return nil, errors.New("cbor: cannot encode analog of unnamed type " + t.String() + " as simple value " + strconv.Itoa(i))
+		}
+		if !t.Comparable() {
+			return nil, errors.New("cbor: cannot encode analog of uncomparable type " + t.String() + " as simple value " + strconv.Itoa(i))
+		}
+		analogs[t] = append(analogs[t], simpleValueAnalog{value: analog.Interface(), sv: sv})
+	}
+	return analogs, nil
+}
+
+// simpleValueAnalogEncoder encodes values of named types equal to analogs in
+// EncOptions.SimpleValueAnalogs as simple values, and other values with alternateEncode.
+type simpleValueAnalogEncoder struct {
+	alternateEncode encodeFunc
+}
+
+func (svae simpleValueAnalogEncoder) encode(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	if em.simpleValueAnalogs == nil || !v.CanInterface() {
+		return svae.alternateEncode(e, em, v)
+	}
+	if analogs := em.simpleValueAnalogs[v.Type()]; analogs != nil {
+		vi := v.Interface()
+		for _, analog := range analogs {
+			if vi == analog.value {
+				return encodeSimpleValue(e, em, reflect.ValueOf(analog.sv))
+			}
+		}
+	}
+	return svae.alternateEncode(e, em, v)
+}
+
 func encodeMarshalerType(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 	if em.tagsMd == TagsForbidden && v.Type() == typeRawTag {
 		return errors.New("cbor: cannot encode cbor.RawTag when TagsMd is TagsForbidden")
@@ -1679,12 +3370,68 @@ func encodeMarshalerType(e *bytes.Buffer, em *encMode, v reflect.Value) error {
 	if err != nil {
 		return err
 	}
+	return writeMarshaledData(e, em, v.Type(), data)
+}
+
+func encodeSimpleValue(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	if err := em.acceptableSimpleValue(v.Uint()); err != nil {
+		return err
+	}
+	return encodeMarshalerType(e, em, v)
+}
+
+// acceptableSimpleValue returns an error if simple value sv can't be encoded with em.
+func (em *encMode) acceptableSimpleValue(sv uint64) error {
+	if em.simpleValues == SimpleValuesFalseTrueNull && (sv < 20 || sv > 22) {
+		return &UnsupportedValueError{msg: "simple value " + strconv.FormatUint(sv, 10)}
+	}
+	return nil
+}
+
+// acceptableNegativeInt returns an error if CBOR negative integer -1-n can't be encoded with em.
+func (em *encMode) acceptableNegativeInt(n uint64) error {
+	if em.negativeInt65 == NegativeInt65Forbidden && n > math.MaxInt64 {
+		return &UnsupportedValueError{msg: "65-bit negative integer"}
+	}
+	return nil
+}
+
+func encodeMarshalerWithModeType(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	m, ok := v.Interface().(MarshalerWithMode)
+	if !ok {
+		pv := reflect.New(v.Type())
+		pv.Elem().Set(v)
+		m = pv.Interface().(MarshalerWithMode)
+	}
+	data, err := m.MarshalCBORWithMode(em.nestedEncMode())
+	if err != nil {
+		return err
+	}
+	return writeMarshaledData(e, em, v.Type(), data)
+}
+
+// encodeRawField writes raw CBOR data item in byte slice v (struct field with "raw" option)
+// after verifying that it is well-formed.  Empty byte slice is encoded as CBOR nil.
+func encodeRawField(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	b := v.Bytes()
+	if len(b) == 0 {
+		e.Write(cborNil)
+		return nil
+	}
+	return writeMarshaledData(e, em, v.Type(), b)
+}
 
-	// Verify returned CBOR data item from MarshalCBOR() is well-formed and passes tag validity for builtin tags 0-3.
-	d := decoder{data: data, dm: getMarshalerDecMode(em.indefLength, em.tagsMd)}
-	err = d.wellformed(false, true)
+// writeMarshaledData writes CBOR data returned by MarshalCBOR() or MarshalCBORWithMode()
+// to e after verifying that data is well-formed and passes tag validity for builtin tags 0-3.
+func writeMarshaledData(e *bytes.Buffer, em *encMode, t reflect.Type, data []byte) error {
+	dm := em.marshalerDecMode
+	if dm == nil {
+		dm = getMarshalerDecMode(em.indefLength, em.tagsMd)
+	}
+	d := decoder{data: data, dm: dm}
+	err := d.wellformed(false, true)
 	if err != nil {
-		return &MarshalerError{typ: v.Type(), err: err}
+		return &MarshalerError{typ: t, err: err}
 	}
 
 	e.Write(data)
@@ -1769,10 +3516,13 @@ func encodeHead(e *bytes.Buffer, t byte, n uint64) int {
 }
 
 var (
-	typeMarshaler       = reflect.TypeOf((*Marshaler)(nil)).Elem()
-	typeBinaryMarshaler = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
-	typeRawMessage      = reflect.TypeOf(RawMessage(nil))
-	typeByteString      = reflect.TypeOf(ByteString(""))
+	typeMarshaler         = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	typeMarshalerTo       = reflect.TypeOf((*MarshalerTo)(nil)).Elem()
+	typeMarshalerWithMode = reflect.TypeOf((*MarshalerWithMode)(nil)).Elem()
+	typeBinaryMarshaler   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	typeStringer          = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	typeRawMessage        = reflect.TypeOf(RawMessage(nil))
+	typeByteString        = reflect.TypeOf(ByteString(""))
 )
 
 func getEncodeFuncInternal(t reflect.Type) (ef encodeFunc, ief isEmptyFunc) {
@@ -1780,9 +3530,17 @@ func getEncodeFuncInternal(t reflect.Type) (ef encodeFunc, ief isEmptyFunc) {
 	if k == reflect.Ptr {
 		return getEncodeIndirectValueFunc(t), isEmptyPtr
 	}
+	if t.Name() != "" && t.PkgPath() != "" && t != typeSimpleValue {
+		defer func() {
+			// capture encoding method used for modes without simple value analogs
+			if ef != nil {
+				ef = simpleValueAnalogEncoder{alternateEncode: ef}.encode
+			}
+		}()
+	}
 	switch t {
 	case typeSimpleValue:
-		return encodeMarshalerType, isEmptyUint
+		return encodeSimpleValue, isEmptyUint
 
 	case typeTag:
 		return encodeTag, alwaysNotEmpty
@@ -1790,14 +3548,32 @@ func getEncodeFuncInternal(t reflect.Type) (ef encodeFunc, ief isEmptyFunc) {
 	case typeTime:
 		return encodeTime, alwaysNotEmpty
 
+	case typeDuration:
+		return encodeDuration, isEmptyInt
+
 	case typeBigInt:
 		return encodeBigInt, alwaysNotEmpty
 
+	case typeBigFloat:
+		return encodeBigFloat, isEmptyBigFloat
+
+	case typeBigRat:
+		return encodeBigRat, isEmptyBigRat
+
 	case typeRawMessage:
 		return encodeMarshalerType, isEmptySlice
 
 	case typeByteString:
 		return encodeMarshalerType, isEmptyString
+
+	case typeJSONNumber:
+		return encodeJSONNumber, isEmptyString
+	}
+	if reflect.PtrTo(t).Implements(typeMarshalerTo) {
+		return encodeMarshalerToType, alwaysNotEmpty
+	}
+	if reflect.PtrTo(t).Implements(typeMarshalerWithMode) {
+		return encodeMarshalerWithModeType, alwaysNotEmpty
 	}
 	if reflect.PtrTo(t).Implements(typeMarshaler) {
 		return encodeMarshalerType, alwaysNotEmpty
@@ -1818,9 +3594,15 @@ func getEncodeFuncInternal(t reflect.Type) (ef encodeFunc, ief isEmptyFunc) {
 		return encodeBool, isEmptyBool
 
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if t.Implements(typeStringer) {
+			return stringerEnumEncoder{alternateEncode: encodeInt}.encode, isEmptyInt
+		}
 		return encodeInt, isEmptyInt
 
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if t.Implements(typeStringer) {
+			return stringerEnumEncoder{alternateEncode: encodeUint}.encode, isEmptyUint
+		}
 		return encodeUint, isEmptyUint
 
 	case reflect.Float32, reflect.Float64:
@@ -1941,6 +3723,12 @@ func isEmptyStruct(em *encMode, v reflect.Value) (bool, error) {
 		return len(structType.fields) == 0, nil
 	}
 
+	if structType.unknownField != nil {
+		if m := unknownFieldValue(v, structType.unknownField); m.IsValid() && m.Len() > 0 {
+			return false, nil
+		}
+	}
+
 	if len(structType.fields) > len(structType.omitEmptyFieldsIdx) {
 		return false, nil
 	}
@@ -1963,17 +3751,58 @@ func isEmptyStruct(em *encMode, v reflect.Value) (bool, error) {
 			}
 		}
 
-		empty, err := f.ief(em, fv)
+		omitted, err := isOmittedField(em, f, fv)
 		if err != nil {
 			return false, err
 		}
-		if !empty {
+		if !omitted {
 			return false, nil
 		}
 	}
 	return true, nil
 }
 
+// isOmittedField returns true if struct field f with value v is omitted by
+// "omitzero" or "omitempty" option.
+func isOmittedField(em *encMode, f *field, v reflect.Value) (bool, error) {
+	if f.omitZero && isZeroValue(v) {
+		return true, nil
+	}
+	if f.omitEmpty {
+		if fn, ok := em.emptyPredicates.get(v.Type()); ok && v.CanInterface() {
+			return fn(v.Interface()), nil
+		}
+		return f.ief(em, v)
+	}
+	return false, nil
+}
+
+type isZeroer interface {
+	IsZero() bool
+}
+
+var typeIsZeroer = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// isZeroValue returns true if v is the zero value of its type, using
+// IsZero() bool method if implemented.
+func isZeroValue(v reflect.Value) bool {
+	t := v.Type()
+	if !v.CanInterface() {
+		// Field promoted from unexported embedded struct
+		return v.IsZero()
+	}
+	if t.Implements(typeIsZeroer) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return true
+		}
+		return v.Interface().(isZeroer).IsZero()
+	}
+	if v.CanAddr() && reflect.PtrTo(t).Implements(typeIsZeroer) {
+		return v.Addr().Interface().(isZeroer).IsZero()
+	}
+	return v.IsZero()
+}
+
 func cannotFitFloat32(f64 float64) bool {
 	f32 := float32(f64)
 	return float64(f32) != f64
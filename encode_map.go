@@ -36,7 +36,7 @@ func (me *mapKeyValueEncodeFunc) encodeKeyValues(e *bytes.Buffer, em *encMode, v
 			if err := me.kf(e, em, *iterk); err != nil {
 				return err
 			}
-			if err := me.ef(e, em, *iterv); err != nil {
+			if err := encodeMapValue(e, em, me.ef, *iterk, *iterv); err != nil {
 				return err
 			}
 		}
@@ -53,7 +53,7 @@ func (me *mapKeyValueEncodeFunc) encodeKeyValues(e *bytes.Buffer, em *encMode, v
 			return err
 		}
 		valueOffset := e.Len()
-		if err := me.ef(e, em, *iterv); err != nil {
+		if err := encodeMapValue(e, em, me.ef, *iterk, *iterv); err != nil {
 			return err
 		}
 		kvs[i] = keyValue{
@@ -89,6 +89,8 @@ func getEncodeMapFunc(t reflect.Type) encodeFunc {
 		},
 	}
 	return mapEncodeFunc{
-		e: mkv.encodeKeyValues,
+		e:  mkv.encodeKeyValues,
+		kf: kf,
+		ef: ef,
 	}.encode
 }
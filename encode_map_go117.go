@@ -20,7 +20,7 @@ func (me *mapKeyValueEncodeFunc) encodeKeyValues(e *bytes.Buffer, em *encMode, v
 			if err := me.kf(e, em, iter.Key()); err != nil {
 				return err
 			}
-			if err := me.ef(e, em, iter.Value()); err != nil {
+			if err := encodeMapValue(e, em, me.ef, iter.Key(), iter.Value()); err != nil {
 				return err
 			}
 		}
@@ -34,7 +34,7 @@ func (me *mapKeyValueEncodeFunc) encodeKeyValues(e *bytes.Buffer, em *encMode, v
 			return err
 		}
 		valueOffset := e.Len()
-		if err := me.ef(e, em, iter.Value()); err != nil {
+		if err := encodeMapValue(e, em, me.ef, iter.Key(), iter.Value()); err != nil {
 			return err
 		}
 		kvs[i] = keyValue{
@@ -55,6 +55,8 @@ func getEncodeMapFunc(t reflect.Type) encodeFunc {
 	}
 	mkv := &mapKeyValueEncodeFunc{kf: kf, ef: ef}
 	return mapEncodeFunc{
-		e: mkv.encodeKeyValues,
+		e:  mkv.encodeKeyValues,
+		kf: kf,
+		ef: ef,
 	}.encode
 }
@@ -6,11 +6,13 @@ package cbor
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"math/big"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -2362,7 +2364,10 @@ func TestMarshalStructKeyAsIntNumError(t *testing.T) {
 		F1 int `cbor:"2.0,keyasint"`
 	}
 	type T2 struct {
-		F1 int `cbor:"-18446744073709551616,keyasint"`
+		F1 int `cbor:"-18446744073709551617,keyasint"`
+	}
+	type T3 struct {
+		F1 int `cbor:"18446744073709551616,keyasint"`
 	}
 	testCases := []struct {
 		name         string
@@ -2375,9 +2380,14 @@ func TestMarshalStructKeyAsIntNumError(t *testing.T) {
 			wantErrorMsg: "cbor: failed to parse field name \"2.0\" to int",
 		},
 		{
-			name:         "out of range int as key",
+			name:         "out of range negative int as key",
 			obj:          T2{},
-			wantErrorMsg: "cbor: failed to parse field name \"-18446744073709551616\" to int",
+			wantErrorMsg: "cbor: failed to parse field name \"-18446744073709551617\" to int",
+		},
+		{
+			name:         "out of range positive int as key",
+			obj:          T3{},
+			wantErrorMsg: "cbor: failed to parse field name \"18446744073709551616\" to int",
 		},
 	}
 	for _, tc := range testCases {
@@ -2494,6 +2504,8 @@ func TestMapSort(t *testing.T) {
 		{"CBOR canonical sort", EncOptions{Sort: SortCanonical}, lenFirstSortedCborData},
 		{"CTAP2 canonical sort", EncOptions{Sort: SortCTAP2}, bytewiseSortedCborData},
 		{"Core deterministic sort", EncOptions{Sort: SortCoreDeterministic}, bytewiseSortedCborData},
+		{"Length first sort by encoded keys", EncOptions{Sort: SortLengthFirst, SortMap: SortMapEncodedKeys}, lenFirstSortedCborData},
+		{"Bytewise sort by encoded keys", EncOptions{Sort: SortBytewiseLexical, SortMap: SortMapEncodedKeys}, bytewiseSortedCborData},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -2512,6 +2524,54 @@ func TestMapSort(t *testing.T) {
 	}
 }
 
+func TestMapSortByEncodedKeys(t *testing.T) {
+	m := make(map[string][]int, 1000)
+	for i := 0; i < 1000; i++ {
+		m[strconv.Itoa(i)] = []int{i, i * 2}
+	}
+
+	for _, sortMode := range []SortMode{SortLengthFirst, SortBytewiseLexical} {
+		pairsEM, err := EncOptions{Sort: sortMode}.EncMode()
+		if err != nil {
+			t.Fatalf("EncMode() returned error %v", err)
+		}
+		keysEM, err := EncOptions{Sort: sortMode, SortMap: SortMapEncodedKeys}.EncMode()
+		if err != nil {
+			t.Fatalf("EncMode() returned error %v", err)
+		}
+
+		want, err := pairsEM.Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal() returned error %v", err)
+		}
+		b, err := keysEM.Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal() returned error %v", err)
+		}
+		if !bytes.Equal(b, want) {
+			t.Errorf("Marshal() with SortMapEncodedKeys and SortMode %d = 0x%x, want 0x%x", sortMode, b, want)
+		}
+	}
+}
+
+func TestMapSortByEncodedKeysNaN(t *testing.T) {
+	// Map values can't be looked up by NaN keys, so entries are sorted by encoded pairs.
+	m := map[float64]int{math.NaN(): 1, 1.5: 2}
+
+	em, err := EncOptions{Sort: SortCoreDeterministic, ShortestFloat: ShortestFloat16, SortMap: SortMapEncodedKeys}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	want := hexDecode("a2f93e0002f97e0001") // {1.5: 2, NaN: 1}
+	b, err := em.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal(%v) returned error %v", m, err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("Marshal(%v) = 0x%x, want 0x%x", m, b, want)
+	}
+}
+
 func TestStructSort(t *testing.T) {
 	type T struct {
 		A bool `cbor:"aa"`
@@ -2572,6 +2632,26 @@ func TestInvalidSort(t *testing.T) {
 			opts:         EncOptions{Sort: 101},
 			wantErrorMsg: "cbor: invalid SortMode 101",
 		},
+		{
+			name:         "SortMap below range of valid modes",
+			opts:         EncOptions{SortMap: -1},
+			wantErrorMsg: "cbor: invalid SortMap -1",
+		},
+		{
+			name:         "SortMap above range of valid modes",
+			opts:         EncOptions{SortMap: 101},
+			wantErrorMsg: "cbor: invalid SortMap 101",
+		},
+		{
+			name:         "SortCustom without SortLess",
+			opts:         EncOptions{Sort: SortCustom},
+			wantErrorMsg: "cbor: SortLess must be set when Sort is SortCustom",
+		},
+		{
+			name:         "SortLess without SortCustom",
+			opts:         EncOptions{Sort: SortBytewiseLexical, SortLess: NewLessFunc(func(a, b []byte) bool { return false })},
+			wantErrorMsg: "cbor: SortLess can only be set when Sort is SortCustom",
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			_, err := tc.opts.EncMode()
@@ -2584,6 +2664,77 @@ func TestInvalidSort(t *testing.T) {
 	}
 }
 
+func TestSortCustom(t *testing.T) {
+	// reverseBytewise sorts encoded keys in descending bytewise lexical order.
+	reverseBytewise := func(a, b []byte) bool { return bytes.Compare(a, b) > 0 }
+
+	type T struct {
+		A bool `cbor:"aa"`
+		B bool `cbor:"z"`
+		C bool `cbor:"-1,keyasint"`
+		D bool `cbor:"100,keyasint"`
+		E bool `cbor:"10,keyasint"`
+	}
+
+	type TUnknown struct {
+		A       bool                   `cbor:"aa"`
+		B       bool                   `cbor:"z"`
+		Unknown map[string]interface{} `cbor:",inline"`
+	}
+
+	testCases := []struct {
+		name         string
+		sortMap      SortMapMode
+		value        interface{}
+		wantCborData []byte
+	}{
+		{
+			name:         "map",
+			value:        map[interface{}]bool{"aa": false, "z": false, -1: false, 100: false, 10: false},
+			wantCborData: hexDecode("a5626161f4617af420f41864f40af4"), // "aa", "z", -1, 100, 10
+		},
+		{
+			name:         "map sorted by encoded keys",
+			sortMap:      SortMapEncodedKeys,
+			value:        map[interface{}]bool{"aa": false, "z": false, -1: false, 100: false, 10: false},
+			wantCborData: hexDecode("a5626161f4617af420f41864f40af4"), // "aa", "z", -1, 100, 10
+		},
+		{
+			name:         "struct",
+			value:        T{},
+			wantCborData: hexDecode("a5626161f4617af420f41864f40af4"), // "aa", "z", -1, 100, 10
+		},
+		{
+			name:         "struct with inline map",
+			value:        TUnknown{Unknown: map[string]interface{}{"b": 1}},
+			wantCborData: hexDecode("a3626161f4617af4616201"), // "aa", "z", "b"
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := EncOptions{Sort: SortCustom, SortLess: NewLessFunc(reverseBytewise), SortMap: tc.sortMap}.EncMode()
+			if err != nil {
+				t.Fatalf("EncMode() returned error %v", err)
+			}
+			b, err := em.Marshal(tc.value)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned error %v", tc.value, err)
+			}
+			if !bytes.Equal(b, tc.wantCborData) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", tc.value, b, tc.wantCborData)
+			}
+		})
+	}
+
+	em, err := EncOptions{Sort: SortCustom, SortLess: NewLessFunc(reverseBytewise)}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	if opts := em.EncOptions(); opts.Sort != SortCustom || opts.SortLess == nil {
+		t.Errorf("EncOptions() = %+v, want SortCustom with non-nil SortLess", opts)
+	}
+}
+
 func TestTypeAlias(t *testing.T) { //nolint:dupl,unconvert
 	type myBool = bool
 	type myUint = uint
@@ -3745,6 +3896,44 @@ func TestEncModeInvalidBigIntConvertMode(t *testing.T) {
 	}
 }
 
+func TestEncModeInvalidBigFloatAndBigRatModes(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         EncOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "BigFloat below range of valid modes",
+			opts:         EncOptions{BigFloat: -1},
+			wantErrorMsg: "cbor: invalid BigFloat -1",
+		},
+		{
+			name:         "BigFloat above range of valid modes",
+			opts:         EncOptions{BigFloat: 101},
+			wantErrorMsg: "cbor: invalid BigFloat 101",
+		},
+		{
+			name:         "BigRat below range of valid modes",
+			opts:         EncOptions{BigRat: -1},
+			wantErrorMsg: "cbor: invalid BigRat -1",
+		},
+		{
+			name:         "BigRat above range of valid modes",
+			opts:         EncOptions{BigRat: 101},
+			wantErrorMsg: "cbor: invalid BigRat 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.EncMode()
+			if err == nil {
+				t.Errorf("EncMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
 func TestEncOptionsTagsForbidden(t *testing.T) {
 	// It's not valid to set both TagsMd and TimeTag to a non-zero value in the same EncOptions,
 	// so this exercises the options-mode-options roundtrip for non-zero TagsMd.
@@ -3762,24 +3951,77 @@ func TestEncOptionsTagsForbidden(t *testing.T) {
 	}
 }
 
+// stubEncMode implements EncMode without methods of ExtendedEncMode.
+type stubEncMode struct{}
+
+func (stubEncMode) Marshal(interface{}) ([]byte, error) { return nil, nil }
+func (stubEncMode) NewEncoder(io.Writer) *Encoder       { return nil }
+func (stubEncMode) EncOptions() EncOptions              { return EncOptions{} }
+
+var _ EncMode = stubEncMode{}
+
+func TestExtendedEncMode(t *testing.T) {
+	tags := NewTagSet()
+	modes := map[string]func() (EncMode, error){
+		"EncMode":               EncOptions{}.EncMode,
+		"EncModeWithTags":       func() (EncMode, error) { return EncOptions{}.EncModeWithTags(tags) },
+		"EncModeWithSharedTags": func() (EncMode, error) { return EncOptions{}.EncModeWithSharedTags(tags) },
+	}
+	for name, fn := range modes {
+		em, err := fn()
+		if err != nil {
+			t.Fatalf("%s() returned error %v", name, err)
+		}
+		if _, ok := em.(ExtendedEncMode); !ok {
+			t.Errorf("%s() returned %T, want ExtendedEncMode", name, em)
+		}
+	}
+}
+
 func TestEncOptions(t *testing.T) {
+	bufferPool, err := NewSizeClassBufferPool(1 << 16)
+	if err != nil {
+		t.Fatalf("NewSizeClassBufferPool() returned error %v", err)
+	}
 	opts1 := EncOptions{
 		Sort:                 SortBytewiseLexical,
+		SortMap:              SortMapEncodedKeys,
 		ShortestFloat:        ShortestFloat16,
 		NaNConvert:           NaNConvertPreserveSignal,
 		InfConvert:           InfConvertNone,
+		Float:                FloatForbidden,
 		BigIntConvert:        BigIntConvertNone,
+		BigFloat:             BigFloatBigfloat,
+		BigRat:               BigRatRational,
 		Time:                 TimeRFC3339Nano,
 		TimeTag:              EncTagRequired,
+		TimePrecision:        TimePrecisionNanosecond,
+		TimeZone:             TimeZoneUTC,
+		Duration:             DurationExtended,
 		IndefLength:          IndefLengthForbidden,
 		NilContainers:        NilContainerAsEmpty,
 		TagsMd:               TagsAllowed,
 		OmitEmpty:            OmitEmptyGoValue,
+		EmptyStruct:          EmptyStructAsNull,
 		String:               StringToByteString,
 		FieldName:            FieldNameToByteString,
 		ByteSliceLaterFormat: ByteSliceLaterFormatBase16,
 		ByteArray:            ByteArrayToArray,
+		ByteSlice:            ByteSliceToArray,
 		BinaryMarshaler:      BinaryMarshalerNone,
+		StringerEnums:        StringerEnumToString,
+		EncodedItem:          EncodedItemToByteString,
+		SelfDescribedCBOR:    SelfDescribedCBOREachItem,
+		JSONNumber:           JSONNumberToNumber,
+		NumericReduction:     NumericReductionFloatToInt,
+		SimpleValues:         SimpleValuesFalseTrueNull,
+		NegativeInt65:        NegativeInt65Forbidden,
+		BufferPool:           bufferPool,
+		NumericDate:          NumericDateDynamic,
+		DateTag:              DateTagDays,
+		SimpleValueAnalogs:   &SimpleValueRegistry{},
+		EmptyPredicates:      NewEmptyPredicates(map[reflect.Type]func(interface{}) bool{typeTime: func(interface{}) bool { return false }}),
+		EncodeHook:           NewEncodeHook(func(string, reflect.Value) (interface{}, bool) { return nil, false }),
 	}
 	ov := reflect.ValueOf(opts1)
 	for i := 0; i < ov.NumField(); i++ {
@@ -3792,6 +4034,16 @@ func TestEncOptions(t *testing.T) {
 				// non-zero value for other options (e.g. TimeTag).
 				continue
 			}
+			if fn == "StringChunkSize" {
+				// Roundtripping non-zero values for StringChunkSize is tested separately
+				// since it is incompatible with IndefLengthForbidden.
+				continue
+			}
+			if fn == "SortLess" {
+				// Roundtripping non-nil values for SortLess is tested separately
+				// since it is incompatible with SortBytewiseLexical.
+				continue
+			}
 			t.Errorf("options field %q is unset or set to the zero value for its type", fn)
 		}
 	}
@@ -4634,6 +4886,161 @@ func TestMarshalByteArrayMode(t *testing.T) {
 	}
 }
 
+func TestInvalidByteSlice(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         EncOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         EncOptions{ByteSlice: -1},
+			wantErrorMsg: "cbor: invalid ByteSlice -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         EncOptions{ByteSlice: 101},
+			wantErrorMsg: "cbor: invalid ByteSlice 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.EncMode()
+			if err == nil {
+				t.Errorf("EncMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestMarshalByteSliceToArray(t *testing.T) {
+	type namedByteSlice []byte
+	ts := NewTagSet()
+	if err := ts.Add(TagOptions{EncTag: EncTagRequired}, reflect.TypeOf(namedByteSlice{}), 0xcc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		tags     TagSet
+		opts     EncOptions
+		in       interface{}
+		expected []byte
+	}{
+		{
+			name:     "byte slice",
+			opts:     EncOptions{ByteSlice: ByteSliceToArray},
+			in:       []byte{0x01, 0xbb},
+			expected: hexDecode("820118bb"),
+		},
+		{
+			name:     "empty byte slice",
+			opts:     EncOptions{ByteSlice: ByteSliceToArray},
+			in:       []byte{},
+			expected: hexDecode("80"),
+		},
+		{
+			name:     "nil byte slice",
+			opts:     EncOptions{ByteSlice: ByteSliceToArray},
+			in:       []byte(nil),
+			expected: hexDecode("f6"),
+		},
+		{
+			name:     "nil byte slice with NilContainerAsEmpty",
+			opts:     EncOptions{ByteSlice: ByteSliceToArray, NilContainers: NilContainerAsEmpty},
+			in:       []byte(nil),
+			expected: hexDecode("80"),
+		},
+		{
+			name:     "expected later encoding tag is ignored",
+			opts:     EncOptions{ByteSlice: ByteSliceToArray, ByteSliceLaterFormat: ByteSliceLaterFormatBase64},
+			in:       []byte{0xbb},
+			expected: hexDecode("8118bb"),
+		},
+		{
+			name:     "user-registered tag number",
+			tags:     ts,
+			opts:     EncOptions{ByteSlice: ByteSliceToArray},
+			in:       namedByteSlice{0xbb},
+			expected: hexDecode("d8cc8118bb"),
+		},
+		{
+			name:     "byte array isn't affected",
+			opts:     EncOptions{ByteSlice: ByteSliceToArray},
+			in:       [1]byte{0xbb},
+			expected: hexDecode("41bb"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var em EncMode
+			var err error
+			if tc.tags != nil {
+				em, err = tc.opts.EncModeWithTags(tc.tags)
+			} else {
+				em, err = tc.opts.EncMode()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := em.Marshal(tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, tc.expected) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", tc.in, out, tc.expected)
+			}
+		})
+	}
+}
+
+func TestMarshalFieldArrayOption(t *testing.T) {
+	type s struct {
+		A []byte  `cbor:"a,array"`
+		B [2]byte `cbor:"b,array"`
+		C []byte  `cbor:"c"`
+		D []byte  `cbor:"d,array,omitempty"`
+	}
+	v := s{A: []byte{1, 0xff}, B: [2]byte{2, 3}, C: []byte{4}}
+	want := hexDecode("a36161820118ff616282020361634104")
+
+	for _, opts := range []EncOptions{
+		{},
+		{ByteArray: ByteArrayToArray},
+	} {
+		em, err := opts.EncMode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := em.Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal(%+v) returned error %v", v, err)
+		}
+		if !bytes.Equal(b, want) {
+			t.Errorf("Marshal(%+v) = 0x%x, want 0x%x", v, b, want)
+		}
+
+		var got s
+		if err = Unmarshal(b, &got); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", b, err)
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Errorf("Unmarshal(0x%x) = %+v, want %+v", b, got, v)
+		}
+	}
+
+	type invalid struct {
+		A string `cbor:"a,array"`
+	}
+	wantErrorMsg := "cbor: field cbor.invalid.a with \"array\" option must be a byte slice or byte array, got string"
+	if _, err := Marshal(invalid{}); err == nil {
+		t.Errorf("Marshal() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Marshal() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+}
+
 func TestMarshalByteSliceMode(t *testing.T) {
 	type namedByteSlice []byte
 	ts := NewTagSet()
@@ -4848,3 +5255,1717 @@ func TestBinaryMarshalerMode(t *testing.T) {
 		})
 	}
 }
+
+func TestEncModeInvalidStringerEnumMode(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         EncOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         EncOptions{StringerEnums: -1},
+			wantErrorMsg: "cbor: invalid StringerEnums -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         EncOptions{StringerEnums: 101},
+			wantErrorMsg: "cbor: invalid StringerEnums 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.EncMode()
+			if err == nil {
+				t.Errorf("EncMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestEncModeInvalidSelfDescribedCBOR(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         EncOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         EncOptions{SelfDescribedCBOR: -1},
+			wantErrorMsg: "cbor: invalid SelfDescribedCBOR -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         EncOptions{SelfDescribedCBOR: 101},
+			wantErrorMsg: "cbor: invalid SelfDescribedCBOR 101",
+		},
+		{
+			name:         "SelfDescribedCBOREachItem with TagsForbidden",
+			opts:         EncOptions{SelfDescribedCBOR: SelfDescribedCBOREachItem, TagsMd: TagsForbidden},
+			wantErrorMsg: "cbor: cannot set TagsMd to TagsForbidden when SelfDescribedCBOR is not SelfDescribedCBORNone",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.EncMode()
+			if err == nil {
+				t.Errorf("EncMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestMarshalSelfDescribedCBOR(t *testing.T) {
+	for _, mode := range []SelfDescribedCBORMode{SelfDescribedCBOREachItem, SelfDescribedCBOROncePerStream} {
+		em, err := EncOptions{SelfDescribedCBOR: mode}.EncMode()
+		if err != nil {
+			t.Fatalf("EncMode() returned error %v", err)
+		}
+
+		want := hexDecode("d9d9f7a1616181f6") // 55799({"a": [null]})
+		v := map[string][]interface{}{"a": {nil}}
+
+		b, err := em.Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal() returned error %v", err)
+		}
+		if !bytes.Equal(b, want) {
+			t.Errorf("Marshal() = 0x%x, want 0x%x", b, want)
+		}
+
+		var buf bytes.Buffer
+		if err := em.(UserBufferEncMode).MarshalToBuffer(v, &buf); err != nil {
+			t.Fatalf("MarshalToBuffer() returned error %v", err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("MarshalToBuffer() = 0x%x, want 0x%x", buf.Bytes(), want)
+		}
+
+		var got map[string][]interface{}
+		if err := Unmarshal(b, &got); err != nil {
+			t.Errorf("Unmarshal(0x%x) returned error %v", b, err)
+		} else if !reflect.DeepEqual(got, v) {
+			t.Errorf("Unmarshal(0x%x) = %v, want %v", b, got, v)
+		}
+	}
+}
+
+func TestMarshalSelfDescribedCBORNested(t *testing.T) {
+	type S struct {
+		E EncodedItem
+		M OrderedMap
+	}
+
+	em, err := EncOptions{SelfDescribedCBOR: SelfDescribedCBOREachItem}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	// Only the top-level data item is prefixed with tag number 55799.
+	v := S{E: EncodedItem{Value: 1}, M: OrderedMap{{Key: "a", Value: 2}}}
+	want := hexDecode("d9d9f7a26145d8184101614da1616102") // 55799({"E": 24(h'01'), "M": {"a": 2}})
+
+	b, err := em.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("Marshal() = 0x%x, want 0x%x", b, want)
+	}
+
+	b, err = em.(ExtendedEncMode).MarshalAppend(nil, v)
+	if err != nil {
+		t.Fatalf("MarshalAppend() returned error %v", err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("MarshalAppend() = 0x%x, want 0x%x", b, want)
+	}
+
+	var buf bytes.Buffer
+	if err := em.NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Encode() = 0x%x, want 0x%x", buf.Bytes(), want)
+	}
+}
+
+type testTaggedSlice []int
+
+type testMarshalerWithModeTaggedSlice struct{}
+
+func (testMarshalerWithModeTaggedSlice) MarshalCBORWithMode(em EncMode) ([]byte, error) {
+	return em.Marshal(testTaggedSlice{1})
+}
+
+func TestMarshalSelfDescribedCBORNestedWithTags(t *testing.T) {
+	tags := NewTagSet()
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagRequired}, reflect.TypeOf(testTaggedSlice(nil)), 1234); err != nil {
+		t.Fatalf("TagSet.Add() returned error %v", err)
+	}
+
+	v := []interface{}{testMarshalerWithModeTaggedSlice{}}
+	for _, tc := range []struct {
+		name string
+		opts EncOptions
+		want []byte
+	}{
+		{
+			name: "without self-described CBOR tag",
+			opts: EncOptions{},
+			want: hexDecode("81d904d28101"), // [1234([1])]
+		},
+		{
+			name: "with self-described CBOR tag",
+			opts: EncOptions{SelfDescribedCBOR: SelfDescribedCBOREachItem},
+			want: hexDecode("d9d9f781d904d28101"), // 55799([1234([1])])
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := tc.opts.EncModeWithTags(tags)
+			if err != nil {
+				t.Fatalf("EncModeWithTags() returned error %v", err)
+			}
+			b, err := em.Marshal(v)
+			if err != nil {
+				t.Fatalf("Marshal() returned error %v", err)
+			}
+			if !bytes.Equal(b, tc.want) {
+				t.Errorf("Marshal() = 0x%x, want 0x%x", b, tc.want)
+			}
+		})
+	}
+}
+
+func TestMarshalAppend(t *testing.T) {
+	values := []interface{}{
+		uint(1),
+		"abc",
+		[]int{1, 2, 3},
+		map[string]interface{}{"a": []interface{}{nil, true}},
+		struct {
+			A int `cbor:"1,keyasint"`
+		}{A: 5},
+	}
+
+	dst := []byte{0x01, 0x02}
+	var want []byte
+	want = append(want, dst...)
+	for _, v := range values {
+		b, err := Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal(%v) returned error %v", v, err)
+		}
+		want = append(want, b...)
+
+		dst, err = MarshalAppend(dst, v)
+		if err != nil {
+			t.Fatalf("MarshalAppend(%v) returned error %v", v, err)
+		}
+		if !bytes.Equal(dst, want) {
+			t.Errorf("MarshalAppend(%v) = 0x%x, want 0x%x", v, dst, want)
+		}
+	}
+
+	// Encoding mode options are applied.
+	em, err := EncOptions{SelfDescribedCBOR: SelfDescribedCBOREachItem}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	b, err := em.(ExtendedEncMode).MarshalAppend(nil, []interface{}{nil})
+	if err != nil {
+		t.Fatalf("MarshalAppend() returned error %v", err)
+	}
+	if want := hexDecode("d9d9f781f6"); !bytes.Equal(b, want) {
+		t.Errorf("MarshalAppend() = 0x%x, want 0x%x", b, want)
+	}
+
+	// dst is returned unmodified if an error is returned.
+	dst = []byte{0x01, 0x02}
+	b, err = MarshalAppend(dst, []interface{}{1, make(chan int)})
+	if err == nil {
+		t.Errorf("MarshalAppend() didn't return an error")
+	}
+	if !bytes.Equal(b, dst) {
+		t.Errorf("MarshalAppend() returned 0x%x with error, want 0x%x", b, dst)
+	}
+
+	// No allocation if dst has enough capacity.
+	buf := make([]byte, 0, 64)
+	var v interface{} = []int{1, 2, 3}
+	allocs := testing.AllocsPerRun(10, func() {
+		buf, _ = MarshalAppend(buf[:0], v)
+	})
+	if allocs != 0 {
+		t.Errorf("MarshalAppend() allocated %v times, want 0", allocs)
+	}
+}
+
+func TestEncModeInvalidEmptyPredicates(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         EncOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "nil type",
+			opts:         EncOptions{EmptyPredicates: NewEmptyPredicates(map[reflect.Type]func(interface{}) bool{nil: func(interface{}) bool { return true }})},
+			wantErrorMsg: "cbor: invalid EmptyPredicates: nil type",
+		},
+		{
+			name:         "nil function",
+			opts:         EncOptions{EmptyPredicates: NewEmptyPredicates(map[reflect.Type]func(interface{}) bool{typeTime: nil})},
+			wantErrorMsg: "cbor: invalid EmptyPredicates: nil function for time.Time",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.EncMode()
+			if err == nil {
+				t.Errorf("EncMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestEmptyPredicates(t *testing.T) {
+	type id [4]byte
+
+	type s struct {
+		T   time.Time  `cbor:"t,omitempty"`
+		P   *time.Time `cbor:"p,omitempty"`
+		ID  id         `cbor:"id,omitempty"`
+		Int int        `cbor:"int,omitempty"`
+	}
+
+	isZeroTime := func(v interface{}) bool { return v.(time.Time).IsZero() }
+	isZeroTimePtr := func(v interface{}) bool { p := v.(*time.Time); return p == nil || p.IsZero() }
+	isNilID := func(v interface{}) bool { return v.(id) == id{} }
+
+	predicates := map[reflect.Type]func(interface{}) bool{
+		typeTime:                     isZeroTime,
+		reflect.TypeOf(&time.Time{}): isZeroTimePtr,
+		reflect.TypeOf(id{}):         isNilID,
+	}
+	opts := EncOptions{EmptyPredicates: NewEmptyPredicates(predicates)}
+	em, err := opts.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	// Modifying map passed to NewEmptyPredicates doesn't affect EncMode.
+	delete(predicates, typeTime)
+
+	zeroTime := time.Time{}
+	tm := time.Unix(1, 0).UTC()
+
+	testCases := []struct {
+		name string
+		v    s
+		want []byte
+	}{
+		{
+			name: "empty",
+			v:    s{P: &zeroTime},
+			want: hexDecode("a0"),
+		},
+		{
+			name: "not empty",
+			v:    s{T: tm, P: &tm, ID: id{1, 2, 3, 4}, Int: 1},
+			want: hexDecode("a4617401617001626964440102030463696e7401"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := em.Marshal(tc.v)
+			if err != nil {
+				t.Fatalf("Marshal(%+v) returned error %v", tc.v, err)
+			}
+			if !bytes.Equal(b, tc.want) {
+				t.Errorf("Marshal(%+v) = 0x%x, want 0x%x", tc.v, b, tc.want)
+			}
+		})
+	}
+
+	// Without EmptyPredicates, zero time.Time and non-nil pointer aren't omitted.
+	b, err := Marshal(s{P: &zeroTime})
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+	if want := hexDecode("a36174f66170f66269644400000000"); !bytes.Equal(b, want) {
+		t.Errorf("Marshal() = 0x%x, want 0x%x", b, want)
+	}
+
+	if opts2 := em.EncOptions(); opts2 != opts {
+		t.Errorf("EncOptions() returned %+v, want %+v", opts2, opts)
+	}
+}
+
+func TestStringerEnumMode(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts EncOptions
+		in   interface{}
+		want []byte
+	}{
+		{
+			name: "time.Month is encoded as integer by default",
+			opts: EncOptions{},
+			in:   time.March,
+			want: hexDecode("03"),
+		},
+		{
+			name: "time.Month is encoded as text string with StringerEnumToString",
+			opts: EncOptions{StringerEnums: StringerEnumToString},
+			in:   time.March,
+			want: hexDecode("654d61726368"), // "March"
+		},
+		{
+			name: "time.Weekday map key is encoded as text string with StringerEnumToString",
+			opts: EncOptions{StringerEnums: StringerEnumToString},
+			in:   map[time.Weekday]int{time.Monday: 1},
+			want: hexDecode("a1664d6f6e64617901"), // {"Monday": 1}
+		},
+		{
+			name: "time.Month is encoded as byte string with StringerEnumToString and StringToByteString",
+			opts: EncOptions{StringerEnums: StringerEnumToString, String: StringToByteString},
+			in:   time.March,
+			want: hexDecode("454d61726368"), // 'March'
+		},
+		{
+			name: "time.Duration is not affected by StringerEnumToString",
+			opts: EncOptions{StringerEnums: StringerEnumToString},
+			in:   time.Duration(1),
+			want: hexDecode("01"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := tc.opts.EncMode()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := em.Marshal(tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(tc.want, got) {
+				t.Errorf("unexpected output, want: 0x%x, got 0x%x", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEncModeInvalidEmptyStructMode(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         EncOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         EncOptions{EmptyStruct: -1},
+			wantErrorMsg: "cbor: invalid EmptyStruct -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         EncOptions{EmptyStruct: 101},
+			wantErrorMsg: "cbor: invalid EmptyStruct 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.EncMode()
+			if err == nil {
+				t.Errorf("EncMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestEmptyStructMode(t *testing.T) {
+	type emptyStruct struct{}
+
+	type omitEmptyStruct struct {
+		A int `cbor:"a,omitempty"`
+	}
+
+	type outerStruct struct {
+		S emptyStruct  `cbor:"s"`
+		P *emptyStruct `cbor:"p"`
+		N int          `cbor:"n"`
+	}
+
+	type outerOmitEmptyStruct struct {
+		O omitEmptyStruct `cbor:"o"`
+	}
+
+	type outerStructWithOmitEmpty struct {
+		S emptyStruct `cbor:"s,omitempty"`
+		N int         `cbor:"n"`
+	}
+
+	type toArrayStruct struct {
+		_ struct{} `cbor:",toarray"`
+	}
+
+	for _, tc := range []struct {
+		name  string
+		in    interface{}
+		wants map[EmptyStructMode][]byte
+	}{
+		{
+			name: "top-level empty struct",
+			in:   emptyStruct{},
+			wants: map[EmptyStructMode][]byte{
+				EmptyStructAsEmptyMap: hexDecode("a0"),
+				EmptyStructAsNull:     hexDecode("f6"),
+				EmptyStructOmitted:    hexDecode("a0"),
+			},
+		},
+		{
+			name: "top-level struct with all fields omitted by omitempty",
+			in:   omitEmptyStruct{},
+			wants: map[EmptyStructMode][]byte{
+				EmptyStructAsEmptyMap: hexDecode("a0"),
+				EmptyStructAsNull:     hexDecode("f6"),
+				EmptyStructOmitted:    hexDecode("a0"),
+			},
+		},
+		{
+			name: "empty struct fields",
+			in:   outerStruct{P: &emptyStruct{}, N: 1},
+			wants: map[EmptyStructMode][]byte{
+				EmptyStructAsEmptyMap: hexDecode("a36173a06170a0616e01"),
+				EmptyStructAsNull:     hexDecode("a36173f66170f6616e01"),
+				EmptyStructOmitted:    hexDecode("a1616e01"),
+			},
+		},
+		{
+			name: "nil pointer to empty struct field",
+			in:   outerStruct{N: 1},
+			wants: map[EmptyStructMode][]byte{
+				EmptyStructAsEmptyMap: hexDecode("a36173a06170f6616e01"),
+				EmptyStructAsNull:     hexDecode("a36173f66170f6616e01"),
+				EmptyStructOmitted:    hexDecode("a26170f6616e01"),
+			},
+		},
+		{
+			name: "nested struct with all fields omitted by omitempty",
+			in:   outerOmitEmptyStruct{},
+			wants: map[EmptyStructMode][]byte{
+				EmptyStructAsEmptyMap: hexDecode("a1616fa0"),
+				EmptyStructAsNull:     hexDecode("a1616ff6"),
+				EmptyStructOmitted:    hexDecode("a0"),
+			},
+		},
+		{
+			name: "empty struct field with omitempty",
+			in:   outerStructWithOmitEmpty{N: 1},
+			wants: map[EmptyStructMode][]byte{
+				EmptyStructAsEmptyMap: hexDecode("a1616e01"),
+				EmptyStructAsNull:     hexDecode("a1616e01"),
+				EmptyStructOmitted:    hexDecode("a1616e01"),
+			},
+		},
+		{
+			name: "empty struct array elements",
+			in:   []emptyStruct{{}},
+			wants: map[EmptyStructMode][]byte{
+				EmptyStructAsEmptyMap: hexDecode("81a0"),
+				EmptyStructAsNull:     hexDecode("81f6"),
+				EmptyStructOmitted:    hexDecode("81a0"),
+			},
+		},
+		{
+			name: "empty struct with toarray option",
+			in:   toArrayStruct{},
+			wants: map[EmptyStructMode][]byte{
+				EmptyStructAsEmptyMap: hexDecode("80"),
+				EmptyStructAsNull:     hexDecode("80"),
+				EmptyStructOmitted:    hexDecode("80"),
+			},
+		},
+	} {
+		for mode, want := range tc.wants {
+			em, err := EncOptions{EmptyStruct: mode}.EncMode()
+			if err != nil {
+				t.Fatalf("EncMode() returned an error %v", err)
+			}
+			got, err := em.Marshal(tc.in)
+			if err != nil {
+				t.Errorf("%s: Marshal(%+v) with EmptyStruct %d returned error %v", tc.name, tc.in, mode, err)
+				continue
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: Marshal(%+v) with EmptyStruct %d = 0x%x, want 0x%x", tc.name, tc.in, mode, got, want)
+			}
+		}
+	}
+}
+
+func TestEncModeInvalidDuration(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         EncOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         EncOptions{Duration: -1},
+			wantErrorMsg: "cbor: invalid Duration -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         EncOptions{Duration: 101},
+			wantErrorMsg: "cbor: invalid Duration 101",
+		},
+		{
+			name:         "DurationExtended with TagsForbidden",
+			opts:         EncOptions{Duration: DurationExtended, TagsMd: TagsForbidden},
+			wantErrorMsg: "cbor: cannot set TagsMd to TagsForbidden when Duration is DurationExtended",
+		},
+		{
+			name:         "TimeExtended with TagsForbidden",
+			opts:         EncOptions{Time: TimeExtended, TagsMd: TagsForbidden},
+			wantErrorMsg: "cbor: cannot set TagsMd to TagsForbidden when Time is TimeExtended",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.EncMode()
+			if err == nil {
+				t.Errorf("EncMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestEncodeDuration(t *testing.T) {
+	type s struct {
+		D time.Duration `cbor:"d,omitempty"`
+	}
+
+	for _, tc := range []struct {
+		name     string
+		mode     DurationMode
+		value    interface{}
+		wantCBOR []byte
+	}{
+		{"nanoseconds", DurationNanoseconds, 1500 * time.Millisecond, hexDecode("1a59682f00")},
+		{"nanoseconds negative", DurationNanoseconds, -time.Nanosecond, hexDecode("20")},
+		{"seconds dynamic whole", DurationSecondsDynamic, 2 * time.Second, hexDecode("02")},
+		{"seconds dynamic fractional", DurationSecondsDynamic, 1500 * time.Millisecond, hexDecode("fb3ff8000000000000")},
+		{"seconds dynamic negative", DurationSecondsDynamic, -1500 * time.Millisecond, hexDecode("fbbff8000000000000")},
+		{"extended whole", DurationExtended, 2 * time.Second, hexDecode("d903eaa10102")},
+		{"extended fractional", DurationExtended, 1500 * time.Millisecond, hexDecode("d903eaa20101281a1dcd6500")},
+		{"extended negative", DurationExtended, -1500 * time.Millisecond, hexDecode("d903eaa20121281a1dcd6500")},
+		{"omitempty", DurationExtended, s{}, hexDecode("a0")},
+		{"struct field", DurationExtended, s{D: time.Second}, hexDecode("a16164d903eaa10101")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := EncOptions{Duration: tc.mode}.EncMode()
+			if err != nil {
+				t.Fatalf("EncMode() returned an error %v", err)
+			}
+			b, err := em.Marshal(tc.value)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned an error %v", tc.value, err)
+			}
+			if !bytes.Equal(b, tc.wantCBOR) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", tc.value, b, tc.wantCBOR)
+			}
+		})
+	}
+}
+
+func TestEncodeExtendedTime(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		opts     EncOptions
+		value    time.Time
+		wantCBOR []byte
+	}{
+		{
+			name:     "whole seconds",
+			opts:     EncOptions{Time: TimeExtended},
+			value:    time.Unix(1700000000, 0),
+			wantCBOR: hexDecode("d903e9a1011a6553f100"),
+		},
+		{
+			name:     "fractional seconds",
+			opts:     EncOptions{Time: TimeExtended},
+			value:    time.Unix(1700000000, 123456789),
+			wantCBOR: hexDecode("d903e9a2011a6553f100281a075bcd15"),
+		},
+		{
+			name:     "before epoch",
+			opts:     EncOptions{Time: TimeExtended},
+			value:    time.Unix(-2, 500000000),
+			wantCBOR: hexDecode("d903e9a20121281a1dcd6500"),
+		},
+		{
+			name:     "TimeTag is ignored",
+			opts:     EncOptions{Time: TimeExtended, TimeTag: EncTagRequired},
+			value:    time.Unix(1700000000, 0),
+			wantCBOR: hexDecode("d903e9a1011a6553f100"),
+		},
+		{
+			name:     "zero time",
+			opts:     EncOptions{Time: TimeExtended},
+			value:    time.Time{},
+			wantCBOR: hexDecode("f6"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := tc.opts.EncMode()
+			if err != nil {
+				t.Fatalf("EncMode() returned an error %v", err)
+			}
+			b, err := em.Marshal(tc.value)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned an error %v", tc.value, err)
+			}
+			if !bytes.Equal(b, tc.wantCBOR) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", tc.value, b, tc.wantCBOR)
+			}
+
+			var tm time.Time
+			if err = Unmarshal(b, &tm); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned an error %v", b, err)
+			}
+			if !tm.Equal(tc.value) {
+				t.Errorf("Unmarshal(0x%x) = %v, want %v", b, tm, tc.value)
+			}
+		})
+	}
+}
+
+func TestEncModeInvalidTimePrecisionAndTimeZone(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         EncOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "TimePrecision below range of valid modes",
+			opts:         EncOptions{TimePrecision: -1},
+			wantErrorMsg: "cbor: invalid TimePrecision -1",
+		},
+		{
+			name:         "TimePrecision above range of valid modes",
+			opts:         EncOptions{TimePrecision: 101},
+			wantErrorMsg: "cbor: invalid TimePrecision 101",
+		},
+		{
+			name:         "TimeZone below range of valid modes",
+			opts:         EncOptions{TimeZone: -1},
+			wantErrorMsg: "cbor: invalid TimeZone -1",
+		},
+		{
+			name:         "TimeZone above range of valid modes",
+			opts:         EncOptions{TimeZone: 101},
+			wantErrorMsg: "cbor: invalid TimeZone 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.EncMode()
+			if err == nil {
+				t.Errorf("EncMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestEncodeTimePrecisionAndTimeZone(t *testing.T) {
+	tm := time.Unix(1363896240, 123456789)
+	tmWithZone := time.Unix(1363896240, 500000000).In(time.FixedZone("", -7*3600))
+
+	for _, tc := range []struct {
+		name     string
+		opts     EncOptions
+		value    time.Time
+		wantCBOR []byte
+	}{
+		{
+			name:     "TimeUnixMicro default precision",
+			opts:     EncOptions{Time: TimeUnixMicro},
+			value:    tm,
+			wantCBOR: hexDecode("fb41d452d9ec07e6b8"), // 1363896240.123457
+		},
+		{
+			name:     "TimeUnixMicro second precision",
+			opts:     EncOptions{Time: TimeUnixMicro, TimePrecision: TimePrecisionSecond},
+			value:    tm,
+			wantCBOR: hexDecode("fb41d452d9ec000000"), // 1363896240.0
+		},
+		{
+			name:     "TimeUnixMicro millisecond precision",
+			opts:     EncOptions{Time: TimeUnixMicro, TimePrecision: TimePrecisionMillisecond},
+			value:    tm,
+			wantCBOR: hexDecode("fb41d452d9ec07df3c"), // 1363896240.123
+		},
+		{
+			name:     "TimeUnixMicro nanosecond precision",
+			opts:     EncOptions{Time: TimeUnixMicro, TimePrecision: TimePrecisionNanosecond},
+			value:    tm,
+			wantCBOR: hexDecode("fb41d452d9ec07e6b7"), // 1363896240.1234567
+		},
+		{
+			name:     "TimeUnixDynamic second precision",
+			opts:     EncOptions{Time: TimeUnixDynamic, TimePrecision: TimePrecisionSecond},
+			value:    tm,
+			wantCBOR: hexDecode("1a514b67b0"), // 1363896240
+		},
+		{
+			name:     "TimeUnixDynamic millisecond precision",
+			opts:     EncOptions{Time: TimeUnixDynamic, TimePrecision: TimePrecisionMillisecond},
+			value:    tm,
+			wantCBOR: hexDecode("fb41d452d9ec07df3b"), // 1363896240.123
+		},
+		{
+			name:     "TimeRFC3339 preserve time zone",
+			opts:     EncOptions{Time: TimeRFC3339},
+			value:    tmWithZone,
+			wantCBOR: hexDecode("7819323031332d30332d32315431333a30343a30302d30373a3030"), // "2013-03-21T13:04:00-07:00"
+		},
+		{
+			name:     "TimeRFC3339 UTC",
+			opts:     EncOptions{Time: TimeRFC3339, TimeZone: TimeZoneUTC},
+			value:    tmWithZone,
+			wantCBOR: hexDecode("74323031332d30332d32315432303a30343a30305a"), // "2013-03-21T20:04:00Z"
+		},
+		{
+			name:     "TimeRFC3339Nano preserve time zone",
+			opts:     EncOptions{Time: TimeRFC3339Nano},
+			value:    tmWithZone,
+			wantCBOR: hexDecode("781b323031332d30332d32315431333a30343a30302e352d30373a3030"), // "2013-03-21T13:04:00.5-07:00"
+		},
+		{
+			name:     "TimeRFC3339Nano UTC",
+			opts:     EncOptions{Time: TimeRFC3339Nano, TimeZone: TimeZoneUTC},
+			value:    tmWithZone,
+			wantCBOR: hexDecode("76323031332d30332d32315432303a30343a30302e355a"), // "2013-03-21T20:04:00.5Z"
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := tc.opts.EncMode()
+			if err != nil {
+				t.Fatalf("EncMode() returned an error %v", err)
+			}
+			b, err := em.Marshal(tc.value)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned an error %v", tc.value, err)
+			}
+			if !bytes.Equal(b, tc.wantCBOR) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", tc.value, b, tc.wantCBOR)
+			}
+		})
+	}
+}
+
+type canMarshalTestMarshaler int
+
+func (m canMarshalTestMarshaler) MarshalCBOR() ([]byte, error) {
+	if m < 0 {
+		return nil, errors.New("negative value")
+	}
+	return Marshal(int(m))
+}
+
+func TestCanMarshal(t *testing.T) {
+	type inner struct {
+		F float64
+	}
+	type embedded struct {
+		C chan int
+	}
+	type outer struct {
+		A int
+		B []inner
+		M map[string]interface{}
+		P *canMarshalTestMarshaler
+		I interface{}
+	}
+	negative := canMarshalTestMarshaler(-1)
+
+	for _, tc := range []struct {
+		name         string
+		opts         EncOptions
+		value        interface{}
+		wantPath     string
+		wantErrorMsg string
+		moreSpecific bool // error is more specific than error returned by Marshal
+	}{
+		{
+			name: "valid",
+			value: outer{
+				A: 1,
+				B: []inner{{F: 1.5}, {F: math.NaN()}},
+				M: map[string]interface{}{"a": []interface{}{1, "b"}},
+			},
+		},
+		{
+			name:  "nil",
+			value: nil,
+		},
+		{
+			name:         "unsupported top-level type",
+			value:        make(chan int),
+			wantPath:     "",
+			wantErrorMsg: "cbor: cannot marshal value: unsupported type: chan int",
+		},
+		{
+			name:         "unsupported element type of empty slice",
+			value:        []chan int{},
+			wantPath:     "",
+			wantErrorMsg: "cbor: cannot marshal value: unsupported type: []chan int",
+		},
+		{
+			name:         "unsupported struct field type",
+			value:        struct{ A, C interface{} }{A: 1, C: make(chan int)},
+			wantPath:     ".C",
+			wantErrorMsg: "cbor: cannot marshal value at .C: unsupported type: chan int",
+		},
+		{
+			name:         "unsupported embedded struct field type",
+			value:        struct{ embedded }{},
+			wantPath:     ".C",
+			wantErrorMsg: "cbor: cannot marshal value at .C: unsupported type: chan int",
+			moreSpecific: true,
+		},
+		{
+			name:         "unsupported value in slice",
+			opts:         EncOptions{NaNConvert: NaNConvertReject},
+			value:        outer{B: []inner{{F: 1.5}, {F: math.NaN()}}},
+			wantPath:     ".B[1].F",
+			wantErrorMsg: "cbor: cannot marshal value at .B[1].F: unsupported value: floating-point NaN",
+		},
+		{
+			name:         "unsupported value in map",
+			value:        outer{M: map[string]interface{}{"a": []interface{}{1, make(chan int)}}},
+			wantPath:     `.M["a"][1]`,
+			wantErrorMsg: `cbor: cannot marshal value at .M["a"][1]: unsupported type: chan int`,
+		},
+		{
+			name:         "MarshalCBOR error",
+			value:        outer{P: &negative},
+			wantPath:     ".P",
+			wantErrorMsg: "cbor: cannot marshal value at .P: negative value",
+		},
+		{
+			name:         "interface",
+			value:        outer{I: []interface{}{negative}},
+			wantPath:     ".I[0]",
+			wantErrorMsg: "cbor: cannot marshal value at .I[0]: negative value",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := tc.opts.EncMode()
+			if err != nil {
+				t.Fatalf("EncMode() returned an error %v", err)
+			}
+			_, marshalErr := em.Marshal(tc.value)
+
+			err = em.(ExtendedEncMode).CanMarshal(tc.value)
+			if tc.wantErrorMsg == "" {
+				if err != nil {
+					t.Errorf("CanMarshal(%v) returned an error %v", tc.value, err)
+				}
+				if marshalErr != nil {
+					t.Errorf("Marshal(%v) returned an error %v", tc.value, marshalErr)
+				}
+				return
+			}
+			if marshalErr == nil {
+				t.Errorf("Marshal(%v) didn't return an error", tc.value)
+			}
+			if err == nil {
+				t.Fatalf("CanMarshal(%v) didn't return an error", tc.value)
+			}
+			if err.Error() != tc.wantErrorMsg {
+				t.Errorf("CanMarshal(%v) returned error %q, want %q", tc.value, err.Error(), tc.wantErrorMsg)
+			}
+			var pathErr *MarshalPathError
+			if !errors.As(err, &pathErr) {
+				t.Fatalf("CanMarshal(%v) returned wrong error type %T, want (*MarshalPathError)", tc.value, err)
+			}
+			if pathErr.Path != tc.wantPath {
+				t.Errorf("CanMarshal(%v) returned error with path %q, want %q", tc.value, pathErr.Path, tc.wantPath)
+			}
+			if marshalErr != nil && !tc.moreSpecific && pathErr.Err.Error() != marshalErr.Error() {
+				t.Errorf("CanMarshal(%v) returned error %q, want error %q returned by Marshal", tc.value, pathErr.Err.Error(), marshalErr.Error())
+			}
+		})
+	}
+}
+
+type omitZeroValueReceiver struct{ V int }
+
+func (z omitZeroValueReceiver) IsZero() bool { return z.V == 1 }
+
+type omitZeroPointerReceiver struct{ V int }
+
+func (z *omitZeroPointerReceiver) IsZero() bool { return z.V == 1 }
+
+func TestOmitZero(t *testing.T) {
+	type inner struct {
+		A int
+	}
+	type T struct {
+		Time    time.Time               `cbor:"t,omitzero"`
+		TimePtr *time.Time              `cbor:"tp,omitzero"`
+		S       inner                   `cbor:"s,omitzero"`
+		Slice   []int                   `cbor:"sl,omitzero"`
+		Arr     [2]int                  `cbor:"a,omitzero"`
+		I       int                     `cbor:"i,omitzero"`
+		C       omitZeroValueReceiver   `cbor:"c,omitzero"`
+		P       omitZeroPointerReceiver `cbor:"p,omitzero"`
+	}
+	type onlyZeroFields struct {
+		Time time.Time `cbor:"t,omitzero"`
+	}
+	type outer struct {
+		Z onlyZeroFields `cbor:"z,omitempty"`
+	}
+
+	zeroT := T{Slice: []int{}, C: omitZeroValueReceiver{1}, P: omitZeroPointerReceiver{1}}
+
+	for _, tc := range []struct {
+		name     string
+		value    interface{}
+		wantCBOR []byte
+	}{
+		{
+			name:  "zero values",
+			value: zeroT,
+			// {"sl": [], "p": {"V": 1}}
+			// P isn't addressable, so IsZero() with pointer receiver isn't used.
+			wantCBOR: hexDecode("a262736c806170a1615601"),
+		},
+		{
+			name:  "zero values through pointer",
+			value: &zeroT,
+			// {"sl": []}
+			wantCBOR: hexDecode("a162736c80"),
+		},
+		{
+			name: "non-zero values",
+			value: &T{
+				Time: time.Unix(1, 0),
+				S:    inner{A: 1},
+				Arr:  [2]int{0, 1},
+				I:    1,
+				C:    omitZeroValueReceiver{0},
+				P:    omitZeroPointerReceiver{0},
+			},
+			// {"t": 1, "s": {"A": 1}, "a": [0, 1], "i": 1, "c": {"V": 0}, "p": {"V": 0}}
+			wantCBOR: hexDecode("a66174016173a161410161618200016169016163a16156006170a1615600"),
+		},
+		{
+			name:     "struct with only omitted fields is empty",
+			value:    outer{},
+			wantCBOR: hexDecode("a0"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := Marshal(tc.value)
+			if err != nil {
+				t.Fatalf("Marshal(%+v) returned an error %v", tc.value, err)
+			}
+			if !bytes.Equal(b, tc.wantCBOR) {
+				t.Errorf("Marshal(%+v) = 0x%x, want 0x%x", tc.value, b, tc.wantCBOR)
+			}
+		})
+	}
+}
+
+func TestEncModeInvalidDCBOROptions(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         EncOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "NumericReduction below range of valid modes",
+			opts:         EncOptions{NumericReduction: -1},
+			wantErrorMsg: "cbor: invalid NumericReduction -1",
+		},
+		{
+			name:         "NumericReduction above range of valid modes",
+			opts:         EncOptions{NumericReduction: 101},
+			wantErrorMsg: "cbor: invalid NumericReduction 101",
+		},
+		{
+			name:         "SimpleValues below range of valid modes",
+			opts:         EncOptions{SimpleValues: -1},
+			wantErrorMsg: "cbor: invalid SimpleValues -1",
+		},
+		{
+			name:         "SimpleValues above range of valid modes",
+			opts:         EncOptions{SimpleValues: 101},
+			wantErrorMsg: "cbor: invalid SimpleValues 101",
+		},
+		{
+			name:         "NegativeInt65 below range of valid modes",
+			opts:         EncOptions{NegativeInt65: -1},
+			wantErrorMsg: "cbor: invalid NegativeInt65 -1",
+		},
+		{
+			name:         "NegativeInt65 above range of valid modes",
+			opts:         EncOptions{NegativeInt65: 101},
+			wantErrorMsg: "cbor: invalid NegativeInt65 101",
+		},
+		{
+			name:         "NumericDate below range of valid modes",
+			opts:         EncOptions{NumericDate: -1},
+			wantErrorMsg: "cbor: invalid NumericDate -1",
+		},
+		{
+			name:         "NumericDate above range of valid modes",
+			opts:         EncOptions{NumericDate: 101},
+			wantErrorMsg: "cbor: invalid NumericDate 101",
+		},
+		{
+			name:         "Float below range of valid modes",
+			opts:         EncOptions{Float: -1},
+			wantErrorMsg: "cbor: invalid Float -1",
+		},
+		{
+			name:         "Float above range of valid modes",
+			opts:         EncOptions{Float: 101},
+			wantErrorMsg: "cbor: invalid Float 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.EncMode()
+			if err == nil {
+				t.Errorf("EncMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDCBOREncOptions(t *testing.T) {
+	em, err := DCBOREncOptions().EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned an error %v", err)
+	}
+
+	minInt65 := new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 64))      // -2^64
+	maxInt65 := new(big.Int).Sub(big.NewInt(math.MinInt64), big.NewInt(1)) // -2^63-1
+
+	testCases := []struct {
+		name         string
+		v            interface{}
+		want         []byte
+		wantErrorMsg string
+	}{
+		{name: "float64 1.0", v: 1.0, want: hexDecode("01")},
+		{name: "float64 -1.0", v: -1.0, want: hexDecode("20")},
+		{name: "float64 -0.0", v: math.Copysign(0, -1), want: hexDecode("00")},
+		{name: "float32 100000.0", v: float32(100000), want: hexDecode("1a000186a0")},
+		{name: "float64 1e19", v: 1e19, want: hexDecode("1b8ac7230489e80000")},
+		{name: "float64 -2^63", v: -9223372036854775808.0, want: hexDecode("3b7fffffffffffffff")},
+		{name: "float64 2^64", v: 18446744073709551616.0, want: hexDecode("fa5f800000")},
+		{name: "float64 -2^64", v: -18446744073709551616.0, want: hexDecode("fadf800000")},
+		{name: "float64 1.5", v: 1.5, want: hexDecode("f93e00")},
+		{name: "float64 NaN", v: math.NaN(), want: hexDecode("f97e00")},
+		{name: "float64 infinity", v: math.Inf(-1), want: hexDecode("f9fc00")},
+		{name: "false", v: false, want: hexDecode("f4")},
+		{name: "null", v: nil, want: hexDecode("f6")},
+		{name: "SimpleValue true", v: SimpleValue(21), want: hexDecode("f5")},
+		{name: "undefined", v: SimpleValue(23), wantErrorMsg: "cbor: unsupported value: simple value 23"},
+		{name: "simple value 16", v: SimpleValue(16), wantErrorMsg: "cbor: unsupported value: simple value 16"},
+		{name: "big.Int -2^63", v: big.NewInt(math.MinInt64), want: hexDecode("3b7fffffffffffffff")},
+		{name: "big.Int -2^63-1", v: maxInt65, wantErrorMsg: "cbor: unsupported value: 65-bit negative integer"},
+		{name: "big.Int -2^64", v: minInt65, wantErrorMsg: "cbor: unsupported value: 65-bit negative integer"},
+		{name: "map", v: map[interface{}]interface{}{"a": 1.0, 10: []float64{2.5, 3}}, want: hexDecode("a20a82f9410003616101")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := em.Marshal(tc.v)
+			if tc.wantErrorMsg != "" {
+				if err == nil {
+					t.Errorf("Marshal(%v) didn't return an error, want %q", tc.v, tc.wantErrorMsg)
+				} else if err.Error() != tc.wantErrorMsg {
+					t.Errorf("Marshal(%v) returned error %q, want %q", tc.v, err.Error(), tc.wantErrorMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned error %v", tc.v, err)
+			}
+			if !bytes.Equal(b, tc.want) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", tc.v, b, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncModeWithOptions(t *testing.T) {
+	type myInt int
+
+	tags := NewTagSet()
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagRequired}, reflect.TypeOf(myInt(0)), 100); err != nil {
+		t.Fatalf("TagSet.Add() returned error %v", err)
+	}
+	em, err := EncOptions{Sort: SortNone}.EncModeWithTags(tags)
+	if err != nil {
+		t.Fatalf("EncModeWithTags() returned error %v", err)
+	}
+
+	derived, err := em.(ExtendedEncMode).WithOptions(func(opts *EncOptions) {
+		opts.Sort = SortLengthFirst
+	})
+	if err != nil {
+		t.Fatalf("WithOptions() returned error %v", err)
+	}
+	if sort := derived.EncOptions().Sort; sort != SortLengthFirst {
+		t.Errorf("WithOptions() returned EncMode with Sort %d, want %d", sort, SortLengthFirst)
+	}
+	if sort := em.EncOptions().Sort; sort != SortNone {
+		t.Errorf("WithOptions() modified original EncMode Sort to %d, want %d", sort, SortNone)
+	}
+
+	// Derived mode keeps tags of the original mode.
+	v := map[int]myInt{10: 1, 1: 2}
+	want := hexDecode("a201d864020ad86401") // {1: 100(2), 10: 100(1)}
+	b, err := derived.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(%v) returned error %v", v, err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("Marshal(%v) = 0x%x, want 0x%x", v, b, want)
+	}
+
+	// Derived mode keeps tags for nil slices in struct fields with "nilasempty" option,
+	// and for data items returned by MarshalerWithMode.
+	type mySlice []int
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagRequired}, reflect.TypeOf(mySlice(nil)), 101); err != nil {
+		t.Fatalf("TagSet.Add() returned error %v", err)
+	}
+	em, err = EncOptions{}.EncModeWithSharedTags(tags)
+	if err != nil {
+		t.Fatalf("EncModeWithSharedTags() returned error %v", err)
+	}
+	derived, err = em.(ExtendedEncMode).WithOptions(func(opts *EncOptions) {
+		opts.NilContainers = NilContainerAsNull
+		opts.SelfDescribedCBOR = SelfDescribedCBOREachItem
+	})
+	if err != nil {
+		t.Fatalf("WithOptions() returned error %v", err)
+	}
+	v2 := struct {
+		A mySlice `cbor:"a,nilasempty"`
+		B EncodedItem
+	}{B: EncodedItem{Value: myInt(1)}}
+	want = hexDecode("d9d9f7a26161d865806142d81843d86401") // 55799({"a": 101([]), "B": 24(h'd86401')})
+	b, err = derived.Marshal(v2)
+	if err != nil {
+		t.Fatalf("Marshal(%v) returned error %v", v2, err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("Marshal(%v) = 0x%x, want 0x%x", v2, b, want)
+	}
+
+	for _, tc := range []struct {
+		name         string
+		fn           func(opts *EncOptions)
+		wantErrorMsg string
+	}{
+		{
+			name:         "invalid option",
+			fn:           func(opts *EncOptions) { opts.Sort = -1 },
+			wantErrorMsg: "cbor: invalid SortMode -1",
+		},
+		{
+			name:         "tags forbidden",
+			fn:           func(opts *EncOptions) { opts.TagsMd = TagsForbidden },
+			wantErrorMsg: "cbor: cannot create EncMode with TagSet when TagsMd is TagsForbidden",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := em.(ExtendedEncMode).WithOptions(tc.fn)
+			if err == nil {
+				t.Errorf("WithOptions() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("WithOptions() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+type marshalerFloat struct{}
+
+func (marshalerFloat) MarshalCBOR() ([]byte, error) {
+	return hexDecode("8201f93e00"), nil // [1, 1.5]
+}
+
+func TestMarshalFloatForbidden(t *testing.T) {
+	em, err := EncOptions{Float: FloatForbidden}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		v    interface{}
+	}{
+		{"float32", float32(1.5)},
+		{"float64", float64(1.5)},
+		{"float64 NaN", math.NaN()},
+		{"float64 Inf", math.Inf(1)},
+		{"float in slice", []float64{1, 2.5}},
+		{"time", time.Unix(1, 500000000)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := em.EncOptions()
+			if tc.name == "time" {
+				opts.Time = TimeUnixDynamic
+			}
+			em, err := opts.EncMode()
+			if err != nil {
+				t.Fatalf("EncMode() returned error %v", err)
+			}
+
+			wantErrorMsg := "cbor: unsupported value: floating-point number"
+			b, err := em.Marshal(tc.v)
+			var uerr *UnsupportedValueError
+			if !errors.As(err, &uerr) {
+				t.Errorf("Marshal(%v) = 0x%x, %v (%T), want *UnsupportedValueError", tc.v, b, err, err)
+			} else if err.Error() != wantErrorMsg {
+				t.Errorf("Marshal(%v) returned error %q, want %q", tc.v, err.Error(), wantErrorMsg)
+			}
+		})
+	}
+
+	// Data returned by Marshaler is rejected if it contains floating-point numbers.
+	_, err = em.Marshal(marshalerFloat{})
+	var merr *MarshalerError
+	if !errors.As(err, &merr) {
+		t.Errorf("Marshal() returned error %v (%T), want *MarshalerError", err, err)
+	}
+	if b, err := Marshal(marshalerFloat{}); err != nil {
+		t.Errorf("Marshal() returned error %v", err)
+	} else if want := hexDecode("8201f93e00"); !bytes.Equal(b, want) {
+		t.Errorf("Marshal() = 0x%x, want 0x%x", b, want)
+	}
+
+	// Integral floating-point values are encoded as integers with NumericReductionFloatToInt.
+	emReduction, err := EncOptions{Float: FloatForbidden, NumericReduction: NumericReductionFloatToInt}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	if b, err := emReduction.Marshal(float64(2)); err != nil {
+		t.Errorf("Marshal() returned error %v", err)
+	} else if want := hexDecode("02"); !bytes.Equal(b, want) {
+		t.Errorf("Marshal() = 0x%x, want 0x%x", b, want)
+	}
+}
+
+func TestEncodeHook(t *testing.T) {
+	type secret string
+	type item struct {
+		Name  string
+		Price int                    // in cents
+		Attrs map[string]interface{} `cbor:",omitempty"`
+	}
+	type order struct {
+		Token secret
+		Items []item
+		Note  interface{}
+		Extra map[string]RawMessage `cbor:",unknown"`
+	}
+
+	var paths []string
+	hook := func(path string, v reflect.Value) (interface{}, bool) {
+		paths = append(paths, path)
+		switch {
+		case v.Type() == reflect.TypeOf(secret("")):
+			return "***", true
+		case strings.HasSuffix(path, ".Price"):
+			return float64(v.Int()) / 100, true
+		case v.Kind() == reflect.String && v.String() == "replace":
+			return []int{1, 2}, true
+		}
+		return nil, false
+	}
+	em, err := EncOptions{EncodeHook: NewEncodeHook(hook), ShortestFloat: ShortestFloat16}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	if em.EncOptions().EncodeHook == nil {
+		t.Errorf("EncOptions() returned nil EncodeHook")
+	}
+
+	v := order{
+		Token: "abc",
+		Items: []item{{Name: "a", Price: 150, Attrs: map[string]interface{}{"k": "replace"}}},
+		Note:  secret("xyz"),
+		Extra: map[string]RawMessage{"x": hexDecode("01")},
+	}
+	type itemWant struct {
+		Name  string
+		Price float64
+		Attrs map[string]interface{}
+	}
+	want := struct {
+		Token string
+		Items []itemWant
+		Note  string
+		X     int `cbor:"x"`
+	}{
+		Token: "***",
+		Items: []itemWant{{Name: "a", Price: 1.5, Attrs: map[string]interface{}{"k": []int{1, 2}}}},
+		Note:  "***",
+		X:     1,
+	}
+	wantPaths := []string{
+		"",
+		".Token",
+		".Items",
+		".Items[0]",
+		".Items[0].Name",
+		".Items[0].Price",
+		".Items[0].Attrs",
+		".Items[0].Attrs[\"k\"]",
+		".Items[0].Attrs[\"k\"][0]",
+		".Items[0].Attrs[\"k\"][1]",
+		".Note",
+		".Extra[\"x\"]",
+	}
+
+	b, err := em.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("EncodeHook called with paths %q, want %q", paths, wantPaths)
+	}
+
+	emNoHook, err := EncOptions{ShortestFloat: ShortestFloat16}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	wantData, err := emNoHook.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+	if !bytes.Equal(b, wantData) {
+		t.Errorf("Marshal() = 0x%x, want 0x%x", b, wantData)
+	}
+
+	// Hook is applied by Encoder.Encode, and path state isn't shared between calls.
+	paths = nil
+	var buf bytes.Buffer
+	if err = em.NewEncoder(&buf).Encode(secret("abc")); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+	if want := hexDecode("632a2a2a"); !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Encode() = 0x%x, want 0x%x", buf.Bytes(), want)
+	}
+	if !reflect.DeepEqual(paths, []string{""}) {
+		t.Errorf("EncodeHook called with paths %q, want %q", paths, []string{""})
+	}
+
+	// Encoding without hook is unaffected.
+	b, err = Marshal(secret("abc"))
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+	if want := hexDecode("63616263"); !bytes.Equal(b, want) {
+		t.Errorf("Marshal() = 0x%x, want 0x%x", b, want)
+	}
+}
+
+func TestMarshalStringChunkSize(t *testing.T) {
+	type s struct {
+		S string `cbor:"s"`
+	}
+
+	testCases := []struct {
+		name         string
+		opts         EncOptions
+		value        interface{}
+		wantCborData []byte
+	}{
+		{
+			name:         "byte slice not longer than chunk size",
+			opts:         EncOptions{StringChunkSize: 5},
+			value:        []byte{1, 2, 3, 4, 5},
+			wantCborData: hexDecode("450102030405"),
+		},
+		{
+			name:         "byte slice longer than chunk size",
+			opts:         EncOptions{StringChunkSize: 2},
+			value:        []byte{1, 2, 3, 4, 5},
+			wantCborData: hexDecode("5f4201024203044105ff"),
+		},
+		{
+			name:         "byte array longer than chunk size",
+			opts:         EncOptions{StringChunkSize: 2, ByteArray: ByteArrayToByteSlice},
+			value:        [5]byte{1, 2, 3, 4, 5},
+			wantCborData: hexDecode("5f4201024203044105ff"),
+		},
+		{
+			name:         "text string not longer than chunk size",
+			opts:         EncOptions{StringChunkSize: 2},
+			value:        "ab",
+			wantCborData: hexDecode("626162"),
+		},
+		{
+			name:         "text string longer than chunk size",
+			opts:         EncOptions{StringChunkSize: 2},
+			value:        "hello",
+			wantCborData: hexDecode("7f626865626c6c616fff"),
+		},
+		{
+			name:         "text string chunked at UTF-8 character boundary",
+			opts:         EncOptions{StringChunkSize: 2},
+			value:        "aé",
+			wantCborData: hexDecode("7f616162c3a9ff"),
+		},
+		{
+			name:         "text string with character longer than chunk size",
+			opts:         EncOptions{StringChunkSize: 1},
+			value:        "éa",
+			wantCborData: hexDecode("7f62c3a96161ff"),
+		},
+		{
+			name:         "string encoded as byte string",
+			opts:         EncOptions{StringChunkSize: 2, String: StringToByteString},
+			value:        "hello",
+			wantCborData: hexDecode("5f426865426c6c416fff"),
+		},
+		{
+			name:         "struct field name isn't chunked",
+			opts:         EncOptions{StringChunkSize: 1},
+			value:        s{S: "ab"},
+			wantCborData: hexDecode("a161737f61616162ff"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := tc.opts.EncMode()
+			if err != nil {
+				t.Fatalf("EncMode() returned error %v", err)
+			}
+			b, err := em.Marshal(tc.value)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned error %v", tc.value, err)
+			}
+			if !bytes.Equal(b, tc.wantCborData) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", tc.value, b, tc.wantCborData)
+			}
+			if got := em.EncOptions().StringChunkSize; got != tc.opts.StringChunkSize {
+				t.Errorf("EncOptions().StringChunkSize = %d, want %d", got, tc.opts.StringChunkSize)
+			}
+		})
+	}
+}
+
+func TestEncModeInvalidStringChunkSize(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         EncOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "negative chunk size",
+			opts:         EncOptions{StringChunkSize: -1},
+			wantErrorMsg: "cbor: invalid StringChunkSize -1 (range is [0, 2147483647])",
+		},
+		{
+			name:         "indefinite length forbidden",
+			opts:         EncOptions{StringChunkSize: 16, IndefLength: IndefLengthForbidden},
+			wantErrorMsg: "cbor: cannot set IndefLength to IndefLengthForbidden when StringChunkSize is set",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.EncMode()
+			if err == nil {
+				t.Errorf("EncMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestNilContainersFieldOption(t *testing.T) {
+	type T struct {
+		A []int          `cbor:"a,nilasempty"`
+		B map[string]int `cbor:"b,nilasnull"`
+		C []byte         `cbor:"c,nilasempty"`
+		D []int          `cbor:"d"`
+	}
+	type U struct {
+		_ struct{} `cbor:",toarray"`
+		A []int    `cbor:",nilasempty"`
+		B []int    `cbor:",nilasnull"`
+	}
+
+	nilContainersNull := EncOptions{NilContainers: NilContainerAsNull}
+	nilContainersEmpty := EncOptions{NilContainers: NilContainerAsEmpty}
+
+	testCases := []struct {
+		name         string
+		v            interface{}
+		opts         EncOptions
+		wantCborData []byte
+	}{
+		{
+			name:         "nil fields with NilContainerAsNull",
+			v:            T{},
+			opts:         nilContainersNull,
+			wantCborData: hexDecode("a46161806162f66163406164f6"), // {"a": [], "b": null, "c": h'', "d": null}
+		},
+		{
+			name:         "nil fields with NilContainerAsEmpty",
+			v:            T{},
+			opts:         nilContainersEmpty,
+			wantCborData: hexDecode("a46161806162f6616340616480"), // {"a": [], "b": null, "c": h'', "d": []}
+		},
+		{
+			name:         "non-nil fields",
+			v:            T{A: []int{1}, B: map[string]int{"x": 1}},
+			opts:         nilContainersNull,
+			wantCborData: hexDecode("a4616181016162a16178016163406164f6"), // {"a": [1], "b": {"x": 1}, "c": h'', "d": null}
+		},
+		{
+			name:         "toarray struct with NilContainerAsNull",
+			v:            U{},
+			opts:         nilContainersNull,
+			wantCborData: hexDecode("8280f6"), // [[], null]
+		},
+		{
+			name:         "toarray struct with NilContainerAsEmpty",
+			v:            U{},
+			opts:         nilContainersEmpty,
+			wantCborData: hexDecode("8280f6"), // [[], null]
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := tc.opts.EncMode()
+			if err != nil {
+				t.Fatalf("EncMode() returned an error %v", err)
+			}
+			b, err := em.Marshal(tc.v)
+			if err != nil {
+				t.Errorf("Marshal(%+v) returned error %v", tc.v, err)
+			} else if !bytes.Equal(b, tc.wantCborData) {
+				t.Errorf("Marshal(%+v) = 0x%x, want 0x%x", tc.v, b, tc.wantCborData)
+			}
+		})
+	}
+
+	// Encoding nil fields with options different from EncOptions.NilContainers doesn't allocate.
+	em, err := nilContainersEmpty.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned an error %v", err)
+	}
+	buf := make([]byte, 0, 64)
+	var v interface{} = T{}
+	allocs := testing.AllocsPerRun(10, func() {
+		buf, _ = em.(ExtendedEncMode).MarshalAppend(buf[:0], v)
+	})
+	if allocs != 0 {
+		t.Errorf("MarshalAppend() allocated %v times, want 0", allocs)
+	}
+}
+
+func TestNilContainersFieldOptionWithTags(t *testing.T) {
+	type S []int
+	type T struct {
+		A S `cbor:"a,nilasempty"`
+	}
+
+	tags := NewTagSet()
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagRequired}, reflect.TypeOf(S(nil)), 1234); err != nil {
+		t.Fatalf("TagSet.Add() returned error %v", err)
+	}
+	opts := EncOptions{NilContainers: NilContainerAsNull}
+
+	em, err := opts.EncModeWithTags(tags)
+	if err != nil {
+		t.Fatalf("EncModeWithTags() returned error %v", err)
+	}
+	sharedEM, err := opts.EncModeWithSharedTags(tags)
+	if err != nil {
+		t.Fatalf("EncModeWithSharedTags() returned error %v", err)
+	}
+
+	for _, tc := range []struct {
+		name         string
+		v            T
+		wantCborData []byte
+	}{
+		{
+			name:         "nil field",
+			v:            T{},
+			wantCborData: hexDecode("a16161d904d280"), // {"a": 1234([])}
+		},
+		{
+			name:         "empty field",
+			v:            T{A: S{}},
+			wantCborData: hexDecode("a16161d904d280"), // {"a": 1234([])}
+		},
+	} {
+		for _, em := range []EncMode{em, sharedEM} {
+			b, err := em.Marshal(tc.v)
+			if err != nil {
+				t.Errorf("%s: Marshal(%+v) returned error %v", tc.name, tc.v, err)
+			} else if !bytes.Equal(b, tc.wantCborData) {
+				t.Errorf("%s: Marshal(%+v) = 0x%x, want 0x%x", tc.name, tc.v, b, tc.wantCborData)
+			}
+		}
+	}
+}
+
+func TestNilContainersFieldOptionError(t *testing.T) {
+	type T1 struct {
+		A int `cbor:"a,nilasempty"`
+	}
+	type T2 struct {
+		A []int `cbor:"a,nilasempty,nilasnull"`
+	}
+
+	testCases := []struct {
+		name         string
+		v            interface{}
+		wantErrorMsg string
+	}{
+		{
+			name:         "not slice or map",
+			v:            T1{},
+			wantErrorMsg: "cbor: field cbor.T1.a with \"nilasempty\" option must be a slice or map, got int",
+		},
+		{
+			name:         "both options",
+			v:            T2{},
+			wantErrorMsg: "cbor: field cbor.T2.a can't have both \"nilasempty\" and \"nilasnull\" options",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Marshal(tc.v)
+			if err == nil {
+				t.Errorf("Marshal(%+v) didn't return an error", tc.v)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Marshal(%+v) returned error %q, want %q", tc.v, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// EncodedItem is a Go value encoded as CBOR tag 24 (encoded CBOR data item), defined
+// in RFC 8949 Section 3.4.5.1.  Tag content is a byte string containing the CBOR
+// encoding of Value.  Protocols such as COSE and ISO/IEC 18013-5 (mdoc) use it to
+// embed CBOR data items that are signed or hashed in their encoded form.
+//
+// EncodedItem is encoded using the caller's encoding mode, both for the enclosed data
+// item and for the enclosing tag (see EncOptions.EncodedItem).
+//
+// When decoding to EncodedItem, tag 24 is optional.  If Value is a non-nil pointer,
+// the enclosed data item is decoded into the value it points to, using the caller's
+// decoding mode.  Otherwise, Value is set to a RawMessage containing the enclosed
+// data item, which can be decoded later.  Decoding CBOR null or undefined is no-op.
+type EncodedItem struct {
+	Value interface{}
+}
+
+var typeEncodedItem = reflect.TypeOf(EncodedItem{})
+
+// MarshalCBOR encodes ei using default encoding options.
+func (ei EncodedItem) MarshalCBOR() ([]byte, error) {
+	return ei.MarshalCBORWithMode(defaultEncMode)
+}
+
+// MarshalCBORWithMode encodes Value using em encoding mode, and returns the encoded
+// data item enclosed in a definite-length byte string, with or without tag 24
+// depending on the EncodedItem option of em.
+func (ei EncodedItem) MarshalCBORWithMode(em EncMode) ([]byte, error) {
+	if em == nil {
+		em = defaultEncMode
+	}
+	b, err := marshalNested(em, ei.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	e := getEncodeBuffer()
+	defer putEncodeBuffer(e)
+
+	if encodedItemMode(em) == EncodedItemToTag {
+		encodeHead(e, byte(cborTypeTag), tagNumEncodedCBORDataItem)
+	}
+	encodeHead(e, byte(cborTypeByteString), uint64(len(b)))
+	e.Write(b)
+
+	buf := make([]byte, e.Len())
+	copy(buf, e.Bytes())
+	return buf, nil
+}
+
+// encodedItemMode returns the EncodedItem option of em, without copying encoding
+// options of em if it is created by this package.
+func encodedItemMode(em EncMode) EncodedItemMode {
+	if iem, ok := em.(*encMode); ok {
+		return iem.encodedItem
+	}
+	return em.EncOptions().EncodedItem
+}
+
+// UnmarshalCBOR decodes data to ei using default decoding options.
+func (ei *EncodedItem) UnmarshalCBOR(data []byte) error {
+	return ei.UnmarshalCBORWithMode(data, defaultDecMode)
+}
+
+// UnmarshalCBORWithMode decodes tag 24 (or byte string without tag 24) in data to ei,
+// decoding the enclosed data item using dm decoding mode.
+func (ei *EncodedItem) UnmarshalCBORWithMode(data []byte, dm DecMode) error {
+	if ei == nil {
+		return errors.New("cbor.EncodedItem: UnmarshalCBOR on nil pointer")
+	}
+	if dm == nil {
+		dm = defaultDecMode
+	}
+
+	d := decoder{data: data, dm: defaultDecMode}
+	if err := d.wellformed(false, false); err != nil {
+		return err
+	}
+	d.reset(data)
+
+	if d.nextCBORNil() {
+		return nil
+	}
+
+	if d.nextCBORType() == cborTypeTag {
+		_, _, tagNum := d.getHead()
+		if tagNum != tagNumEncodedCBORDataItem {
+			return &UnmarshalTypeError{
+				CBORType: cborTypeTag.String(),
+				GoType:   typeEncodedItem.String(),
+				errorMsg: "expect tag number " + strconv.Itoa(tagNumEncodedCBORDataItem) + ", got " + strconv.FormatUint(tagNum, 10),
+			}
+		}
+	}
+
+	if t := d.nextCBORType(); t != cborTypeByteString {
+		return &UnmarshalTypeError{CBORType: t.String(), GoType: typeEncodedItem.String()}
+	}
+	b, _ := d.parseByteString()
+
+	if ei.Value != nil {
+		if rv := reflect.ValueOf(ei.Value); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+			return dm.Unmarshal(b, ei.Value)
+		}
+	}
+
+	if err := dm.Wellformed(b); err != nil {
+		return err
+	}
+	raw := make(RawMessage, len(b))
+	copy(raw, b)
+	ei.Value = raw
+	return nil
+}
+
+// nextCBOREncodedItem returns true if next CBOR data item is tag 24 (encoded CBOR data item).
+func (d *decoder) nextCBOREncodedItem() bool {
+	if d.nextCBORType() != cborTypeTag {
+		return false
+	}
+	off := d.off
+	_, _, tagNum := d.getHead()
+	d.off = off
+	return tagNum == tagNumEncodedCBORDataItem
+}
+
+// nextEncodedItemDecoder returns a decoder for the data item enclosed in tag 24 at d.off,
+// and moves cursor past tag 24.  It returns an error if tag content isn't a byte string,
+// or if the byte string doesn't contain exactly one well-formed CBOR data item.
+func (d *decoder) nextEncodedItemDecoder() (*decoder, error) {
+	off := d.off
+	d.getHead() // Skip tag number 24.
+	if t := d.nextCBORType(); t != cborTypeByteString {
+		d.off = off
+		d.skip()
+		return nil, newInadmissibleTagContentTypeError(tagNumEncodedCBORDataItem, "byte string", t.String())
+	}
+	b, _ := d.parseByteString()
+
+	nd := &decoder{
+		data:          b,
+		dm:            d.dm,
+		parsingMapKey: d.parsingMapKey,
+		copyBytes:     d.copyBytes,
+	}
+	if err := nd.wellformed(false, false); err != nil {
+		return nil, err
+	}
+	nd.reset(b)
+	return nd, nil
+}
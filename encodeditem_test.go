@@ -0,0 +1,395 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMarshalEncodedItem(t *testing.T) {
+	type s struct {
+		P EncodedItem `cbor:"p"`
+	}
+
+	emByteString, err := EncOptions{EncodedItem: EncodedItemToByteString}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	emSorted, err := EncOptions{Sort: SortBytewiseLexical}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		em   EncMode
+		v    interface{}
+		want []byte
+	}{
+		{
+			name: "int",
+			em:   defaultEncMode,
+			v:    EncodedItem{Value: 1},
+			want: hexDecode("d8184101"), // 24(h'01')
+		},
+		{
+			name: "nil",
+			em:   defaultEncMode,
+			v:    EncodedItem{},
+			want: hexDecode("d81841f6"), // 24(h'f6')
+		},
+		{
+			name: "struct field",
+			em:   defaultEncMode,
+			v:    s{P: EncodedItem{Value: "a"}},
+			want: hexDecode("a16170d818426161"), // {"p": 24(h'6161')}
+		},
+		{
+			name: "EncodedItemToByteString",
+			em:   emByteString,
+			v:    EncodedItem{Value: 1},
+			want: hexDecode("4101"), // h'01'
+		},
+		{
+			name: "enclosed data item encoded with caller's mode",
+			em:   emSorted,
+			v:    EncodedItem{Value: map[string]int{"b": 2, "a": 1}},
+			want: hexDecode("d81847a2616101616202"), // 24(h'a2616101616202')
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.em.Marshal(tc.v)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned error %v", tc.v, err)
+			}
+			if !bytes.Equal(b, tc.want) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", tc.v, b, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalEncodedItem(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+		want RawMessage
+	}{
+		{
+			name: "tag 24",
+			data: hexDecode("d8184101"), // 24(h'01')
+			want: RawMessage{0x01},
+		},
+		{
+			name: "byte string without tag 24",
+			data: hexDecode("4101"), // h'01'
+			want: RawMessage{0x01},
+		},
+		{
+			name: "indefinite-length byte string",
+			data: hexDecode("d8185f4182420102ff"), // 24((_ h'82', h'0102'))
+			want: RawMessage{0x82, 0x01, 0x02},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ei EncodedItem
+			if err := Unmarshal(tc.data, &ei); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if !reflect.DeepEqual(ei.Value, tc.want) {
+				t.Errorf("Unmarshal(0x%x) = %#v, want %#v", tc.data, ei.Value, tc.want)
+			}
+		})
+	}
+
+	t.Run("nested decode", func(t *testing.T) {
+		var i int
+		ei := EncodedItem{Value: &i}
+		data := hexDecode("d8184101") // 24(h'01')
+		if err := Unmarshal(data, &ei); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+		}
+		if i != 1 {
+			t.Errorf("Unmarshal(0x%x) decoded enclosed data item %d, want 1", data, i)
+		}
+		if ei.Value != &i {
+			t.Errorf("Unmarshal(0x%x) changed Value to %v", data, ei.Value)
+		}
+	})
+
+	t.Run("null", func(t *testing.T) {
+		ei := EncodedItem{Value: 1}
+		if err := Unmarshal(hexDecode("f6"), &ei); err != nil {
+			t.Fatalf("Unmarshal(0xf6) returned error %v", err)
+		}
+		if ei.Value != 1 {
+			t.Errorf("Unmarshal(0xf6) changed Value to %v", ei.Value)
+		}
+	})
+}
+
+func TestUnmarshalEncodedItemError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "different tag number",
+			data:         hexDecode("c24101"), // 2(h'01')
+			wantErrorMsg: "cbor: cannot unmarshal tag into Go value of type cbor.EncodedItem (expect tag number 24, got 2)",
+		},
+		{
+			name:         "tag content isn't byte string",
+			data:         hexDecode("d81801"), // 24(1)
+			wantErrorMsg: "cbor: cannot unmarshal positive integer into Go value of type cbor.EncodedItem",
+		},
+		{
+			name:         "truncated enclosed data item",
+			data:         hexDecode("d8184118"), // 24(h'18')
+			wantErrorMsg: io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			name:         "extraneous data after enclosed data item",
+			data:         hexDecode("d818420101"), // 24(h'0101')
+			wantErrorMsg: "cbor: 1 bytes of extraneous data starting at index 1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ei EncodedItem
+			err := Unmarshal(tc.data, &ei)
+			if err == nil {
+				t.Fatalf("Unmarshal(0x%x) didn't return an error", tc.data)
+			}
+			if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestEncodedItemRoundTrip(t *testing.T) {
+	type item struct {
+		ID   int    `cbor:"id"`
+		Name string `cbor:"name"`
+	}
+	type document struct {
+		Item EncodedItem `cbor:"item"`
+	}
+
+	in := document{Item: EncodedItem{Value: item{ID: 1, Name: "x"}}}
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal(%v) returned error %v", in, err)
+	}
+
+	var got item
+	out := document{Item: EncodedItem{Value: &got}}
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", b, err)
+	}
+	if got != in.Item.Value {
+		t.Errorf("Unmarshal(0x%x) = %v, want %v", b, got, in.Item.Value)
+	}
+}
+
+func TestEncModeInvalidEncodedItem(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         EncOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         EncOptions{EncodedItem: -1},
+			wantErrorMsg: "cbor: invalid EncodedItem -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         EncOptions{EncodedItem: 101},
+			wantErrorMsg: "cbor: invalid EncodedItem 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.EncMode()
+			if err == nil {
+				t.Errorf("EncMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDecModeInvalidEncodedItem(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         DecOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         DecOptions{EncodedItem: -1},
+			wantErrorMsg: "cbor: invalid EncodedItem -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         DecOptions{EncodedItem: 101},
+			wantErrorMsg: "cbor: invalid EncodedItem 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.DecMode()
+			if err == nil {
+				t.Errorf("DecMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDecodeEncodedItemUnwrap(t *testing.T) {
+	dm, err := DecOptions{EncodedItem: EncodedItemDecodeUnwrap}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		data []byte
+		want interface{}
+	}{
+		{
+			name: "to empty interface",
+			data: hexDecode("d8184101"), // 24(h'01')
+			want: uint64(1),
+		},
+		{
+			name: "nested tag 24 to empty interface",
+			data: hexDecode("d81844d8184101"), // 24(h'd8184101')
+			want: uint64(1),
+		},
+		{
+			name: "map value to empty interface",
+			data: hexDecode("a16161d8184101"), // {"a": 24(h'01')}
+			want: map[interface{}]interface{}{"a": uint64(1)},
+		},
+		{
+			name: "to int",
+			data: hexDecode("d8184101"), // 24(h'01')
+			want: 1,
+		},
+		{
+			name: "to byte slice",
+			data: hexDecode("d81843420102"), // 24(h'420102')
+			want: []byte{1, 2},
+		},
+		{
+			name: "to struct",
+			data: hexDecode("d81844a1616101"), // 24(h'a1616101')
+			want: struct{ A int }{A: 1},
+		},
+		{
+			name: "to time",
+			data: hexDecode("d81842c100"), // 24(h'c100')
+			want: time.Unix(0, 0),
+		},
+		{
+			name: "to Tag",
+			data: hexDecode("d8184101"), // 24(h'01')
+			want: Tag{Number: 24, Content: []byte{0x01}},
+		},
+		{
+			name: "to RawMessage",
+			data: hexDecode("d8184101"), // 24(h'01')
+			want: RawMessage(hexDecode("d8184101")),
+		},
+		{
+			name: "to EncodedItem",
+			data: hexDecode("d8184101"), // 24(h'01')
+			want: EncodedItem{Value: RawMessage{0x01}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := reflect.New(reflect.TypeOf(tc.want))
+			if err := dm.Unmarshal(tc.data, v.Interface()); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			got := v.Elem().Interface()
+			if tm, ok := got.(time.Time); ok {
+				if !tm.Equal(tc.want.(time.Time)) {
+					t.Errorf("Unmarshal(0x%x) = %v, want %v", tc.data, got, tc.want)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Unmarshal(0x%x) = %#v, want %#v", tc.data, got, tc.want)
+			}
+		})
+	}
+
+	t.Run("default mode", func(t *testing.T) {
+		var v interface{}
+		data := hexDecode("d8184101") // 24(h'01')
+		if err := Unmarshal(data, &v); err != nil {
+			t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+		}
+		want := Tag{Number: 24, Content: []byte{0x01}}
+		if !reflect.DeepEqual(v, want) {
+			t.Errorf("Unmarshal(0x%x) = %#v, want %#v", data, v, want)
+		}
+	})
+}
+
+func TestDecodeEncodedItemUnwrapError(t *testing.T) {
+	dm, err := DecOptions{EncodedItem: EncodedItemDecodeUnwrap}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "tag content isn't byte string",
+			data:         hexDecode("d81801"), // 24(1)
+			wantErrorMsg: "cbor: tag number 24 must be followed by byte string, got positive integer",
+		},
+		{
+			name:         "truncated enclosed data item",
+			data:         hexDecode("d8184118"), // 24(h'18')
+			wantErrorMsg: io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			name:         "extraneous data after enclosed data item",
+			data:         hexDecode("d818420101"), // 24(h'0101')
+			wantErrorMsg: "cbor: 1 bytes of extraneous data starting at index 1",
+		},
+	}
+	for _, tc := range testCases {
+		for _, typ := range []reflect.Type{typeIntf, typeInt64} {
+			t.Run(tc.name+" to "+typ.String(), func(t *testing.T) {
+				v := reflect.New(typ)
+				err := dm.Unmarshal(tc.data, v.Interface())
+				if err == nil {
+					t.Fatalf("Unmarshal(0x%x) didn't return an error", tc.data)
+				}
+				if err.Error() != tc.wantErrorMsg {
+					t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+				}
+			})
+		}
+	}
+}
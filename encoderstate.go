@@ -0,0 +1,161 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// MarshalerTo is the interface implemented by types that can append their CBOR
+// encoding to EncoderState without reflection.  It is intended for types on hot
+// paths (e.g. generated code), since encoding struct fields with reflection is much
+// slower than writing them directly.
+//
+// Unlike Marshaler, data written by MarshalCBORTo isn't checked for well-formedness,
+// so MarshalCBORTo must write exactly one well-formed CBOR data item.  EncoderState
+// methods honor options of the encoding mode (e.g. ShortestFloat and String), but
+// MarshalCBORTo is responsible for writing map entries in the order specified by
+// EncOptions.Sort if needed.
+//
+// If a type implements MarshalerTo and MarshalerWithMode or Marshaler, MarshalerTo is used.
+type MarshalerTo interface {
+	MarshalCBORTo(s *EncoderState) error
+}
+
+// EncoderState appends CBOR data items to the encoding buffer of a Marshal call,
+// using its encoding mode.  It is only valid during the MarshalCBORTo call that
+// received it.
+type EncoderState struct {
+	e  *bytes.Buffer
+	em *encMode
+}
+
+// EncMode returns the encoding mode used by s.
+func (s *EncoderState) EncMode() EncMode {
+	return s.em
+}
+
+// WriteNil writes CBOR null.
+func (s *EncoderState) WriteNil() {
+	s.e.Write(cborNil)
+}
+
+// WriteBool writes CBOR false or true.
+func (s *EncoderState) WriteBool(b bool) {
+	if b {
+		s.e.Write(cborTrue)
+	} else {
+		s.e.Write(cborFalse)
+	}
+}
+
+// WriteInt writes i as CBOR positive or negative integer.
+func (s *EncoderState) WriteInt(i int64) {
+	if i >= 0 {
+		encodeHead(s.e, byte(cborTypePositiveInt), uint64(i))
+		return
+	}
+	encodeHead(s.e, byte(cborTypeNegativeInt), uint64(-1-i))
+}
+
+// WriteUint writes u as CBOR positive integer.
+func (s *EncoderState) WriteUint(u uint64) {
+	encodeHead(s.e, byte(cborTypePositiveInt), u)
+}
+
+// WriteFloat64 writes f as CBOR floating-point number, as specified by ShortestFloat,
+// NaNConvert, InfConvert, and NumericReduction options.
+func (s *EncoderState) WriteFloat64(f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return encodeFloat(s.e, s.em, reflect.ValueOf(f))
+	}
+	return encodeFiniteFloat(s.e, s.em, f, true)
+}
+
+// WriteFloat32 writes f as CBOR floating-point number, as specified by ShortestFloat,
+// NaNConvert, InfConvert, and NumericReduction options.
+func (s *EncoderState) WriteFloat32(f float32) error {
+	f64 := float64(f)
+	if math.IsNaN(f64) || math.IsInf(f64, 0) {
+		return encodeFloat(s.e, s.em, reflect.ValueOf(f))
+	}
+	return encodeFiniteFloat(s.e, s.em, f64, false)
+}
+
+// WriteString writes str as CBOR text string or byte string, as specified by String and
+// StringChunkSize options.
+func (s *EncoderState) WriteString(str string) {
+	encodeStringContent(s.e, s.em, str)
+}
+
+// WriteBytes writes b as CBOR byte string.  Nil b is written as CBOR null or empty
+// byte string, as specified by NilContainers option.  Expected later encoding tag
+// is written before the byte string if specified by ByteSliceLaterFormat option.
+// Long b is chunked as specified by StringChunkSize option.
+func (s *EncoderState) WriteBytes(b []byte) {
+	if b == nil && s.em.nilContainers == NilContainerAsNull {
+		s.e.Write(cborNil)
+		return
+	}
+	if s.em.byteSliceLaterEncodingTag != 0 {
+		encodeHead(s.e, byte(cborTypeTag), s.em.byteSliceLaterEncodingTag)
+	}
+	encodeBytes(s.e, s.em, b)
+}
+
+// WriteArrayHead writes the head of definite-length CBOR array with n elements.
+// It must be followed by n data items.
+func (s *EncoderState) WriteArrayHead(n int) {
+	encodeHead(s.e, byte(cborTypeArray), uint64(n))
+}
+
+// WriteMapHead writes the head of definite-length CBOR map with n key-value pairs.
+// It must be followed by 2*n data items.
+func (s *EncoderState) WriteMapHead(n int) {
+	encodeHead(s.e, byte(cborTypeMap), uint64(n))
+}
+
+// WriteTagHead writes the head of CBOR tag with tag number num.  It must be followed
+// by tag content.  It returns an error if TagsMd option is TagsForbidden.
+func (s *EncoderState) WriteTagHead(num uint64) error {
+	if s.em.tagsMd == TagsForbidden {
+		return errors.New("cbor: cannot encode tag number " + strconv.FormatUint(num, 10) + " when TagsMd is TagsForbidden")
+	}
+	encodeHead(s.e, byte(cborTypeTag), num)
+	return nil
+}
+
+// WriteRaw writes data, which must be one or more well-formed encoded CBOR data items.
+func (s *EncoderState) WriteRaw(data []byte) {
+	s.e.Write(data)
+}
+
+// Encode writes the CBOR encoding of v using the encoding mode of s, same as Marshal.
+// It can be used for values without fast path.  If v implements MarshalerTo, its
+// MarshalCBORTo is called after a lookup of cached type information.
+func (s *EncoderState) Encode(v interface{}) error {
+	return encode(s.e, s.em, reflect.ValueOf(v))
+}
+
+func encodeMarshalerToType(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	var m MarshalerTo
+	ok := false
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		// Avoid copying v to interface{} if MarshalCBORTo has pointer receiver.
+		m, ok = v.Addr().Interface().(MarshalerTo)
+	}
+	if !ok {
+		m, ok = v.Interface().(MarshalerTo)
+	}
+	if !ok {
+		pv := reflect.New(v.Type())
+		pv.Elem().Set(v)
+		m = pv.Interface().(MarshalerTo)
+	}
+	return m.MarshalCBORTo(&EncoderState{e: e, em: em})
+}
@@ -0,0 +1,177 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// hotPoint is encoded the same as hotPointReflect, using MarshalerTo.
+type hotPoint struct {
+	Name  string
+	X     int64
+	Y     uint64
+	F     float64
+	G     float32
+	B     []byte
+	OK    bool
+	Inner *hotPoint
+}
+
+type hotPointReflect struct {
+	Name  string           `cbor:"1,keyasint"`
+	X     int64            `cbor:"2,keyasint"`
+	Y     uint64           `cbor:"3,keyasint"`
+	F     float64          `cbor:"4,keyasint"`
+	G     float32          `cbor:"5,keyasint"`
+	B     []byte           `cbor:"6,keyasint"`
+	OK    bool             `cbor:"7,keyasint"`
+	Inner *hotPointReflect `cbor:"8,keyasint"`
+}
+
+func (p *hotPoint) MarshalCBORTo(s *EncoderState) error {
+	s.WriteMapHead(8)
+	s.WriteUint(1)
+	s.WriteString(p.Name)
+	s.WriteUint(2)
+	s.WriteInt(p.X)
+	s.WriteUint(3)
+	s.WriteUint(p.Y)
+	s.WriteUint(4)
+	if err := s.WriteFloat64(p.F); err != nil {
+		return err
+	}
+	s.WriteUint(5)
+	if err := s.WriteFloat32(p.G); err != nil {
+		return err
+	}
+	s.WriteUint(6)
+	s.WriteBytes(p.B)
+	s.WriteUint(7)
+	s.WriteBool(p.OK)
+	s.WriteUint(8)
+	if p.Inner == nil {
+		s.WriteNil()
+		return nil
+	}
+	return s.Encode(p.Inner)
+}
+
+// MarshalCBOR isn't used because MarshalerTo takes precedence.
+func (p *hotPoint) MarshalCBOR() ([]byte, error) {
+	return []byte{0xf6}, nil
+}
+
+func TestMarshalerTo(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts EncOptions
+		v    hotPoint
+	}{
+		{
+			name: "default options",
+			v:    hotPoint{Name: "a", X: -100, Y: 1000, F: 1.5, G: 0.1, B: []byte{1, 2}, OK: true},
+		},
+		{
+			name: "nested value with nil byte slice",
+			v:    hotPoint{Name: "a", X: math.MinInt64, Y: math.MaxUint64, Inner: &hotPoint{Name: "b", F: 100000}},
+		},
+		{
+			name: "shortest float and numeric reduction",
+			opts: EncOptions{ShortestFloat: ShortestFloat16, NumericReduction: NumericReductionFloatToInt},
+			v:    hotPoint{F: 2, G: 1.5, Inner: &hotPoint{F: 0.1, G: 65504}},
+		},
+		{
+			name: "NaN and Inf",
+			opts: EncOptions{NaNConvert: NaNConvert7e00, InfConvert: InfConvertFloat16},
+			v:    hotPoint{F: math.NaN(), G: float32(math.Inf(-1))},
+		},
+		{
+			name: "string and byte slice options",
+			opts: EncOptions{String: StringToByteString, NilContainers: NilContainerAsEmpty, ByteSliceLaterFormat: ByteSliceLaterFormatBase64},
+			v:    hotPoint{Name: "abc", B: nil, Inner: &hotPoint{B: []byte{1}}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := tc.opts.EncMode()
+			if err != nil {
+				t.Fatalf("EncMode() returned error %v", err)
+			}
+
+			want, err := em.Marshal(toHotPointReflect(&tc.v))
+			if err != nil {
+				t.Fatalf("Marshal() returned error %v", err)
+			}
+
+			for _, v := range []interface{}{tc.v, &tc.v} {
+				b, err := em.Marshal(v)
+				if err != nil {
+					t.Fatalf("Marshal(%T) returned error %v", v, err)
+				}
+				if !bytes.Equal(b, want) {
+					t.Errorf("Marshal(%T) = 0x%x, want 0x%x", v, b, want)
+				}
+			}
+
+			// Values implementing MarshalerTo in slices are also encoded with MarshalCBORTo.
+			b, err := em.Marshal([]hotPoint{tc.v})
+			if err != nil {
+				t.Fatalf("Marshal([]hotPoint) returned error %v", err)
+			}
+			if !bytes.Equal(b, append([]byte{0x81}, want...)) {
+				t.Errorf("Marshal([]hotPoint) = 0x%x, want 0x81%x", b, want)
+			}
+		})
+	}
+}
+
+func toHotPointReflect(p *hotPoint) *hotPointReflect {
+	if p == nil {
+		return nil
+	}
+	return &hotPointReflect{
+		Name:  p.Name,
+		X:     p.X,
+		Y:     p.Y,
+		F:     p.F,
+		G:     p.G,
+		B:     p.B,
+		OK:    p.OK,
+		Inner: toHotPointReflect(p.Inner),
+	}
+}
+
+type taggedHotValue struct{}
+
+func (taggedHotValue) MarshalCBORTo(s *EncoderState) error {
+	if err := s.WriteTagHead(100); err != nil {
+		return err
+	}
+	s.WriteRaw([]byte{0x01})
+	return nil
+}
+
+func TestMarshalerToTag(t *testing.T) {
+	b, err := Marshal(taggedHotValue{})
+	if err != nil {
+		t.Fatalf("Marshal() returned error %v", err)
+	}
+	if want := hexDecode("d86401"); !bytes.Equal(b, want) {
+		t.Errorf("Marshal() = 0x%x, want 0x%x", b, want)
+	}
+
+	em, err := EncOptions{TagsMd: TagsForbidden}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	wantErrorMsg := "cbor: cannot encode tag number 100 when TagsMd is TagsForbidden"
+	if _, err := em.Marshal(taggedHotValue{}); err == nil {
+		t.Errorf("Marshal() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Marshal() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+}
@@ -352,6 +352,29 @@ func ExampleDecoder() {
 	// {Age:2 Name:Duke Owners:[Norton] Male:true}
 }
 
+func ExampleHexDump() {
+	type Animal struct {
+		Age  int    `cbor:"1,keyasint"`
+		Name string `cbor:"2,keyasint"`
+	}
+	b, err := cbor.Marshal(Animal{Age: 4, Name: "Candy"})
+	if err != nil {
+		fmt.Println("error:", err)
+	}
+	s, err := cbor.HexDump(b)
+	if err != nil {
+		fmt.Println("error:", err)
+	}
+	fmt.Print(s)
+	// Output:
+	// 00000000  a2              # map(2)
+	// 00000001    01            #   unsigned(1)
+	// 00000002    04            #   unsigned(4)
+	// 00000003    02            #   unsigned(2)
+	// 00000004    65            #   text(5)
+	// 00000005      43616e6479  #     "Candy"
+}
+
 func Example_cWT() {
 	// Use "keyasint" struct tag to encode/decode struct to/from CBOR map.
 	type claims struct {
@@ -0,0 +1,87 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import "strings"
+
+// FieldMask selects struct fields to encode with MarshalWithMask.
+//
+// Keys are field names as encoded in CBOR (e.g. name in struct tag, or integer key
+// of a "keyasint" field such as "1").  A field with nil FieldMask is encoded entirely.
+// A field with non-nil FieldMask is encoded with only the selected fields of the
+// struct in the field value, including structs in arrays, slices, maps, and pointers
+// in the field value.
+type FieldMask map[string]FieldMask
+
+// NewFieldMask returns FieldMask selecting fields by paths of field names separated
+// by ".", such as "name" and "address.city".  A path selecting a field entirely
+// overrides paths selecting its nested fields.
+func NewFieldMask(paths ...string) FieldMask {
+	mask := FieldMask{}
+	for _, path := range paths {
+		m := mask
+		names := strings.Split(path, ".")
+		for i, name := range names {
+			sub, ok := m[name]
+			if ok && sub == nil {
+				// Field is already selected entirely.
+				break
+			}
+			if i == len(names)-1 {
+				m[name] = nil
+				break
+			}
+			if sub == nil {
+				sub = FieldMask{}
+				m[name] = sub
+			}
+			m = sub
+		}
+	}
+	return mask
+}
+
+// MarshalWithMask returns the CBOR encoding of v using default encoding options,
+// with only struct fields selected by mask.
+//
+// See the documentation for (*encMode).MarshalWithMask for details.
+func MarshalWithMask(v interface{}, mask FieldMask) ([]byte, error) {
+	return defaultEncMode.MarshalWithMask(v, mask)
+}
+
+// MarshalWithMask returns the CBOR encoding of v using em encoding mode, with only
+// struct fields selected by mask.  It can be used for sparse updates and field
+// filtering in APIs without defining a struct type for each subset of fields.
+//
+// Mask applies to the first struct encountered in v (e.g. v itself, or elements of
+// v if v is a slice of structs).  Selected fields are still subject to "omitempty"
+// and "omitzero" options.  Names in mask without matching fields are ignored.
+// Struct fields with "toarray" option are encoded with all fields, and map entries
+// in struct fields with "unknown" option aren't encoded.  Values implementing
+// Marshaler are encoded by their MarshalCBOR method without mask.
+//
+// A nil mask selects all fields, same as Marshal.
+func (em *encMode) MarshalWithMask(v interface{}, mask FieldMask) ([]byte, error) {
+	if mask == nil {
+		return em.Marshal(v)
+	}
+	return em.withFieldMask(mask).Marshal(v)
+}
+
+// withFieldMask returns a shallow copy of em with fieldMask set to mask.
+func (em *encMode) withFieldMask(mask FieldMask) *encMode {
+	vem := *em // shallow copy
+	vem.fieldMask = mask
+	return &vem
+}
+
+// fieldEncMode returns encoding mode for value of struct field f selected by em.fieldMask.
+// It returns false if f isn't selected.
+func (em *encMode) fieldEncMode(f *field) (*encMode, bool) {
+	mask, ok := em.fieldMask[f.name]
+	if !ok {
+		return nil, false
+	}
+	return em.withFieldMask(mask), true
+}
@@ -0,0 +1,172 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestNewFieldMask(t *testing.T) {
+	testCases := []struct {
+		name  string
+		paths []string
+		want  FieldMask
+	}{
+		{
+			name:  "no paths",
+			paths: nil,
+			want:  FieldMask{},
+		},
+		{
+			name:  "top-level fields",
+			paths: []string{"a", "b"},
+			want:  FieldMask{"a": nil, "b": nil},
+		},
+		{
+			name:  "nested fields",
+			paths: []string{"a.b", "a.c.d", "e"},
+			want:  FieldMask{"a": FieldMask{"b": nil, "c": FieldMask{"d": nil}}, "e": nil},
+		},
+		{
+			name:  "entire field before nested field",
+			paths: []string{"a", "a.b"},
+			want:  FieldMask{"a": nil},
+		},
+		{
+			name:  "entire field after nested field",
+			paths: []string{"a.b", "a"},
+			want:  FieldMask{"a": nil},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mask := NewFieldMask(tc.paths...)
+			if !reflect.DeepEqual(mask, tc.want) {
+				t.Errorf("NewFieldMask(%q) = %v, want %v", tc.paths, mask, tc.want)
+			}
+		})
+	}
+}
+
+func TestMarshalWithMask(t *testing.T) {
+	type address struct {
+		Street string `cbor:"street"`
+		City   string `cbor:"city"`
+	}
+	type point struct {
+		X int `cbor:"1,keyasint"`
+		Y int `cbor:"2,keyasint"`
+	}
+	type pair struct {
+		_ struct{} `cbor:",toarray"`
+		A address
+		B int
+	}
+	type user struct {
+		Name    string   `cbor:"name"`
+		Email   string   `cbor:"email,omitempty"`
+		Address *address `cbor:"address"`
+		Points  []point  `cbor:"points"`
+		Pair    pair     `cbor:"pair"`
+	}
+
+	v := user{
+		Name:    "Al",
+		Address: &address{Street: "Main", City: "Oslo"},
+		Points:  []point{{X: 1, Y: 2}, {X: 3, Y: 4}},
+		Pair:    pair{A: address{Street: "x", City: "y"}, B: 5},
+	}
+
+	em, err := EncOptions{Sort: SortCanonical}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	// Expected data is encoded from equivalent maps, since SortCanonical sorts
+	// both struct fields and map keys.
+	testCases := []struct {
+		name string
+		mask FieldMask
+		want interface{}
+	}{
+		{
+			name: "nil mask",
+			mask: nil,
+			want: v,
+		},
+		{
+			name: "empty mask",
+			mask: FieldMask{},
+			want: map[string]interface{}{},
+		},
+		{
+			name: "top-level fields",
+			mask: NewFieldMask("name", "address"),
+			want: map[string]interface{}{
+				"name":    "Al",
+				"address": map[string]interface{}{"street": "Main", "city": "Oslo"},
+			},
+		},
+		{
+			name: "omitempty field",
+			mask: NewFieldMask("name", "email"),
+			want: map[string]interface{}{"name": "Al"},
+		},
+		{
+			name: "nested field in pointer to struct",
+			mask: NewFieldMask("address.city"),
+			want: map[string]interface{}{
+				"address": map[string]interface{}{"city": "Oslo"},
+			},
+		},
+		{
+			name: "nested keyasint field in slice of structs",
+			mask: NewFieldMask("points.2"),
+			want: map[string]interface{}{
+				"points": []interface{}{map[int]int{2: 2}, map[int]int{2: 4}},
+			},
+		},
+		{
+			name: "nested field in toarray struct",
+			mask: NewFieldMask("pair.A.city"),
+			want: map[string]interface{}{
+				"pair": []interface{}{map[string]interface{}{"street": "x", "city": "y"}, 5},
+			},
+		},
+		{
+			name: "unmatched names",
+			mask: NewFieldMask("name", "phone", "address.zip"),
+			want: map[string]interface{}{
+				"name":    "Al",
+				"address": map[string]interface{}{},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wantData, err := em.Marshal(tc.want)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned error %v", tc.want, err)
+			}
+			b, err := em.(ExtendedEncMode).MarshalWithMask(v, tc.mask)
+			if err != nil {
+				t.Fatalf("MarshalWithMask() returned error %v", err)
+			}
+			if !bytes.Equal(b, wantData) {
+				t.Errorf("MarshalWithMask() = 0x%x, want 0x%x", b, wantData)
+			}
+		})
+	}
+
+	// Package-level MarshalWithMask uses default encoding options.
+	b, err := MarshalWithMask(v, NewFieldMask("name"))
+	if err != nil {
+		t.Fatalf("MarshalWithMask() returned error %v", err)
+	}
+	if want := hexDecode("a1646e616d6562416c"); !bytes.Equal(b, want) {
+		t.Errorf("MarshalWithMask() = 0x%x, want 0x%x", b, want)
+	}
+}
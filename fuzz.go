@@ -0,0 +1,108 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// fuzzEncMode sorts map keys, so encoding the same value twice produces the same
+// encoded data (except for maps with multiple NaN keys).
+var fuzzEncMode, _ = CoreDetEncOptions().encMode()
+
+// fuzzCorpusHeader is the first line of corpus files used by go test fuzzing.
+const fuzzCorpusHeader = "go test fuzz v1\n"
+
+// FuzzDecode is a fuzz function compatible with go-fuzz and go test fuzzing.  It is
+// the same as FuzzDecodeInto with v pointing to an empty interface value.
+func FuzzDecode(data []byte) int {
+	var v interface{}
+	return FuzzDecodeInto(data, &v)
+}
+
+// FuzzDecodeInto is a fuzz function compatible with go-fuzz, which can be used by
+// downstream projects to fuzz decoding of their own types.  v must be a non-nil pointer,
+// and only its type is used.  FuzzDecodeInto returns 0 if data can't be decoded into
+// the type v points to.  Otherwise, it encodes the decoded value, decodes and encodes
+// it again, and returns 1.  It panics if data is decoded but isn't well-formed, or if
+// the decoded value can't be encoded, or if the encoded value can't be decoded, or if
+// encoding isn't stable across the roundtrip.
+//
+// FuzzDecodeInto can be called from go test fuzzing as:
+//
+//	func FuzzMyType(f *testing.F) {
+//		f.Fuzz(func(t *testing.T, data []byte) {
+//			cbor.FuzzDecodeInto(data, new(MyType))
+//		})
+//	}
+func FuzzDecodeInto(data []byte, v interface{}) int {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		panic(fmt.Sprintf("cbor: FuzzDecodeInto requires non-nil pointer, got %T", v))
+	}
+	typ := rv.Type().Elem()
+
+	v1 := reflect.New(typ)
+	if err := Unmarshal(data, v1.Interface()); err != nil {
+		return 0
+	}
+	if err := Wellformed(data); err != nil {
+		panic(fmt.Sprintf("cbor: decoded data 0x%x that isn't well-formed: %v", data, err))
+	}
+
+	b1, err := fuzzEncMode.Marshal(v1.Elem().Interface())
+	if err != nil {
+		panic(fmt.Sprintf("cbor: failed to encode %#v decoded from 0x%x: %v", v1.Elem().Interface(), data, err))
+	}
+
+	v2 := reflect.New(typ)
+	if err := Unmarshal(b1, v2.Interface()); err != nil {
+		panic(fmt.Sprintf("cbor: failed to decode 0x%x encoded from %#v: %v", b1, v1.Elem().Interface(), err))
+	}
+
+	b2, err := fuzzEncMode.Marshal(v2.Elem().Interface())
+	if err != nil {
+		panic(fmt.Sprintf("cbor: failed to encode %#v decoded from 0x%x: %v", v2.Elem().Interface(), b1, err))
+	}
+	// Compare lengths instead of bytes because entries of maps with multiple NaN keys
+	// can't be sorted deterministically.
+	if len(b1) != len(b2) {
+		panic(fmt.Sprintf("cbor: encoding isn't stable: 0x%x != 0x%x", b1, b2))
+	}
+	return 1
+}
+
+// FuzzCorpusEntry returns data in the corpus file format used by go test fuzzing, so
+// encoded CBOR data (e.g. test vectors or data returned by Marshal) can be saved to
+// testdata/fuzz/FuzzXxx as seed corpus of a fuzz target with a []byte argument.
+func FuzzCorpusEntry(data []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(fuzzCorpusHeader)
+	b.WriteString("[]byte(")
+	b.WriteString(strconv.Quote(string(data)))
+	b.WriteString(")\n")
+	return b.Bytes()
+}
+
+// ParseFuzzCorpusEntry returns data saved in the corpus file format used by go test
+// fuzzing, so corpus entries found by go test fuzzing can be used with go-fuzz and
+// other tools.  It returns an error if entry doesn't have exactly one []byte value.
+func ParseFuzzCorpusEntry(entry []byte) ([]byte, error) {
+	if !bytes.HasPrefix(entry, []byte(fuzzCorpusHeader)) {
+		return nil, errors.New("cbor: fuzz corpus entry doesn't begin with " + strconv.Quote(fuzzCorpusHeader))
+	}
+	line := bytes.TrimSpace(entry[len(fuzzCorpusHeader):])
+	if !bytes.HasPrefix(line, []byte("[]byte(")) || !bytes.HasSuffix(line, []byte(")")) {
+		return nil, errors.New("cbor: fuzz corpus entry doesn't have exactly one []byte value")
+	}
+	s, err := strconv.Unquote(string(line[len("[]byte(") : len(line)-1]))
+	if err != nil {
+		return nil, errors.New("cbor: fuzz corpus entry has malformed []byte value: " + err.Error())
+	}
+	return []byte(s), nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFuzzDecode(t *testing.T) {
+	for _, tc := range unmarshalTests {
+		if got := FuzzDecode(tc.data); got != 1 {
+			t.Errorf("FuzzDecode(0x%x) = %d, want 1", tc.data, got)
+		}
+	}
+
+	for _, data := range [][]byte{
+		hexDecode("a101"),     // map missing value
+		hexDecode("1f"),       // reserved additional information
+		hexDecode("0000"),     // extraneous data
+		hexDecode("7f4161ff"), // byte string chunk in indefinite-length text string
+	} {
+		if got := FuzzDecode(data); got != 0 {
+			t.Errorf("FuzzDecode(0x%x) = %d, want 0", data, got)
+		}
+	}
+}
+
+func TestFuzzDecodeInto(t *testing.T) {
+	type T struct {
+		A int               `cbor:"a"`
+		B []string          `cbor:"b"`
+		C map[string]uint64 `cbor:"c"`
+	}
+
+	data := hexDecode("a3616101616282616361646163a1616505") // {"a": 1, "b": ["c", "d"], "c": {"e": 5}}
+	if got := FuzzDecodeInto(data, new(T)); got != 1 {
+		t.Errorf("FuzzDecodeInto(0x%x) = %d, want 1", data, got)
+	}
+
+	data = hexDecode("a1616162") // {"a": "b"} can't be decoded into T.A
+	if got := FuzzDecodeInto(data, new(T)); got != 0 {
+		t.Errorf("FuzzDecodeInto(0x%x) = %d, want 0", data, got)
+	}
+
+	for _, v := range []interface{}{nil, T{}, (*T)(nil)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("FuzzDecodeInto(%#v) didn't panic", v)
+				}
+			}()
+			FuzzDecodeInto(hexDecode("a0"), v)
+		}()
+	}
+}
+
+func TestFuzzCorpusEntry(t *testing.T) {
+	data := hexDecode("a2616101616282f5f6")
+	want := "go test fuzz v1\n[]byte(\"\\xa2aa\\x01ab\\x82\\xf5\\xf6\")\n"
+
+	entry := FuzzCorpusEntry(data)
+	if string(entry) != want {
+		t.Errorf("FuzzCorpusEntry(0x%x) = %q, want %q", data, entry, want)
+	}
+
+	got, err := ParseFuzzCorpusEntry(entry)
+	if err != nil {
+		t.Fatalf("ParseFuzzCorpusEntry(%q) returned error %v", entry, err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("ParseFuzzCorpusEntry(%q) = 0x%x, want 0x%x", entry, got, data)
+	}
+}
+
+func TestParseFuzzCorpusEntryError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		entry        string
+		wantErrorMsg string
+	}{
+		{
+			name:         "missing header",
+			entry:        "[]byte(\"\\x00\")\n",
+			wantErrorMsg: "cbor: fuzz corpus entry doesn't begin with \"go test fuzz v1\\n\"",
+		},
+		{
+			name:         "not []byte",
+			entry:        "go test fuzz v1\nstring(\"a\")\n",
+			wantErrorMsg: "cbor: fuzz corpus entry doesn't have exactly one []byte value",
+		},
+		{
+			name:         "more than one value",
+			entry:        "go test fuzz v1\n[]byte(\"a\")\n[]byte(\"b\")\n",
+			wantErrorMsg: "cbor: fuzz corpus entry has malformed []byte value: invalid syntax",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseFuzzCorpusEntry([]byte(tc.entry))
+			if err == nil {
+				t.Fatalf("ParseFuzzCorpusEntry(%q) didn't return an error", tc.entry)
+			}
+			if err.Error() != tc.wantErrorMsg {
+				t.Errorf("ParseFuzzCorpusEntry(%q) returned error %q, want %q", tc.entry, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
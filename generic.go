@@ -0,0 +1,122 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+//go:build go1.18
+
+package cbor
+
+import "math"
+
+// Decode decodes the CBOR data item in data into a new value of type T using dm
+// decoding mode, or the default decoding mode if dm is nil.  It is the same as
+// calling dm.Unmarshal with a pointer to a new value of type T, without the pointer
+// boilerplate at call sites.
+//
+// Decode uses faster decoding for untagged CBOR data items decoded into string,
+// []byte, and int64.  Other data items and types are decoded by Unmarshal.
+//
+// See the documentation for Unmarshal for details.
+func Decode[T any](data []byte, dm DecMode) (T, error) {
+	var v T
+	err := DecodeInto(data, dm, &v)
+	return v, err
+}
+
+// DecodeInto decodes the CBOR data item in data into the value pointed to by v using
+// dm decoding mode, or the default decoding mode if dm is nil.  Unlike Unmarshal, the
+// type of v is checked at compile time.  DecodeInto is a function instead of a DecMode
+// method because Go methods can't have type parameters.
+//
+// See the documentation for Decode and Unmarshal for details.
+func DecodeInto[T any](data []byte, dm DecMode, v *T) error {
+	if dm == nil {
+		dm = defaultDecMode
+	}
+	m, ok := dm.(*decMode)
+	if !ok || v == nil {
+		return dm.Unmarshal(data, v)
+	}
+
+	d := decoder{data: data, dm: m}
+
+	// Check well-formedness.
+	off := d.off // Save offset before data validation
+	err := d.wellformed(m.trailingBytes == TrailingBytesAllowed, false)
+	d.off = off // Restore offset
+	if err != nil {
+		return err
+	}
+
+	if d.fastValue(v) {
+		return nil
+	}
+	return d.value(v)
+}
+
+// Encode returns the CBOR encoding of v using em encoding mode, or the default
+// encoding mode if em is nil.
+//
+// See the documentation for Marshal for details.
+func Encode[T any](v T, em EncMode) ([]byte, error) {
+	if em == nil {
+		em = defaultEncMode
+	}
+	return em.Marshal(v)
+}
+
+// fastValue decodes untagged CBOR data item to string, []byte, or int64 pointed to
+// by v without reflection, and returns true if the data item is decoded.  It returns
+// false without moving cursor if the data item or the type of v requires decoding by
+// Unmarshal (e.g. registered tags, options transforming decoded values, or errors).
+func (d *decoder) fastValue(v interface{}) bool {
+	t := d.nextCBORType()
+	switch p := v.(type) {
+	case *string:
+		if t != cborTypeTextString ||
+			d.dm.unicodeNormalization != nil ||
+			d.getRegisteredTagItem(typeString) != nil {
+			return false
+		}
+		off := d.off
+		b, err := d.parseTextString()
+		if err != nil {
+			d.off = off
+			return false
+		}
+		*p = string(b)
+		return true
+
+	case *[]byte:
+		if t != cborTypeByteString ||
+			d.dm.byteStringExpectedFormat != ByteStringExpectedFormatNone ||
+			d.dm.reuse == ReuseContainers ||
+			d.getRegisteredTagItem(typeByteSlice) != nil {
+			return false
+		}
+		b, copied := d.parseByteString()
+		if !copied && !d.zeroCopy() {
+			b = append(make([]byte, 0, len(b)), b...)
+		}
+		*p = b
+		return true
+
+	case *int64:
+		if (t != cborTypePositiveInt && t != cborTypeNegativeInt) ||
+			d.getRegisteredTagItem(typeInt64) != nil {
+			return false
+		}
+		off := d.off
+		_, _, val := d.getHead()
+		if val > math.MaxInt64 {
+			d.off = off
+			return false
+		}
+		if t == cborTypeNegativeInt {
+			*p = int64(-1) ^ int64(val)
+		} else {
+			*p = int64(val)
+		}
+		return true
+	}
+	return false
+}
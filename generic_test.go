@@ -0,0 +1,150 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+//go:build go1.18
+
+package cbor
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testDecodeMatchesUnmarshal verifies that Decode[T] returns the same value and error
+// as dm.Unmarshal for data.
+func testDecodeMatchesUnmarshal[T any](t *testing.T, data []byte, dm DecMode) {
+	t.Helper()
+
+	var want T
+	var wantErr error
+	if dm == nil {
+		wantErr = Unmarshal(data, &want)
+	} else {
+		wantErr = dm.Unmarshal(data, &want)
+	}
+
+	got, err := Decode[T](data, dm)
+	if (err == nil) != (wantErr == nil) || (err != nil && err.Error() != wantErr.Error()) {
+		t.Errorf("Decode[%T](0x%x) returned error %v, want %v", got, data, err, wantErr)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode[%T](0x%x) = %#v, want %#v", got, data, got, want)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range unmarshalTests {
+		testDecodeMatchesUnmarshal[string](t, tc.data, nil)
+		testDecodeMatchesUnmarshal[[]byte](t, tc.data, nil)
+		testDecodeMatchesUnmarshal[int64](t, tc.data, nil)
+		testDecodeMatchesUnmarshal[map[string]interface{}](t, tc.data, nil)
+		testDecodeMatchesUnmarshal[interface{}](t, tc.data, nil)
+	}
+
+	for _, data := range [][]byte{
+		hexDecode("40"),                 // empty byte string
+		hexDecode("5f4201024103ff"),     // indefinite-length byte string
+		hexDecode("7f6261626163ff"),     // indefinite-length text string
+		hexDecode("62c328"),             // invalid UTF-8 text string
+		hexDecode("1bffffffffffffffff"), // 18446744073709551615 overflows int64
+		hexDecode("3bffffffffffffffff"), // -18446744073709551616 overflows int64
+		hexDecode("d9d9f763616263"),     // self-described CBOR tag
+		hexDecode("c11a514b67b0"),       // tagged integer
+		hexDecode("0000"),               // extraneous data
+		hexDecode("a1"),                 // truncated data
+	} {
+		testDecodeMatchesUnmarshal[string](t, data, nil)
+		testDecodeMatchesUnmarshal[[]byte](t, data, nil)
+		testDecodeMatchesUnmarshal[int64](t, data, nil)
+	}
+}
+
+func TestDecodeWithDecMode(t *testing.T) {
+	zeroCopyDM, err := DecOptions{ZeroCopy: ZeroCopyBytes}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+	upperDM, err := DecOptions{UnicodeNormalization: NewStringTransform(strings.ToUpper)}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+	base64DM, err := DecOptions{ByteStringExpectedFormat: ByteStringExpectedBase64}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	for _, dm := range []DecMode{zeroCopyDM, upperDM, base64DM} {
+		for _, data := range [][]byte{
+			hexDecode("63616263"),   // "abc"
+			hexDecode("4461626364"), // h'61626364'
+			hexDecode("3903e7"),     // -1000
+		} {
+			testDecodeMatchesUnmarshal[string](t, data, dm)
+			testDecodeMatchesUnmarshal[[]byte](t, data, dm)
+			testDecodeMatchesUnmarshal[int64](t, data, dm)
+		}
+	}
+
+	// Byte slices decoded without ZeroCopyBytes don't share data.
+	data := hexDecode("43010203")
+	b, err := Decode[[]byte](data, nil)
+	if err != nil {
+		t.Fatalf("Decode() returned error %v", err)
+	}
+	b[0] = 0xff
+	if data[1] != 0x01 {
+		t.Errorf("Decode[[]byte]() returned byte slice sharing data")
+	}
+}
+
+func TestDecodeInto(t *testing.T) {
+	type T struct {
+		A int    `cbor:"a"`
+		B string `cbor:"b"`
+	}
+
+	data := hexDecode("a26161016162627878") // {"a": 1, "b": "xx"}
+	var v T
+	if err := DecodeInto(data, nil, &v); err != nil {
+		t.Fatalf("DecodeInto(0x%x) returned error %v", data, err)
+	}
+	if want := (T{A: 1, B: "xx"}); v != want {
+		t.Errorf("DecodeInto(0x%x) = %+v, want %+v", data, v, want)
+	}
+
+	wantErrorMsg := "cbor: Unmarshal(nil *cbor.T)"
+	if err := DecodeInto[T](data, nil, nil); err == nil {
+		t.Errorf("DecodeInto(nil) didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("DecodeInto(nil) returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	em, err := EncOptions{Sort: SortBytewiseLexical}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	for _, tc := range []struct {
+		name         string
+		em           EncMode
+		wantCborData []byte
+	}{
+		{"default", nil, hexDecode("a1616101")},
+		{"EncMode", em, hexDecode("a1616101")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := Encode(map[string]int{"a": 1}, tc.em)
+			if err != nil {
+				t.Fatalf("Encode() returned error %v", err)
+			}
+			if !bytes.Equal(b, tc.wantCborData) {
+				t.Errorf("Encode() = 0x%x, want 0x%x", b, tc.wantCborData)
+			}
+		})
+	}
+}
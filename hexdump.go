@@ -0,0 +1,201 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/x448/float16"
+)
+
+// hexDumpBytesPerLine is the max number of string content bytes shown per line.
+const hexDumpBytesPerLine = 16
+
+// HexDump returns an annotated hex dump of CBOR data items in data (CBOR Sequence).
+// Each line shows the offset of the bytes in data, the bytes in hex indented by nesting
+// level, and a comment describing the major type and argument (or content) they encode.
+// For example, HexDump of 0xa161610f returns:
+//
+//	00000000  a1      # map(1)
+//	00000001    61    #   text(1)
+//	00000002      61  #     "a"
+//	00000003    0f    #   unsigned(15)
+//
+// HexDump uses the same limits as the default diagnostic mode.  To dump a Go value,
+// pass the result of Marshal to HexDump.
+func HexDump(data []byte) (string, error) {
+	d := decoder{data: data, dm: defaultDiagMode.decMode}
+	hd := hexDump{d: &d}
+
+	for {
+		off := d.off
+		err := d.wellformed(true, false)
+		d.off = off
+		if err == io.EOF && len(hd.lines) > 0 {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		hd.item(0)
+	}
+	return hd.String(), nil
+}
+
+type hexDumpLine struct {
+	off     int
+	depth   int
+	hex     string
+	comment string
+}
+
+type hexDump struct {
+	d     *decoder
+	lines []hexDumpLine
+}
+
+func (hd *hexDump) add(off int, depth int, comment string) {
+	hd.lines = append(hd.lines, hexDumpLine{
+		off:     off,
+		depth:   depth,
+		hex:     hex.EncodeToString(hd.d.data[off:hd.d.off]),
+		comment: comment,
+	})
+}
+
+// item adds lines of the data item at d.off to hd.
+// It assumes data is well-formed, and does not perform bounds checking.
+func (hd *hexDump) item(depth int) {
+	d := hd.d
+	off := d.off
+	t, ai, val, indefiniteLength := d.getHeadWithIndefiniteLengthFlag()
+
+	switch t {
+	case cborTypePositiveInt:
+		hd.add(off, depth, "unsigned("+strconv.FormatUint(val, 10)+")")
+
+	case cborTypeNegativeInt:
+		hd.add(off, depth, "negative("+Integer{Value: val, Negative: true}.String()+")")
+
+	case cborTypeByteString, cborTypeTextString:
+		name := "bytes"
+		if t == cborTypeTextString {
+			name = "text"
+		}
+		if indefiniteLength {
+			hd.add(off, depth, name+"(*)")
+			for !hd.foundBreak(depth) {
+				hd.item(depth + 1)
+			}
+			return
+		}
+		hd.add(off, depth, name+"("+strconv.FormatUint(val, 10)+")")
+		end := d.off + int(val)
+		for d.off < end {
+			off = d.off
+			d.off += hexDumpBytesPerLine
+			if d.off > end {
+				d.off = end
+			}
+			comment := ""
+			if t == cborTypeTextString {
+				comment = strconv.Quote(string(d.data[off:d.off]))
+			}
+			hd.add(off, depth+1, comment)
+		}
+
+	case cborTypeArray, cborTypeMap:
+		name := "array"
+		count := int(val)
+		if t == cborTypeMap {
+			name = "map"
+			count *= 2
+		}
+		if indefiniteLength {
+			hd.add(off, depth, name+"(*)")
+			for !hd.foundBreak(depth) {
+				hd.item(depth + 1)
+			}
+			return
+		}
+		hd.add(off, depth, name+"("+strconv.FormatUint(val, 10)+")")
+		for i := 0; i < count; i++ {
+			hd.item(depth + 1)
+		}
+
+	case cborTypeTag:
+		hd.add(off, depth, "tag("+strconv.FormatUint(val, 10)+")")
+		hd.item(depth + 1)
+
+	case cborTypePrimitives:
+		hd.add(off, depth, hexDumpPrimitive(ai, val))
+	}
+}
+
+// foundBreak returns true and adds break code line to hd if next byte is CBOR break code.
+func (hd *hexDump) foundBreak(depth int) bool {
+	off := hd.d.off
+	if !hd.d.foundBreak() {
+		return false
+	}
+	hd.add(off, depth, "break")
+	return true
+}
+
+func hexDumpPrimitive(ai byte, val uint64) string {
+	switch ai {
+	case additionalInformationAsFalse:
+		return "false"
+	case additionalInformationAsTrue:
+		return "true"
+	case additionalInformationAsNull:
+		return "null"
+	case additionalInformationAsUndefined:
+		return "undefined"
+	case additionalInformationAsFloat16:
+		f := float64(float16.Frombits(uint16(val)).Float32())
+		return "float16(" + strconv.FormatFloat(f, 'g', -1, 32) + ")"
+	case additionalInformationAsFloat32:
+		f := float64(math.Float32frombits(uint32(val)))
+		return "float32(" + strconv.FormatFloat(f, 'g', -1, 32) + ")"
+	case additionalInformationAsFloat64:
+		f := math.Float64frombits(val)
+		return "float64(" + strconv.FormatFloat(f, 'g', -1, 64) + ")"
+	}
+	return "simple(" + strconv.FormatUint(val, 10) + ")"
+}
+
+func (hd *hexDump) String() string {
+	width := 0
+	for _, l := range hd.lines {
+		if n := 2*l.depth + len(l.hex); n > width {
+			width = n
+		}
+	}
+
+	var b bytes.Buffer
+	for _, l := range hd.lines {
+		indent := strings.Repeat("  ", l.depth)
+		col := indent + l.hex
+		if l.comment == "" {
+			b.WriteString(hexOffset(l.off) + "  " + col + "\n")
+			continue
+		}
+		b.WriteString(hexOffset(l.off) + "  " + col + strings.Repeat(" ", width-len(col)) + "  # " + indent + l.comment + "\n")
+	}
+	return b.String()
+}
+
+func hexOffset(off int) string {
+	s := strconv.FormatInt(int64(off), 16)
+	if len(s) < 8 {
+		s = strings.Repeat("0", 8-len(s)) + s
+	}
+	return s
+}
@@ -0,0 +1,100 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"io"
+	"testing"
+)
+
+func TestHexDump(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{
+			name: "map",
+			data: hexDecode("a161610f"),
+			want: "" +
+				"00000000  a1      # map(1)\n" +
+				"00000001    61    #   text(1)\n" +
+				"00000002      61  #     \"a\"\n" +
+				"00000003    0f    #   unsigned(15)\n",
+		},
+		{
+			name: "indefinite-length byte string and array",
+			data: hexDecode("9f5f4101ff20ff"),
+			want: "" +
+				"00000000  9f        # array(*)\n" +
+				"00000001    5f      #   bytes(*)\n" +
+				"00000002      41    #     bytes(1)\n" +
+				"00000003        01\n" +
+				"00000004    ff      #   break\n" +
+				"00000005    20      #   negative(-1)\n" +
+				"00000006  ff        # break\n",
+		},
+		{
+			name: "tag and primitives",
+			data: hexDecode("c182f93e00f820"),
+			want: "" +
+				"00000000  c1          # tag(1)\n" +
+				"00000001    82        #   array(2)\n" +
+				"00000002      f93e00  #     float16(1.5)\n" +
+				"00000005      f820    #     simple(32)\n",
+		},
+		{
+			name: "long text string",
+			data: hexDecode("78186162636465666768696a6b6c6d6e6f707172737475767778"),
+			want: "" +
+				"00000000  7818                                # text(24)\n" +
+				"00000002    6162636465666768696a6b6c6d6e6f70  #   \"abcdefghijklmnop\"\n" +
+				"00000012    7172737475767778                  #   \"qrstuvwx\"\n",
+		},
+		{
+			name: "CBOR sequence",
+			data: hexDecode("f5fb3ff8000000000000"),
+			want: "" +
+				"00000000  f5                  # true\n" +
+				"00000001  fb3ff8000000000000  # float64(1.5)\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := HexDump(tc.data)
+			if err != nil {
+				t.Fatalf("HexDump(0x%x) returned error %v", tc.data, err)
+			}
+			if s != tc.want {
+				t.Errorf("HexDump(0x%x) returned:\n%s\nwant:\n%s", tc.data, s, tc.want)
+			}
+		})
+	}
+}
+
+func TestHexDumpError(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{
+			name:    "empty data",
+			data:    nil,
+			wantErr: io.EOF,
+		},
+		{
+			name:    "truncated data item",
+			data:    hexDecode("0182"),
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := HexDump(tc.data); err != tc.wantErr {
+				t.Errorf("HexDump(0x%x) returned error %v, want %v", tc.data, err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// Integer represents CBOR integer (major type 0 or 1) with its original major type and
+// full range of values: -2^64 to 2^64-1.
+//
+// Value is the unsigned argument of the CBOR integer, so the value of Integer is:
+//   - Value if Negative is false (CBOR unsigned integer, major type 0)
+//   - -1 - Value if Negative is true (CBOR negative integer, major type 1)
+//
+// Integer can be used as a lightweight alternative to big.Int to decode and encode
+// CBOR integers losslessly.  See IntDecConvertInteger to decode CBOR integers to Integer
+// when decoding to Go interface{}.
+type Integer struct {
+	Value    uint64
+	Negative bool
+}
+
+var typeInteger = reflect.TypeOf(Integer{})
+
+// BigInt returns the value of i as a *big.Int.
+func (i Integer) BigInt() *big.Int {
+	bi := new(big.Int).SetUint64(i.Value)
+	if i.Negative {
+		bi.Add(bi, big.NewInt(1))
+		bi.Neg(bi)
+	}
+	return bi
+}
+
+// Int64 returns the value of i as int64, and false if i overflows int64.
+func (i Integer) Int64() (int64, bool) {
+	if i.Value > math.MaxInt64 {
+		return 0, false
+	}
+	if i.Negative {
+		return int64(-1) ^ int64(i.Value), true
+	}
+	return int64(i.Value), true
+}
+
+// Uint64 returns the value of i as uint64, and false if i is negative.
+func (i Integer) Uint64() (uint64, bool) {
+	if i.Negative {
+		return 0, false
+	}
+	return i.Value, true
+}
+
+// String returns the decimal representation of i.
+func (i Integer) String() string {
+	if !i.Negative {
+		return strconv.FormatUint(i.Value, 10)
+	}
+	if i.Value < math.MaxUint64 {
+		return "-" + strconv.FormatUint(i.Value+1, 10)
+	}
+	return i.BigInt().String()
+}
+
+// MarshalCBOR encodes Integer as CBOR unsigned integer (major type 0) or
+// CBOR negative integer (major type 1).
+func (i Integer) MarshalCBOR() ([]byte, error) {
+	t := cborTypePositiveInt
+	if i.Negative {
+		t = cborTypeNegativeInt
+	}
+
+	e := getEncodeBuffer()
+	encodeHead(e, byte(t), i.Value)
+
+	buf := make([]byte, e.Len())
+	copy(buf, e.Bytes())
+
+	putEncodeBuffer(e)
+	return buf, nil
+}
+
+// UnmarshalCBOR decodes CBOR unsigned integer (major type 0) or
+// CBOR negative integer (major type 1) to Integer.
+// Decoding CBOR null and undefined to Integer is no-op.
+func (i *Integer) UnmarshalCBOR(data []byte) error {
+	if i == nil {
+		return errors.New("cbor.Integer: UnmarshalCBOR on nil pointer")
+	}
+
+	// Decoding CBOR null and undefined to cbor.Integer is no-op.
+	if len(data) == 1 && (data[0] == 0xf6 || data[0] == 0xf7) {
+		return nil
+	}
+
+	d := decoder{data: data, dm: defaultDecMode}
+
+	typ, _, val := d.getHead()
+	if typ != cborTypePositiveInt && typ != cborTypeNegativeInt {
+		return &UnmarshalTypeError{CBORType: typ.String(), GoType: typeInteger.String()}
+	}
+
+	*i = Integer{Value: val, Negative: typ == cborTypeNegativeInt}
+	return nil
+}
+
+// key returns the value of i as int64 if it fits, otherwise i.  It is used
+// to report and detect duplicate integer map keys when decoding to struct.
+func (i Integer) key() interface{} {
+	if n, ok := i.Int64(); ok {
+		return n
+	}
+	return i
+}
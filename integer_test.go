@@ -0,0 +1,136 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestInteger(t *testing.T) {
+	testCases := []struct {
+		name       string
+		data       []byte
+		want       Integer
+		wantString string
+		wantInt64  int64
+		wantFits   bool
+	}{
+		{name: "0", data: hexDecode("00"), want: Integer{Value: 0}, wantString: "0", wantInt64: 0, wantFits: true},
+		{name: "-1", data: hexDecode("20"), want: Integer{Value: 0, Negative: true}, wantString: "-1", wantInt64: -1, wantFits: true},
+		{name: "1000000", data: hexDecode("1a000f4240"), want: Integer{Value: 1000000}, wantString: "1000000", wantInt64: 1000000, wantFits: true},
+		{name: "-1000000", data: hexDecode("3a000f423f"), want: Integer{Value: 999999, Negative: true}, wantString: "-1000000", wantInt64: -1000000, wantFits: true},
+		{name: "math.MaxInt64", data: hexDecode("1b7fffffffffffffff"), want: Integer{Value: 9223372036854775807}, wantString: "9223372036854775807", wantInt64: 9223372036854775807, wantFits: true},
+		{name: "math.MinInt64", data: hexDecode("3b7fffffffffffffff"), want: Integer{Value: 9223372036854775807, Negative: true}, wantString: "-9223372036854775808", wantInt64: -9223372036854775808, wantFits: true},
+		{name: "math.MaxUint64", data: hexDecode("1bffffffffffffffff"), want: Integer{Value: 18446744073709551615}, wantString: "18446744073709551615"},
+		{name: "-18446744073709551616", data: hexDecode("3bffffffffffffffff"), want: Integer{Value: 18446744073709551615, Negative: true}, wantString: "-18446744073709551616"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Decode to Integer
+			var i Integer
+			if err := Unmarshal(tc.data, &i); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if i != tc.want {
+				t.Errorf("Unmarshal(0x%x) = %+v, want %+v", tc.data, i, tc.want)
+			}
+
+			// Decode to empty interface
+			dm, err := DecOptions{IntDec: IntDecConvertInteger}.DecMode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var v interface{}
+			if err := dm.Unmarshal(tc.data, &v); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if v != tc.want {
+				t.Errorf("Unmarshal(0x%x) = %v (%T), want %+v", tc.data, v, v, tc.want)
+			}
+
+			// Encode
+			b, err := Marshal(tc.want)
+			if err != nil {
+				t.Fatalf("Marshal(%+v) returned error %v", tc.want, err)
+			}
+			if !bytes.Equal(b, tc.data) {
+				t.Errorf("Marshal(%+v) = 0x%x, want 0x%x", tc.want, b, tc.data)
+			}
+
+			if s := tc.want.String(); s != tc.wantString {
+				t.Errorf("String() = %q, want %q", s, tc.wantString)
+			}
+			wantBigInt, _ := new(big.Int).SetString(tc.wantString, 10)
+			if bi := tc.want.BigInt(); bi.Cmp(wantBigInt) != 0 {
+				t.Errorf("BigInt() = %s, want %s", bi, wantBigInt)
+			}
+			if i64, ok := tc.want.Int64(); ok != tc.wantFits || i64 != tc.wantInt64 {
+				t.Errorf("Int64() = %d, %t, want %d, %t", i64, ok, tc.wantInt64, tc.wantFits)
+			}
+			if u64, ok := tc.want.Uint64(); ok == tc.want.Negative || (ok && u64 != tc.want.Value) {
+				t.Errorf("Uint64() = %d, %t, want %d, %t", u64, ok, tc.want.Value, !tc.want.Negative)
+			}
+		})
+	}
+}
+
+func TestIntegerInContainer(t *testing.T) {
+	// {-1: [0, -18446744073709551616]}
+	data := hexDecode("a120823bffffffffffffffff00")
+
+	dm, err := DecOptions{IntDec: IntDecConvertInteger}.DecMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err := dm.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	want := map[interface{}]interface{}{
+		Integer{Negative: true}: []interface{}{Integer{Value: 18446744073709551615, Negative: true}, Integer{}},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("Unmarshal(0x%x) = %v, want %v", data, v, want)
+	}
+
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(%v) returned error %v", v, err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Errorf("Marshal(%v) = 0x%x, want 0x%x", v, b, data)
+	}
+}
+
+func TestUnmarshalIntegerError(t *testing.T) {
+	var i *Integer
+	wantErrorMsg := "cbor.Integer: UnmarshalCBOR on nil pointer"
+	if err := i.UnmarshalCBOR(hexDecode("00")); err == nil {
+		t.Errorf("UnmarshalCBOR() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("UnmarshalCBOR() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+
+	var v Integer
+	data := hexDecode("6161")
+	wantErrorMsg = "cbor: cannot unmarshal UTF-8 text string into Go value of type cbor.Integer"
+	if err := Unmarshal(data, &v); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+	} else if _, ok := err.(*UnmarshalTypeError); !ok {
+		t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want *UnmarshalTypeError", data, err)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
+	}
+
+	v = Integer{Value: 1}
+	data = hexDecode("f6")
+	if err := Unmarshal(data, &v); err != nil {
+		t.Errorf("Unmarshal(0x%x) returned error %v", data, err)
+	} else if v != (Integer{Value: 1}) {
+		t.Errorf("Unmarshal(0x%x) = %+v, want unmodified value", data, v)
+	}
+}
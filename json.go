@@ -0,0 +1,480 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/x448/float16"
+)
+
+// jsonEncMode encodes JSON numbers as CBOR integers (or bignums), or as floating-point
+// numbers in the shortest form that preserves their values.
+var jsonEncMode, _ = EncOptions{ShortestFloat: ShortestFloat16, JSONNumber: JSONNumberToNumber}.encMode()
+
+// JSONOption configures conversion of CBOR to JSON by ToJSON.
+type JSONOption func(*jsonEncoder)
+
+// WithJSONStrict makes ToJSON return an error for data items that don't have a JSON
+// equivalent (undefined, simple values other than false, true, and null, NaN, and
+// infinity) instead of converting them to null, and for map keys that aren't text strings
+// instead of converting them to JSON strings.
+func WithJSONStrict() JSONOption {
+	return func(je *jsonEncoder) {
+		je.strict = true
+	}
+}
+
+// WithJSONBignumAsNumber makes ToJSON convert bignums (tag 2 and 3) to JSON numbers
+// instead of base64url-encoded JSON strings.  Note that many JSON implementations
+// can't decode integers outside the range of float64 without losing precision.
+func WithJSONBignumAsNumber() JSONOption {
+	return func(je *jsonEncoder) {
+		je.bignumAsNumber = true
+	}
+}
+
+// ToJSON converts a CBOR data item to JSON and writes it to w, following RFC 8949
+// Section 6.1 without decoding data to Go values.  It returns an error if data isn't
+// a single well-formed CBOR data item, or if the data item can't be converted to JSON.
+// Nothing is written to w if an error is returned during conversion.
+//
+// CBOR data types are converted to JSON as follows:
+//   - integers and floating-point numbers are JSON numbers,
+//   - NaN and infinity are null (see WithJSONStrict),
+//   - byte strings are base64url-encoded JSON strings without padding, unless they are
+//     enclosed in tag 22 (base64 with padding) or 23 (base16 with uppercase letters),
+//   - bignums (tag 2 and 3) are base64url-encoded JSON strings, with "~" prefix for
+//     negative bignums (see WithJSONBignumAsNumber),
+//   - text strings are JSON strings,
+//   - arrays are JSON arrays, and maps are JSON objects,
+//   - false, true, and null are JSON false, true, and null,
+//   - undefined and other simple values are null (see WithJSONStrict),
+//   - other tags are converted to their tag content.
+//
+// Map keys that aren't text strings are converted to JSON strings containing their
+// JSON representation (e.g. integer 1 to "1"), which can result in duplicate keys.
+// Maps and arrays can't be map keys.
+func ToJSON(data []byte, w io.Writer, opts ...JSONOption) error {
+	d := decoder{data: data, dm: defaultDecMode}
+	if err := d.wellformed(false, false); err != nil {
+		return err
+	}
+	d.reset(data)
+
+	je := jsonEncoder{d: &d}
+	for _, opt := range opts {
+		opt(&je)
+	}
+	if err := je.value(0); err != nil {
+		return err
+	}
+	_, err := w.Write(je.b.Bytes())
+	return err
+}
+
+type jsonEncoder struct {
+	d              *decoder
+	b              bytes.Buffer
+	strict         bool
+	bignumAsNumber bool
+}
+
+// value writes JSON representation of the data item at d.off and moves cursor past it.
+// laterEncoding is the tag number (21, 22, or 23) of the innermost enclosing expected
+// later encoding tag, or 0 if there isn't one.
+func (je *jsonEncoder) value(laterEncoding uint64) error {
+	d := je.d
+
+	t := d.nextCBORType()
+	switch t {
+	case cborTypePositiveInt:
+		_, _, val := d.getHead()
+		je.b.WriteString(strconv.FormatUint(val, 10))
+		return nil
+
+	case cborTypeNegativeInt:
+		_, _, val := d.getHead()
+		je.b.WriteString(Integer{Value: val, Negative: true}.String())
+		return nil
+
+	case cborTypeByteString:
+		b, _ := d.parseByteString()
+		writeJSONString(&je.b, jsonByteString(b, laterEncoding))
+		return nil
+
+	case cborTypeTextString:
+		b, err := d.parseTextString()
+		if err != nil {
+			return err
+		}
+		writeJSONString(&je.b, string(b))
+		return nil
+
+	case cborTypeArray, cborTypeMap:
+		_, _, val, indefiniteLength := d.getHeadWithIndefiniteLengthFlag()
+		count := int(val)
+		if indefiniteLength {
+			count = d.numOfItemsUntilBreak()
+			if t == cborTypeMap {
+				count /= 2
+			}
+		}
+
+		if t == cborTypeArray {
+			je.b.WriteByte('[')
+		} else {
+			je.b.WriteByte('{')
+		}
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				je.b.WriteByte(',')
+			}
+			if t == cborTypeMap {
+				if err := je.mapKey(laterEncoding); err != nil {
+					return err
+				}
+				je.b.WriteByte(':')
+			}
+			if err := je.value(laterEncoding); err != nil {
+				return err
+			}
+		}
+		if t == cborTypeArray {
+			je.b.WriteByte(']')
+		} else {
+			je.b.WriteByte('}')
+		}
+
+		if indefiniteLength {
+			d.off++ // Skip break code
+		}
+		return nil
+
+	case cborTypeTag:
+		_, _, tagNum := d.getHead()
+		switch tagNum {
+		case tagNumUnsignedBignum, tagNumNegativeBignum:
+			if d.nextCBORType() != cborTypeByteString {
+				return je.value(laterEncoding)
+			}
+			b, _ := d.parseByteString()
+			if je.bignumAsNumber {
+				bi := new(big.Int).SetBytes(b)
+				if tagNum == tagNumNegativeBignum {
+					bi.Add(bi, big.NewInt(1))
+					bi.Neg(bi)
+				}
+				je.b.WriteString(bi.String())
+				return nil
+			}
+			s := base64.RawURLEncoding.EncodeToString(b)
+			if tagNum == tagNumNegativeBignum {
+				s = "~" + s
+			}
+			writeJSONString(&je.b, s)
+			return nil
+
+		case tagNumExpectedLaterEncodingBase64URL, tagNumExpectedLaterEncodingBase64, tagNumExpectedLaterEncodingBase16:
+			return je.value(tagNum)
+		}
+		return je.value(laterEncoding)
+	}
+
+	// CBOR primitives
+	_, ai, val := d.getHead()
+	switch ai {
+	case additionalInformationAsFalse:
+		je.b.WriteString("false")
+		return nil
+
+	case additionalInformationAsTrue:
+		je.b.WriteString("true")
+		return nil
+
+	case additionalInformationAsNull:
+		je.b.WriteString("null")
+		return nil
+
+	case additionalInformationAsUndefined:
+		return je.substitute("undefined")
+
+	case additionalInformationAsFloat16:
+		f := float64(float16.Frombits(uint16(val)).Float32())
+		return je.float(f, 32)
+
+	case additionalInformationAsFloat32:
+		f := float64(math.Float32frombits(uint32(val)))
+		return je.float(f, 32)
+
+	case additionalInformationAsFloat64:
+		return je.float(math.Float64frombits(val), 64)
+	}
+	return je.substitute("simple value " + strconv.FormatUint(val, 10))
+}
+
+// mapKey writes JSON string representation of the map key at d.off.
+func (je *jsonEncoder) mapKey(laterEncoding uint64) error {
+	t := je.d.nextCBORType()
+	if t != cborTypeTextString && (je.strict || t == cborTypeArray || t == cborTypeMap) {
+		return errors.New("cbor: map key of CBOR type " + t.String() + " can't be converted to JSON")
+	}
+
+	start := je.b.Len()
+	if err := je.value(laterEncoding); err != nil {
+		return err
+	}
+	if k := je.b.Bytes()[start:]; k[0] != '"' {
+		if k[0] == '[' || k[0] == '{' {
+			return errors.New("cbor: map key of CBOR type " + t.String() + " can't be converted to JSON")
+		}
+		s := string(k)
+		je.b.Truncate(start)
+		writeJSONString(&je.b, s)
+	}
+	return nil
+}
+
+// substitute writes null in place of a data item that doesn't have a JSON equivalent,
+// or returns an error if strict is true.
+func (je *jsonEncoder) substitute(what string) error {
+	if je.strict {
+		return errors.New("cbor: " + what + " can't be converted to JSON")
+	}
+	je.b.WriteString("null")
+	return nil
+}
+
+// float writes floating-point number f as JSON number.  bitSize is 32 if f was
+// decoded from float16 or float32, so it is formatted using the shortest representation
+// that roundtrips to float32.
+func (je *jsonEncoder) float(f float64, bitSize int) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return je.substitute("floating-point number " + strconv.FormatFloat(f, 'g', -1, 64))
+	}
+
+	// Same as encoding/json, use exponent only for very small and very large numbers.
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f, format, -1, bitSize)
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		if n := len(s); n >= 4 && s[n-4] == 'e' && s[n-3] == '-' && s[n-2] == '0' {
+			s = s[:n-2] + s[n-1:]
+		}
+	}
+	je.b.WriteString(s)
+	return nil
+}
+
+// jsonByteString returns byte string b encoded as specified by expected later encoding
+// tag number, or in base64url without padding by default (RFC 8949 Section 6.1).
+func jsonByteString(b []byte, laterEncoding uint64) string {
+	switch laterEncoding {
+	case tagNumExpectedLaterEncodingBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	case tagNumExpectedLaterEncodingBase16:
+		return strings.ToUpper(hex.EncodeToString(b))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// writeJSONString writes s as JSON string.  Invalid UTF-8 is replaced with U+FFFD.
+func writeJSONString(b *bytes.Buffer, s string) {
+	const hexDigits = "0123456789abcdef"
+
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			case c == '\n':
+				b.WriteString(`\n`)
+			case c == '\r':
+				b.WriteString(`\r`)
+			case c == '\t':
+				b.WriteString(`\t`)
+			case c < 0x20:
+				b.WriteString(`\u00`)
+				b.WriteByte(hexDigits[c>>4])
+				b.WriteByte(hexDigits[c&0xf])
+			default:
+				b.WriteByte(c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b.WriteString("\ufffd")
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	b.WriteByte('"')
+}
+
+// FromJSON converts a JSON value read from r to a CBOR data item, following RFC 8949
+// Section 6.2 without decoding JSON to Go values.  It returns an error if r doesn't
+// contain exactly one JSON value, or if a JSON object has duplicate keys.
+//
+// JSON numbers without fraction and exponent are encoded as CBOR integers, or as bignums
+// if they don't fit in CBOR integers.  Other JSON numbers are encoded as floating-point
+// numbers in the shortest form that preserves their values.  JSON strings are encoded as
+// text strings, JSON arrays and objects are encoded as definite-length arrays and maps
+// (in document order), and JSON false, true, and null are encoded as CBOR false, true,
+// and null.
+func FromJSON(r io.Reader) ([]byte, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var e bytes.Buffer
+	if err := encodeJSONValue(&e, dec, 1); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		if err == nil {
+			err = errors.New("cbor: invalid JSON: data after top-level value")
+		}
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// encodeJSONValue encodes the next JSON value read by dec to e.
+func encodeJSONValue(e *bytes.Buffer, dec *json.Decoder, depth int) error {
+	if depth > maxMaxNestedLevels {
+		return errors.New("cbor: exceeded max nested level " + strconv.Itoa(maxMaxNestedLevels) + " in JSON")
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		// JSON decoder verifies that delimiters are balanced, so tok is '[' or '{'.
+		var elems bytes.Buffer
+		count := 0
+		var keys map[string]struct{}
+		if tok == '{' {
+			keys = make(map[string]struct{})
+		}
+		for dec.More() {
+			if tok == '{' {
+				kt, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				k := kt.(string)
+				if _, ok := keys[k]; ok {
+					return errors.New("cbor: duplicate map key " + strconv.Quote(k) + " in JSON")
+				}
+				keys[k] = struct{}{}
+				encodeHead(&elems, byte(cborTypeTextString), uint64(len(k)))
+				elems.WriteString(k)
+			}
+			if err := encodeJSONValue(&elems, dec, depth+1); err != nil {
+				return err
+			}
+			count++
+		}
+		if _, err := dec.Token(); err != nil { // Closing delimiter
+			return err
+		}
+		if tok == '[' {
+			encodeHead(e, byte(cborTypeArray), uint64(count))
+		} else {
+			encodeHead(e, byte(cborTypeMap), uint64(count))
+		}
+		e.Write(elems.Bytes())
+		return nil
+
+	case string:
+		encodeHead(e, byte(cborTypeTextString), uint64(len(tok)))
+		e.WriteString(tok)
+		return nil
+
+	case json.Number:
+		return encodeJSONNumber(e, jsonEncMode, reflect.ValueOf(tok))
+
+	case bool:
+		if tok {
+			e.WriteByte(byte(cborTypePrimitives) | additionalInformationAsTrue)
+		} else {
+			e.WriteByte(byte(cborTypePrimitives) | additionalInformationAsFalse)
+		}
+		return nil
+	}
+
+	// JSON null
+	e.WriteByte(byte(cborTypePrimitives) | additionalInformationAsNull)
+	return nil
+}
+
+// rawMessageJSON is the JSON representation of RawMessage.
+type rawMessageJSON struct {
+	Diag string `json:"diag,omitempty"`
+	CBOR []byte `json:"cbor"`
+}
+
+// MarshalJSON returns m as JSON object with the diagnostic notation of m in "diag"
+// member, and the base64-encoded m in "cbor" member, so structs containing RawMessage
+// can be logged with encoding/json.  "diag" member is omitted if m isn't a single
+// well-formed CBOR data item.  Nil or empty m is returned as JSON null.
+func (m RawMessage) MarshalJSON() ([]byte, error) {
+	if len(m) == 0 {
+		return []byte("null"), nil
+	}
+	diag, err := Diagnose(m)
+	if err != nil {
+		diag = ""
+	}
+	return json.Marshal(rawMessageJSON{Diag: diag, CBOR: []byte(m)})
+}
+
+// UnmarshalJSON decodes base64-encoded CBOR data from "cbor" member of JSON object
+// returned by MarshalJSON and saves a copy to *m.  "diag" member is ignored.  JSON null
+// is a no-op.
+func (m *RawMessage) UnmarshalJSON(data []byte) error {
+	if m == nil {
+		return errors.New("cbor.RawMessage: UnmarshalJSON on nil pointer")
+	}
+	if string(data) == "null" {
+		return nil
+	}
+	var v rawMessageJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if len(v.CBOR) == 0 {
+		return errors.New("cbor.RawMessage: UnmarshalJSON requires \"cbor\" member")
+	}
+	if err := Wellformed(v.CBOR); err != nil {
+		return err
+	}
+	*m = append((*m)[0:0], v.CBOR...)
+	return nil
+}
@@ -0,0 +1,285 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestToJSON(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+		opts []JSONOption
+		want string
+	}{
+		{"positive integer", hexDecode("1903e8"), nil, `1000`},
+		{"negative integer", hexDecode("3bffffffffffffffff"), nil, `-18446744073709551616`},
+		{"positive bignum", hexDecode("c249010000000000000000"), nil, `"AQAAAAAAAAAA"`},
+		{"negative bignum", hexDecode("c349010000000000000000"), nil, `"~AQAAAAAAAAAA"`},
+		{"positive bignum as number", hexDecode("c249010000000000000000"), []JSONOption{WithJSONBignumAsNumber()}, `18446744073709551616`},
+		{"negative bignum as number", hexDecode("c349010000000000000000"), []JSONOption{WithJSONBignumAsNumber()}, `-18446744073709551617`},
+		{"float16", hexDecode("f93e00"), nil, `1.5`},
+		{"float32", hexDecode("fa3dcccccd"), nil, `0.1`},
+		{"float64", hexDecode("fbc010666666666666"), nil, `-4.1`},
+		{"large float64", hexDecode("fb7e37e43c8800759c"), nil, `1e+300`},
+		{"small float64", hexDecode("fb3eb0c6f7a0b5ed8d"), nil, `0.000001`},
+		{"smaller float64", hexDecode("fb3e7ad7f29abcaf48"), nil, `1e-7`},
+		{"integral float64", hexDecode("fb4059000000000000"), nil, `100`},
+		{"NaN", hexDecode("f97e00"), nil, `null`},
+		{"infinity", hexDecode("f97c00"), nil, `null`},
+		{"byte string", hexDecode("4401020304"), nil, `"AQIDBA"`},
+		{"indefinite-length byte string", hexDecode("5f42010243030405ff"), nil, `"AQIDBAU"`},
+		{"byte string with tag 21", hexDecode("d5434dfbff"), nil, `"Tfv_"`},
+		{"byte string with tag 22", hexDecode("d6434dfbff"), nil, `"Tfv/"`},
+		{"byte string with tag 22 and padding", hexDecode("d6424dfb"), nil, `"Tfs="`},
+		{"byte string with tag 23", hexDecode("d7434dfbff"), nil, `"4DFBFF"`},
+		{"array with tag 23", hexDecode("d7824101814102"), nil, `["01",["02"]]`},
+		{"text string", hexDecode("6449455446"), nil, `"IETF"`},
+		{"text string with escapes", hexDecode("66225c0a09017f"), nil, `"\"\\\n\t\u0001` + "\x7f" + `"`},
+		{"text string with non-ASCII", hexDecode("62c3bc"), nil, `"ü"`},
+		{"false", hexDecode("f4"), nil, `false`},
+		{"true", hexDecode("f5"), nil, `true`},
+		{"null", hexDecode("f6"), nil, `null`},
+		{"undefined", hexDecode("f7"), nil, `null`},
+		{"simple value", hexDecode("f0"), nil, `null`},
+		{"empty array", hexDecode("80"), nil, `[]`},
+		{"array", hexDecode("83010203"), nil, `[1,2,3]`},
+		{"indefinite-length array", hexDecode("9f018202039f0405ffff"), nil, `[1,[2,3],[4,5]]`},
+		{"empty map", hexDecode("a0"), nil, `{}`},
+		{"map", hexDecode("a26161016162820203"), nil, `{"a":1,"b":[2,3]}`},
+		{"indefinite-length map", hexDecode("bf61610161629f0203ffff"), nil, `{"a":1,"b":[2,3]}`},
+		{"map with integer keys", hexDecode("a201616120f6"), nil, `{"1":"a","-1":null}`},
+		{"map with byte string key", hexDecode("a1420102f5"), nil, `{"AQI":true}`},
+		{"map with tag 23 byte string key", hexDecode("a1d7420102f5"), nil, `{"0102":true}`},
+		{"time tag", hexDecode("c11a514b67b0"), nil, `1363896240`},
+		{"unrecognized tag", hexDecode("d9d9f7a16161d82a01"), nil, `{"a":1}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := ToJSON(tc.data, &b, tc.opts...); err != nil {
+				t.Fatalf("ToJSON(0x%x) returned error %v", tc.data, err)
+			}
+			if b.String() != tc.want {
+				t.Errorf("ToJSON(0x%x) = %s, want %s", tc.data, b.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestToJSONError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         []byte
+		opts         []JSONOption
+		wantErrorMsg string
+	}{
+		{"malformed data", hexDecode("81"), nil, "unexpected EOF"},
+		{"extraneous data", hexDecode("0102"), nil, "cbor: 1 bytes of extraneous data starting at index 1"},
+		{"invalid UTF-8 string", hexDecode("61ff"), nil, "cbor: invalid UTF-8 string"},
+		{"array map key", hexDecode("a18001"), nil, "cbor: map key of CBOR type array can't be converted to JSON"},
+		{"map map key", hexDecode("a1a001"), nil, "cbor: map key of CBOR type map can't be converted to JSON"},
+		{"tagged array map key", hexDecode("a1c18001"), nil, "cbor: map key of CBOR type tag can't be converted to JSON"},
+		{"strict undefined", hexDecode("f7"), []JSONOption{WithJSONStrict()}, "cbor: undefined can't be converted to JSON"},
+		{"strict simple value", hexDecode("f0"), []JSONOption{WithJSONStrict()}, "cbor: simple value 16 can't be converted to JSON"},
+		{"strict NaN", hexDecode("f97e00"), []JSONOption{WithJSONStrict()}, "cbor: floating-point number NaN can't be converted to JSON"},
+		{"strict infinity", hexDecode("f9fc00"), []JSONOption{WithJSONStrict()}, "cbor: floating-point number -Inf can't be converted to JSON"},
+		{"strict integer map key", hexDecode("a10102"), []JSONOption{WithJSONStrict()}, "cbor: map key of CBOR type positive integer can't be converted to JSON"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b bytes.Buffer
+			err := ToJSON(tc.data, &b, tc.opts...)
+			if err == nil {
+				t.Errorf("ToJSON(0x%x) didn't return an error", tc.data)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("ToJSON(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+			if b.Len() != 0 {
+				t.Errorf("ToJSON(0x%x) wrote %q, want nothing", tc.data, b.String())
+			}
+		})
+	}
+}
+
+func TestToJSONWriterError(t *testing.T) {
+	wantErr := errors.New("write error")
+	err := ToJSON(hexDecode("01"), errorWriter{wantErr})
+	if err != wantErr {
+		t.Errorf("ToJSON() returned error %v, want %v", err, wantErr)
+	}
+}
+
+type errorWriter struct {
+	err error
+}
+
+func (w errorWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
+
+func TestFromJSON(t *testing.T) {
+	testCases := []struct {
+		name string
+		json string
+		want []byte
+	}{
+		{"zero", `0`, hexDecode("00")},
+		{"positive integer", `1000`, hexDecode("1903e8")},
+		{"negative integer", `-1000`, hexDecode("3903e7")},
+		{"max uint64", `18446744073709551615`, hexDecode("1bffffffffffffffff")},
+		{"min CBOR negative integer", `-18446744073709551616`, hexDecode("3bffffffffffffffff")},
+		{"positive bignum", `18446744073709551616`, hexDecode("c249010000000000000000")},
+		{"negative bignum", `-18446744073709551617`, hexDecode("c349010000000000000000")},
+		{"float16", `1.5`, hexDecode("f93e00")},
+		{"float32", `100000.0`, hexDecode("fa47c35000")},
+		{"float64", `1.1`, hexDecode("fb3ff199999999999a")},
+		{"exponent", `1e3`, hexDecode("f963d0")},
+		{"negative zero", `-0.0`, hexDecode("f98000")},
+		{"string", `"IETF"`, hexDecode("6449455446")},
+		{"string with escapes", `"\"\\\nü😀"`, hexDecode("69225c0ac3bcf09f9880")},
+		{"false", `false`, hexDecode("f4")},
+		{"true", `true`, hexDecode("f5")},
+		{"null", `null`, hexDecode("f6")},
+		{"empty array", `[]`, hexDecode("80")},
+		{"array", ` [1, [2, 3], [4, 5]] `, hexDecode("8301820203820405")},
+		{"empty object", `{}`, hexDecode("a0")},
+		{"object", `{"b": [2, 3], "a": 1}`, hexDecode("a26162820203616101")},
+		{"nested object", `{"a": {"b": null}}`, hexDecode("a16161a16162f6")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FromJSON(strings.NewReader(tc.json))
+			if err != nil {
+				t.Fatalf("FromJSON(%s) returned error %v", tc.json, err)
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("FromJSON(%s) = 0x%x, want 0x%x", tc.json, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFromJSONError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		json         string
+		wantErrorMsg string
+	}{
+		{"empty", ``, io.ErrUnexpectedEOF.Error()},
+		{"unterminated array", `[1, 2`, "unexpected end of JSON input"},
+		{"invalid value", `[1, x]`, "invalid character 'x' looking for beginning of value"},
+		{"trailing data", `1 2`, "cbor: invalid JSON: data after top-level value"},
+		{"duplicate key", `{"a": 1, "a": 2}`, `cbor: duplicate map key "a" in JSON`},
+		{"float overflow", `1e400`, `cbor: unsupported value: json.Number "1e400" overflows float64`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := FromJSON(strings.NewReader(tc.json))
+			if err == nil {
+				t.Errorf("FromJSON(%s) didn't return an error", tc.json)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("FromJSON(%s) returned error %q, want %q", tc.json, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	data := hexDecode("a3616101616282f5f6616383f93e006449455446a0")
+	var b bytes.Buffer
+	if err := ToJSON(data, &b); err != nil {
+		t.Fatalf("ToJSON(0x%x) returned error %v", data, err)
+	}
+	got, err := FromJSON(&b)
+	if err != nil {
+		t.Fatalf("FromJSON() returned error %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("FromJSON(ToJSON(0x%x)) = 0x%x", data, got)
+	}
+}
+
+func TestRawMessageJSON(t *testing.T) {
+	type envelope struct {
+		ID      int        `json:"id"`
+		Payload RawMessage `json:"payload"`
+		Extra   RawMessage `json:"extra"`
+	}
+
+	testCases := []struct {
+		name     string
+		v        envelope
+		wantJSON string
+	}{
+		{
+			name:     "well-formed",
+			v:        envelope{ID: 1, Payload: hexDecode("a1016161")}, // {1: "a"}
+			wantJSON: `{"id":1,"payload":{"diag":"{1: \"a\"}","cbor":"oQFhYQ=="},"extra":null}`,
+		},
+		{
+			name:     "malformed",
+			v:        envelope{ID: 2, Payload: hexDecode("a101")},
+			wantJSON: `{"id":2,"payload":{"cbor":"oQE="},"extra":null}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := json.Marshal(tc.v)
+			if err != nil {
+				t.Fatalf("json.Marshal(%v) returned error %v", tc.v, err)
+			}
+			if string(b) != tc.wantJSON {
+				t.Errorf("json.Marshal(%v) = %s, want %s", tc.v, b, tc.wantJSON)
+			}
+		})
+	}
+
+	var v envelope
+	data := []byte(`{"id":1,"payload":{"diag":"ignored","cbor":"oQFhYQ=="},"extra":null}`)
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("json.Unmarshal(%s) returned error %v", data, err)
+	}
+	if want := hexDecode("a1016161"); !bytes.Equal(v.Payload, want) {
+		t.Errorf("json.Unmarshal(%s) payload = 0x%x, want 0x%x", data, []byte(v.Payload), want)
+	}
+	if v.Extra != nil {
+		t.Errorf("json.Unmarshal(%s) extra = 0x%x, want nil", data, []byte(v.Extra))
+	}
+}
+
+func TestRawMessageUnmarshalJSONError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         string
+		wantErrorMsg string
+	}{
+		{"missing cbor member", `{"diag":"1"}`, "cbor.RawMessage: UnmarshalJSON requires \"cbor\" member"},
+		{"malformed cbor", `{"cbor":"oQE="}`, "unexpected EOF"},
+		{"not object", `"oQFhYQ=="`, "json: cannot unmarshal string into Go value of type cbor.rawMessageJSON"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var m RawMessage
+			err := m.UnmarshalJSON([]byte(tc.data))
+			if err == nil {
+				t.Fatalf("UnmarshalJSON(%s) didn't return an error", tc.data)
+			}
+			if err.Error() != tc.wantErrorMsg {
+				t.Errorf("UnmarshalJSON(%s) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+
+	var m *RawMessage
+	if err := m.UnmarshalJSON([]byte("null")); err == nil {
+		t.Errorf("UnmarshalJSON() on nil pointer didn't return an error")
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var typeJSONNumber = reflect.TypeOf(json.Number(""))
+
+// encodeJSONNumber encodes json.Number as CBOR text string, or as CBOR number with
+// JSONNumberToNumber.  Integer literals are encoded as CBOR integers (or bignums if they
+// overflow 64 bits), and other numbers are encoded as floating-point numbers.
+func encodeJSONNumber(e *bytes.Buffer, em *encMode, v reflect.Value) error {
+	if em.jsonNumber != JSONNumberToNumber {
+		return encodeString(e, em, v)
+	}
+
+	s := v.String()
+	if s == "" {
+		// Same as encoding/json, empty json.Number is encoded as 0.
+		s = "0"
+	}
+	if !isValidJSONNumber(s) {
+		return &UnsupportedValueError{msg: "invalid json.Number " + strconv.Quote(s)}
+	}
+
+	if b := em.encTagBytes(v.Type()); b != nil {
+		e.Write(b)
+	}
+
+	if !strings.ContainsAny(s, ".eE") {
+		bi, _ := new(big.Int).SetString(s, 10)
+		if bi.IsInt64() || bi.IsUint64() {
+			if bi.Sign() >= 0 {
+				encodeHead(e, byte(cborTypePositiveInt), bi.Uint64())
+			} else {
+				encodeHead(e, byte(cborTypeNegativeInt), uint64(-1-bi.Int64()))
+			}
+			return nil
+		}
+		if bi.Sign() < 0 {
+			// CBOR negative integer (-1-n) can represent integers down to -2^64.
+			n := new(big.Int).Neg(bi)
+			n.Sub(n, big.NewInt(1))
+			if n.IsUint64() {
+				if err := em.acceptableNegativeInt(n.Uint64()); err != nil {
+					return err
+				}
+				encodeHead(e, byte(cborTypeNegativeInt), n.Uint64())
+				return nil
+			}
+		}
+		return encodeBigInt(e, em, reflect.ValueOf(*bi))
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		// Number is valid, so err is range error.
+		return &UnsupportedValueError{msg: "json.Number " + strconv.Quote(s) + " overflows float64"}
+	}
+	return encodeFloat(e, em, reflect.ValueOf(f))
+}
+
+// isValidJSONNumber returns true if s is a valid JSON number (RFC 8259 Section 6).
+func isValidJSONNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	// Optional minus sign
+	if s[0] == '-' {
+		s = s[1:]
+		if s == "" {
+			return false
+		}
+	}
+
+	// Integer part
+	switch {
+	case s[0] == '0':
+		s = s[1:]
+	case '1' <= s[0] && s[0] <= '9':
+		s = s[1:]
+		for s != "" && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	default:
+		return false
+	}
+
+	// Optional fraction part
+	if len(s) >= 2 && s[0] == '.' && '0' <= s[1] && s[1] <= '9' {
+		s = s[2:]
+		for s != "" && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// Optional exponent part
+	if len(s) >= 2 && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if s[0] == '+' || s[0] == '-' {
+			s = s[1:]
+			if s == "" {
+				return false
+			}
+		}
+		for s != "" && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	return s == ""
+}
+
+// parseToJSONNumber decodes CBOR integer, bignum, or floating-point number to json.Number v.
+// It returns false if the next data item isn't a number, so it can be decoded as usual.
+func (d *decoder) parseToJSONNumber(v reflect.Value) (bool, error) {
+	t := d.nextCBORType()
+	switch t {
+	case cborTypePositiveInt:
+		_, _, val := d.getHead()
+		v.SetString(strconv.FormatUint(val, 10))
+		return true, nil
+
+	case cborTypeNegativeInt:
+		_, _, val := d.getHead()
+		v.SetString(Integer{Value: val, Negative: true}.String())
+		return true, nil
+
+	case cborTypeTag:
+		off := d.off
+		_, _, tagNum := d.getHead()
+		if tagNum != tagNumUnsignedBignum && tagNum != tagNumNegativeBignum {
+			d.off = off
+			return false, nil
+		}
+		b, _ := d.parseByteString()
+		bi := new(big.Int).SetBytes(b)
+		if tagNum == tagNumNegativeBignum {
+			bi.Add(bi, big.NewInt(1))
+			bi.Neg(bi)
+		}
+		v.SetString(bi.String())
+		return true, nil
+
+	case cborTypePrimitives:
+		ai := getAdditionalInformation(d.data[d.off])
+		f, ok := d.parseFloat()
+		if !ok {
+			return false, nil
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return true, &UnmarshalTypeError{
+				CBORType: t.String(),
+				GoType:   typeJSONNumber.String(),
+				errorMsg: "floating-point number " + strconv.FormatFloat(f, 'g', -1, 64) + " isn't a valid JSON number",
+			}
+		}
+		bitSize := 64
+		if ai != additionalInformationAsFloat64 {
+			// float16 and float32 values are formatted with the shortest representation
+			// that roundtrips to float32.
+			bitSize = 32
+		}
+		v.SetString(strconv.FormatFloat(f, 'g', -1, bitSize))
+		return true, nil
+	}
+	return false, nil
+}
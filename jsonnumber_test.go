@@ -0,0 +1,228 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEncModeInvalidJSONNumber(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         EncOptions
+		wantErrorMsg string
+	}{
+		{
+			name:         "below range of valid modes",
+			opts:         EncOptions{JSONNumber: -1},
+			wantErrorMsg: "cbor: invalid JSONNumber -1",
+		},
+		{
+			name:         "above range of valid modes",
+			opts:         EncOptions{JSONNumber: 101},
+			wantErrorMsg: "cbor: invalid JSONNumber 101",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.EncMode()
+			if err == nil {
+				t.Errorf("EncMode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncMode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestMarshalJSONNumber(t *testing.T) {
+	em, err := EncOptions{JSONNumber: JSONNumberToNumber}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		v    interface{}
+		want []byte
+	}{
+		{name: "zero", v: json.Number("0"), want: hexDecode("00")},
+		{name: "empty", v: json.Number(""), want: hexDecode("00")},
+		{name: "positive integer", v: json.Number("1000"), want: hexDecode("1903e8")},
+		{name: "negative integer", v: json.Number("-1"), want: hexDecode("20")},
+		{name: "max uint64", v: json.Number("18446744073709551615"), want: hexDecode("1bffffffffffffffff")},
+		{name: "min CBOR negative integer", v: json.Number("-18446744073709551616"), want: hexDecode("3bffffffffffffffff")},
+		{name: "positive bignum", v: json.Number("18446744073709551616"), want: hexDecode("c249010000000000000000")},
+		{name: "negative bignum", v: json.Number("-18446744073709551617"), want: hexDecode("c349010000000000000000")},
+		{name: "fraction", v: json.Number("1.5"), want: hexDecode("fb3ff8000000000000")},
+		{name: "exponent", v: json.Number("1e3"), want: hexDecode("fb408f400000000000")},
+		{name: "map value", v: map[string]json.Number{"a": "1"}, want: hexDecode("a1616101")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := em.Marshal(tc.v)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned error %v", tc.v, err)
+			}
+			if !bytes.Equal(b, tc.want) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", tc.v, b, tc.want)
+			}
+		})
+	}
+
+	t.Run("default mode", func(t *testing.T) {
+		b, err := Marshal(json.Number("1.5"))
+		if err != nil {
+			t.Fatalf("Marshal() returned error %v", err)
+		}
+		if want := hexDecode("63312e35"); !bytes.Equal(b, want) {
+			t.Errorf("Marshal() = 0x%x, want 0x%x", b, want)
+		}
+	})
+
+	t.Run("shortest float", func(t *testing.T) {
+		em, err := EncOptions{JSONNumber: JSONNumberToNumber, ShortestFloat: ShortestFloat16}.EncMode()
+		if err != nil {
+			t.Fatalf("EncMode() returned error %v", err)
+		}
+		b, err := em.Marshal(json.Number("1.5"))
+		if err != nil {
+			t.Fatalf("Marshal() returned error %v", err)
+		}
+		if want := hexDecode("f93e00"); !bytes.Equal(b, want) {
+			t.Errorf("Marshal() = 0x%x, want 0x%x", b, want)
+		}
+	})
+}
+
+func TestMarshalJSONNumberError(t *testing.T) {
+	em, err := EncOptions{JSONNumber: JSONNumberToNumber}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		v            json.Number
+		wantErrorMsg string
+	}{
+		{v: "abc", wantErrorMsg: `cbor: unsupported value: invalid json.Number "abc"`},
+		{v: "01", wantErrorMsg: `cbor: unsupported value: invalid json.Number "01"`},
+		{v: "+1", wantErrorMsg: `cbor: unsupported value: invalid json.Number "+1"`},
+		{v: "1.", wantErrorMsg: `cbor: unsupported value: invalid json.Number "1."`},
+		{v: "1e", wantErrorMsg: `cbor: unsupported value: invalid json.Number "1e"`},
+		{v: "-", wantErrorMsg: `cbor: unsupported value: invalid json.Number "-"`},
+		{v: "1e400", wantErrorMsg: `cbor: unsupported value: json.Number "1e400" overflows float64`},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.v), func(t *testing.T) {
+			_, err := em.Marshal(tc.v)
+			if err == nil {
+				t.Fatalf("Marshal(%q) didn't return an error", tc.v)
+			}
+			if _, ok := err.(*UnsupportedValueError); !ok {
+				t.Errorf("Marshal(%q) returned wrong error type %T, want (*UnsupportedValueError)", tc.v, err)
+			}
+			if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Marshal(%q) returned error %q, want %q", tc.v, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONNumber(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+		want json.Number
+	}{
+		{name: "positive integer", data: hexDecode("1903e8"), want: "1000"},
+		{name: "negative integer", data: hexDecode("20"), want: "-1"},
+		{name: "min CBOR negative integer", data: hexDecode("3bffffffffffffffff"), want: "-18446744073709551616"},
+		{name: "positive bignum", data: hexDecode("c249010000000000000000"), want: "18446744073709551616"},
+		{name: "negative bignum", data: hexDecode("c349010000000000000000"), want: "-18446744073709551617"},
+		{name: "float16", data: hexDecode("f93e00"), want: "1.5"},
+		{name: "float32", data: hexDecode("fa3dcccccd"), want: "0.1"},
+		{name: "float64", data: hexDecode("fb3fb999999999999a"), want: "0.1"},
+		{name: "large float64", data: hexDecode("fb4480000000000000"), want: "9.44473296573929e+21"},
+		{name: "text string", data: hexDecode("63312e35"), want: "1.5"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var n json.Number
+			if err := Unmarshal(tc.data, &n); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if n != tc.want {
+				t.Errorf("Unmarshal(0x%x) = %q, want %q", tc.data, n, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONNumberError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "NaN",
+			data:         hexDecode("f97e00"),
+			wantErrorMsg: "cbor: cannot unmarshal primitives into Go value of type json.Number (floating-point number NaN isn't a valid JSON number)",
+		},
+		{
+			name:         "infinity",
+			data:         hexDecode("f97c00"),
+			wantErrorMsg: "cbor: cannot unmarshal primitives into Go value of type json.Number (floating-point number +Inf isn't a valid JSON number)",
+		},
+		{
+			name:         "bool",
+			data:         hexDecode("f5"),
+			wantErrorMsg: "cbor: cannot unmarshal primitives into Go value of type json.Number",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var n json.Number
+			err := Unmarshal(tc.data, &n)
+			if err == nil {
+				t.Fatalf("Unmarshal(0x%x) didn't return an error", tc.data)
+			}
+			if _, ok := err.(*UnmarshalTypeError); !ok {
+				t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*UnmarshalTypeError)", tc.data, err)
+			}
+			if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestJSONNumberRoundTrip(t *testing.T) {
+	em, err := EncOptions{JSONNumber: JSONNumberToNumber}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	in := map[string]json.Number{
+		"int":    "-42",
+		"big":    "123456789012345678901234567890",
+		"float":  "3.25",
+		"tiny":   "5e-324",
+		"uint64": "18446744073709551615",
+	}
+	b, err := em.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal(%v) returned error %v", in, err)
+	}
+	var out map[string]json.Number
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", b, err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Unmarshal(0x%x) = %v, want %v", b, out, in)
+	}
+}
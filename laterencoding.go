@@ -0,0 +1,110 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ExpectedLaterEncodings returns expected later encoding of each byte string in CBOR data
+// item data, keyed by JSON Pointer (RFC 6901) of the byte string.  The value is the number
+// of the nearest enclosing tag 21 (base64url), 22 (base64), or 23 (base16).
+// See "Expected Later Encoding for CBOR-to-JSON Converters" in RFC 8949 Section 3.4.5.2.
+//
+// Decoding data to Go values (e.g. interface{}) doesn't keep these tags for each element,
+// so a CBOR-to-JSON converter can use the returned map to serialize each byte string in its
+// intended encoding.  In JSON Pointer, array elements are referenced by index, and map values
+// by map key: text string as is, integer in decimal, and other types in diagnostic notation.
+// Byte strings not enclosed in tag 21-23 and byte strings in map keys are not included.
+func ExpectedLaterEncodings(data []byte) (map[string]uint64, error) {
+	d := decoder{data: data, dm: defaultDecMode}
+	if err := d.wellformed(false, false); err != nil {
+		return nil, err
+	}
+	d.reset(data)
+
+	encodings := make(map[string]uint64)
+	if err := expectedLaterEncodings(&d, "", 0, encodings); err != nil {
+		return nil, err
+	}
+	return encodings, nil
+}
+
+// expectedLaterEncodings adds expected later encoding tagNum (0 if none) of byte strings
+// in data item at d.off to encodings.
+// It assumes data is well-formed, and does not perform bounds checking.
+func expectedLaterEncodings(d *decoder, path string, tagNum uint64, encodings map[string]uint64) error {
+	off := d.off
+	t, ai, val := d.getHead()
+	indefiniteLength := additionalInformation(ai).isIndefiniteLength()
+
+	switch t {
+	case cborTypeByteString, cborTypeTextString:
+		if t == cborTypeByteString && tagNum != 0 {
+			encodings[path] = tagNum
+		}
+		d.off = off
+		d.skip()
+
+	case cborTypeArray:
+		for i := 0; indefiniteLength || i < int(val); i++ {
+			if indefiniteLength && d.foundBreak() {
+				break
+			}
+			if err := expectedLaterEncodings(d, path+"/"+strconv.Itoa(i), tagNum, encodings); err != nil {
+				return err
+			}
+		}
+
+	case cborTypeMap:
+		for i := 0; indefiniteLength || i < int(val); i++ {
+			if indefiniteLength && d.foundBreak() {
+				break
+			}
+			key, err := jsonPointerMapKey(d)
+			if err != nil {
+				return err
+			}
+			if err := expectedLaterEncodings(d, path+"/"+key, tagNum, encodings); err != nil {
+				return err
+			}
+		}
+
+	case cborTypeTag:
+		switch val {
+		case tagNumExpectedLaterEncodingBase64URL, tagNumExpectedLaterEncodingBase64, tagNumExpectedLaterEncodingBase16:
+			tagNum = val
+		}
+		return expectedLaterEncodings(d, path, tagNum, encodings)
+	}
+	return nil
+}
+
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// jsonPointerMapKey returns escaped JSON Pointer reference token for map key at d.off,
+// and moves cursor past the map key.
+func jsonPointerMapKey(d *decoder) (string, error) {
+	switch t := d.nextCBORType(); t {
+	case cborTypeTextString:
+		b, err := d.parseTextString()
+		if err != nil {
+			return "", err
+		}
+		return jsonPointerEscaper.Replace(string(b)), nil
+
+	case cborTypePositiveInt, cborTypeNegativeInt:
+		_, _, val := d.getHead()
+		return Integer{Value: val, Negative: t == cborTypeNegativeInt}.String(), nil
+	}
+
+	off := d.off
+	d.skip()
+	s, err := defaultDiagMode.Diagnose(d.data[off:d.off])
+	if err != nil {
+		return "", err
+	}
+	return jsonPointerEscaper.Replace(s), nil
+}
@@ -0,0 +1,109 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestExpectedLaterEncodings(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+		want map[string]uint64
+	}{
+		{
+			name: "no tags",
+			data: hexDecode("8241004101"),
+			want: map[string]uint64{},
+		},
+		{
+			name: "top-level byte string",
+			data: hexDecode("d54100"),
+			want: map[string]uint64{"": 21},
+		},
+		{
+			name: "array elements",
+			data: hexDecode("d6824101d54102"),
+			want: map[string]uint64{"/0": 22, "/1": 21},
+		},
+		{
+			name: "nearest tag takes precedence",
+			data: hexDecode("d582d641004101"),
+			want: map[string]uint64{"/0": 22, "/1": 21},
+		},
+		{
+			name: "map values",
+			data: hexDecode("a26161d741ff61624100"),
+			want: map[string]uint64{"/a": 23},
+		},
+		{
+			name: "integer and escaped text string map keys",
+			data: hexDecode("a201d6410062612fd54100"),
+			want: map[string]uint64{"/1": 22, "/a~1": 21},
+		},
+		{
+			name: "byte string map key",
+			data: hexDecode("a14101d64100"),
+			want: map[string]uint64{"/h'01'": 22},
+		},
+		{
+			name: "indefinite-length array and byte string",
+			data: hexDecode("d69f41005f4101ffff"),
+			want: map[string]uint64{"/0": 22, "/1": 22},
+		},
+		{
+			name: "other tags",
+			data: hexDecode("d5d8184100"),
+			want: map[string]uint64{"": 21},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ExpectedLaterEncodings(tc.data)
+			if err != nil {
+				t.Fatalf("ExpectedLaterEncodings(0x%x) returned an error %v", tc.data, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ExpectedLaterEncodings(0x%x) = %v, want %v", tc.data, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExpectedLaterEncodingsError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "empty data",
+			data:         []byte{},
+			wantErrorMsg: io.EOF.Error(),
+		},
+		{
+			name:         "truncated data",
+			data:         hexDecode("d68241"),
+			wantErrorMsg: io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			name:         "extraneous data",
+			data:         hexDecode("d6410000"),
+			wantErrorMsg: "cbor: 1 bytes of extraneous data starting at index 3",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ExpectedLaterEncodings(tc.data)
+			if err == nil {
+				t.Errorf("ExpectedLaterEncodings(0x%x) didn't return an error", tc.data)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("ExpectedLaterEncodings(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"reflect"
+)
+
+// Memory usage reported by MemoryUsage and TypeCacheStats is an estimate based on sizes
+// of Go values retained, plus a fixed overhead for each map and cache entry.  It doesn't
+// include memory shared with other values, such as reflect.Type.
+
+// mapEntryOverhead is the estimated number of bytes used by each map or cache entry in
+// addition to its key and value.
+const mapEntryOverhead = 48
+
+var (
+	encModeSize             = int(reflect.TypeOf(encMode{}).Size())
+	decModeSize             = int(reflect.TypeOf(decMode{}).Size())
+	tagItemSize             = int(reflect.TypeOf(tagItem{}).Size())
+	fieldSize               = int(reflect.TypeOf(field{}).Size())
+	encodingStructTypeSize  = int(reflect.TypeOf(encodingStructType{}).Size())
+	decodingStructTypeSize  = int(reflect.TypeOf(decodingStructType{}).Size())
+	intKeyFieldsSize        = int(reflect.TypeOf(intKeyFields{}).Size())
+	typeInfoSize            = int(reflect.TypeOf(typeInfo{}).Size())
+	encodeFuncsSize         = int(reflect.TypeOf(encodeFuncs{}).Size())
+	simpleValueRegistrySize = int(reflect.TypeOf(SimpleValueRegistry{}).Size())
+	pointerSize             = int(reflect.TypeOf(uintptr(0)).Size())
+	interfaceSize           = int(reflect.TypeOf([]interface{}(nil)).Elem().Size())
+	reflectValueSize        = int(reflect.TypeOf(reflect.Value{}).Size())
+)
+
+// MemoryUsage returns approximate number of bytes retained by em, including its tags
+// and copies of em created for nil containers, nested data items, and checking data
+// returned by Marshaler.
+func (em *encMode) MemoryUsage() int {
+	n := encModeSize + tagProviderMemoryUsage(em.tags)
+	// Copies share tags and other referenced values with em.
+	for _, nem := range em.nilContainerModes {
+		if nem != em {
+			n += encModeSize
+		}
+	}
+	if em.nested != nil {
+		n += encModeSize
+	}
+	if em.marshalerDecMode != nil {
+		n += decModeSize
+	}
+	if em.emptyPredicates != nil {
+		n += len(em.emptyPredicates.m) * (interfaceSize + pointerSize + mapEntryOverhead)
+	}
+	for _, analogs := range em.simpleValueAnalogs {
+		n += interfaceSize + mapEntryOverhead + len(analogs)*(interfaceSize+pointerSize)
+	}
+	return n
+}
+
+// MemoryUsage returns approximate number of bytes retained by dm, including its tags,
+// interface fallback types, stringer enums, and simple value registry.
+func (dm *decMode) MemoryUsage() int {
+	n := decModeSize + tagProviderMemoryUsage(dm.tags)
+	if dm.interfaceFallbackTypes != nil {
+		n += len(dm.interfaceFallbackTypes.m) * (2*interfaceSize + mapEntryOverhead)
+	}
+	if dm.stringerEnums != nil {
+		n += len(dm.stringerEnums.enums) * (interfaceSize + reflectValueSize + mapEntryOverhead)
+	}
+	for _, names := range dm.stringerEnumValues {
+		n += interfaceSize + mapEntryOverhead
+		for name := range names {
+			n += len(name)
+		}
+	}
+	if dm.simpleValues != defaultSimpleValues {
+		n += simpleValueRegistrySize
+		n += len(dm.simpleValues.analogs) * (1 + reflectValueSize + mapEntryOverhead)
+	}
+	return n
+}
+
+// tagProviderMemoryUsage returns approximate number of bytes retained by tags.
+// Shared tags (TagSet used by DecModeWithSharedTags and EncModeWithSharedTags) are
+// included because they are retained by the mode.
+func tagProviderMemoryUsage(tags tagProvider) int {
+	switch tags := tags.(type) {
+	case tagSet:
+		return tags.memoryUsage()
+	case *syncTagSet:
+		tags.RLock()
+		defer tags.RUnlock()
+		return tags.t.memoryUsage()
+	}
+	return 0
+}
+
+func (t tagSet) memoryUsage() int {
+	n := 0
+	for _, item := range t {
+		n += interfaceSize + pointerSize + mapEntryOverhead
+		n += tagItemSize + 8*len(item.num) + len(item.cborTagNum)
+	}
+	return n
+}
+
+func (flds fields) memoryUsage() int {
+	n := pointerSize * len(flds)
+	for _, f := range flds {
+		n += fieldSize + len(f.name) + len(f.cborName) + len(f.cborNameByteString) + pointerSize*len(f.idx)
+	}
+	return n
+}
+
+func (st *decodingStructType) memoryUsage() int {
+	n := decodingStructTypeSize + st.fields.memoryUsage()
+	for name := range st.fieldIndicesByName {
+		n += len(name) + 2*pointerSize + mapEntryOverhead
+	}
+	if st.unknownField != nil {
+		n += fields{st.unknownField}.memoryUsage()
+	}
+	if st.intKeyFields != nil {
+		n += intKeyFieldsSize + pointerSize*(len(st.intKeyFields.pos)+len(st.intKeyFields.neg))
+	}
+	return n
+}
+
+func (st *encodingStructType) memoryUsage() int {
+	n := encodingStructTypeSize + st.fields.memoryUsage()
+	n += pointerSize * (len(st.bytewiseFields) + len(st.lengthFirstFields) + len(st.omitEmptyFieldsIdx))
+	if st.unknownField != nil {
+		n += fields{st.unknownField}.memoryUsage()
+	}
+	return n
+}
+
+// typeCacheEntryMemoryUsage returns approximate number of bytes retained by value v
+// stored in a type cache.
+func typeCacheEntryMemoryUsage(v interface{}) int {
+	n := interfaceSize + mapEntryOverhead
+	switch v := v.(type) {
+	case *decodingStructType:
+		n += v.memoryUsage()
+	case *encodingStructType:
+		n += v.memoryUsage()
+	case *typeInfo:
+		n += typeInfoSize
+	case encodeFuncs:
+		n += encodeFuncsSize
+	}
+	return n
+}
@@ -0,0 +1,140 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModeMemoryUsage(t *testing.T) {
+	type myInt int
+
+	tags := NewTagSet()
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagRequired}, reflect.TypeOf(myInt(0)), 100); err != nil {
+		t.Fatal(err)
+	}
+
+	em, err := EncOptions{}.EncMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	emWithTags, err := EncOptions{}.EncModeWithTags(tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := em.(ExtendedEncMode).MemoryUsage(); n <= 0 {
+		t.Errorf("EncMode.MemoryUsage() = %d, want > 0", n)
+	}
+	if n, m := emWithTags.(ExtendedEncMode).MemoryUsage(), em.(ExtendedEncMode).MemoryUsage(); n <= m {
+		t.Errorf("EncMode.MemoryUsage() with tags = %d, want > %d", n, m)
+	}
+
+	// Copies of em are created for nil containers, nested data items, and checking
+	// data returned by Marshaler.
+	if n, m := em.(ExtendedEncMode).MemoryUsage(), int(maxNilContainersMode)*encModeSize; n < m {
+		t.Errorf("EncMode.MemoryUsage() = %d, want >= %d", n, m)
+	}
+	for _, tc := range []struct {
+		name string
+		opts EncOptions
+	}{
+		{name: "SelfDescribedCBOR", opts: EncOptions{SelfDescribedCBOR: SelfDescribedCBOREachItem}},
+		{name: "FloatForbidden", opts: EncOptions{Float: FloatForbidden}},
+	} {
+		emWithCopy, err := tc.opts.EncMode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, m := emWithCopy.(ExtendedEncMode).MemoryUsage(), em.(ExtendedEncMode).MemoryUsage(); n <= m {
+			t.Errorf("EncMode.MemoryUsage() with %s = %d, want > %d", tc.name, n, m)
+		}
+	}
+
+	dm, err := DecOptions{}.DecMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dmWithTags, err := DecOptions{}.DecModeWithSharedTags(tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := dm.(ExtendedDecMode).MemoryUsage(); n <= 0 {
+		t.Errorf("DecMode.MemoryUsage() = %d, want > 0", n)
+	}
+	if n, m := dmWithTags.(ExtendedDecMode).MemoryUsage(), dm.(ExtendedDecMode).MemoryUsage(); n <= m {
+		t.Errorf("DecMode.MemoryUsage() with shared tags = %d, want > %d", n, m)
+	}
+
+	// Shared tags added after creating DecMode are retained by DecMode.
+	before := dmWithTags.(ExtendedDecMode).MemoryUsage()
+	type myString string
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagRequired}, reflect.TypeOf(myString("")), 101); err != nil {
+		t.Fatal(err)
+	}
+	if n := dmWithTags.(ExtendedDecMode).MemoryUsage(); n <= before {
+		t.Errorf("DecMode.MemoryUsage() after adding shared tag = %d, want > %d", n, before)
+	}
+
+	dmWithFallback, err := DecOptions{
+		InterfaceFallbackTypes: NewInterfaceFallbackTypes(map[reflect.Type]reflect.Type{reflect.TypeOf((*error)(nil)).Elem(): reflect.TypeOf(UnknownFieldError{})}),
+	}.DecMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, m := dmWithFallback.(ExtendedDecMode).MemoryUsage(), dm.(ExtendedDecMode).MemoryUsage(); n <= m {
+		t.Errorf("DecMode.MemoryUsage() with interface fallback types = %d, want > %d", n, m)
+	}
+}
+
+func TestDecodingStructTypeMemoryUsageIntKeyFields(t *testing.T) {
+	type keyAsInt struct {
+		A int `cbor:"1,keyasint"`
+		B int `cbor:"-100,keyasint"`
+	}
+	st := getDecodingStructType(reflect.TypeOf(keyAsInt{}))
+	if st.intKeyFields == nil {
+		t.Fatal("decodingStructType.intKeyFields is nil")
+	}
+	withoutTable := *st
+	withoutTable.intKeyFields = nil
+	if n, m := st.memoryUsage(), withoutTable.memoryUsage(); n <= m {
+		t.Errorf("decodingStructType.memoryUsage() with intKeyFields = %d, want > %d", n, m)
+	}
+}
+
+func TestTypeCacheStatsBytes(t *testing.T) {
+	// Reset caches.
+	if err := SetTypeCacheLimit(0); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := TypeCacheStats(); stats.Bytes != 0 {
+		t.Errorf("TypeCacheStats().Bytes = %d, want 0 after SetTypeCacheLimit()", stats.Bytes)
+	}
+
+	type small struct {
+		A int
+	}
+	if _, err := Marshal(small{}); err != nil {
+		t.Fatal(err)
+	}
+	stats := TypeCacheStats()
+	if stats.Bytes <= 0 {
+		t.Errorf("TypeCacheStats().Bytes = %d, want > 0", stats.Bytes)
+	}
+
+	type large struct {
+		LongFieldName1 int
+		LongFieldName2 int
+		LongFieldName3 int
+		LongFieldName4 int
+	}
+	if _, err := Marshal(large{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := TypeCacheStats().Bytes; n <= stats.Bytes {
+		t.Errorf("TypeCacheStats().Bytes = %d, want > %d", n, stats.Bytes)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+)
+
+// MultimapEntry is a key-value pair in Multimap.  Key and Value are raw encoded
+// CBOR data items, so they are preserved exactly as received.
+type MultimapEntry struct {
+	Key   RawMessage
+	Value RawMessage
+}
+
+// Multimap is a CBOR map represented as an ordered list of key-value pairs.
+//
+// Unlike Go maps, Multimap accepts and preserves duplicate map keys and the order
+// of map entries on decode, and re-encodes them verbatim.  This is useful for tools
+// that must inspect data from non-conforming producers without losing or rejecting
+// any of it.  Decoding options such as DupMapKey don't apply to Multimap.
+//
+// Since keys are kept as encoded CBOR data, Multimap can also hold map keys that
+// can't be Go map keys, such as CBOR arrays, maps, and tags enclosing them.  Get,
+// Set, and Delete find entries by comparing encoded keys byte by byte, so keys
+// should be encoded consistently (e.g. with the same EncMode) by the caller.
+type Multimap []MultimapEntry
+
+var typeMultimap = reflect.TypeOf(Multimap(nil))
+
+// Values returns values of all entries in m with encoded key equal to key, in order.
+func (m Multimap) Values(key RawMessage) []RawMessage {
+	var values []RawMessage
+	for _, entry := range m {
+		if bytes.Equal(entry.Key, key) {
+			values = append(values, entry.Value)
+		}
+	}
+	return values
+}
+
+// Get returns value of the first entry in m with encoded key equal to key.
+// It returns false if no such entry exists.
+func (m Multimap) Get(key RawMessage) (RawMessage, bool) {
+	for _, entry := range m {
+		if bytes.Equal(entry.Key, key) {
+			return entry.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set sets value of the first entry in m with encoded key equal to key, and
+// removes other entries with the same key.  If no such entry exists, a new
+// entry is appended to m.
+func (m *Multimap) Set(key, value RawMessage) {
+	found := false
+	entries := (*m)[:0]
+	for _, entry := range *m {
+		if bytes.Equal(entry.Key, key) {
+			if found {
+				continue
+			}
+			found = true
+			entry.Value = value
+		}
+		entries = append(entries, entry)
+	}
+	if !found {
+		entries = append(entries, MultimapEntry{Key: key, Value: value})
+	}
+	*m = entries
+}
+
+// Delete removes all entries in m with encoded key equal to key.
+func (m *Multimap) Delete(key RawMessage) {
+	entries := (*m)[:0]
+	for _, entry := range *m {
+		if !bytes.Equal(entry.Key, key) {
+			entries = append(entries, entry)
+		}
+	}
+	*m = entries
+}
+
+// MarshalCBOR encodes m as CBOR map with definite length, with entries in the
+// same order as m.  Nil Multimap is encoded as CBOR null.  Empty Key or Value is
+// encoded as CBOR null.
+func (m Multimap) MarshalCBOR() ([]byte, error) {
+	if m == nil {
+		return cborNil, nil
+	}
+
+	e := getEncodeBuffer()
+	defer putEncodeBuffer(e)
+
+	encodeHead(e, byte(cborTypeMap), uint64(len(m)))
+	for _, entry := range m {
+		for _, item := range [2]RawMessage{entry.Key, entry.Value} {
+			if len(item) == 0 {
+				e.Write(cborNil)
+			} else {
+				e.Write(item)
+			}
+		}
+	}
+
+	buf := make([]byte, e.Len())
+	copy(buf, e.Bytes())
+	return buf, nil
+}
+
+// UnmarshalCBOR decodes CBOR map (definite or indefinite length) to Multimap,
+// preserving all entries including duplicate keys.  Decoding CBOR null or
+// undefined sets m to nil.
+func (m *Multimap) UnmarshalCBOR(data []byte) error {
+	if m == nil {
+		return errors.New("cbor.Multimap: UnmarshalCBOR on nil pointer")
+	}
+
+	d := decoder{data: data, dm: defaultDecMode}
+	count, isNil, err := d.parseMapHead(typeMultimap)
+	if err != nil {
+		return err
+	}
+	if isNil {
+		*m = nil
+		return nil
+	}
+
+	entries := make(Multimap, count)
+	for i := 0; i < count; i++ {
+		entries[i].Key = d.nextRawMessage()
+		entries[i].Value = d.nextRawMessage()
+	}
+	*m = entries
+	return nil
+}
+
+// parseMapHead checks that d.data is a single well-formed CBOR data item, and parses
+// head of CBOR map (definite or indefinite length) decoded to a Go value of goType,
+// such as Multimap and OrderedMap.  It returns number of map entries, or true if the
+// data item is CBOR null or undefined.
+func (d *decoder) parseMapHead(goType reflect.Type) (count int, isNil bool, err error) {
+	if err := d.wellformed(false, false); err != nil {
+		return 0, false, err
+	}
+	d.reset(d.data)
+
+	if d.nextCBORNil() {
+		return 0, true, nil
+	}
+
+	t := d.nextCBORType()
+	if t != cborTypeMap {
+		return 0, false, &UnmarshalTypeError{CBORType: t.String(), GoType: goType.String()}
+	}
+
+	_, _, val, indefiniteLength := d.getHeadWithIndefiniteLengthFlag()
+	count = int(val)
+	if indefiniteLength {
+		count = d.numOfItemsUntilBreak() / 2
+	}
+	return count, false, nil
+}
+
+// nextRawMessage returns a copy of the next CBOR data item and moves cursor past it.
+func (d *decoder) nextRawMessage() RawMessage {
+	start := d.off
+	d.skip()
+	b := make(RawMessage, d.off-start)
+	copy(b, d.data[start:d.off])
+	return b
+}
@@ -0,0 +1,178 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMultimap(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     []byte
+		want     Multimap
+		wantData []byte
+	}{
+		{
+			name:     "CBOR null",
+			data:     hexDecode("f6"),
+			want:     nil,
+			wantData: hexDecode("f6"),
+		},
+		{
+			name:     "empty map",
+			data:     hexDecode("a0"),
+			want:     Multimap{},
+			wantData: hexDecode("a0"),
+		},
+		{
+			name: "duplicate and unsorted keys",
+			// {2: "a", 1: "b", 2: "c"}
+			data: hexDecode("a3026161016162026163"),
+			want: Multimap{
+				{Key: hexDecode("02"), Value: hexDecode("6161")},
+				{Key: hexDecode("01"), Value: hexDecode("6162")},
+				{Key: hexDecode("02"), Value: hexDecode("6163")},
+			},
+			wantData: hexDecode("a3026161016162026163"),
+		},
+		{
+			name: "duplicate keys in non-shortest form",
+			// {1: 1.5, 1: [1]}
+			data: hexDecode("a21a00000001fb3ff800000000000018018101"),
+			want: Multimap{
+				{Key: hexDecode("1a00000001"), Value: hexDecode("fb3ff8000000000000")},
+				{Key: hexDecode("1801"), Value: hexDecode("8101")},
+			},
+			wantData: hexDecode("a21a00000001fb3ff800000000000018018101"),
+		},
+		{
+			name: "indefinite-length map",
+			// {_ 1: 2, 3: {_ 4: 5}}
+			data: hexDecode("bf010203bf0405ffff"),
+			want: Multimap{
+				{Key: hexDecode("01"), Value: hexDecode("02")},
+				{Key: hexDecode("03"), Value: hexDecode("bf0405ff")},
+			},
+			wantData: hexDecode("a2010203bf0405ff"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var m Multimap
+			if err := Unmarshal(tc.data, &m); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if !reflect.DeepEqual(m, tc.want) {
+				t.Errorf("Unmarshal(0x%x) = %v, want %v", tc.data, m, tc.want)
+			}
+
+			data, err := Marshal(m)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned error %v", m, err)
+			}
+			if !bytes.Equal(data, tc.wantData) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", m, data, tc.wantData)
+			}
+		})
+	}
+}
+
+func TestMultimapWithDupMapKeyEnforced(t *testing.T) {
+	type s struct {
+		M Multimap `cbor:"m"`
+	}
+
+	dm, err := DecOptions{DupMapKey: DupMapKeyEnforcedAPF}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	// {"m": {1: 2, 1: 3}}
+	data := hexDecode("a1616da201020103")
+	var v s
+	if err := dm.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	if values := v.M.Values(hexDecode("01")); !reflect.DeepEqual(values, []RawMessage{hexDecode("02"), hexDecode("03")}) {
+		t.Errorf("Values(0x01) = %v, want [0x02 0x03]", values)
+	}
+	if values := v.M.Values(hexDecode("02")); values != nil {
+		t.Errorf("Values(0x02) = %v, want nil", values)
+	}
+}
+
+func TestMultimapUnmarshalError(t *testing.T) {
+	data := hexDecode("8101")
+	wantErrorMsg := "cbor: cannot unmarshal array into Go value of type cbor.Multimap"
+
+	var m Multimap
+	if err := Unmarshal(data, &m); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
+	}
+
+	data = hexDecode("a201")
+	if err := m.UnmarshalCBOR(data); err == nil {
+		t.Errorf("UnmarshalCBOR(0x%x) didn't return an error", data)
+	}
+
+	var pm *Multimap
+	if err := pm.UnmarshalCBOR(hexDecode("a0")); err == nil {
+		t.Errorf("UnmarshalCBOR() on nil pointer didn't return an error")
+	}
+}
+
+func TestMultimapGetSetDelete(t *testing.T) {
+	// {[1, 2]: 1, {1: 2}: 2, [1, 2]: 3}
+	data := hexDecode("a382010201a101020282010203")
+	arrayKey := hexDecode("820102")
+	mapKey := hexDecode("a10102")
+	tagKey := hexDecode("d864820102")
+
+	var m Multimap
+	if err := Unmarshal(data, &m); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+
+	if v, ok := m.Get(arrayKey); !ok || !bytes.Equal(v, hexDecode("01")) {
+		t.Errorf("Get(0x%x) = 0x%x, %t, want 0x01, true", arrayKey, v, ok)
+	}
+	if v, ok := m.Get(tagKey); ok || v != nil {
+		t.Errorf("Get(0x%x) = 0x%x, %t, want nil, false", tagKey, v, ok)
+	}
+
+	m.Set(arrayKey, hexDecode("04"))
+	m.Set(tagKey, hexDecode("05"))
+	want := Multimap{
+		{Key: arrayKey, Value: hexDecode("04")},
+		{Key: mapKey, Value: hexDecode("02")},
+		{Key: tagKey, Value: hexDecode("05")},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("Set() = %v, want %v", m, want)
+	}
+
+	m.Delete(mapKey)
+	want = Multimap{
+		{Key: arrayKey, Value: hexDecode("04")},
+		{Key: tagKey, Value: hexDecode("05")},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("Delete() = %v, want %v", m, want)
+	}
+
+	// {[1, 2]: 4, 100([1, 2]): 5}
+	wantData := hexDecode("a282010204d86482010205")
+	b, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal(%v) returned error %v", m, err)
+	}
+	if !bytes.Equal(b, wantData) {
+		t.Errorf("Marshal(%v) = 0x%x, want 0x%x", m, b, wantData)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+//go:build go1.18
+
+package cbor
+
+import "errors"
+
+// Optional represents a value of type T with presence tracking, so callers can tell
+// whether a CBOR map key was absent, present with null (or undefined), or present with
+// a value (including the zero value of T) after Unmarshal.  This is useful for
+// patch-style APIs where absent fields are left unchanged and null fields are cleared.
+//
+// Unmarshal leaves Optional struct fields unchanged if their map keys are absent, so
+// Optional fields should be zero before decoding.  When present, Optional is decoded
+// with the caller's decoding options.
+//
+// Marshal encodes Optional as CBOR null if it isn't present or is null, and as Value
+// otherwise, using the caller's encoding options.  Use "omitzero" struct field option
+// to omit Optional fields that aren't present.
+type Optional[T any] struct {
+	// Value is the decoded value.  It is the zero value of T if Present is false
+	// or Null is true.
+	Value T
+
+	// Present is true if the value is present in CBOR data, including null.
+	Present bool
+
+	// Null is true if the value is present as CBOR null or undefined.
+	Null bool
+}
+
+// OptionalOf returns present Optional with value v.
+func OptionalOf[T any](v T) Optional[T] {
+	return Optional[T]{Value: v, Present: true}
+}
+
+// OptionalNull returns Optional present as null.
+func OptionalNull[T any]() Optional[T] {
+	return Optional[T]{Present: true, Null: true}
+}
+
+// IsZero returns true if o isn't present.  It is used by "omitzero" struct field option.
+func (o Optional[T]) IsZero() bool {
+	return !o.Present
+}
+
+// MarshalCBORWithMode returns CBOR encoding of o.Value using em, or CBOR null if o
+// isn't present or is null.
+func (o Optional[T]) MarshalCBORWithMode(em EncMode) ([]byte, error) {
+	if !o.Present || o.Null {
+		b := make([]byte, len(cborNil))
+		copy(b, cborNil)
+		return b, nil
+	}
+	return marshalNested(em, o.Value)
+}
+
+// UnmarshalCBORWithMode marks o as present and decodes data to o.Value using dm.
+// CBOR null and undefined mark o as null and set o.Value to the zero value of T.
+func (o *Optional[T]) UnmarshalCBORWithMode(data []byte, dm DecMode) error {
+	if o == nil {
+		return errors.New("cbor.Optional: UnmarshalCBORWithMode on nil pointer")
+	}
+	if len(data) == 1 && (data[0] == 0xf6 || data[0] == 0xf7) {
+		*o = Optional[T]{Present: true, Null: true}
+		return nil
+	}
+	if err := dm.Unmarshal(data, &o.Value); err != nil {
+		return err
+	}
+	o.Present = true
+	o.Null = false
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+//go:build go1.18
+
+package cbor
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type optionalPatch struct {
+	Name  Optional[string]         `cbor:"name,omitzero"`
+	Age   Optional[int]            `cbor:"age,omitzero"`
+	Tags  Optional[[]string]       `cbor:"tags,omitzero"`
+	Attrs Optional[map[string]int] `cbor:"attrs,omitzero"`
+}
+
+func TestUnmarshalOptional(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+		want optionalPatch
+	}{
+		{
+			name: "absent",
+			data: hexDecode("a0"), // {}
+			want: optionalPatch{},
+		},
+		{
+			name: "null",
+			data: hexDecode("a2646e616d65f663616765f7"), // {"name": null, "age": undefined}
+			want: optionalPatch{Name: OptionalNull[string](), Age: OptionalNull[int]()},
+		},
+		{
+			name: "zero values",
+			data: hexDecode("a3646e616d65606361676500647461677380"), // {"name": "", "age": 0, "tags": []}
+			want: optionalPatch{Name: OptionalOf(""), Age: OptionalOf(0), Tags: OptionalOf([]string{})},
+		},
+		{
+			name: "values",
+			data: hexDecode("a2646e616d6563426f62656174747273a1616101"), // {"name": "Bob", "attrs": {"a": 1}}
+			want: optionalPatch{Name: OptionalOf("Bob"), Attrs: OptionalOf(map[string]int{"a": 1})},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var v optionalPatch
+			if err := Unmarshal(tc.data, &v); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if !reflect.DeepEqual(v, tc.want) {
+				t.Errorf("Unmarshal(0x%x) = %+v, want %+v", tc.data, v, tc.want)
+			}
+
+			// Encoding omits absent values and roundtrips present values.
+			b, err := Marshal(v)
+			if err != nil {
+				t.Fatalf("Marshal(%+v) returned error %v", v, err)
+			}
+			var v2 optionalPatch
+			if err := Unmarshal(b, &v2); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", b, err)
+			}
+			if !reflect.DeepEqual(v2, tc.want) {
+				t.Errorf("Unmarshal(Marshal(%+v)) = %+v", v, v2)
+			}
+		})
+	}
+}
+
+func TestUnmarshalOptionalWithDecMode(t *testing.T) {
+	dm, err := DecOptions{IntDec: IntDecConvertSigned}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	data := hexDecode("a16161a1616201") // {"a": {"b": 1}}
+	var v struct {
+		A Optional[map[string]interface{}] `cbor:"a"`
+	}
+	if err := dm.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	if want := OptionalOf(map[string]interface{}{"b": int64(1)}); !reflect.DeepEqual(v.A, want) {
+		t.Errorf("Unmarshal(0x%x) = %+v, want %+v", data, v.A, want)
+	}
+
+	data = hexDecode("a16161a1616263") // {"a": {"b": "c"}} can't be decoded into map[string]int
+	var v2 struct {
+		A Optional[map[string]int] `cbor:"a"`
+	}
+	if err := dm.Unmarshal(data, &v2); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+	}
+}
+
+func TestMarshalOptional(t *testing.T) {
+	em, err := EncOptions{Sort: SortCoreDeterministic}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	type s struct {
+		A Optional[int] `cbor:"a"`
+		B Optional[int] `cbor:"b,omitzero"`
+	}
+
+	testCases := []struct {
+		name         string
+		v            interface{}
+		wantCborData []byte
+	}{
+		{"absent", s{}, hexDecode("a16161f6")},                                                   // {"a": null}
+		{"null", s{A: OptionalNull[int](), B: OptionalNull[int]()}, hexDecode("a26161f66162f6")}, // {"a": null, "b": null}
+		{"zero", s{A: OptionalOf(0), B: OptionalOf(0)}, hexDecode("a2616100616200")},             // {"a": 0, "b": 0}
+		{"map value", OptionalOf(map[string]int{"b": 2, "a": 1}), hexDecode("a2616101616202")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := em.Marshal(tc.v)
+			if err != nil {
+				t.Fatalf("Marshal(%+v) returned error %v", tc.v, err)
+			}
+			if !bytes.Equal(b, tc.wantCborData) {
+				t.Errorf("Marshal(%+v) = 0x%x, want 0x%x", tc.v, b, tc.wantCborData)
+			}
+		})
+	}
+}
+
+func TestMarshalOptionalSelfDescribedCBOR(t *testing.T) {
+	em, err := EncOptions{SelfDescribedCBOR: SelfDescribedCBOREachItem}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	type s struct {
+		A Optional[int] `cbor:"a"`
+	}
+
+	// Optional values nested in s aren't prefixed with tag number 55799.
+	v := s{A: OptionalOf(1)}
+	want := hexDecode("d9d9f7a1616101") // 55799({"a": 1})
+	b, err := em.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(%+v) returned error %v", v, err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("Marshal(%+v) = 0x%x, want 0x%x", v, b, want)
+	}
+}
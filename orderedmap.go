@@ -0,0 +1,165 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"errors"
+	"reflect"
+)
+
+// KeyValuePair is a key-value pair in OrderedMap.
+type KeyValuePair struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// OrderedMap is a CBOR map represented as a list of key-value pairs in the order of
+// map entries in CBOR data.
+//
+// Unlike Go maps, OrderedMap preserves the order of map entries on decode, and encodes
+// map entries in the same order regardless of EncOptions.Sort.  This is useful for
+// protocols where the order of map entries is meaningful, even though CBOR doesn't
+// define an order for map entries.
+//
+// Keys and values are decoded as if decoded to interface{} using the caller's decoding
+// options, so keys that can't be Go map keys (e.g. CBOR arrays) are also accepted.
+// Duplicate map keys are detected as specified by DecOptions.DupMapKey, and OrderedMap
+// isn't modified if decoding fails.  Use Multimap to keep encoded keys and values
+// verbatim, including duplicate map keys.  OrderedMap and Multimap share parsing of
+// CBOR map head, but OrderedMap decodes keys and values to Go values with the caller's
+// DecMode, which Multimap doesn't do since it has no DecMode.
+type OrderedMap []KeyValuePair
+
+var typeOrderedMap = reflect.TypeOf(OrderedMap(nil))
+
+// Get returns value of the first entry in m with key equal to key.  Keys are compared
+// with reflect.DeepEqual, so key must have the same Go type as decoded keys (e.g. uint64
+// for CBOR unsigned integers with default decoding options).  It returns false if no
+// such entry exists.
+func (m OrderedMap) Get(key interface{}) (interface{}, bool) {
+	for _, kv := range m {
+		if reflect.DeepEqual(kv.Key, key) {
+			return kv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set sets value of the first entry in m with key equal to key, keeping its position.
+// If no such entry exists, a new entry is appended to m.  Keys are compared as in Get.
+func (m *OrderedMap) Set(key, value interface{}) {
+	for i := range *m {
+		if reflect.DeepEqual((*m)[i].Key, key) {
+			(*m)[i].Value = value
+			return
+		}
+	}
+	*m = append(*m, KeyValuePair{Key: key, Value: value})
+}
+
+// Delete removes all entries in m with key equal to key.  Keys are compared as in Get.
+func (m *OrderedMap) Delete(key interface{}) {
+	entries := (*m)[:0]
+	for _, kv := range *m {
+		if !reflect.DeepEqual(kv.Key, key) {
+			entries = append(entries, kv)
+		}
+	}
+	*m = entries
+}
+
+// MarshalCBORWithMode encodes m as CBOR map with definite length using em, with entries
+// in the same order as m.  Nil OrderedMap is encoded as CBOR null.
+func (m OrderedMap) MarshalCBORWithMode(em EncMode) ([]byte, error) {
+	if m == nil {
+		b := make([]byte, len(cborNil))
+		copy(b, cborNil)
+		return b, nil
+	}
+
+	if em == nil {
+		em = defaultEncMode
+	}
+	iem, ok := em.(*encMode)
+	if !ok {
+		return nil, errors.New("cbor: unsupported EncMode implementation " + reflect.TypeOf(em).String())
+	}
+
+	e := getEncodeBuffer()
+	defer putEncodeBuffer(e)
+
+	encodeHead(e, byte(cborTypeMap), uint64(len(m)))
+	for _, kv := range m {
+		if err := encode(e, iem, reflect.ValueOf(kv.Key)); err != nil {
+			return nil, err
+		}
+		if err := encode(e, iem, reflect.ValueOf(kv.Value)); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := make([]byte, e.Len())
+	copy(buf, e.Bytes())
+	return buf, nil
+}
+
+// UnmarshalCBORWithMode decodes CBOR map (definite or indefinite length) to OrderedMap
+// using dm, preserving the order of map entries.  Decoding CBOR null or undefined sets
+// m to nil.
+func (m *OrderedMap) UnmarshalCBORWithMode(data []byte, dm DecMode) error {
+	if m == nil {
+		return errors.New("cbor.OrderedMap: UnmarshalCBORWithMode on nil pointer")
+	}
+
+	if dm == nil {
+		dm = defaultDecMode
+	}
+	idm, ok := dm.(*decMode)
+	if !ok {
+		return errors.New("cbor: unsupported DecMode implementation " + reflect.TypeOf(dm).String())
+	}
+
+	d := decoder{data: data, dm: idm}
+	count, isNil, err := d.parseMapHead(typeOrderedMap)
+	if err != nil {
+		return err
+	}
+	if isNil {
+		*m = nil
+		return nil
+	}
+
+	var keys map[interface{}]struct{}
+	if idm.dupMapKey != DupMapKeyQuiet {
+		keys = make(map[interface{}]struct{}, count)
+	}
+
+	tInfo := getTypeInfo(typeIntf)
+	entries := make(OrderedMap, count)
+	for i := range entries {
+		keyOff := d.off
+		parsingMapKey := d.parsingMapKey
+		d.parsingMapKey = true
+		err = d.parseToValue(reflect.ValueOf(&entries[i].Key).Elem(), tInfo)
+		d.parsingMapKey = parsingMapKey
+		if err != nil {
+			return err
+		}
+
+		// Detect duplicate map key.
+		if keys != nil {
+			k := comparableMapKey(entries[i].Key, d.data[keyOff:d.off])
+			if _, ok := keys[k]; ok {
+				return &DupMapKeyError{entries[i].Key, i}
+			}
+			keys[k] = struct{}{}
+		}
+
+		if err := d.parseToValue(reflect.ValueOf(&entries[i].Value).Elem(), tInfo); err != nil {
+			return err
+		}
+	}
+	*m = entries
+	return nil
+}
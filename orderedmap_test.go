@@ -0,0 +1,213 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestOrderedMap(t *testing.T) {
+	em, err := EncOptions{Sort: SortCanonical}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		data     []byte
+		want     OrderedMap
+		wantData []byte
+	}{
+		{
+			name:     "CBOR null",
+			data:     hexDecode("f6"),
+			want:     nil,
+			wantData: hexDecode("f6"),
+		},
+		{
+			name:     "empty map",
+			data:     hexDecode("a0"),
+			want:     OrderedMap{},
+			wantData: hexDecode("a0"),
+		},
+		{
+			name: "unsorted keys",
+			// {"b": 1, "a": -1, 10: "c", [0]: h'01'}
+			data: hexDecode("a46162016161200a616381004101"),
+			want: OrderedMap{
+				{Key: "b", Value: uint64(1)},
+				{Key: "a", Value: int64(-1)},
+				{Key: uint64(10), Value: "c"},
+				{Key: []interface{}{uint64(0)}, Value: []byte{1}},
+			},
+			wantData: hexDecode("a46162016161200a616381004101"),
+		},
+		{
+			name: "indefinite-length map",
+			// {_ "b": 1, "a": {_ "d": 2, "c": 3}}
+			data: hexDecode("bf6162016161bf616402616303ffff"),
+			want: OrderedMap{
+				{Key: "b", Value: uint64(1)},
+				{Key: "a", Value: map[interface{}]interface{}{"d": uint64(2), "c": uint64(3)}},
+			},
+			// Nested Go map is sorted by EncMode.
+			wantData: hexDecode("a26162016161a2616303616402"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var m OrderedMap
+			if err := Unmarshal(tc.data, &m); err != nil {
+				t.Fatalf("Unmarshal(0x%x) returned error %v", tc.data, err)
+			}
+			if !reflect.DeepEqual(m, tc.want) {
+				t.Errorf("Unmarshal(0x%x) = %v, want %v", tc.data, m, tc.want)
+			}
+
+			data, err := em.Marshal(m)
+			if err != nil {
+				t.Fatalf("Marshal(%v) returned error %v", m, err)
+			}
+			if !bytes.Equal(data, tc.wantData) {
+				t.Errorf("Marshal(%v) = 0x%x, want 0x%x", m, data, tc.wantData)
+			}
+		})
+	}
+}
+
+func TestOrderedMapWithDecMode(t *testing.T) {
+	type T struct {
+		M OrderedMap `cbor:"m"`
+	}
+
+	dm, err := DecOptions{IntDec: IntDecConvertSigned}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	data := hexDecode("a1616da2020161610a") // {"m": {2: 1, "a": 10}}
+	var v T
+	if err := dm.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	want := OrderedMap{
+		{Key: int64(2), Value: int64(1)},
+		{Key: "a", Value: int64(10)},
+	}
+	if !reflect.DeepEqual(v.M, want) {
+		t.Errorf("Unmarshal(0x%x) = %v, want %v", data, v.M, want)
+	}
+}
+
+func TestOrderedMapDupMapKey(t *testing.T) {
+	data := hexDecode("a3616101616202616103") // {"a": 1, "b": 2, "a": 3}
+
+	// Duplicate map keys are kept by default.
+	var m OrderedMap
+	if err := Unmarshal(data, &m); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	want := OrderedMap{
+		{Key: "a", Value: uint64(1)},
+		{Key: "b", Value: uint64(2)},
+		{Key: "a", Value: uint64(3)},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("Unmarshal(0x%x) = %v, want %v", data, m, want)
+	}
+
+	for _, dupMapKey := range []DupMapKeyMode{DupMapKeyEnforcedAPF, DupMapKeyReject} {
+		dm, err := DecOptions{DupMapKey: dupMapKey}.DecMode()
+		if err != nil {
+			t.Fatalf("DecMode() returned error %v", err)
+		}
+
+		wantErrorMsg := "cbor: found duplicate map key \"a\" at map element index 2"
+		m := OrderedMap{{Key: "c", Value: uint64(4)}}
+		if err := dm.Unmarshal(data, &m); err == nil {
+			t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+		} else if _, ok := err.(*DupMapKeyError); !ok {
+			t.Errorf("Unmarshal(0x%x) returned wrong error type %T, want (*DupMapKeyError)", data, err)
+		} else if err.Error() != wantErrorMsg {
+			t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
+		}
+		if want := (OrderedMap{{Key: "c", Value: uint64(4)}}); !reflect.DeepEqual(m, want) {
+			t.Errorf("Unmarshal(0x%x) modified OrderedMap to %v, want %v", data, m, want)
+		}
+	}
+
+	// Keys that can't be Go map keys are compared by CBOR encoding.
+	dm, err := DecOptions{DupMapKey: DupMapKeyEnforcedAPF}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+	data = hexDecode("a3810001810102810003") // {[0]: 1, [1]: 2, [0]: 3}
+	wantErrorMsg := "cbor: found duplicate map key \"[0]\" at map element index 2"
+	if err := dm.Unmarshal(data, &m); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
+	}
+}
+
+func TestOrderedMapUnmarshalError(t *testing.T) {
+	data := hexDecode("8101") // [1]
+	wantErrorMsg := "cbor: cannot unmarshal array into Go value of type cbor.OrderedMap"
+	var m OrderedMap
+	if err := Unmarshal(data, &m); err == nil {
+		t.Errorf("Unmarshal(0x%x) didn't return an error", data)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
+	}
+
+	wantErrorMsg = "cbor.OrderedMap: UnmarshalCBORWithMode on nil pointer"
+	if err := (*OrderedMap)(nil).UnmarshalCBORWithMode(hexDecode("a0"), nil); err == nil {
+		t.Errorf("UnmarshalCBORWithMode() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("UnmarshalCBORWithMode() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+}
+
+func TestOrderedMapGetSetDelete(t *testing.T) {
+	var m OrderedMap
+	m.Set("b", 1)
+	m.Set("a", 2)
+	m.Set("b", 3)
+	m.Set(uint64(1), []int{4})
+
+	want := OrderedMap{
+		{Key: "b", Value: 3},
+		{Key: "a", Value: 2},
+		{Key: uint64(1), Value: []int{4}},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("Set() = %v, want %v", m, want)
+	}
+
+	if v, ok := m.Get("a"); !ok || v != 2 {
+		t.Errorf("Get(\"a\") = %v, %t, want 2, true", v, ok)
+	}
+	if v, ok := m.Get(1); ok {
+		t.Errorf("Get(1) = %v, %t, want nil, false", v, ok)
+	}
+
+	m.Delete("b")
+	want = OrderedMap{
+		{Key: "a", Value: 2},
+		{Key: uint64(1), Value: []int{4}},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("Delete() = %v, want %v", m, want)
+	}
+
+	data, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal(%v) returned error %v", m, err)
+	}
+	if wantData := hexDecode("a2616102018104"); !bytes.Equal(data, wantData) {
+		t.Errorf("Marshal(%v) = 0x%x, want 0x%x", m, data, wantData)
+	}
+}
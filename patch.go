@@ -0,0 +1,447 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+// Patch operations.  See Diff for patch format.
+const (
+	patchOpSet    = 0
+	patchOpRemove = 1
+	patchOpAppend = 2
+)
+
+// patchNestedLevels is the number of nested levels a patch adds to data items of
+// a document (patch, operation, and path arrays).
+const patchNestedLevels = 3
+
+// patchDecMode checks patches created by Diff from documents within default
+// decoding limits.
+var patchDecMode, _ = DecOptions{MaxNestedLevels: defaultMaxNestedLevels + patchNestedLevels}.decMode()
+
+// Diff returns a patch that transforms CBOR data item oldDoc to newDoc when applied by
+// Apply.  It returns an error if oldDoc or newDoc isn't a single well-formed CBOR data item,
+// or if a map contains duplicate keys after canonicalization.
+//
+// Patch is a CBOR array of operations, which are applied in order:
+//
+//	patch = [* operation]
+//	operation = [0, path, value]      ; set
+//	          / [1, path]             ; remove
+//	          / [2, path, [* value]]  ; append
+//	path = [* (key / index)]
+//
+// Path is a CBOR array of map keys and array indexes (unsigned integers) identifying a
+// data item from the root of the document.  Empty path identifies the document itself.
+// Only untagged arrays and maps can be traversed.  Map keys in path are matched against
+// map keys in the document after both are canonicalized (see Canonicalize).
+//
+// Set operation replaces the data item identified by path with value, or adds a map
+// entry if path identifies a missing key of an existing map.  Remove operation removes
+// the map entry identified by path.  Append operation appends elements of an array to
+// the array identified by path.
+//
+// Diff canonicalizes oldDoc and newDoc before comparing them, so differences in encoding
+// (e.g. map order or integer encoding size) aren't included in the patch, and map keys and
+// values in the patch are encoded as in canonicalized newDoc.  Maps and arrays (of the same
+// type and without tags) are compared recursively, and arrays are compared by index.  Other
+// changed data items, including arrays that become shorter, are replaced.
+//
+// Patch nests data items of newDoc up to 3 levels deeper than newDoc (patch, operation,
+// and path arrays), which Apply allows for.
+func Diff(oldDoc, newDoc RawMessage) (RawMessage, error) {
+	ca, err := Canonicalize(oldDoc, nil)
+	if err != nil {
+		return nil, err
+	}
+	cb, err := Canonicalize(newDoc, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var pd patchDiffer
+	pd.diff(ca, cb, nil)
+
+	var e bytes.Buffer
+	encodeHead(&e, byte(cborTypeArray), uint64(pd.count))
+	e.Write(pd.ops.Bytes())
+	return RawMessage(e.Bytes()), nil
+}
+
+type patchDiffer struct {
+	ops   bytes.Buffer
+	count int
+}
+
+// diff adds operations to transform canonicalized data item a to canonicalized data
+// item b at path.
+func (pd *patchDiffer) diff(a, b []byte, path [][]byte) {
+	if bytes.Equal(a, b) {
+		return
+	}
+
+	at, aItems, aok := patchContainer(a)
+	bt, bItems, bok := patchContainer(b)
+	if !aok || !bok || at != bt {
+		pd.addOp(patchOpSet, path, b)
+		return
+	}
+
+	if at == cborTypeMap {
+		pd.diffMaps(aItems, bItems, path)
+		return
+	}
+
+	if len(bItems) < len(aItems) {
+		pd.addOp(patchOpSet, path, b)
+		return
+	}
+	for i := range aItems {
+		pd.diff(aItems[i], bItems[i], appendPath(path, encodePatchIndex(i)))
+	}
+	if len(bItems) > len(aItems) {
+		var elems bytes.Buffer
+		encodeHead(&elems, byte(cborTypeArray), uint64(len(bItems)-len(aItems)))
+		for _, item := range bItems[len(aItems):] {
+			elems.Write(item)
+		}
+		pd.addOp(patchOpAppend, path, elems.Bytes())
+	}
+}
+
+// diffMaps adds operations to transform canonicalized map entries a to b at path.
+// Map entries are key and value pairs.
+func (pd *patchDiffer) diffMaps(a, b [][]byte, path [][]byte) {
+	aIndex := mapEntryIndex(a)
+	bIndex := mapEntryIndex(b)
+
+	for i := 0; i < len(a); i += 2 {
+		if _, ok := bIndex[string(a[i])]; !ok {
+			pd.addOp(patchOpRemove, appendPath(path, a[i]), nil)
+		}
+	}
+	for i := 0; i < len(b); i += 2 {
+		j, ok := aIndex[string(b[i])]
+		if !ok {
+			pd.addOp(patchOpSet, appendPath(path, b[i]), b[i+1])
+			continue
+		}
+		pd.diff(a[j+1], b[i+1], appendPath(path, b[i]))
+	}
+}
+
+// addOp encodes operation op with path and value (nil for remove operation).
+func (pd *patchDiffer) addOp(op int, path [][]byte, value []byte) {
+	if value == nil {
+		encodeHead(&pd.ops, byte(cborTypeArray), 2)
+	} else {
+		encodeHead(&pd.ops, byte(cborTypeArray), 3)
+	}
+	encodeHead(&pd.ops, byte(cborTypePositiveInt), uint64(op))
+	encodeHead(&pd.ops, byte(cborTypeArray), uint64(len(path)))
+	for _, p := range path {
+		pd.ops.Write(p)
+	}
+	pd.ops.Write(value)
+	pd.count++
+}
+
+// Apply returns a copy of CBOR data item doc transformed by patch created by Diff.
+// It returns an error if doc or patch isn't a single well-formed CBOR data item, if
+// patch is invalid, or if an operation can't be applied to doc.  Arrays and maps
+// modified by patch are encoded with definite length, and other data items are kept
+// as is.  Patch can nest data items up to 3 levels deeper than the default max nested
+// level of documents.  See Diff for patch format.
+func Apply(doc, patch RawMessage) (RawMessage, error) {
+	d := decoder{data: doc, dm: defaultDecMode}
+	if err := d.wellformed(false, false); err != nil {
+		return nil, err
+	}
+	d = decoder{data: patch, dm: patchDecMode}
+	if err := d.wellformed(false, false); err != nil {
+		return nil, err
+	}
+
+	pt, ops, ok := patchContainer(patch)
+	if !ok || pt != cborTypeArray {
+		return nil, errors.New("cbor: invalid patch: patch isn't an array")
+	}
+
+	root := &patchNode{raw: doc}
+	for i, op := range ops {
+		var err error
+		if root, err = applyPatchOp(root, op); err != nil {
+			return nil, errors.New("cbor: cannot apply patch operation " + strconv.Itoa(i) + ": " + err.Error())
+		}
+	}
+
+	var e bytes.Buffer
+	root.encode(&e)
+	return RawMessage(e.Bytes()), nil
+}
+
+// applyPatchOp applies patch operation op to document root, and returns the new root.
+func applyPatchOp(root *patchNode, op []byte) (*patchNode, error) {
+	ot, opItems, ok := patchContainer(op)
+	if !ok || ot != cborTypeArray || len(opItems) < 2 || len(opItems) > 3 {
+		return nil, errors.New("operation isn't an array of 2 or 3 elements")
+	}
+
+	d := decoder{data: opItems[0], dm: defaultDecMode}
+	t, _, opNum := d.getHead()
+	if t != cborTypePositiveInt || opNum > patchOpAppend {
+		return nil, errors.New("unknown operation")
+	}
+	if (opNum == patchOpRemove) != (len(opItems) == 2) {
+		return nil, errors.New("wrong number of elements for operation " + strconv.FormatUint(opNum, 10))
+	}
+
+	pt, path, ok := patchContainer(opItems[1])
+	if !ok || pt != cborTypeArray {
+		return nil, errors.New("path isn't an array")
+	}
+
+	switch opNum {
+	case patchOpSet:
+		if len(path) == 0 {
+			return &patchNode{raw: opItems[2]}, nil
+		}
+		parent, err := root.find(path[:len(path)-1])
+		if err != nil {
+			return nil, err
+		}
+		return root, parent.set(path[len(path)-1], opItems[2])
+
+	case patchOpRemove:
+		if len(path) == 0 {
+			return nil, errors.New("cannot remove document")
+		}
+		parent, err := root.find(path[:len(path)-1])
+		if err != nil {
+			return nil, err
+		}
+		return root, parent.remove(path[len(path)-1])
+
+	default: // patchOpAppend
+		n, err := root.find(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := n.expand(); err != nil {
+			return nil, err
+		}
+		vt, elems, ok := patchContainer(opItems[2])
+		if n.isMap || !ok || vt != cborTypeArray {
+			return nil, errors.New("cannot append to data item that isn't an array")
+		}
+		for _, elem := range elems {
+			n.values = append(n.values, &patchNode{raw: elem})
+		}
+		return root, nil
+	}
+}
+
+// patchNode is a data item in a document being patched.  Arrays and maps are expanded
+// when they are traversed or modified, and other data items are kept encoded.
+type patchNode struct {
+	raw      []byte
+	expanded bool
+	isMap    bool
+	keys     [][]byte // encoded map keys
+	ckeys    []string // canonicalized map keys
+	values   []*patchNode
+}
+
+// expand parses encoded array or map to its elements.
+func (n *patchNode) expand() error {
+	if n.expanded {
+		return nil
+	}
+	t, items, ok := patchContainer(n.raw)
+	if !ok {
+		return errors.New("path refers to data item that isn't an untagged array or map")
+	}
+	if t == cborTypeArray {
+		n.values = make([]*patchNode, len(items))
+		for i, item := range items {
+			n.values[i] = &patchNode{raw: item}
+		}
+	} else {
+		n.isMap = true
+		n.keys = make([][]byte, 0, len(items)/2)
+		n.ckeys = make([]string, 0, len(items)/2)
+		n.values = make([]*patchNode, 0, len(items)/2)
+		for i := 0; i < len(items); i += 2 {
+			ck, err := Canonicalize(items[i], nil)
+			if err != nil {
+				return err
+			}
+			n.keys = append(n.keys, items[i])
+			n.ckeys = append(n.ckeys, string(ck))
+			n.values = append(n.values, &patchNode{raw: items[i+1]})
+		}
+	}
+	n.expanded = true
+	n.raw = nil
+	return nil
+}
+
+// index returns index of element identified by path element p in expanded node n,
+// or -1 if it isn't found.
+func (n *patchNode) index(p []byte) (int, error) {
+	if n.isMap {
+		ck, err := Canonicalize(p, nil)
+		if err != nil {
+			return -1, err
+		}
+		for i, k := range n.ckeys {
+			if k == string(ck) {
+				return i, nil
+			}
+		}
+		return -1, nil
+	}
+
+	d := decoder{data: p, dm: defaultDecMode}
+	t, _, val := d.getHead()
+	if t != cborTypePositiveInt || d.off != len(p) {
+		return -1, errors.New("array index isn't an unsigned integer")
+	}
+	if val >= uint64(len(n.values)) {
+		return -1, errors.New("array index " + strconv.FormatUint(val, 10) + " is out of range")
+	}
+	return int(val), nil
+}
+
+// find returns data item identified by path from n.
+func (n *patchNode) find(path [][]byte) (*patchNode, error) {
+	for _, p := range path {
+		if err := n.expand(); err != nil {
+			return nil, err
+		}
+		i, err := n.index(p)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 {
+			return nil, errors.New("map key isn't found")
+		}
+		n = n.values[i]
+	}
+	return n, nil
+}
+
+// set replaces element identified by path element p in n with value, or adds a map entry.
+func (n *patchNode) set(p []byte, value []byte) error {
+	if err := n.expand(); err != nil {
+		return err
+	}
+	i, err := n.index(p)
+	if err != nil {
+		return err
+	}
+	if i >= 0 {
+		n.values[i] = &patchNode{raw: value}
+		return nil
+	}
+	ck, err := Canonicalize(p, nil)
+	if err != nil {
+		return err
+	}
+	n.keys = append(n.keys, p)
+	n.ckeys = append(n.ckeys, string(ck))
+	n.values = append(n.values, &patchNode{raw: value})
+	return nil
+}
+
+// remove removes map entry identified by path element p from n.
+func (n *patchNode) remove(p []byte) error {
+	if err := n.expand(); err != nil {
+		return err
+	}
+	if !n.isMap {
+		return errors.New("cannot remove array element")
+	}
+	i, err := n.index(p)
+	if err != nil {
+		return err
+	}
+	if i < 0 {
+		return errors.New("map key isn't found")
+	}
+	n.keys = append(n.keys[:i], n.keys[i+1:]...)
+	n.ckeys = append(n.ckeys[:i], n.ckeys[i+1:]...)
+	n.values = append(n.values[:i], n.values[i+1:]...)
+	return nil
+}
+
+func (n *patchNode) encode(e *bytes.Buffer) {
+	if !n.expanded {
+		e.Write(n.raw)
+		return
+	}
+	if !n.isMap {
+		encodeHead(e, byte(cborTypeArray), uint64(len(n.values)))
+		for _, v := range n.values {
+			v.encode(e)
+		}
+		return
+	}
+	encodeHead(e, byte(cborTypeMap), uint64(len(n.values)))
+	for i, v := range n.values {
+		e.Write(n.keys[i])
+		v.encode(e)
+	}
+}
+
+// patchContainer returns elements of untagged array, or keys and values of untagged map,
+// in well-formed data item data.  It returns false if data isn't an untagged array or map.
+func patchContainer(data []byte) (cborType, [][]byte, bool) {
+	d := decoder{data: data, dm: defaultDecMode}
+	t := d.nextCBORType()
+	if t != cborTypeArray && t != cborTypeMap {
+		return t, nil, false
+	}
+
+	_, _, val, indefiniteLength := d.getHeadWithIndefiniteLengthFlag()
+	count := int(val)
+	if indefiniteLength {
+		count = d.numOfItemsUntilBreak()
+	} else if t == cborTypeMap {
+		count *= 2
+	}
+
+	items := make([][]byte, count)
+	for i := range items {
+		off := d.off
+		d.skip()
+		items[i] = data[off:d.off]
+	}
+	return t, items, true
+}
+
+// mapEntryIndex returns index of each map entry (key and value pair) by encoded map key.
+func mapEntryIndex(entries [][]byte) map[string]int {
+	index := make(map[string]int, len(entries)/2)
+	for i := 0; i < len(entries); i += 2 {
+		index[string(entries[i])] = i
+	}
+	return index
+}
+
+func appendPath(path [][]byte, p []byte) [][]byte {
+	newPath := make([][]byte, len(path), len(path)+1)
+	copy(newPath, path)
+	return append(newPath, p)
+}
+
+func encodePatchIndex(i int) []byte {
+	var e bytes.Buffer
+	encodeHead(&e, byte(cborTypePositiveInt), uint64(i))
+	return e.Bytes()
+}
@@ -0,0 +1,211 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDiffAndApply(t *testing.T) {
+	testCases := []struct {
+		name      string
+		oldDoc    []byte
+		newDoc    []byte
+		wantPatch []byte
+	}{
+		{
+			name:      "same",
+			oldDoc:    hexDecode("a2616101616202"),
+			newDoc:    hexDecode("a2616202616101"), // Different map order
+			wantPatch: hexDecode("80"),
+		},
+		{
+			name:      "same with different encoding",
+			oldDoc:    hexDecode("9f1801ff"),
+			newDoc:    hexDecode("8101"),
+			wantPatch: hexDecode("80"),
+		},
+		{
+			name:      "replace document",
+			oldDoc:    hexDecode("01"),
+			newDoc:    hexDecode("6161"),
+			wantPatch: hexDecode("818300806161"),
+		},
+		{
+			name:      "set map value",
+			oldDoc:    hexDecode("a2616101616202"),
+			newDoc:    hexDecode("a2616101616203"),
+			wantPatch: hexDecode("81830081616203"),
+		},
+		{
+			name:      "add and remove map entries",
+			oldDoc:    hexDecode("a2616101616202"),
+			newDoc:    hexDecode("a2616101616303"),
+			wantPatch: hexDecode("828201816162830081616303"),
+		},
+		{
+			name:      "nested map",
+			oldDoc:    hexDecode("a16161a1616201"),
+			newDoc:    hexDecode("a16161a1616202"),
+			wantPatch: hexDecode("818300826161616202"),
+		},
+		{
+			name:      "set array element",
+			oldDoc:    hexDecode("83010203"),
+			newDoc:    hexDecode("83010204"),
+			wantPatch: hexDecode("818300810204"),
+		},
+		{
+			name:      "append array elements",
+			oldDoc:    hexDecode("820102"),
+			newDoc:    hexDecode("8401020304"),
+			wantPatch: hexDecode("81830280820304"),
+		},
+		{
+			name:      "shorter array",
+			oldDoc:    hexDecode("83010203"),
+			newDoc:    hexDecode("820102"),
+			wantPatch: hexDecode("81830080820102"),
+		},
+		{
+			name:      "different type",
+			oldDoc:    hexDecode("a16161820102"),
+			newDoc:    hexDecode("a16161a0"),
+			wantPatch: hexDecode("818300816161a0"),
+		},
+		{
+			name:      "integer map key",
+			oldDoc:    hexDecode("a1011801"),
+			newDoc:    hexDecode("a10102"),
+			wantPatch: hexDecode("818300810102"),
+		},
+		{
+			name:      "tagged map is replaced",
+			oldDoc:    hexDecode("d82aa10101"),
+			newDoc:    hexDecode("d82aa10102"),
+			wantPatch: hexDecode("81830080d82aa10102"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patch, err := Diff(tc.oldDoc, tc.newDoc)
+			if err != nil {
+				t.Fatalf("Diff(0x%x, 0x%x) returned error %v", tc.oldDoc, tc.newDoc, err)
+			}
+			if !bytes.Equal(patch, tc.wantPatch) {
+				t.Errorf("Diff(0x%x, 0x%x) = 0x%x, want 0x%x", tc.oldDoc, tc.newDoc, []byte(patch), tc.wantPatch)
+			}
+
+			got, err := Apply(tc.oldDoc, patch)
+			if err != nil {
+				t.Fatalf("Apply(0x%x, 0x%x) returned error %v", tc.oldDoc, []byte(patch), err)
+			}
+			if equal, err := Equal(got, tc.newDoc, nil); err != nil || !equal {
+				t.Errorf("Apply(0x%x, 0x%x) = 0x%x, want 0x%x", tc.oldDoc, []byte(patch), []byte(got), tc.newDoc)
+			}
+		})
+	}
+}
+
+func TestApplyKeepsUnmodifiedDataItems(t *testing.T) {
+	// {"a": [_ 1], "b": 1}
+	doc := hexDecode("a261619f01ff616201")
+	// [[0, ["b"], 2]]
+	patch := hexDecode("81830081616202")
+	got, err := Apply(doc, patch)
+	if err != nil {
+		t.Fatalf("Apply() returned error %v", err)
+	}
+	if want := hexDecode("a261619f01ff616202"); !bytes.Equal(got, want) {
+		t.Errorf("Apply() = 0x%x, want 0x%x", []byte(got), want)
+	}
+}
+
+func TestDiffAndApplyNestedDocument(t *testing.T) {
+	// Array nested at max nested level 32 by default.
+	newDoc := append(bytes.Repeat([]byte{0x81}, 31), 0x80)
+	oldDoc := hexDecode("00")
+
+	patch, err := Diff(oldDoc, newDoc)
+	if err != nil {
+		t.Fatalf("Diff() returned error %v", err)
+	}
+	got, err := Apply(oldDoc, patch)
+	if err != nil {
+		t.Fatalf("Apply() returned error %v", err)
+	}
+	if !bytes.Equal(got, newDoc) {
+		t.Errorf("Apply() = 0x%x, want 0x%x", []byte(got), newDoc)
+	}
+
+	// Patch can't nest data items deeper than created by Diff.
+	patch = append(bytes.Repeat([]byte{0x81}, 35), 0x80)
+	wantErrorMsg := "cbor: exceeded max nested level 35"
+	if _, err := Apply(oldDoc, patch); err == nil {
+		t.Errorf("Apply() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Apply() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+}
+
+func TestApplyError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		doc          []byte
+		patch        []byte
+		wantErrorMsg string
+	}{
+		{"malformed document", hexDecode("81"), hexDecode("80"), "unexpected EOF"},
+		{"malformed patch", hexDecode("01"), hexDecode("81"), "unexpected EOF"},
+		{"patch isn't array", hexDecode("01"), hexDecode("a0"), "cbor: invalid patch: patch isn't an array"},
+		{"operation isn't array", hexDecode("01"), hexDecode("8101"), "cbor: cannot apply patch operation 0: operation isn't an array of 2 or 3 elements"},
+		{"unknown operation", hexDecode("01"), hexDecode("8183038001"), "cbor: cannot apply patch operation 0: unknown operation"},
+		{"set without value", hexDecode("01"), hexDecode("81820080"), "cbor: cannot apply patch operation 0: wrong number of elements for operation 0"},
+		{"path isn't array", hexDecode("01"), hexDecode("8183000101"), "cbor: cannot apply patch operation 0: path isn't an array"},
+		{"remove document", hexDecode("01"), hexDecode("81820180"), "cbor: cannot apply patch operation 0: cannot remove document"},
+		{"traverse integer", hexDecode("01"), hexDecode("818300810001"), "cbor: cannot apply patch operation 0: path refers to data item that isn't an untagged array or map"},
+		{"traverse tagged map", hexDecode("c1a0"), hexDecode("81830081616101"), "cbor: cannot apply patch operation 0: path refers to data item that isn't an untagged array or map"},
+		{"missing map key", hexDecode("a0"), hexDecode("818201816161"), "cbor: cannot apply patch operation 0: map key isn't found"},
+		{"missing nested map key", hexDecode("a0"), hexDecode("818300826161616201"), "cbor: cannot apply patch operation 0: map key isn't found"},
+		{"array index out of range", hexDecode("8101"), hexDecode("818300810102"), "cbor: cannot apply patch operation 0: array index 1 is out of range"},
+		{"array index isn't integer", hexDecode("8101"), hexDecode("81830081616102"), "cbor: cannot apply patch operation 0: array index isn't an unsigned integer"},
+		{"remove array element", hexDecode("8101"), hexDecode("8182018100"), "cbor: cannot apply patch operation 0: cannot remove array element"},
+		{"append to map", hexDecode("a0"), hexDecode("818302808101"), "cbor: cannot apply patch operation 0: cannot append to data item that isn't an array"},
+		{"second operation", hexDecode("a0"), hexDecode("82830080a0820180"), "cbor: cannot apply patch operation 1: cannot remove document"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Apply(tc.doc, tc.patch)
+			if err == nil {
+				t.Errorf("Apply(0x%x, 0x%x) didn't return an error", tc.doc, tc.patch)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Apply(0x%x, 0x%x) returned error %q, want %q", tc.doc, tc.patch, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDiffError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		oldDoc       []byte
+		newDoc       []byte
+		wantErrorMsg string
+	}{
+		{"malformed old document", hexDecode("81"), hexDecode("01"), "unexpected EOF"},
+		{"malformed new document", hexDecode("01"), hexDecode("81"), "unexpected EOF"},
+		{"duplicate map keys", hexDecode("a0"), hexDecode("a20101180102"), "cbor: found duplicate map key 0x01 after canonicalization"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Diff(tc.oldDoc, tc.newDoc)
+			if err == nil {
+				t.Errorf("Diff(0x%x, 0x%x) didn't return an error", tc.oldDoc, tc.newDoc)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Diff(0x%x, 0x%x) returned error %q, want %q", tc.oldDoc, tc.newDoc, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"reflect"
+)
+
+// StructPlan describes how a Go struct type is encoded or decoded by a mode.
+// It is returned by EncMode.Precompile and DecMode.Precompile for introspection.
+type StructPlan struct {
+	// Type is the Go struct type.
+	Type reflect.Type
+
+	// ToArray is true if the struct is encoded or decoded as CBOR array ("toarray" option).
+	ToArray bool
+
+	// TagNumbers is tag numbers registered for Type in the mode's TagSet, or nil.
+	TagNumbers []uint64
+
+	// Fields is encoded or decoded struct fields.  For encoding, fields are in the
+	// encoded order specified by EncOptions.Sort (except SortFastShuffle), and field
+	// with "unknown" option is last.  For decoding, field with "orig" option is last.
+	Fields []FieldPlan
+}
+
+// FieldPlan describes how a Go struct field is encoded or decoded by a mode.
+type FieldPlan struct {
+	// Name is the field name used as CBOR map key.  It is integer key for field
+	// with "keyasint" option (e.g. "1").
+	Name string
+
+	// Index is the index sequence of the field for reflect.Type.FieldByIndex.
+	// It has more than one element for fields promoted from embedded structs.
+	Index []int
+
+	// Type is the Go type of the field.
+	Type reflect.Type
+
+	// TagNumbers is tag numbers registered for Type in the mode's TagSet, or nil.
+	TagNumbers []uint64
+
+	KeyAsInt  bool // field has "keyasint" option
+	OmitEmpty bool // field has "omitempty" option
+	OmitZero  bool // field has "omitzero" option
+	Raw       bool // field has "raw" option
+	Unknown   bool // field has "unknown" option
+	Orig      bool // field has "orig" option
+	Array     bool // field has "array" option
+	Inline    bool // field has "inline" option
+}
+
+// Precompile builds and caches encoding information of types and all types reachable
+// from them (e.g. struct field types and slice element types), so the first Marshal
+// of values of these types doesn't pay for it.  It is intended to be called at startup
+// to warm internal caches and make latency of first requests predictable.
+//
+// Precompile returns StructPlan of each struct type found, in the order found, which
+// can be used to check struct fields and tags discovered from struct tags.  It returns
+// UnsupportedTypeError or an error for invalid struct tags if any type can't be
+// encoded.  Types of values in interface{} and types implementing Marshaler aren't
+// known until encoding, so they aren't visited.
+//
+// Cached information is shared by all modes, and cache entries can be evicted if
+// SetTypeCacheLimit is used.
+func (em *encMode) Precompile(types ...reflect.Type) ([]StructPlan, error) {
+	return precompile(types, func(t reflect.Type) (*StructPlan, []reflect.Type, error) {
+		if !isWalkableType(em, t) {
+			if f, _ := getEncodeFunc(t); f == nil {
+				return nil, nil, &UnsupportedTypeError{t}
+			}
+			return nil, nil, nil
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			structType, err := getEncodingStructType(t)
+			if err != nil {
+				return nil, nil, err
+			}
+			flds := structType.getFields(em)
+			if structType.toArray {
+				flds = structType.fields
+			}
+			if structType.unknownField != nil {
+				flds = append(flds[:len(flds):len(flds)], structType.unknownField)
+			}
+			plan := newStructPlan(t, structType.toArray, flds, em.tags)
+			return plan, fieldTypes(flds), nil
+
+		case reflect.Map:
+			return nil, []reflect.Type{t.Key(), t.Elem()}, nil
+
+		case reflect.Slice, reflect.Array:
+			return nil, []reflect.Type{t.Elem()}, nil
+		}
+		return nil, nil, nil
+	})
+}
+
+// Precompile builds and caches decoding information of types and all types reachable
+// from them (e.g. struct field types and slice element types), so the first Unmarshal
+// into values of these types doesn't pay for it.  It is intended to be called at startup
+// to warm internal caches and make latency of first requests predictable.
+//
+// Precompile returns StructPlan of each struct type found, in the order found, which
+// can be used to check struct fields and tags discovered from struct tags.  It returns
+// an error for invalid struct tags if any struct type can't be decoded.  Types
+// implementing Unmarshaler and types of values decoded into interface{} aren't visited.
+//
+// Cached information is shared by all modes, and cache entries can be evicted if
+// SetTypeCacheLimit is used.
+func (dm *decMode) Precompile(types ...reflect.Type) ([]StructPlan, error) {
+	return precompile(types, func(t reflect.Type) (*StructPlan, []reflect.Type, error) {
+		tInfo := getTypeInfo(t)
+		if tInfo.spclType != specialTypeNone {
+			return nil, nil, nil
+		}
+		switch t {
+		case typeBigInt, typeBigFloat, typeBigRat, typeRawMessage, typeByteString:
+			return nil, nil, nil
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			structType := getDecodingStructType(t)
+			if structType.err != nil {
+				return nil, nil, structType.err
+			}
+			flds := structType.fields
+			if structType.unknownField != nil {
+				flds = append(flds[:len(flds):len(flds)], structType.unknownField)
+			}
+			if structType.origField != nil {
+				flds = append(flds[:len(flds):len(flds)], structType.origField)
+			}
+			plan := newStructPlan(t, structType.toArray, flds, dm.tags)
+			return plan, fieldTypes(flds), nil
+
+		case reflect.Map:
+			return nil, []reflect.Type{t.Key(), t.Elem()}, nil
+
+		case reflect.Slice, reflect.Array:
+			return nil, []reflect.Type{t.Elem()}, nil
+		}
+		return nil, nil, nil
+	})
+}
+
+// precompile visits types and types reachable from them once, in depth-first order.
+// visit returns StructPlan of struct type t, and types reachable from t.
+func precompile(
+	types []reflect.Type,
+	visit func(t reflect.Type) (*StructPlan, []reflect.Type, error),
+) ([]StructPlan, error) {
+	var plans []StructPlan
+	visited := make(map[reflect.Type]bool)
+
+	var walk func(t reflect.Type) error
+	walk = func(t reflect.Type) error {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if visited[t] {
+			return nil
+		}
+		visited[t] = true
+
+		plan, children, err := visit(t)
+		if err != nil {
+			return err
+		}
+		if plan != nil {
+			plans = append(plans, *plan)
+		}
+		for _, child := range children {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, t := range types {
+		if t == nil {
+			continue
+		}
+		if err := walk(t); err != nil {
+			return nil, err
+		}
+	}
+	return plans, nil
+}
+
+func newStructPlan(t reflect.Type, toArray bool, flds fields, tags tagProvider) *StructPlan {
+	plan := &StructPlan{
+		Type:       t,
+		ToArray:    toArray,
+		TagNumbers: registeredTagNumbers(tags, t),
+		Fields:     make([]FieldPlan, len(flds)),
+	}
+	for i, f := range flds {
+		plan.Fields[i] = FieldPlan{
+			Name:       f.name,
+			Index:      append([]int(nil), f.idx...),
+			Type:       f.typ,
+			TagNumbers: registeredTagNumbers(tags, f.typ),
+			KeyAsInt:   f.keyAsInt,
+			OmitEmpty:  f.omitEmpty,
+			OmitZero:   f.omitZero,
+			Raw:        f.raw,
+			Unknown:    f.unknown,
+			Orig:       f.orig,
+			Array:      f.array,
+			Inline:     f.inline,
+		}
+	}
+	return plan
+}
+
+// registeredTagNumbers returns a copy of tag numbers registered for t in tags, or nil.
+func registeredTagNumbers(tags tagProvider, t reflect.Type) []uint64 {
+	if tags == nil {
+		return nil
+	}
+	tagItem := tags.getTagItemFromType(t)
+	if tagItem == nil {
+		return nil
+	}
+	return append([]uint64(nil), tagItem.num...)
+}
+
+func fieldTypes(flds fields) []reflect.Type {
+	types := make([]reflect.Type, len(flds))
+	for i, f := range flds {
+		types[i] = f.typ
+	}
+	return types
+}
@@ -0,0 +1,165 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type precompileInner struct {
+	B string `cbor:"b"`
+	A int    `cbor:"1,keyasint,omitempty"`
+}
+
+type precompileArray struct {
+	_ struct{} `cbor:",toarray"`
+	X int
+}
+
+type precompileOuter struct {
+	Inner   *precompileInner           `cbor:"inner"`
+	List    []precompileArray          `cbor:"list,omitzero"`
+	Map     map[string]precompileInner `cbor:"map"`
+	Time    time.Time                  `cbor:"time"`
+	Next    *precompileOuter           `cbor:"next"`
+	Any     interface{}                `cbor:"any"`
+	Unknown map[string]RawMessage      `cbor:",unknown"`
+}
+
+func TestEncModePrecompile(t *testing.T) {
+	tags := NewTagSet()
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagRequired}, reflect.TypeOf(precompileInner{}), 100); err != nil {
+		t.Fatalf("TagSet.Add() returned error %v", err)
+	}
+	em, err := EncOptions{Sort: SortLengthFirst}.EncModeWithTags(tags)
+	if err != nil {
+		t.Fatalf("EncModeWithTags() returned error %v", err)
+	}
+
+	typeOuter := reflect.TypeOf(precompileOuter{})
+	typeInner := reflect.TypeOf(precompileInner{})
+	typeArray := reflect.TypeOf(precompileArray{})
+
+	plans, err := em.(ExtendedEncMode).Precompile(reflect.TypeOf(&precompileOuter{}), typeInner)
+	if err != nil {
+		t.Fatalf("Precompile() returned error %v", err)
+	}
+	want := []StructPlan{
+		{
+			Type: typeOuter,
+			Fields: []FieldPlan{
+				{Name: "any", Index: []int{5}, Type: reflect.TypeOf((*interface{})(nil)).Elem()},
+				{Name: "map", Index: []int{2}, Type: reflect.TypeOf(map[string]precompileInner{})},
+				{Name: "list", Index: []int{1}, Type: reflect.TypeOf([]precompileArray{}), OmitZero: true},
+				{Name: "next", Index: []int{4}, Type: reflect.TypeOf(&precompileOuter{})},
+				{Name: "time", Index: []int{3}, Type: reflect.TypeOf(time.Time{})},
+				{Name: "inner", Index: []int{0}, Type: reflect.TypeOf(&precompileInner{})},
+				{Name: "Unknown", Index: []int{6}, Type: reflect.TypeOf(map[string]RawMessage{}), Unknown: true},
+			},
+		},
+		{
+			Type:       typeInner,
+			TagNumbers: []uint64{100},
+			Fields: []FieldPlan{
+				{Name: "1", Index: []int{1}, Type: reflect.TypeOf(0), KeyAsInt: true, OmitEmpty: true},
+				{Name: "b", Index: []int{0}, Type: reflect.TypeOf("")},
+			},
+		},
+		{
+			Type:    typeArray,
+			ToArray: true,
+			Fields: []FieldPlan{
+				{Name: "X", Index: []int{1}, Type: reflect.TypeOf(0)},
+			},
+		},
+	}
+	if !reflect.DeepEqual(plans, want) {
+		t.Errorf("Precompile() = %+v, want %+v", plans, want)
+	}
+
+	if _, ok := encodingStructTypeCache.Load(typeArray); !ok {
+		t.Errorf("Precompile() didn't cache encoding information of %s", typeArray)
+	}
+}
+
+func TestEncModePrecompileError(t *testing.T) {
+	type unsupported struct {
+		C chan int
+	}
+	type invalidKeyAsInt struct {
+		A int `cbor:"a,keyasint"`
+	}
+
+	testCases := []struct {
+		name         string
+		typ          reflect.Type
+		wantErrorMsg string
+	}{
+		{
+			name:         "unsupported field type",
+			typ:          reflect.TypeOf(unsupported{}),
+			wantErrorMsg: "cbor: unsupported type: cbor.unsupported",
+		},
+		{
+			name:         "unsupported type in slice",
+			typ:          reflect.TypeOf([]func(){}),
+			wantErrorMsg: "cbor: unsupported type: func()",
+		},
+		{
+			name:         "invalid keyasint",
+			typ:          reflect.TypeOf(invalidKeyAsInt{}),
+			wantErrorMsg: "cbor: failed to parse field name \"a\" to int (strconv.ParseUint: parsing \"a\": invalid syntax)",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := defaultEncMode.Precompile(tc.typ)
+			if err == nil {
+				t.Errorf("Precompile(%s) didn't return an error", tc.typ)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Precompile(%s) returned error %q, want %q", tc.typ, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestDecModePrecompile(t *testing.T) {
+	typeOuter := reflect.TypeOf(precompileOuter{})
+	typeInner := reflect.TypeOf(precompileInner{})
+	typeArray := reflect.TypeOf(precompileArray{})
+
+	plans, err := defaultDecMode.Precompile(reflect.TypeOf([]precompileOuter{}))
+	if err != nil {
+		t.Fatalf("Precompile() returned error %v", err)
+	}
+	var types []reflect.Type
+	for _, plan := range plans {
+		types = append(types, plan.Type)
+	}
+	if want := []reflect.Type{typeOuter, typeInner, typeArray}; !reflect.DeepEqual(types, want) {
+		t.Errorf("Precompile() returned plans of types %v, want %v", types, want)
+	}
+	if n := len(plans[0].Fields); n != 7 {
+		t.Errorf("Precompile() returned %d fields of %s, want 7", n, typeOuter)
+	} else if f := plans[0].Fields[6]; f.Name != "Unknown" || !f.Unknown {
+		t.Errorf("Precompile() returned last field %+v, want field with unknown option", f)
+	}
+
+	if _, ok := decodingStructTypeCache.Load(typeArray); !ok {
+		t.Errorf("Precompile() didn't cache decoding information of %s", typeArray)
+	}
+
+	type invalidKeyAsInt struct {
+		A int `cbor:"a,keyasint"`
+	}
+	typ := reflect.TypeOf(invalidKeyAsInt{})
+	wantErrorMsg := "cbor: failed to parse field name \"a\" to int (strconv.ParseUint: parsing \"a\": invalid syntax)"
+	if _, err := defaultDecMode.Precompile(typ); err == nil {
+		t.Errorf("Precompile(%s) didn't return an error", typ)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Precompile(%s) returned error %q, want %q", typ, err.Error(), wantErrorMsg)
+	}
+}
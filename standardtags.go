@@ -0,0 +1,147 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+// Tag numbers of standard tags with Go types registered by AddStandardTags.
+const (
+	tagNumURI                  = 32
+	tagNumBase64URL            = 33
+	tagNumBase64               = 34
+	tagNumRegexp               = 35
+	tagNumMIMEMessage          = 36
+	tagNumUUID                 = 37
+	tagNumLanguageTaggedString = 38
+)
+
+// URI represents tag 32 (URI, RFC 8949 Section 3.4.5.3) content.
+type URI string
+
+// URL parses u as URL.
+func (u URI) URL() (*url.URL, error) {
+	return url.Parse(string(u))
+}
+
+// Base64URLString represents tag 33 (base64url-encoded text, RFC 8949 Section 3.4.5.3)
+// content, which is encoded without padding.
+type Base64URLString string
+
+// Decode returns bytes encoded in s.
+func (s Base64URLString) Decode() ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(string(s))
+}
+
+// Base64String represents tag 34 (base64-encoded text, RFC 8949 Section 3.4.5.3)
+// content, which is encoded with padding.
+type Base64String string
+
+// Decode returns bytes encoded in s.
+func (s Base64String) Decode() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(string(s))
+}
+
+// Regexp represents tag 35 (regular expression, RFC 8949 Section 3.4.5.3) content,
+// which is a regular expression pattern in PCRE or ECMAScript syntax.
+type Regexp string
+
+// Compile parses r as Go regular expression.  Patterns using PCRE or ECMAScript
+// features not supported by Go (e.g. backreferences) return an error.
+func (r Regexp) Compile() (*regexp.Regexp, error) {
+	return regexp.Compile(string(r))
+}
+
+// MIMEMessage represents tag 36 (MIME message, RFC 8949 Section 3.4.5.3) content,
+// including all headers.
+type MIMEMessage string
+
+// UUID represents tag 37 (binary UUID, RFC 4122) content.  It is encoded as CBOR
+// byte string of 16 bytes.
+type UUID [16]byte
+
+// String returns u in the standard form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx.
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// MarshalBinary returns bytes of u.
+func (u UUID) MarshalBinary() ([]byte, error) {
+	return u[:], nil
+}
+
+// UnmarshalBinary sets u to data, which must be 16 bytes.
+func (u *UUID) UnmarshalBinary(data []byte) error {
+	if len(data) != len(u) {
+		return errors.New("cbor: invalid UUID length " + strconv.Itoa(len(data)))
+	}
+	copy(u[:], data)
+	return nil
+}
+
+// LanguageTaggedString represents tag 38 (language-tagged string, registered in IANA
+// CBOR Tags registry) content, which is an array of BCP 47 language tag and text.
+type LanguageTaggedString struct {
+	_        struct{} `cbor:",toarray"`
+	Language string
+	Text     string
+}
+
+// standardTagTypes are Go types registered by AddStandardTags.
+var standardTagTypes = []struct {
+	typ reflect.Type
+	num uint64
+}{
+	{reflect.TypeOf(URI("")), tagNumURI},
+	{reflect.TypeOf(Base64URLString("")), tagNumBase64URL},
+	{reflect.TypeOf(Base64String("")), tagNumBase64},
+	{reflect.TypeOf(Regexp("")), tagNumRegexp},
+	{reflect.TypeOf(MIMEMessage("")), tagNumMIMEMessage},
+	{reflect.TypeOf(UUID{}), tagNumUUID},
+	{reflect.TypeOf(LanguageTaggedString{}), tagNumLanguageTaggedString},
+}
+
+// AddStandardTags registers Go types for tags 32 to 38 in tags: URI, Base64URLString,
+// Base64String, Regexp, MIMEMessage, UUID, and LanguageTaggedString.  These types are
+// encoded with their tag numbers, and tag content is decoded to them, including into
+// empty interfaces (instead of Tag).  Untagged content can also be decoded to them.
+// Tag 39 (identifier) isn't registered since its content can be any data item.
+//
+// It returns an error if any of these types or tag numbers is already in tags, in which
+// case types registered before the conflict aren't removed.
+func AddStandardTags(tags TagSet) error {
+	opts := TagOptions{EncTag: EncTagRequired, DecTag: DecTagOptional}
+	for _, t := range standardTagTypes {
+		if err := tags.Add(opts, t.typ, t.num); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewStandardTagSet returns a new TagSet with Go types for tags 32 to 38 registered
+// by AddStandardTags.  It can be used with EncModeWithTags and DecModeWithTags, and
+// more tags can be added to it.
+func NewStandardTagSet() TagSet {
+	tags := NewTagSet()
+	_ = AddStandardTags(tags) // Adding to empty TagSet doesn't fail.
+	return tags
+}
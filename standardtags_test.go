@@ -0,0 +1,152 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestStandardTags(t *testing.T) {
+	tags := NewStandardTagSet()
+	em, err := EncOptions{}.EncModeWithTags(tags)
+	if err != nil {
+		t.Fatalf("EncModeWithTags() returned error %v", err)
+	}
+	dm, err := DecOptions{}.DecModeWithTags(tags)
+	if err != nil {
+		t.Fatalf("DecModeWithTags() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		v    interface{}
+		data []byte
+	}{
+		{
+			name: "URI",
+			v:    URI("http://a.b"),
+			data: hexDecode("d8206a687474703a2f2f612e62"),
+		},
+		{
+			name: "Base64URLString",
+			v:    Base64URLString("_-8"),
+			data: hexDecode("d821635f2d38"),
+		},
+		{
+			name: "Base64String",
+			v:    Base64String("/+8="),
+			data: hexDecode("d822642f2b383d"),
+		},
+		{
+			name: "Regexp",
+			v:    Regexp("^a+$"),
+			data: hexDecode("d823645e612b24"),
+		},
+		{
+			name: "MIMEMessage",
+			v:    MIMEMessage("a: b"),
+			data: hexDecode("d82464613a2062"),
+		},
+		{
+			name: "UUID",
+			v:    UUID{0x8c, 0x8a, 0x8d, 0x48, 0x9b, 0x3e, 0x4b, 0x49, 0x9d, 0x2a, 0x4f, 0x8f, 0x4d, 0x84, 0x3b, 0x2c},
+			data: hexDecode("d825508c8a8d489b3e4b499d2a4f8f4d843b2c"),
+		},
+		{
+			name: "LanguageTaggedString",
+			v:    LanguageTaggedString{Language: "en", Text: "Hi"},
+			data: hexDecode("d8268262656e624869"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := em.Marshal(tc.v)
+			if err != nil {
+				t.Fatalf("Marshal() returned error %v", err)
+			}
+			if !bytes.Equal(b, tc.data) {
+				t.Errorf("Marshal() = 0x%x, want 0x%x", b, tc.data)
+			}
+
+			// Registered tags are decoded to registered types, including into empty interface.
+			var i interface{}
+			if err := dm.Unmarshal(tc.data, &i); err != nil {
+				t.Fatalf("Unmarshal() returned error %v", err)
+			}
+			if !reflect.DeepEqual(i, tc.v) {
+				t.Errorf("Unmarshal() = %v (%T), want %v (%T)", i, i, tc.v, tc.v)
+			}
+
+			// Untagged content is also decoded to registered types.
+			v := reflect.New(reflect.TypeOf(tc.v))
+			if err := dm.Unmarshal(tc.data[2:], v.Interface()); err != nil {
+				t.Fatalf("Unmarshal() returned error %v", err)
+			}
+			if !reflect.DeepEqual(v.Elem().Interface(), tc.v) {
+				t.Errorf("Unmarshal() = %v, want %v", v.Elem().Interface(), tc.v)
+			}
+		})
+	}
+}
+
+func TestStandardTagsMethods(t *testing.T) {
+	u, err := URI("http://a.b/c?d=e").URL()
+	if err != nil {
+		t.Fatalf("URL() returned error %v", err)
+	}
+	if u.Host != "a.b" || u.Path != "/c" {
+		t.Errorf("URL() = %v, want host a.b and path /c", u)
+	}
+
+	b, err := Base64URLString("_-8").Decode()
+	if err != nil || !bytes.Equal(b, []byte{0xff, 0xef}) {
+		t.Errorf("Base64URLString.Decode() = 0x%x, %v, want 0xffef", b, err)
+	}
+	b, err = Base64String("/+8=").Decode()
+	if err != nil || !bytes.Equal(b, []byte{0xff, 0xef}) {
+		t.Errorf("Base64String.Decode() = 0x%x, %v, want 0xffef", b, err)
+	}
+
+	re, err := Regexp("^a+$").Compile()
+	if err != nil {
+		t.Fatalf("Compile() returned error %v", err)
+	}
+	if !re.MatchString("aa") {
+		t.Errorf("Compile() returned %v, which doesn't match \"aa\"", re)
+	}
+
+	uuid := UUID{0x8c, 0x8a, 0x8d, 0x48, 0x9b, 0x3e, 0x4b, 0x49, 0x9d, 0x2a, 0x4f, 0x8f, 0x4d, 0x84, 0x3b, 0x2c}
+	if s, want := uuid.String(), "8c8a8d48-9b3e-4b49-9d2a-4f8f4d843b2c"; s != want {
+		t.Errorf("UUID.String() = %q, want %q", s, want)
+	}
+}
+
+func TestStandardTagsError(t *testing.T) {
+	dm, err := DecOptions{}.DecModeWithTags(NewStandardTagSet())
+	if err != nil {
+		t.Fatalf("DecModeWithTags() returned error %v", err)
+	}
+	wantErrorMsg := "cbor: invalid UUID length 2"
+	var uuid UUID
+	if err := dm.Unmarshal(hexDecode("d825420102"), &uuid); err == nil {
+		t.Errorf("Unmarshal() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Unmarshal() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+
+	tags := NewTagSet()
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagRequired}, reflect.TypeOf(myUUIDString("")), 37); err != nil {
+		t.Fatalf("TagSet.Add() returned error %v", err)
+	}
+	wantErrorMsg = "cbor: tag number [37] already exists in TagSet"
+	if err := AddStandardTags(tags); err == nil {
+		t.Errorf("AddStandardTags() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("AddStandardTags() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+}
+
+type myUUIDString string
@@ -8,6 +8,8 @@ import (
 	"errors"
 	"io"
 	"reflect"
+	"strconv"
+	"unicode/utf8"
 )
 
 // Decoder reads and decodes CBOR values from io.Reader.
@@ -17,6 +19,16 @@ type Decoder struct {
 	buf       []byte
 	off       int // next read offset in buf
 	bytesRead int
+
+	// maxPending is the max number of bytes read from r in one Read call, or 0 if unlimited.
+	maxPending int
+
+	// frameLength reads length prefix of the next frame, or is nil if data items aren't framed.
+	frameLength FrameLengthFunc
+
+	// lastConsumed is the number of bytes consumed by the last Decode, Skip, or
+	// DecodeTextChunks call.
+	lastConsumed int
 }
 
 // NewDecoder returns a new decoder that reads and decodes from r using
@@ -27,6 +39,11 @@ func NewDecoder(r io.Reader) *Decoder {
 
 // Decode reads CBOR value and decodes it into the value pointed to by v.
 func (dec *Decoder) Decode(v interface{}) error {
+	dec.lastConsumed = 0
+	if dec.frameLength != nil {
+		return dec.decodeFrame(v)
+	}
+
 	_, err := dec.readNext()
 	if err != nil {
 		// Return validation error or read error.
@@ -35,6 +52,9 @@ func (dec *Decoder) Decode(v interface{}) error {
 
 	dec.d.reset(dec.buf[dec.off:])
 	err = dec.d.value(v)
+	if err != nil {
+		addErrorOffset(err, dec.bytesRead)
+	}
 
 	// Increment dec.off even if decoding err is not nil because
 	// dec.d.off points to the next CBOR data item if current
@@ -43,13 +63,25 @@ func (dec *Decoder) Decode(v interface{}) error {
 	// call to this function.
 	dec.off += dec.d.off
 	dec.bytesRead += dec.d.off
+	dec.lastConsumed = dec.d.off
 
 	return err
 }
 
 // Skip skips to the next CBOR data item (if there is any),
 // otherwise it returns error such as io.EOF, io.UnexpectedEOF, etc.
+//
+// Skip checks that the skipped data item is well-formed and within limits specified
+// by decoding options (e.g. MaxNestedLevels and MaxArrayElements), same as Decode.
+// The data item isn't decoded to Go values, so Skip doesn't allocate memory other
+// than for buffering data read from io.Reader.  If an error is returned, the data
+// item isn't skipped.
 func (dec *Decoder) Skip() error {
+	dec.lastConsumed = 0
+	if dec.frameLength != nil {
+		return dec.decodeFrame(nil)
+	}
+
 	n, err := dec.readNext()
 	if err != nil {
 		// Return validation error or read error.
@@ -58,6 +90,147 @@ func (dec *Decoder) Skip() error {
 
 	dec.off += n
 	dec.bytesRead += n
+	dec.lastConsumed = n
+	return nil
+}
+
+// DecodeTextChunks reads the next CBOR data item, which must be a text string, and calls
+// fn with each chunk of it, without concatenating chunks in memory.  Chunks of an
+// indefinite-length text string are read one at a time, so memory use is bounded by the
+// largest chunk rather than by the length of the text string.  A definite-length text
+// string is passed to fn as a single chunk.
+//
+// Chunk passed to fn is only valid until fn returns.  If fn returns an error, decoding
+// stops and the error is returned.  Each chunk is checked for valid UTF-8 as specified by
+// DecOptions.UTF8, and the total length is checked against DecOptions.MaxStringBytes.
+//
+// If the next CBOR data item isn't a text string, it is skipped and UnmarshalTypeError is
+// returned.  If an error is returned after reading some chunks, the position of dec is
+// undefined and dec shouldn't be used to decode following data items.
+func (dec *Decoder) DecodeTextChunks(fn func(chunk []byte) error) error {
+	dec.lastConsumed = 0
+	if dec.frameLength != nil {
+		return errors.New("cbor: DecodeTextChunks isn't supported by Decoder with framing")
+	}
+	if err := dec.fill(1); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return io.EOF
+		}
+		return err
+	}
+
+	b := dec.buf[dec.off]
+	t := getType(b)
+	if t != cborTypeTextString {
+		if err := dec.Skip(); err != nil {
+			return err
+		}
+		return &UnmarshalTypeError{CBORType: t.String(), GoType: "text string chunks"}
+	}
+
+	start := dec.bytesRead
+	if b&0x1f != additionalInformationAsIndefiniteLengthFlag {
+		if _, err := dec.decodeTextChunk(fn, 0); err != nil {
+			return err
+		}
+		dec.lastConsumed = dec.bytesRead - start
+		return nil
+	}
+
+	if dec.d.dm.indefLength == IndefLengthForbidden {
+		return &IndefiniteLengthError{t}
+	}
+	if dec.d.dm.deterministic != DeterministicNotChecked {
+		return newNonDeterministicError(t, "indefinite-length "+t.String())
+	}
+	dec.off++
+	dec.bytesRead++
+
+	totalBytes := 0
+	for {
+		if err := dec.fill(1); err != nil {
+			return err
+		}
+		b := dec.buf[dec.off]
+		if isBreakFlag(b) {
+			dec.off++
+			dec.bytesRead++
+			dec.lastConsumed = dec.bytesRead - start
+			return nil
+		}
+		if nt := getType(b); nt != t {
+			return &SyntaxError{msg: "cbor: wrong element type " + nt.String() + " for indefinite-length " + t.String()}
+		}
+		if b&0x1f == additionalInformationAsIndefiniteLengthFlag {
+			return &SyntaxError{msg: "cbor: indefinite-length " + t.String() + " chunk is not definite-length"}
+		}
+		n, err := dec.decodeTextChunk(fn, totalBytes)
+		if err != nil {
+			return err
+		}
+		totalBytes += n
+	}
+}
+
+// decodeTextChunk reads a definite-length text string from dec and calls fn with its
+// content.  totalBytes is the length of previous chunks of the same text string.
+// It returns the length of the text string.
+func (dec *Decoder) decodeTextChunk(fn func(chunk []byte) error, totalBytes int) (int, error) {
+	headSize := 1
+	switch dec.buf[dec.off] & 0x1f {
+	case additionalInformationWith1ByteArgument:
+		headSize = 2
+	case additionalInformationWith2ByteArgument:
+		headSize = 3
+	case additionalInformationWith4ByteArgument:
+		headSize = 5
+	case additionalInformationWith8ByteArgument:
+		headSize = 9
+	}
+	if err := dec.fill(headSize); err != nil {
+		return 0, err
+	}
+
+	dec.d.reset(dec.buf[dec.off : dec.off+headSize])
+	t, _, val, err := dec.d.wellformedHead()
+	if err != nil {
+		return 0, err
+	}
+	n := int(val)
+	if n < 0 {
+		// Detect integer overflow
+		return 0, errors.New("cbor: " + t.String() + " length " + strconv.FormatUint(val, 10) + " is too large, causing integer overflow")
+	}
+	if maxBytes := dec.d.dm.maxStringBytes; maxBytes > 0 && n > maxBytes-totalBytes {
+		return 0, &MaxStringBytesError{t, maxBytes}
+	}
+	if err := dec.fill(headSize + n); err != nil {
+		return 0, err
+	}
+
+	chunk := dec.buf[dec.off+headSize : dec.off+headSize+n]
+	if dec.d.dm.utf8 == UTF8RejectInvalid && !utf8.Valid(chunk) {
+		return 0, &SemanticError{msg: "cbor: invalid UTF-8 string"}
+	}
+	dec.off += headSize + n
+	dec.bytesRead += headSize + n
+	if err := fn(chunk); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// fill reads from r until dec.buf has at least n bytes of unread data.
+func (dec *Decoder) fill(n int) error {
+	for len(dec.buf)-dec.off < n {
+		m, err := dec.read()
+		if m == 0 && err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
 	return nil
 }
 
@@ -66,12 +239,167 @@ func (dec *Decoder) NumBytesRead() int {
 	return dec.bytesRead
 }
 
+// InputOffset returns the input stream byte offset of the current decoder position,
+// which is the end of the most recently decoded or skipped CBOR data item and the
+// beginning of the next one.  Since a malformed data item isn't consumed, InputOffset
+// is the offset of the malformed data item after Decode or Skip returns its error.
+// It is the same as NumBytesRead, similar to encoding/json's Decoder.InputOffset.
+func (dec *Decoder) InputOffset() int64 {
+	return int64(dec.bytesRead)
+}
+
+// SetMaxPending limits the number of bytes dec buffers ahead of the CBOR data item
+// being decoded to less than n.  Each Read call on the underlying io.Reader is given at
+// most n bytes of buffer, so a data item is read with as many Read calls as needed,
+// and at most n-1 bytes of the following data items are buffered when Decode, Skip,
+// or DecodeTextChunks returns.
+//
+// This keeps memory use per Decoder predictable, and leaves unread data in the
+// underlying connection (applying backpressure to the peer) instead of in dec.
+// Smaller n requires more Read calls, so read deadlines on a net.Conn should be
+// set per Decode rather than per Read.  SetMaxPending doesn't limit the size of
+// a data item; use DecOptions such as MaxStringBytes and MaxArrayElements for that.
+//
+// n <= 0 removes the limit, which is the default.  The limit is kept after Reset.
+func (dec *Decoder) SetMaxPending(n int) {
+	if n < 0 {
+		n = 0
+	}
+	dec.maxPending = n
+}
+
+// FrameLengthFunc reads the length prefix of the next frame from r, and returns the
+// length of the frame content in bytes.  r reads from the input of Decoder, and implements
+// io.ByteReader (e.g. for binary.ReadUvarint).  r must not be wrapped by a buffered reader,
+// since bytes read from r are consumed as the length prefix.
+//
+// FrameLengthFunc should return an error for frame lengths too large for the application,
+// since Decoder buffers the entire frame before decoding it.  io.EOF returned before
+// reading any byte indicates the end of input.
+type FrameLengthFunc func(r io.Reader) (int, error)
+
+// SetFraming makes dec read CBOR data items carried in length-prefixed frames (e.g. from
+// length-delimited transport messages), with each frame containing exactly one CBOR data
+// item.  Before each Decode or Skip, fn reads the length prefix of the next frame.
+//
+// Decode and Skip consume the entire frame even if an error is returned, so the next
+// frame can be decoded after an error in a frame.  If a frame contains data after the
+// CBOR data item, ExtraneousDataError (ErrExtraneousData) is returned without decoding
+// the data item.  If a frame is empty or contains an incomplete data item,
+// io.ErrUnexpectedEOF is returned.  DecodeTextChunks isn't supported with framing.
+//
+// fn = nil removes framing, which is the default.  Framing is kept after Reset.
+func (dec *Decoder) SetFraming(fn FrameLengthFunc) {
+	dec.frameLength = fn
+}
+
+// BytesConsumed returns the number of bytes consumed by the last call to Decode, Skip, or
+// DecodeTextChunks, including the frame length prefix if SetFraming is used.  It is 0 if the data item
+// wasn't consumed (e.g. a malformed data item or a read error).
+func (dec *Decoder) BytesConsumed() int {
+	return dec.lastConsumed
+}
+
+// decodeFrame reads the next frame and decodes its data item into v, or checks its data
+// item if v is nil.
+func (dec *Decoder) decodeFrame(v interface{}) error {
+	prefixLen, frameLen, err := dec.readFrame()
+	if err != nil {
+		return err
+	}
+
+	start := dec.off + prefixLen
+	data := dec.buf[start : start+frameLen]
+	dec.d.reset(data)
+	err = dec.d.wellformed(false, false)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF // empty frame
+	}
+	if err == nil && v != nil {
+		dec.d.reset(data)
+		err = dec.d.value(v)
+	}
+	if err != nil {
+		addErrorOffset(err, dec.bytesRead+prefixLen)
+	}
+
+	dec.off += prefixLen + frameLen
+	dec.bytesRead += prefixLen + frameLen
+	dec.lastConsumed = prefixLen + frameLen
+	return err
+}
+
+// readFrame reads the length prefix and content of the next frame to dec.buf, and returns
+// the length of the prefix and of the content.  The frame starts at dec.off.
+func (dec *Decoder) readFrame() (prefixLen int, frameLen int, err error) {
+	r := frameReader{dec: dec}
+	frameLen, err = dec.frameLength(&r)
+	if err != nil {
+		if err == io.EOF && r.n > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, 0, err
+	}
+	if frameLen < 0 {
+		return 0, 0, errors.New("cbor: invalid frame length " + strconv.Itoa(frameLen))
+	}
+	if err = dec.fill(r.n + frameLen); err != nil {
+		return 0, 0, err
+	}
+	return r.n, frameLen, nil
+}
+
+// frameReader reads the frame length prefix from the input of Decoder.
+type frameReader struct {
+	dec *Decoder
+	n   int // number of bytes read after dec.off
+}
+
+func (r *frameReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := r.dec.fill(r.n + 1); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	n := copy(p, r.dec.buf[r.dec.off+r.n:])
+	r.n += n
+	return n, nil
+}
+
+func (r *frameReader) ReadByte() (byte, error) {
+	if err := r.dec.fill(r.n + 1); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	b := r.dec.buf[r.dec.off+r.n]
+	r.n++
+	return b, nil
+}
+
 // Buffered returns a reader for data remaining in Decoder's buffer.
 // Returned reader is valid until the next call to Decode or Skip.
 func (dec *Decoder) Buffered() io.Reader {
 	return bytes.NewReader(dec.buf[dec.off:])
 }
 
+// Reset discards any buffered data and resets dec to read from r, keeping the
+// decoding mode and internal buffer of dec.  This allows Decoders to be pooled
+// (e.g. with sync.Pool) and reused to reduce allocations.
+func (dec *Decoder) Reset(r io.Reader) {
+	dec.r = r
+	dec.buf = dec.buf[:0]
+	dec.off = 0
+	dec.bytesRead = 0
+	dec.lastConsumed = 0
+	dec.d.reset(nil)
+}
+
 // readNext() reads next CBOR data item from Reader to buffer.
 // It returns the size of next CBOR data item.
 // It also returns validation error or read error if any.
@@ -92,6 +420,7 @@ func (dec *Decoder) readNext() (int, error) {
 			}
 
 			if validErr != io.ErrUnexpectedEOF {
+				addErrorOffset(validErr, dec.bytesRead)
 				return 0, validErr
 			}
 
@@ -133,7 +462,10 @@ func (dec *Decoder) readNext() (int, error) {
 // - dec.off is 0.
 func (dec *Decoder) read() (int, error) {
 	// Grow buf if needed.
-	const minRead = 512
+	minRead := 512
+	if dec.maxPending > 0 && dec.maxPending < minRead {
+		minRead = dec.maxPending
+	}
 	if cap(dec.buf)-len(dec.buf)+dec.off < minRead {
 		oldUnreadBuf := dec.buf[dec.off:]
 		dec.buf = make([]byte, len(dec.buf)-dec.off, 2*cap(dec.buf)+minRead)
@@ -146,7 +478,11 @@ func (dec *Decoder) read() (int, error) {
 	}
 
 	// Read from reader and reslice buf.
-	n, err := dec.r.Read(dec.buf[len(dec.buf):cap(dec.buf)])
+	end := cap(dec.buf)
+	if dec.maxPending > 0 && end-len(dec.buf) > dec.maxPending {
+		end = len(dec.buf) + dec.maxPending
+	}
+	n, err := dec.r.Read(dec.buf[len(dec.buf):end])
 	dec.buf = dec.buf[0 : len(dec.buf)+n]
 	return n, err
 }
@@ -162,6 +498,14 @@ type Encoder struct {
 	w          io.Writer
 	em         *encMode
 	indefTypes []cborType
+
+	// buf holds encoded data not yet written to w if bufSize > 0.
+	buf     []byte
+	bufSize int
+
+	// selfDescribedTagWritten is true if tag number 55799 has been written
+	// with SelfDescribedCBOROncePerStream.
+	selfDescribedTagWritten bool
 }
 
 // NewEncoder returns a new encoder that writes to w using the default encoding options.
@@ -169,7 +513,18 @@ func NewEncoder(w io.Writer) *Encoder {
 	return defaultEncMode.NewEncoder(w)
 }
 
+// EncodeSeq writes elements yielded by seq to w as a CBOR array, using the default
+// encoding options.
+//
+// See the documentation for (*Encoder).EncodeSeq for details.
+func EncodeSeq(w io.Writer, seq func(yield func(v interface{}) bool)) error {
+	return NewEncoder(w).EncodeSeq(seq)
+}
+
 // Encode writes the CBOR encoding of v.
+//
+// Inside an indefinite-length byte or text string, v is written as a definite-length chunk
+// of the same type, regardless of encoding options and registered tags.
 func (enc *Encoder) Encode(v interface{}) error {
 	if len(enc.indefTypes) > 0 && v != nil {
 		indefType := enc.indefTypes[len(enc.indefTypes)-1]
@@ -178,26 +533,258 @@ func (enc *Encoder) Encode(v interface{}) error {
 			if k != reflect.String {
 				return errors.New("cbor: cannot encode item type " + k.String() + " for indefinite-length text string")
 			}
+			return enc.encodeStringChunk(cborTypeTextString, reflect.ValueOf(v))
 		} else if indefType == cborTypeByteString {
 			t := reflect.TypeOf(v)
 			k := t.Kind()
 			if (k != reflect.Array && k != reflect.Slice) || t.Elem().Kind() != reflect.Uint8 {
 				return errors.New("cbor: cannot encode item type " + k.String() + " for indefinite-length byte string")
 			}
+			return enc.encodeStringChunk(cborTypeByteString, reflect.ValueOf(v))
 		}
 	}
 
-	buf := getEncodeBuffer()
+	buf := enc.em.getBuffer()
 
-	err := encode(buf, enc.em, reflect.ValueOf(v))
+	tagged := enc.encodeSelfDescribedTag(buf)
+	err := encodeValue(buf, enc.em, v)
 	if err == nil {
-		_, err = enc.w.Write(buf.Bytes())
+		err = enc.write(buf.Bytes())
+	}
+	if err == nil && tagged {
+		enc.selfDescribedTagWritten = true
+	}
+
+	enc.em.putBuffer(buf)
+	return err
+}
+
+// encodeStringChunk writes v as a definite-length chunk of indefinite-length string.
+// Chunks are always encoded as CBOR type t, so encoding options such as ByteSliceToArray,
+// StringChunkSize, and registered tags don't apply to them.
+func (enc *Encoder) encodeStringChunk(t cborType, v reflect.Value) error {
+	buf := enc.em.getBuffer()
+	encodeHead(buf, byte(t), uint64(v.Len()))
+	switch v.Kind() {
+	case reflect.String:
+		buf.WriteString(v.String())
+	case reflect.Slice:
+		buf.Write(v.Bytes())
+	default:
+		for i := 0; i < v.Len(); i++ {
+			buf.WriteByte(byte(v.Index(i).Uint()))
+		}
+	}
+	err := enc.write(buf.Bytes())
+	enc.em.putBuffer(buf)
+	return err
+}
+
+// encodeSelfDescribedTag writes tag number 55799 to buf and returns true if the next data
+// item written by enc is a top-level data item to be prefixed with it.
+func (enc *Encoder) encodeSelfDescribedTag(buf *bytes.Buffer) bool {
+	if len(enc.indefTypes) > 0 {
+		return false
+	}
+	switch enc.em.selfDescribedCBOR {
+	case SelfDescribedCBOREachItem:
+	case SelfDescribedCBOROncePerStream:
+		if enc.selfDescribedTagWritten {
+			return false
+		}
+	default:
+		return false
+	}
+	encodeHead(buf, byte(cborTypeTag), tagNumSelfDescribedCBOR)
+	return true
+}
+
+// Reset discards any unfinished indefinite-length data items and buffered data, and
+// resets enc to write to w, keeping the encoding mode and buffer size of enc.  This
+// allows Encoders to be pooled (e.g. with sync.Pool) and reused to reduce allocations.
+func (enc *Encoder) Reset(w io.Writer) {
+	enc.w = w
+	enc.indefTypes = enc.indefTypes[:0]
+	enc.selfDescribedTagWritten = false
+	enc.buf = enc.buf[:0]
+}
+
+// SetBufferSize makes enc buffer encoded data in memory and write it to the underlying
+// io.Writer in batches of up to n bytes, so encoding many small data items (e.g. to a
+// net.Conn) doesn't need one Write call per data item.  Encoded data larger than n
+// bytes is written directly after the buffered data.
+//
+// Buffered data is written only when the buffer is full or Flush is called, so Flush
+// must be called after the last data item, including when streaming indefinite-length
+// data items with StartIndefiniteArray, etc.  Call Flush after writing chunks that the
+// peer needs to receive before EndIndefinite is called.
+//
+// n <= 0 disables buffering, which is the default.  Data already buffered is written
+// by the next write or Flush.  The buffer size is kept after Reset.
+func (enc *Encoder) SetBufferSize(n int) {
+	if n < 0 {
+		n = 0
+	}
+	enc.bufSize = n
+}
+
+// Flush writes buffered data to the underlying io.Writer.  Data not written because
+// of an error is kept in the buffer.
+func (enc *Encoder) Flush() error {
+	if len(enc.buf) == 0 {
+		return nil
 	}
+	n, err := enc.w.Write(enc.buf)
+	if n < len(enc.buf) && err == nil {
+		err = io.ErrShortWrite
+	}
+	enc.buf = enc.buf[:copy(enc.buf, enc.buf[n:])]
+	return err
+}
 
+// Buffered returns the number of bytes buffered by enc and not yet written to the
+// underlying io.Writer.
+func (enc *Encoder) Buffered() int {
+	return len(enc.buf)
+}
+
+// write writes p to the underlying io.Writer, or appends p to the buffer if it has room.
+func (enc *Encoder) write(p []byte) error {
+	if len(enc.buf)+len(p) <= enc.bufSize {
+		enc.buf = append(enc.buf, p...)
+		return nil
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	if len(p) < enc.bufSize {
+		enc.buf = append(enc.buf, p...)
+		return nil
+	}
+	_, err := enc.w.Write(p)
+	return err
+}
+
+// EncodeReader writes a definite-length CBOR byte string containing size bytes read from r.
+// Content is copied from r to the underlying io.Writer without being buffered in memory,
+// so it can be used to encode very large byte strings.
+//
+// Inside an indefinite-length byte string, it writes a chunk without the tag specified by
+// EncOptions.ByteSliceLaterFormat.
+//
+// If r returns fewer than size bytes, io.ErrUnexpectedEOF is returned.  In this case,
+// a partially encoded byte string has already been written.
+func (enc *Encoder) EncodeReader(r io.Reader, size int64) error {
+	if size < 0 {
+		return errors.New("cbor: cannot encode byte string with negative length " + strconv.FormatInt(size, 10))
+	}
+	var indefType cborType
+	if len(enc.indefTypes) > 0 {
+		indefType = enc.indefTypes[len(enc.indefTypes)-1]
+	}
+	if indefType == cborTypeTextString {
+		return errors.New("cbor: cannot encode byte string for indefinite-length text string")
+	}
+
+	buf := getEncodeBuffer()
+	tagged := enc.encodeSelfDescribedTag(buf)
+	// Chunks of indefinite-length byte string can't be tagged.
+	if enc.em.byteSliceLaterEncodingTag != 0 && indefType != cborTypeByteString {
+		encodeHead(buf, byte(cborTypeTag), enc.em.byteSliceLaterEncodingTag)
+	}
+	encodeHead(buf, byte(cborTypeByteString), uint64(size))
+	err := enc.write(buf.Bytes())
 	putEncodeBuffer(buf)
+	if err == nil {
+		// Content is copied directly to the underlying io.Writer after buffered data.
+		err = enc.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	if tagged {
+		enc.selfDescribedTagWritten = true
+	}
+
+	n, err := io.CopyN(enc.w, r, size)
+	if n < size && err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
 	return err
 }
 
+// EncodeSeq writes elements yielded by seq as a CBOR array, so a lazily produced
+// collection (e.g. database cursor) can be encoded without being buffered in memory.
+// seq has the same signature as iter.Seq[interface{}] in Go 1.23.
+//
+// EncodeSeq writes an indefinite-length array, unless IndefLength is IndefLengthForbidden.
+// In that case, EncodeSeq calls seq twice: first to count the elements, and then to
+// encode them in a definite-length array, so seq must yield the same number of elements
+// each time it is called.  An error is returned if it doesn't.
+//
+// If an error is returned, a partially encoded array may have already been written.
+func (enc *Encoder) EncodeSeq(seq func(yield func(v interface{}) bool)) error {
+	if len(enc.indefTypes) > 0 {
+		if t := enc.indefTypes[len(enc.indefTypes)-1]; t == cborTypeByteString || t == cborTypeTextString {
+			return errors.New("cbor: cannot encode array for indefinite-length " + t.String())
+		}
+	}
+
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
+
+	tagged := enc.encodeSelfDescribedTag(buf)
+
+	// count is -1 for indefinite-length array.
+	count := -1
+	if enc.em.indefLength == IndefLengthForbidden {
+		count = 0
+		seq(func(interface{}) bool {
+			count++
+			return true
+		})
+		encodeHead(buf, byte(cborTypeArray), uint64(count))
+	} else {
+		buf.WriteByte(cborArrayWithIndefiniteLengthHead)
+	}
+	if err := enc.write(buf.Bytes()); err != nil {
+		return err
+	}
+	if tagged {
+		enc.selfDescribedTagWritten = true
+	}
+
+	n := 0
+	var err error
+	seq(func(v interface{}) bool {
+		if n == count {
+			n++ // more elements than counted
+			return false
+		}
+		buf.Reset()
+		if err = encodeValue(buf, enc.em, v); err != nil {
+			return false
+		}
+		if err = enc.write(buf.Bytes()); err != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if count < 0 {
+		return enc.write([]byte{cborBreakFlag})
+	}
+	if n != count {
+		return errors.New("cbor: sequence yielded different number of elements when encoding definite-length array of " +
+			strconv.Itoa(count) + " elements")
+	}
+	return nil
+}
+
 // StartIndefiniteByteString starts byte string encoding of indefinite length.
 // Subsequent calls of (*Encoder).Encode() encodes definite length byte strings
 // ("chunks") as one contiguous string until EndIndefinite is called.
@@ -231,7 +818,7 @@ func (enc *Encoder) EndIndefinite() error {
 	if len(enc.indefTypes) == 0 {
 		return errors.New("cbor: cannot encode \"break\" code outside indefinite length values")
 	}
-	_, err := enc.w.Write([]byte{cborBreakFlag})
+	err := enc.write([]byte{cborBreakFlag})
 	if err == nil {
 		enc.indefTypes = enc.indefTypes[:len(enc.indefTypes)-1]
 	}
@@ -249,9 +836,18 @@ func (enc *Encoder) startIndefinite(typ cborType) error {
 	if enc.em.indefLength == IndefLengthForbidden {
 		return &IndefiniteLengthError{typ}
 	}
-	_, err := enc.w.Write(cborIndefHeader[typ])
+
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
+
+	tagged := enc.encodeSelfDescribedTag(buf)
+	buf.Write(cborIndefHeader[typ])
+	err := enc.write(buf.Bytes())
 	if err == nil {
 		enc.indefTypes = append(enc.indefTypes, typ)
+		if tagged {
+			enc.selfDescribedTagWritten = true
+		}
 	}
 	return err
 }
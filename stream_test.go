@@ -5,10 +5,12 @@ package cbor
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -375,6 +377,58 @@ func TestDecoderSkip(t *testing.T) {
 	}
 }
 
+func TestDecoderSkipNestedLevels(t *testing.T) {
+	dm, err := DecOptions{MaxNestedLevels: 4}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	// [[[[1]]]], [[[[[1]]]]]
+	dec := dm.NewDecoder(bytes.NewReader(hexDecode("818181810181818181810101")))
+	if err := dec.Skip(); err != nil {
+		t.Fatalf("Skip() returned error %v", err)
+	}
+	wantErrorMsg := "cbor: exceeded max nested level 4"
+	for i := 0; i < 2; i++ {
+		err := dec.Skip()
+		if err == nil {
+			t.Fatalf("Skip() didn't return an error")
+		}
+		if _, ok := err.(*MaxNestedLevelError); !ok {
+			t.Errorf("Skip() returned wrong error type %T, want (*MaxNestedLevelError)", err)
+		} else if err.Error() != wantErrorMsg {
+			t.Errorf("Skip() returned error %q, want %q", err.Error(), wantErrorMsg)
+		}
+		if dec.NumBytesRead() != 5 {
+			t.Errorf("NumBytesRead() = %d, want 5", dec.NumBytesRead())
+		}
+	}
+}
+
+func TestDecoderSkipAllocs(t *testing.T) {
+	// [1, [2, {"a": h'0102'}], "text", 1.5]
+	item := hexDecode("84018202a161614201026474657874f93e00")
+
+	const numItems = 100
+	dec := NewDecoder(bytes.NewReader(bytes.Repeat(item, numItems)))
+
+	// Skip some data items so that buffer is allocated.
+	for i := 0; i < numItems/2; i++ {
+		if err := dec.Skip(); err != nil {
+			t.Fatalf("Skip() returned error %v", err)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(numItems/2-1, func() {
+		if err := dec.Skip(); err != nil {
+			t.Fatalf("Skip() returned error %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Skip() allocated %v times, want 0", allocs)
+	}
+}
+
 func TestDecoderSkipInvalidDataError(t *testing.T) {
 	var buf bytes.Buffer
 	for _, tc := range unmarshalTests {
@@ -554,6 +608,169 @@ func TestDecoderSkipRecoverableReadError(t *testing.T) {
 	}
 }
 
+func TestDecoderDecodeTextChunks(t *testing.T) {
+	// (_ "ab", "", "cde"), "f", 1
+	data := hexDecode("7f6261626063636465ff616601")
+
+	for _, maxBytesPerRead := range []int{1, 2, len(data)} {
+		t.Run(fmt.Sprintf("%d bytes per read", maxBytesPerRead), func(t *testing.T) {
+			dec := NewDecoder(newNBytesReader(data, maxBytesPerRead))
+
+			var chunks []string
+			fn := func(chunk []byte) error {
+				chunks = append(chunks, string(chunk))
+				return nil
+			}
+
+			if err := dec.DecodeTextChunks(fn); err != nil {
+				t.Fatalf("DecodeTextChunks() returned error %v", err)
+			}
+			if want := []string{"ab", "", "cde"}; !reflect.DeepEqual(chunks, want) {
+				t.Errorf("DecodeTextChunks() returned chunks %q, want %q", chunks, want)
+			}
+			if dec.NumBytesRead() != 10 {
+				t.Errorf("NumBytesRead() = %d, want 10", dec.NumBytesRead())
+			}
+
+			chunks = nil
+			if err := dec.DecodeTextChunks(fn); err != nil {
+				t.Fatalf("DecodeTextChunks() returned error %v", err)
+			}
+			if want := []string{"f"}; !reflect.DeepEqual(chunks, want) {
+				t.Errorf("DecodeTextChunks() returned chunks %q, want %q", chunks, want)
+			}
+
+			var v int
+			if err := dec.Decode(&v); err != nil {
+				t.Fatalf("Decode() returned error %v", err)
+			}
+			if v != 1 {
+				t.Errorf("Decode() = %d, want 1", v)
+			}
+
+			if err := dec.DecodeTextChunks(fn); err != io.EOF {
+				t.Errorf("DecodeTextChunks() returned error %v, want %v", err, io.EOF)
+			}
+			if dec.NumBytesRead() != len(data) {
+				t.Errorf("NumBytesRead() = %d, want %d", dec.NumBytesRead(), len(data))
+			}
+		})
+	}
+}
+
+func TestDecoderDecodeTextChunksBoundedMemory(t *testing.T) {
+	const chunkSize = 1000
+	const numChunks = 1000
+
+	chunk := append(hexDecode("7903e8"), bytes.Repeat([]byte{'a'}, chunkSize)...)
+	var b bytes.Buffer
+	b.WriteByte(0x7f)
+	for i := 0; i < numChunks; i++ {
+		b.Write(chunk)
+	}
+	b.WriteByte(0xff)
+
+	dec := NewDecoder(&b)
+	totalBytes := 0
+	err := dec.DecodeTextChunks(func(chunk []byte) error {
+		if len(chunk) != chunkSize {
+			t.Errorf("DecodeTextChunks() returned chunk of %d bytes, want %d", len(chunk), chunkSize)
+		}
+		totalBytes += len(chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DecodeTextChunks() returned error %v", err)
+	}
+	if totalBytes != chunkSize*numChunks {
+		t.Errorf("DecodeTextChunks() returned %d bytes, want %d", totalBytes, chunkSize*numChunks)
+	}
+	if cap(dec.buf) > 8*chunkSize {
+		t.Errorf("DecodeTextChunks() buffered %d bytes, want <= %d", cap(dec.buf), 8*chunkSize)
+	}
+}
+
+func TestDecoderDecodeTextChunksError(t *testing.T) {
+	errCallback := errors.New("callback error")
+
+	testCases := []struct {
+		name         string
+		opts         DecOptions
+		data         []byte
+		fnErr        error
+		wantErrorMsg string
+	}{
+		{
+			name:         "integer",
+			data:         hexDecode("01"),
+			wantErrorMsg: "cbor: cannot unmarshal positive integer into Go value of type text string chunks",
+		},
+		{
+			name:         "malformed array",
+			data:         hexDecode("82"),
+			wantErrorMsg: "unexpected EOF",
+		},
+		{
+			name:         "truncated text string",
+			data:         hexDecode("6261"),
+			wantErrorMsg: "unexpected EOF",
+		},
+		{
+			name:         "truncated indefinite-length text string",
+			data:         hexDecode("7f6161"),
+			wantErrorMsg: "unexpected EOF",
+		},
+		{
+			name:         "wrong chunk type",
+			data:         hexDecode("7f4161ff"),
+			wantErrorMsg: "cbor: wrong element type byte string for indefinite-length UTF-8 text string",
+		},
+		{
+			name:         "indefinite-length chunk",
+			data:         hexDecode("7f7fffff"),
+			wantErrorMsg: "cbor: indefinite-length UTF-8 text string chunk is not definite-length",
+		},
+		{
+			name:         "invalid UTF-8 chunk",
+			data:         hexDecode("7f62c328ff"),
+			wantErrorMsg: "cbor: invalid UTF-8 string",
+		},
+		{
+			name:         "exceed MaxStringBytes",
+			opts:         DecOptions{MaxStringBytes: 2},
+			data:         hexDecode("7f6261616161ff"),
+			wantErrorMsg: "cbor: exceeded max length 2 bytes for CBOR UTF-8 text string",
+		},
+		{
+			name:         "indefinite length forbidden",
+			opts:         DecOptions{IndefLength: IndefLengthForbidden},
+			data:         hexDecode("7f6161ff"),
+			wantErrorMsg: "cbor: indefinite-length UTF-8 text string isn't allowed",
+		},
+		{
+			name:         "callback error",
+			data:         hexDecode("7f6161ff"),
+			fnErr:        errCallback,
+			wantErrorMsg: errCallback.Error(),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dm, err := tc.opts.DecMode()
+			if err != nil {
+				t.Fatalf("DecMode() returned error %v", err)
+			}
+			dec := dm.NewDecoder(bytes.NewReader(tc.data))
+			err = dec.DecodeTextChunks(func([]byte) error { return tc.fnErr })
+			if err == nil {
+				t.Errorf("DecodeTextChunks() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecodeTextChunks() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
 func TestDecoderStructTag(t *testing.T) {
 	type strc struct {
 		A string `json:"x" cbor:"a"`
@@ -663,6 +880,149 @@ func TestDecoderBuffered(t *testing.T) {
 	}
 }
 
+func TestDecoderInputOffset(t *testing.T) {
+	// 1, [2, 3], "a", 0xf8 0x00 (invalid simple value), 4
+	data := hexDecode("018202036161f80004")
+
+	dec := NewDecoder(bytes.NewReader(data))
+	if off := dec.InputOffset(); off != 0 {
+		t.Errorf("InputOffset() = %d, want 0", off)
+	}
+
+	var v interface{}
+	for _, wantOffset := range []int64{1, 4} {
+		if err := dec.Decode(&v); err != nil {
+			t.Fatalf("Decode() returned error %v", err)
+		}
+		if off := dec.InputOffset(); off != wantOffset {
+			t.Errorf("InputOffset() = %d, want %d", off, wantOffset)
+		}
+	}
+	if err := dec.Skip(); err != nil {
+		t.Fatalf("Skip() returned error %v", err)
+	}
+	if off := dec.InputOffset(); off != 6 {
+		t.Errorf("InputOffset() = %d, want 6", off)
+	}
+
+	// Malformed data item isn't consumed, so offset locates it in the input.
+	if err := dec.Decode(&v); err == nil {
+		t.Fatalf("Decode() didn't return an error")
+	}
+	if off := dec.InputOffset(); off != 6 {
+		t.Errorf("InputOffset() = %d, want 6", off)
+	}
+	buffered, err := io.ReadAll(dec.Buffered())
+	if err != nil {
+		t.Fatalf("failed to read from reader returned by Buffered(): %v", err)
+	}
+	if want := data[6:]; !bytes.Equal(buffered, want) {
+		t.Errorf("Buffered() = 0x%x, want 0x%x", buffered, want)
+	}
+}
+
+// maxReadSizeReader records the largest buffer passed to Read.
+type maxReadSizeReader struct {
+	r           io.Reader
+	maxReadSize int
+}
+
+func (r *maxReadSizeReader) Read(p []byte) (int, error) {
+	if len(p) > r.maxReadSize {
+		r.maxReadSize = len(p)
+	}
+	return r.r.Read(p)
+}
+
+func TestDecoderSetMaxPending(t *testing.T) {
+	// "aaaa...a" (100 bytes), 1, [2, 3]
+	data := hexDecode("7864" + strings.Repeat("61", 100) + "01820203")
+
+	for _, maxPending := range []int{1, 3, 16} {
+		t.Run(strconv.Itoa(maxPending), func(t *testing.T) {
+			r := &maxReadSizeReader{r: bytes.NewReader(data)}
+			dec := NewDecoder(r)
+			dec.SetMaxPending(maxPending)
+
+			var s string
+			if err := dec.Decode(&s); err != nil {
+				t.Fatalf("Decode() returned error %v", err)
+			}
+			if s != strings.Repeat("a", 100) {
+				t.Errorf("Decode() = %q, want 100 bytes of \"a\"", s)
+			}
+			if n, _ := io.Copy(io.Discard, dec.Buffered()); int(n) >= maxPending {
+				t.Errorf("Buffered() has %d bytes, want less than %d", n, maxPending)
+			}
+
+			var v interface{}
+			for _, want := range []interface{}{uint64(1), []interface{}{uint64(2), uint64(3)}} {
+				if err := dec.Decode(&v); err != nil {
+					t.Fatalf("Decode() returned error %v", err)
+				}
+				if !reflect.DeepEqual(v, want) {
+					t.Errorf("Decode() = %v, want %v", v, want)
+				}
+			}
+			if err := dec.Decode(&v); err != io.EOF {
+				t.Errorf("Decode() returned error %v, want %v", err, io.EOF)
+			}
+			if r.maxReadSize > maxPending {
+				t.Errorf("Read() was called with %d bytes, want at most %d", r.maxReadSize, maxPending)
+			}
+		})
+	}
+
+	// Negative limit is the same as no limit.
+	r := &maxReadSizeReader{r: bytes.NewReader(data)}
+	dec := NewDecoder(r)
+	dec.SetMaxPending(-1)
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("Decode() returned error %v", err)
+	}
+	if r.maxReadSize <= len(data) {
+		t.Errorf("Read() was called with %d bytes, want more than %d", r.maxReadSize, len(data))
+	}
+}
+
+func TestDecoderReset(t *testing.T) {
+	dm, err := DecOptions{MaxArrayElements: 16}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+
+	// Decode the first of two data items, leaving the second one buffered.
+	decoder := dm.NewDecoder(bytes.NewReader(hexDecode("0102")))
+	var v int
+	if err := decoder.Decode(&v); err != nil {
+		t.Fatalf("Decode() returned error %v", err)
+	}
+
+	decoder.Reset(bytes.NewReader(hexDecode("8103")))
+	if n := decoder.NumBytesRead(); n != 0 {
+		t.Errorf("NumBytesRead() = %d after Reset(), want 0", n)
+	}
+	var a []int
+	if err := decoder.Decode(&a); err != nil {
+		t.Fatalf("Decode() returned error %v", err)
+	}
+	if !reflect.DeepEqual(a, []int{3}) {
+		t.Errorf("Decode() = %v, want [3]", a)
+	}
+	if err := decoder.Decode(&v); err != io.EOF {
+		t.Errorf("Decode() returned error %v, want %v", err, io.EOF)
+	}
+
+	// Decoding mode is kept after Reset.
+	data := hexDecode("91" + strings.Repeat("00", 17))
+	decoder.Reset(bytes.NewReader(data))
+	wantErrorMsg := "cbor: exceeded max number of elements 16 for CBOR array"
+	if err := decoder.Decode(&a); err == nil || err.Error() != wantErrorMsg {
+		t.Errorf("Decode(0x%x) returned error %v, want %q", data, err, wantErrorMsg)
+	}
+}
+
 func TestEncoder(t *testing.T) {
 	var want bytes.Buffer
 	var w bytes.Buffer
@@ -685,6 +1045,160 @@ func TestEncoder(t *testing.T) {
 	}
 }
 
+func TestEncoderReset(t *testing.T) {
+	em, err := EncOptions{IndefLength: IndefLengthForbidden}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	var w1 bytes.Buffer
+	encoder := em.NewEncoder(&w1)
+	if err := encoder.Encode(1); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+
+	var w2 bytes.Buffer
+	encoder.Reset(&w2)
+	if err := encoder.Encode([]int{2}); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+	if want := hexDecode("01"); !bytes.Equal(w1.Bytes(), want) {
+		t.Errorf("Encode() wrote 0x%x before Reset(), want 0x%x", w1.Bytes(), want)
+	}
+	if want := hexDecode("8102"); !bytes.Equal(w2.Bytes(), want) {
+		t.Errorf("Encode() wrote 0x%x after Reset(), want 0x%x", w2.Bytes(), want)
+	}
+
+	// Encoding mode is kept after Reset.
+	wantErrorMsg := "cbor: indefinite-length array isn't allowed"
+	if err := encoder.StartIndefiniteArray(); err == nil || err.Error() != wantErrorMsg {
+		t.Errorf("StartIndefiniteArray() returned error %v, want %q", err, wantErrorMsg)
+	}
+}
+
+func TestEncoderSelfDescribedCBOR(t *testing.T) {
+	testCases := []struct {
+		name   string
+		mode   SelfDescribedCBORMode
+		encode func(enc *Encoder) error
+		want   []byte
+	}{
+		{
+			name: "each item",
+			mode: SelfDescribedCBOREachItem,
+			encode: func(enc *Encoder) error {
+				if err := enc.Encode(1); err != nil {
+					return err
+				}
+				return enc.Encode([]int{2})
+			},
+			want: hexDecode("d9d9f701d9d9f78102"), // 55799(1), 55799([2])
+		},
+		{
+			name: "once per stream",
+			mode: SelfDescribedCBOROncePerStream,
+			encode: func(enc *Encoder) error {
+				if err := enc.Encode(1); err != nil {
+					return err
+				}
+				return enc.Encode([]int{2})
+			},
+			want: hexDecode("d9d9f7018102"), // 55799(1), [2]
+		},
+		{
+			name: "indefinite-length array",
+			mode: SelfDescribedCBOREachItem,
+			encode: func(enc *Encoder) error {
+				if err := enc.StartIndefiniteArray(); err != nil {
+					return err
+				}
+				if err := enc.Encode(1); err != nil {
+					return err
+				}
+				return enc.EndIndefinite()
+			},
+			want: hexDecode("d9d9f79f01ff"), // 55799([_ 1])
+		},
+		{
+			name: "EncodeReader",
+			mode: SelfDescribedCBOREachItem,
+			encode: func(enc *Encoder) error {
+				return enc.EncodeReader(strings.NewReader("a"), 1)
+			},
+			want: hexDecode("d9d9f74161"), // 55799(h'61')
+		},
+		{
+			name: "EncodeSeq",
+			mode: SelfDescribedCBOROncePerStream,
+			encode: func(enc *Encoder) error {
+				seq := func(yield func(v interface{}) bool) {
+					yield(1)
+				}
+				if err := enc.EncodeSeq(seq); err != nil {
+					return err
+				}
+				return enc.EncodeSeq(seq)
+			},
+			want: hexDecode("d9d9f79f01ff9f01ff"), // 55799([_ 1]), [_ 1]
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := EncOptions{SelfDescribedCBOR: tc.mode}.EncMode()
+			if err != nil {
+				t.Fatalf("EncMode() returned error %v", err)
+			}
+			var w bytes.Buffer
+			if err := tc.encode(em.NewEncoder(&w)); err != nil {
+				t.Fatalf("encoding returned error %v", err)
+			}
+			if !bytes.Equal(w.Bytes(), tc.want) {
+				t.Errorf("Encoder wrote 0x%x, want 0x%x", w.Bytes(), tc.want)
+			}
+		})
+	}
+
+	t.Run("once per stream after Reset", func(t *testing.T) {
+		em, err := EncOptions{SelfDescribedCBOR: SelfDescribedCBOROncePerStream}.EncMode()
+		if err != nil {
+			t.Fatalf("EncMode() returned error %v", err)
+		}
+		var w1, w2 bytes.Buffer
+		enc := em.NewEncoder(&w1)
+		if err := enc.Encode(1); err != nil {
+			t.Fatalf("Encode() returned error %v", err)
+		}
+		enc.Reset(&w2)
+		if err := enc.Encode(2); err != nil {
+			t.Fatalf("Encode() returned error %v", err)
+		}
+		if want := hexDecode("d9d9f702"); !bytes.Equal(w2.Bytes(), want) {
+			t.Errorf("Encode() wrote 0x%x after Reset(), want 0x%x", w2.Bytes(), want)
+		}
+	})
+}
+
+func TestEncoderResetIndefiniteLength(t *testing.T) {
+	var w1 bytes.Buffer
+	encoder := NewEncoder(&w1)
+	if err := encoder.StartIndefiniteTextString(); err != nil {
+		t.Fatalf("StartIndefiniteTextString() returned error %v", err)
+	}
+
+	// Unfinished indefinite-length text string is discarded.
+	var w2 bytes.Buffer
+	encoder.Reset(&w2)
+	if err := encoder.Encode(1); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+	if err := encoder.EndIndefinite(); err == nil {
+		t.Errorf("EndIndefinite() didn't return an error after Reset()")
+	}
+	if want := hexDecode("01"); !bytes.Equal(w2.Bytes(), want) {
+		t.Errorf("Encode() wrote 0x%x after Reset(), want 0x%x", w2.Bytes(), want)
+	}
+}
+
 func TestEncoderError(t *testing.T) {
 	testcases := []struct {
 		name         string
@@ -715,30 +1229,416 @@ func TestEncoderError(t *testing.T) {
 	}
 }
 
-func TestIndefiniteByteString(t *testing.T) {
-	want := hexDecode("5f42010243030405ff")
-	var w bytes.Buffer
-	encoder := NewEncoder(&w)
-	if err := encoder.StartIndefiniteByteString(); err != nil {
-		t.Fatalf("StartIndefiniteByteString() returned error %v", err)
-	}
-	if err := encoder.Encode([]byte{1, 2}); err != nil {
-		t.Fatalf("Encode() returned error %v", err)
-	}
-	if err := encoder.Encode([3]byte{3, 4, 5}); err != nil {
-		t.Fatalf("Encode() returned error %v", err)
-	}
-	if err := encoder.EndIndefinite(); err != nil {
-		t.Fatalf("EndIndefinite() returned error %v", err)
+func TestEncoderEncodeReader(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     EncOptions
+		data     []byte
+		wantData []byte
+	}{
+		{
+			name:     "empty",
+			data:     []byte{},
+			wantData: hexDecode("40"),
+		},
+		{
+			name:     "4 bytes",
+			data:     []byte{1, 2, 3, 4},
+			wantData: hexDecode("4401020304"),
+		},
+		{
+			name:     "24 bytes",
+			data:     bytes.Repeat([]byte{0xaa}, 24),
+			wantData: append(hexDecode("5818"), bytes.Repeat([]byte{0xaa}, 24)...),
+		},
+		{
+			name:     "later encoding tag",
+			opts:     EncOptions{ByteSliceLaterFormat: ByteSliceLaterFormatBase16},
+			data:     []byte{1, 2},
+			wantData: hexDecode("d7420102"),
+		},
 	}
-	if !bytes.Equal(w.Bytes(), want) {
-		t.Errorf("Encoding mismatch: got %v, want %v", w.Bytes(), want)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := tc.opts.EncMode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var w bytes.Buffer
+			encoder := em.NewEncoder(&w)
+			if err := encoder.EncodeReader(bytes.NewReader(tc.data), int64(len(tc.data))); err != nil {
+				t.Fatalf("EncodeReader() returned error %v", err)
+			}
+			if !bytes.Equal(w.Bytes(), tc.wantData) {
+				t.Errorf("EncodeReader() = 0x%x, want 0x%x", w.Bytes(), tc.wantData)
+			}
+
+			var got []byte
+			if err := Unmarshal(w.Bytes(), &got); err != nil {
+				t.Fatalf("Unmarshal() returned error %v", err)
+			}
+			if !bytes.Equal(got, tc.data) {
+				t.Errorf("Unmarshal() = 0x%x, want 0x%x", got, tc.data)
+			}
+		})
 	}
 }
 
-func TestIndefiniteByteStringError(t *testing.T) {
-	var w bytes.Buffer
-	encoder := NewEncoder(&w)
+func TestEncoderEncodeReaderInIndefiniteByteString(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     EncOptions
+		wantData []byte
+	}{
+		{
+			name:     "default options",
+			wantData: hexDecode("5f42010243030405ff"),
+		},
+		{
+			// Chunks of indefinite-length byte string can't be tagged.
+			name:     "later encoding tag",
+			opts:     EncOptions{ByteSliceLaterFormat: ByteSliceLaterFormatBase16},
+			wantData: hexDecode("5f42010243030405ff"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := tc.opts.EncMode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var w bytes.Buffer
+			encoder := em.NewEncoder(&w)
+			if err := encoder.StartIndefiniteByteString(); err != nil {
+				t.Fatalf("StartIndefiniteByteString() returned error %v", err)
+			}
+			if err := encoder.EncodeReader(bytes.NewReader([]byte{1, 2}), 2); err != nil {
+				t.Fatalf("EncodeReader() returned error %v", err)
+			}
+			if err := encoder.EncodeReader(bytes.NewReader([]byte{3, 4, 5, 6}), 3); err != nil {
+				t.Fatalf("EncodeReader() returned error %v", err)
+			}
+			if err := encoder.EndIndefinite(); err != nil {
+				t.Fatalf("EndIndefinite() returned error %v", err)
+			}
+			if !bytes.Equal(w.Bytes(), tc.wantData) {
+				t.Errorf("Encoding mismatch: got 0x%x, want 0x%x", w.Bytes(), tc.wantData)
+			}
+			if err := Wellformed(w.Bytes()); err != nil {
+				t.Errorf("Wellformed(0x%x) returned error %v", w.Bytes(), err)
+			}
+		})
+	}
+}
+
+func TestEncoderEncodeReaderInIndefiniteArray(t *testing.T) {
+	em, err := EncOptions{ByteSliceLaterFormat: ByteSliceLaterFormatBase16}.EncMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := hexDecode("9fd7420102ff") // [_ 23(h'0102')]
+	var w bytes.Buffer
+	encoder := em.NewEncoder(&w)
+	if err := encoder.StartIndefiniteArray(); err != nil {
+		t.Fatalf("StartIndefiniteArray() returned error %v", err)
+	}
+	if err := encoder.EncodeReader(bytes.NewReader([]byte{1, 2}), 2); err != nil {
+		t.Fatalf("EncodeReader() returned error %v", err)
+	}
+	if err := encoder.EndIndefinite(); err != nil {
+		t.Fatalf("EndIndefinite() returned error %v", err)
+	}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("Encoding mismatch: got 0x%x, want 0x%x", w.Bytes(), want)
+	}
+}
+
+func TestEncoderEncodeReaderError(t *testing.T) {
+	var w bytes.Buffer
+	encoder := NewEncoder(&w)
+
+	wantErrorMsg := "cbor: cannot encode byte string with negative length -1"
+	if err := encoder.EncodeReader(bytes.NewReader(nil), -1); err == nil {
+		t.Errorf("EncodeReader() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("EncodeReader() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+
+	if err := encoder.EncodeReader(bytes.NewReader([]byte{1, 2}), 3); err != io.ErrUnexpectedEOF {
+		t.Errorf("EncodeReader() returned error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	w.Reset()
+	if err := encoder.StartIndefiniteTextString(); err != nil {
+		t.Fatalf("StartIndefiniteTextString() returned error %v", err)
+	}
+	wantErrorMsg = "cbor: cannot encode byte string for indefinite-length text string"
+	if err := encoder.EncodeReader(bytes.NewReader([]byte{1}), 1); err == nil {
+		t.Errorf("EncodeReader() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("EncodeReader() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+	if w.Len() != 1 {
+		t.Errorf("Encoder's writer has %d bytes of data, want 1 byte", w.Len())
+	}
+}
+
+func TestEncoderEncodeSeq(t *testing.T) {
+	seqOf := func(values ...interface{}) func(yield func(v interface{}) bool) {
+		return func(yield func(v interface{}) bool) {
+			for _, v := range values {
+				if !yield(v) {
+					return
+				}
+			}
+		}
+	}
+
+	emDefinite, err := EncOptions{IndefLength: IndefLengthForbidden}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		em   EncMode
+		seq  func(yield func(v interface{}) bool)
+		want []byte
+	}{
+		{
+			name: "indefinite-length array",
+			em:   defaultEncMode,
+			seq:  seqOf(1, "a", []int{2}),
+			want: hexDecode("9f0161618102ff"),
+		},
+		{
+			name: "empty indefinite-length array",
+			em:   defaultEncMode,
+			seq:  seqOf(),
+			want: hexDecode("9fff"),
+		},
+		{
+			name: "definite-length array",
+			em:   emDefinite,
+			seq:  seqOf(1, "a", []int{2}),
+			want: hexDecode("830161618102"),
+		},
+		{
+			name: "empty definite-length array",
+			em:   emDefinite,
+			seq:  seqOf(),
+			want: hexDecode("80"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var w bytes.Buffer
+			if err := tc.em.NewEncoder(&w).EncodeSeq(tc.seq); err != nil {
+				t.Fatalf("EncodeSeq() returned error %v", err)
+			}
+			if !bytes.Equal(w.Bytes(), tc.want) {
+				t.Errorf("EncodeSeq() = 0x%x, want 0x%x", w.Bytes(), tc.want)
+			}
+		})
+	}
+
+	t.Run("package-level EncodeSeq", func(t *testing.T) {
+		var w bytes.Buffer
+		if err := EncodeSeq(&w, seqOf(1, 2)); err != nil {
+			t.Fatalf("EncodeSeq() returned error %v", err)
+		}
+		want := hexDecode("9f0102ff")
+		if !bytes.Equal(w.Bytes(), want) {
+			t.Errorf("EncodeSeq() = 0x%x, want 0x%x", w.Bytes(), want)
+		}
+	})
+
+	t.Run("in indefinite-length array", func(t *testing.T) {
+		var w bytes.Buffer
+		encoder := NewEncoder(&w)
+		if err := encoder.StartIndefiniteArray(); err != nil {
+			t.Fatalf("StartIndefiniteArray() returned error %v", err)
+		}
+		if err := encoder.EncodeSeq(seqOf(1)); err != nil {
+			t.Fatalf("EncodeSeq() returned error %v", err)
+		}
+		if err := encoder.EndIndefinite(); err != nil {
+			t.Fatalf("EndIndefinite() returned error %v", err)
+		}
+		want := hexDecode("9f9f01ffff")
+		if !bytes.Equal(w.Bytes(), want) {
+			t.Errorf("EncodeSeq() = 0x%x, want 0x%x", w.Bytes(), want)
+		}
+	})
+}
+
+func TestEncoderEncodeSeqError(t *testing.T) {
+	emDefinite, err := EncOptions{IndefLength: IndefLengthForbidden}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+
+	// growingSeq yields one more element each time it is called.
+	growingSeq := func() func(yield func(v interface{}) bool) {
+		calls := 0
+		return func(yield func(v interface{}) bool) {
+			calls++
+			for i := 0; i < calls; i++ {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	}
+
+	// shrinkingSeq yields one less element each time it is called.
+	shrinkingSeq := func() func(yield func(v interface{}) bool) {
+		n := 3
+		return func(yield func(v interface{}) bool) {
+			n--
+			for i := 0; i < n; i++ {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	}
+
+	testCases := []struct {
+		name         string
+		em           EncMode
+		seq          func(yield func(v interface{}) bool)
+		wantErrorMsg string
+	}{
+		{
+			name: "unsupported element type",
+			em:   defaultEncMode,
+			seq: func(yield func(v interface{}) bool) {
+				if yield(1) {
+					yield(make(chan bool))
+				}
+			},
+			wantErrorMsg: "cbor: unsupported type: chan bool",
+		},
+		{
+			name:         "more elements than counted",
+			em:           emDefinite,
+			seq:          growingSeq(),
+			wantErrorMsg: "cbor: sequence yielded different number of elements when encoding definite-length array of 1 elements",
+		},
+		{
+			name:         "fewer elements than counted",
+			em:           emDefinite,
+			seq:          shrinkingSeq(),
+			wantErrorMsg: "cbor: sequence yielded different number of elements when encoding definite-length array of 2 elements",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var w bytes.Buffer
+			if err := tc.em.NewEncoder(&w).EncodeSeq(tc.seq); err == nil {
+				t.Errorf("EncodeSeq() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("EncodeSeq() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+
+	t.Run("in indefinite-length byte string", func(t *testing.T) {
+		var w bytes.Buffer
+		encoder := NewEncoder(&w)
+		if err := encoder.StartIndefiniteByteString(); err != nil {
+			t.Fatalf("StartIndefiniteByteString() returned error %v", err)
+		}
+		wantErrorMsg := "cbor: cannot encode array for indefinite-length byte string"
+		if err := encoder.EncodeSeq(func(yield func(v interface{}) bool) {}); err == nil {
+			t.Errorf("EncodeSeq() didn't return an error")
+		} else if err.Error() != wantErrorMsg {
+			t.Errorf("EncodeSeq() returned error %q, want %q", err.Error(), wantErrorMsg)
+		}
+		if w.Len() != 1 {
+			t.Errorf("Encoder's writer has %d bytes of data, want 1 byte", w.Len())
+		}
+	})
+}
+
+func TestIndefiniteByteString(t *testing.T) {
+	want := hexDecode("5f42010243030405ff")
+	var w bytes.Buffer
+	encoder := NewEncoder(&w)
+	if err := encoder.StartIndefiniteByteString(); err != nil {
+		t.Fatalf("StartIndefiniteByteString() returned error %v", err)
+	}
+	if err := encoder.Encode([]byte{1, 2}); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+	if err := encoder.Encode([3]byte{3, 4, 5}); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+	if err := encoder.EndIndefinite(); err != nil {
+		t.Fatalf("EndIndefinite() returned error %v", err)
+	}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("Encoding mismatch: got %v, want %v", w.Bytes(), want)
+	}
+}
+
+func TestIndefiniteByteStringEncOptions(t *testing.T) {
+	type myBytes []byte
+	typ := reflect.TypeOf(myBytes(nil))
+
+	tags := NewTagSet()
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagRequired}, typ, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		opts EncOptions
+		tags TagSet
+	}{
+		{name: "ByteSliceToArray", opts: EncOptions{ByteSlice: ByteSliceToArray}},
+		{name: "ByteSliceLaterFormat", opts: EncOptions{ByteSliceLaterFormat: ByteSliceLaterFormatBase16}},
+		{name: "registered tag", tags: tags},
+	}
+	// Chunks are always encoded as definite-length byte strings.
+	want := hexDecode("5f42010243030405ff")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.tags == nil {
+				tc.tags = NewTagSet()
+			}
+			em, err := tc.opts.EncModeWithTags(tc.tags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var w bytes.Buffer
+			encoder := em.NewEncoder(&w)
+			if err := encoder.StartIndefiniteByteString(); err != nil {
+				t.Fatalf("StartIndefiniteByteString() returned error %v", err)
+			}
+			if err := encoder.Encode(myBytes{1, 2}); err != nil {
+				t.Fatalf("Encode() returned error %v", err)
+			}
+			if err := encoder.Encode([3]byte{3, 4, 5}); err != nil {
+				t.Fatalf("Encode() returned error %v", err)
+			}
+			if err := encoder.EndIndefinite(); err != nil {
+				t.Fatalf("EndIndefinite() returned error %v", err)
+			}
+			if !bytes.Equal(w.Bytes(), want) {
+				t.Errorf("Encoding mismatch: got 0x%x, want 0x%x", w.Bytes(), want)
+			}
+			if err := Wellformed(w.Bytes()); err != nil {
+				t.Errorf("Wellformed(0x%x) returned error %v", w.Bytes(), err)
+			}
+		})
+	}
+}
+
+func TestIndefiniteByteStringError(t *testing.T) {
+	var w bytes.Buffer
+	encoder := NewEncoder(&w)
 	if err := encoder.StartIndefiniteByteString(); err != nil {
 		t.Fatalf("StartIndefiniteByteString() returned error %v", err)
 	}
@@ -775,6 +1675,74 @@ func TestIndefiniteTextString(t *testing.T) {
 	}
 }
 
+func TestIndefiniteTextStringEncOptions(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts EncOptions
+	}{
+		{name: "StringChunkSize", opts: EncOptions{StringChunkSize: 2}},
+		{name: "StringToByteString", opts: EncOptions{String: StringToByteString}},
+	}
+	// Chunks are always encoded as definite-length text strings.
+	want := hexDecode("7f657374726561646d696e67ff")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			em, err := tc.opts.EncMode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var w bytes.Buffer
+			encoder := em.NewEncoder(&w)
+			if err := encoder.StartIndefiniteTextString(); err != nil {
+				t.Fatalf("StartIndefiniteTextString() returned error %v", err)
+			}
+			if err := encoder.Encode("strea"); err != nil {
+				t.Fatalf("Encode() returned error %v", err)
+			}
+			if err := encoder.Encode("ming"); err != nil {
+				t.Fatalf("Encode() returned error %v", err)
+			}
+			if err := encoder.EndIndefinite(); err != nil {
+				t.Fatalf("EndIndefinite() returned error %v", err)
+			}
+			if !bytes.Equal(w.Bytes(), want) {
+				t.Errorf("Encoding mismatch: got 0x%x, want 0x%x", w.Bytes(), want)
+			}
+			if err := Wellformed(w.Bytes()); err != nil {
+				t.Errorf("Wellformed(0x%x) returned error %v", w.Bytes(), err)
+			}
+		})
+	}
+}
+
+func TestIndefiniteByteStringStringChunkSize(t *testing.T) {
+	em, err := EncOptions{StringChunkSize: 2}.EncMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := hexDecode("5f4301020343040506ff")
+	var w bytes.Buffer
+	encoder := em.NewEncoder(&w)
+	if err := encoder.StartIndefiniteByteString(); err != nil {
+		t.Fatalf("StartIndefiniteByteString() returned error %v", err)
+	}
+	if err := encoder.Encode([]byte{1, 2, 3}); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+	if err := encoder.Encode([]byte{4, 5, 6}); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+	if err := encoder.EndIndefinite(); err != nil {
+		t.Fatalf("EndIndefinite() returned error %v", err)
+	}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("Encoding mismatch: got 0x%x, want 0x%x", w.Bytes(), want)
+	}
+	if err := Wellformed(w.Bytes()); err != nil {
+		t.Errorf("Wellformed(0x%x) returned error %v", w.Bytes(), err)
+	}
+}
+
 func TestIndefiniteTextStringError(t *testing.T) {
 	var w bytes.Buffer
 	encoder := NewEncoder(&w)
@@ -1079,3 +2047,499 @@ func (r *recoverableReader) Read(b []byte) (int, error) {
 	}
 	return r.nBytesReader.Read(b)
 }
+
+func TestDecoderExceedMaxByteStringBytes(t *testing.T) {
+	// Byte string header declares 2147483647 bytes, which exceeds MaxByteStringBytes.
+	// Decoder should return error without reading and buffering string content.
+	r := &countingReader{r: io.MultiReader(bytes.NewReader(hexDecode("5a7fffffff")), zeroReader{})}
+
+	dm, err := DecOptions{MaxByteStringBytes: 1024}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+	dec := dm.NewDecoder(r)
+
+	var v []byte
+	if err := dec.Decode(&v); err == nil {
+		t.Errorf("Decode() didn't return an error")
+	} else if _, ok := err.(*MaxStringBytesError); !ok {
+		t.Errorf("Decode() returned wrong error type %T, want (*MaxStringBytesError)", err)
+	}
+	if r.n > 1024 {
+		t.Errorf("Decode() read %d bytes, want <= 1024", r.n)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestDecoderErrorLocation(t *testing.T) {
+	// [], [1, "x"], [], [1, break]
+	data := hexDecode("8082016178808201ff")
+	dec := NewDecoder(bytes.NewReader(data))
+
+	var v []int
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("Decode() returned error %v", err)
+	}
+
+	err := dec.Decode(&v)
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("Decode() returned error %v (%T), want *UnmarshalTypeError", err, err)
+	}
+	if typeErr.Offset != 3 || typeErr.Path != "/1" {
+		t.Errorf("Decode() returned error location (%d, %q), want (3, %q)", typeErr.Offset, typeErr.Path, "/1")
+	}
+
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("Decode() returned error %v", err)
+	}
+
+	err = dec.Decode(&v)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("Decode() returned error %v (%T), want *SyntaxError", err, err)
+	}
+	if syntaxErr.Offset != 8 || syntaxErr.Path != "/1" {
+		t.Errorf("Decode() returned error location (%d, %q), want (8, %q)", syntaxErr.Offset, syntaxErr.Path, "/1")
+	}
+}
+
+func uint32FrameLength(r io.Reader) (int, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+func uvarintFrameLength(r io.Reader) (int, error) {
+	n, err := binary.ReadUvarint(r.(io.ByteReader))
+	if err != nil {
+		return 0, err
+	}
+	if n > 1024 {
+		return 0, errors.New("frame too large")
+	}
+	return int(n), nil
+}
+
+func TestDecoderFraming(t *testing.T) {
+	// Frames with 4-byte big-endian length prefix: [1, 2], "a", empty frame.
+	data := hexDecode("0000000382010200000002616100000000")
+	dec := NewDecoder(bytes.NewReader(data))
+	dec.SetFraming(uint32FrameLength)
+
+	var v []int
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("Decode() returned error %v", err)
+	}
+	if !reflect.DeepEqual(v, []int{1, 2}) {
+		t.Errorf("Decode() = %v, want [1 2]", v)
+	}
+	if n := dec.BytesConsumed(); n != 7 {
+		t.Errorf("BytesConsumed() = %d, want 7", n)
+	}
+
+	if err := dec.Skip(); err != nil {
+		t.Fatalf("Skip() returned error %v", err)
+	}
+	if n := dec.BytesConsumed(); n != 6 {
+		t.Errorf("BytesConsumed() = %d, want 6", n)
+	}
+
+	if err := dec.Decode(&v); err != io.ErrUnexpectedEOF {
+		t.Errorf("Decode() returned error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if n := dec.BytesConsumed(); n != 4 {
+		t.Errorf("BytesConsumed() = %d, want 4", n)
+	}
+
+	if err := dec.Decode(&v); err != io.EOF {
+		t.Errorf("Decode() returned error %v, want %v", err, io.EOF)
+	}
+	if n := dec.BytesConsumed(); n != 0 {
+		t.Errorf("BytesConsumed() = %d, want 0", n)
+	}
+	if n := dec.NumBytesRead(); n != len(data) {
+		t.Errorf("NumBytesRead() = %d, want %d", n, len(data))
+	}
+}
+
+func TestDecoderBytesConsumedAfterReset(t *testing.T) {
+	dec := NewDecoder(bytes.NewReader(hexDecode("820102"))) // [1, 2]
+
+	var v []int
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("Decode() returned error %v", err)
+	}
+	if n := dec.BytesConsumed(); n != 3 {
+		t.Errorf("BytesConsumed() = %d, want 3", n)
+	}
+
+	dec.Reset(bytes.NewReader(hexDecode("01")))
+	if n := dec.BytesConsumed(); n != 0 {
+		t.Errorf("BytesConsumed() after Reset() = %d, want 0", n)
+	}
+}
+
+func TestDecoderFramingError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+		wantConsumed int
+	}{
+		{
+			name:         "trailing data",
+			data:         hexDecode("030102030101"),
+			wantErrorMsg: "cbor: 2 bytes of extraneous data starting at index 1",
+			wantConsumed: 4,
+		},
+		{
+			name:         "incomplete data item",
+			data:         hexDecode("0282010101"),
+			wantErrorMsg: io.ErrUnexpectedEOF.Error(),
+			wantConsumed: 3,
+		},
+		{
+			name:         "malformed data item",
+			data:         hexDecode("011c0101"),
+			wantErrorMsg: "cbor: invalid additional information 28 for type positive integer",
+			wantConsumed: 2,
+		},
+		{
+			name:         "type mismatch",
+			data:         hexDecode("0261610101"),
+			wantErrorMsg: "cbor: cannot unmarshal UTF-8 text string into Go value of type int",
+			wantConsumed: 3,
+		},
+		{
+			name:         "incomplete frame",
+			data:         hexDecode("0382"),
+			wantErrorMsg: io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			name:         "incomplete length prefix",
+			data:         hexDecode("80"),
+			wantErrorMsg: io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			name:         "invalid frame length",
+			data:         hexDecode("ff7f"),
+			wantErrorMsg: "frame too large",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := NewDecoder(bytes.NewReader(tc.data))
+			dec.SetFraming(uvarintFrameLength)
+
+			var v int
+			err := dec.Decode(&v)
+			if err == nil {
+				t.Fatalf("Decode() didn't return an error")
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("Decode() returned error %q, want %q", err.Error(), tc.wantErrorMsg)
+			}
+			if n := dec.BytesConsumed(); n != tc.wantConsumed {
+				t.Errorf("BytesConsumed() = %d, want %d", n, tc.wantConsumed)
+			}
+
+			// Decoding continues with the next frame after a consumed frame.
+			if tc.wantConsumed > 0 {
+				if err := dec.Decode(&v); err != nil {
+					t.Fatalf("Decode() returned error %v", err)
+				}
+				if v != 1 {
+					t.Errorf("Decode() = %d, want 1", v)
+				}
+			}
+		})
+	}
+}
+
+func TestDecoderFramingNegativeLength(t *testing.T) {
+	dec := NewDecoder(bytes.NewReader(hexDecode("00")))
+	dec.SetFraming(func(r io.Reader) (int, error) { return -1, nil })
+
+	wantErrorMsg := "cbor: invalid frame length -1"
+	var v interface{}
+	if err := dec.Decode(&v); err == nil {
+		t.Errorf("Decode() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("Decode() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+
+	wantErrorMsg = "cbor: DecodeTextChunks isn't supported by Decoder with framing"
+	if err := dec.DecodeTextChunks(func([]byte) error { return nil }); err == nil {
+		t.Errorf("DecodeTextChunks() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("DecodeTextChunks() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+}
+
+func TestDecoderBytesConsumed(t *testing.T) {
+	dec := NewDecoder(bytes.NewReader(hexDecode("0182010218ff")))
+	var v interface{}
+	for _, want := range []int{1, 3, 2} {
+		if err := dec.Decode(&v); err != nil {
+			t.Fatalf("Decode() returned error %v", err)
+		}
+		if n := dec.BytesConsumed(); n != want {
+			t.Errorf("BytesConsumed() = %d, want %d", n, want)
+		}
+	}
+}
+
+func TestDecoderBytesConsumedDecodeTextChunks(t *testing.T) {
+	// "abc", (_ "ab", "c"), 1
+	dec := NewDecoder(bytes.NewReader(hexDecode("636162637f6261626163ff01")))
+	fn := func([]byte) error { return nil }
+	for _, want := range []int{4, 7} {
+		if err := dec.DecodeTextChunks(fn); err != nil {
+			t.Fatalf("DecodeTextChunks() returned error %v", err)
+		}
+		if n := dec.BytesConsumed(); n != want {
+			t.Errorf("BytesConsumed() = %d, want %d", n, want)
+		}
+	}
+
+	// Data item that isn't a text string is skipped.
+	if err := dec.DecodeTextChunks(fn); err == nil {
+		t.Errorf("DecodeTextChunks() didn't return an error")
+	} else if _, ok := err.(*UnmarshalTypeError); !ok {
+		t.Errorf("DecodeTextChunks() returned wrong error type %T, want (*UnmarshalTypeError)", err)
+	}
+	if n := dec.BytesConsumed(); n != 1 {
+		t.Errorf("BytesConsumed() = %d, want 1", n)
+	}
+
+	if err := dec.DecodeTextChunks(fn); err != io.EOF {
+		t.Errorf("DecodeTextChunks() returned error %v, want %v", err, io.EOF)
+	}
+	if n := dec.BytesConsumed(); n != 0 {
+		t.Errorf("BytesConsumed() = %d, want 0", n)
+	}
+}
+
+// countingWriter records the number of Write calls.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestEncoderSetBufferSize(t *testing.T) {
+	var w countingWriter
+	encoder := NewEncoder(&w)
+	encoder.SetBufferSize(16)
+
+	for i := 0; i < 10; i++ {
+		if err := encoder.Encode(i); err != nil {
+			t.Fatalf("Encode() returned error %v", err)
+		}
+	}
+	if w.writes != 0 {
+		t.Errorf("Encode() wrote %d times before buffer is full, want 0", w.writes)
+	}
+	if n := encoder.Buffered(); n != 10 {
+		t.Errorf("Buffered() = %d, want 10", n)
+	}
+
+	// Data larger than buffer size is written after buffered data.
+	if err := encoder.Encode(strings.Repeat("a", 20)); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+	if w.writes != 2 {
+		t.Errorf("Encode() wrote %d times, want 2", w.writes)
+	}
+	if n := encoder.Buffered(); n != 0 {
+		t.Errorf("Buffered() = %d, want 0", n)
+	}
+
+	// Data is buffered after writing full buffer.
+	for i := 0; i < 20; i++ {
+		if err := encoder.Encode(i); err != nil {
+			t.Fatalf("Encode() returned error %v", err)
+		}
+	}
+	if w.writes != 3 {
+		t.Errorf("Encode() wrote %d times, want 3", w.writes)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatalf("Flush() returned error %v", err)
+	}
+	if w.writes != 4 {
+		t.Errorf("Flush() wrote %d times, want 4", w.writes)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatalf("Flush() returned error %v", err)
+	}
+	if w.writes != 4 {
+		t.Errorf("Flush() with empty buffer wrote %d times, want 4", w.writes)
+	}
+
+	var want bytes.Buffer
+	unbuffered := NewEncoder(&want)
+	for i := 0; i < 10; i++ {
+		unbuffered.Encode(i) //nolint:errcheck
+	}
+	unbuffered.Encode(strings.Repeat("a", 20)) //nolint:errcheck
+	for i := 0; i < 20; i++ {
+		unbuffered.Encode(i) //nolint:errcheck
+	}
+	if !bytes.Equal(w.Bytes(), want.Bytes()) {
+		t.Errorf("Encoding mismatch: got 0x%x, want 0x%x", w.Bytes(), want.Bytes())
+	}
+
+	// Disabling buffering writes each data item.
+	encoder.SetBufferSize(0)
+	for i := 0; i < 3; i++ {
+		if err := encoder.Encode(i); err != nil {
+			t.Fatalf("Encode() returned error %v", err)
+		}
+	}
+	if w.writes != 7 {
+		t.Errorf("Encode() wrote %d times, want 7", w.writes)
+	}
+}
+
+func TestEncoderSetBufferSizeIndefiniteLength(t *testing.T) {
+	var w countingWriter
+	encoder := NewEncoder(&w)
+	encoder.SetBufferSize(64)
+
+	if err := encoder.StartIndefiniteArray(); err != nil {
+		t.Fatalf("StartIndefiniteArray() returned error %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := encoder.Encode(i); err != nil {
+			t.Fatalf("Encode() returned error %v", err)
+		}
+	}
+	if err := encoder.EndIndefinite(); err != nil {
+		t.Fatalf("EndIndefinite() returned error %v", err)
+	}
+	if w.Len() != 0 {
+		t.Errorf("Encoder's writer has %d bytes of data before Flush, want 0", w.Len())
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatalf("Flush() returned error %v", err)
+	}
+	if w.writes != 1 {
+		t.Errorf("Flush() wrote %d times, want 1", w.writes)
+	}
+	want := hexDecode("9f000102ff")
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("Encoding mismatch: got 0x%x, want 0x%x", w.Bytes(), want)
+	}
+
+	// Buffered head is written before content copied by EncodeReader.
+	w.Reset()
+	if err := encoder.StartIndefiniteByteString(); err != nil {
+		t.Fatalf("StartIndefiniteByteString() returned error %v", err)
+	}
+	if err := encoder.EncodeReader(bytes.NewReader([]byte{1, 2}), 2); err != nil {
+		t.Fatalf("EncodeReader() returned error %v", err)
+	}
+	if err := encoder.EndIndefinite(); err != nil {
+		t.Fatalf("EndIndefinite() returned error %v", err)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatalf("Flush() returned error %v", err)
+	}
+	want = hexDecode("5f420102ff")
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("Encoding mismatch: got 0x%x, want 0x%x", w.Bytes(), want)
+	}
+}
+
+func TestEncoderSetBufferSizeReset(t *testing.T) {
+	var w1, w2 bytes.Buffer
+	encoder := NewEncoder(&w1)
+	encoder.SetBufferSize(16)
+
+	if err := encoder.Encode(1); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+	encoder.Reset(&w2)
+	if n := encoder.Buffered(); n != 0 {
+		t.Errorf("Buffered() after Reset() = %d, want 0", n)
+	}
+	if err := encoder.Encode(2); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+	if w2.Len() != 0 {
+		t.Errorf("Encoder's writer has %d bytes of data before Flush, want 0", w2.Len())
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatalf("Flush() returned error %v", err)
+	}
+	if w1.Len() != 0 {
+		t.Errorf("Encoder's old writer has %d bytes of data, want 0", w1.Len())
+	}
+	if want := hexDecode("02"); !bytes.Equal(w2.Bytes(), want) {
+		t.Errorf("Encoding mismatch: got 0x%x, want 0x%x", w2.Bytes(), want)
+	}
+}
+
+// shortWriter writes at most n bytes and returns error if p is longer.
+type shortWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n, _ := w.Buffer.Write(p[:w.n])
+		return n, errors.New("short write")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestEncoderFlushError(t *testing.T) {
+	w := &shortWriter{n: 1}
+	encoder := NewEncoder(w)
+	encoder.SetBufferSize(16)
+
+	if err := encoder.Encode([]int{1, 2}); err != nil {
+		t.Fatalf("Encode() returned error %v", err)
+	}
+	if err := encoder.Flush(); err == nil {
+		t.Errorf("Flush() didn't return an error")
+	}
+	if n := encoder.Buffered(); n != 2 {
+		t.Errorf("Buffered() after failed Flush() = %d, want 2", n)
+	}
+
+	w.n = 16
+	if err := encoder.Flush(); err != nil {
+		t.Fatalf("Flush() returned error %v", err)
+	}
+	if want := hexDecode("820102"); !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("Encoding mismatch: got 0x%x, want 0x%x", w.Bytes(), want)
+	}
+}
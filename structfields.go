@@ -4,6 +4,7 @@
 package cbor
 
 import (
+	"errors"
 	"reflect"
 	"sort"
 	"strings"
@@ -11,7 +12,7 @@ import (
 
 type field struct {
 	name               string
-	nameAsInt          int64 // used to decoder to match field name with CBOR int
+	nameAsInt          Integer // used to decoder to match field name with CBOR int
 	cborName           []byte
 	cborNameByteString []byte // major type 2 name encoding iff cborName has major type 3
 	idx                []int
@@ -21,7 +22,16 @@ type field struct {
 	typInfo            *typeInfo // used to decoder to reuse type info
 	tagged             bool      // used to choose dominant field (at the same level tagged fields dominate untagged fields)
 	omitEmpty          bool      // used to skip empty field
+	omitZero           bool      // used to skip zero field
 	keyAsInt           bool      // used to encode/decode field name as int
+	raw                bool      // used to encode/decode field value as raw CBOR data item
+	copy               bool      // used to always copy decoded bytes (even with ZeroCopyBytes)
+	unknown            bool      // used to capture unknown map entries when decoding and encode them
+	orig               bool      // used to capture entire encoded CBOR map or array when decoding
+	array              bool      // used to encode byte slice or byte array as CBOR array of integers
+	inline             bool      // used to merge map entries into the parent map when encoding and collect them when decoding
+	nilAsEmpty         bool      // used to encode nil slice or map as empty container regardless of NilContainers
+	nilAsNull          bool      // used to encode nil slice or map as CBOR null regardless of NilContainers
 }
 
 type fields []*field
@@ -177,7 +187,7 @@ func appendFields(
 
 		// Parse field tag options
 		var tagFieldName string
-		var omitempty, keyasint bool
+		var omitempty, omitzero, keyasint, raw, copyBytes, unknown, orig, array, inline, nilasempty, nilasnull bool
 		for j := 0; tag != ""; j++ {
 			var token string
 			idx := strings.IndexByte(tag, ',')
@@ -192,8 +202,26 @@ func appendFields(
 				switch token {
 				case "omitempty":
 					omitempty = true
+				case "omitzero":
+					omitzero = true
 				case "keyasint":
 					keyasint = true
+				case "raw":
+					raw = true
+				case "copy":
+					copyBytes = true
+				case "unknown":
+					unknown = true
+				case "orig":
+					orig = true
+				case "array":
+					array = true
+				case "inline":
+					inline = true
+				case "nilasempty":
+					nilasempty = true
+				case "nilasnull":
+					nilasnull = true
 				}
 			}
 		}
@@ -207,14 +235,25 @@ func appendFields(
 		copy(fIdx, idx)
 		fIdx[len(fIdx)-1] = i
 
-		if !f.Anonymous || ft.Kind() != reflect.Struct || tagFieldName != "" {
+		// Anonymous struct fields without name and struct fields with "inline" option
+		// are flattened.
+		if ft.Kind() != reflect.Struct || (!inline && (!f.Anonymous || tagFieldName != "")) {
 			flds = append(flds, &field{
-				name:      fieldName,
-				idx:       fIdx,
-				typ:       f.Type,
-				omitEmpty: omitempty,
-				keyAsInt:  keyasint,
-				tagged:    tagged})
+				name:       fieldName,
+				idx:        fIdx,
+				typ:        f.Type,
+				omitEmpty:  omitempty,
+				omitZero:   omitzero,
+				keyAsInt:   keyasint,
+				raw:        raw,
+				copy:       copyBytes,
+				unknown:    unknown,
+				orig:       orig,
+				array:      array,
+				inline:     inline,
+				nilAsEmpty: nilasempty,
+				nilAsNull:  nilasnull,
+				tagged:     tagged})
 		} else {
 			if nTypes == nil {
 				nTypes = make(map[reflect.Type][][]int)
@@ -226,6 +265,106 @@ func appendFields(
 	return flds, nTypes
 }
 
+// validFieldOptions returns an error if struct field f of struct type t has "raw" option
+// but isn't a byte slice, or has "array" option but isn't a byte slice or byte array,
+// or has "nilasempty" or "nilasnull" option but isn't a slice or map.
+func validFieldOptions(t reflect.Type, f *field) error {
+	k := f.typ.Kind()
+	if f.nilAsEmpty && f.nilAsNull {
+		return errors.New("cbor: field " + t.String() + "." + f.name + " can't have both \"nilasempty\" and \"nilasnull\" options")
+	}
+	if (f.nilAsEmpty || f.nilAsNull) && k != reflect.Slice && k != reflect.Map {
+		return errors.New("cbor: field " + t.String() + "." + f.name + " with \"" + f.nilContainerOption() + "\" option must be a slice or map, got " + f.typ.String())
+	}
+	if f.raw && (k != reflect.Slice || f.typ.Elem().Kind() != reflect.Uint8) {
+		return errors.New("cbor: field " + t.String() + "." + f.name + " with \"raw\" option must be a byte slice, got " + f.typ.String())
+	}
+	if f.array && ((k != reflect.Slice && k != reflect.Array) || f.typ.Elem().Kind() != reflect.Uint8) {
+		return errors.New("cbor: field " + t.String() + "." + f.name + " with \"array\" option must be a byte slice or byte array, got " + f.typ.String())
+	}
+	return nil
+}
+
+// splitUnknownField returns fields of struct type t without the field with "unknown"
+// or "inline" option, and that field (nil if absent).  It returns an error if t has more
+// than one such field, or if the field type isn't a map with string or interface{} keys
+// (and RawMessage values for "unknown" option).
+func splitUnknownField(t reflect.Type, flds fields) (fields, *field, error) {
+	unknownIdx := -1
+	for i, f := range flds {
+		if !f.unknown && !f.inline {
+			continue
+		}
+		if unknownIdx != -1 {
+			return flds, nil, errors.New("cbor: struct " + t.String() + " has more than one field with \"" + f.unknownOption() + "\" option")
+		}
+		validKey := f.typ.Kind() == reflect.Map && (f.typ.Key().Kind() == reflect.String || f.typ.Key() == typeIntf)
+		if f.unknown && (!validKey || f.typ.Elem() != typeRawMessage) {
+			return flds, nil, errors.New("cbor: field " + t.String() + "." + f.name +
+				" with \"unknown\" option must be map[string]cbor.RawMessage or map[interface{}]cbor.RawMessage, got " + f.typ.String())
+		}
+		if !validKey {
+			return flds, nil, errors.New("cbor: field " + t.String() + "." + f.name +
+				" with \"inline\" option must be a struct or a map with string or interface{} keys, got " + f.typ.String())
+		}
+		unknownIdx = i
+	}
+	if unknownIdx == -1 {
+		return flds, nil, nil
+	}
+
+	unknownField := flds[unknownIdx]
+	knownFlds := make(fields, 0, len(flds)-1)
+	knownFlds = append(knownFlds, flds[:unknownIdx]...)
+	knownFlds = append(knownFlds, flds[unknownIdx+1:]...)
+	return knownFlds, unknownField, nil
+}
+
+// unknownOption returns the option name of field f returned by splitUnknownField.
+func (f *field) unknownOption() string {
+	if f.unknown {
+		return "unknown"
+	}
+	return "inline"
+}
+
+// nilContainerOption returns name of "nilasempty" or "nilasnull" option of f.
+func (f *field) nilContainerOption() string {
+	if f.nilAsEmpty {
+		return "nilasempty"
+	}
+	return "nilasnull"
+}
+
+// splitOrigField returns fields of struct type t without the field with "orig" option,
+// and the field with "orig" option (nil if absent).  It returns an error if there is
+// more than one such field or if its type isn't RawMessage.
+func splitOrigField(t reflect.Type, flds fields) (fields, *field, error) {
+	origIdx := -1
+	for i, f := range flds {
+		if !f.orig {
+			continue
+		}
+		if origIdx != -1 {
+			return flds, nil, errors.New("cbor: struct " + t.String() + " has more than one field with \"orig\" option")
+		}
+		if f.typ != typeRawMessage {
+			return flds, nil, errors.New("cbor: field " + t.String() + "." + f.name +
+				" with \"orig\" option must be cbor.RawMessage, got " + f.typ.String())
+		}
+		origIdx = i
+	}
+	if origIdx == -1 {
+		return flds, nil, nil
+	}
+
+	origField := flds[origIdx]
+	otherFlds := make(fields, 0, len(flds)-1)
+	otherFlds = append(otherFlds, flds[:origIdx]...)
+	otherFlds = append(otherFlds, flds[origIdx+1:]...)
+	return otherFlds, origField, nil
+}
+
 // isFieldExportable returns true if f is an exportable (regular or anonymous) field or
 // a nonexportable anonymous field of struct type.
 // Nonexportable anonymous field of struct type can contain exportable fields.
@@ -75,6 +75,119 @@ func (t RawTag) MarshalCBOR() ([]byte, error) {
 	return buf, nil
 }
 
+// ToInterface returns tag content with CBOR tags removed recursively.  Tags enclosed in
+// arrays and maps (including map keys) are replaced by their content.  An error is returned
+// if removing tags from map keys produces duplicate map keys.
+func (t Tag) ToInterface() (interface{}, error) {
+	return removeTags(t.Content)
+}
+
+// ToInterface decodes raw tag content to an empty interface value using default decoding
+// options, and returns it with CBOR tags removed recursively as Tag.ToInterface does.
+func (t RawTag) ToInterface() (interface{}, error) {
+	if len(t.Content) == 0 {
+		return nil, nil
+	}
+	var v interface{}
+	if err := Unmarshal(t.Content, &v); err != nil {
+		return nil, err
+	}
+	return removeTags(v)
+}
+
+func removeTags(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case Tag:
+		return removeTags(v.Content)
+
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			var err error
+			if a[i], err = removeTags(e); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			newKey, err := removeTags(k)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := m[newKey]; ok {
+				return nil, fmt.Errorf("cbor: found duplicate map key %v after removing tags", newKey)
+			}
+			if m[newKey], err = removeTags(e); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			var err error
+			if m[k], err = removeTags(e); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return v, nil
+}
+
+// TagPolicy returns tag number to enclose v and true, or false if v shouldn't be enclosed in a tag.
+type TagPolicy func(v interface{}) (num uint64, ok bool)
+
+// FromInterface returns a copy of v with values enclosed in CBOR tags (as Tag) based on policy.
+// It is the reverse of Tag.ToInterface.  Policy is called for every value in v, including
+// elements of []interface{}, and keys and values of map[interface{}]interface{} and
+// map[string]interface{}.  Policy receives values without tags, and tags are added after
+// nested values are processed.  If policy is nil, v is returned unchanged.
+func FromInterface(v interface{}, policy TagPolicy) interface{} {
+	if policy == nil {
+		return v
+	}
+	return addTags(v, policy)
+}
+
+func addTags(v interface{}, policy TagPolicy) interface{} {
+	var newValue interface{}
+	switch v := v.(type) {
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			a[i] = addTags(e, policy)
+		}
+		newValue = a
+
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			m[addTags(k, policy)] = addTags(e, policy)
+		}
+		newValue = m
+
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = addTags(e, policy)
+		}
+		newValue = m
+
+	default:
+		newValue = v
+	}
+
+	if num, ok := policy(v); ok {
+		return Tag{Number: num, Content: newValue}
+	}
+	return newValue
+}
+
 // DecTagMode specifies how decoder handles tag number.
 type DecTagMode int
 
@@ -116,6 +229,12 @@ func (etm EncTagMode) valid() bool {
 type TagOptions struct {
 	DecTag DecTagMode
 	EncTag EncTagMode
+
+	// ValidateContent, if set, is called with the decoded value after CBOR data is
+	// decoded to the registered content type, so decoders can enforce constraints
+	// on tag content beyond matching tag numbers.  Decoding fails with TagContentError
+	// if ValidateContent returns an error.  It isn't called if DecTag is DecTagIgnored.
+	ValidateContent func(v interface{}) error
 }
 
 // TagSet is an interface to add and remove tag info.  It is used by EncMode and DecMode
@@ -297,3 +416,19 @@ type WrongTagError struct {
 func (e *WrongTagError) Error() string {
 	return fmt.Sprintf("cbor: wrong tag number for %s, got %v, expected %v", e.RegisteredType.String(), e.TagNum, e.RegisteredTagNum)
 }
+
+// TagContentError describes decoded tag content rejected by TagOptions.ValidateContent
+// of registered tag.
+type TagContentError struct {
+	RegisteredType   reflect.Type
+	RegisteredTagNum []uint64
+	err              error
+}
+
+func (e *TagContentError) Error() string {
+	return fmt.Sprintf("cbor: invalid tag content for %s (tag number %v): %v", e.RegisteredType.String(), e.RegisteredTagNum, e.err)
+}
+
+func (e *TagContentError) Unwrap() error {
+	return e.err
+}
@@ -2,6 +2,7 @@ package cbor
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -1536,3 +1537,199 @@ func TestEncodeBuiltinTag(t *testing.T) {
 		})
 	}
 }
+
+func TestTagToInterface(t *testing.T) {
+	testCases := []struct {
+		name string
+		tag  Tag
+		want interface{}
+	}{
+		{
+			name: "untagged content",
+			tag:  Tag{Number: 100, Content: "hello"},
+			want: "hello",
+		},
+		{
+			name: "nested tag",
+			tag:  Tag{Number: 100, Content: Tag{Number: 101, Content: uint64(1)}},
+			want: uint64(1),
+		},
+		{
+			name: "array",
+			tag:  Tag{Number: 100, Content: []interface{}{Tag{Number: 101, Content: uint64(1)}, "a"}},
+			want: []interface{}{uint64(1), "a"},
+		},
+		{
+			name: "map",
+			tag: Tag{Number: 100, Content: map[interface{}]interface{}{
+				Tag{Number: 101, Content: "a"}: []interface{}{Tag{Number: 102, Content: true}},
+				"b":                            map[string]interface{}{"c": Tag{Number: 103, Content: nil}},
+			}},
+			want: map[interface{}]interface{}{
+				"a": []interface{}{true},
+				"b": map[string]interface{}{"c": nil},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.tag.ToInterface()
+			if err != nil {
+				t.Fatalf("ToInterface() returned error %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ToInterface() = %v (%T), want %v (%T)", got, got, tc.want, tc.want)
+			}
+		})
+	}
+}
+
+func TestTagToInterfaceDuplicateMapKeyError(t *testing.T) {
+	tag := Tag{Number: 100, Content: map[interface{}]interface{}{
+		Tag{Number: 101, Content: "a"}: uint64(1),
+		"a":                            uint64(2),
+	}}
+	wantErrorMsg := "cbor: found duplicate map key a after removing tags"
+	if _, err := tag.ToInterface(); err == nil {
+		t.Errorf("ToInterface() didn't return an error")
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("ToInterface() returned error %q, want %q", err.Error(), wantErrorMsg)
+	}
+}
+
+func TestRawTagToInterface(t *testing.T) {
+	var rt RawTag
+	if got, err := rt.ToInterface(); err != nil || got != nil {
+		t.Errorf("ToInterface() = %v, %v, want nil, nil", got, err)
+	}
+
+	// 100([101("a"), {102(1): 103(h'01')}])
+	data := hexDecode("d86482d8656161a1d86601d8674101")
+	if err := Unmarshal(data, &rt); err != nil {
+		t.Fatalf("Unmarshal(0x%x) returned error %v", data, err)
+	}
+	got, err := rt.ToInterface()
+	if err != nil {
+		t.Fatalf("ToInterface() returned error %v", err)
+	}
+	want := []interface{}{"a", map[interface{}]interface{}{uint64(1): []byte{1}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToInterface() = %v, want %v", got, want)
+	}
+
+	rt = RawTag{Number: 100, Content: hexDecode("ff")}
+	if _, err := rt.ToInterface(); err == nil {
+		t.Errorf("ToInterface() didn't return an error")
+	}
+}
+
+func TestFromInterface(t *testing.T) {
+	v := map[interface{}]interface{}{
+		"a": []interface{}{uint64(1), "b"},
+		"c": map[string]interface{}{"d": "e"},
+	}
+
+	if got := FromInterface(v, nil); !reflect.DeepEqual(got, v) {
+		t.Errorf("FromInterface() = %v, want %v", got, v)
+	}
+
+	// Enclose strings in tag 100 and arrays in tag 101.
+	policy := func(v interface{}) (uint64, bool) {
+		switch v.(type) {
+		case string:
+			return 100, true
+		case []interface{}:
+			return 101, true
+		}
+		return 0, false
+	}
+	want := map[interface{}]interface{}{
+		Tag{Number: 100, Content: "a"}: Tag{Number: 101, Content: []interface{}{uint64(1), Tag{Number: 100, Content: "b"}}},
+		Tag{Number: 100, Content: "c"}: map[string]interface{}{"d": Tag{Number: 100, Content: "e"}},
+	}
+	got := FromInterface(v, policy)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromInterface() = %v, want %v", got, want)
+	}
+
+	// Round trip
+	stripped, err := Tag{Number: 0, Content: got}.ToInterface()
+	if err != nil {
+		t.Fatalf("ToInterface() returned error %v", err)
+	}
+	if !reflect.DeepEqual(stripped, v) {
+		t.Errorf("ToInterface() = %v, want %v", stripped, v)
+	}
+}
+
+func TestDecodeTagValidateContent(t *testing.T) {
+	type uri string
+	type s struct {
+		A uri  `cbor:"a"`
+		B *uri `cbor:"b"`
+	}
+
+	errNoScheme := errors.New("missing scheme")
+	validateURI := func(v interface{}) error {
+		if !strings.Contains(string(v.(uri)), "://") {
+			return errNoScheme
+		}
+		return nil
+	}
+
+	tags := NewTagSet()
+	if err := tags.Add(TagOptions{EncTag: EncTagRequired, DecTag: DecTagOptional, ValidateContent: validateURI}, reflect.TypeOf(uri("")), 32); err != nil {
+		t.Fatalf("TagSet.Add() returned error %v", err)
+	}
+	dm, err := DecOptions{}.DecModeWithTags(tags)
+	if err != nil {
+		t.Fatalf("DecModeWithTags() returned error %v", err)
+	}
+	sharedDM, err := DecOptions{}.DecModeWithSharedTags(tags)
+	if err != nil {
+		t.Fatalf("DecModeWithSharedTags() returned error %v", err)
+	}
+
+	wantErrorMsg := "cbor: invalid tag content for cbor.uri (tag number [32]): missing scheme"
+
+	testCases := []struct {
+		name    string
+		data    []byte
+		v       interface{}
+		wantErr bool
+	}{
+		{"valid content", hexDecode("d8206f68747470733a2f2f6578616d706c65"), new(uri), false},
+		{"invalid content", hexDecode("d820676578616d706c65"), new(uri), true},
+		{"invalid content without tag", hexDecode("676578616d706c65"), new(uri), true},
+		{"invalid content in struct field", hexDecode("a16161d820676578616d706c65"), new(s), true},
+		{"invalid content in pointer struct field", hexDecode("a16162d820676578616d706c65"), new(s), true},
+		{"nil pointer struct field", hexDecode("a16162f6"), new(s), false},
+		{"invalid content in empty interface", hexDecode("d820676578616d706c65"), new(interface{}), true},
+		{"invalid content in slice", hexDecode("81d820676578616d706c65"), new([]uri), true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, dm := range []DecMode{dm, sharedDM} {
+				v := reflect.New(reflect.TypeOf(tc.v).Elem()).Interface()
+				err := dm.Unmarshal(tc.data, v)
+				if !tc.wantErr {
+					if err != nil {
+						t.Errorf("Unmarshal(0x%x) returned error %v", tc.data, err)
+					}
+					continue
+				}
+				var tcErr *TagContentError
+				if !errors.As(err, &tcErr) {
+					t.Errorf("Unmarshal(0x%x) returned error %v (%T), want *TagContentError", tc.data, err, err)
+					continue
+				}
+				if !strings.HasSuffix(err.Error(), wantErrorMsg) {
+					t.Errorf("Unmarshal(0x%x) returned error %q, want %q", tc.data, err.Error(), wantErrorMsg)
+				}
+				if !errors.Is(err, errNoScheme) {
+					t.Errorf("Unmarshal(0x%x) returned error %v, want error wrapping %v", tc.data, err, errNoScheme)
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@
 package cbor
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"io"
@@ -13,20 +14,193 @@ import (
 	"github.com/x448/float16"
 )
 
+// Errors that decoding errors can be compared with using errors.Is, instead of matching
+// error messages.  For example, errors.Is(err, ErrSyntax) reports whether err is a
+// SyntaxError.  Use errors.As to get details of the matched error.
+var (
+	// ErrSyntax matches SyntaxError for malformed CBOR data.
+	ErrSyntax = errors.New("cbor: syntax error")
+
+	// ErrSemantic matches SemanticError for well-formed but invalid CBOR data
+	// (e.g. text string with invalid UTF-8).
+	ErrSemantic = errors.New("cbor: semantic error")
+
+	// ErrUnexpectedEOF is io.ErrUnexpectedEOF, which is returned as is for truncated
+	// CBOR data.
+	ErrUnexpectedEOF = io.ErrUnexpectedEOF
+
+	// ErrMaxDepthExceeded matches MaxNestedLevelError.
+	ErrMaxDepthExceeded = errors.New("cbor: exceeded max nested level")
+
+	// ErrLimitExceeded matches errors for exceeded decoding limits: MaxNestedLevelError,
+	// MaxArrayElementsError, MaxMapPairsError, MaxStringBytesError, and MaxDecodedSizeError.
+	ErrLimitExceeded = errors.New("cbor: exceeded decoding limit")
+
+	// ErrDuplicateMapKey matches DupMapKeyError.
+	ErrDuplicateMapKey = errors.New("cbor: found duplicate map key")
+
+	// ErrUnknownField matches UnknownFieldError.
+	ErrUnknownField = errors.New("cbor: found unknown field")
+
+	// ErrTypeMismatch matches UnmarshalTypeError.
+	ErrTypeMismatch = errors.New("cbor: cannot unmarshal CBOR value into Go value")
+
+	// ErrExtraneousData matches ExtraneousDataError.
+	ErrExtraneousData = errors.New("cbor: found extraneous data")
+)
+
 // SyntaxError is a description of a CBOR syntax error.
+// See ErrorLocation for the location of the error in CBOR data.
 type SyntaxError struct {
 	msg string
+	ErrorLocation
 }
 
 func (e *SyntaxError) Error() string { return e.msg }
 
+// Is returns true if target is ErrSyntax.
+func (e *SyntaxError) Is(target error) bool { return target == ErrSyntax }
+
 // SemanticError is a description of a CBOR semantic error.
+// See ErrorLocation for the location of the error in CBOR data.
 type SemanticError struct {
 	msg string
+	ErrorLocation
 }
 
 func (e *SemanticError) Error() string { return e.msg }
 
+// Is returns true if target is ErrSemantic.
+func (e *SemanticError) Is(target error) bool { return target == ErrSemantic }
+
+// ErrorLocation is the location in CBOR data where a decoding error occurred.
+// It is embedded in UnmarshalTypeError, SemanticError, and SyntaxError, so the
+// location is available after using errors.As to get these errors.  Error messages
+// don't include the location.
+//
+// If the error is returned by Unmarshaler, the location is relative to the data
+// passed to UnmarshalCBOR.
+type ErrorLocation struct {
+	// Offset is the byte offset of the data item where the error occurred, relative
+	// to the beginning of the decoded data, or of the input stream for Decoder.
+	Offset int
+
+	// Path is the location of the data item where the error occurred from the root
+	// data item, as a sequence of array indexes and map keys in diagnostic notation,
+	// e.g. /2/"headers"/3.  Tags don't appear in Path.  Path is empty for the root
+	// data item, and for errors in map keys Path is the location of the map.
+	Path string
+
+	hasOffset bool
+	hasPath   bool
+}
+
+func (l *ErrorLocation) errorLocation() *ErrorLocation { return l }
+
+// locatedError is implemented by errors embedding ErrorLocation.
+type locatedError interface {
+	errorLocation() *ErrorLocation
+}
+
+// setErrorOffset sets offset of err to off, if err is a located error without offset.
+func setErrorOffset(err error, off int) {
+	if le, ok := err.(locatedError); ok {
+		if l := le.errorLocation(); !l.hasOffset {
+			l.Offset = off
+			l.hasOffset = true
+		}
+	}
+}
+
+// addErrorOffset adds n to offset of err, if err is a located error with offset.
+func addErrorOffset(err error, n int) {
+	if le, ok := err.(locatedError); ok {
+		if l := le.errorLocation(); l.hasOffset {
+			l.Offset += n
+		}
+	}
+}
+
+// setErrorPath sets path of err from offset of err, if err is a located error with
+// offset and without path.  start is the offset of the root data item in d.data.
+func (d *decoder) setErrorPath(err error, start int) {
+	le, ok := err.(locatedError)
+	if !ok {
+		return
+	}
+	l := le.errorLocation()
+	if !l.hasOffset || l.hasPath || l.Offset < start || l.Offset > len(d.data) {
+		return
+	}
+	l.Path = dataItemPath(d.dm, d.data[start:], l.Offset-start)
+	l.hasPath = true
+}
+
+// dataItemPath returns path of the innermost data item in data that starts at or
+// contains offset target.  Data items before target are expected to be well-formed.
+func dataItemPath(dm *decMode, data []byte, target int) string {
+	noBudget := *dm
+	noBudget.budget = nil
+	noBudget.maxDecodedSizeBytes = 0
+	d := decoder{data: data, dm: &noBudget}
+
+	var path []byte
+	depth := 0
+	for d.off < target {
+		t, _, val, indefiniteLength, err := d.wellformedHeadWithIndefiniteLengthFlag()
+		if err != nil {
+			break
+		}
+		if t == cborTypeTag {
+			depth++
+			continue
+		}
+		if t != cborTypeArray && t != cborTypeMap {
+			break
+		}
+		depth++
+
+		// Find element containing target.
+		found := false
+		keyOff, keyEnd := 0, 0
+		for i := uint64(0); ; i++ {
+			if indefiniteLength {
+				if d.off >= len(data) || isBreakFlag(data[d.off]) {
+					break
+				}
+			} else if (t == cborTypeArray && i >= val) || (t == cborTypeMap && i/2 >= val) {
+				break
+			}
+			off := d.off
+			if _, err := d.wellformedInternal(depth, false); err == nil && d.off <= target {
+				if t == cborTypeMap && i%2 == 0 {
+					keyOff, keyEnd = off, d.off
+				}
+				continue
+			}
+			if t == cborTypeMap && i%2 == 0 {
+				// Target is in map key.
+				break
+			}
+			d.off = off
+			found = true
+			path = append(path, '/')
+			if t == cborTypeArray {
+				path = strconv.AppendUint(path, i, 10)
+			} else if key, err := Diagnose(data[keyOff:keyEnd]); err == nil {
+				path = append(path, key...)
+			} else {
+				path = append(path, '?')
+			}
+			break
+		}
+		if !found {
+			break
+		}
+	}
+	return string(path)
+}
+
 // MaxNestedLevelError indicates exceeded max nested level of any combination of CBOR arrays/maps/tags.
 type MaxNestedLevelError struct {
 	maxNestedLevels int
@@ -36,6 +210,11 @@ func (e *MaxNestedLevelError) Error() string {
 	return "cbor: exceeded max nested level " + strconv.Itoa(e.maxNestedLevels)
 }
 
+// Is returns true if target is ErrMaxDepthExceeded or ErrLimitExceeded.
+func (e *MaxNestedLevelError) Is(target error) bool {
+	return target == ErrMaxDepthExceeded || target == ErrLimitExceeded
+}
+
 // MaxArrayElementsError indicates exceeded max number of elements for CBOR arrays.
 type MaxArrayElementsError struct {
 	maxArrayElements int
@@ -45,6 +224,9 @@ func (e *MaxArrayElementsError) Error() string {
 	return "cbor: exceeded max number of elements " + strconv.Itoa(e.maxArrayElements) + " for CBOR array"
 }
 
+// Is returns true if target is ErrLimitExceeded.
+func (e *MaxArrayElementsError) Is(target error) bool { return target == ErrLimitExceeded }
+
 // MaxMapPairsError indicates exceeded max number of key-value pairs for CBOR maps.
 type MaxMapPairsError struct {
 	maxMapPairs int
@@ -54,6 +236,39 @@ func (e *MaxMapPairsError) Error() string {
 	return "cbor: exceeded max number of key-value pairs " + strconv.Itoa(e.maxMapPairs) + " for CBOR map"
 }
 
+// Is returns true if target is ErrLimitExceeded.
+func (e *MaxMapPairsError) Is(target error) bool { return target == ErrLimitExceeded }
+
+// MaxStringBytesError indicates exceeded max length in bytes of CBOR byte string or text string.
+type MaxStringBytesError struct {
+	t              cborType
+	maxStringBytes int
+}
+
+func (e *MaxStringBytesError) Error() string {
+	return "cbor: exceeded max length " + strconv.Itoa(e.maxStringBytes) + " bytes for CBOR " + e.t.String()
+}
+
+// Is returns true if target is ErrLimitExceeded.
+func (e *MaxStringBytesError) Is(target error) bool { return target == ErrLimitExceeded }
+
+// MaxDecodedSizeError indicates exceeded max estimated size in bytes of Go values decoded
+// from a CBOR data item.
+type MaxDecodedSizeError struct {
+	maxDecodedSizeBytes int
+}
+
+func (e *MaxDecodedSizeError) Error() string {
+	return "cbor: exceeded max decoded size " + strconv.Itoa(e.maxDecodedSizeBytes) + " bytes"
+}
+
+// Is returns true if target is ErrLimitExceeded.
+func (e *MaxDecodedSizeError) Is(target error) bool { return target == ErrLimitExceeded }
+
+// decodedElementSize is the estimated decoded size in bytes of each array element and
+// each map key and value, which is the size of interface{} on 64-bit platforms.
+const decodedElementSize = 16
+
 // IndefiniteLengthError indicates found disallowed indefinite length items.
 type IndefiniteLengthError struct {
 	t cborType
@@ -81,6 +296,9 @@ func (e *ExtraneousDataError) Error() string {
 	return "cbor: " + strconv.Itoa(e.numOfBytes) + " bytes of extraneous data starting at index " + strconv.Itoa(e.index)
 }
 
+// Is returns true if target is ErrExtraneousData.
+func (e *ExtraneousDataError) Is(target error) bool { return target == ErrExtraneousData }
+
 // wellformed checks whether the CBOR data item is well-formed.
 // allowExtraData indicates if extraneous data is allowed after the CBOR data item.
 // - use allowExtraData = true when using Decoder.Decode()
@@ -89,7 +307,16 @@ func (d *decoder) wellformed(allowExtraData bool, checkBuiltinTags bool) error {
 	if len(d.data) == d.off {
 		return io.EOF
 	}
+	off := d.off
+	d.itemsDecoded, d.budgetOff = 0, d.off
+	d.decodedSize = 0
 	_, err := d.wellformedInternal(0, checkBuiltinTags)
+	if err != nil {
+		d.setErrorPath(err, off)
+	}
+	if err == nil && d.dm.budget != nil {
+		err = d.dm.budget.fn(d.itemsDecoded, d.off-d.budgetOff)
+	}
 	if err == nil {
 		if !allowExtraData && d.off != len(d.data) {
 			err = &ExtraneousDataError{len(d.data) - d.off, d.off}
@@ -99,18 +326,46 @@ func (d *decoder) wellformed(allowExtraData bool, checkBuiltinTags bool) error {
 }
 
 // wellformedInternal checks data's well-formedness and returns max depth and error.
-func (d *decoder) wellformedInternal(depth int, checkBuiltinTags bool) (int, error) { //nolint:gocyclo
-	t, _, val, indefiniteLength, err := d.wellformedHeadWithIndefiniteLengthFlag()
+func (d *decoder) wellformedInternal(depth int, checkBuiltinTags bool) (int, error) {
+	off := d.off
+	depth, err := d.wellformedDataItem(depth, checkBuiltinTags)
+	if err != nil {
+		setErrorOffset(err, off)
+	}
+	return depth, err
+}
+
+// wellformedDataItem checks data's well-formedness and returns max depth and error.
+func (d *decoder) wellformedDataItem(depth int, checkBuiltinTags bool) (int, error) { //nolint:gocyclo
+	t, ai, val, indefiniteLength, err := d.wellformedHeadWithIndefiniteLengthFlag()
 	if err != nil {
 		return 0, err
 	}
 
+	if d.dm.budget != nil {
+		d.itemsDecoded++
+		if d.itemsDecoded%budgetInterval == 0 {
+			if err := d.dm.budget.fn(d.itemsDecoded, d.off-d.budgetOff); err != nil {
+				return 0, err
+			}
+		}
+	}
+
 	switch t {
 	case cborTypeByteString, cborTypeTextString:
 		if indefiniteLength {
 			if d.dm.indefLength == IndefLengthForbidden {
 				return 0, &IndefiniteLengthError{t}
 			}
+			if d.dm.chunkedString == ChunkedStringForbidden {
+				return 0, &UnacceptableDataItemError{
+					CBORType: t.String(),
+					Message:  "indefinite-length string",
+				}
+			}
+			if d.dm.deterministic != DeterministicNotChecked {
+				return 0, newNonDeterministicError(t, "indefinite-length "+t.String())
+			}
 			return d.wellformedIndefiniteString(t, depth, checkBuiltinTags)
 		}
 		valInt := int(val)
@@ -118,6 +373,20 @@ func (d *decoder) wellformedInternal(depth int, checkBuiltinTags bool) (int, err
 			// Detect integer overflow
 			return 0, errors.New("cbor: " + t.String() + " length " + strconv.FormatUint(val, 10) + " is too large, causing integer overflow")
 		}
+		// Check max length before checking available data, so that streaming
+		// decoder doesn't buffer data for a string that exceeds the limit.
+		if maxBytes := d.maxStringBytes(t); maxBytes > 0 && valInt > maxBytes {
+			return 0, &MaxStringBytesError{t, maxBytes}
+		}
+		if d.dm.chunkedString == ChunkedStringRequired && valInt > d.dm.maxStringChunkSize {
+			return 0, &UnacceptableDataItemError{
+				CBORType: t.String(),
+				Message:  "definite-length string longer than " + strconv.Itoa(d.dm.maxStringChunkSize) + " bytes",
+			}
+		}
+		if err := d.addDecodedSize(valInt, 1); err != nil {
+			return 0, err
+		}
 		if len(d.data)-d.off < valInt { // valInt+off may overflow integer
 			return 0, io.ErrUnexpectedEOF
 		}
@@ -133,6 +402,9 @@ func (d *decoder) wellformedInternal(depth int, checkBuiltinTags bool) (int, err
 			if d.dm.indefLength == IndefLengthForbidden {
 				return 0, &IndefiniteLengthError{t}
 			}
+			if d.dm.deterministic != DeterministicNotChecked {
+				return 0, newNonDeterministicError(t, "indefinite-length "+t.String())
+			}
 			return d.wellformedIndefiniteArrayOrMap(t, depth, checkBuiltinTags)
 		}
 
@@ -156,9 +428,21 @@ func (d *decoder) wellformedInternal(depth int, checkBuiltinTags bool) (int, err
 		if t == cborTypeMap {
 			count = 2
 		}
+		if err := d.addDecodedSize(valInt, count*decodedElementSize); err != nil {
+			return 0, err
+		}
+		checkMapKeyOrder := t == cborTypeMap && d.dm.deterministic != DeterministicNotChecked
+		rejectDupMapKey := t == cborTypeMap && d.dm.dupMapKey == DupMapKeyReject
+		var prevKey []byte
+		var mapKeys map[interface{}]struct{}
+		if rejectDupMapKey {
+			mapKeys = make(map[interface{}]struct{})
+		}
 		maxDepth := depth
 		for j := 0; j < count; j++ {
 			for i := 0; i < valInt; i++ {
+				isMapKey := checkMapKeyOrder && (j*valInt+i)%2 == 0
+				keyOff := d.off
 				var dpt int
 				if dpt, err = d.wellformedInternal(depth, checkBuiltinTags); err != nil {
 					return 0, err
@@ -166,10 +450,37 @@ func (d *decoder) wellformedInternal(depth int, checkBuiltinTags bool) (int, err
 				if dpt > maxDepth {
 					maxDepth = dpt // Save max depth
 				}
+				if rejectDupMapKey && (j*valInt+i)%2 == 0 {
+					if err = d.wellformedMapKey(mapKeys, keyOff, (j*valInt+i)/2); err != nil {
+						return 0, err
+					}
+				}
+				if isMapKey {
+					key := d.data[keyOff:d.off]
+					if prevKey != nil && bytes.Compare(prevKey, key) >= 0 {
+						return 0, newNonDeterministicError(t, "map keys not in bytewise lexicographic order or duplicated")
+					}
+					prevKey = key
+				}
 			}
 		}
 		depth = maxDepth
 
+	case cborTypeNegativeInt:
+		if d.dm.deterministic == DeterministicDCBORRequired && val > math.MaxInt64 {
+			return 0, &UnacceptableDataItemError{
+				CBORType: t.String(),
+				Message:  "65-bit negative integer",
+			}
+		}
+
+	case cborTypePrimitives:
+		if d.dm.deterministic == DeterministicDCBORRequired {
+			if err := dcborPrimitive(ai, val); err != nil {
+				return 0, err
+			}
+		}
+
 	case cborTypeTag:
 		if d.dm.tagsMd == TagsForbidden {
 			return 0, &TagsMdError{}
@@ -188,6 +499,12 @@ func (d *decoder) wellformedInternal(depth int, checkBuiltinTags bool) (int, err
 					return 0, err
 				}
 			}
+			if d.dm.allowedTags != nil && !d.dm.allowedTags.contains(tagNum) {
+				return 0, &UnacceptableDataItemError{
+					CBORType: cborTypeTag.String(),
+					Message:  "tag number " + strconv.FormatUint(tagNum, 10),
+				}
+			}
 			if d.dm.bignumTag == BignumTagForbidden && (tagNum == 2 || tagNum == 3) {
 				return 0, &UnacceptableDataItemError{
 					CBORType: cborTypeTag.String(),
@@ -215,6 +532,8 @@ func (d *decoder) wellformedInternal(depth int, checkBuiltinTags bool) (int, err
 // wellformedIndefiniteString checks indefinite length byte/text string's well-formedness and returns max depth and error.
 func (d *decoder) wellformedIndefiniteString(t cborType, depth int, checkBuiltinTags bool) (int, error) {
 	var err error
+	maxBytes := d.maxStringBytes(t)
+	totalBytes := 0
 	for {
 		if len(d.data) == d.off {
 			return 0, io.ErrUnexpectedEOF
@@ -226,10 +545,22 @@ func (d *decoder) wellformedIndefiniteString(t cborType, depth int, checkBuiltin
 		// Peek ahead to get next type and indefinite length status.
 		nt, ai := parseInitialByte(d.data[d.off])
 		if t != nt {
-			return 0, &SyntaxError{"cbor: wrong element type " + nt.String() + " for indefinite-length " + t.String()}
+			return 0, &SyntaxError{msg: "cbor: wrong element type " + nt.String() + " for indefinite-length " + t.String()}
 		}
 		if additionalInformation(ai).isIndefiniteLength() {
-			return 0, &SyntaxError{"cbor: indefinite-length " + t.String() + " chunk is not definite-length"}
+			return 0, &SyntaxError{msg: "cbor: indefinite-length " + t.String() + " chunk is not definite-length"}
+		}
+		if maxBytes > 0 {
+			off := d.off
+			_, _, val, err := d.wellformedHead()
+			if err != nil {
+				return 0, err
+			}
+			d.off = off
+			if val > uint64(maxBytes-totalBytes) {
+				return 0, &MaxStringBytesError{t, maxBytes}
+			}
+			totalBytes += int(val)
 		}
 		if depth, err = d.wellformedInternal(depth, checkBuiltinTags); err != nil {
 			return 0, err
@@ -238,10 +569,58 @@ func (d *decoder) wellformedIndefiniteString(t cborType, depth int, checkBuiltin
 	return depth, nil
 }
 
+// addDecodedSize adds n items of size bytes to estimated decoded size, and returns
+// MaxDecodedSizeError if it exceeds max decoded size.
+func (d *decoder) addDecodedSize(n int, size int) error {
+	maxSize := d.dm.maxDecodedSizeBytes
+	if maxSize == 0 {
+		return nil
+	}
+	if n > (maxSize-d.decodedSize)/size {
+		return &MaxDecodedSizeError{maxSize}
+	}
+	d.decodedSize += n * size
+	return nil
+}
+
+// wellformedMapKey returns DupMapKeyError if well-formed map key d.data[off:d.off] at
+// map element index i is already in keys, and adds the key to keys otherwise.  Keys are
+// decoded to interface{} with default decoding options for comparison, so that decoding
+// keys doesn't depend on registered tags or other decoding options.
+func (d *decoder) wellformedMapKey(keys map[interface{}]struct{}, off int, i int) error {
+	b := d.data[off:d.off]
+	kd := decoder{data: b, dm: defaultDecMode}
+	k, err := kd.parse(true)
+	var ck interface{}
+	if err != nil {
+		// Keys that can't be decoded (e.g. invalid UTF-8) are compared by CBOR encoding.
+		ck = encodedMapKey(b)
+	} else {
+		ck = comparableMapKey(k, b)
+	}
+	if _, ok := keys[ck]; ok {
+		return &DupMapKeyError{ck, i}
+	}
+	keys[ck] = struct{}{}
+	return nil
+}
+
+// maxStringBytes returns max length in bytes of byte string or text string t, or 0 if there is no limit.
+func (d *decoder) maxStringBytes(t cborType) int {
+	if t == cborTypeTextString {
+		return d.dm.maxStringBytes
+	}
+	return d.dm.maxByteStringBytes
+}
+
 // wellformedIndefiniteArrayOrMap checks indefinite length array/map's well-formedness and returns max depth and error.
 func (d *decoder) wellformedIndefiniteArrayOrMap(t cborType, depth int, checkBuiltinTags bool) (int, error) {
 	var err error
 	maxDepth := depth
+	var mapKeys map[interface{}]struct{}
+	if t == cborTypeMap && d.dm.dupMapKey == DupMapKeyReject {
+		mapKeys = make(map[interface{}]struct{})
+	}
 	i := 0
 	for {
 		if len(d.data) == d.off {
@@ -251,6 +630,7 @@ func (d *decoder) wellformedIndefiniteArrayOrMap(t cborType, depth int, checkBui
 			d.off++
 			break
 		}
+		off := d.off
 		var dpt int
 		if dpt, err = d.wellformedInternal(depth, checkBuiltinTags); err != nil {
 			return 0, err
@@ -258,7 +638,15 @@ func (d *decoder) wellformedIndefiniteArrayOrMap(t cborType, depth int, checkBui
 		if dpt > maxDepth {
 			maxDepth = dpt
 		}
+		if mapKeys != nil && i%2 == 0 {
+			if err = d.wellformedMapKey(mapKeys, off, i/2); err != nil {
+				return 0, err
+			}
+		}
 		i++
+		if err := d.addDecodedSize(1, decodedElementSize); err != nil {
+			return 0, err
+		}
 		if t == cborTypeArray {
 			if i > d.dm.maxArrayElements {
 				return 0, &MaxArrayElementsError{d.dm.maxArrayElements}
@@ -270,7 +658,7 @@ func (d *decoder) wellformedIndefiniteArrayOrMap(t cborType, depth int, checkBui
 		}
 	}
 	if t == cborTypeMap && i%2 == 1 {
-		return 0, &SyntaxError{"cbor: unexpected \"break\" code"}
+		return 0, &SyntaxError{msg: "cbor: unexpected \"break\" code"}
 	}
 	return maxDepth, nil
 }
@@ -313,7 +701,10 @@ func (d *decoder) wellformedHead() (t cborType, ai byte, val uint64, err error)
 		val = uint64(d.data[d.off])
 		d.off++
 		if t == cborTypePrimitives && val < 32 {
-			return 0, 0, 0, &SyntaxError{"cbor: invalid simple value " + strconv.Itoa(int(val)) + " for type " + t.String()}
+			return 0, 0, 0, &SyntaxError{msg: "cbor: invalid simple value " + strconv.Itoa(int(val)) + " for type " + t.String()}
+		}
+		if err := d.deterministicArgument(t, val, 24); err != nil {
+			return 0, 0, 0, err
 		}
 		return t, ai, val, nil
 	}
@@ -329,6 +720,8 @@ func (d *decoder) wellformedHead() (t cborType, ai byte, val uint64, err error)
 			if err := d.acceptableFloat(float64(float16.Frombits(uint16(val)).Float32())); err != nil {
 				return 0, 0, 0, err
 			}
+		} else if err := d.deterministicArgument(t, val, math.MaxUint8+1); err != nil {
+			return 0, 0, 0, err
 		}
 		return t, ai, val, nil
 	}
@@ -344,6 +737,11 @@ func (d *decoder) wellformedHead() (t cborType, ai byte, val uint64, err error)
 			if err := d.acceptableFloat(float64(math.Float32frombits(uint32(val)))); err != nil {
 				return 0, 0, 0, err
 			}
+			if d.dm.deterministic != DeterministicNotChecked && fitsFloat16(uint32(val)) {
+				return 0, 0, 0, newNonDeterministicError(t, "floating-point value not in shortest form")
+			}
+		} else if err := d.deterministicArgument(t, val, math.MaxUint16+1); err != nil {
+			return 0, 0, 0, err
 		}
 		return t, ai, val, nil
 	}
@@ -359,6 +757,11 @@ func (d *decoder) wellformedHead() (t cborType, ai byte, val uint64, err error)
 			if err := d.acceptableFloat(math.Float64frombits(val)); err != nil {
 				return 0, 0, 0, err
 			}
+			if d.dm.deterministic != DeterministicNotChecked && fitsFloat32(val) {
+				return 0, 0, 0, newNonDeterministicError(t, "floating-point value not in shortest form")
+			}
+		} else if err := d.deterministicArgument(t, val, math.MaxUint32+1); err != nil {
+			return 0, 0, 0, err
 		}
 		return t, ai, val, nil
 	}
@@ -366,19 +769,24 @@ func (d *decoder) wellformedHead() (t cborType, ai byte, val uint64, err error)
 	if additionalInformation(ai).isIndefiniteLength() {
 		switch t {
 		case cborTypePositiveInt, cborTypeNegativeInt, cborTypeTag:
-			return 0, 0, 0, &SyntaxError{"cbor: invalid additional information " + strconv.Itoa(int(ai)) + " for type " + t.String()}
+			return 0, 0, 0, &SyntaxError{msg: "cbor: invalid additional information " + strconv.Itoa(int(ai)) + " for type " + t.String()}
 		case cborTypePrimitives: // 0xff (break code) should not be outside wellformedIndefinite().
-			return 0, 0, 0, &SyntaxError{"cbor: unexpected \"break\" code"}
+			return 0, 0, 0, &SyntaxError{msg: "cbor: unexpected \"break\" code"}
 		}
 		return t, ai, val, nil
 	}
 
 	// ai == 28, 29, 30
-	return 0, 0, 0, &SyntaxError{"cbor: invalid additional information " + strconv.Itoa(int(ai)) + " for type " + t.String()}
+	return 0, 0, 0, &SyntaxError{msg: "cbor: invalid additional information " + strconv.Itoa(int(ai)) + " for type " + t.String()}
 }
 
 func (d *decoder) acceptableFloat(f float64) error {
 	switch {
+	case d.dm.float == FloatForbidden:
+		return &UnacceptableDataItemError{
+			CBORType: cborTypePrimitives.String(),
+			Message:  "floating-point number",
+		}
 	case d.dm.nanDec == NaNDecodeForbidden && math.IsNaN(f):
 		return &UnacceptableDataItemError{
 			CBORType: cborTypePrimitives.String(),
@@ -392,3 +800,78 @@ func (d *decoder) acceptableFloat(f float64) error {
 	}
 	return nil
 }
+
+// dcborPrimitive returns error if CBOR simple value or floating-point number with
+// additional information ai and argument val doesn't comply with dCBOR.
+func dcborPrimitive(ai byte, val uint64) error {
+	var f float64
+	switch ai {
+	case additionalInformationAsFalse, additionalInformationAsTrue, additionalInformationAsNull:
+		return nil
+	case additionalInformationAsFloat16:
+		if val == 0x7e00 {
+			return nil
+		}
+		f = float64(float16.Frombits(uint16(val)).Float32())
+	case additionalInformationAsFloat32:
+		f = float64(math.Float32frombits(uint32(val)))
+	case additionalInformationAsFloat64:
+		f = math.Float64frombits(val)
+	default:
+		return &UnacceptableDataItemError{
+			CBORType: cborTypePrimitives.String(),
+			Message:  "simple value " + strconv.FormatUint(val, 10),
+		}
+	}
+	if math.IsNaN(f) {
+		return newNonDeterministicError(cborTypePrimitives, "NaN not encoded as 0xf97e00")
+	}
+	if f == math.Trunc(f) && f >= -(1<<63) && f < 1<<64 {
+		return newNonDeterministicError(cborTypePrimitives, "floating-point value not reduced to integer")
+	}
+	return nil
+}
+
+func newNonDeterministicError(t cborType, msg string) error {
+	return &UnacceptableDataItemError{
+		CBORType: t.String(),
+		Message:  "non-deterministic encoding: " + msg,
+	}
+}
+
+// deterministicArgument returns error if deterministic encoding is required and
+// argument val could have been encoded in a shorter form than the one used,
+// which can only encode values >= minVal.
+func (d *decoder) deterministicArgument(t cborType, val uint64, minVal uint64) error {
+	if d.dm.deterministic != DeterministicNotChecked && val < minVal {
+		return newNonDeterministicError(t, "argument not in shortest form")
+	}
+	return nil
+}
+
+// fitsFloat16 returns true if float32 (given as bits) can be encoded as float16 without losing
+// its value (or NaN payload).
+func fitsFloat16(bits uint32) bool {
+	f32 := math.Float32frombits(bits)
+	if math.IsNaN(float64(f32)) {
+		// float16 has 10 bits of mantissa vs. float32's 23 bits.
+		return bits&0x1fff == 0
+	}
+	p := float16.PrecisionFromfloat32(f32)
+	if p == float16.PrecisionUnknown {
+		// Try roundtrip float32->float16->float32 to determine if float32 can fit into float16.
+		return float16.Fromfloat32(f32).Float32() == f32
+	}
+	return p == float16.PrecisionExact
+}
+
+// fitsFloat32 returns true if float64 (given as bits) can be encoded as float32 without losing
+// its value (or NaN payload).
+func fitsFloat32(bits uint64) bool {
+	f64 := math.Float64frombits(bits)
+	if math.IsNaN(f64) {
+		// float32 has 23 bits of mantissa vs. float64's 52 bits.
+		return bits&0x1fffffff == 0
+	}
+	return float64(float32(f64)) == f64
+}
@@ -5,6 +5,7 @@ package cbor
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -296,3 +297,118 @@ func TestInvalidBuiltinTagTest(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodingErrorsIs(t *testing.T) {
+	dupMapKeyDM, _ := DecOptions{DupMapKey: DupMapKeyEnforcedAPF}.DecMode()
+	unknownFieldDM, _ := DecOptions{ExtraReturnErrors: ExtraDecErrorUnknownField}.DecMode()
+	maxNestedDM, _ := DecOptions{MaxNestedLevels: 4}.DecMode()
+	maxArrayDM, _ := DecOptions{MaxArrayElements: 16}.DecMode()
+
+	type s struct {
+		A int
+	}
+
+	testCases := []struct {
+		name    string
+		dm      DecMode
+		data    []byte
+		v       interface{}
+		wantErr []error
+	}{
+		{
+			name:    "syntax error",
+			dm:      defaultDecMode,
+			data:    hexDecode("1c"),
+			v:       new(interface{}),
+			wantErr: []error{ErrSyntax},
+		},
+		{
+			name:    "semantic error",
+			dm:      defaultDecMode,
+			data:    hexDecode("61fe"),
+			v:       new(string),
+			wantErr: []error{ErrSemantic},
+		},
+		{
+			name:    "unexpected EOF",
+			dm:      defaultDecMode,
+			data:    hexDecode("8201"),
+			v:       new(interface{}),
+			wantErr: []error{ErrUnexpectedEOF},
+		},
+		{
+			name:    "max nested level",
+			dm:      maxNestedDM,
+			data:    hexDecode("8181818181818101"),
+			v:       new(interface{}),
+			wantErr: []error{ErrMaxDepthExceeded, ErrLimitExceeded},
+		},
+		{
+			name:    "max array elements",
+			dm:      maxArrayDM,
+			data:    hexDecode("9811000000000000000000000000000000000000"),
+			v:       new(interface{}),
+			wantErr: []error{ErrLimitExceeded},
+		},
+		{
+			name:    "duplicate map key",
+			dm:      dupMapKeyDM,
+			data:    hexDecode("a2616101616102"),
+			v:       new(map[string]int),
+			wantErr: []error{ErrDuplicateMapKey},
+		},
+		{
+			name:    "unknown field",
+			dm:      unknownFieldDM,
+			data:    hexDecode("a2614101614202"),
+			v:       new(s),
+			wantErr: []error{ErrUnknownField},
+		},
+		{
+			name:    "type mismatch",
+			dm:      defaultDecMode,
+			data:    hexDecode("6161"),
+			v:       new(int),
+			wantErr: []error{ErrTypeMismatch},
+		},
+		{
+			name:    "extraneous data",
+			dm:      defaultDecMode,
+			data:    hexDecode("0101"),
+			v:       new(int),
+			wantErr: []error{ErrExtraneousData},
+		},
+	}
+
+	sentinels := []error{
+		ErrSyntax,
+		ErrSemantic,
+		ErrUnexpectedEOF,
+		ErrMaxDepthExceeded,
+		ErrLimitExceeded,
+		ErrDuplicateMapKey,
+		ErrUnknownField,
+		ErrTypeMismatch,
+		ErrExtraneousData,
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.dm.Unmarshal(tc.data, tc.v)
+			if err == nil {
+				t.Fatalf("Unmarshal(0x%x) didn't return an error", tc.data)
+			}
+			for _, sentinel := range sentinels {
+				want := false
+				for _, wantErr := range tc.wantErr {
+					if sentinel == wantErr {
+						want = true
+					}
+				}
+				if got := errors.Is(err, sentinel); got != want {
+					t.Errorf("errors.Is(%v, %v) = %t, want %t", err, sentinel, got, want)
+				}
+			}
+		})
+	}
+}
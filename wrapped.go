@@ -0,0 +1,74 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"errors"
+)
+
+// EncodeWrapped encodes v using default encoding options, and returns the encoded
+// CBOR data item wrapped in a definite-length CBOR byte string (envelope).
+//
+// See DecodeWrapped for unwrapping and decoding the returned data.
+func EncodeWrapped(v interface{}) ([]byte, error) {
+	return defaultEncMode.EncodeWrapped(v)
+}
+
+// DecodeWrapped decodes CBOR data item wrapped in a definite-length CBOR byte string
+// (envelope) into the value pointed to by v, using default decoding options.
+//
+// DecodeWrapped returns an error if data isn't exactly one well-formed CBOR byte string,
+// or if the byte string is indefinite-length.  The content of the byte string must be
+// exactly one CBOR data item: io.ErrUnexpectedEOF is returned if the data item is truncated,
+// and ExtraneousDataError is returned if there are any remaining bytes after the data item.
+//
+// See Unmarshal for details about decoding the wrapped data item.
+func DecodeWrapped(data []byte, v interface{}) error {
+	return defaultDecMode.DecodeWrapped(data, v)
+}
+
+// EncodeWrapped encodes v using em encoding mode, and returns the encoded CBOR data
+// item wrapped in a definite-length CBOR byte string (envelope).
+//
+// See the documentation for EncodeWrapped for details.
+func (em *encMode) EncodeWrapped(v interface{}) ([]byte, error) {
+	e := em.getBuffer()
+	defer em.putBuffer(e)
+
+	if err := encodeValue(e, em, v); err != nil {
+		return nil, err
+	}
+
+	var head bytes.Buffer
+	encodeHead(&head, byte(cborTypeByteString), uint64(e.Len()))
+
+	buf := make([]byte, head.Len()+e.Len())
+	n := copy(buf, head.Bytes())
+	copy(buf[n:], e.Bytes())
+	return buf, nil
+}
+
+// DecodeWrapped decodes CBOR data item wrapped in a definite-length CBOR byte string
+// (envelope) into the value pointed to by v, using dm decoding mode.
+//
+// See the documentation for DecodeWrapped for details.
+func (dm *decMode) DecodeWrapped(data []byte, v interface{}) error {
+	d := decoder{data: data, dm: dm}
+	if err := d.wellformed(false, false); err != nil {
+		return err
+	}
+	d.reset(data)
+
+	if t := d.nextCBORType(); t != cborTypeByteString {
+		return errors.New("cbor: wrapped CBOR data item must be enclosed in byte string, found " + t.String())
+	}
+
+	_, _, val, indefiniteLength := d.getHeadWithIndefiniteLengthFlag()
+	if indefiniteLength {
+		return errors.New("cbor: wrapped CBOR data item must be enclosed in definite-length byte string")
+	}
+
+	return dm.Unmarshal(d.data[d.off:d.off+int(val)], v)
+}
@@ -0,0 +1,143 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package cbor
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeWrapped(t *testing.T) {
+	testCases := []struct {
+		name string
+		v    interface{}
+		want []byte
+	}{
+		{
+			name: "int",
+			v:    1,
+			want: hexDecode("4101"),
+		},
+		{
+			name: "array",
+			v:    []int{1, 2},
+			want: hexDecode("43820102"),
+		},
+		{
+			name: "24-byte data item",
+			v:    bytes.Repeat([]byte{0}, 23),
+			want: hexDecode("5818" + "57" + "0000000000000000000000000000000000000000000000"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := EncodeWrapped(tc.v)
+			if err != nil {
+				t.Fatalf("EncodeWrapped(%v) returned error %v", tc.v, err)
+			}
+			if !bytes.Equal(b, tc.want) {
+				t.Errorf("EncodeWrapped(%v) = 0x%x, want 0x%x", tc.v, b, tc.want)
+			}
+
+			v := reflect.New(reflect.TypeOf(tc.v))
+			if err = DecodeWrapped(b, v.Interface()); err != nil {
+				t.Fatalf("DecodeWrapped(0x%x) returned error %v", b, err)
+			}
+			if !reflect.DeepEqual(v.Elem().Interface(), tc.v) {
+				t.Errorf("DecodeWrapped(0x%x) = %v, want %v", b, v.Elem().Interface(), tc.v)
+			}
+		})
+	}
+}
+
+func TestEncodeWrappedError(t *testing.T) {
+	_, err := EncodeWrapped(make(chan bool))
+	if _, ok := err.(*UnsupportedTypeError); !ok {
+		t.Errorf("EncodeWrapped() returned error %v (%T), want *UnsupportedTypeError", err, err)
+	}
+}
+
+func TestEncodeWrappedWithMode(t *testing.T) {
+	em, err := EncOptions{Sort: SortLengthFirst}.EncMode()
+	if err != nil {
+		t.Fatalf("EncMode() returned error %v", err)
+	}
+	b, err := em.(ExtendedEncMode).EncodeWrapped(map[string]int{"bb": 2, "a": 1})
+	if err != nil {
+		t.Fatalf("EncodeWrapped() returned error %v", err)
+	}
+	want := hexDecode("48a261610162626202") // h'a261610162626202' ({"a": 1, "bb": 2})
+	if !bytes.Equal(b, want) {
+		t.Errorf("EncodeWrapped() = 0x%x, want 0x%x", b, want)
+	}
+
+	dm, err := DecOptions{DupMapKey: DupMapKeyEnforcedAPF}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() returned error %v", err)
+	}
+	var v map[string]int
+	data := hexDecode("47a2616101616102") // h'a2616101616102' ({"a": 1, "a": 2})
+	if err = dm.(ExtendedDecMode).DecodeWrapped(data, &v); err == nil {
+		t.Errorf("DecodeWrapped(0x%x) didn't return an error", data)
+	} else if _, ok := err.(*DupMapKeyError); !ok {
+		t.Errorf("DecodeWrapped(0x%x) returned error %v (%T), want *DupMapKeyError", data, err, err)
+	}
+}
+
+func TestDecodeWrappedError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{
+			name:         "not byte string",
+			data:         hexDecode("820102"),
+			wantErrorMsg: "cbor: wrapped CBOR data item must be enclosed in byte string, found array",
+		},
+		{
+			name:         "indefinite-length byte string",
+			data:         hexDecode("5f43820102ff"),
+			wantErrorMsg: "cbor: wrapped CBOR data item must be enclosed in definite-length byte string",
+		},
+		{
+			name:         "truncated byte string",
+			data:         hexDecode("44820102"),
+			wantErrorMsg: io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			name:         "extra data after byte string",
+			data:         hexDecode("4382010200"),
+			wantErrorMsg: "cbor: 1 bytes of extraneous data starting at index 4",
+		},
+		{
+			name:         "truncated wrapped data item",
+			data:         hexDecode("428201"),
+			wantErrorMsg: io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			name:         "extra data after wrapped data item",
+			data:         hexDecode("448201020f"),
+			wantErrorMsg: "cbor: 1 bytes of extraneous data starting at index 3",
+		},
+		{
+			name:         "empty byte string",
+			data:         hexDecode("40"),
+			wantErrorMsg: io.EOF.Error(),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var v []int
+			err := DecodeWrapped(tc.data, &v)
+			if err == nil {
+				t.Errorf("DecodeWrapped(0x%x) didn't return an error", tc.data)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("DecodeWrapped(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
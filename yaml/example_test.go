@@ -0,0 +1,34 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package yaml_test
+
+import (
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/fxamacker/cbor/v2/yaml"
+)
+
+func ExampleFromCBOR() {
+	type Config struct {
+		Name  string `cbor:"1,keyasint"`
+		Ports []int  `cbor:"2,keyasint"`
+		Key   []byte `cbor:"3,keyasint"`
+	}
+	b, err := cbor.Marshal(Config{Name: "sensor-1", Ports: []int{80, 443}, Key: []byte{1, 2, 3, 4}})
+	if err != nil {
+		fmt.Println("error:", err)
+	}
+	y, err := yaml.FromCBOR(b)
+	if err != nil {
+		fmt.Println("error:", err)
+	}
+	fmt.Print(string(y))
+	// Output:
+	// "1": "sensor-1"
+	// "2":
+	//   - 80
+	//   - 443
+	// "3": "AQIDBA"
+}
@@ -0,0 +1,325 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+// Package yaml converts between CBOR data items and YAML documents, e.g. to view and
+// edit CBOR configuration files.
+//
+// Conversion uses the same typing rules as cbor.ToJSON and cbor.FromJSON, and YAML
+// documents are limited to the JSON-compatible subset of YAML: collections are written
+// in block style, and scalars are JSON values.  So a document converted from CBOR is
+// read by other YAML parsers as the same value as the JSON converted from CBOR.
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// FromCBOR converts a CBOR data item to a YAML document.  It returns an error if data
+// isn't a single well-formed CBOR data item, or if cbor.ToJSON can't convert it with opts.
+//
+// CBOR data item is converted to JSON by cbor.ToJSON, so byte strings are base64url-encoded
+// strings, map keys are strings, and so on.  Then non-empty arrays and maps are written as
+// block sequences and block mappings, and other values are written as JSON values (e.g.
+// strings are always double-quoted).
+func FromCBOR(data []byte, opts ...cbor.JSONOption) ([]byte, error) {
+	var j bytes.Buffer
+	if err := cbor.ToJSON(data, &j, opts...); err != nil {
+		return nil, err
+	}
+
+	var yw yamlWriter
+	v := json.RawMessage(j.Bytes())
+	if isScalarOrEmpty(v) {
+		yw.b.Write(v)
+		yw.b.WriteByte('\n')
+	} else if err := yw.block(v, 0); err != nil {
+		return nil, err
+	}
+	return yw.b.Bytes(), nil
+}
+
+type yamlWriter struct {
+	b bytes.Buffer
+}
+
+// isScalarOrEmpty returns true if compact JSON value v isn't a non-empty array or object.
+func isScalarOrEmpty(v json.RawMessage) bool {
+	return (v[0] != '[' && v[0] != '{') || len(v) == 2
+}
+
+// block writes non-empty JSON array or object v as block collection with indent.
+// The first line isn't indented, because it follows a sequence indicator or starts
+// the document.
+func (yw *yamlWriter) block(v json.RawMessage, indent int) error {
+	dec := json.NewDecoder(bytes.NewReader(v))
+	delim, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			yw.b.WriteString(strings.Repeat(" ", indent))
+		}
+		inSequence := delim == json.Delim('[')
+		if inSequence {
+			yw.b.WriteString("-")
+		} else {
+			k, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if err := yw.key(k.(string)); err != nil {
+				return err
+			}
+		}
+		var elem json.RawMessage
+		if err := dec.Decode(&elem); err != nil {
+			return err
+		}
+		if err := yw.value(elem, indent+2, inSequence); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// key writes map key k as double-quoted scalar followed by map value indicator.
+func (yw *yamlWriter) key(k string) error {
+	enc := json.NewEncoder(&yw.b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(k); err != nil {
+		return err
+	}
+	yw.b.Truncate(yw.b.Len() - 1) // Remove newline written by Encode
+	yw.b.WriteString(":")
+	return nil
+}
+
+// value writes JSON value v after a map key or sequence indicator.
+func (yw *yamlWriter) value(v json.RawMessage, indent int, inSequence bool) error {
+	if isScalarOrEmpty(v) {
+		yw.b.WriteString(" ")
+		yw.b.Write(v)
+		yw.b.WriteString("\n")
+		return nil
+	}
+	if inSequence {
+		yw.b.WriteString(" ")
+	} else {
+		yw.b.WriteString("\n" + strings.Repeat(" ", indent))
+	}
+	return yw.block(v, indent)
+}
+
+// ToCBOR converts a YAML document to a CBOR data item.  It accepts the JSON-compatible
+// subset of YAML written by FromCBOR:
+//   - block sequences and block mappings, including compact nested collections
+//     (e.g. "- - 1" and "- "a": 1"),
+//   - map keys that are double-quoted strings,
+//   - scalars and flow collections that are JSON values on a single line
+//     (e.g. "a", 1.5, null, [1, 2], and {"a": 1}),
+//   - comment lines and comments following values.
+//
+// Other YAML features, such as plain and single-quoted strings, tags, anchors, block
+// scalars, and multiple documents, aren't supported.  The document is converted to JSON
+// and then to CBOR by cbor.FromJSON, so an empty document is converted to null, and an
+// error is returned if a mapping has duplicate keys.
+func ToCBOR(data []byte) ([]byte, error) {
+	lines, err := splitLines(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return cbor.FromJSON(strings.NewReader("null"))
+	}
+
+	yp := yamlParser{lines: lines}
+	if err := yp.node(); err != nil {
+		return nil, err
+	}
+	if yp.i < len(yp.lines) {
+		l := yp.lines[yp.i]
+		if l.indent > 0 {
+			return nil, l.error("unexpected indentation")
+		}
+		return nil, l.error("unexpected content")
+	}
+	return cbor.FromJSON(&yp.b)
+}
+
+// yamlLine is a line of YAML document that isn't empty or a comment line.
+type yamlLine struct {
+	num    int    // line number starting at 1
+	indent int    // number of spaces before text
+	text   string // line content without indentation and trailing whitespace
+}
+
+func (l yamlLine) error(msg string) error {
+	return errors.New("yaml: line " + strconv.Itoa(l.num) + ": " + msg)
+}
+
+// splitLines returns lines of YAML document data, skipping empty lines and comment lines.
+func splitLines(data []byte) ([]yamlLine, error) {
+	if !utf8.Valid(data) {
+		return nil, errors.New("yaml: invalid UTF-8")
+	}
+	var lines []yamlLine
+	for i, s := range strings.Split(string(data), "\n") {
+		text := strings.TrimLeft(s, " ")
+		l := yamlLine{num: i + 1, indent: len(s) - len(text), text: strings.TrimRight(text, " \t\r")}
+		if l.text == "" || l.text[0] == '#' {
+			continue
+		}
+		if l.text[0] == '\t' {
+			return nil, l.error("tabs can't be used for indentation")
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+// yamlParser converts lines of YAML document to JSON.
+type yamlParser struct {
+	lines []yamlLine
+	i     int          // index of the current line
+	b     bytes.Buffer // JSON text
+}
+
+// node converts the block node starting at the current line.
+func (yp *yamlParser) node() error {
+	l := yp.lines[yp.i]
+	if isSequenceEntry(l.text) {
+		return yp.sequence(l.indent)
+	}
+	if _, _, ok := mappingKey(l.text); ok {
+		return yp.mapping(l.indent)
+	}
+	yp.i++
+	return yp.scalar(l, l.text)
+}
+
+// nested converts the block node on the lines following a map key or sequence indicator
+// with indent, or writes null if there isn't one.  Block sequence can be a map value with
+// the same indentation as its key.
+func (yp *yamlParser) nested(indent int, inMapping bool) error {
+	if yp.i < len(yp.lines) {
+		l := yp.lines[yp.i]
+		if l.indent > indent || (inMapping && l.indent == indent && isSequenceEntry(l.text)) {
+			return yp.node()
+		}
+	}
+	yp.b.WriteString("null")
+	return nil
+}
+
+// sequence converts block sequence with indent starting at the current line.
+func (yp *yamlParser) sequence(indent int) error {
+	yp.b.WriteByte('[')
+	for n := 0; yp.i < len(yp.lines); n++ {
+		l := yp.lines[yp.i]
+		if l.indent != indent || !isSequenceEntry(l.text) {
+			break
+		}
+		if n > 0 {
+			yp.b.WriteByte(',')
+		}
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if rest == "" || rest[0] == '#' {
+			yp.i++
+			if err := yp.nested(indent, false); err != nil {
+				return err
+			}
+			continue
+		}
+		// Node following sequence indicator on the same line is indented by the
+		// sequence indicator and the spaces after it.
+		yp.lines[yp.i] = yamlLine{num: l.num, indent: indent + len(l.text) - len(rest), text: rest}
+		if err := yp.node(); err != nil {
+			return err
+		}
+	}
+	yp.b.WriteByte(']')
+	return nil
+}
+
+// mapping converts block mapping with indent starting at the current line.
+func (yp *yamlParser) mapping(indent int) error {
+	yp.b.WriteByte('{')
+	for n := 0; yp.i < len(yp.lines); n++ {
+		l := yp.lines[yp.i]
+		if l.indent != indent {
+			break
+		}
+		k, rest, ok := mappingKey(l.text)
+		if !ok {
+			return l.error("expected map key")
+		}
+		if n > 0 {
+			yp.b.WriteByte(',')
+		}
+		yp.b.Write(k)
+		yp.b.WriteByte(':')
+		yp.i++
+
+		rest = strings.TrimLeft(rest, " ")
+		if rest == "" || rest[0] == '#' {
+			if err := yp.nested(indent, true); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := yp.scalar(l, rest); err != nil {
+			return err
+		}
+	}
+	yp.b.WriteByte('}')
+	return nil
+}
+
+// scalar converts JSON value s on line l, which can be followed by a comment.
+func (yp *yamlParser) scalar(l yamlLine, s string) error {
+	v, rest, err := jsonValue(s)
+	if err != nil {
+		return l.error("invalid JSON value: " + err.Error())
+	}
+	if rest != "" && (rest[0] != ' ' || strings.TrimLeft(rest, " ")[0] != '#') {
+		return l.error("unexpected content after JSON value")
+	}
+	yp.b.Write(v)
+	return nil
+}
+
+// isSequenceEntry returns true if s starts with sequence indicator.
+func isSequenceEntry(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
+
+// mappingKey returns double-quoted map key at the start of s and the rest of s following
+// map value indicator, or false if s doesn't start with a map key.
+func mappingKey(s string) (json.RawMessage, string, bool) {
+	if s[0] != '"' {
+		return nil, "", false
+	}
+	k, rest, err := jsonValue(s)
+	if err != nil || (rest != ":" && !strings.HasPrefix(rest, ": ")) {
+		return nil, "", false
+	}
+	return k, rest[1:], true
+}
+
+// jsonValue returns JSON value at the start of s and the rest of s.
+func jsonValue(s string) (json.RawMessage, string, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	var v json.RawMessage
+	if err := dec.Decode(&v); err != nil {
+		return nil, "", err
+	}
+	return v, s[dec.InputOffset():], nil
+}
@@ -0,0 +1,195 @@
+// Copyright (c) Faye Amacker. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root for license information.
+
+package yaml
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func hexDecode(s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestFromCBOR(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"positive integer", hexDecode("01"), "1\n"},
+		{"negative integer", hexDecode("3bffffffffffffffff"), "-18446744073709551616\n"},
+		{"float16", hexDecode("f93e00"), "1.5\n"},
+		{"float64", hexDecode("fbc010666666666666"), "-4.1\n"},
+		{"byte string", hexDecode("4401020304"), "\"AQIDBA\"\n"},
+		{"byte string with base64 encoding hint", hexDecode("d64401020304"), "\"AQIDBA==\"\n"},
+		{"positive bignum", hexDecode("c249010000000000000000"), "\"AQAAAAAAAAAA\"\n"},
+		{"text string", hexDecode("6161"), "\"a\"\n"},
+		{"text string with special characters", hexDecode("65613a0a3c22"), "\"a:\\n<\\\"\"\n"},
+		{"false", hexDecode("f4"), "false\n"},
+		{"null", hexDecode("f6"), "null\n"},
+		{"undefined", hexDecode("f7"), "null\n"},
+		{"empty array", hexDecode("80"), "[]\n"},
+		{"empty map", hexDecode("a0"), "{}\n"},
+		{"tag", hexDecode("c11a514b67b0"), "1363896240\n"},
+		{"array", hexDecode("83018202038105"), "- 1\n- - 2\n  - 3\n- - 5\n"},
+		{"indefinite-length array", hexDecode("9f01ff"), "- 1\n"},
+		{"array of maps", hexDecode("82a2616101616202a0"), "- \"a\": 1\n  \"b\": 2\n- {}\n"},
+		{
+			name: "map",
+			data: hexDecode("a401616120a161620161638261788100616444ffffffff"),
+			want: "\"1\": \"a\"\n\"-1\":\n  \"b\": 1\n\"c\":\n  - \"x\"\n  - - 0\n\"d\": \"_____w\"\n",
+		},
+		{"map with key needing escape", hexDecode("a1623c0a01"), "\"<\\n\": 1\n"},
+		{"indefinite-length map", hexDecode("bf616101ff"), "\"a\": 1\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FromCBOR(tc.data)
+			if err != nil {
+				t.Fatalf("FromCBOR(0x%x) returned error %v", tc.data, err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("FromCBOR(0x%x) = %q, want %q", tc.data, got, tc.want)
+			}
+
+			// Converting back to CBOR returns the same data item as converting the
+			// JSON produced by cbor.ToJSON.
+			var j bytes.Buffer
+			if err := cbor.ToJSON(tc.data, &j); err != nil {
+				t.Fatalf("ToJSON(0x%x) returned error %v", tc.data, err)
+			}
+			want, err := cbor.FromJSON(&j)
+			if err != nil {
+				t.Fatalf("FromJSON(%q) returned error %v", j.String(), err)
+			}
+			data, err := ToCBOR(got)
+			if err != nil {
+				t.Fatalf("ToCBOR(%q) returned error %v", got, err)
+			}
+			if !bytes.Equal(data, want) {
+				t.Errorf("ToCBOR(%q) = 0x%x, want 0x%x", got, data, want)
+			}
+		})
+	}
+}
+
+func TestFromCBOROptions(t *testing.T) {
+	data := hexDecode("c249010000000000000000") // 18446744073709551616
+	want := "18446744073709551616\n"
+	got, err := FromCBOR(data, cbor.WithJSONBignumAsNumber())
+	if err != nil {
+		t.Fatalf("FromCBOR(0x%x) returned error %v", data, err)
+	}
+	if string(got) != want {
+		t.Errorf("FromCBOR(0x%x) = %q, want %q", data, got, want)
+	}
+
+	data = hexDecode("f7") // undefined
+	wantErrorMsg := "cbor: undefined can't be converted to JSON"
+	if _, err := FromCBOR(data, cbor.WithJSONStrict()); err == nil {
+		t.Errorf("FromCBOR(0x%x) didn't return an error", data)
+	} else if err.Error() != wantErrorMsg {
+		t.Errorf("FromCBOR(0x%x) returned error %q, want %q", data, err.Error(), wantErrorMsg)
+	}
+}
+
+func TestFromCBORError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantErrorMsg string
+	}{
+		{"malformed data", hexDecode("81"), "unexpected EOF"},
+		{"extraneous data", hexDecode("0102"), "cbor: 1 bytes of extraneous data starting at index 1"},
+		{"array map key", hexDecode("a18001"), "cbor: map key of CBOR type array can't be converted to JSON"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := FromCBOR(tc.data)
+			if err == nil {
+				t.Errorf("FromCBOR(0x%x) didn't return an error", tc.data)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("FromCBOR(0x%x) returned error %q, want %q", tc.data, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}
+
+func TestToCBOR(t *testing.T) {
+	testCases := []struct {
+		name string
+		yaml string
+		want []byte
+	}{
+		{"empty document", "", hexDecode("f6")},
+		{"comment only", "# comment\n", hexDecode("f6")},
+		{"null", "null", hexDecode("f6")},
+		{"bool", "true", hexDecode("f5")},
+		{"integer", "10", hexDecode("0a")},
+		{"negative integer", "-18446744073709551616", hexDecode("3bffffffffffffffff")},
+		{"positive bignum", "18446744073709551616", hexDecode("c249010000000000000000")},
+		{"float", "1.5", hexDecode("f93e00")},
+		{"float64", "-4.1", hexDecode("fbc010666666666666")},
+		{"string", `"a\tb\u00e9"`, hexDecode("65610962c3a9")},
+		{"string with comment", `"a # b" # comment`, hexDecode("656120232062")},
+		{"flow sequence", `[1, ["a"], {}]`, hexDecode("8301816161a0")},
+		{"flow mapping", `{"a": 1, "b": [2]}`, hexDecode("a261610161628102")},
+		{"block sequence", "- 1\n-\n- - \"a\"\n  - \"b\"\n-\n  \"c\": 2\n", hexDecode("8401f68261616162a1616302")},
+		{"block sequence with comments", "# list\n- 1 # one\n\n-   2\n", hexDecode("820102")},
+		{"block mapping", "\"a\":\n  \"b\": 1\n  \"c\":\n  - \"x\"\n\"d\": null\n", hexDecode("a26161a261620161638161786164f6")},
+		{"sequence of mappings", "- \"a\": 1\n  \"b\": 2\n- \"c\":\n", hexDecode("82a2616101616202a16163f6")},
+		{"mapping with CRLF line breaks", "\"a\": 1\r\n\"b\": 2\r\n", hexDecode("a2616101616202")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ToCBOR([]byte(tc.yaml))
+			if err != nil {
+				t.Fatalf("ToCBOR(%q) returned error %v", tc.yaml, err)
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("ToCBOR(%q) = 0x%x, want 0x%x", tc.yaml, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestToCBORError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		yaml         string
+		wantErrorMsg string
+	}{
+		{"invalid UTF-8", "\xff", "yaml: invalid UTF-8"},
+		{"tab indentation", "\"a\":\n\t\"b\": 1", "yaml: line 2: tabs can't be used for indentation"},
+		{"plain scalar", "a", "yaml: line 1: invalid JSON value: invalid character 'a' looking for beginning of value"},
+		{"plain map key", "a: 1", "yaml: line 1: invalid JSON value: invalid character 'a' looking for beginning of value"},
+		{"tag", "!!binary AQ==", "yaml: line 1: invalid JSON value: invalid character '!' looking for beginning of value"},
+		{"multi-line flow collection", "[1,\n2]", "yaml: line 1: invalid JSON value: unexpected EOF"},
+		{"content after value", "\"a\": 1 2", "yaml: line 1: unexpected content after JSON value"},
+		{"comment without preceding space", "[1]#c", "yaml: line 1: unexpected content after JSON value"},
+		{"map value without preceding space", "\"a\":1", "yaml: line 1: unexpected content after JSON value"},
+		{"unexpected indentation", "\"a\": 1\n  \"b\": 2", "yaml: line 2: unexpected indentation"},
+		{"sequence entry in mapping", "\"a\": 1\n- 2", "yaml: line 2: expected map key"},
+		{"mapping entry after sequence", "- 1\n\"b\": 2", "yaml: line 2: unexpected content"},
+		{"duplicate map key", "\"a\": 1\n\"a\": 2", "cbor: duplicate map key \"a\" in JSON"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ToCBOR([]byte(tc.yaml))
+			if err == nil {
+				t.Errorf("ToCBOR(%q) didn't return an error", tc.yaml)
+			} else if err.Error() != tc.wantErrorMsg {
+				t.Errorf("ToCBOR(%q) returned error %q, want %q", tc.yaml, err.Error(), tc.wantErrorMsg)
+			}
+		})
+	}
+}